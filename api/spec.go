@@ -0,0 +1,8 @@
+// Package api holds the OpenAPI document of the service, so it is built
+// into the binary and served from the same source it is edited in
+package api
+
+import _ "embed"
+
+//go:embed openapi.yaml
+var OpenAPI []byte
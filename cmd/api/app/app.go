@@ -1,37 +1,124 @@
 package app
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	_ "github.com/lib/pq"
 
+	"github.com/XSAM/otelsql"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
+	"github.com/pelyams/simpler_go_service/api"
 	"github.com/pelyams/simpler_go_service/internal/adapters/cache"
+	"github.com/pelyams/simpler_go_service/internal/adapters/codec"
+	"github.com/pelyams/simpler_go_service/internal/adapters/consumer"
+	"github.com/pelyams/simpler_go_service/internal/adapters/events"
+	"github.com/pelyams/simpler_go_service/internal/adapters/feed"
+	"github.com/pelyams/simpler_go_service/internal/adapters/grpcserver"
+	"github.com/pelyams/simpler_go_service/internal/adapters/jwks"
+	"github.com/pelyams/simpler_go_service/internal/adapters/metrics"
+	"github.com/pelyams/simpler_go_service/internal/adapters/objectstore"
 	"github.com/pelyams/simpler_go_service/internal/adapters/repository"
+	"github.com/pelyams/simpler_go_service/internal/adapters/search"
+	"github.com/pelyams/simpler_go_service/internal/adapters/tracing"
 	"github.com/pelyams/simpler_go_service/internal/config"
+	"github.com/pelyams/simpler_go_service/internal/domain"
 	"github.com/pelyams/simpler_go_service/internal/ports"
 	"github.com/pelyams/simpler_go_service/internal/routing"
 	"github.com/pelyams/simpler_go_service/internal/service"
 )
 
+const supplierSyncJob = "supplier-sync"
+
+// scheduledJobs may be given schedules in config, even if disabled
+var scheduledJobs = []string{supplierSyncJob}
+
+// applyLimits fits GOMAXPROCS and GOMEMLIMIT to container limits unless
+// they are set explicitly. Part of memory is left for what Go does not
+// count against the limit, like stacks of cgo
+func applyLimits(limits config.Limits) {
+	if _, ok := os.LookupEnv("GOMAXPROCS"); !ok && limits.CPUs > 0 {
+		runtime.GOMAXPROCS(max(1, int(limits.CPUs)))
+	}
+	if _, ok := os.LookupEnv("GOMEMLIMIT"); !ok && limits.MemoryBytes > 0 {
+		debug.SetMemoryLimit(limits.MemoryBytes / 10 * 9)
+	}
+}
+
+type backgroundWorker interface {
+	Run(ctx context.Context) error
+	Close() error
+}
+
 type App struct {
-	config     *config.Config
-	db         ports.Repository
-	cache      ports.Cache
-	service    ports.ResourseService
-	handler    *routing.ProductHandler
-	router     *http.Handler
-	middleware *routing.Logger
+	config      *config.Config
+	db          ports.Repository
+	cache       ports.Cache
+	service     ports.ResourseService
+	handler     *routing.ProductHandler
+	router      *http.Handler
+	adminRouter *http.Handler
+	// nil unless GRPC_PORT is set
+	grpcServer *grpc.Server
+	// nil for listeners that do not require client certificates
+	tlsConfig      *tls.Config
+	adminTLSConfig *tls.Config
+	middleware     *routing.Logger
+	workers        []backgroundWorker
+	publisher      ports.EventPublisher
+	drainer        *routing.Drainer
+	// nil unless OTLP endpoint is set
+	tracerProvider *sdktrace.TracerProvider
+	route          func(r *http.Request) string
 }
 
 func New() (*App, error) {
+	// sized before config is loaded, pool defaults follow GOMAXPROCS
+	limits := config.DetectLimits()
+	applyLimits(limits)
 	cfg := config.Load()
 
+	// clients are instrumented as they are created, so tracing comes first
+	var tracerProvider *sdktrace.TracerProvider
+	if cfg.OTLPEndpoint != "" {
+		if cfg.TraceSampleRatio < 0 || cfg.TraceSampleRatio > 1 {
+			return nil, fmt.Errorf("trace sample ratio must be within [0, 1], got %v", cfg.TraceSampleRatio)
+		}
+		provider, err := tracing.NewTracerProvider(context.Background(), cfg.OTLPEndpoint, cfg.ServiceName, cfg.TraceSampleRatio)
+		if err != nil {
+			return nil, err
+		}
+		tracerProvider = provider
+	}
+
 	dbConnetionStr := fmt.Sprintf(
 		"postgres://%s:%s@%s/%s?sslmode=disable",
 		cfg.DatabaseUser,
@@ -39,45 +126,816 @@ func New() (*App, error) {
 		cfg.DatabaseHost,
 		cfg.DatabaseName,
 	)
-	databaseClient, err := sql.Open("postgres", dbConnetionStr)
+	databaseClient, err := openPostgres(dbConnetionStr, tracerProvider)
 	if err != nil {
 		log.Fatal(err)
 	}
+	databaseClient.SetMaxOpenConns(cfg.DatabaseMaxOpenConns)
+	databaseClient.SetMaxIdleConns(cfg.DatabaseMaxOpenConns)
 
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:     cfg.RedisHost + ":" + cfg.RedisPort,
-		Password: cfg.RedisPassword,
-		DB:       0,
+		Addr:            cfg.RedisHost + ":" + cfg.RedisPort,
+		Password:        cfg.RedisPassword,
+		DB:              0,
+		PoolSize:        cfg.RedisPoolSize,
+		MinIdleConns:    cfg.RedisMinIdleConns,
+		PoolTimeout:     cfg.RedisPoolTimeout,
+		DialTimeout:     cfg.RedisDialTimeout,
+		ReadTimeout:     cfg.RedisReadTimeout,
+		WriteTimeout:    cfg.RedisWriteTimeout,
+		MaxRetries:      cfg.RedisMaxRetries,
+		MinRetryBackoff: cfg.RedisMinRetryBackoff,
+		MaxRetryBackoff: cfg.RedisMaxRetryBackoff,
 	})
 	redisClient.ConfigSet(context.Background(), "maxmemory", "10mb")
 	redisClient.ConfigSet(context.Background(), "maxmemory-policy", "allkeys-lru")
+	if tracerProvider != nil {
+		if err := redisotel.InstrumentTracing(redisClient, redisotel.WithTracerProvider(tracerProvider)); err != nil {
+			return nil, fmt.Errorf("failed to instrument redis client: %w", err)
+		}
+	}
 
-	repo := repository.NewPostgresRepository(databaseClient)
-	cache := cache.NewRedisCache(redisClient)
-	service := service.NewResourceService(repo, cache)
-
-	handler := routing.NewProductHandler(service)
-	router := routing.NewRouter(handler).SetupRoutes()
 	logFile := cfg.LogFile
 	if logFile == "" {
 		logFile = "app.log"
 	}
 
-	logger, err := routing.NewLogger(0, logFile)
+	var loggerOpts []routing.LoggerOption
+	if cfg.LogMaxSizeMB > 0 {
+		loggerOpts = append(loggerOpts, routing.WithRotation(routing.Rotation{
+			MaxSizeMB:  cfg.LogMaxSizeMB,
+			MaxAge:     cfg.LogMaxAge,
+			MaxBackups: cfg.LogMaxBackups,
+			Compress:   cfg.LogCompress,
+		}))
+	}
+	logger, err := routing.NewLogger(logFile, loggerOpts...)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		log.Fatal(err)
+	}
+	if err := logger.SetFormat(cfg.LogFormat); err != nil {
+		log.Fatal(err)
+	}
+	// records of log package and of libraries logging through slog are
+	// structured as well
+	slog.SetDefault(logger.Slog())
+	logger.Slog().Info("runtime sized",
+		slog.Float64("cpu_limit", limits.CPUs),
+		slog.Int64("memory_limit_bytes", limits.MemoryBytes),
+		slog.Int("gomaxprocs", runtime.GOMAXPROCS(0)),
+		slog.Int64("gomemlimit", debug.SetMemoryLimit(-1)),
+		slog.Int("worker_pool_size", cfg.WorkerPoolSize),
+		slog.Int("db_max_open_conns", cfg.DatabaseMaxOpenConns),
+	)
+
+	if cfg.FuzzySearchThreshold < 0 || cfg.FuzzySearchThreshold > 1 {
+		return nil, fmt.Errorf("fuzzy search threshold must be within [0, 1], got %v", cfg.FuzzySearchThreshold)
+	}
+	transportMetrics := metrics.NewTransportMetrics()
+	repoOpts := []repository.Option{
+		repository.WithSimilarityThreshold(cfg.FuzzySearchThreshold),
+		repository.WithQueryMetrics(transportMetrics),
+	}
+	if cfg.OutboxEnabled {
+		repoOpts = append(repoOpts, repository.WithOutbox())
+	}
+	if cfg.AuditTrail {
+		repoOpts = append(repoOpts, repository.WithAuditTrail())
+	}
+	repo := repository.NewPostgresRepository(databaseClient, repoOpts...)
+	var catalog ports.Repository = repo
+	var shardClients []*sql.DB
+	if cfg.DatabaseShards != "" {
+		sharded, clients, err := newShardedRepository(cfg, repo, repoOpts, tracerProvider)
+		if err != nil {
+			return nil, err
+		}
+		catalog, shardClients = sharded, clients
+	}
+	confirmations := cache.NewRedisConfirmationStore(redisClient)
+	writeDedup := cache.NewRedisWriteDedupStore(redisClient)
+	signatureReplays := cache.NewRedisSignatureReplayStore(redisClient)
+	usageCounter := cache.NewRedisUsageCounter(redisClient)
+	rateLimiter := cache.NewRedisRateLimiter(redisClient)
+	hostname, _ := os.Hostname()
+	leaderLease := cache.NewRedisLeaderLease(redisClient, "jobs", hostname+"-"+uuid.NewString())
+	cache := cache.NewRedisCache(redisClient, cache.WithMetrics(transportMetrics))
+	businessMetrics := metrics.NewBusinessMetrics(catalog)
+	businessMetrics.Registry().MustRegister(collectors.NewDBStatsCollector(databaseClient, cfg.DatabaseName))
+	for i, client := range shardClients {
+		businessMetrics.Registry().MustRegister(collectors.NewDBStatsCollector(client, fmt.Sprintf("shard_%d", i+1)))
+	}
+	serviceOpts := []service.Option{
+		service.WithBusinessMetrics(businessMetrics),
+		service.WithParallelExport(cfg.ExportWorkers, cfg.ExportChunkSize),
+	}
+	if cfg.SuggestCacheTTL > 0 {
+		serviceOpts = append(serviceOpts, service.WithSuggestionCache(cfg.SuggestCacheTTL, cfg.SuggestCacheSize))
+	}
+	if cfg.ReadYourWritesWindow > 0 {
+		serviceOpts = append(serviceOpts, service.WithReadYourWrites(cfg.ReadYourWritesWindow))
+	}
+	if tracerProvider != nil {
+		serviceOpts = append(serviceOpts, service.WithTracerProvider(tracerProvider))
+	}
+	publisher, err := newEventPublisher(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.S3Endpoint != "" {
+		objects, err := objectstore.NewS3Store(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3UseSSL, cfg.S3PublicURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		serviceOpts = append(serviceOpts, service.WithObjectStore(objects))
+	}
+	if cfg.ElasticsearchURL != "" {
+		searchIndex := search.NewElasticsearchIndex(
+			&http.Client{Timeout: 5 * time.Second},
+			cfg.ElasticsearchURL,
+			cfg.ElasticsearchIndex,
+			cfg.ElasticsearchUsername,
+			cfg.ElasticsearchPassword,
+		)
+		if err := searchIndex.EnsureIndex(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+		serviceOpts = append(serviceOpts, service.WithSearchIndex(searchIndex))
+	}
+	handlerOpts := []routing.HandlerOption{
+		routing.WithBasePath(cfg.BasePath),
+		routing.WithResponseEnvelope(cfg.ResponseEnvelope),
+		routing.WithMaxDecompressedBody(int64(cfg.MaxDecompressedBodyBytes)),
+		routing.WithMaxImageBytes(int64(cfg.MaxImageBytes)),
+		routing.WithDeleteConfirmation(confirmations, cfg.DeleteConfirmationTTL),
+	}
+	switch cfg.JSONCodec {
+	case "", "std":
+	case "jsoniter":
+		handlerOpts = append(handlerOpts, routing.WithJSONCodec(codec.NewJsoniter()))
+	default:
+		return nil, fmt.Errorf("unknown json codec %q", cfg.JSONCodec)
+	}
+	if cfg.ListMemoWindow > 0 {
+		handlerOpts = append(handlerOpts, routing.WithListMemo(cfg.ListMemoWindow))
+	}
+	deprecations, err := routing.ParseDeprecations(cfg.DeprecatedRoutes)
+	if err != nil {
+		return nil, err
+	}
+	handlerOpts = append(handlerOpts, routing.WithDeprecations(deprecations))
+	openAPI, err := routing.OpenAPIJSON(api.OpenAPI, cfg.BasePath)
+	if err != nil {
+		return nil, err
+	}
+	handlerOpts = append(handlerOpts, routing.WithOpenAPI(openAPI, cfg.SwaggerUI))
+	// shared by HTTP and gRPC listeners
+	var grpcAuthOpts []grpcserver.AuthOption
+	if cfg.Authorization {
+		authorizer, err := newAuthorizer(cfg)
+		if err != nil {
+			return nil, err
+		}
+		handlerOpts = append(handlerOpts, routing.WithAuthorizer(authorizer))
+		grpcAuthOpts = append(grpcAuthOpts, grpcserver.WithAuthorizer(authorizer))
+	}
+	if cfg.RequireVersion {
+		handlerOpts = append(handlerOpts, routing.WithVersionRequired())
+		serviceOpts = append(serviceOpts, service.WithVersionRequired())
+	}
+	if cfg.AuditTrail {
+		handlerOpts = append(handlerOpts, routing.WithAuditTrail(catalog.(ports.AuditTrail)))
+	}
+	if cfg.DatabaseShards == "" {
+		handlerOpts = append(handlerOpts, routing.WithCategories(repo))
+	}
+	if cfg.ChangeFeedSize > 0 {
+		changes := events.NewChangeFeed(cfg.ChangeFeedSize)
+		if publisher != nil {
+			publisher = events.NewMultiPublisher(publisher, changes)
+		} else {
+			publisher = changes
+		}
+		handlerOpts = append(handlerOpts, routing.WithChangeFeed(changes, cfg.ChangesMaxWait))
+	}
+	if cfg.Webhooks {
+		webhooks := events.NewWebhookPublisher(repo)
+		if publisher != nil {
+			publisher = events.NewMultiPublisher(publisher, webhooks)
+		} else {
+			publisher = webhooks
+		}
+	}
+	if publisher != nil {
+		serviceOpts = append(serviceOpts, service.WithEventPublisher(publisher))
+	}
+	var productRepo ports.Repository = catalog
+	var breaker *repository.CircuitBreaker
+	if cfg.DatabaseBreakerThreshold > 0 {
+		breaker = repository.NewCircuitBreaker(catalog, cfg.DatabaseBreakerThreshold, cfg.DatabaseBreakerCooldown)
+		productRepo = breaker
+		serviceOpts = append(serviceOpts, service.WithCircuitBreaker(breaker))
+		if cfg.DegradedReads {
+			serviceOpts = append(serviceOpts, service.WithDegradedReads())
+		}
+	}
+	var healthWatcher *service.HealthWatcher
+	if cfg.HealthCheckInterval > 0 {
+		postgresProbe := service.HealthProbe{
+			Name: "postgres",
+			// instances serving degraded reads are still worth routing to
+			Critical: breaker == nil || !cfg.DegradedReads,
+			Check:    databaseClient.PingContext,
+		}
+		if breaker != nil {
+			postgresProbe.OnChange = func(healthy bool) { breaker.SetDown(!healthy) }
+		}
+		redisProbe := service.HealthProbe{
+			Name:  "redis",
+			Check: func(ctx context.Context) error { return redisClient.Ping(ctx).Err() },
+		}
+		probes := []service.HealthProbe{postgresProbe, redisProbe}
+		for i, client := range shardClients {
+			probes = append(probes, service.HealthProbe{
+				Name:     fmt.Sprintf("postgres-shard-%d", i+1),
+				Critical: postgresProbe.Critical,
+				Check:    client.PingContext,
+			})
+		}
+		healthWatcher = service.NewHealthWatcher(probes,
+			cfg.HealthCheckInterval, cfg.HealthCheckTimeout, cfg.HealthCheckFailures, logger.Slog())
+		serviceOpts = append(serviceOpts, service.WithCacheBreaker(healthWatcher.Breaker("redis")))
+	}
+	// readiness waits for these once, later outages are left to health checks
+	startupChecks := []service.StartupCheck{
+		{Name: "postgres-schema", Check: repo.CheckSchema},
+	}
+	for i, client := range shardClients {
+		shard := repository.NewPostgresRepository(client, repoOpts...)
+		startupChecks = append(startupChecks, service.StartupCheck{
+			Name:  fmt.Sprintf("postgres-shard-%d-schema", i+1),
+			Check: shard.CheckSchema,
+		})
+	}
+	if warmConns := min(cfg.DatabaseWarmConns, cfg.DatabaseMaxOpenConns); warmConns > 0 {
+		for i, client := range append([]*sql.DB{databaseClient}, shardClients...) {
+			name := "postgres-pool"
+			if i > 0 {
+				name = fmt.Sprintf("postgres-shard-%d-pool", i)
+			}
+			startupChecks = append(startupChecks, service.StartupCheck{
+				Name:  name,
+				Check: func(ctx context.Context) error { return repository.WarmPool(ctx, client, warmConns) },
+			})
+		}
+	}
+	startupChecks = append(startupChecks, service.StartupCheck{
+		Name:  "redis",
+		Check: func(ctx context.Context) error { return redisClient.Ping(ctx).Err() },
+	})
+	startup := service.NewStartupGate(startupChecks, cfg.StartupCheckInterval, cfg.StartupCheckTimeout, logger.Slog())
+
+	var pool *service.WorkerPool
+	if cfg.WorkerPoolSize > 0 {
+		pool = service.NewWorkerPool(cfg.WorkerPoolSize, cfg.WorkerQueueSize, cfg.WorkerTaskTimeout, logger.Slog())
+		serviceOpts = append(serviceOpts, service.WithWorkerPool(pool))
+		businessMetrics.Registry().MustRegister(metrics.NewWorkerPoolCollector(pool))
+	}
+	svc := service.NewResourceService(productRepo, cache, serviceOpts...)
+
+	drainer := routing.NewDrainer()
+	adminOpts := []routing.AdminOption{
+		routing.WithDrainer(drainer),
+		routing.WithBusinessMetrics(promhttp.HandlerFor(businessMetrics.Registry(), promhttp.HandlerOpts{})),
+		routing.WithMetrics(promhttp.HandlerFor(transportMetrics.Registry(), promhttp.HandlerOpts{})),
+		// requires authenticated clients, so it is only reachable if admin
+		// listener requires client certificates
+		routing.WithDataset(svc),
+		routing.WithStartup(startup),
+		routing.WithProfiling(cfg.Profiling),
+	}
+	if healthWatcher != nil {
+		adminOpts = append(adminOpts, routing.WithHealth(healthWatcher))
+	}
+
+	if cfg.GRPCGateway {
+		gateway, err := grpcserver.NewGateway(svc, logger.Slog())
+		if err != nil {
+			return nil, err
+		}
+		handlerOpts = append(handlerOpts, routing.WithGateway(gateway))
+	}
+	var apiKeys *routing.APIKeyAuthenticator
+	switch cfg.APIKeyAuth {
+	case "off":
+	case "writes", "all":
+		apiKeys = routing.NewAPIKeyAuthenticator(repo, cfg.APIKeyAuth == "all", cfg.APIKeyCacheTTL)
+		handlerOpts = append(handlerOpts, routing.WithAPIKeyPolicy(apiKeys))
+		grpcAuthOpts = append(grpcAuthOpts, grpcserver.WithAPIKeys(apiKeys))
+		adminOpts = append(adminOpts, routing.WithAPIKeys(repo))
+	default:
+		return nil, fmt.Errorf("unknown API key auth mode %q", cfg.APIKeyAuth)
+	}
+	handler := routing.NewProductHandler(svc, handlerOpts...)
+	routes := routing.NewRouter(handler)
+	router := routes.SetupRoutes()
+	if cfg.WriteDedupWindow > 0 {
+		router = routing.NewWriteDeduplicator(writeDedup, cfg.WriteDedupWindow, cfg.WriteDedupReplay).Middleware(router)
+	}
+	if cfg.RateLimitTiers != "" {
+		tiers, err := routing.ParseRateLimitTiers(cfg.RateLimitTiers)
+		if err != nil {
+			return nil, err
+		}
+		clientTiers, err := routing.ParseClientTiers(cfg.RateLimitClientTiers)
+		if err != nil {
+			return nil, err
+		}
+		limiter, err := routing.NewRateLimiter(rateLimiter, tiers, clientTiers)
+		if err != nil {
+			return nil, err
+		}
+		// inside of usage counting, so rejected requests are counted too
+		router = limiter.Middleware(router)
+	}
+	if cfg.UsageAnalytics {
+		// inside of signature verification, which sets principal of signed requests
+		router = routing.UsageMiddleware(usageCounter, router)
+		adminOpts = append(adminOpts, routing.WithUsage(repo))
+	}
+	if apiKeys != nil {
+		// inside of request timeout, which bounds key lookups, and outside of
+		// usage counting and rate limiting, which need principal
+		router = apiKeys.Middleware(router)
+	}
+	if cfg.JWTIssuer != "" {
+		if cfg.JWTJWKSURL == "" {
+			return nil, fmt.Errorf("JWT issuer is set but JWKS URL is not")
+		}
+		keys := jwks.NewHTTPKeySet(&http.Client{Timeout: 5 * time.Second}, cfg.JWTJWKSURL, cfg.JWKSRefreshInterval)
+		tokens := routing.NewJWTAuthenticator(keys, cfg.JWTIssuer, cfg.JWTAudience)
+		// outside of API key auth, requests with a valid token need no key
+		router = tokens.Middleware(router)
+		grpcAuthOpts = append(grpcAuthOpts, grpcserver.WithTokens(tokens))
+	}
+	router = routing.RequestTimeoutMiddleware(cfg.RequestTimeout, cfg.MaxRequestTimeout, router)
+	if cfg.HMACClientSecrets != "" {
+		secrets, err := routing.ParseClientSecrets(cfg.HMACClientSecrets)
+		if err != nil {
+			return nil, err
+		}
+		router = routing.NewSignatureVerifier(secrets, signatureReplays, cfg.HMACReplayWindow, int64(cfg.HMACMaxBodyBytes)).Middleware(router)
+	}
+	if cfg.FaultInjectionEnabled {
+		if cfg.Environment == "production" {
+			return nil, fmt.Errorf("fault injection must not be enabled in production")
+		}
+		rules, err := routing.ParseFaultRules(cfg.FaultInjectionRules)
+		if err != nil {
+			return nil, err
+		}
+		faults, err := routing.NewFaultInjector(rules)
+		if err != nil {
+			return nil, err
+		}
+		router = faults.Middleware(router)
+		adminOpts = append(adminOpts, routing.WithFaultInjector(faults))
+	}
+	// outermost, so requests rejected by any middleware are recorded too
+	router = routing.MetricsMiddleware(transportMetrics, routes.Route, router)
+	workers := []backgroundWorker{startup}
+	if pool != nil {
+		workers = append(workers, pool)
+	}
+	if healthWatcher != nil {
+		workers = append(workers, healthWatcher)
+	}
+	if cfg.DatabasePoolWaitThreshold > 0 {
+		workers = append(workers, repository.NewPoolMonitor(databaseClient, cfg.DatabasePoolCheckInterval, cfg.DatabasePoolWaitThreshold, logger.Slog()))
+		for _, client := range shardClients {
+			workers = append(workers, repository.NewPoolMonitor(client, cfg.DatabasePoolCheckInterval, cfg.DatabasePoolWaitThreshold, logger.Slog()))
+		}
+	}
+	// scheduled jobs must run on a single instance when there are replicas
+	scheduled := func(job backgroundWorker) backgroundWorker {
+		return job
+	}
+	if cfg.LeaderElection {
+		elector := service.NewLeaderElector(leaderLease, cfg.LeaderLeaseTTL, logger.Slog())
+		workers = append(workers, elector)
+		scheduled = func(job backgroundWorker) backgroundWorker {
+			return service.LeaderOnly(elector, job)
+		}
+	}
+	if cfg.KafkaBrokers != "" {
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers: strings.Split(cfg.KafkaBrokers, ","),
+			Topic:   cfg.KafkaProductsTopic,
+			GroupID: cfg.KafkaConsumerGroup,
+		})
+		workers = append(workers, consumer.NewKafkaConsumer(reader, svc, logger.Slog()))
+	}
+	if cfg.RabbitMQIngestQueue != "" {
+		workers = append(workers, consumer.NewRabbitMQConsumer(
+			cfg.RabbitMQURL, cfg.RabbitMQIngestExchange, cfg.RabbitMQIngestQueue, svc, logger.Slog()))
+	}
+
+	if cfg.UsageAnalytics {
+		workers = append(workers, service.NewUsageFlusher(usageCounter, repo, logger.Slog(), cfg.UsageFlushInterval))
+	}
+	if cfg.Webhooks {
+		workers = append(workers, service.NewWebhookDispatcher(repo, logger.Slog(), cfg.WebhookPollInterval,
+			cfg.WebhookTimeout, cfg.WebhookMaxAttempts, cfg.WebhookRetryBackoff, cfg.WebhookMaxRetryBackoff))
+		adminOpts = append(adminOpts, routing.WithWebhooks(repo))
+	}
+
+	schedules, err := service.ParseJobSchedules(cfg.JobSchedules)
+	if err != nil {
+		return nil, err
+	}
+	scheduler := service.NewScheduler(logger.Slog())
+	if cfg.SupplierFeedURL != "" {
+		mapping, err := feed.ParseFieldMapping(cfg.SupplierFeedFieldMapping)
+		if err != nil {
+			log.Fatal(err)
+		}
+		supplierFeed := feed.NewHTTPFeed(
+			&http.Client{Timeout: time.Minute},
+			cfg.SupplierFeedURL,
+			cfg.SupplierFeedAuthorization,
+			cfg.SupplierFeedItemsPath,
+			mapping,
+		)
+		supplierSync := service.NewSupplierSync(supplierFeed, svc, logger.Slog(), cfg.SupplierSyncInterval, cfg.SupplierSyncDeleteMissing)
+		spec := cmp.Or(schedules[supplierSyncJob], "@every "+cfg.SupplierSyncInterval.String())
+		err = scheduler.Register(supplierSyncJob, spec, func(ctx context.Context) error {
+			if report := supplierSync.SyncOnce(ctx); len(report.Errors) > 0 {
+				return fmt.Errorf("supplier sync finished with %d errors", len(report.Errors))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		adminOpts = append(adminOpts, routing.WithSyncJob(supplierSync))
+	}
+	for name := range schedules {
+		if !slices.Contains(scheduledJobs, name) {
+			return nil, fmt.Errorf("unknown job %q in JOB_SCHEDULES", name)
+		}
+	}
+	workers = append(workers, scheduled(scheduler))
+	adminOpts = append(adminOpts, routing.WithScheduler(scheduler))
+
+	adminHandler := routing.NewAdminHandler(logger, adminOpts...)
+	adminRouter := routing.NewAdminRouter(adminHandler).SetupRoutes()
+
+	var tlsConfig, adminTLSConfig *tls.Config
+	if cfg.ClientCAFile != "" {
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return nil, fmt.Errorf("client certificates require TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		var subjects []string
+		if cfg.ClientAllowedSubjects != "" {
+			subjects = strings.Split(cfg.ClientAllowedSubjects, ",")
+		}
+		clientCerts, err := routing.NewClientCertTLSConfig(cfg.ClientCAFile, subjects)
+		if err != nil {
+			return nil, err
+		}
+		switch cfg.ClientCertListeners {
+		case "main":
+			tlsConfig = clientCerts
+		case "admin":
+			adminTLSConfig = clientCerts
+		case "all":
+			tlsConfig, adminTLSConfig = clientCerts, clientCerts
+		default:
+			return nil, fmt.Errorf("unknown client certificate listeners %q", cfg.ClientCertListeners)
+		}
+	}
+
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != "" {
+		var grpcOpts []grpc.ServerOption
+		if cfg.TLSCertFile != "" {
+			// client certificates are required as on main listener
+			grpcTLS := &tls.Config{MinVersion: tls.VersionTLS12}
+			if tlsConfig != nil {
+				grpcTLS = tlsConfig.Clone()
+			}
+			cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+			}
+			grpcTLS.Certificates = []tls.Certificate{cert}
+			grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(grpcTLS)))
+		}
+		// calls are authenticated as requests to main listener are
+		grpcOpts = append(grpcOpts, grpc.ChainUnaryInterceptor(grpcserver.NewAuth(logger.Slog(), grpcAuthOpts...).Interceptor))
+		grpcServer = grpcserver.NewServer(svc, logger.Slog(), grpcOpts...)
+	}
+
 	return &App{
-		config:     cfg,
-		db:         repo,
-		cache:      cache,
-		service:    service,
-		handler:    handler,
-		router:     &router,
-		middleware: logger,
+		config:         cfg,
+		db:             catalog,
+		cache:          cache,
+		service:        svc,
+		handler:        handler,
+		router:         &router,
+		adminRouter:    &adminRouter,
+		grpcServer:     grpcServer,
+		tlsConfig:      tlsConfig,
+		adminTLSConfig: adminTLSConfig,
+		middleware:     logger,
+		workers:        workers,
+		publisher:      publisher,
+		drainer:        drainer,
+		tracerProvider: tracerProvider,
+		route:          routes.Route,
 	}, nil
 }
 
+// newShardedRepository opens shards configured next to the main database,
+// which is the first shard, and aligns their id sequences to routing
+func newShardedRepository(cfg *config.Config, primary *repository.PostgresRepository, opts []repository.Option, tracerProvider *sdktrace.TracerProvider) (*repository.ShardedRepository, []*sql.DB, error) {
+	shards := []*repository.PostgresRepository{primary}
+	var clients []*sql.DB
+	for _, url := range strings.Split(cfg.DatabaseShards, ",") {
+		client, err := openPostgres(strings.TrimSpace(url), tracerProvider)
+		if err != nil {
+			return nil, nil, err
+		}
+		client.SetMaxOpenConns(cfg.DatabaseMaxOpenConns)
+		client.SetMaxIdleConns(cfg.DatabaseMaxOpenConns)
+		clients = append(clients, client)
+		shards = append(shards, repository.NewPostgresRepository(client, opts...))
+	}
+	repos := make([]ports.Repository, len(shards))
+	for i, shard := range shards {
+		repos[i] = shard
+	}
+
+	sharded := repository.NewHashShardedRepository(repos)
+	if cfg.DatabaseShardRanges != "" {
+		starts, err := repository.ParseShardRanges(cfg.DatabaseShardRanges)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sharded, err = repository.NewRangeShardedRepository(repos, starts); err != nil {
+			return nil, nil, err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for i, shard := range shards {
+		first, increment := sharded.IdSequence(i)
+		if err := shard.AlignIdSequence(ctx, first, increment); err != nil {
+			return nil, nil, fmt.Errorf("failed to align id sequence of shard %d: %w", i, err)
+		}
+	}
+	return sharded, clients, nil
+}
+
+// openPostgres opens database whose queries are recorded as spans, if
+// tracing is enabled. Statements are recorded with placeholders, values
+// are not
+func openPostgres(dsn string, tracerProvider *sdktrace.TracerProvider) (*sql.DB, error) {
+	if tracerProvider == nil {
+		return sql.Open("postgres", dsn)
+	}
+	return otelsql.Open("postgres", dsn,
+		otelsql.WithTracerProvider(tracerProvider),
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+		}))
+}
+
+// newAuthorizer enforces default policy, roles "none" grant nothing
+func newAuthorizer(cfg *config.Config) (*routing.Authorizer, error) {
+	clientRoles, err := routing.ParseClientRoles(cfg.ClientRoles)
+	if err != nil {
+		return nil, err
+	}
+	var roles [2]domain.Role
+	for i, value := range []string{cfg.AuthenticatedRole, cfg.AnonymousRole} {
+		if value == "none" {
+			continue
+		}
+		if roles[i], err = domain.ParseRole(value); err != nil {
+			return nil, err
+		}
+	}
+	return routing.NewAuthorizer(domain.DefaultPolicy(), clientRoles, roles[0], roles[1]), nil
+}
+
+func newEventPublisher(cfg *config.Config) (ports.EventPublisher, error) {
+	switch cfg.EventsPublisher {
+	case "", "none":
+		return nil, nil
+	case "kafka":
+		if cfg.KafkaBrokers == "" {
+			return nil, fmt.Errorf("kafka events publisher requires KAFKA_BROKERS")
+		}
+		writer := &kafka.Writer{
+			Addr:         kafka.TCP(strings.Split(cfg.KafkaBrokers, ",")...),
+			Topic:        cfg.KafkaEventsTopic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		}
+		return events.NewKafkaPublisher(writer), nil
+	case "rabbitmq":
+		publisher, err := events.NewRabbitMQPublisher(cfg.RabbitMQURL, cfg.RabbitMQEventsExchange)
+		if err != nil {
+			return nil, err
+		}
+		return publisher, nil
+	case "nats":
+		conn, err := nats.Connect(cfg.NatsURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to nats: %w", err)
+		}
+		publisher, err := events.NewNatsPublisher(context.Background(), conn, cfg.NatsStream, cfg.NatsSubjectPrefix)
+		if err != nil {
+			return nil, err
+		}
+		return publisher, nil
+	default:
+		return nil, fmt.Errorf("unknown events publisher %q", cfg.EventsPublisher)
+	}
+}
+
+// Run serves until SIGINT or SIGTERM, then fails readiness for DrainDelay,
+// stops accepting connections, waits for in-flight requests and stops
+// workers. Draining started through admin endpoint shuts main listener
+// down ahead of that, admin listener keeps serving until the process is
+// terminated
 func (a *App) Run() error {
-	return http.ListenAndServe(":"+a.config.Port, a.middleware.LoggerMiddleware(*a.router))
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	workersCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+
+	if a.tracerProvider != nil {
+		defer a.shutdownTracing()
+	}
+	if a.publisher != nil {
+		defer a.publisher.Close()
+	}
+
+	errs := make(chan error, 3+len(a.workers))
+	var workers sync.WaitGroup
+	for _, w := range a.workers {
+		defer w.Close()
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			if err := w.Run(workersCtx); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	servers := []*http.Server{a.newServer(a.config.Port, *a.router, a.tlsConfig, a.route)}
+	// admin endpoints are served on a separate port, so they are never
+	// exposed unless explicitly configured
+	if a.config.AdminPort != "" {
+		servers = append(servers, a.newServer(a.config.AdminPort, *a.adminRouter, a.adminTLSConfig, nil))
+	}
+	for _, server := range servers {
+		go func() {
+			if err := a.listen(server); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	if a.grpcServer != nil {
+		listener, err := net.Listen("tcp", ":"+a.config.GRPCPort)
+		if err != nil {
+			return fmt.Errorf("failed to listen on gRPC port: %w", err)
+		}
+		go func() {
+			if err := a.grpcServer.Serve(listener); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		// fail readiness first, so load balancers stop routing before
+		// listeners close. Another signal stops right away
+		a.drainer.Drain()
+		stop()
+		interrupted, stopInterrupted := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		select {
+		case <-time.After(a.config.DrainDelay):
+		case <-interrupted.Done():
+		}
+		stopInterrupted()
+	case <-a.drainer.Started():
+		// load balancers need a moment to notice failing readiness
+		select {
+		case <-time.After(a.config.DrainDelay):
+			if err := a.shutdown(servers[0]); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+		}
+		select {
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+		}
+	}
+
+	a.drainer.Drain()
+	var shutdownErrs []error
+	for _, server := range servers {
+		shutdownErrs = append(shutdownErrs, a.shutdown(server))
+	}
+	if a.grpcServer != nil {
+		shutdownErrs = append(shutdownErrs, a.shutdownGRPC())
+	}
+	cancelWorkers()
+	workers.Wait()
+	return errors.Join(shutdownErrs...)
+}
+
+// newServer creates listener serving over TLS if server certificate is
+// configured. Request id, principal from client certificate and trace
+// context are set before logging, so that they get logged too. Requests are recorded as
+// spans if tracing is enabled and route tells their routes
+func (a *App) newServer(port string, handler http.Handler, tlsConfig *tls.Config, route func(r *http.Request) string) *http.Server {
+	handler = a.middleware.LoggerMiddleware(handler)
+	if a.tracerProvider != nil && route != nil {
+		handler = routing.SpanMiddleware(a.tracerProvider, route, handler)
+	} else {
+		handler = routing.TraceMiddleware(handler)
+	}
+	return &http.Server{
+		Addr:      ":" + port,
+		Handler:   routing.RequestIdMiddleware(routing.ClientCertMiddleware(handler)),
+		TLSConfig: tlsConfig,
+	}
+}
+
+// listen serves until server is shut down
+func (a *App) listen(server *http.Server) error {
+	var err error
+	if a.config.TLSCertFile == "" {
+		err = server.ListenAndServe()
+	} else {
+		err = server.ListenAndServeTLS(a.config.TLSCertFile, a.config.TLSKeyFile)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// shutdown stops accepting connections and waits for in-flight requests, for
+// ShutdownTimeout at most
+func (a *App) shutdown(server *http.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shut down listener %s: %w", server.Addr, err)
+	}
+	return nil
+}
+
+// shutdownGRPC stops accepting calls and waits for in-flight ones, for
+// ShutdownTimeout at most, then cancels those left
+func (a *App) shutdownGRPC() error {
+	stopped := make(chan struct{})
+	go func() {
+		a.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-time.After(a.config.ShutdownTimeout):
+		a.grpcServer.Stop()
+		return fmt.Errorf("failed to shut down gRPC listener :%s: %w", a.config.GRPCPort, context.DeadlineExceeded)
+	}
+}
+
+// shutdownTracing exports spans still buffered, for ShutdownTimeout at most
+func (a *App) shutdownTracing() {
+	ctx, cancel := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
+	defer cancel()
+	if err := a.tracerProvider.Shutdown(ctx); err != nil {
+		a.middleware.Slog().Error("failed to flush spans", slog.String("error", err.Error()))
+	}
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/pelyams/simpler_go_service/pkg/client"
+)
+
+// change mirrors product event types published by the service
+type change struct {
+	Type      string          `json:"type"`
+	ProductId int64           `json:"productId"`
+	Product   *client.Product `json:"product,omitempty"`
+}
+
+func snapshot(products []client.Product) map[int64]client.Product {
+	result := make(map[int64]client.Product, len(products))
+	for _, p := range products {
+		result[p.Id] = p
+	}
+	return result
+}
+
+// diff returns changes ordered by product id
+func diff(previous, current map[int64]client.Product) []change {
+	var changes []change
+	for id, p := range current {
+		old, ok := previous[id]
+		switch {
+		case !ok:
+			changes = append(changes, change{Type: "product.created", ProductId: id, Product: &p})
+		case !reflect.DeepEqual(old, p):
+			changes = append(changes, change{Type: "product.updated", ProductId: id, Product: &p})
+		}
+	}
+	for id := range previous {
+		if _, ok := current[id]; !ok {
+			changes = append(changes, change{Type: "product.deleted", ProductId: id})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ProductId < changes[j].ProductId })
+	return changes
+}
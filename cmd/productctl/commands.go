@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/pkg/client"
+)
+
+func parseId(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, errors.New("expected a single product id")
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid product id %q", args[0])
+	}
+	return id, nil
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func getCmd(ctx context.Context, c *client.Client, args []string) error {
+	id, err := parseId(args)
+	if err != nil {
+		return err
+	}
+	product, err := c.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printJSON(product)
+}
+
+func listCmd(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	offset := fs.Int64("offset", 0, "number of products to skip")
+	limit := fs.Int64("limit", 20, "page size")
+	all := fs.Bool("all", false, "list the whole catalog")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var products []client.Product
+	var err error
+	if *all {
+		products, err = c.ListAll(ctx)
+	} else {
+		products, err = c.List(ctx, *offset, *limit)
+	}
+	if err != nil {
+		return err
+	}
+	return printJSON(products)
+}
+
+func productFlags(name string, args []string) (client.NewProduct, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	productName := fs.String("name", "", "product name")
+	info := fs.String("info", "", "product additional info")
+	if err := fs.Parse(args); err != nil {
+		return client.NewProduct{}, nil, err
+	}
+	if *productName == "" || *info == "" {
+		return client.NewProduct{}, nil, errors.New("both -name and -info are required")
+	}
+	return client.NewProduct{Name: *productName, AdditionalInfo: *info}, fs.Args(), nil
+}
+
+func createCmd(ctx context.Context, c *client.Client, args []string) error {
+	product, _, err := productFlags("create", args)
+	if err != nil {
+		return err
+	}
+	id, err := c.Create(ctx, product)
+	if err != nil {
+		return err
+	}
+	fmt.Println(id)
+	return nil
+}
+
+func updateCmd(ctx context.Context, c *client.Client, args []string) error {
+	product, rest, err := productFlags("update", args)
+	if err != nil {
+		return err
+	}
+	id, err := parseId(rest)
+	if err != nil {
+		return err
+	}
+	updated, err := c.Update(ctx, id, product)
+	if err != nil {
+		return err
+	}
+	return printJSON(updated)
+}
+
+func deleteCmd(ctx context.Context, c *client.Client, args []string) error {
+	id, err := parseId(args)
+	if err != nil {
+		return err
+	}
+	deleted, err := c.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printJSON(deleted)
+}
+
+func deleteAllCmd(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("delete-all", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "confirm deletion of the whole catalog")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*yes {
+		return errors.New("refusing to delete all products without -yes")
+	}
+	deleted, err := c.DeleteAll(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("deleted %d product(s)\n", deleted)
+	return nil
+}
+
+// importCmd updates products that carry an id and creates the rest. It goes
+// on after failures and reports how many items failed in the end
+func importCmd(ctx context.Context, c *client.Client, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected a single file name")
+	}
+	products, err := readProducts(args[0])
+	if err != nil {
+		return err
+	}
+	var created, updated, failed int
+	for i, p := range products {
+		newProduct := client.NewProduct{Name: p.Name, AdditionalInfo: p.AdditionalInfo}
+		if p.Id > 0 {
+			_, err = c.Update(ctx, p.Id, newProduct)
+		} else {
+			_, err = c.Create(ctx, newProduct)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed++
+			fmt.Fprintf(os.Stderr, "item #%d: %s\n", i, err)
+			continue
+		}
+		if p.Id > 0 {
+			updated++
+		} else {
+			created++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "created %d, updated %d, failed %d\n", created, updated, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d item(s) failed to import", failed)
+	}
+	return nil
+}
+
+func exportCmd(ctx context.Context, c *client.Client, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected a single file name")
+	}
+	products, err := c.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	if err := writeProducts(args[0], products); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d product(s)\n", len(products))
+	return nil
+}
+
+// tailCmd polls the catalog and prints differences between snapshots, so it
+// works against any deployment regardless of configured event publisher
+func tailCmd(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	interval := fs.Duration("interval", 5*time.Second, "polling interval")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	products, err := c.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	previous := snapshot(products)
+	encoder := json.NewEncoder(os.Stdout)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		products, err := c.ListAll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			fmt.Fprintln(os.Stderr, "productctl:", err)
+			continue
+		}
+		current := snapshot(products)
+		for _, change := range diff(previous, current) {
+			if err := encoder.Encode(change); err != nil {
+				return err
+			}
+		}
+		previous = current
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelyams/simpler_go_service/pkg/client"
+)
+
+func isLineDelimited(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".ndjson" || ext == ".jsonl"
+}
+
+func readProducts(name string) ([]client.Product, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return decodeProducts(r, isLineDelimited(name))
+}
+
+func decodeProducts(r io.Reader, lineDelimited bool) ([]client.Product, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if !lineDelimited {
+		var products []client.Product
+		if err := decoder.Decode(&products); err != nil {
+			return nil, fmt.Errorf("failed to decode products: %w", err)
+		}
+		return products, nil
+	}
+	var products []client.Product
+	for {
+		var p client.Product
+		err := decoder.Decode(&p)
+		if errors.Is(err, io.EOF) {
+			return products, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode product #%d: %w", len(products), err)
+		}
+		products = append(products, p)
+	}
+}
+
+func writeProducts(name string, products []client.Product) error {
+	var w io.Writer = os.Stdout
+	if name != "-" {
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	buffered := bufio.NewWriter(w)
+	if err := encodeProducts(buffered, products, isLineDelimited(name)); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+func encodeProducts(w io.Writer, products []client.Product, lineDelimited bool) error {
+	encoder := json.NewEncoder(w)
+	if !lineDelimited {
+		if products == nil {
+			products = []client.Product{}
+		}
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(products)
+	}
+	for _, p := range products {
+		if err := encoder.Encode(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/pkg/client"
+)
+
+// operation is a single kind of request issued by load test
+type operation func(ctx context.Context, c *client.Client, ids *idPool) error
+
+var operations = map[string]operation{
+	"get": func(ctx context.Context, c *client.Client, ids *idPool) error {
+		id, ok := ids.random()
+		if !ok {
+			return errors.New("no product ids to read")
+		}
+		_, err := c.Get(ctx, id)
+		return err
+	},
+	"list": func(ctx context.Context, c *client.Client, ids *idPool) error {
+		_, err := c.List(ctx, 0, 20)
+		return err
+	},
+	"create": func(ctx context.Context, c *client.Client, ids *idPool) error {
+		id, err := c.Create(ctx, client.NewProduct{Name: "Load test product", AdditionalInfo: "Created by productctl loadtest"})
+		if err == nil {
+			ids.add(id)
+		}
+		return err
+	},
+}
+
+// parseMix parses weights like "get=70,list=20,create=10"
+func parseMix(s string) (map[string]int, error) {
+	mix := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if _, known := operations[name]; !ok || !known {
+			return nil, fmt.Errorf("invalid mix entry %q", pair)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight in mix entry %q", pair)
+		}
+		if w > 0 {
+			mix[name] = w
+		}
+	}
+	if len(mix) == 0 {
+		return nil, errors.New("mix has no operations")
+	}
+	return mix, nil
+}
+
+type idPool struct {
+	mu  sync.Mutex
+	ids []int64
+}
+
+func (p *idPool) add(id int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ids = append(p.ids, id)
+}
+
+func (p *idPool) random() (int64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.ids) == 0 {
+		return 0, false
+	}
+	return p.ids[rand.Intn(len(p.ids))], true
+}
+
+// endpointStats collects latencies of a single operation
+type endpointStats struct {
+	latencies []time.Duration
+	errors    int
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted))*p/100+0.5) - 1
+	idx = max(0, min(idx, len(sorted)-1))
+	return sorted[idx]
+}
+
+type loadReport struct {
+	stats   map[string]*endpointStats
+	dropped int
+	elapsed time.Duration
+}
+
+func (r *loadReport) print() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "endpoint\trequests\terrors\terror rate\tp50\tp90\tp99\tmax\t")
+	names := make([]string, 0, len(r.stats))
+	for name := range r.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := r.stats[name]
+		sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f%%\t%s\t%s\t%s\t%s\t\n", name, len(s.latencies), s.errors, s.errorRate()*100,
+			percentile(s.latencies, 50), percentile(s.latencies, 90), percentile(s.latencies, 99), percentile(s.latencies, 100))
+	}
+	w.Flush()
+	fmt.Printf("elapsed %s, dropped %d request(s) because all workers were busy\n", r.elapsed.Round(time.Millisecond), r.dropped)
+}
+
+func (s *endpointStats) errorRate() float64 {
+	if len(s.latencies) == 0 {
+		return 0
+	}
+	return float64(s.errors) / float64(len(s.latencies))
+}
+
+// loadtestCmd issues requests at a fixed rate regardless of how fast the
+// service answers, so slowdowns show up as latency rather than lower load.
+// It exits with an error when thresholds are exceeded, to be used in CI
+func loadtestCmd(ctx context.Context, addr string, timeout time.Duration, args []string) error {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	rps := fs.Int("rps", 50, "requests per second")
+	duration := fs.Duration("duration", 30*time.Second, "test duration")
+	workers := fs.Int("workers", 32, "maximum number of requests in flight")
+	mixFlag := fs.String("mix", "get=70,list=20,create=10", "weights of operations")
+	maxErrorRate := fs.Float64("max-error-rate", 0.01, "fail if any endpoint error rate exceeds it")
+	maxP99 := fs.Duration("max-p99", 0, "fail if any endpoint p99 latency exceeds it, 0 disables check")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *rps < 1 || *workers < 1 {
+		return errors.New("-rps and -workers must be positive")
+	}
+	mix, err := parseMix(*mixFlag)
+	if err != nil {
+		return err
+	}
+
+	// retries would hide failures and distort latencies
+	c := client.New(addr, client.WithTimeout(timeout), client.WithRetries(0, 0))
+	ids := &idPool{}
+	existing, err := c.List(ctx, 0, 100)
+	if err != nil {
+		return fmt.Errorf("failed to prepare product ids: %w", err)
+	}
+	for _, p := range existing {
+		ids.add(p.Id)
+	}
+
+	report := runLoad(ctx, c, ids, mix, *rps, *duration, *workers)
+	report.print()
+
+	var violations []string
+	for name, s := range report.stats {
+		if rate := s.errorRate(); rate > *maxErrorRate {
+			violations = append(violations, fmt.Sprintf("%s error rate %.2f%%", name, rate*100))
+		}
+		if p99 := percentile(s.latencies, 99); *maxP99 > 0 && p99 > *maxP99 {
+			violations = append(violations, fmt.Sprintf("%s p99 %s", name, p99))
+		}
+	}
+	if len(violations) > 0 {
+		sort.Strings(violations)
+		return fmt.Errorf("thresholds exceeded: %s", strings.Join(violations, ", "))
+	}
+	return nil
+}
+
+func runLoad(ctx context.Context, c *client.Client, ids *idPool, mix map[string]int, rps int, duration time.Duration, workers int) *loadReport {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	var weighted []string
+	for name, weight := range mix {
+		for range weight {
+			weighted = append(weighted, name)
+		}
+	}
+
+	report := &loadReport{stats: make(map[string]*endpointStats)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, workers)
+
+	started := time.Now()
+	ticker := time.NewTicker(time.Second / time.Duration(rps))
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			report.dropped++
+			continue
+		}
+		name := weighted[rand.Intn(len(weighted))]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			// requests in flight at the end are allowed to finish
+			begin := time.Now()
+			err := operations[name](context.WithoutCancel(ctx), c, ids)
+			latency := time.Since(begin)
+
+			mu.Lock()
+			defer mu.Unlock()
+			s, ok := report.stats[name]
+			if !ok {
+				s = &endpointStats{}
+				report.stats[name] = s
+			}
+			s.latencies = append(s.latencies, latency)
+			if err != nil {
+				s.errors++
+			}
+		}()
+	}
+	wg.Wait()
+	report.elapsed = time.Since(started)
+	return report
+}
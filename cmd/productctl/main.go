@@ -0,0 +1,89 @@
+// Command productctl is an operator tool for the products API
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/pkg/client"
+)
+
+const usage = `Usage: productctl [-addr URL] [-timeout D] <command> [arguments]
+
+Commands:
+  get <id>                              print a product
+  list [-offset N] [-limit N] [-all]    print products
+  create -name NAME -info INFO          create a product, print its id
+  update -name NAME -info INFO <id>     replace a product
+  delete <id>                           delete a product
+  delete-all -yes                       delete every product
+  import <file>                         create or update products from file
+  export <file>                         write all products to file
+  tail [-interval D]                    print changes as they happen
+  loadtest [-rps N] [-duration D] [-mix get=70,list=20,create=10]
+                                        drive load and report latencies
+
+Files ending in .ndjson or .jsonl hold one product per line, others hold a
+JSON array. Use "-" for stdin/stdout.
+`
+
+func main() {
+	addr := flag.String("addr", envOr("PRODUCTCTL_ADDR", "http://localhost:8080"), "products API base URL")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of a single request")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	if flag.Arg(0) == "loadtest" {
+		err = loadtestCmd(ctx, *addr, *timeout, flag.Args()[1:])
+	} else {
+		err = run(ctx, client.New(*addr, client.WithTimeout(*timeout)), flag.Arg(0), flag.Args()[1:])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "productctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, c *client.Client, command string, args []string) error {
+	switch command {
+	case "get":
+		return getCmd(ctx, c, args)
+	case "list":
+		return listCmd(ctx, c, args)
+	case "create":
+		return createCmd(ctx, c, args)
+	case "update":
+		return updateCmd(ctx, c, args)
+	case "delete":
+		return deleteCmd(ctx, c, args)
+	case "delete-all":
+		return deleteAllCmd(ctx, c, args)
+	case "import":
+		return importCmd(ctx, c, args)
+	case "export":
+		return exportCmd(ctx, c, args)
+	case "tail":
+		return tailCmd(ctx, c, args)
+	default:
+		return fmt.Errorf("unknown command %q, run productctl -h for usage", command)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/pkg/client"
+)
+
+func TestProductsRoundTrip(t *testing.T) {
+	products := []client.Product{
+		{Id: 1, Name: "First", AdditionalInfo: "One"},
+		{Name: "Second", AdditionalInfo: "Two"},
+	}
+	for _, lineDelimited := range []bool{false, true} {
+		var buf bytes.Buffer
+		require.NoError(t, encodeProducts(&buf, products, lineDelimited))
+		decoded, err := decodeProducts(&buf, lineDelimited)
+		require.NoError(t, err)
+		assert.Equal(t, products, decoded)
+	}
+}
+
+func TestDecodeProductsUnknownField(t *testing.T) {
+	_, err := decodeProducts(bytes.NewBufferString(`{"id":1,"title":"x"}`), true)
+	assert.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	previous := snapshot([]client.Product{
+		{Id: 1, Name: "Kept", AdditionalInfo: "Same"},
+		{Id: 2, Name: "Changed", AdditionalInfo: "Old"},
+		{Id: 3, Name: "Removed", AdditionalInfo: "Gone"},
+	})
+	current := snapshot([]client.Product{
+		{Id: 1, Name: "Kept", AdditionalInfo: "Same"},
+		{Id: 2, Name: "Changed", AdditionalInfo: "New"},
+		{Id: 4, Name: "Added", AdditionalInfo: "Fresh"},
+	})
+
+	changes := diff(previous, current)
+	require.Len(t, changes, 3)
+	assert.Equal(t, "product.updated", changes[0].Type)
+	assert.Equal(t, "New", changes[0].Product.AdditionalInfo)
+	assert.Equal(t, change{Type: "product.deleted", ProductId: 3}, changes[1])
+	assert.Equal(t, "product.created", changes[2].Type)
+	assert.Equal(t, int64(4), changes[2].ProductId)
+}
+
+func TestParseMix(t *testing.T) {
+	mix, err := parseMix("get=70, list=30,create=0")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"get": 70, "list": 30}, mix)
+
+	for _, invalid := range []string{"get", "get=x", "update=10", "get=-1", "create=0"} {
+		_, err := parseMix(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 50*time.Millisecond, percentile(latencies, 50))
+	assert.Equal(t, 99*time.Millisecond, percentile(latencies, 99))
+	assert.Equal(t, 100*time.Millisecond, percentile(latencies, 100))
+	assert.Zero(t, percentile(nil, 99))
+}
+
+func TestRunLoad(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, client.WithRetries(0, 0))
+	report := runLoad(context.Background(), c, &idPool{}, map[string]int{"list": 1}, 200, 100*time.Millisecond, 4)
+	require.Contains(t, report.stats, "list")
+	assert.Positive(t, len(report.stats["list"].latencies))
+	assert.Zero(t, report.stats["list"].errors)
+}
@@ -0,0 +1,60 @@
+// Command seed fills the catalog with generated products. Database settings
+// are read from the same environment variables as the service uses
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/pelyams/simpler_go_service/internal/adapters/repository"
+	"github.com/pelyams/simpler_go_service/internal/config"
+	"github.com/pelyams/simpler_go_service/internal/seed"
+)
+
+func main() {
+	count := flag.Int("n", 1000, "number of products to generate")
+	batchSize := flag.Int("batch", 500, "products stored per transaction")
+	randomSeed := flag.Int64("seed", time.Now().UnixNano(), "random seed, same seed produces same products")
+	flag.Parse()
+	if *count < 1 || *batchSize < 1 {
+		log.Fatal("-n and -batch must be positive")
+	}
+
+	cfg := config.Load()
+	db, err := sql.Open("postgres", fmt.Sprintf(
+		"postgres://%s:%s@%s/%s?sslmode=disable",
+		cfg.DatabaseUser,
+		cfg.DatabasePassword,
+		cfg.DatabaseHost,
+		cfg.DatabaseName,
+	))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	var repoOpts []repository.Option
+	if cfg.OutboxEnabled {
+		repoOpts = append(repoOpts, repository.WithOutbox())
+	}
+	repo := repository.NewPostgresRepository(db, repoOpts...)
+	generator := seed.NewGenerator(*randomSeed)
+
+	ctx := context.Background()
+	started := time.Now()
+	for stored := 0; stored < *count; {
+		batch := generator.Products(min(*batchSize, *count-stored))
+		if _, err := repo.StoreProducts(ctx, batch); err != nil {
+			log.Fatalf("stored %d of %d products: %v", stored, *count, err)
+		}
+		stored += len(batch)
+		log.Printf("stored %d of %d products", stored, *count)
+	}
+	log.Printf("done in %s, seed %d", time.Since(started).Round(time.Millisecond), *randomSeed)
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type RedisConfirmationStore struct {
+	client *redis.Client
+}
+
+func NewRedisConfirmationStore(client *redis.Client) *RedisConfirmationStore {
+	return &RedisConfirmationStore{client: client}
+}
+
+func confirmationKey(action string, token string) string {
+	return fmt.Sprintf("confirmation:%s:%s", action, token)
+}
+
+func (r *RedisConfirmationStore) Issue(ctx context.Context, action string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("%w: failed to generate confirmation token: %s", domain.ErrInternalCache, err.Error())
+	}
+	token := hex.EncodeToString(buf)
+	if err := r.client.Set(ctx, confirmationKey(action, token), 1, ttl).Err(); err != nil {
+		return "", fmt.Errorf("%w: failed to store confirmation token: %s", domain.ErrInternalCache, err.Error())
+	}
+	return token, nil
+}
+
+func (r *RedisConfirmationStore) Consume(ctx context.Context, action string, token string) (bool, error) {
+	// GETDEL makes sure token can not be used twice by concurrent requests
+	err := r.client.GetDel(ctx, confirmationKey(action, token)).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: failed to check confirmation token: %s", domain.ErrInternalCache, err.Error())
+	}
+	return true, nil
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type RedisWriteDedupStore struct {
+	client *redis.Client
+}
+
+func NewRedisWriteDedupStore(client *redis.Client) *RedisWriteDedupStore {
+	return &RedisWriteDedupStore{client: client}
+}
+
+func dedupKey(key string) string {
+	return "dedup:" + key
+}
+
+func (r *RedisWriteDedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, []byte, error) {
+	// empty value marks request in progress, SET NX makes sure only one of
+	// concurrent duplicates gets through
+	claimed, err := r.client.SetNX(ctx, dedupKey(key), "", ttl).Result()
+	if err != nil {
+		return false, nil, fmt.Errorf("%w: failed to claim write request: %s", domain.ErrInternalCache, err.Error())
+	}
+	if claimed {
+		return true, nil, nil
+	}
+	response, err := r.client.Get(ctx, dedupKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// expired in between, still a duplicate as far as caller is concerned
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, fmt.Errorf("%w: failed to get deduplicated response: %s", domain.ErrInternalCache, err.Error())
+	}
+	if len(response) == 0 {
+		return false, nil, nil
+	}
+	return false, response, nil
+}
+
+func (r *RedisWriteDedupStore) Save(ctx context.Context, key string, response []byte) error {
+	err := r.client.SetArgs(ctx, dedupKey(key), response, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("%w: failed to save deduplicated response: %s", domain.ErrInternalCache, err.Error())
+	}
+	return nil
+}
+
+func (r *RedisWriteDedupStore) Release(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, dedupKey(key)).Err(); err != nil {
+		return fmt.Errorf("%w: failed to release write request: %s", domain.ErrInternalCache, err.Error())
+	}
+	return nil
+}
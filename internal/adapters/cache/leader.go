@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// lease is only touched by its holder, in case it has expired and has been
+// taken over meanwhile
+var (
+	renewLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+	releaseLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+// RedisLeaderLease is a key holding id of the instance leading
+type RedisLeaderLease struct {
+	client   *redis.Client
+	key      string
+	instance string
+}
+
+func NewRedisLeaderLease(client *redis.Client, name string, instance string) *RedisLeaderLease {
+	return &RedisLeaderLease{client: client, key: "leader:" + name, instance: instance}
+}
+
+func (r *RedisLeaderLease) Acquire(ctx context.Context, ttl time.Duration) (bool, error) {
+	acquired, err := r.client.SetNX(ctx, r.key, r.instance, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("%w: failed to acquire leader lease: %s", domain.ErrInternalCache, err.Error())
+	}
+	return acquired, nil
+}
+
+func (r *RedisLeaderLease) Renew(ctx context.Context, ttl time.Duration) (bool, error) {
+	renewed, err := renewLeaseScript.Run(ctx, r.client, []string{r.key}, r.instance, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("%w: failed to renew leader lease: %s", domain.ErrInternalCache, err.Error())
+	}
+	return renewed == 1, nil
+}
+
+func (r *RedisLeaderLease) Release(ctx context.Context) error {
+	if err := releaseLeaseScript.Run(ctx, r.client, []string{r.key}, r.instance).Err(); err != nil {
+		return fmt.Errorf("%w: failed to release leader lease: %s", domain.ErrInternalCache, err.Error())
+	}
+	return nil
+}
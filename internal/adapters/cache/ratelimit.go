@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// gcraScript implements generic cell rate algorithm. Key holds theoretical
+// arrival time of the next request, in microseconds of Redis clock, so
+// clocks of replicas do not matter. Returns allowed flag, remaining
+// requests, retry after and reset after, the latter two in microseconds
+var gcraScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local emission = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local next_tat = tat + emission
+local diff = now - (next_tat - tolerance)
+if diff < 0 then
+	return {0, 0, -diff, tat - now}
+end
+redis.call('SET', KEYS[1], next_tat, 'PX', math.ceil((next_tat - now) / 1000))
+return {1, math.floor(diff / emission), 0, next_tat - now}
+`)
+
+type RedisRateLimiter struct {
+	client *redis.Client
+}
+
+func NewRedisRateLimiter(client *redis.Client) *RedisRateLimiter {
+	return &RedisRateLimiter{client: client}
+}
+
+func rateLimitKey(key string) string {
+	return "ratelimit:" + key
+}
+
+func (r *RedisRateLimiter) Allow(ctx context.Context, key string, limit domain.RateLimit) (domain.RateDecision, error) {
+	emission := limit.Emission()
+	result, err := gcraScript.Run(ctx, r.client, []string{rateLimitKey(key)},
+		emission.Microseconds(), (emission * time.Duration(limit.Burst)).Microseconds()).Int64Slice()
+	if err != nil {
+		return domain.RateDecision{}, fmt.Errorf("%w: failed to check rate limit: %s", domain.ErrInternalCache, err.Error())
+	}
+	return domain.RateDecision{
+		Allowed:    result[0] == 1,
+		Remaining:  int(result[1]),
+		RetryAfter: time.Duration(result[2]) * time.Microsecond,
+		ResetAfter: time.Duration(result[3]) * time.Microsecond,
+	}, nil
+}
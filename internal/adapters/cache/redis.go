@@ -9,14 +9,29 @@ import (
 	"github.com/redis/go-redis/v9"
 
 	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
 )
 
 type RedisCache struct {
-	client *redis.Client
+	client  *redis.Client
+	metrics ports.CacheMetrics
 }
 
-func NewRedisCache(client *redis.Client) *RedisCache {
-	return &RedisCache{client: client}
+type Option func(*RedisCache)
+
+// WithMetrics records hits and misses of product lookups
+func WithMetrics(metrics ports.CacheMetrics) Option {
+	return func(r *RedisCache) {
+		r.metrics = metrics
+	}
+}
+
+func NewRedisCache(client *redis.Client, opts ...Option) *RedisCache {
+	r := &RedisCache{client: client}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 func createKey(id int64) string {
@@ -36,18 +51,71 @@ func (r *RedisCache) SetProduct(ctx context.Context, product *domain.Product) er
 	return nil
 }
 
+// SetProducts stores products with a single MSET, so like SetProduct it
+// leaves entries without expiration
+func (r *RedisCache) SetProducts(ctx context.Context, products []domain.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	pairs := make([]interface{}, 0, 2*len(products))
+	for _, product := range products {
+		data, err := json.Marshal(product)
+		if err != nil {
+			return fmt.Errorf("%w: error marshalling product: %s", domain.ErrInternalCache, err.Error())
+		}
+		pairs = append(pairs, createKey(product.Id), data)
+	}
+	if err := r.client.MSet(ctx, pairs...).Err(); err != nil {
+		return fmt.Errorf("%w: failed to store products to cache: %s", domain.ErrInternalCache, err.Error())
+	}
+	return nil
+}
+
 func (r *RedisCache) GetJSONProductById(ctx context.Context, id int64) ([]byte, error) {
 	key := createKey(id)
 	data, err := r.client.Get(ctx, key).Bytes()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
+			r.lookup(false, nil)
 			return nil, fmt.Errorf("%w: failed to find product %d in cache", domain.ErrNotFound, id)
 		}
+		r.lookup(false, err)
 		return nil, fmt.Errorf("%w: failed to get product %d from cache: %s", domain.ErrInternalCache, id, err.Error())
 	}
+	r.lookup(true, nil)
 	return data, nil
 }
 
+// skuKey is kept apart from product keys, it holds product id only
+func skuKey(sku string) string {
+	return "product:sku:" + sku
+}
+
+func (r *RedisCache) SetProductSku(ctx context.Context, sku string, id int64) error {
+	err := r.client.Set(ctx, skuKey(sku), id, 0).Err()
+	if err != nil {
+		return fmt.Errorf("%w: failed to store sku %q to cache: %s", domain.ErrInternalCache, sku, err.Error())
+	}
+	return nil
+}
+
+func (r *RedisCache) GetProductIdBySku(ctx context.Context, sku string) (int64, error) {
+	id, err := r.client.Get(ctx, skuKey(sku)).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, fmt.Errorf("%w: failed to find sku %q in cache", domain.ErrNotFound, sku)
+		}
+		return 0, fmt.Errorf("%w: failed to get sku %q from cache: %s", domain.ErrInternalCache, sku, err.Error())
+	}
+	return id, nil
+}
+
+func (r *RedisCache) lookup(hit bool, err error) {
+	if r.metrics != nil {
+		r.metrics.CacheLookup(hit, err)
+	}
+}
+
 func (r *RedisCache) DeleteProductById(ctx context.Context, id int64) error {
 	key := createKey(id)
 	result, err := r.client.Del(ctx, key).Result()
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/pelyams/simpler_go_service/testhelpers"
+)
+
+// TestMain terminates the container shared by benchmarks, if they started one
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if err := testhelpers.TerminateSharedContainers(context.Background()); err != nil {
+		log.Println(err)
+	}
+	os.Exit(code)
+}
+
+func BenchmarkCacheGet(b *testing.B) {
+	ctx := context.Background()
+	redisContainer, err := testhelpers.SharedRedisContainer(ctx)
+	if err != nil {
+		b.Fatal("failed to create RedisContainer: ", err)
+	}
+	if err := redisContainer.Reset(ctx); err != nil {
+		b.Fatal("failed to reset redis container: ", err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: redisContainer.ConnectionString})
+	b.Cleanup(func() { client.Close() })
+	cache := NewRedisCache(client)
+
+	products := testhelpers.GenerateProducts(1000)
+	for i := range products {
+		products[i].Id = int64(i + 1)
+	}
+	if err := testhelpers.SeedCache(ctx, client, products...); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("hit", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := cache.GetJSONProductById(ctx, int64(i%len(products)+1)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("miss", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := cache.GetJSONProductById(ctx, int64(len(products)+i+1)); err == nil {
+				b.Fatal("expected cache miss")
+			}
+		}
+	})
+}
+
+func BenchmarkCacheSetBatch(b *testing.B) {
+	ctx := context.Background()
+	redisContainer, err := testhelpers.SharedRedisContainer(ctx)
+	if err != nil {
+		b.Fatal("failed to create RedisContainer: ", err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: redisContainer.ConnectionString})
+	b.Cleanup(func() { client.Close() })
+	cache := NewRedisCache(client)
+
+	products := testhelpers.GenerateProducts(500)
+	for i := range products {
+		products[i].Id = int64(i + 1)
+	}
+
+	b.Run("one by one", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := range products {
+				if err := cache.SetProduct(ctx, &products[j]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("mset", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := cache.SetProducts(ctx, products); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
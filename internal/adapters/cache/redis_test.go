@@ -6,11 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
 	"github.com/pelyams/simpler_go_service/testhelpers"
+	"github.com/pelyams/simpler_go_service/testhelpers/contract"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -23,9 +27,6 @@ type ProductCacheTestSuite struct {
 
 func (suite *ProductCacheTestSuite) SetupSuite() {
 	suite.ctx = context.Background()
-}
-
-func (suite *ProductCacheTestSuite) SetupTest() {
 	t := suite.T()
 	redisContainer, err := testhelpers.CreateRedisContainer(suite.ctx)
 	if err != nil {
@@ -37,20 +38,23 @@ func (suite *ProductCacheTestSuite) SetupTest() {
 		Addr: redisContainer.ConnectionString,
 		DB:   0,
 	})
-	redisClient.ConfigSet(context.Background(), "maxmemory", "10mb")
-	redisClient.ConfigSet(context.Background(), "maxmemory-policy", "allkeys-lru")
+	suite.cache = NewRedisCache(redisClient)
+}
 
-	cache := NewRedisCache(redisClient)
-	if err != nil {
-		t.Fatal("failed to create RedisCache: ", err)
+func (suite *ProductCacheTestSuite) TearDownSuite() {
+	suite.cache.client.Close()
+	if err := suite.cacheContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatal("error terminating redis container: ", err)
 	}
-	suite.cache = cache
 }
 
-func (suite *ProductCacheTestSuite) TearDownTest() {
-	if err := suite.cacheContainer.Terminate(suite.ctx); err != nil {
-		suite.T().Fatal("error terminating postgres container: ", err)
+func (suite *ProductCacheTestSuite) SetupTest() {
+	if err := suite.cacheContainer.Reset(suite.ctx); err != nil {
+		suite.T().Fatal("failed to reset redis container: ", err)
 	}
+	// memory settings do not survive restarts after simulated outages
+	suite.cache.client.ConfigSet(suite.ctx, "maxmemory", "10mb")
+	suite.cache.client.ConfigSet(suite.ctx, "maxmemory-policy", "allkeys-lru")
 }
 
 func TestCustomerRepoTestSuite(t *testing.T) {
@@ -92,6 +96,38 @@ func (suite *ProductCacheTestSuite) TestSetProduct() {
 
 }
 
+// lookupRecorder counts lookups by result
+type lookupRecorder map[string]int
+
+func (l lookupRecorder) CacheLookup(hit bool, err error) {
+	switch {
+	case err != nil:
+		l["error"]++
+	case hit:
+		l["hit"]++
+	default:
+		l["miss"]++
+	}
+}
+
+func (suite *ProductCacheTestSuite) TestLookupMetrics() {
+	t := suite.T()
+	lookups := lookupRecorder{}
+	cache := NewRedisCache(suite.cache.client, WithMetrics(lookups))
+	require.NoError(t, cache.SetProduct(suite.ctx, &domain.Product{Id: 1, Name: "lamp", AdditionalInfo: "brass"}))
+
+	_, err := cache.GetJSONProductById(suite.ctx, 1)
+	assert.NoError(t, err)
+	_, err = cache.GetJSONProductById(suite.ctx, 2)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.Equal(t, lookupRecorder{"hit": 1, "miss": 1}, lookups)
+
+	require.NoError(t, suite.cacheContainer.Stop(suite.ctx, nil))
+	_, err = cache.GetJSONProductById(suite.ctx, 1)
+	assert.ErrorIs(t, err, domain.ErrInternalCache)
+	assert.Equal(t, 1, lookups["error"])
+}
+
 func (suite *ProductCacheTestSuite) TestGetJSONProductById() {
 	testCases := []struct {
 		name        string
@@ -260,3 +296,167 @@ func (suite *ProductCacheTestSuite) TestClearCache() {
 	assert.Error(t, err)
 	assert.True(t, errors.Is(err, domain.ErrInternalCache))
 }
+
+func (suite *ProductCacheTestSuite) TestConfirmationStore() {
+	t := suite.T()
+	store := NewRedisConfirmationStore(suite.cache.client)
+
+	token, err := store.Issue(suite.ctx, "delete_all_products", time.Minute)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	ok, err := store.Consume(suite.ctx, "other_action", token)
+	require.NoError(t, err)
+	assert.False(t, ok, "token must be bound to its action")
+
+	ok, err = store.Consume(suite.ctx, "delete_all_products", token)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = store.Consume(suite.ctx, "delete_all_products", token)
+	require.NoError(t, err)
+	assert.False(t, ok, "token must be single-use")
+
+	expiring, err := store.Issue(suite.ctx, "delete_all_products", 50*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	ok, err = store.Consume(suite.ctx, "delete_all_products", expiring)
+	require.NoError(t, err)
+	assert.False(t, ok, "token must expire")
+}
+
+func (suite *ProductCacheTestSuite) TestContract() {
+	contract.RunCacheTests(suite.T(), func(t *testing.T) ports.Cache {
+		if err := suite.cacheContainer.Reset(suite.ctx); err != nil {
+			t.Fatal("failed to reset redis container: ", err)
+		}
+		return suite.cache
+	})
+}
+
+func (suite *ProductCacheTestSuite) TestWriteDedupStore() {
+	t := suite.T()
+	store := NewRedisWriteDedupStore(suite.cache.client)
+
+	claimed, _, err := store.Claim(suite.ctx, "request", time.Minute)
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	claimed, response, err := store.Claim(suite.ctx, "request", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.Nil(t, response, "response is not known while request is in progress")
+
+	require.NoError(t, store.Save(suite.ctx, "request", []byte(`{"status":201}`)))
+	claimed, response, err = store.Claim(suite.ctx, "request", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.Equal(t, `{"status":201}`, string(response))
+
+	require.NoError(t, store.Release(suite.ctx, "request"))
+	claimed, _, err = store.Claim(suite.ctx, "request", 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, claimed, "released request may be retried")
+
+	time.Sleep(100 * time.Millisecond)
+	claimed, _, err = store.Claim(suite.ctx, "request", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, claimed, "claim must expire")
+}
+
+func (suite *ProductCacheTestSuite) TestSignatureReplayStore() {
+	t := suite.T()
+	store := NewRedisSignatureReplayStore(suite.cache.client)
+
+	claimed, err := store.Claim(suite.ctx, "partner:abc", 50*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	claimed, err = store.Claim(suite.ctx, "partner:abc", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed, "signature may be used once")
+
+	time.Sleep(100 * time.Millisecond)
+	claimed, err = store.Claim(suite.ctx, "partner:abc", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, claimed, "claim must expire")
+}
+
+func (suite *ProductCacheTestSuite) TestUsageCounter() {
+	t := suite.T()
+	counter := NewRedisUsageCounter(suite.cache.client)
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, counter.Add(suite.ctx, domain.DailyUsage{Day: day, Usage: domain.Usage{Client: "billing:eu", Requests: 1, BytesIn: 10, BytesOut: 100}}))
+	require.NoError(t, counter.Add(suite.ctx, domain.DailyUsage{Day: day, Usage: domain.Usage{Client: "billing:eu", Requests: 1, Errors: 1, BytesOut: 20}}))
+
+	drained, err := counter.Drain(suite.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.DailyUsage{
+		{Day: day, Usage: domain.Usage{Client: "billing:eu", Requests: 2, Errors: 1, BytesIn: 10, BytesOut: 120}},
+	}, drained)
+
+	drained, err = counter.Drain(suite.ctx)
+	require.NoError(t, err)
+	assert.Empty(t, drained, "drain resets counters")
+}
+
+func (suite *ProductCacheTestSuite) TestRateLimiter() {
+	t := suite.T()
+	limiter := NewRedisRateLimiter(suite.cache.client)
+	limit := domain.RateLimit{Requests: 1, Period: time.Hour, Burst: 2}
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		decision, err := limiter.Allow(suite.ctx, "client:shop", limit)
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, remaining, decision.Remaining)
+	}
+	decision, err := limiter.Allow(suite.ctx, "client:shop", limit)
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed, "burst is used up")
+	assert.InDelta(t, time.Hour, decision.RetryAfter, float64(time.Minute))
+	assert.InDelta(t, 2*time.Hour, decision.ResetAfter, float64(time.Minute))
+
+	decision, err = limiter.Allow(suite.ctx, "client:other", limit)
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed, "clients are limited separately")
+
+	ttl, err := suite.cache.client.PTTL(suite.ctx, rateLimitKey("client:shop")).Result()
+	require.NoError(t, err)
+	assert.InDelta(t, 2*time.Hour, ttl, float64(time.Minute), "key expires once burst is restored")
+}
+
+func (suite *ProductCacheTestSuite) TestLeaderLease() {
+	t := suite.T()
+	first := NewRedisLeaderLease(suite.cache.client, "jobs", "first")
+	second := NewRedisLeaderLease(suite.cache.client, "jobs", "second")
+
+	acquired, err := first.Acquire(suite.ctx, time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	acquired, err = second.Acquire(suite.ctx, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, acquired, "lease is held by first")
+
+	renewed, err := second.Renew(suite.ctx, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, renewed, "only holder renews")
+	require.NoError(t, second.Release(suite.ctx))
+	renewed, err = first.Renew(suite.ctx, 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, renewed, "release by another instance is no-op")
+
+	time.Sleep(100 * time.Millisecond)
+	acquired, err = second.Acquire(suite.ctx, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired, "expired lease is taken over")
+	renewed, err = first.Renew(suite.ctx, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, renewed)
+
+	require.NoError(t, second.Release(suite.ctx))
+	acquired, err = first.Acquire(suite.ctx, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type RedisSignatureReplayStore struct {
+	client *redis.Client
+}
+
+func NewRedisSignatureReplayStore(client *redis.Client) *RedisSignatureReplayStore {
+	return &RedisSignatureReplayStore{client: client}
+}
+
+func (r *RedisSignatureReplayStore) Claim(ctx context.Context, signature string, ttl time.Duration) (bool, error) {
+	claimed, err := r.client.SetNX(ctx, "signature:"+signature, "", ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("%w: failed to claim request signature: %s", domain.ErrInternalCache, err.Error())
+	}
+	return claimed, nil
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+const usageKeyPrefix = "usage:"
+
+// usageTTL keeps counters from piling up if they are never flushed
+const usageTTL = 7 * 24 * time.Hour
+
+// drainUsageScript reads and deletes counters at once, so that requests
+// counted meanwhile are not lost
+var drainUsageScript = redis.NewScript(`
+local usage = redis.call('HGETALL', KEYS[1])
+redis.call('DEL', KEYS[1])
+return usage
+`)
+
+// RedisUsageCounter keeps a hash of counters per client and day, shared by
+// all instances
+type RedisUsageCounter struct {
+	client *redis.Client
+}
+
+func NewRedisUsageCounter(client *redis.Client) *RedisUsageCounter {
+	return &RedisUsageCounter{client: client}
+}
+
+func usageKey(day time.Time, client string) string {
+	return usageKeyPrefix + day.UTC().Format(time.DateOnly) + ":" + client
+}
+
+func (r *RedisUsageCounter) Add(ctx context.Context, usage domain.DailyUsage) error {
+	key := usageKey(usage.Day, usage.Client)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, "requests", usage.Requests)
+		pipe.HIncrBy(ctx, key, "errors", usage.Errors)
+		pipe.HIncrBy(ctx, key, "bytes_in", usage.BytesIn)
+		pipe.HIncrBy(ctx, key, "bytes_out", usage.BytesOut)
+		pipe.Expire(ctx, key, usageTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: failed to count usage: %s", domain.ErrInternalCache, err.Error())
+	}
+	return nil
+}
+
+func (r *RedisUsageCounter) Drain(ctx context.Context) ([]domain.DailyUsage, error) {
+	var drained []domain.DailyUsage
+	iter := r.client.Scan(ctx, 0, usageKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		dayAndClient := strings.TrimPrefix(key, usageKeyPrefix)
+		dayPart, client, ok := strings.Cut(dayAndClient, ":")
+		day, err := time.Parse(time.DateOnly, dayPart)
+		if !ok || err != nil {
+			continue
+		}
+		fields, err := drainUsageScript.Run(ctx, r.client, []string{key}).StringSlice()
+		if err != nil {
+			return drained, fmt.Errorf("%w: failed to drain usage: %s", domain.ErrInternalCache, err.Error())
+		}
+		usage := domain.DailyUsage{Day: day, Usage: domain.Usage{Client: client}}
+		for i := 0; i+1 < len(fields); i += 2 {
+			value, _ := strconv.ParseInt(fields[i+1], 10, 64)
+			switch fields[i] {
+			case "requests":
+				usage.Requests = value
+			case "errors":
+				usage.Errors = value
+			case "bytes_in":
+				usage.BytesIn = value
+			case "bytes_out":
+				usage.BytesOut = value
+			}
+		}
+		if usage.Requests > 0 {
+			drained = append(drained, usage)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return drained, fmt.Errorf("%w: failed to scan usage: %s", domain.ErrInternalCache, err.Error())
+	}
+	return drained, nil
+}
@@ -0,0 +1,40 @@
+package codec
+
+import (
+	"io"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// Jsoniter is json-iterator configured to behave like encoding/json, map
+// keys sorted and HTML escaped included, so responses do not change. The
+// difference left is that json.RawMessage is written as it is rather than
+// compacted, product responses have none
+type Jsoniter struct {
+	api jsoniter.API
+}
+
+func NewJsoniter() *Jsoniter {
+	return &Jsoniter{api: jsoniter.Config{
+		EscapeHTML:             true,
+		SortMapKeys:            true,
+		ValidateJsonRawMessage: true,
+		DisallowUnknownFields:  true,
+	}.Froze()}
+}
+
+func (j *Jsoniter) NewEncoder(w io.Writer) ports.JSONEncoder {
+	return j.api.NewEncoder(w)
+}
+
+// Decode reads the whole body, decoder of json-iterator can not tell
+// trailing data from the end of input
+func (j *Jsoniter) Decode(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return j.api.Unmarshal(data, v)
+}
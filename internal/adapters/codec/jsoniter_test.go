@@ -0,0 +1,42 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func TestJsoniterEncoder(t *testing.T) {
+	values := []any{
+		domain.Product{Id: 1, Name: "<b>Bold</b> & co", AdditionalInfo: "Ünïcode line"},
+		[]domain.Product{{Id: 1, Name: "First"}, {Id: 2, Name: "Second"}},
+		map[string]any{"b": 1, "a": []int{}, "c": nil},
+	}
+	for _, v := range values {
+		var expected, actual bytes.Buffer
+		require.NoError(t, json.NewEncoder(&expected).Encode(v))
+		require.NoError(t, NewJsoniter().NewEncoder(&actual).Encode(v))
+		assert.Equal(t, expected.String(), actual.String())
+	}
+}
+
+func TestJsoniterDecode(t *testing.T) {
+	var product domain.NewProduct
+	require.NoError(t, NewJsoniter().Decode(strings.NewReader(`{"name":"New","additionalInfo":"Created"}`+" \n"), &product))
+	assert.Equal(t, domain.NewProduct{Name: "New", AdditionalInfo: "Created"}, product)
+
+	for _, body := range []string{
+		`{"name":"New","price":10}`,
+		`{"name":"New"} {"name":"Other"}`,
+		`{"name":"New"}}`,
+		``,
+	} {
+		assert.Error(t, NewJsoniter().Decode(strings.NewReader(body), &product), body)
+	}
+}
@@ -0,0 +1,87 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+type KafkaConsumer struct {
+	productApplier
+	reader     *kafka.Reader
+	retryDelay time.Duration
+}
+
+func NewKafkaConsumer(reader *kafka.Reader, svc ports.ResourseService, logger *slog.Logger) *KafkaConsumer {
+	return &KafkaConsumer{
+		productApplier: productApplier{
+			svc:    svc,
+			logger: logger.With(slog.String("component", "kafka_consumer")),
+		},
+		reader:     reader,
+		retryDelay: time.Second,
+	}
+}
+
+// Run consumes messages until ctx is cancelled. Offsets are committed only after
+// message is applied (or rejected as one that never can be), so delivery is at-least-once;
+// both upserts and deletes are idempotent, so redelivery is harmless
+func (c *KafkaConsumer) Run(ctx context.Context) error {
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("consumer error: failed to fetch message: %w", err)
+		}
+
+		msgCtx := withMessageTrace(ctx, kafkaHeader(msg, "traceparent"))
+		for {
+			err = c.handleMessage(msgCtx, msg.Value)
+			if err == nil || permanent(err) {
+				break
+			}
+			c.logger.WarnContext(msgCtx, "failed to apply message, retrying",
+				slog.Int("partition", msg.Partition),
+				slog.Int64("offset", msg.Offset),
+				slog.String("error", err.Error()))
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(c.retryDelay):
+			}
+		}
+		if err != nil {
+			c.logger.ErrorContext(msgCtx, "skipping message that can not be applied",
+				slog.Int("partition", msg.Partition),
+				slog.Int64("offset", msg.Offset),
+				slog.String("error", err.Error()))
+		}
+
+		if err := c.reader.CommitMessages(ctx, msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("consumer error: failed to commit offset %d: %w", msg.Offset, err)
+		}
+	}
+}
+
+func kafkaHeader(msg kafka.Message, key string) string {
+	for _, header := range msg.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c *KafkaConsumer) Close() error {
+	return c.reader.Close()
+}
@@ -0,0 +1,242 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) GetProductById(ctx context.Context, id int64) ([]byte, *domain.ServiceError) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]byte), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) GetProductBySku(ctx context.Context, sku string) ([]byte, *domain.ServiceError) {
+	args := m.Called(ctx, sku)
+	return args.Get(0).([]byte), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) AdjustProductStock(ctx context.Context, id int64, delta int64) (*domain.Product, *domain.ServiceError) {
+	args := m.Called(ctx, id, delta)
+	return args.Get(0).(*domain.Product), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) AddProductImage(ctx context.Context, id int64, upload domain.ImageUpload) (*domain.Product, *domain.ServiceError) {
+	args := m.Called(ctx, id, upload)
+	return args.Get(0).(*domain.Product), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) GetAllProducts(ctx context.Context) ([]domain.Product, *domain.ServiceError) {
+	args := m.Called(ctx)
+	return args.Get(0).([]domain.Product), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) EachProduct(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, fn func(product domain.Product) error) *domain.ServiceError {
+	args := m.Called(ctx, filter, sort, fn)
+	return args.Get(0).(*domain.ServiceError)
+}
+
+func (m *MockService) GetProductsPaged(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, limit int64, offset int64) ([]domain.Product, *domain.ServiceError) {
+	args := m.Called(ctx, filter, sort, limit, offset)
+	return args.Get(0).([]domain.Product), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) GetProductsAfter(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, after *domain.Product, limit int64) ([]domain.Product, *domain.ServiceError) {
+	args := m.Called(ctx, filter, sort, after, limit)
+	return args.Get(0).([]domain.Product), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) CountProducts(ctx context.Context, filter domain.ProductFilter) (int64, *domain.ServiceError) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(int64), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) ExportProducts(ctx context.Context, fn func(page []domain.Product) error) *domain.ServiceError {
+	args := m.Called(ctx, fn)
+	return args.Get(0).(*domain.ServiceError)
+}
+
+func (m *MockService) SearchProducts(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, *domain.ServiceError) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(*domain.SearchResult), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) SuggestProductNames(ctx context.Context, prefix string, limit int64) ([]string, *domain.ServiceError) {
+	args := m.Called(ctx, prefix, limit)
+	return args.Get(0).([]string), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) CreateProduct(ctx context.Context, product domain.NewProduct) (int64, *domain.ServiceError) {
+	args := m.Called(ctx, product)
+	return args.Get(0).(int64), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) CreateProducts(ctx context.Context, products []domain.NewProduct) ([]int64, *domain.ServiceError) {
+	args := m.Called(ctx, products)
+	return args.Get(0).([]int64), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) UpsertProduct(ctx context.Context, product domain.Product) (bool, *domain.ServiceError) {
+	args := m.Called(ctx, product)
+	return args.Bool(0), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) UpdateProductById(ctx context.Context, id int64, product domain.NewProduct) (*domain.Product, *domain.ServiceError) {
+	args := m.Called(ctx, id, product)
+	return args.Get(0).(*domain.Product), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) PatchProduct(ctx context.Context, id int64, patch domain.Patcher) (*domain.Product, *domain.ServiceError) {
+	args := m.Called(ctx, id, patch)
+	return args.Get(0).(*domain.Product), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) DeleteProductById(ctx context.Context, id int64) (*domain.Product, *domain.ServiceError) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*domain.Product), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) AddProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, *domain.ServiceError) {
+	args := m.Called(ctx, id, tags)
+	return args.Get(0).(*domain.Product), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) RemoveProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, *domain.ServiceError) {
+	args := m.Called(ctx, id, tags)
+	return args.Get(0).(*domain.Product), args.Get(1).(*domain.ServiceError)
+}
+
+func (m *MockService) DeleteAllProducts(ctx context.Context) (int64, *domain.ServiceError) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Get(1).(*domain.ServiceError)
+}
+
+func TestHandleMessage(t *testing.T) {
+	ctx := context.Background()
+	product := domain.Product{Id: 5, Name: "Ingested product", AdditionalInfo: "Ingested description"}
+
+	testCases := []struct {
+		name          string
+		message       string
+		expectedError error
+		// message must be skipped, not retried
+		poison     bool
+		setupMocks func(svc *MockService)
+	}{
+		{
+			name:    "upsert - success",
+			message: `{"op":"upsert","product":{"id":5,"name":"Ingested product","additionalInfo":"Ingested description"}}`,
+			setupMocks: func(svc *MockService) {
+				svc.On("UpsertProduct", ctx, product).Return(true, (*domain.ServiceError)(nil)).Once()
+			},
+		},
+		{
+			name:          "upsert - db error is retriable",
+			message:       `{"op":"upsert","product":{"id":5,"name":"Ingested product","additionalInfo":"Ingested description"}}`,
+			expectedError: domain.ErrInternalDb,
+			setupMocks: func(svc *MockService) {
+				svc.On("UpsertProduct", ctx, product).Return(false, domain.NewServiceError(domain.ErrInternalDb, nil)).Once()
+			},
+		},
+		{
+			name:          "upsert - missing product",
+			message:       `{"op":"upsert","id":5}`,
+			expectedError: domain.ErrInvalidInput,
+			poison:        true,
+			setupMocks:    func(svc *MockService) {},
+		},
+		{
+			name:          "upsert - invalid sku",
+			message:       `{"op":"upsert","product":{"id":5,"name":"Ingested product","additionalInfo":"Ingested description","sku":"has space"}}`,
+			expectedError: domain.ErrInvalidInput,
+			poison:        true,
+			setupMocks:    func(svc *MockService) {},
+		},
+		{
+			name:          "upsert - negative category",
+			message:       `{"op":"upsert","product":{"id":5,"name":"Ingested product","additionalInfo":"Ingested description","categoryId":-1}}`,
+			expectedError: domain.ErrInvalidInput,
+			poison:        true,
+			setupMocks:    func(svc *MockService) {},
+		},
+		{
+			name:          "upsert - unknown category",
+			message:       `{"op":"upsert","product":{"id":5,"name":"Ingested product","additionalInfo":"Ingested description","categoryId":42}}`,
+			expectedError: domain.ErrUnknownCategory,
+			poison:        true,
+			setupMocks: func(svc *MockService) {
+				withCategory := product
+				withCategory.CategoryId = 42
+				svc.On("UpsertProduct", ctx, withCategory).Return(false, domain.NewServiceError(domain.ErrUnknownCategory, nil)).Once()
+			},
+		},
+		{
+			name:          "upsert - duplicate sku",
+			message:       `{"op":"upsert","product":{"id":5,"name":"Ingested product","additionalInfo":"Ingested description","sku":"LMP-1"}}`,
+			expectedError: domain.ErrDuplicateSku,
+			poison:        true,
+			setupMocks: func(svc *MockService) {
+				withSku := product
+				withSku.Sku = "LMP-1"
+				svc.On("UpsertProduct", ctx, withSku).Return(false, domain.NewServiceError(domain.ErrDuplicateSku, nil)).Once()
+			},
+		},
+		{
+			name:    "delete - success",
+			message: `{"op":"delete","id":5}`,
+			setupMocks: func(svc *MockService) {
+				svc.On("DeleteProductById", ctx, int64(5)).Return(&product, (*domain.ServiceError)(nil)).Once()
+			},
+		},
+		{
+			name:    "delete - already deleted",
+			message: `{"op":"delete","id":5}`,
+			setupMocks: func(svc *MockService) {
+				svc.On("DeleteProductById", ctx, int64(5)).Return((*domain.Product)(nil), domain.NewServiceError(domain.ErrNotFound, nil)).Once()
+			},
+		},
+		{
+			name:          "unknown operation",
+			message:       `{"op":"truncate"}`,
+			expectedError: domain.ErrInvalidInput,
+			poison:        true,
+			setupMocks:    func(svc *MockService) {},
+		},
+		{
+			name:          "malformed json",
+			message:       `{"op":`,
+			expectedError: domain.ErrInvalidInput,
+			poison:        true,
+			setupMocks:    func(svc *MockService) {},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := new(MockService)
+			tc.setupMocks(svc)
+			consumer := NewKafkaConsumer(nil, svc, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			err := consumer.handleMessage(ctx, []byte(tc.message))
+			if tc.expectedError != nil {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, tc.expectedError))
+				assert.Equal(t, tc.poison, permanent(err))
+			} else {
+				assert.NoError(t, err)
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+const (
+	OpUpsert = "upsert"
+	OpDelete = "delete"
+)
+
+// ProductMessage is a payload of a single ingested message, the same for every broker.
+// Upserts carry the whole product, deletes carry only id
+type ProductMessage struct {
+	Op      string          `json:"op"`
+	Id      int64           `json:"id,omitempty"`
+	Product *domain.Product `json:"product,omitempty"`
+}
+
+// productApplier applies decoded messages through service layer. Messages
+// that can never be applied are reported with errors permanent tells apart,
+// they must not be retried
+type productApplier struct {
+	svc    ports.ResourseService
+	logger *slog.Logger
+}
+
+// withMessageTrace continues trace of producer if message carries
+// traceparent, so writes it causes belong to the same trace
+func withMessageTrace(ctx context.Context, traceparent string) context.Context {
+	tc, err := domain.ParseTraceparent(traceparent)
+	if err != nil {
+		return domain.WithTraceContext(ctx, domain.NewTraceContext())
+	}
+	return domain.WithTraceContext(ctx, tc.Child())
+}
+
+// permanent tells whether applying message failed for good, because it is
+// malformed or conflicts with catalog. Retrying such a poison message would
+// block partition or queue behind it forever
+func permanent(err error) bool {
+	return errors.Is(err, domain.ErrInvalidInput) ||
+		errors.Is(err, domain.ErrUnknownCategory) ||
+		errors.Is(err, domain.ErrDuplicateSku)
+}
+
+func (c *productApplier) handleMessage(ctx context.Context, value []byte) error {
+	var msg ProductMessage
+	if err := json.Unmarshal(value, &msg); err != nil {
+		return fmt.Errorf("%w: failed to decode message: %s", domain.ErrInvalidInput, err.Error())
+	}
+
+	var serviceErr *domain.ServiceError
+	switch msg.Op {
+	case OpUpsert:
+		if msg.Product == nil || msg.Product.Id < 1 || msg.Product.Name == "" || msg.Product.AdditionalInfo == "" {
+			return fmt.Errorf("%w: upsert message must contain product with id, name and additional info", domain.ErrInvalidInput)
+		}
+		if msg.Product.CategoryId < 0 {
+			return fmt.Errorf("%w: invalid category id %d", domain.ErrInvalidInput, msg.Product.CategoryId)
+		}
+		if msg.Product.Sku != "" {
+			if err := domain.ValidateSku(msg.Product.Sku); err != nil {
+				return err
+			}
+		}
+		_, serviceErr = c.svc.UpsertProduct(ctx, *msg.Product)
+	case OpDelete:
+		if msg.Id < 1 {
+			return fmt.Errorf("%w: delete message must contain product id", domain.ErrInvalidInput)
+		}
+		_, serviceErr = c.svc.DeleteProductById(ctx, msg.Id)
+		// product is already gone, most likely the message is redelivered
+		if serviceErr != nil && errors.Is(serviceErr.CriticalError, domain.ErrNotFound) {
+			serviceErr.NonCriticalErrors = append(serviceErr.NonCriticalErrors, serviceErr.CriticalError)
+			serviceErr.CriticalError = nil
+		}
+	default:
+		return fmt.Errorf("%w: unknown operation %q", domain.ErrInvalidInput, msg.Op)
+	}
+
+	if serviceErr != nil {
+		if serviceErr.CriticalError != nil {
+			return serviceErr.CriticalError
+		}
+		for _, err := range serviceErr.NonCriticalErrors {
+			c.logger.Debug("non-critical error while applying message", slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
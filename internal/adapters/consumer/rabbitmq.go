@@ -0,0 +1,123 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// RabbitMQConsumer reads product messages from a durable queue bound to
+// ingestion exchange. Messages are acked only after being applied
+type RabbitMQConsumer struct {
+	productApplier
+	url            string
+	exchange       string
+	queue          string
+	prefetch       int
+	reconnectDelay time.Duration
+	mu             sync.Mutex
+	conn           *amqp.Connection
+}
+
+func NewRabbitMQConsumer(url string, exchange string, queue string, svc ports.ResourseService, logger *slog.Logger) *RabbitMQConsumer {
+	return &RabbitMQConsumer{
+		productApplier: productApplier{
+			svc:    svc,
+			logger: logger.With(slog.String("component", "rabbitmq_consumer")),
+		},
+		url:            url,
+		exchange:       exchange,
+		queue:          queue,
+		prefetch:       16,
+		reconnectDelay: 2 * time.Second,
+	}
+}
+
+// Run consumes until ctx is cancelled, reconnecting whenever connection is lost
+func (c *RabbitMQConsumer) Run(ctx context.Context) error {
+	for {
+		err := c.consume(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		c.logger.Warn("rabbitmq consumer disconnected, reconnecting", slog.String("error", err.Error()))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(c.reconnectDelay):
+		}
+	}
+}
+
+func (c *RabbitMQConsumer) consume(ctx context.Context) error {
+	conn, err := amqp.Dial(c.url)
+	if err != nil {
+		return fmt.Errorf("consumer error: failed to connect to rabbitmq: %w", err)
+	}
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	defer conn.Close()
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("consumer error: failed to open channel: %w", err)
+	}
+	if err := channel.ExchangeDeclare(c.exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("consumer error: failed to declare exchange %s: %w", c.exchange, err)
+	}
+	if _, err := channel.QueueDeclare(c.queue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("consumer error: failed to declare queue %s: %w", c.queue, err)
+	}
+	if err := channel.QueueBind(c.queue, "#", c.exchange, false, nil); err != nil {
+		return fmt.Errorf("consumer error: failed to bind queue %s: %w", c.queue, err)
+	}
+	if err := channel.Qos(c.prefetch, 0, false); err != nil {
+		return fmt.Errorf("consumer error: failed to set qos: %w", err)
+	}
+	deliveries, err := channel.ConsumeWithContext(ctx, c.queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consumer error: failed to start consuming: %w", err)
+	}
+
+	for delivery := range deliveries {
+		traceparent, _ := delivery.Headers["traceparent"].(string)
+		msgCtx := withMessageTrace(ctx, traceparent)
+		err := c.handleMessage(msgCtx, delivery.Body)
+		switch {
+		case err == nil:
+			delivery.Ack(false)
+		case permanent(err):
+			c.logger.ErrorContext(msgCtx, "rejecting message that can not be applied",
+				slog.Uint64("delivery_tag", delivery.DeliveryTag),
+				slog.String("error", err.Error()))
+			delivery.Nack(false, false)
+		default:
+			c.logger.WarnContext(msgCtx, "failed to apply message, requeueing",
+				slog.Uint64("delivery_tag", delivery.DeliveryTag),
+				slog.String("error", err.Error()))
+			select {
+			case <-ctx.Done():
+			case <-time.After(c.reconnectDelay):
+			}
+			delivery.Nack(false, true)
+		}
+	}
+	return errors.New("consumer error: delivery channel closed")
+}
+
+func (c *RabbitMQConsumer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil || c.conn.IsClosed() {
+		return nil
+	}
+	return c.conn.Close()
+}
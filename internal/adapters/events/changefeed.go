@@ -0,0 +1,80 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// ChangeFeed keeps the most recent events in memory and wakes up clients
+// waiting for them. Events are numbered from 1 in order of publishing, the
+// number of the last one seen is a cursor to resume from. Feed is local to
+// the instance and starts empty after restart
+type ChangeFeed struct {
+	mu     sync.Mutex
+	events []domain.ProductEvent
+	head   int64
+	// closed and replaced on every publish
+	published chan struct{}
+}
+
+// NewChangeFeed retains up to size latest events
+func NewChangeFeed(size int) *ChangeFeed {
+	return &ChangeFeed{
+		events:    make([]domain.ProductEvent, size),
+		published: make(chan struct{}),
+	}
+}
+
+func (f *ChangeFeed) Publish(ctx context.Context, event domain.ProductEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events[f.head%int64(len(f.events))] = event
+	f.head++
+	close(f.published)
+	f.published = make(chan struct{})
+	return nil
+}
+
+func (f *ChangeFeed) Close() error {
+	return nil
+}
+
+// Cursor is the number of the latest event
+func (f *ChangeFeed) Cursor() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.head
+}
+
+// Wait returns events after cursor and the cursor of the last of them,
+// blocking until there are any or ctx is done. Cursors of events no longer
+// retained, or never published, are rejected with domain.ErrCursorExpired
+func (f *ChangeFeed) Wait(ctx context.Context, cursor int64) ([]domain.ProductEvent, int64, error) {
+	for {
+		f.mu.Lock()
+		retained := min(f.head, int64(len(f.events)))
+		if cursor > f.head || cursor < f.head-retained {
+			f.mu.Unlock()
+			return nil, cursor, domain.ErrCursorExpired
+		}
+		if cursor < f.head {
+			events := make([]domain.ProductEvent, 0, f.head-cursor)
+			for seq := cursor; seq < f.head; seq++ {
+				events = append(events, f.events[seq%int64(len(f.events))])
+			}
+			head := f.head
+			f.mu.Unlock()
+			return events, head, nil
+		}
+		published := f.published
+		f.mu.Unlock()
+
+		select {
+		case <-published:
+		case <-ctx.Done():
+			return []domain.ProductEvent{}, cursor, nil
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func publishUpdates(t *testing.T, feed *ChangeFeed, ids ...int64) {
+	t.Helper()
+	for _, id := range ids {
+		require.NoError(t, feed.Publish(context.Background(), domain.NewProductEvent(domain.EventProductUpdated, id, nil)))
+	}
+}
+
+func productIds(events []domain.ProductEvent) []int64 {
+	ids := make([]int64, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ProductId)
+	}
+	return ids
+}
+
+func TestChangeFeed(t *testing.T) {
+	ctx := context.Background()
+	feed := NewChangeFeed(3)
+	publishUpdates(t, feed, 1, 2)
+
+	events, cursor, err := feed.Wait(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, productIds(events))
+	assert.Equal(t, int64(2), cursor)
+
+	// only 3 latest events are retained
+	publishUpdates(t, feed, 3, 4, 5)
+	events, cursor, err = feed.Wait(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3, 4, 5}, productIds(events))
+	assert.Equal(t, int64(5), cursor)
+
+	_, _, err = feed.Wait(ctx, 1)
+	assert.True(t, errors.Is(err, domain.ErrCursorExpired))
+	_, _, err = feed.Wait(ctx, 6)
+	assert.True(t, errors.Is(err, domain.ErrCursorExpired))
+}
+
+func TestChangeFeedWait(t *testing.T) {
+	feed := NewChangeFeed(10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	events, cursor, err := feed.Wait(ctx, 0)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	assert.Equal(t, int64(0), cursor)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		publishUpdates(t, feed, 7)
+	}()
+	events, cursor, err = feed.Wait(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{7}, productIds(events))
+	assert.Equal(t, int64(1), cursor)
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// KafkaPublisher writes all product events to a single topic keyed by
+// product id, so events of one product stay ordered within a partition
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaPublisher(writer *kafka.Writer) *KafkaPublisher {
+	return &KafkaPublisher{writer: writer}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, event domain.ProductEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%w: error marshalling event: %s", domain.ErrPublishEvent, err.Error())
+	}
+	headers := []kafka.Header{
+		{Key: "event-id", Value: []byte(event.Id)},
+		{Key: "event-type", Value: []byte(event.Type)},
+	}
+	if event.Traceparent != "" {
+		headers = append(headers, kafka.Header{Key: "traceparent", Value: []byte(event.Traceparent)})
+	}
+	err = p.writer.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(strconv.FormatInt(event.ProductId, 10)),
+		Value:   data,
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: failed to publish %s event %s: %s", domain.ErrPublishEvent, event.Type, event.Id, err.Error())
+	}
+	return nil
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
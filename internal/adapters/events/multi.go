@@ -0,0 +1,39 @@
+package events
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// MultiPublisher fans every event out to all publishers. One failing publisher
+// does not prevent delivery to the others
+type MultiPublisher struct {
+	publishers []ports.EventPublisher
+}
+
+func NewMultiPublisher(publishers ...ports.EventPublisher) *MultiPublisher {
+	return &MultiPublisher{publishers: publishers}
+}
+
+func (m *MultiPublisher) Publish(ctx context.Context, event domain.ProductEvent) error {
+	var errs []error
+	for _, p := range m.publishers {
+		if err := p.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *MultiPublisher) Close() error {
+	var errs []error
+	for _, p := range m.publishers {
+		if err := p.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
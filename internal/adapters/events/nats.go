@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// NatsPublisher publishes product events to JetStream. Each event type goes to
+// its own subject under common prefix, e.g. catalog.product.created
+type NatsPublisher struct {
+	conn          *nats.Conn
+	js            jetstream.JetStream
+	subjectPrefix string
+	stream        string
+}
+
+// NewNatsPublisher makes sure the stream capturing all subjects under prefix
+// exists, so events are persisted even if no consumer is attached yet
+func NewNatsPublisher(ctx context.Context, conn *nats.Conn, stream string, subjectPrefix string) (*NatsPublisher, error) {
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to create jetstream context: %s", domain.ErrPublishEvent, err.Error())
+	}
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     stream,
+		Subjects: []string{subjectPrefix + ".>"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to create stream %s: %s", domain.ErrPublishEvent, stream, err.Error())
+	}
+	return &NatsPublisher{
+		conn:          conn,
+		js:            js,
+		subjectPrefix: subjectPrefix,
+		stream:        stream,
+	}, nil
+}
+
+func natsSubject(prefix string, eventType string) string {
+	return fmt.Sprintf("%s.%s", prefix, eventType)
+}
+
+// Publish waits for stream acknowledgement. Event id is used as message id,
+// so retried publishes are deduplicated by the server
+func (p *NatsPublisher) Publish(ctx context.Context, event domain.ProductEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%w: error marshalling event: %s", domain.ErrPublishEvent, err.Error())
+	}
+	msg := nats.NewMsg(natsSubject(p.subjectPrefix, event.Type))
+	msg.Data = data
+	if event.Traceparent != "" {
+		msg.Header.Set("traceparent", event.Traceparent)
+	}
+	_, err = p.js.PublishMsg(ctx, msg,
+		jetstream.WithMsgID(event.Id),
+		jetstream.WithExpectStream(p.stream),
+		jetstream.WithRetryAttempts(3),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: failed to publish %s event %s: %s", domain.ErrPublishEvent, event.Type, event.Id, err.Error())
+	}
+	return nil
+}
+
+func (p *NatsPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// RabbitMQPublisher publishes product events to a durable topic exchange,
+// using event type as routing key. Channel is put into confirm mode, so
+// Publish returns only after broker has taken responsibility for the message
+type RabbitMQPublisher struct {
+	url      string
+	exchange string
+	mu       sync.Mutex
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+}
+
+func NewRabbitMQPublisher(url string, exchange string) (*RabbitMQPublisher, error) {
+	p := &RabbitMQPublisher{
+		url:      url,
+		exchange: exchange,
+	}
+	if _, err := p.getChannel(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// getChannel returns open channel, transparently redialing if connection
+// or channel were closed since last publish
+func (p *RabbitMQPublisher) getChannel() (*amqp.Channel, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.channel != nil && !p.channel.IsClosed() {
+		return p.channel, nil
+	}
+	if p.conn == nil || p.conn.IsClosed() {
+		conn, err := amqp.Dial(p.url)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to connect to rabbitmq: %s", domain.ErrPublishEvent, err.Error())
+		}
+		p.conn = conn
+	}
+	channel, err := p.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to open rabbitmq channel: %s", domain.ErrPublishEvent, err.Error())
+	}
+	if err := channel.ExchangeDeclare(p.exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("%w: failed to declare exchange %s: %s", domain.ErrPublishEvent, p.exchange, err.Error())
+	}
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("%w: failed to enable publisher confirms: %s", domain.ErrPublishEvent, err.Error())
+	}
+	p.channel = channel
+	return channel, nil
+}
+
+func (p *RabbitMQPublisher) Publish(ctx context.Context, event domain.ProductEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%w: error marshalling event: %s", domain.ErrPublishEvent, err.Error())
+	}
+	msg := amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    event.Id,
+		Type:         event.Type,
+		Timestamp:    event.OccurredAt,
+		Body:         data,
+	}
+	if event.Traceparent != "" {
+		msg.Headers = amqp.Table{"traceparent": event.Traceparent}
+	}
+
+	// one retry covers the case when connection was dropped while idle
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		channel, err := p.getChannel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx, p.exchange, event.Type, false, false, msg)
+		if err != nil {
+			lastErr = fmt.Errorf("%w: failed to publish %s event %s: %s", domain.ErrPublishEvent, event.Type, event.Id, err.Error())
+			continue
+		}
+		acked, err := confirmation.WaitContext(ctx)
+		if err != nil {
+			return fmt.Errorf("%w: no confirmation for %s event %s: %s", domain.ErrPublishEvent, event.Type, event.Id, err.Error())
+		}
+		if !acked {
+			return fmt.Errorf("%w: %s event %s was nacked by broker", domain.ErrPublishEvent, event.Type, event.Id)
+		}
+		return nil
+	}
+	return lastErr
+}
+
+func (p *RabbitMQPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil || p.conn.IsClosed() {
+		return nil
+	}
+	return p.conn.Close()
+}
@@ -0,0 +1,35 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// WebhookPublisher queues every event for webhooks subscribed to its type,
+// they are delivered later by service.WebhookDispatcher
+type WebhookPublisher struct {
+	webhooks ports.WebhookRepository
+}
+
+func NewWebhookPublisher(webhooks ports.WebhookRepository) *WebhookPublisher {
+	return &WebhookPublisher{webhooks: webhooks}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event domain.ProductEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%w: error marshalling event: %s", domain.ErrPublishEvent, err.Error())
+	}
+	if _, err := p.webhooks.EnqueueWebhookDeliveries(ctx, event, data); err != nil {
+		return fmt.Errorf("%w: failed to queue event for webhooks: %w", domain.ErrPublishEvent, err)
+	}
+	return nil
+}
+
+func (p *WebhookPublisher) Close() error {
+	return nil
+}
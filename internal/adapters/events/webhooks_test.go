@@ -0,0 +1,36 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestWebhookPublisher(t *testing.T) {
+	ctx := context.Background()
+	webhooks := fakes.NewWebhookRepository()
+	_, err := webhooks.CreateWebhook(ctx, domain.Webhook{URL: "https://partner.example.com/hooks", Secret: "secret", EventTypes: []string{domain.EventProductDeleted}})
+	require.NoError(t, err)
+	publisher := NewWebhookPublisher(webhooks)
+
+	require.NoError(t, publisher.Publish(ctx, domain.NewProductEvent(domain.EventProductCreated, 1, &domain.Product{Id: 1})))
+	assert.Empty(t, webhooks.Deliveries(), "webhook is not subscribed to creations")
+
+	deleted := domain.NewProductEvent(domain.EventProductDeleted, 1, nil)
+	require.NoError(t, publisher.Publish(ctx, deleted))
+	deliveries := webhooks.Deliveries()
+	require.Len(t, deliveries, 1)
+	var sent domain.ProductEvent
+	require.NoError(t, json.Unmarshal(deliveries[0].Payload, &sent))
+	assert.Equal(t, deleted.Id, sent.Id)
+	assert.Equal(t, domain.EventProductDeleted, sent.Type)
+
+	webhooks.FailWith("EnqueueWebhookDeliveries", domain.ErrInternalDb)
+	assert.ErrorIs(t, publisher.Publish(ctx, deleted), domain.ErrPublishEvent)
+}
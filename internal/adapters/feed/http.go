@@ -0,0 +1,155 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// FieldMapping tells which feed fields hold product attributes
+type FieldMapping struct {
+	Id             string
+	Name           string
+	AdditionalInfo string
+}
+
+var DefaultFieldMapping = FieldMapping{
+	Id:             "id",
+	Name:           "name",
+	AdditionalInfo: "additionalInfo",
+}
+
+// ParseFieldMapping parses "id=sku_id,name=title,additionalInfo=description".
+// Attributes not mentioned keep default field names
+func ParseFieldMapping(s string) (FieldMapping, error) {
+	mapping := DefaultFieldMapping
+	if strings.TrimSpace(s) == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		attr, field, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || field == "" {
+			return mapping, fmt.Errorf("%w: invalid field mapping %q", domain.ErrFeed, pair)
+		}
+		switch attr {
+		case "id":
+			mapping.Id = field
+		case "name":
+			mapping.Name = field
+		case "additionalInfo":
+			mapping.AdditionalInfo = field
+		default:
+			return mapping, fmt.Errorf("%w: unknown product attribute %q in field mapping", domain.ErrFeed, attr)
+		}
+	}
+	return mapping, nil
+}
+
+// HTTPFeed fetches JSON feed, either a top-level array or an array nested
+// under itemsPath (dot-separated, e.g. "data.items")
+type HTTPFeed struct {
+	httpClient    *http.Client
+	url           string
+	authorization string
+	itemsPath     string
+	mapping       FieldMapping
+}
+
+func NewHTTPFeed(httpClient *http.Client, url string, authorization string, itemsPath string, mapping FieldMapping) *HTTPFeed {
+	return &HTTPFeed{
+		httpClient:    httpClient,
+		url:           url,
+		authorization: authorization,
+		itemsPath:     itemsPath,
+		mapping:       mapping,
+	}
+}
+
+func (f *HTTPFeed) Fetch(ctx context.Context) ([]domain.Product, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to build request: %s", domain.ErrFeed, err.Error())
+	}
+	req.Header.Set("Accept", "application/json")
+	if tc, ok := domain.TraceContextFromContext(ctx); ok {
+		for key, value := range tc.TraceHeaders() {
+			req.Header.Set(key, value)
+		}
+	}
+	if f.authorization != "" {
+		req.Header.Set("Authorization", f.authorization)
+	}
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to fetch feed: %s", domain.ErrFeed, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: feed responded with status %d", domain.ErrFeed, resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode feed: %s", domain.ErrFeed, err.Error())
+	}
+	if f.itemsPath != "" {
+		for _, key := range strings.Split(f.itemsPath, ".") {
+			obj, ok := document.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: items path %q not found in feed", domain.ErrFeed, f.itemsPath)
+			}
+			document = obj[key]
+		}
+	}
+	items, ok := document.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: feed items are not an array", domain.ErrFeed)
+	}
+
+	products := make([]domain.Product, 0, len(items))
+	for i, item := range items {
+		product, err := f.mapItem(item)
+		if err != nil {
+			return nil, fmt.Errorf("%w: item #%d: %s", domain.ErrFeed, i, err.Error())
+		}
+		products = append(products, product)
+	}
+	return products, nil
+}
+
+func (f *HTTPFeed) mapItem(item interface{}) (domain.Product, error) {
+	obj, ok := item.(map[string]interface{})
+	if !ok {
+		return domain.Product{}, fmt.Errorf("item is not an object")
+	}
+	var product domain.Product
+	switch id := obj[f.mapping.Id].(type) {
+	case json.Number:
+		value, err := id.Int64()
+		if err != nil {
+			return product, fmt.Errorf("invalid id %q", id)
+		}
+		product.Id = value
+	case string:
+		value, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return product, fmt.Errorf("invalid id %q", id)
+		}
+		product.Id = value
+	default:
+		return product, fmt.Errorf("missing id field %q", f.mapping.Id)
+	}
+	product.Name, _ = obj[f.mapping.Name].(string)
+	product.AdditionalInfo, _ = obj[f.mapping.AdditionalInfo].(string)
+	if product.Id < 1 || product.Name == "" || product.AdditionalInfo == "" {
+		return product, fmt.Errorf("product %d must have positive id, name and additional info", product.Id)
+	}
+	return product, nil
+}
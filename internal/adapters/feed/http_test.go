@@ -0,0 +1,84 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func TestFetch(t *testing.T) {
+	testCases := []struct {
+		name           string
+		status         int
+		body           string
+		itemsPath      string
+		mapping        string
+		expectedResult []domain.Product
+		expectedError  error
+	}{
+		{
+			name:   "fetch - default mapping",
+			status: http.StatusOK,
+			body:   `[{"id":1,"name":"Product","additionalInfo":"Info","price":10}]`,
+			expectedResult: []domain.Product{
+				{Id: 1, Name: "Product", AdditionalInfo: "Info"},
+			},
+		},
+		{
+			name:      "fetch - custom mapping and nested items",
+			status:    http.StatusOK,
+			body:      `{"data":{"items":[{"sku":"7","title":"Product","description":"Info"}]}}`,
+			itemsPath: "data.items",
+			mapping:   "id=sku,name=title,additionalInfo=description",
+			expectedResult: []domain.Product{
+				{Id: 7, Name: "Product", AdditionalInfo: "Info"},
+			},
+		},
+		{
+			name:          "fetch - item without name",
+			status:        http.StatusOK,
+			body:          `[{"id":1,"additionalInfo":"Info"}]`,
+			expectedError: domain.ErrFeed,
+		},
+		{
+			name:          "fetch - feed unavailable",
+			status:        http.StatusBadGateway,
+			expectedError: domain.ErrFeed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+			mapping, err := ParseFieldMapping(tc.mapping)
+			require.NoError(t, err)
+
+			products, err := NewHTTPFeed(server.Client(), server.URL, "Bearer secret", tc.itemsPath, mapping).Fetch(context.Background())
+			if tc.expectedError != nil {
+				assert.True(t, errors.Is(err, tc.expectedError))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedResult, products)
+		})
+	}
+}
+
+func TestParseFieldMappingInvalid(t *testing.T) {
+	_, err := ParseFieldMapping("price=cost")
+	assert.True(t, errors.Is(err, domain.ErrFeed))
+	_, err = ParseFieldMapping("name")
+	assert.True(t, errors.Is(err, domain.ErrFeed))
+}
@@ -0,0 +1,152 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/pelyams/simpler_go_service/api/productpb"
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/routing"
+)
+
+// apiKeyMetadata carries API key of call, as X-API-Key header does of HTTP
+// requests
+const apiKeyMetadata = "x-api-key"
+
+// methodActions classifies calls as requestAction of HTTP authorization
+// does requests, methods not listed are writes
+var methodActions = map[string]domain.Action{
+	productpb.ProductService_GetProduct_FullMethodName:    domain.ActionRead,
+	productpb.ProductService_ListProducts_FullMethodName:  domain.ActionRead,
+	productpb.ProductService_CreateProduct_FullMethodName: domain.ActionWrite,
+	productpb.ProductService_UpdateProduct_FullMethodName: domain.ActionWrite,
+	productpb.ProductService_DeleteProduct_FullMethodName: domain.ActionWrite,
+}
+
+// Auth authenticates and authorizes calls the way HTTP middleware does
+// requests, with the same authenticators and policy. Common name of verified
+// client certificate becomes principal of call
+type Auth struct {
+	keys       *routing.APIKeyAuthenticator
+	tokens     *routing.JWTAuthenticator
+	authorizer *routing.Authorizer
+	logger     *slog.Logger
+}
+
+type AuthOption func(a *Auth)
+
+// WithAPIKeys requires calls to carry a valid key in x-api-key metadata,
+// unless key is not required for what they do
+func WithAPIKeys(keys *routing.APIKeyAuthenticator) AuthOption {
+	return func(a *Auth) {
+		a.keys = keys
+	}
+}
+
+// WithTokens authenticates calls carrying a bearer token in authorization
+// metadata, those need no key
+func WithTokens(tokens *routing.JWTAuthenticator) AuthOption {
+	return func(a *Auth) {
+		a.tokens = tokens
+	}
+}
+
+// WithAuthorizer lets through only calls policy allows to caller
+func WithAuthorizer(authorizer *routing.Authorizer) AuthOption {
+	return func(a *Auth) {
+		a.authorizer = authorizer
+	}
+}
+
+func NewAuth(logger *slog.Logger, opts ...AuthOption) *Auth {
+	a := &Auth{logger: logger.With(slog.String("component", "grpc_auth"))}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Interceptor rejects calls without valid credentials with Unauthenticated,
+// and those policy does not allow to caller with PermissionDenied
+func (a *Auth) Interceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	action, ok := methodActions[info.FullMethod]
+	if !ok {
+		action = domain.ActionWrite
+	}
+	ctx = clientCertPrincipal(ctx)
+	if authorization := metadataValue(ctx, "authorization"); a.tokens != nil && authorization != "" {
+		scheme, token, _ := strings.Cut(authorization, " ")
+		if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return nil, status.Error(codes.Unauthenticated, "Bearer token required")
+		}
+		var err error
+		if ctx, err = a.tokens.Authenticate(ctx, strings.TrimSpace(token)); err != nil {
+			return nil, a.authError(ctx, err, "Invalid bearer token")
+		}
+	}
+	if a.keys != nil && routing.PrincipalFromContext(ctx) == "" && a.keys.RequiresKey(action) {
+		key := metadataValue(ctx, apiKeyMetadata)
+		if key == "" {
+			return nil, status.Error(codes.Unauthenticated, "API key required")
+		}
+		var err error
+		if ctx, err = a.keys.Authenticate(ctx, key); err != nil {
+			return nil, a.authError(ctx, err, "Invalid API key")
+		}
+	}
+	if a.authorizer != nil {
+		if err := a.authorizer.Authorize(ctx, action); err != nil {
+			a.logger.InfoContext(ctx, "rejected call", slog.String("method", info.FullMethod), slog.String("error", err.Error()))
+			if errors.Is(err, domain.ErrUnauthenticated) {
+				return nil, status.Error(codes.Unauthenticated, "Authentication required")
+			}
+			return nil, status.Error(codes.PermissionDenied, "Not allowed to "+string(action))
+		}
+	}
+	return handler(ctx, req)
+}
+
+// authError turns failure to authenticate into status, failures of lookups
+// are only logged
+func (a *Auth) authError(ctx context.Context, err error, message string) error {
+	if errors.Is(err, domain.ErrUnauthenticated) {
+		a.logger.InfoContext(ctx, "rejected credentials", slog.String("error", err.Error()))
+		return status.Error(codes.Unauthenticated, message)
+	}
+	a.logger.ErrorContext(ctx, "failed to authenticate call", slog.String("error", err.Error()))
+	if errors.Is(err, domain.ErrUnavailable) {
+		return status.Error(codes.Unavailable, "Service unavailable")
+	}
+	return status.Error(codes.Internal, "Internal server error")
+}
+
+// clientCertPrincipal makes common name of verified client certificate the
+// principal of call, as routing.ClientCertMiddleware does of requests
+func clientCertPrincipal(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ctx
+	}
+	return routing.WithPrincipal(ctx, info.State.VerifiedChains[0][0].Subject.CommonName)
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	values := metadata.ValueFromIncomingContext(ctx, key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
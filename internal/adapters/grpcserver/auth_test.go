@@ -0,0 +1,133 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/pelyams/simpler_go_service/api/productpb"
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/routing"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestAuthAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	keys := fakes.NewAPIKeyRepository()
+	_, err := keys.CreateAPIKey(ctx, domain.APIKey{Principal: "importer", Role: domain.RoleWriter}, routing.HashAPIKey("valid-key"))
+	require.NoError(t, err)
+	auth := NewAuth(slog.New(slog.NewTextHandler(io.Discard, nil)), WithAPIKeys(routing.NewAPIKeyAuthenticator(keys, false, time.Minute)))
+	client := newClient(t, grpc.ChainUnaryInterceptor(auth.Interceptor))
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, apiKeyMetadata, key)
+	}
+
+	t.Run("writes without key are rejected", func(t *testing.T) {
+		_, err := client.CreateProduct(ctx, &productpb.CreateProductRequest{Name: "lamp", AdditionalInfo: "brass"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		_, err = client.DeleteProduct(ctx, &productpb.DeleteProductRequest{Id: 1})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("writes with unknown key are rejected", func(t *testing.T) {
+		_, err := client.CreateProduct(withKey("stolen-key"), &productpb.CreateProductRequest{Name: "lamp", AdditionalInfo: "brass"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("writes with valid key are let through", func(t *testing.T) {
+		created, err := client.CreateProduct(withKey("valid-key"), &productpb.CreateProductRequest{Name: "lamp", AdditionalInfo: "brass"})
+		require.NoError(t, err)
+		_, err = client.UpdateProduct(withKey("valid-key"), &productpb.UpdateProductRequest{Id: created.Id, Name: "lamp", AdditionalInfo: "copper"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("reads need no key", func(t *testing.T) {
+		_, err := client.ListProducts(ctx, &productpb.ListProductsRequest{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("failed lookup is not taken for invalid key", func(t *testing.T) {
+		keys.FailWith("LookupAPIKey", fmt.Errorf("%w: %w", domain.ErrInternalDb, domain.ErrUnavailable))
+		t.Cleanup(func() { keys.FailWith("LookupAPIKey", nil) })
+		_, err := client.CreateProduct(withKey("another-key"), &productpb.CreateProductRequest{Name: "desk", AdditionalInfo: "oak"})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+type staticKeySet map[string]crypto.PublicKey
+
+func (s staticKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok := s[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: no signing key with id %q", domain.ErrNotFound, kid)
+	}
+	return key, nil
+}
+
+func TestAuthPolicy(t *testing.T) {
+	ctx := context.Background()
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keys := fakes.NewAPIKeyRepository()
+	_, err = keys.CreateAPIKey(ctx, domain.APIKey{Principal: "dashboard", Role: domain.RoleReader}, routing.HashAPIKey("reader-key"))
+	require.NoError(t, err)
+	auth := NewAuth(slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WithAPIKeys(routing.NewAPIKeyAuthenticator(keys, true, time.Minute)),
+		WithTokens(routing.NewJWTAuthenticator(staticKeySet{"current": &signingKey.PublicKey}, "https://issuer.example.com", "")),
+		WithAuthorizer(routing.NewAuthorizer(domain.DefaultPolicy(), nil, domain.RoleReader, "")))
+	client := newClient(t, grpc.ChainUnaryInterceptor(auth.Interceptor))
+	withToken := func(roles ...string) context.Context {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+			"iss":   "https://issuer.example.com",
+			"sub":   "partner",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"roles": roles,
+		})
+		token.Header["kid"] = "current"
+		signed, err := token.SignedString(signingKey)
+		require.NoError(t, err)
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+signed)
+	}
+	reader := metadata.AppendToOutgoingContext(ctx, apiKeyMetadata, "reader-key")
+	create := &productpb.CreateProductRequest{Name: "lamp", AdditionalInfo: "brass"}
+
+	t.Run("reader may read but not write", func(t *testing.T) {
+		_, err := client.ListProducts(reader, &productpb.ListProductsRequest{})
+		assert.NoError(t, err)
+		_, err = client.CreateProduct(reader, create)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		_, err = client.DeleteProduct(withToken(), &productpb.DeleteProductRequest{Id: 1})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), "token without roles grants authenticated role")
+	})
+
+	t.Run("writer token may write without key", func(t *testing.T) {
+		created, err := client.CreateProduct(withToken("writer"), create)
+		require.NoError(t, err)
+		_, err = client.DeleteProduct(withToken("writer"), &productpb.DeleteProductRequest{Id: created.Id})
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid credentials are rejected", func(t *testing.T) {
+		_, err := client.ListProducts(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer not-a-token"), &productpb.ListProductsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		_, err = client.ListProducts(metadata.AppendToOutgoingContext(ctx, "authorization", "Basic cGFydG5lcg=="), &productpb.ListProductsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		_, err = client.ListProducts(ctx, &productpb.ListProductsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
@@ -0,0 +1,25 @@
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+
+	"github.com/pelyams/simpler_go_service/api/productpb"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// NewGateway serves ProductService as JSON over HTTP, with routes generated
+// from api/proto/product_service.yaml, so that both APIs are derived from the
+// same definitions. Calls go to ProductServer in process, not through gRPC
+// interceptors, it is meant to be mounted behind HTTP middlewares that
+// authenticate and log requests
+func NewGateway(svc ports.ResourseService, logger *slog.Logger) (http.Handler, error) {
+	mux := runtime.NewServeMux()
+	if err := productpb.RegisterProductServiceHandlerServer(context.Background(), mux, NewProductServer(svc, logger)); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
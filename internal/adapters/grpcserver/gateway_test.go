@@ -0,0 +1,82 @@
+package grpcserver
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestGateway(t *testing.T) {
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "lamp", AdditionalInfo: "brass"})
+	gateway, err := NewGateway(service.NewResourceService(repo, fakes.NewCache()), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		gateway.ServeHTTP(rec, req)
+		return rec
+	}
+	// int64 fields are strings in protobuf JSON mapping
+	type product struct {
+		Id             string `json:"id"`
+		Name           string `json:"name"`
+		AdditionalInfo string `json:"additionalInfo"`
+	}
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+		t.Helper()
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(v))
+	}
+
+	t.Run("gets product", func(t *testing.T) {
+		rec := do(http.MethodGet, "/v1/products/1", "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var got product
+		decode(t, rec, &got)
+		assert.Equal(t, product{Id: "1", Name: "lamp", AdditionalInfo: "brass"}, got)
+	})
+
+	t.Run("creates, updates and deletes product", func(t *testing.T) {
+		rec := do(http.MethodPost, "/v1/products", `{"name":"desk","additionalInfo":"oak"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var created product
+		decode(t, rec, &created)
+		assert.Equal(t, "desk", created.Name)
+
+		rec = do(http.MethodPut, "/v1/products/"+created.Id, `{"name":"desk","additionalInfo":"walnut"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "walnut", repo.Products()[1].AdditionalInfo)
+
+		rec = do(http.MethodDelete, "/v1/products/"+created.Id, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Len(t, repo.Products(), 1)
+	})
+
+	t.Run("lists products by query parameters", func(t *testing.T) {
+		rec := do(http.MethodGet, "/v1/products?name=LAMP&limit=10", "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var list struct {
+			Products []product `json:"products"`
+		}
+		decode(t, rec, &list)
+		require.Len(t, list.Products, 1)
+		assert.Equal(t, "lamp", list.Products[0].Name)
+	})
+
+	t.Run("maps status codes", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/v1/products/42", "").Code)
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/v1/products", `{"name":"desk"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/v1/products?sort=price", "").Code)
+	})
+}
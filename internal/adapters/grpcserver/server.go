@@ -0,0 +1,210 @@
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pelyams/simpler_go_service/api/productpb"
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+	"github.com/pelyams/simpler_go_service/internal/routing"
+)
+
+// ProductServer serves ProductService of api/proto/product_service.proto
+// with the same service layer HTTP handlers use
+type ProductServer struct {
+	productpb.UnimplementedProductServiceServer
+	svc    ports.ResourseService
+	logger *slog.Logger
+}
+
+func NewProductServer(svc ports.ResourseService, logger *slog.Logger) *ProductServer {
+	return &ProductServer{
+		svc:    svc,
+		logger: logger.With(slog.String("component", "grpc")),
+	}
+}
+
+// NewServer creates gRPC server with ProductService registered, calls are
+// logged like HTTP requests are
+func NewServer(svc ports.ResourseService, logger *slog.Logger, opts ...grpc.ServerOption) *grpc.Server {
+	products := NewProductServer(svc, logger)
+	server := grpc.NewServer(append([]grpc.ServerOption{grpc.UnaryInterceptor(products.logCall)}, opts...)...)
+	productpb.RegisterProductServiceServer(server, products)
+	return server
+}
+
+func (s *ProductServer) GetProduct(ctx context.Context, req *productpb.GetProductRequest) (*productpb.Product, error) {
+	if req.Id < 0 {
+		return nil, status.Error(codes.InvalidArgument, "product id must not be negative")
+	}
+	body, serviceErr := s.svc.GetProductById(ctx, req.Id)
+	if err := s.serviceError(ctx, serviceErr); err != nil {
+		return nil, err
+	}
+	var product domain.Product
+	if err := json.Unmarshal(body, &product); err != nil {
+		s.logger.ErrorContext(ctx, "failed to decode product",
+			slog.Int64("id", req.Id), slog.String("error", err.Error()))
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+	return routing.ProductMessage(product), nil
+}
+
+// ListProducts pages products like GET /products does, without limit all
+// matching products are returned
+func (s *ProductServer) ListProducts(ctx context.Context, req *productpb.ListProductsRequest) (*productpb.ProductList, error) {
+	if req.Limit < 0 || req.Offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and offset must not be negative")
+	}
+	sort, err := domain.ParseProductSort(req.Sort)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid sort")
+	}
+	filter := domain.ProductFilter{Name: strings.TrimSpace(req.Name), InfoContains: req.InfoContains}
+
+	var products []domain.Product
+	var serviceErr *domain.ServiceError
+	if req.Limit > 0 {
+		products, serviceErr = s.svc.GetProductsPaged(ctx, filter, sort, req.Limit, req.Offset)
+	} else {
+		var skipped int64
+		serviceErr = s.svc.EachProduct(ctx, filter, sort, func(product domain.Product) error {
+			if skipped < req.Offset {
+				skipped++
+				return nil
+			}
+			products = append(products, product)
+			return nil
+		})
+	}
+	if serviceErr != nil && errors.Is(serviceErr.CriticalError, domain.ErrInvalidInput) {
+		s.logger.WarnContext(ctx, "rejected sort", slog.String("error", serviceErr.CriticalError.Error()))
+		return nil, status.Error(codes.InvalidArgument, "Invalid sort")
+	}
+	if err := s.serviceError(ctx, serviceErr); err != nil {
+		return nil, err
+	}
+	list := &productpb.ProductList{Products: make([]*productpb.Product, len(products))}
+	for i, product := range products {
+		list.Products[i] = routing.ProductMessage(product)
+	}
+	return list, nil
+}
+
+func (s *ProductServer) CreateProduct(ctx context.Context, req *productpb.CreateProductRequest) (*productpb.Product, error) {
+	product := domain.NewProduct{Name: req.Name, AdditionalInfo: req.AdditionalInfo, CategoryId: req.CategoryId, Sku: req.Sku}
+	if err := validateProduct(product); err != nil {
+		return nil, err
+	}
+	id, serviceErr := s.svc.CreateProduct(ctx, product)
+	if err := s.serviceError(ctx, serviceErr); err != nil {
+		return nil, err
+	}
+	return routing.ProductMessage(domain.Product{Id: id, Name: product.Name, AdditionalInfo: product.AdditionalInfo, Version: 1, CategoryId: product.CategoryId, Sku: product.Sku}), nil
+}
+
+func (s *ProductServer) UpdateProduct(ctx context.Context, req *productpb.UpdateProductRequest) (*productpb.Product, error) {
+	if req.Id < 0 {
+		return nil, status.Error(codes.InvalidArgument, "product id must not be negative")
+	}
+	if req.Version < 0 {
+		return nil, status.Error(codes.InvalidArgument, "version must not be negative")
+	}
+	product := domain.NewProduct{Name: req.Name, AdditionalInfo: req.AdditionalInfo, Version: req.Version, CategoryId: req.CategoryId, Sku: req.Sku}
+	if err := validateProduct(product); err != nil {
+		return nil, err
+	}
+	// service returns product as it was before the update
+	old, serviceErr := s.svc.UpdateProductById(ctx, req.Id, product)
+	if err := s.serviceError(ctx, serviceErr); err != nil {
+		return nil, err
+	}
+	return routing.ProductMessage(domain.Product{Id: req.Id, Name: product.Name, AdditionalInfo: product.AdditionalInfo, Version: old.Version + 1, CategoryId: product.CategoryId, Sku: product.Sku, Stock: old.Stock, Tags: old.Tags, Images: old.Images}), nil
+}
+
+// validateProduct checks product the way HTTP handlers check bodies
+func validateProduct(product domain.NewProduct) error {
+	if product.Name == "" || product.AdditionalInfo == "" {
+		return status.Error(codes.InvalidArgument, "product name or additional info is empty")
+	}
+	if product.CategoryId < 0 {
+		return status.Error(codes.InvalidArgument, "category id must not be negative")
+	}
+	if product.Sku != "" {
+		if err := domain.ValidateSku(product.Sku); err != nil {
+			return status.Error(codes.InvalidArgument, "Invalid sku")
+		}
+	}
+	return nil
+}
+
+func (s *ProductServer) DeleteProduct(ctx context.Context, req *productpb.DeleteProductRequest) (*productpb.Product, error) {
+	if req.Id < 0 {
+		return nil, status.Error(codes.InvalidArgument, "product id must not be negative")
+	}
+	product, serviceErr := s.svc.DeleteProductById(ctx, req.Id)
+	if err := s.serviceError(ctx, serviceErr); err != nil {
+		return nil, err
+	}
+	return routing.ProductMessage(*product), nil
+}
+
+// serviceError logs non-critical errors and turns critical one into status.
+// Details of internal errors are only logged, as HTTP handlers do
+func (s *ProductServer) serviceError(ctx context.Context, serviceErr *domain.ServiceError) error {
+	if serviceErr == nil {
+		return nil
+	}
+	for _, err := range serviceErr.NonCriticalErrors {
+		s.logger.WarnContext(ctx, "service error", slog.String("error", err.Error()))
+	}
+	err := serviceErr.CriticalError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, domain.ErrNotFound):
+		return status.Error(codes.NotFound, "Product not found")
+	case errors.Is(err, domain.ErrVersionConflict):
+		return status.Error(codes.Aborted, "Product was changed since the version given")
+	case errors.Is(err, domain.ErrVersionRequired):
+		return status.Error(codes.FailedPrecondition, "Version is required")
+	case errors.Is(err, domain.ErrUnknownCategory):
+		return status.Error(codes.InvalidArgument, "Category does not exist")
+	case errors.Is(err, domain.ErrDuplicateSku):
+		return status.Error(codes.AlreadyExists, "SKU is already used by another product")
+	case errors.Is(err, domain.ErrInvalidInput):
+		return status.Error(codes.InvalidArgument, "Invalid input")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "Deadline exceeded")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "Request canceled")
+	}
+	s.logger.ErrorContext(ctx, "service error", slog.String("error", err.Error()))
+	if errors.Is(err, domain.ErrUnavailable) {
+		return status.Error(codes.Unavailable, "Service unavailable")
+	}
+	return status.Error(codes.Internal, "Internal server error")
+}
+
+func (s *ProductServer) logCall(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	level := slog.LevelInfo
+	if status.Code(err) == codes.Internal || status.Code(err) == codes.Unavailable {
+		level = slog.LevelError
+	}
+	s.logger.Log(ctx, level, "grpc call",
+		slog.String("method", info.FullMethod),
+		slog.String("code", status.Code(err).String()),
+		slog.Duration("duration", time.Since(start)))
+	return resp, err
+}
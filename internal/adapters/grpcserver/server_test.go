@@ -0,0 +1,194 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/pelyams/simpler_go_service/api/productpb"
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func newClient(t *testing.T, opts ...grpc.ServerOption) productpb.ProductServiceClient {
+	t.Helper()
+	return newRepositoryClient(t, fakes.NewRepository(), opts...)
+}
+
+func newRepositoryClient(t *testing.T, repo *fakes.Repository, opts ...grpc.ServerOption) productpb.ProductServiceClient {
+	t.Helper()
+	return newServiceClient(t, service.NewResourceService(repo, fakes.NewCache()), opts...)
+}
+
+func newServiceClient(t *testing.T, svc ports.ResourseService, opts ...grpc.ServerOption) productpb.ProductServiceClient {
+	t.Helper()
+	server := NewServer(svc, slog.New(slog.NewTextHandler(io.Discard, nil)), opts...)
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return productpb.NewProductServiceClient(conn)
+}
+
+func TestProductService(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	created, err := client.CreateProduct(ctx, &productpb.CreateProductRequest{Name: "lamp", AdditionalInfo: "brass"})
+	require.NoError(t, err)
+	assert.Positive(t, created.Id)
+	_, err = client.CreateProduct(ctx, &productpb.CreateProductRequest{Name: "desk", AdditionalInfo: "oak"})
+	require.NoError(t, err)
+
+	product, err := client.GetProduct(ctx, &productpb.GetProductRequest{Id: created.Id})
+	require.NoError(t, err)
+	assert.Equal(t, "lamp", product.Name)
+	assert.Equal(t, "brass", product.AdditionalInfo)
+
+	updated, err := client.UpdateProduct(ctx, &productpb.UpdateProductRequest{Id: created.Id, Name: "lamp", AdditionalInfo: "copper"})
+	require.NoError(t, err)
+	assert.Equal(t, "copper", updated.AdditionalInfo)
+
+	list, err := client.ListProducts(ctx, &productpb.ListProductsRequest{Sort: "name"})
+	require.NoError(t, err)
+	require.Len(t, list.Products, 2)
+	assert.Equal(t, "desk", list.Products[0].Name)
+
+	list, err = client.ListProducts(ctx, &productpb.ListProductsRequest{Limit: 1, Offset: 1, Sort: "name"})
+	require.NoError(t, err)
+	require.Len(t, list.Products, 1)
+	assert.Equal(t, "lamp", list.Products[0].Name)
+
+	list, err = client.ListProducts(ctx, &productpb.ListProductsRequest{InfoContains: "OAK"})
+	require.NoError(t, err)
+	require.Len(t, list.Products, 1)
+	assert.Equal(t, "desk", list.Products[0].Name)
+
+	deleted, err := client.DeleteProduct(ctx, &productpb.DeleteProductRequest{Id: created.Id})
+	require.NoError(t, err)
+	assert.Equal(t, "copper", deleted.AdditionalInfo)
+
+	_, err = client.GetProduct(ctx, &productpb.GetProductRequest{Id: created.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestProductServiceSkuAndCategory(t *testing.T) {
+	ctx := context.Background()
+	repo := fakes.NewRepository()
+	categoryId, err := repo.StoreCategory(ctx, "lighting")
+	require.NoError(t, err)
+	client := newRepositoryClient(t, repo)
+
+	created, err := client.CreateProduct(ctx, &productpb.CreateProductRequest{Name: "lamp", AdditionalInfo: "brass", CategoryId: categoryId, Sku: "LMP-1"})
+	require.NoError(t, err)
+	expected := &productpb.Product{Id: created.Id, Name: "lamp", AdditionalInfo: "brass", Version: 1, CategoryId: categoryId, Sku: "LMP-1"}
+	assert.True(t, proto.Equal(expected, created), "created %v", created)
+
+	t.Run("update keeps sku and category given", func(t *testing.T) {
+		updated, err := client.UpdateProduct(ctx, &productpb.UpdateProductRequest{Id: created.Id, Name: "lamp", AdditionalInfo: "copper", CategoryId: categoryId, Sku: "LMP-1", Version: 1})
+		require.NoError(t, err)
+		expected := &productpb.Product{Id: created.Id, Name: "lamp", AdditionalInfo: "copper", Version: 2, CategoryId: categoryId, Sku: "LMP-1"}
+		assert.True(t, proto.Equal(expected, updated), "updated %v", updated)
+		assert.Equal(t, domain.Product{Id: created.Id, Name: "lamp", AdditionalInfo: "copper", Version: 2, CategoryId: categoryId, Sku: "LMP-1"}, repo.Products()[0])
+	})
+
+	t.Run("update sets sku", func(t *testing.T) {
+		_, err := client.UpdateProduct(ctx, &productpb.UpdateProductRequest{Id: created.Id, Name: "lamp", AdditionalInfo: "copper", CategoryId: categoryId, Sku: "LMP-2"})
+		require.NoError(t, err)
+		product, err := client.GetProduct(ctx, &productpb.GetProductRequest{Id: created.Id})
+		require.NoError(t, err)
+		assert.Equal(t, "LMP-2", product.Sku)
+		assert.Equal(t, categoryId, product.CategoryId)
+	})
+
+	t.Run("rejected updates", func(t *testing.T) {
+		_, err := client.UpdateProduct(ctx, &productpb.UpdateProductRequest{Id: created.Id, Name: "lamp", AdditionalInfo: "tin", Version: 1})
+		assert.Equal(t, codes.Aborted, status.Code(err), "stale version")
+		_, err = client.UpdateProduct(ctx, &productpb.UpdateProductRequest{Id: created.Id, Name: "lamp", AdditionalInfo: "tin", CategoryId: 42})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "unknown category")
+		_, err = client.UpdateProduct(ctx, &productpb.UpdateProductRequest{Id: created.Id, Name: "lamp", AdditionalInfo: "tin", Sku: "not a sku!"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "invalid sku")
+		_, err = client.CreateProduct(ctx, &productpb.CreateProductRequest{Name: "desk", AdditionalInfo: "oak", Sku: "LMP-2"})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err), "duplicate sku")
+		assert.Equal(t, "copper", repo.Products()[0].AdditionalInfo)
+	})
+}
+
+func TestProductServiceVersionRequired(t *testing.T) {
+	ctx := context.Background()
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "lamp", AdditionalInfo: "brass", Version: 1})
+	client := newServiceClient(t, service.NewResourceService(repo, fakes.NewCache(), service.WithVersionRequired()))
+
+	_, err := client.UpdateProduct(ctx, &productpb.UpdateProductRequest{Id: 1, Name: "lamp", AdditionalInfo: "copper"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, "brass", repo.Products()[0].AdditionalInfo)
+
+	updated, err := client.UpdateProduct(ctx, &productpb.UpdateProductRequest{Id: 1, Name: "lamp", AdditionalInfo: "copper", Version: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+}
+
+func TestProductServiceErrors(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	tests := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"empty name", func() error {
+			_, err := client.CreateProduct(ctx, &productpb.CreateProductRequest{AdditionalInfo: "brass"})
+			return err
+		}, codes.InvalidArgument},
+		{"negative id", func() error {
+			_, err := client.GetProduct(ctx, &productpb.GetProductRequest{Id: -1})
+			return err
+		}, codes.InvalidArgument},
+		{"negative limit", func() error {
+			_, err := client.ListProducts(ctx, &productpb.ListProductsRequest{Limit: -1})
+			return err
+		}, codes.InvalidArgument},
+		{"empty sort field", func() error {
+			_, err := client.ListProducts(ctx, &productpb.ListProductsRequest{Sort: "name,"})
+			return err
+		}, codes.InvalidArgument},
+		{"unknown sort field", func() error {
+			_, err := client.ListProducts(ctx, &productpb.ListProductsRequest{Limit: 10, Sort: "price"})
+			return err
+		}, codes.InvalidArgument},
+		{"update missing", func() error {
+			_, err := client.UpdateProduct(ctx, &productpb.UpdateProductRequest{Id: 42, Name: "lamp", AdditionalInfo: "brass"})
+			return err
+		}, codes.NotFound},
+		{"delete missing", func() error {
+			_, err := client.DeleteProduct(ctx, &productpb.DeleteProductRequest{Id: 42})
+			return err
+		}, codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.code, status.Code(tt.call()))
+		})
+	}
+}
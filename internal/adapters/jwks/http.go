@@ -0,0 +1,190 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// minRefreshInterval limits refetches triggered by tokens with unknown key
+// ids, so that made up ids do not hammer the issuer, as well as retries
+// after a failed fetch
+const minRefreshInterval = 30 * time.Second
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// HTTPKeySet serves keys of a JSON Web Key Set published at url. Keys are
+// refetched every refreshInterval, and sooner when a token refers to a key
+// not seen yet, as issuers publish new keys before they start using them.
+// Keys fetched last keep being used while the set can not be fetched
+type HTTPKeySet struct {
+	httpClient      *http.Client
+	url             string
+	refreshInterval time.Duration
+	now             func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	// last attempt to fetch keys and its error, if it failed
+	triedAt time.Time
+	err     error
+}
+
+func NewHTTPKeySet(httpClient *http.Client, url string, refreshInterval time.Duration) *HTTPKeySet {
+	return &HTTPKeySet{
+		httpClient:      httpClient,
+		url:             url,
+		refreshInterval: refreshInterval,
+		now:             time.Now,
+	}
+}
+
+func (s *HTTPKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	_, ok := s.keys[kid]
+	if (ok && now.Sub(s.fetchedAt) < s.refreshInterval) || now.Sub(s.triedAt) < minRefreshInterval {
+		return s.lookup(kid)
+	}
+	s.triedAt = now
+	// keys are shared by all requests, a canceled one must not fail others
+	keys, err := s.fetch(context.WithoutCancel(ctx))
+	if err != nil {
+		s.err = err
+	} else {
+		s.keys, s.fetchedAt, s.err = keys, now, nil
+	}
+	return s.lookup(kid)
+}
+
+// lookup returns key fetched last, even if refreshing it failed since
+func (s *HTTPKeySet) lookup(kid string) (crypto.PublicKey, error) {
+	key, ok := s.keys[kid]
+	if ok {
+		return key, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return nil, fmt.Errorf("%w: no signing key with id %q", domain.ErrNotFound, kid)
+}
+
+func (s *HTTPKeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to build request: %s", domain.ErrKeySet, err.Error())
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: failed to fetch key set: %s", domain.ErrKeySet, domain.ErrUnavailable, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: key set responded with status %d", domain.ErrKeySet, resp.StatusCode)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode key set: %s", domain.ErrKeySet, err.Error())
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q: %s", domain.ErrKeySet, jwk.Kid, err.Error())
+		}
+		// keys of types or curves tokens can not be verified with are left out
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
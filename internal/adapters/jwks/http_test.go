@@ -0,0 +1,78 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func TestHTTPKeySet(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keys := []jsonWebKey{
+		{Kty: "RSA", Kid: "rsa", Use: "sig", N: encodeInt(rsaKey.N), E: encodeInt(big.NewInt(int64(rsaKey.E)))},
+		{Kty: "EC", Kid: "ec", Crv: "P-256", X: encodeInt(ecKey.X), Y: encodeInt(ecKey.Y)},
+		{Kty: "RSA", Kid: "enc", Use: "enc", N: encodeInt(rsaKey.N), E: encodeInt(big.NewInt(int64(rsaKey.E)))},
+		{Kty: "oct", Kid: "secret"},
+	}
+	var fetches atomic.Int32
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.WriteHeader(int(status.Load()))
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	defer server.Close()
+	now := time.Unix(1700000000, 0)
+	set := NewHTTPKeySet(server.Client(), server.URL, time.Hour)
+	set.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	key, err := set.Key(ctx, "rsa")
+	require.NoError(t, err)
+	assert.True(t, rsaKey.PublicKey.Equal(key))
+	key, err = set.Key(ctx, "ec")
+	require.NoError(t, err)
+	assert.True(t, ecKey.PublicKey.Equal(key))
+	for _, kid := range []string{"enc", "secret", "unknown"} {
+		_, err = set.Key(ctx, kid)
+		assert.ErrorIs(t, err, domain.ErrNotFound, kid)
+	}
+	assert.EqualValues(t, 1, fetches.Load(), "unknown keys are not refetched right away")
+
+	now = now.Add(time.Minute)
+	keys = append(keys, jsonWebKey{Kty: "RSA", Kid: "rotated", N: encodeInt(rsaKey.N), E: encodeInt(big.NewInt(int64(rsaKey.E)))})
+	_, err = set.Key(ctx, "rotated")
+	require.NoError(t, err, "unknown key is looked up in a new set")
+	assert.EqualValues(t, 2, fetches.Load())
+
+	now = now.Add(2 * time.Hour)
+	status.Store(http.StatusBadGateway)
+	_, err = set.Key(ctx, "rsa")
+	require.NoError(t, err, "keys fetched last are used while set is unavailable")
+	assert.EqualValues(t, 3, fetches.Load())
+	_, err = set.Key(ctx, "other")
+	assert.ErrorIs(t, err, domain.ErrKeySet)
+}
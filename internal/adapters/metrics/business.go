@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// BusinessMetrics keeps domain metrics in its own registry, so they can be
+// scraped separately from http/runtime metrics
+type BusinessMetrics struct {
+	registry        *prometheus.Registry
+	productsCreated prometheus.Counter
+	productReads    *prometheus.CounterVec
+	deleteAll       prometheus.Counter
+}
+
+// NewBusinessMetrics registers domain metrics. Total products gauge is
+// evaluated on scrape; if counting fails, last known value is reported
+func NewBusinessMetrics(repo ports.Repository) *BusinessMetrics {
+	m := &BusinessMetrics{
+		registry: prometheus.NewRegistry(),
+		productsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "products",
+			Name:      "created_total",
+			Help:      "Number of products created via API, ingestion or sync.",
+		}),
+		productReads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "products",
+			Name:      "reads_total",
+			Help:      "Number of single product reads by cache outcome.",
+		}, []string{"cache"}),
+		deleteAll: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "products",
+			Name:      "delete_all_invocations_total",
+			Help:      "Number of times the whole catalog was deleted.",
+		}),
+	}
+
+	var lastTotal atomic.Int64
+	total := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "products",
+		Name:      "total",
+		Help:      "Number of products currently stored.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if count, err := repo.CountProducts(ctx, domain.ProductFilter{}); err == nil {
+			lastTotal.Store(count)
+		}
+		return float64(lastTotal.Load())
+	})
+
+	m.registry.MustRegister(m.productsCreated, m.productReads, m.deleteAll, total)
+	// pre-create both label values, so hit ratio is defined from the start
+	m.productReads.WithLabelValues("hit")
+	m.productReads.WithLabelValues("miss")
+	return m
+}
+
+func (m *BusinessMetrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+func (m *BusinessMetrics) ProductsCreated(n int) {
+	m.productsCreated.Add(float64(n))
+}
+
+func (m *BusinessMetrics) ProductRead(cacheHit bool) {
+	if cacheHit {
+		m.productReads.WithLabelValues("hit").Inc()
+		return
+	}
+	m.productReads.WithLabelValues("miss").Inc()
+}
+
+func (m *BusinessMetrics) DeleteAllInvoked() {
+	m.deleteAll.Inc()
+}
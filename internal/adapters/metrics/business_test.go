@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// countingRepository implements only what the gauge needs
+type countingRepository struct {
+	ports.Repository
+	count int64
+	err   error
+}
+
+func (r *countingRepository) CountProducts(ctx context.Context, filter domain.ProductFilter) (int64, error) {
+	return r.count, r.err
+}
+
+func TestBusinessMetrics(t *testing.T) {
+	repo := &countingRepository{count: 5}
+	m := NewBusinessMetrics(repo)
+
+	m.ProductsCreated(1)
+	m.ProductsCreated(2)
+	m.ProductRead(true)
+	m.ProductRead(true)
+	m.ProductRead(false)
+	m.DeleteAllInvoked()
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.productsCreated))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.productReads.WithLabelValues("hit")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.productReads.WithLabelValues("miss")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.deleteAll))
+
+	count, err := testutil.GatherAndCount(m.Registry(), "products_total")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestBusinessMetricsTotalKeepsLastValueOnError(t *testing.T) {
+	repo := &countingRepository{count: 5}
+	m := NewBusinessMetrics(repo)
+
+	families, err := m.Registry().Gather()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(5), gaugeValue(families, "products_total"))
+
+	repo.err = errors.New("db is down")
+	families, err = m.Registry().Gather()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(5), gaugeValue(families, "products_total"))
+}
+
+func gaugeValue(families []*dto.MetricFamily, name string) float64 {
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	return -1
+}
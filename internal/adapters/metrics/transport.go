@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// TransportMetrics keeps http, cache, database and runtime metrics in a
+// registry of their own, business metrics are scraped separately
+type TransportMetrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        *prometheus.GaugeVec
+	cacheLookups    *prometheus.CounterVec
+	queries         *prometheus.CounterVec
+}
+
+func NewTransportMetrics() *TransportMetrics {
+	m := &TransportMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "http",
+			Name:      "requests_total",
+			Help:      "Number of served requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time to serve requests by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "http",
+			Name:      "requests_in_flight",
+			Help:      "Number of requests being served by route.",
+		}, []string{"route"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cache",
+			Name:      "lookups_total",
+			Help:      "Number of product cache lookups by result: hit, miss or error.",
+		}, []string{"result"}),
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "db",
+			Name:      "queries_total",
+			Help:      "Number of database operations by repository method and outcome: ok, not_found or error.",
+		}, []string{"operation", "outcome"}),
+	}
+	m.registry.MustRegister(m.requests, m.requestDuration, m.inFlight, m.cacheLookups, m.queries,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	// pre-create cache results, so hit ratio is defined from the start
+	for _, result := range []string{"hit", "miss", "error"} {
+		m.cacheLookups.WithLabelValues(result)
+	}
+	return m
+}
+
+func (m *TransportMetrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+func (m *TransportMetrics) RequestStarted(route string, method string) func(status int) {
+	started := time.Now()
+	inFlight := m.inFlight.WithLabelValues(route)
+	inFlight.Inc()
+	return func(status int) {
+		inFlight.Dec()
+		m.requestDuration.WithLabelValues(route, method).Observe(time.Since(started).Seconds())
+		m.requests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	}
+}
+
+func (m *TransportMetrics) CacheLookup(hit bool, err error) {
+	switch {
+	case err != nil:
+		m.cacheLookups.WithLabelValues("error").Inc()
+	case hit:
+		m.cacheLookups.WithLabelValues("hit").Inc()
+	default:
+		m.cacheLookups.WithLabelValues("miss").Inc()
+	}
+}
+
+func (m *TransportMetrics) QueryDone(operation string, err error) {
+	outcome := "ok"
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		outcome = "not_found"
+	case err != nil:
+		outcome = "error"
+	}
+	m.queries.WithLabelValues(operation, outcome).Inc()
+}
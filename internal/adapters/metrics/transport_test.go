@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func TestTransportMetrics(t *testing.T) {
+	m := NewTransportMetrics()
+
+	done := m.RequestStarted("/product/{id}", http.MethodGet)
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.inFlight.WithLabelValues("/product/{id}")))
+	done(http.StatusNotFound)
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.inFlight.WithLabelValues("/product/{id}")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues("/product/{id}", http.MethodGet, "404")))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.requestDuration))
+
+	m.CacheLookup(true, nil)
+	m.CacheLookup(false, nil)
+	m.CacheLookup(false, errors.New("connection refused"))
+	m.CacheLookup(true, nil)
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.cacheLookups.WithLabelValues("hit")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cacheLookups.WithLabelValues("miss")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cacheLookups.WithLabelValues("error")))
+
+	m.QueryDone("get_product", nil)
+	m.QueryDone("get_product", fmt.Errorf("%w: failed to find product 1 in DB", domain.ErrNotFound))
+	m.QueryDone("get_product", domain.ErrInternalDb)
+	for _, outcome := range []string{"ok", "not_found", "error"} {
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.queries.WithLabelValues("get_product", outcome)), outcome)
+	}
+
+	count, err := testutil.GatherAndCount(m.Registry(), "go_goroutines")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count, "runtime metrics are registered")
+}
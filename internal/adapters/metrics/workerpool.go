@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+var (
+	poolWorkersDesc = prometheus.NewDesc("worker_pool_workers", "Number of workers running side effects.", nil, nil)
+	poolQueuedDesc  = prometheus.NewDesc("worker_pool_queue_depth", "Number of tasks waiting for a worker.", nil, nil)
+	poolSizeDesc    = prometheus.NewDesc("worker_pool_queue_capacity", "Number of tasks that may wait before submitters are held back.", nil, nil)
+	poolBusyDesc    = prometheus.NewDesc("worker_pool_busy_workers", "Number of workers running a task.", nil, nil)
+	poolTasksDesc   = prometheus.NewDesc("worker_pool_tasks_total", "Number of tasks by outcome, rejected ones were never run.", []string{"outcome"}, nil)
+)
+
+// WorkerPoolCollector reports worker pool stats taken on scrape
+type WorkerPoolCollector struct {
+	pool ports.WorkerPool
+}
+
+func NewWorkerPoolCollector(pool ports.WorkerPool) *WorkerPoolCollector {
+	return &WorkerPoolCollector{pool: pool}
+}
+
+func (c *WorkerPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolWorkersDesc
+	ch <- poolQueuedDesc
+	ch <- poolSizeDesc
+	ch <- poolBusyDesc
+	ch <- poolTasksDesc
+}
+
+func (c *WorkerPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.pool.Stats()
+	ch <- prometheus.MustNewConstMetric(poolWorkersDesc, prometheus.GaugeValue, float64(stats.Workers))
+	ch <- prometheus.MustNewConstMetric(poolQueuedDesc, prometheus.GaugeValue, float64(stats.Queued))
+	ch <- prometheus.MustNewConstMetric(poolSizeDesc, prometheus.GaugeValue, float64(stats.QueueSize))
+	ch <- prometheus.MustNewConstMetric(poolBusyDesc, prometheus.GaugeValue, float64(stats.Busy))
+	ch <- prometheus.MustNewConstMetric(poolTasksDesc, prometheus.CounterValue, float64(stats.Succeeded), "succeeded")
+	ch <- prometheus.MustNewConstMetric(poolTasksDesc, prometheus.CounterValue, float64(stats.Failed), "failed")
+	ch <- prometheus.MustNewConstMetric(poolTasksDesc, prometheus.CounterValue, float64(stats.Rejected), "rejected")
+}
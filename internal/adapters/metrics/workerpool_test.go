@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+type staticPool struct {
+	ports.WorkerPool
+	stats domain.WorkerPoolStats
+}
+
+func (p staticPool) Stats() domain.WorkerPoolStats {
+	return p.stats
+}
+
+func TestWorkerPoolCollector(t *testing.T) {
+	collector := NewWorkerPoolCollector(staticPool{stats: domain.WorkerPoolStats{
+		Workers: 4, Queued: 7, QueueSize: 400, Busy: 3, Succeeded: 10, Failed: 2, Rejected: 1,
+	}})
+
+	expected := `
+# HELP worker_pool_queue_depth Number of tasks waiting for a worker.
+# TYPE worker_pool_queue_depth gauge
+worker_pool_queue_depth 7
+# HELP worker_pool_tasks_total Number of tasks by outcome, rejected ones were never run.
+# TYPE worker_pool_tasks_total counter
+worker_pool_tasks_total{outcome="failed"} 2
+worker_pool_tasks_total{outcome="rejected"} 1
+worker_pool_tasks_total{outcome="succeeded"} 10
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "worker_pool_queue_depth", "worker_pool_tasks_total"))
+	assert.Equal(t, 7, testutil.CollectAndCount(collector))
+}
@@ -0,0 +1,57 @@
+package objectstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// S3Store keeps objects in a bucket of S3 or any storage speaking its API,
+// such as MinIO
+type S3Store struct {
+	client  *minio.Client
+	bucket  string
+	baseURL string
+}
+
+// NewS3Store connects to bucket at endpoint, given as host[:port]. Objects
+// are served from publicURL if given, e.g. by CDN in front of the bucket,
+// otherwise from the endpoint itself
+func NewS3Store(endpoint string, region string, bucket string, accessKey string, secretKey string, secure bool, publicURL string) (*S3Store, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: secure,
+		Region: region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	baseURL := strings.TrimRight(publicURL, "/")
+	if baseURL == "" {
+		baseURL = client.EndpointURL().String() + "/" + bucket
+	}
+	return &S3Store{client: client, bucket: bucket, baseURL: baseURL}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) (string, error) {
+	_, err := s.client.PutObject(ctx, s.bucket, key, body, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to put object %s: %w", domain.ErrObjectStore, key, err)
+	}
+	return s.baseURL + "/" + (&url.URL{Path: key}).EscapedPath(), nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("%w: failed to delete object %s: %w", domain.ErrObjectStore, key, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package objectstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type recordedRequest struct {
+	method      string
+	path        string
+	contentType string
+	body        string
+}
+
+func newFakeS3(t *testing.T, status int) (*httptest.Server, *[]recordedRequest) {
+	requests := &[]recordedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		*requests = append(*requests, recordedRequest{method: r.Method, path: r.URL.Path, contentType: r.Header.Get("Content-Type"), body: string(data)})
+		if status >= http.StatusBadRequest {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(status)
+			w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+			return
+		}
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func newStore(t *testing.T, server *httptest.Server, publicURL string) *S3Store {
+	store, err := NewS3Store(strings.TrimPrefix(server.URL, "http://"), "us-east-1", "images", "key", "secret", false, publicURL)
+	require.NoError(t, err)
+	return store
+}
+
+func TestPut(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("streams object to bucket", func(t *testing.T) {
+		server, requests := newFakeS3(t, http.StatusOK)
+		store := newStore(t, server, "")
+
+		url, err := store.Put(ctx, "products/1/a.png", strings.NewReader("png"), 3, "image/png")
+		require.NoError(t, err)
+		assert.Equal(t, server.URL+"/images/products/1/a.png", url)
+		require.Len(t, *requests, 1)
+		put := (*requests)[0]
+		assert.Equal(t, http.MethodPut, put.method)
+		assert.Equal(t, "/images/products/1/a.png", put.path)
+		assert.Equal(t, "image/png", put.contentType)
+		// over plain http payload is sent in signed chunks
+		assert.Contains(t, put.body, "\r\npng\r\n")
+	})
+
+	t.Run("url is built from public url", func(t *testing.T) {
+		server, _ := newFakeS3(t, http.StatusOK)
+		store := newStore(t, server, "https://cdn.example.com/")
+
+		url, err := store.Put(ctx, "products/1/a.png", strings.NewReader("png"), 3, "image/png")
+		require.NoError(t, err)
+		assert.Equal(t, "https://cdn.example.com/products/1/a.png", url)
+	})
+
+	t.Run("failure is an object store error", func(t *testing.T) {
+		server, _ := newFakeS3(t, http.StatusForbidden)
+		store := newStore(t, server, "")
+
+		_, err := store.Put(ctx, "products/1/a.png", strings.NewReader("png"), 3, "image/png")
+		assert.ErrorIs(t, err, domain.ErrObjectStore)
+	})
+}
+
+func TestDelete(t *testing.T) {
+	server, requests := newFakeS3(t, http.StatusNoContent)
+	store := newStore(t, server, "")
+
+	require.NoError(t, store.Delete(context.Background(), "products/1/a.png"))
+	require.Len(t, *requests, 1)
+	assert.Equal(t, http.MethodDelete, (*requests)[0].method)
+	assert.Equal(t, "/images/products/1/a.png", (*requests)[0].path)
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func (r *PostgresRepository) CreateAPIKey(ctx context.Context, key domain.APIKey, hash string) (_ int64, err error) {
+	defer r.observe("create_api_key", &err)
+	var id int64
+	err = r.db.QueryRowContext(ctx,
+		`INSERT INTO api_keys (principal, role, key_hash) VALUES ($1, $2, $3) RETURNING id`,
+		key.Principal, key.Role, hash).Scan(&id)
+	if err != nil {
+		return 0, dbError(err, "failed to create API key")
+	}
+	return id, nil
+}
+
+func (r *PostgresRepository) RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	defer r.observe("revoke_api_key", &err)
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return dbError(err, "failed to revoke API key %d", id)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return dbError(err, "failed to revoke API key %d", id)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: failed to find API key %d in DB", domain.ErrNotFound, id)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) LookupAPIKey(ctx context.Context, hash string) (_ domain.APIKey, err error) {
+	defer r.observe("lookup_api_key", &err)
+	var key domain.APIKey
+	err = r.db.QueryRowContext(ctx,
+		`SELECT principal, role FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`, hash).
+		Scan(&key.Principal, &key.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return key, fmt.Errorf("%w: unknown or revoked API key", domain.ErrNotFound)
+		}
+		return key, dbError(err, "failed to look up API key")
+	}
+	return key, nil
+}
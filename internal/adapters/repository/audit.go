@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// WithAuditTrail makes every write also record a change into product_audit
+// table, within the same transaction. Changes are attributed to actor found
+// in context
+func WithAuditTrail() Option {
+	return func(r *PostgresRepository) {
+		r.audit = true
+	}
+}
+
+// auditImage is nil for a missing side of a change, so that it is stored as
+// NULL rather than JSON null
+func auditImage(p *domain.Product) ([]byte, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+func (r *PostgresRepository) writeAudit(ctx context.Context, tx *sql.Tx, action domain.AuditAction, productId int64, before *domain.Product, after *domain.Product) error {
+	if !r.audit {
+		return nil
+	}
+	beforeImage, err := auditImage(before)
+	if err != nil {
+		return dbError(err, "failed to marshal audit entry")
+	}
+	afterImage, err := auditImage(after)
+	if err != nil {
+		return dbError(err, "failed to marshal audit entry")
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO product_audit (product_id, action, actor, before, after) VALUES ($1, $2, NULLIF($3, ''), $4, $5)",
+		productId, action, domain.ActorFromContext(ctx), beforeImage, afterImage)
+	if err != nil {
+		return dbError(err, "failed to write audit entry")
+	}
+	return nil
+}
+
+// writeAuditAll records deletion of every product, before products table is
+// truncated
+func (r *PostgresRepository) writeAuditAll(ctx context.Context, tx *sql.Tx) error {
+	if !r.audit {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO product_audit (product_id, action, actor, before)
+		SELECT id, $1, NULLIF($2, ''), json_build_object('id', id, 'name', name, 'additionalInfo', additional_info, 'version', version, 'categoryId', category_id, 'sku', sku, 'stock', stock, 'tags', tags)
+		FROM products`,
+		domain.AuditDelete, domain.ActorFromContext(ctx))
+	if err != nil {
+		return dbError(err, "failed to write audit entries")
+	}
+	return nil
+}
+
+// ProductAudit returns changes of product, newest first
+func (r *PostgresRepository) ProductAudit(ctx context.Context, productId int64, before int64, limit int64) (_ []domain.AuditEntry, err error) {
+	defer r.observe("product_audit", &err)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, product_id, action, COALESCE(actor, ''), created_at, before, after FROM product_audit
+		WHERE product_id = $1 AND ($2::bigint = 0 OR id < $2)
+		ORDER BY id DESC LIMIT $3`,
+		productId, before, limit)
+	if err != nil {
+		return nil, dbError(err, "failed to get audit trail of product %d", productId)
+	}
+	defer rows.Close()
+	entries := make([]domain.AuditEntry, 0)
+	for rows.Next() {
+		var entry domain.AuditEntry
+		var beforeImage, afterImage []byte
+		if err := rows.Scan(&entry.Id, &entry.ProductId, &entry.Action, &entry.Actor, &entry.At, &beforeImage, &afterImage); err != nil {
+			return nil, dbError(err, "failed to convert row into go type")
+		}
+		if entry.Before, err = parseAuditImage(beforeImage); err != nil {
+			return nil, dbError(err, "failed to unmarshal audit entry %d", entry.Id)
+		}
+		if entry.After, err = parseAuditImage(afterImage); err != nil {
+			return nil, dbError(err, "failed to unmarshal audit entry %d", entry.Id)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error while iterating over rows")
+	}
+	return entries, nil
+}
+
+func parseAuditImage(image []byte) (*domain.Product, error) {
+	if image == nil {
+		return nil, nil
+	}
+	var product domain.Product
+	if err := json.Unmarshal(image, &product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
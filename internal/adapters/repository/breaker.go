@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+var errBreakerOpen = fmt.Errorf("%w: %w: circuit breaker is open", domain.ErrInternalDb, domain.ErrUnavailable)
+
+// CircuitBreaker decorates repository, so that once database is found
+// unreachable calls fail fast instead of waiting for connection timeouts.
+// Breaker opens after threshold consecutive connectivity failures, other
+// errors do not count. After cooldown calls are let through again, the first
+// connectivity failure opens breaker for another cooldown. Calls whose
+// context is done do not count, caller giving up says nothing of database
+type CircuitBreaker struct {
+	repo      ports.Repository
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	// set by health checks, see SetDown
+	down bool
+}
+
+func NewCircuitBreaker(repo ports.Repository, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		repo:      repo,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+func (b *CircuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open()
+}
+
+func (b *CircuitBreaker) open() bool {
+	return b.down || b.failures >= b.threshold && b.now().Sub(b.openedAt) < b.cooldown
+}
+
+// SetDown keeps breaker open while down is true, for health checks finding
+// database unreachable before calls do. Clearing it closes breaker right
+// away instead of after cooldown
+func (b *CircuitBreaker) SetDown(down bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.down = down
+	if !down {
+		b.failures = 0
+	}
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !errors.Is(err, domain.ErrUnavailable) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
+
+func guard[T any](ctx context.Context, b *CircuitBreaker, call func() (T, error)) (T, error) {
+	if b.Open() {
+		var zero T
+		return zero, errBreakerOpen
+	}
+	res, err := call()
+	if ctx.Err() == nil {
+		b.record(err)
+	}
+	return res, err
+}
+
+func (b *CircuitBreaker) GetProduct(ctx context.Context, id int64) (*domain.Product, error) {
+	return guard(ctx, b, func() (*domain.Product, error) { return b.repo.GetProduct(ctx, id) })
+}
+
+func (b *CircuitBreaker) GetProductBySku(ctx context.Context, sku string) (*domain.Product, error) {
+	return guard(ctx, b, func() (*domain.Product, error) { return b.repo.GetProductBySku(ctx, sku) })
+}
+
+func (b *CircuitBreaker) GetAllProducts(ctx context.Context) ([]domain.Product, error) {
+	return guard(ctx, b, func() ([]domain.Product, error) { return b.repo.GetAllProducts(ctx) })
+}
+
+func (b *CircuitBreaker) EachProduct(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, fn func(product domain.Product) error) error {
+	_, err := guard(ctx, b, func() (struct{}, error) { return struct{}{}, b.repo.EachProduct(ctx, filter, sort, fn) })
+	return err
+}
+
+func (b *CircuitBreaker) GetProductsPaged(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, limit int64, offset int64) ([]domain.Product, error) {
+	return guard(ctx, b, func() ([]domain.Product, error) { return b.repo.GetProductsPaged(ctx, filter, sort, limit, offset) })
+}
+
+func (b *CircuitBreaker) GetProductsAfter(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, after *domain.Product, limit int64) ([]domain.Product, error) {
+	return guard(ctx, b, func() ([]domain.Product, error) { return b.repo.GetProductsAfter(ctx, filter, sort, after, limit) })
+}
+
+func (b *CircuitBreaker) ProductIdRange(ctx context.Context) (int64, int64, error) {
+	bounds, err := guard(ctx, b, func() ([2]int64, error) {
+		first, last, err := b.repo.ProductIdRange(ctx)
+		return [2]int64{first, last}, err
+	})
+	return bounds[0], bounds[1], err
+}
+
+func (b *CircuitBreaker) GetProductsInRange(ctx context.Context, fromId int64, toId int64) ([]domain.Product, error) {
+	return guard(ctx, b, func() ([]domain.Product, error) { return b.repo.GetProductsInRange(ctx, fromId, toId) })
+}
+
+func (b *CircuitBreaker) CountProducts(ctx context.Context, filter domain.ProductFilter) (int64, error) {
+	return guard(ctx, b, func() (int64, error) { return b.repo.CountProducts(ctx, filter) })
+}
+
+func (b *CircuitBreaker) SuggestProductNames(ctx context.Context, prefix string, limit int64) ([]string, error) {
+	return guard(ctx, b, func() ([]string, error) { return b.repo.SuggestProductNames(ctx, prefix, limit) })
+}
+
+func (b *CircuitBreaker) FuzzySearchProducts(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error) {
+	return guard(ctx, b, func() (*domain.SearchResult, error) { return b.repo.FuzzySearchProducts(ctx, query) })
+}
+
+func (b *CircuitBreaker) SearchProducts(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error) {
+	return guard(ctx, b, func() (*domain.SearchResult, error) { return b.repo.SearchProducts(ctx, query) })
+}
+
+func (b *CircuitBreaker) StoreProduct(ctx context.Context, product domain.NewProduct) (int64, error) {
+	return guard(ctx, b, func() (int64, error) { return b.repo.StoreProduct(ctx, product) })
+}
+
+func (b *CircuitBreaker) StoreProducts(ctx context.Context, products []domain.NewProduct) ([]int64, error) {
+	return guard(ctx, b, func() ([]int64, error) { return b.repo.StoreProducts(ctx, products) })
+}
+
+func (b *CircuitBreaker) UpsertProduct(ctx context.Context, product domain.Product) (bool, error) {
+	return guard(ctx, b, func() (bool, error) { return b.repo.UpsertProduct(ctx, product) })
+}
+
+func (b *CircuitBreaker) UpdateProductById(ctx context.Context, id int64, product domain.NewProduct) (*domain.Product, error) {
+	return guard(ctx, b, func() (*domain.Product, error) { return b.repo.UpdateProductById(ctx, id, product) })
+}
+
+func (b *CircuitBreaker) DeleteProductById(ctx context.Context, id int64) (*domain.Product, error) {
+	return guard(ctx, b, func() (*domain.Product, error) { return b.repo.DeleteProductById(ctx, id) })
+}
+
+func (b *CircuitBreaker) AddProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, error) {
+	return guard(ctx, b, func() (*domain.Product, error) { return b.repo.AddProductTags(ctx, id, tags) })
+}
+
+func (b *CircuitBreaker) RemoveProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, error) {
+	return guard(ctx, b, func() (*domain.Product, error) { return b.repo.RemoveProductTags(ctx, id, tags) })
+}
+
+func (b *CircuitBreaker) AdjustProductStock(ctx context.Context, id int64, delta int64) (*domain.Product, error) {
+	return guard(ctx, b, func() (*domain.Product, error) { return b.repo.AdjustProductStock(ctx, id, delta) })
+}
+
+func (b *CircuitBreaker) AddProductImage(ctx context.Context, id int64, image domain.ProductImage) (*domain.Product, error) {
+	return guard(ctx, b, func() (*domain.Product, error) { return b.repo.AddProductImage(ctx, id, image) })
+}
+
+func (b *CircuitBreaker) DeleteAllProducts(ctx context.Context) (int64, error) {
+	return guard(ctx, b, func() (int64, error) { return b.repo.DeleteAllProducts(ctx) })
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"})
+	var calls int
+	var failure error
+	repo.OnCall(func(method string) error {
+		calls++
+		if method == "GetProduct" {
+			return failure
+		}
+		return nil
+	})
+	now := time.Now()
+	breaker := NewCircuitBreaker(repo, 2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	// errors other than connectivity ones do not count
+	for range 3 {
+		_, err := breaker.GetProduct(ctx, 42)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	}
+	assert.False(t, breaker.Open())
+
+	failure = fmt.Errorf("%w: %w: connection refused", domain.ErrInternalDb, domain.ErrUnavailable)
+	for range 2 {
+		_, err := breaker.GetProduct(ctx, 1)
+		assert.ErrorIs(t, err, domain.ErrUnavailable)
+	}
+	require.True(t, breaker.Open())
+
+	calls = 0
+	_, err := breaker.GetAllProducts(ctx)
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.Zero(t, calls, "open breaker must not call repository")
+
+	// after cooldown a single failure opens breaker again
+	now = now.Add(time.Minute)
+	assert.False(t, breaker.Open())
+	_, err = breaker.GetProduct(ctx, 1)
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.Equal(t, 1, calls)
+	require.True(t, breaker.Open())
+
+	// and a success closes it
+	now = now.Add(time.Minute)
+	failure = nil
+	product, err := breaker.GetProduct(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "First", product.Name)
+	assert.False(t, breaker.Open())
+}
+
+func TestCircuitBreakerSetDown(t *testing.T) {
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"})
+	breaker := NewCircuitBreaker(repo, 1, time.Minute)
+
+	breaker.SetDown(true)
+	_, err := breaker.GetProduct(context.Background(), 1)
+	assert.ErrorIs(t, err, domain.ErrUnavailable, "breaker is open before any call fails")
+
+	// recovery closes breaker without waiting for cooldown
+	repo.FailWith("GetProduct", fmt.Errorf("%w: %w: connection refused", domain.ErrInternalDb, domain.ErrUnavailable))
+	breaker.SetDown(false)
+	_, err = breaker.GetProduct(context.Background(), 1)
+	require.ErrorIs(t, err, domain.ErrUnavailable)
+	require.True(t, breaker.Open())
+	breaker.SetDown(false)
+	assert.False(t, breaker.Open())
+}
+
+func TestCircuitBreakerIgnoresCallerDeadline(t *testing.T) {
+	repo := fakes.NewRepository()
+	repo.OnCall(func(string) error {
+		return fmt.Errorf("%w: %w: %w", domain.ErrInternalDb, domain.ErrUnavailable, context.DeadlineExceeded)
+	})
+	breaker := NewCircuitBreaker(repo, 1, time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	_, err := breaker.GetProduct(ctx, 1)
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.False(t, breaker.Open(), "failure caused by caller deadline must not count")
+
+	_, err = breaker.GetProduct(context.Background(), 1)
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.True(t, breaker.Open())
+}
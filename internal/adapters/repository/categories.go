@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func (r *PostgresRepository) ListCategories(ctx context.Context) (_ []domain.Category, err error) {
+	defer r.observe("list_categories", &err)
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name FROM categories ORDER BY id")
+	if err != nil {
+		return nil, dbError(err, "failed to list categories")
+	}
+	defer rows.Close()
+	categories := make([]domain.Category, 0)
+	for rows.Next() {
+		var category domain.Category
+		if err := rows.Scan(&category.Id, &category.Name); err != nil {
+			return nil, dbError(err, "failed to convert row into go type")
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error while iterating over rows")
+	}
+	return categories, nil
+}
+
+func (r *PostgresRepository) GetCategory(ctx context.Context, id int64) (_ *domain.Category, err error) {
+	defer r.observe("get_category", &err)
+	var category domain.Category
+	err = r.db.QueryRowContext(ctx, "SELECT id, name FROM categories WHERE id = $1", id).Scan(&category.Id, &category.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: failed to find category %d in DB", domain.ErrNotFound, id)
+		}
+		return nil, dbError(err, "failed to get category %d", id)
+	}
+	return &category, nil
+}
+
+func (r *PostgresRepository) StoreCategory(ctx context.Context, name string) (_ int64, err error) {
+	defer r.observe("store_category", &err)
+	var id int64
+	err = r.db.QueryRowContext(ctx, "INSERT INTO categories (name) VALUES ($1) RETURNING id", name).Scan(&id)
+	if err != nil {
+		return 0, dbError(err, "failed to store category")
+	}
+	return id, nil
+}
+
+func (r *PostgresRepository) UpdateCategory(ctx context.Context, category domain.Category) (err error) {
+	defer r.observe("update_category", &err)
+	result, err := r.db.ExecContext(ctx, "UPDATE categories SET name = $1 WHERE id = $2", category.Name, category.Id)
+	if err != nil {
+		return dbError(err, "failed to update category %d", category.Id)
+	}
+	return categoryAffected(result, category.Id)
+}
+
+// DeleteCategory relies on foreign key of products, so a product put in
+// category concurrently either makes delete fail or fails itself
+func (r *PostgresRepository) DeleteCategory(ctx context.Context, id int64) (err error) {
+	defer r.observe("delete_category", &err)
+	result, err := r.db.ExecContext(ctx, "DELETE FROM categories WHERE id = $1", id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("%w: category %d", domain.ErrCategoryInUse, id)
+		}
+		return dbError(err, "failed to delete category %d", id)
+	}
+	return categoryAffected(result, id)
+}
+
+func categoryAffected(result sql.Result, id int64) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return dbError(err, "failed to change category %d", id)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: failed to find category %d in DB", domain.ErrNotFound, id)
+	}
+	return nil
+}
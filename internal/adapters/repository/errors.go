@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/lib/pq"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// dbError wraps err into domain.ErrInternalDb. Connectivity failures also
+// match domain.ErrUnavailable, so they can be told apart from query bugs
+func dbError(err error, format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	if isConnectivityError(err) {
+		return fmt.Errorf("%w: %w: %s. %s", domain.ErrInternalDb, domain.ErrUnavailable, message, err.Error())
+	}
+	return fmt.Errorf("%w: %s. %s", domain.ErrInternalDb, message, err.Error())
+}
+
+// foreignKeyViolation is postgres error code of writes referring to rows that
+// do not exist, or deletes of rows still referred to
+const foreignKeyViolation = "23503"
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation
+}
+
+// uniqueViolation is postgres error code of writes duplicating a unique
+// column, sku being the only one products can collide on
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+// storeError is dbError of product writes, which fail with
+// domain.ErrUnknownCategory if product refers to a category that does not
+// exist and with domain.ErrDuplicateSku if another product has its sku
+func storeError(err error, format string, args ...interface{}) error {
+	switch {
+	case isForeignKeyViolation(err):
+		return fmt.Errorf("%w: %s. %s", domain.ErrUnknownCategory, fmt.Sprintf(format, args...), err.Error())
+	case isUniqueViolation(err):
+		return fmt.Errorf("%w: %s. %s", domain.ErrDuplicateSku, fmt.Sprintf(format, args...), err.Error())
+	}
+	return dbError(err, format, args...)
+}
+
+func isConnectivityError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03", // cannot_connect_now
+			"53300": // too_many_connections
+			return true
+		}
+		// class 08 - connection exception
+		return pqErr.Code.Class() == "08"
+	}
+	return false
+}
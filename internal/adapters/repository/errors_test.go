@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func TestDbErrorClassification(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		unavailable bool
+	}{
+		{name: "connection refused", err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, unavailable: true},
+		{name: "connection reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), unavailable: true},
+		{name: "admin shutdown", err: &pq.Error{Code: "57P01"}, unavailable: true},
+		{name: "connection exception class", err: &pq.Error{Code: "08006"}, unavailable: true},
+		{name: "syntax error", err: &pq.Error{Code: "42601"}, unavailable: false},
+		{name: "unique violation", err: &pq.Error{Code: "23505"}, unavailable: false},
+		{name: "other error", err: errors.New("sql: Scan error"), unavailable: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dbError(tt.err, "failed to get product %d", 1)
+			assert.ErrorIs(t, err, domain.ErrInternalDb)
+			assert.Equal(t, tt.unavailable, errors.Is(err, domain.ErrUnavailable))
+			assert.Contains(t, err.Error(), "failed to get product 1")
+		})
+	}
+}
+
+func TestStoreError(t *testing.T) {
+	err := storeError(&pq.Error{Code: "23503"}, "failed to store product")
+	assert.ErrorIs(t, err, domain.ErrUnknownCategory)
+	assert.NotErrorIs(t, err, domain.ErrInternalDb)
+
+	err = storeError(&pq.Error{Code: "23505"}, "failed to store product")
+	assert.ErrorIs(t, err, domain.ErrDuplicateSku)
+	assert.NotErrorIs(t, err, domain.ErrInternalDb)
+
+	err = storeError(&pq.Error{Code: "42601"}, "failed to store product")
+	assert.ErrorIs(t, err, domain.ErrInternalDb)
+	assert.NotErrorIs(t, err, domain.ErrUnknownCategory)
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// headlineOptions mark matches the way Elasticsearch highlights do. Names
+// are short, so they are highlighted whole
+const (
+	nameHeadlineOptions = "StartSel=<em>, StopSel=</em>, HighlightAll=true"
+	infoHeadlineOptions = "StartSel=<em>, StopSel=</em>, MaxFragments=3, MinWords=5, MaxWords=20"
+)
+
+// SearchProducts finds products matching query text in name or additional
+// info by full-text search over search_vector, best ranked first. Query text
+// takes web search syntax, e.g. "lamp -desk" or "\"desk lamp\"". Facets are
+// not supported
+func (r *PostgresRepository) SearchProducts(ctx context.Context, query domain.SearchQuery) (_ *domain.SearchResult, err error) {
+	defer r.observe("search_products", &err)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, `+imagesColumn+`,
+			ts_rank_cd(search_vector, q) AS score,
+			CASE WHEN to_tsvector('english', name) @@ q THEN ts_headline('english', name, q, $4) END,
+			CASE WHEN to_tsvector('english', additional_info) @@ q THEN ts_headline('english', additional_info, q, $5) END,
+			COUNT(*) OVER () AS total
+		FROM products, websearch_to_tsquery('english', $1) AS q
+		WHERE search_vector @@ q
+		ORDER BY score DESC, id LIMIT $2 OFFSET $3`,
+		query.Text, query.Limit, query.Offset, nameHeadlineOptions, infoHeadlineOptions)
+	if err != nil {
+		return nil, dbError(err, "failed to search products")
+	}
+	defer rows.Close()
+
+	result := &domain.SearchResult{Hits: []domain.SearchHit{}}
+	for rows.Next() {
+		var hit domain.SearchHit
+		var name, info sql.NullString
+		err := rows.Scan(&hit.Product.Id, &hit.Product.Name, &hit.Product.AdditionalInfo, &hit.Product.Version, &hit.Product.CategoryId, &hit.Product.Sku, &hit.Product.Stock, pq.Array(&hit.Product.Tags), (*images)(&hit.Product.Images), &hit.Score, &name, &info, &result.Total)
+		if err != nil {
+			return nil, dbError(err, "failed to convert row into go type")
+		}
+		hit.Highlights = make(map[string][]string)
+		if name.Valid {
+			hit.Highlights["name"] = []string{name.String}
+		}
+		if info.Valid {
+			hit.Highlights["additionalInfo"] = []string{info.String}
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error while iterating over rows")
+	}
+	if len(result.Hits) == 0 && query.Offset > 0 {
+		// window count is not available past the last match
+		err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products WHERE search_vector @@ websearch_to_tsquery('english', $1)", query.Text).Scan(&result.Total)
+		if err != nil {
+			return nil, dbError(err, "failed to count found products")
+		}
+	}
+	return result, nil
+}
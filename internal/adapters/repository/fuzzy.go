@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/lib/pq"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// defaultSimilarityThreshold is pg_trgm default, low enough for a typo or two
+// in short names
+const defaultSimilarityThreshold = 0.3
+
+// WithSimilarityThreshold sets minimal trigram similarity of names found by
+// fuzzy search, within [0, 1]
+func WithSimilarityThreshold(threshold float64) Option {
+	return func(r *PostgresRepository) {
+		r.similarityThreshold = threshold
+	}
+}
+
+// FuzzySearchProducts finds products with names similar to query text by
+// pg_trgm similarity, best matches first. Facets are not supported
+func (r *PostgresRepository) FuzzySearchProducts(ctx context.Context, query domain.SearchQuery) (_ *domain.SearchResult, err error) {
+	defer r.observe("fuzzy_search_products", &err)
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, dbError(err, "failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	// % operator can use trigram index, unlike comparing similarity()
+	// directly, but takes threshold from the setting only
+	_, err = tx.ExecContext(ctx, "SELECT set_config('pg_trgm.similarity_threshold', $1, true)",
+		strconv.FormatFloat(r.similarityThreshold, 'f', -1, 64))
+	if err != nil {
+		return nil, dbError(err, "failed to set similarity threshold")
+	}
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, `+imagesColumn+`, similarity(name, $1) AS score, COUNT(*) OVER () AS total
+		FROM products WHERE name % $1
+		ORDER BY score DESC, id LIMIT $2 OFFSET $3`,
+		query.Text, query.Limit, query.Offset)
+	if err != nil {
+		return nil, dbError(err, "failed to search products")
+	}
+	defer rows.Close()
+
+	result := &domain.SearchResult{Hits: []domain.SearchHit{}}
+	for rows.Next() {
+		var hit domain.SearchHit
+		err := rows.Scan(&hit.Product.Id, &hit.Product.Name, &hit.Product.AdditionalInfo, &hit.Product.Version, &hit.Product.CategoryId, &hit.Product.Sku, &hit.Product.Stock, pq.Array(&hit.Product.Tags), (*images)(&hit.Product.Images), &hit.Score, &result.Total)
+		if err != nil {
+			return nil, dbError(err, "failed to convert row into go type")
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error while iterating over rows")
+	}
+	if len(result.Hits) == 0 && query.Offset > 0 {
+		// window count is not available past the last match
+		err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM products WHERE name % $1", query.Text).Scan(&result.Total)
+		if err != nil {
+			return nil, dbError(err, "failed to count found products")
+		}
+	}
+	return result, nil
+}
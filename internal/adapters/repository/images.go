@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/lib/pq"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// imagesColumn aggregates images of products row into JSON array, NULL if
+// product has none. Scan it with images
+const imagesColumn = `(SELECT json_agg(json_build_object('id', i.id, 'key', i.object_key, 'url', i.url,
+	'contentType', i.content_type, 'size', i.size, 'createdAt', i.created_at) ORDER BY i.id)
+	FROM product_images i WHERE i.product_id = products.id)`
+
+type imageRow struct {
+	domain.ProductImage
+	Key string `json:"key"`
+}
+
+// images scans imagesColumn
+type images []domain.ProductImage
+
+func (i *images) Scan(src any) error {
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		*i = nil
+		return nil
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("unexpected images column type %T", src)
+	}
+	var rows []imageRow
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return fmt.Errorf("failed to decode images: %w", err)
+	}
+	*i = make(images, len(rows))
+	for n, row := range rows {
+		(*i)[n] = row.ProductImage
+		(*i)[n].Key = row.Key
+	}
+	return nil
+}
+
+// AddProductImage records image stored for product and bumps its version,
+// returning the product with the image
+func (r *PostgresRepository) AddProductImage(ctx context.Context, id int64, image domain.ProductImage) (_ *domain.Product, err error) {
+	defer r.observe("add_product_image", &err)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, dbError(err, "failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	var before domain.Product
+	err = tx.QueryRowContext(ctx, "SELECT id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, "+imagesColumn+" FROM products WHERE id = $1 FOR UPDATE", id).
+		Scan(&before.Id, &before.Name, &before.AdditionalInfo, &before.Version, &before.CategoryId, &before.Sku, &before.Stock, pq.Array(&before.Tags), (*images)(&before.Images))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: failed to find product %d in DB", domain.ErrNotFound, id)
+		}
+		return nil, dbError(err, "failed to get product %d", id)
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO product_images (product_id, object_key, url, content_type, size) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		id, image.Key, image.URL, image.ContentType, image.Size).Scan(&image.Id, &image.CreatedAt)
+	if err != nil {
+		return nil, dbError(err, "failed to store image of product %d", id)
+	}
+	after := before
+	err = tx.QueryRowContext(ctx, "UPDATE products SET version = version + 1, updated_at = now() WHERE id = $1 RETURNING version", id).
+		Scan(&after.Version)
+	if err != nil {
+		return nil, dbError(err, "failed to update product %d", id)
+	}
+	after.Images = append(slices.Clip(before.Images), image)
+	if err := r.writeOutbox(tx, domain.EventProductUpdated, opUpdate, id, &before, &after); err != nil {
+		return nil, err
+	}
+	if err := r.writeAudit(ctx, tx, domain.AuditUpdate, id, &before, &after); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, dbError(err, "failed to commit transaction")
+	}
+	return &after, nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// Debezium operation codes
+const (
+	opCreate   = "c"
+	opUpdate   = "u"
+	opDelete   = "d"
+	opTruncate = "t"
+)
+
+// productRow mirrors products table columns, as CDC consumers expect row
+// images keyed by column names rather than API field names
+type productRow struct {
+	Id             int64    `json:"id"`
+	Name           string   `json:"name"`
+	AdditionalInfo string   `json:"additional_info"`
+	Version        int64    `json:"version"`
+	CategoryId     int64    `json:"category_id,omitempty"`
+	Sku            string   `json:"sku,omitempty"`
+	Stock          int64    `json:"stock"`
+	Tags           []string `json:"tags,omitempty"`
+}
+
+type changeSource struct {
+	Name  string `json:"name"`
+	Table string `json:"table"`
+	TsMs  int64  `json:"ts_ms"`
+}
+
+// changeEnvelope follows Debezium change event value layout, so pipelines
+// built for Debezium can consume outbox payloads as is
+type changeEnvelope struct {
+	Before *productRow  `json:"before"`
+	After  *productRow  `json:"after"`
+	Source changeSource `json:"source"`
+	Op     string       `json:"op"`
+	TsMs   int64        `json:"ts_ms"`
+}
+
+func toRow(p *domain.Product) *productRow {
+	if p == nil {
+		return nil
+	}
+	return &productRow{Id: p.Id, Name: p.Name, AdditionalInfo: p.AdditionalInfo, Version: p.Version, CategoryId: p.CategoryId, Sku: p.Sku, Stock: p.Stock, Tags: p.Tags}
+}
+
+// writeOutbox records change within the same transaction as the change itself.
+// Columns follow Debezium outbox event router conventions
+func (r *PostgresRepository) writeOutbox(tx *sql.Tx, eventType string, op string, aggregateId int64, before *domain.Product, after *domain.Product) error {
+	if !r.outbox {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	payload, err := json.Marshal(changeEnvelope{
+		Before: toRow(before),
+		After:  toRow(after),
+		Source: changeSource{Name: "simpler_go_service", Table: "products", TsMs: now},
+		Op:     op,
+		TsMs:   now,
+	})
+	if err != nil {
+		return dbError(err, "failed to marshal outbox payload")
+	}
+	_, err = tx.Exec(
+		"INSERT INTO outbox (id, aggregatetype, aggregateid, type, payload) VALUES ($1, $2, $3, $4, $5)",
+		uuid.NewString(), "product", strconv.FormatInt(aggregateId, 10), eventType, payload)
+	if err != nil {
+		return dbError(err, "failed to write outbox record")
+	}
+	return nil
+}
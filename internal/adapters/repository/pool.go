@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// WarmPool opens conns connections to db at once, so that first requests do
+// not pay for connecting. Connections stay idle in pool as long as
+// db allows that many idle ones
+func WarmPool(ctx context.Context, db *sql.DB, conns int) error {
+	opened := make([]*sql.Conn, 0, conns)
+	defer func() {
+		for _, conn := range opened {
+			conn.Close()
+		}
+	}()
+	for range conns {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return dbError(err, "failed to warm connection pool")
+		}
+		opened = append(opened, conn)
+		if err := conn.PingContext(ctx); err != nil {
+			return dbError(err, "failed to warm connection pool")
+		}
+	}
+	return nil
+}
+
+type poolStatser interface {
+	Stats() sql.DBStats
+}
+
+// PoolMonitor warns when requests for a connection wait for a free one
+// longer than threshold on average, so pool exhaustion shows up in logs
+// before requests start timing out
+type PoolMonitor struct {
+	db        poolStatser
+	interval  time.Duration
+	threshold time.Duration
+	logger    *slog.Logger
+}
+
+func NewPoolMonitor(db poolStatser, interval time.Duration, threshold time.Duration, logger *slog.Logger) *PoolMonitor {
+	return &PoolMonitor{
+		db:        db,
+		interval:  interval,
+		threshold: threshold,
+		logger:    logger.With(slog.String("component", "db_pool")),
+	}
+}
+
+// Run compares pool stats every interval until ctx is done
+func (m *PoolMonitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	prev := m.db.Stats()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			current := m.db.Stats()
+			m.check(prev, current)
+			prev = current
+		}
+	}
+}
+
+func (m *PoolMonitor) Close() error {
+	return nil
+}
+
+// check warns if connections waited for since prev took threshold or longer
+// on average, and reports whether it did
+func (m *PoolMonitor) check(prev sql.DBStats, current sql.DBStats) bool {
+	waits := current.WaitCount - prev.WaitCount
+	if waits == 0 {
+		return false
+	}
+	average := (current.WaitDuration - prev.WaitDuration) / time.Duration(waits)
+	if average < m.threshold {
+		return false
+	}
+	m.logger.Warn("database connection pool is saturated",
+		slog.Int64("waits", waits),
+		slog.Duration("average_wait", average),
+		slog.Int("in_use", current.InUse),
+		slog.Int("idle", current.Idle),
+		slog.Int("max_open", current.MaxOpenConnections),
+	)
+	return true
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolMonitorCheck(t *testing.T) {
+	monitor := NewPoolMonitor(nil, time.Second, 100*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	prev := sql.DBStats{WaitCount: 10, WaitDuration: 5 * time.Second}
+	tests := []struct {
+		name     string
+		current  sql.DBStats
+		expected bool
+	}{
+		{name: "no waits", current: prev, expected: false},
+		{name: "short waits", current: sql.DBStats{WaitCount: 20, WaitDuration: 5*time.Second + 500*time.Millisecond}, expected: false},
+		{name: "average at threshold", current: sql.DBStats{WaitCount: 20, WaitDuration: 6 * time.Second}, expected: true},
+		{name: "single long wait", current: sql.DBStats{WaitCount: 11, WaitDuration: 7 * time.Second}, expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, monitor.check(prev, tt.current))
+		})
+	}
+}
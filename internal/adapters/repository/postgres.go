@@ -5,132 +5,675 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
 
 	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
 )
 
 type PostgresRepository struct {
-	db *sql.DB
+	db                  *sql.DB
+	outbox              bool
+	audit               bool
+	similarityThreshold float64
+	metrics             ports.QueryMetrics
+}
+
+type Option func(*PostgresRepository)
+
+// WithOutbox makes every write also record a change into outbox table,
+// within the same transaction
+func WithOutbox() Option {
+	return func(r *PostgresRepository) {
+		r.outbox = true
+	}
+}
+
+// WithQueryMetrics counts operations by method and outcome
+func WithQueryMetrics(metrics ports.QueryMetrics) Option {
+	return func(r *PostgresRepository) {
+		r.metrics = metrics
+	}
+}
+
+func NewPostgresRepository(db *sql.DB, opts ...Option) *PostgresRepository {
+	r := &PostgresRepository{db: db, similarityThreshold: defaultSimilarityThreshold}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// observe records operation once it is done, err points to its result
+func (r *PostgresRepository) observe(operation string, err *error) {
+	if r.metrics != nil {
+		r.metrics.QueryDone(operation, *err)
+	}
 }
 
-func NewPostgresRepository(db *sql.DB) *PostgresRepository {
-	return &PostgresRepository{db: db}
+// CheckSchema fails unless tables and columns the repository queries exist,
+// e.g. while sql/init.sql has not been applied yet
+func (r *PostgresRepository) CheckSchema(ctx context.Context) error {
+	queries := []string{
+		"SELECT id, name, additional_info, version, category_id, sku, stock, tags, " + imagesColumn + ", created_at, updated_at FROM products LIMIT 0",
+		"SELECT id, name FROM categories LIMIT 0",
+	}
+	if r.outbox {
+		queries = append(queries, "SELECT id, aggregatetype, aggregateid, type, payload, created_at FROM outbox LIMIT 0")
+	}
+	if r.audit {
+		queries = append(queries, "SELECT id, product_id, action, actor, before, after, created_at FROM product_audit LIMIT 0")
+	}
+	for _, query := range queries {
+		rows, err := r.db.QueryContext(ctx, query)
+		if err != nil {
+			return dbError(err, "schema is not migrated")
+		}
+		rows.Close()
+	}
+	return nil
 }
 
-func (r *PostgresRepository) GetProduct(ctx context.Context, id int64) (*domain.Product, error) {
+func (r *PostgresRepository) GetProduct(ctx context.Context, id int64) (_ *domain.Product, err error) {
+	defer r.observe("get_product", &err)
 	var product domain.Product
-	err := r.db.QueryRow("SELECT id, name, additional_info FROM products WHERE id = $1", id).
-		Scan(&product.Id, &product.Name, &product.AdditionalInfo)
+	err = r.db.QueryRowContext(ctx, "SELECT id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, "+imagesColumn+" FROM products WHERE id = $1", id).
+		Scan(&product.Id, &product.Name, &product.AdditionalInfo, &product.Version, &product.CategoryId, &product.Sku, &product.Stock, pq.Array(&product.Tags), (*images)(&product.Images))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("%w: failed to find product %d in DB", domain.ErrNotFound, id)
 		}
-		return nil, fmt.Errorf("%w: failed to get product %d. %s", domain.ErrInternalDb, id, err.Error())
+		return nil, dbError(err, "failed to get product %d", id)
 	}
 	return &product, nil
 }
 
-func (r *PostgresRepository) GetAllProducts(ctx context.Context) ([]domain.Product, error) {
+func (r *PostgresRepository) GetProductBySku(ctx context.Context, sku string) (_ *domain.Product, err error) {
+	defer r.observe("get_product_by_sku", &err)
+	var product domain.Product
+	err = r.db.QueryRowContext(ctx, "SELECT id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, "+imagesColumn+" FROM products WHERE sku = $1", sku).
+		Scan(&product.Id, &product.Name, &product.AdditionalInfo, &product.Version, &product.CategoryId, &product.Sku, &product.Stock, pq.Array(&product.Tags), (*images)(&product.Images))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: failed to find product with sku %q in DB", domain.ErrNotFound, sku)
+		}
+		return nil, dbError(err, "failed to get product with sku %q", sku)
+	}
+	return &product, nil
+}
+
+func (r *PostgresRepository) GetAllProducts(ctx context.Context) (_ []domain.Product, err error) {
+	defer r.observe("get_all_products", &err)
 	var products = make([]domain.Product, 0)
-	rows, err := r.db.Query("SELECT id, name, additional_info FROM products")
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, "+imagesColumn+" FROM products ORDER BY id")
 	if err != nil {
-		return nil, fmt.Errorf("%w: failed to get all products", domain.ErrInternalDb)
+		return nil, dbError(err, "failed to get all products")
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var product domain.Product
-		if err := rows.Scan(&product.Id, &product.Name, &product.AdditionalInfo); err != nil {
-			return nil, fmt.Errorf("%w: failed to convert row into go type. %s", domain.ErrInternalDb, err.Error())
+		if err := rows.Scan(&product.Id, &product.Name, &product.AdditionalInfo, &product.Version, &product.CategoryId, &product.Sku, &product.Stock, pq.Array(&product.Tags), (*images)(&product.Images)); err != nil {
+			return nil, dbError(err, "failed to convert row into go type")
 		}
 		products = append(products, product)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("%w: error while iterating over rows. %s", domain.ErrInternalDb, err.Error())
+		return nil, dbError(err, "error while iterating over rows")
 	}
 	return products, nil
 }
 
-func (r *PostgresRepository) GetProductsPaged(ctx context.Context, limit int64, offset int64) ([]domain.Product, error) {
+func (r *PostgresRepository) EachProduct(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, fn func(product domain.Product) error) (err error) {
+	defer r.observe("each_product", &err)
+	terms, err := sortTerms(sort)
+	if err != nil {
+		return err
+	}
+	where, args := filterClause(filter)
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, "+imagesColumn+" FROM products"+where+orderClause(terms), args...)
+	if err != nil {
+		return dbError(err, "failed to get all products")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var product domain.Product
+		if err := rows.Scan(&product.Id, &product.Name, &product.AdditionalInfo, &product.Version, &product.CategoryId, &product.Sku, &product.Stock, pq.Array(&product.Tags), (*images)(&product.Images)); err != nil {
+			return dbError(err, "failed to convert row into go type")
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return dbError(err, "error while iterating over rows")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetProductsPaged(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, limit int64, offset int64) (_ []domain.Product, err error) {
+	defer r.observe("get_products_paged", &err)
+	terms, err := sortTerms(sort)
+	if err != nil {
+		return nil, err
+	}
 	var products = make([]domain.Product, 0, limit)
-	rows, err := r.db.Query("SELECT id, name, additional_info FROM products LIMIT $1 OFFSET $2", limit, offset)
+	where, args := filterClause(filter)
+	rows, err := r.db.QueryContext(ctx,
+		fmt.Sprintf("SELECT id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, "+imagesColumn+" FROM products%s%s LIMIT $%d OFFSET $%d", where, orderClause(terms), len(args)+1, len(args)+2),
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, dbError(err, "failed to get paginated products")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var product domain.Product
+		if err := rows.Scan(&product.Id, &product.Name, &product.AdditionalInfo, &product.Version, &product.CategoryId, &product.Sku, &product.Stock, pq.Array(&product.Tags), (*images)(&product.Images)); err != nil {
+			return nil, dbError(err, "failed to convert row into go type")
+		}
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error while iterating over rows")
+	}
+	return products, nil
+}
+
+// GetProductsAfter seeks past after by sort columns, so deep pages cost no
+// more than the first one when an index covers them
+func (r *PostgresRepository) GetProductsAfter(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, after *domain.Product, limit int64) (_ []domain.Product, err error) {
+	defer r.observe("get_products_after", &err)
+	terms, err := sortTerms(sort)
 	if err != nil {
-		return nil, fmt.Errorf("%w: failed to get paginated products. %s", domain.ErrInternalDb, err.Error())
+		return nil, err
+	}
+	where, args := filterClause(filter)
+	if after != nil {
+		var condition string
+		condition, args = keysetCondition(terms, *after, args)
+		if where == "" {
+			where = " WHERE " + condition
+		} else {
+			where += " AND " + condition
+		}
+	}
+	rows, err := r.db.QueryContext(ctx,
+		fmt.Sprintf("SELECT id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, "+imagesColumn+" FROM products%s%s LIMIT $%d", where, orderClause(terms), len(args)+1),
+		append(args, limit)...)
+	if err != nil {
+		return nil, dbError(err, "failed to get products after cursor")
 	}
 	defer rows.Close()
+	products := make([]domain.Product, 0, limit)
 	for rows.Next() {
 		var product domain.Product
-		if err := rows.Scan(&product.Id, &product.Name, &product.AdditionalInfo); err != nil {
-			return nil, fmt.Errorf("%w: failed to convert row into go type. %s", domain.ErrInternalDb, err.Error())
+		if err := rows.Scan(&product.Id, &product.Name, &product.AdditionalInfo, &product.Version, &product.CategoryId, &product.Sku, &product.Stock, pq.Array(&product.Tags), (*images)(&product.Images)); err != nil {
+			return nil, dbError(err, "failed to convert row into go type")
 		}
 		products = append(products, product)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error while iterating over rows")
+	}
+	return products, nil
+}
 
+func (r *PostgresRepository) ProductIdRange(ctx context.Context) (_ int64, _ int64, err error) {
+	defer r.observe("product_id_range", &err)
+	var first, last int64
+	err = r.db.QueryRowContext(ctx, "SELECT COALESCE(MIN(id), 0), COALESCE(MAX(id), 0) FROM products").Scan(&first, &last)
+	if err != nil {
+		return 0, 0, dbError(err, "failed to get product id range")
+	}
+	return first, last, nil
+}
+
+func (r *PostgresRepository) GetProductsInRange(ctx context.Context, fromId int64, toId int64) (_ []domain.Product, err error) {
+	defer r.observe("get_products_in_range", &err)
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, "+imagesColumn+" FROM products WHERE id >= $1 AND id < $2 ORDER BY id", fromId, toId)
+	if err != nil {
+		return nil, dbError(err, "failed to get products in range")
+	}
+	defer rows.Close()
+	products := make([]domain.Product, 0)
+	for rows.Next() {
+		var product domain.Product
+		if err := rows.Scan(&product.Id, &product.Name, &product.AdditionalInfo, &product.Version, &product.CategoryId, &product.Sku, &product.Stock, pq.Array(&product.Tags), (*images)(&product.Images)); err != nil {
+			return nil, dbError(err, "failed to convert row into go type")
+		}
+		products = append(products, product)
+	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("%w: error while iterating over rows. %s", domain.ErrInternalDb, err.Error())
+		return nil, dbError(err, "error while iterating over rows")
 	}
 	return products, nil
 }
 
-func (r *PostgresRepository) UpdateProductById(ctx context.Context, id int64, product domain.NewProduct) (*domain.Product, error) {
+func (r *PostgresRepository) CountProducts(ctx context.Context, filter domain.ProductFilter) (_ int64, err error) {
+	defer r.observe("count_products", &err)
+	var count int64
+	where, args := filterClause(filter)
+	err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products"+where, args...).Scan(&count)
+	if err != nil {
+		return 0, dbError(err, "failed to count products")
+	}
+	return count, nil
+}
+
+// likeEscaper makes user input match literally in LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// filterClause turns filter into WHERE clause, placeholders are numbered
+// from $1 in order of returned args
+func filterClause(filter domain.ProductFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if filter.Name != "" {
+		// served by lower(name) index
+		args = append(args, strings.ToLower(filter.Name))
+		conditions = append(conditions, fmt.Sprintf("lower(name) = $%d", len(args)))
+	}
+	if filter.InfoContains != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.InfoContains)+"%")
+		conditions = append(conditions, fmt.Sprintf(`additional_info ILIKE $%d ESCAPE '\'`, len(args)))
+	}
+	if filter.CategoryId != 0 {
+		args = append(args, filter.CategoryId)
+		conditions = append(conditions, fmt.Sprintf("category_id = $%d", len(args)))
+	}
+	if filter.Tag != "" {
+		// containment is served by GIN index, unlike ANY
+		args = append(args, filter.Tag)
+		conditions = append(conditions, fmt.Sprintf("tags @> ARRAY[$%d::text]", len(args)))
+	}
+	// bounds are served by created_at and updated_at indexes
+	if !filter.CreatedAfter.IsZero() {
+		args = append(args, filter.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		args = append(args, filter.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		args = append(args, filter.UpdatedAfter)
+		conditions = append(conditions, fmt.Sprintf("updated_at >= $%d", len(args)))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// sortColumns whitelists fields products can be sorted by. Text is
+// compared bytewise, as domain.ProductSort does when merging shards
+var sortColumns = map[string]string{
+	"id":             "id",
+	"name":           `name COLLATE "C"`,
+	"additionalInfo": `additional_info COLLATE "C"`,
+}
+
+// sortTerms validates sort and appends id to break ties, unless sort
+// already includes it
+func sortTerms(sort domain.ProductSort) (domain.ProductSort, error) {
+	terms := make(domain.ProductSort, 0, len(sort)+1)
+	byId := false
+	for _, f := range sort {
+		if _, ok := sortColumns[f.Field]; !ok {
+			return nil, fmt.Errorf("%w: products can not be sorted by %q", domain.ErrInvalidInput, f.Field)
+		}
+		terms = append(terms, f)
+		byId = byId || f.Field == "id"
+	}
+	if !byId {
+		terms = append(terms, domain.SortField{Field: "id"})
+	}
+	return terms, nil
+}
+
+// orderClause turns sort terms into ORDER BY clause
+func orderClause(terms domain.ProductSort) string {
+	columns := make([]string, len(terms))
+	for i, f := range terms {
+		columns[i] = sortColumns[f.Field]
+		if f.Desc {
+			columns[i] += " DESC"
+		}
+	}
+	return " ORDER BY " + strings.Join(columns, ", ")
+}
+
+// keysetCondition matches rows following after in order of sort terms. It
+// is row comparison spelled out, (a > $1) OR (a = $1 AND b > $2) and so
+// on, as row comparison can not mix directions
+func keysetCondition(terms domain.ProductSort, after domain.Product, args []interface{}) (string, []interface{}) {
+	alternatives := make([]string, len(terms))
+	for i, f := range terms {
+		var equal []string
+		for _, prev := range terms[:i] {
+			args = append(args, sortValue(after, prev.Field))
+			equal = append(equal, fmt.Sprintf("%s = $%d", sortColumns[prev.Field], len(args)))
+		}
+		operator := ">"
+		if f.Desc {
+			operator = "<"
+		}
+		args = append(args, sortValue(after, f.Field))
+		alternatives[i] = "(" + strings.Join(append(equal, fmt.Sprintf("%s %s $%d", sortColumns[f.Field], operator, len(args))), " AND ") + ")"
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+func sortValue(p domain.Product, field string) interface{} {
+	switch field {
+	case "name":
+		return p.Name
+	case "additionalInfo":
+		return p.AdditionalInfo
+	default:
+		return p.Id
+	}
+}
+
+func (r *PostgresRepository) SuggestProductNames(ctx context.Context, prefix string, limit int64) (_ []string, err error) {
+	defer r.observe("suggest_product_names", &err)
+	// lower(name) text_pattern_ops index serves prefix matches
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT DISTINCT name FROM products WHERE lower(name) LIKE $1 ESCAPE '\' ORDER BY name LIMIT $2`,
+		likeEscaper.Replace(strings.ToLower(prefix))+"%", limit)
+	if err != nil {
+		return nil, dbError(err, "failed to get name suggestions")
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, dbError(err, "failed to scan name suggestion")
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "failed to get name suggestions")
+	}
+	return names, nil
+}
+
+// UpdateProductById fails with domain.ErrVersionConflict if product.Version
+// is set and the stored product is at another version
+func (r *PostgresRepository) UpdateProductById(ctx context.Context, id int64, product domain.NewProduct) (_ *domain.Product, err error) {
+	defer r.observe("update_product_by_id", &err)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, dbError(err, "failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	// version condition is evaluated again on the locked row, so of two
+	// concurrent writers expecting the same version only one succeeds
 	var oldProduct domain.Product
-	err := r.db.QueryRow(
-		`UPDATE products SET name = $1, additional_info = $2
-		FROM (SELECT name, additional_info FROM products WHERE id = $3) as old
-		WHERE id = $3
-		RETURNING id, old.name, old.additional_info`,
-		product.Name, product.AdditionalInfo, id).Scan(&oldProduct.Id, &oldProduct.Name, &oldProduct.AdditionalInfo)
+	err = tx.QueryRowContext(ctx,
+		`UPDATE products SET name = $1, additional_info = $2, category_id = NULLIF($5::bigint, 0), sku = NULLIF($6, ''), version = products.version + 1, updated_at = now()
+		FROM (SELECT name, additional_info, version, category_id, sku, stock, tags FROM products WHERE id = $3) as old
+		WHERE id = $3 AND ($4::bigint = 0 OR products.version = $4)
+		RETURNING id, old.name, old.additional_info, old.version, COALESCE(old.category_id, 0), COALESCE(old.sku, ''), old.stock, old.tags, `+imagesColumn,
+		product.Name, product.AdditionalInfo, id, product.Version, product.CategoryId, product.Sku).
+		Scan(&oldProduct.Id, &oldProduct.Name, &oldProduct.AdditionalInfo, &oldProduct.Version, &oldProduct.CategoryId, &oldProduct.Sku, &oldProduct.Stock, pq.Array(&oldProduct.Tags), (*images)(&oldProduct.Images))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("%w: failed to find product %d in DB", domain.ErrNotFound, id)
+			return nil, r.updateMissed(ctx, tx, id, product.Version)
 		}
-		return nil, fmt.Errorf("%w: failed to update product %d. %s", domain.ErrInternalDb, id, err.Error())
+		return nil, storeError(err, "failed to update product %d", id)
+	}
+	newProduct := domain.Product{Id: id, Name: product.Name, AdditionalInfo: product.AdditionalInfo, Version: oldProduct.Version + 1, CategoryId: product.CategoryId, Sku: product.Sku, Stock: oldProduct.Stock, Tags: oldProduct.Tags, Images: oldProduct.Images}
+	if err := r.writeOutbox(tx, domain.EventProductUpdated, opUpdate, id, &oldProduct, &newProduct); err != nil {
+		return nil, err
+	}
+	if err := r.writeAudit(ctx, tx, domain.AuditUpdate, id, &oldProduct, &newProduct); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, dbError(err, "failed to commit transaction")
 	}
 	return &oldProduct, nil
 }
 
-func (r *PostgresRepository) DeleteProductById(ctx context.Context, id int64) (*domain.Product, error) {
+// updateMissed tells why update matched no row
+func (r *PostgresRepository) updateMissed(ctx context.Context, tx *sql.Tx, id int64, expected int64) error {
+	var version int64
+	err := tx.QueryRowContext(ctx, "SELECT version FROM products WHERE id = $1", id).Scan(&version)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: failed to find product %d in DB", domain.ErrNotFound, id)
+	case err != nil:
+		return dbError(err, "failed to get version of product %d", id)
+	}
+	return fmt.Errorf("%w: product %d is at version %d, not %d", domain.ErrVersionConflict, id, version, expected)
+}
+
+func (r *PostgresRepository) DeleteProductById(ctx context.Context, id int64) (_ *domain.Product, err error) {
+	defer r.observe("delete_product_by_id", &err)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, dbError(err, "failed to start transaction")
+	}
+	defer tx.Rollback()
+
 	var oldProduct domain.Product
-	err := r.db.QueryRow("DELETE FROM products WHERE id = $1 RETURNING id, name, additional_info", id).Scan(&oldProduct.Id, &oldProduct.Name, &oldProduct.AdditionalInfo)
+	err = tx.QueryRowContext(ctx, "DELETE FROM products WHERE id = $1 RETURNING id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, "+imagesColumn, id).
+		Scan(&oldProduct.Id, &oldProduct.Name, &oldProduct.AdditionalInfo, &oldProduct.Version, &oldProduct.CategoryId, &oldProduct.Sku, &oldProduct.Stock, pq.Array(&oldProduct.Tags), (*images)(&oldProduct.Images))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("%w: failed to find product %d in DB", domain.ErrNotFound, id)
 		}
-		return nil, fmt.Errorf("%w: failed to delete product %d. %s", domain.ErrInternalDb, id, err.Error())
+		return nil, dbError(err, "failed to delete product %d", id)
+	}
+	if err := r.writeOutbox(tx, domain.EventProductDeleted, opDelete, id, &oldProduct, nil); err != nil {
+		return nil, err
+	}
+	if err := r.writeAudit(ctx, tx, domain.AuditDelete, id, &oldProduct, nil); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, dbError(err, "failed to commit transaction")
 	}
 	return &oldProduct, nil
 }
 
-func (r *PostgresRepository) DeleteAllProducts(ctx context.Context) (int64, error) {
+func (r *PostgresRepository) DeleteAllProducts(ctx context.Context) (_ int64, err error) {
+	defer r.observe("delete_all_products", &err)
 	var count int64
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, fmt.Errorf("%w: failed to start transaction. %s", domain.ErrInternalDb, err.Error())
+		return 0, dbError(err, "failed to start transaction")
 	}
 	defer tx.Rollback()
 
-	err = tx.QueryRow("SELECT COUNT (*) FROM products").Scan(&count)
+	err = tx.QueryRowContext(ctx, "SELECT COUNT (*) FROM products").Scan(&count)
 	if err != nil {
-		return 0, fmt.Errorf("%w: failed to count rows. %s", domain.ErrInternalDb, err.Error())
+		return 0, dbError(err, "failed to count rows")
+	}
+	if err := r.writeAuditAll(ctx, tx); err != nil {
+		return 0, err
 	}
-	_, err = tx.Exec("TRUNCATE TABLE products")
+	_, err = tx.ExecContext(ctx, "TRUNCATE TABLE products, product_images")
 	if err != nil {
-		return 0, fmt.Errorf("%w: failed to truncate table. %s", domain.ErrInternalDb, err.Error())
+		return 0, dbError(err, "failed to truncate table")
+	}
+	if err := r.writeOutbox(tx, domain.EventProductsDeletedAll, opTruncate, 0, nil, nil); err != nil {
+		return 0, err
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return 0, fmt.Errorf("%w: failed to commit transaction. %s", domain.ErrInternalDb, err.Error())
+		return 0, dbError(err, "failed to commit transaction")
 	}
 
 	return count, nil
 }
 
-func (r *PostgresRepository) StoreProduct(ctx context.Context, product domain.NewProduct) (int64, error) {
+func (r *PostgresRepository) StoreProduct(ctx context.Context, product domain.NewProduct) (_ int64, err error) {
+	defer r.observe("store_product", &err)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, dbError(err, "failed to start transaction")
+	}
+	defer tx.Rollback()
+
 	var id int64
-	err := r.db.QueryRow("INSERT INTO products (name, additional_info) VALUES ($1, $2) RETURNING id", product.Name, product.AdditionalInfo).Scan(&id)
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO products (name, additional_info, category_id, sku) VALUES ($1, $2, NULLIF($3::bigint, 0), NULLIF($4, '')) RETURNING id",
+		product.Name, product.AdditionalInfo, product.CategoryId, product.Sku).Scan(&id)
 	if err != nil {
-		return 0, fmt.Errorf("%w: failed to store product. %s", domain.ErrInternalDb, err.Error())
+		return 0, storeError(err, "failed to store product")
+	}
+	newProduct := domain.Product{Id: id, Name: product.Name, AdditionalInfo: product.AdditionalInfo, Version: 1, CategoryId: product.CategoryId, Sku: product.Sku}
+	if err := r.writeOutbox(tx, domain.EventProductCreated, opCreate, id, nil, &newProduct); err != nil {
+		return 0, err
+	}
+	if err := r.writeAudit(ctx, tx, domain.AuditCreate, id, nil, &newProduct); err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, dbError(err, "failed to commit transaction")
 	}
 	return id, nil
 }
+
+// storeBatchSize keeps multi-row inserts well below postgres limit of 65535
+// bind parameters per statement
+const storeBatchSize = 1000
+
+// StoreProducts inserts products in a single transaction using multi-row
+// inserts. Returned ids follow the order of products
+func (r *PostgresRepository) StoreProducts(ctx context.Context, products []domain.NewProduct) (_ []int64, err error) {
+	defer r.observe("store_products", &err)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, dbError(err, "failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, 0, len(products))
+	for start := 0; start < len(products); start += storeBatchSize {
+		batch := products[start:min(start+storeBatchSize, len(products))]
+		placeholders := make([]string, len(batch))
+		args := make([]interface{}, 0, 4*len(batch))
+		for i, p := range batch {
+			placeholders[i] = fmt.Sprintf("($%d, $%d, NULLIF($%d::bigint, 0), NULLIF($%d, ''))", 4*i+1, 4*i+2, 4*i+3, 4*i+4)
+			args = append(args, p.Name, p.AdditionalInfo, p.CategoryId, p.Sku)
+		}
+		rows, err := tx.QueryContext(ctx,
+			"INSERT INTO products (name, additional_info, category_id, sku) VALUES "+strings.Join(placeholders, ", ")+" RETURNING id",
+			args...)
+		if err != nil {
+			return nil, storeError(err, "failed to store products")
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, dbError(err, "failed to read stored product id")
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, dbError(err, "failed to store products")
+		}
+	}
+
+	for i, id := range ids {
+		newProduct := domain.Product{Id: id, Name: products[i].Name, AdditionalInfo: products[i].AdditionalInfo, Version: 1, CategoryId: products[i].CategoryId, Sku: products[i].Sku}
+		if err := r.writeOutbox(tx, domain.EventProductCreated, opCreate, id, nil, &newProduct); err != nil {
+			return nil, err
+		}
+		if err := r.writeAudit(ctx, tx, domain.AuditCreate, id, nil, &newProduct); err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, dbError(err, "failed to commit transaction")
+	}
+	return ids, nil
+}
+
+// UpsertProduct stores product under its own id, overwriting existing row if any.
+// Version, stock, tags and images of product are not stored, overwritten row
+// gets the next version and keeps the rest.
+// Reports whether a new row was created. Id sequence is moved forward when needed,
+// so products created later via StoreProduct do not collide with upserted ones
+func (r *PostgresRepository) UpsertProduct(ctx context.Context, product domain.Product) (_ bool, err error) {
+	defer r.observe("upsert_product", &err)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, dbError(err, "failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	var before *domain.Product
+	if r.outbox || r.audit {
+		var old domain.Product
+		err = tx.QueryRowContext(ctx, "SELECT id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, "+imagesColumn+" FROM products WHERE id = $1 FOR UPDATE", product.Id).
+			Scan(&old.Id, &old.Name, &old.AdditionalInfo, &old.Version, &old.CategoryId, &old.Sku, &old.Stock, pq.Array(&old.Tags), (*images)(&old.Images))
+		switch {
+		case err == nil:
+			before = &old
+		case !errors.Is(err, sql.ErrNoRows):
+			return false, dbError(err, "failed to get product %d", product.Id)
+		}
+	}
+
+	var created bool
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO products (id, name, additional_info, category_id, sku) VALUES ($1, $2, $3, NULLIF($4::bigint, 0), NULLIF($5, ''))
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, additional_info = EXCLUDED.additional_info,
+			category_id = EXCLUDED.category_id, sku = EXCLUDED.sku, version = products.version + 1, updated_at = now()
+		RETURNING (xmax = 0), version, stock, tags, `+imagesColumn,
+		product.Id, product.Name, product.AdditionalInfo, product.CategoryId, product.Sku).Scan(&created, &product.Version, &product.Stock, pq.Array(&product.Tags), (*images)(&product.Images))
+	if err != nil {
+		return false, storeError(err, "failed to upsert product %d", product.Id)
+	}
+	if created {
+		_, err = tx.ExecContext(ctx,
+			`SELECT setval('products_id_seq', $1)
+			WHERE $1 > (SELECT last_value FROM products_id_seq)`,
+			product.Id)
+		if err != nil {
+			return false, dbError(err, "failed to adjust id sequence")
+		}
+	}
+	if created {
+		err = r.writeOutbox(tx, domain.EventProductCreated, opCreate, product.Id, nil, &product)
+		if err == nil {
+			err = r.writeAudit(ctx, tx, domain.AuditCreate, product.Id, nil, &product)
+		}
+	} else {
+		err = r.writeOutbox(tx, domain.EventProductUpdated, opUpdate, product.Id, before, &product)
+		if err == nil {
+			err = r.writeAudit(ctx, tx, domain.AuditUpdate, product.Id, before, &product)
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, dbError(err, "failed to commit transaction")
+	}
+	return created, nil
+}
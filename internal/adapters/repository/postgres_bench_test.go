@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers"
+)
+
+// TestMain terminates the container shared by benchmarks, if they started one
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if err := testhelpers.TerminateSharedContainers(context.Background()); err != nil {
+		log.Println(err)
+	}
+	os.Exit(code)
+}
+
+// newBenchRepository returns repository on a shared container, b.N rounds of
+// the same benchmark reuse it instead of starting a new one each time
+func newBenchRepository(b *testing.B) *PostgresRepository {
+	b.Helper()
+	ctx := context.Background()
+	pgContainer, err := testhelpers.SharedPostgresContainer(ctx)
+	if err != nil {
+		b.Fatal("failed to create PostgresContainer: ", err)
+	}
+	if err := pgContainer.Reset(ctx); err != nil {
+		b.Fatal("failed to reset postgres container: ", err)
+	}
+	db, err := sql.Open("postgres", pgContainer.ConnectionString)
+	if err != nil {
+		b.Fatal("failed to start database client: ", err)
+	}
+	b.Cleanup(func() { db.Close() })
+	return NewPostgresRepository(db)
+}
+
+func BenchmarkGetProduct(b *testing.B) {
+	repo := newBenchRepository(b)
+	ctx := context.Background()
+	products, err := testhelpers.InsertProducts(repo.db, testhelpers.GenerateProducts(1000)...)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.GetProduct(ctx, products[i%len(products)].Id); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStoreProductsBatch(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			repo := newBenchRepository(b)
+			ctx := context.Background()
+			batch := make([]domain.NewProduct, size)
+			for i, p := range testhelpers.GenerateProducts(size) {
+				batch[i] = domain.NewProduct{Name: p.Name, AdditionalInfo: p.AdditionalInfo}
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.StoreProducts(ctx, batch); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*size)/b.Elapsed().Seconds(), "products/s")
+		})
+	}
+}
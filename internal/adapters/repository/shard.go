@@ -0,0 +1,575 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lib/pq"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// shardStreamBuffer is how many products EachProduct reads ahead per shard
+const shardStreamBuffer = 64
+
+// ShardedRepository spreads products over several databases by id, either
+// by ranges or by hash. Calls on a single product go to the shard holding
+// it, lists and counts fan out to all shards and merge results in the
+// order single database would return them. Writes of many products are
+// transactional per shard only: StoreProducts keeps the whole batch on one
+// shard, DeleteAllProducts may delete from some shards and fail on others.
+// Skus are kept unique across shards by checking all of them before a
+// write, see claimSkus. Id sequences of shards must hand out only ids
+// routed to them, see IdSequence
+type ShardedRepository struct {
+	shards []ports.Repository
+	// first ids of shard ranges in ascending order, nil for hash sharding
+	starts []int64
+	// picks shard of new products under hash sharding
+	next atomic.Uint64
+	// serializes writes of skus if the first shard can not lock them
+	skuMu sync.Mutex
+}
+
+// skuLocker locks skus for writers of all instances, see
+// PostgresRepository.LockSkus
+type skuLocker interface {
+	LockSkus(ctx context.Context, skus []string) (func(), error)
+}
+
+// skuLockClass namespaces advisory locks of skus
+const skuLockClass = 0x736b75
+
+// NewHashShardedRepository routes product id to shard (id - 1) mod number
+// of shards. New products are spread round-robin
+func NewHashShardedRepository(shards []ports.Repository) *ShardedRepository {
+	return &ShardedRepository{shards: shards}
+}
+
+// NewRangeShardedRepository routes product ids from starts[i] up to
+// starts[i+1] to shard i, ids below starts[0] to the first shard. New
+// products go to the last shard, so catalog grows by adding a shard whose
+// range starts above ids in use
+func NewRangeShardedRepository(shards []ports.Repository, starts []int64) (*ShardedRepository, error) {
+	if len(starts) != len(shards) {
+		return nil, fmt.Errorf("%w: %d shards need %d range starts, got %d", domain.ErrInvalidInput, len(shards), len(shards), len(starts))
+	}
+	for i := 1; i < len(starts); i++ {
+		if starts[i] <= starts[i-1] {
+			return nil, fmt.Errorf("%w: shard range starts must ascend", domain.ErrInvalidInput)
+		}
+	}
+	return &ShardedRepository{shards: shards, starts: starts}, nil
+}
+
+// ParseShardRanges parses comma separated first ids of shard ranges
+func ParseShardRanges(s string) ([]int64, error) {
+	var starts []int64
+	for _, field := range strings.Split(s, ",") {
+		start, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+		if err != nil || start < 1 {
+			return nil, fmt.Errorf("%w: invalid shard range start %q", domain.ErrInvalidInput, field)
+		}
+		starts = append(starts, start)
+	}
+	return starts, nil
+}
+
+// ShardOf returns index of shard holding product id
+func (r *ShardedRepository) ShardOf(id int64) int {
+	if r.starts == nil {
+		// there are no such products, any shard can tell
+		if id < 1 {
+			return 0
+		}
+		return int((id - 1) % int64(len(r.shards)))
+	}
+	i, found := slices.BinarySearch(r.starts, id)
+	if !found {
+		i--
+	}
+	return max(i, 0)
+}
+
+// IdSequence tells which ids id sequence of shard has to hand out, first
+// and every increment-th after it, so that stored products stay on shard
+// they are routed to by id
+func (r *ShardedRepository) IdSequence(shard int) (int64, int64) {
+	if r.starts == nil {
+		return int64(shard) + 1, int64(len(r.shards))
+	}
+	return max(r.starts[shard], 1), 1
+}
+
+// shardForNew picks shard storing new products
+func (r *ShardedRepository) shardForNew() ports.Repository {
+	if r.starts == nil {
+		return r.shards[(r.next.Add(1)-1)%uint64(len(r.shards))]
+	}
+	return r.shards[len(r.shards)-1]
+}
+
+// fanOut calls all shards concurrently. Error of the first failed shard is
+// returned
+func fanOut[T any](ctx context.Context, shards []ports.Repository, call func(ctx context.Context, shard ports.Repository) (T, error)) ([]T, error) {
+	results := make([]T, len(shards))
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = call(ctx, shard)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// mergeSorted merges lists ordered by sort
+func mergeSorted(lists [][]domain.Product, sort domain.ProductSort) []domain.Product {
+	merged := slices.Concat(lists...)
+	if merged == nil {
+		merged = []domain.Product{}
+	}
+	slices.SortFunc(merged, sort.Compare)
+	return merged
+}
+
+// page cuts page out of merged results
+func page[T any](items []T, limit int64, offset int64) []T {
+	offset = min(offset, int64(len(items)))
+	return items[offset:min(offset+limit, int64(len(items)))]
+}
+
+func (r *ShardedRepository) GetProduct(ctx context.Context, id int64) (*domain.Product, error) {
+	return r.shards[r.ShardOf(id)].GetProduct(ctx, id)
+}
+
+// GetProductBySku asks all shards, as sku says nothing of where product is.
+// Of products stored before skus were checked across shards and sharing
+// one, the lowest id is returned
+func (r *ShardedRepository) GetProductBySku(ctx context.Context, sku string) (*domain.Product, error) {
+	found, err := r.productsWithSku(ctx, sku)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("%w: failed to find product with sku %q in any shard", domain.ErrNotFound, sku)
+	}
+	return slices.MinFunc(found, func(a, b *domain.Product) int { return cmp.Compare(a.Id, b.Id) }), nil
+}
+
+// productsWithSku returns product with sku of every shard having one
+func (r *ShardedRepository) productsWithSku(ctx context.Context, sku string) ([]*domain.Product, error) {
+	found, err := fanOut(ctx, r.shards, func(ctx context.Context, shard ports.Repository) (*domain.Product, error) {
+		product, err := shard.GetProductBySku(ctx, sku)
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, nil
+		}
+		return product, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(found, func(product *domain.Product) bool { return product == nil }), nil
+}
+
+func (r *ShardedRepository) GetAllProducts(ctx context.Context) ([]domain.Product, error) {
+	lists, err := fanOut(ctx, r.shards, func(ctx context.Context, shard ports.Repository) ([]domain.Product, error) {
+		return shard.GetAllProducts(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergeSorted(lists, nil), nil
+}
+
+// EachProduct reads shards of ranges one after another when ordering by
+// id. Otherwise all shards are read at once and merged in sort order
+func (r *ShardedRepository) EachProduct(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, fn func(product domain.Product) error) error {
+	if r.starts != nil && len(sort) == 0 {
+		for _, shard := range r.shards {
+			if err := shard.EachProduct(ctx, filter, sort, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// readers are stopped before waiting for them
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	streams := make([]chan domain.Product, len(r.shards))
+	errs := make([]error, len(r.shards))
+	for i, shard := range r.shards {
+		streams[i] = make(chan domain.Product, shardStreamBuffer)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(streams[i])
+			errs[i] = shard.EachProduct(ctx, filter, sort, func(product domain.Product) error {
+				select {
+				case streams[i] <- product:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+		}()
+	}
+
+	heads := make([]*domain.Product, len(streams))
+	// receive reads next product of shard, error is set once shard is
+	// done, so it is safe to read after stream is closed
+	receive := func(i int) error {
+		product, ok := <-streams[i]
+		if !ok {
+			heads[i] = nil
+			return errs[i]
+		}
+		heads[i] = &product
+		return nil
+	}
+	for i := range streams {
+		if err := receive(i); err != nil {
+			return err
+		}
+	}
+	for {
+		lowest := -1
+		for i, head := range heads {
+			if head != nil && (lowest < 0 || sort.Compare(*head, *heads[lowest]) < 0) {
+				lowest = i
+			}
+		}
+		if lowest < 0 {
+			return nil
+		}
+		if err := fn(*heads[lowest]); err != nil {
+			return err
+		}
+		if err := receive(lowest); err != nil {
+			return err
+		}
+	}
+}
+
+// GetProductsPaged reads limit+offset products from every shard, so deep
+// pages cost more than on single database
+func (r *ShardedRepository) GetProductsPaged(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, limit int64, offset int64) ([]domain.Product, error) {
+	lists, err := fanOut(ctx, r.shards, func(ctx context.Context, shard ports.Repository) ([]domain.Product, error) {
+		return shard.GetProductsPaged(ctx, filter, sort, limit+offset, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page(mergeSorted(lists, sort), limit, offset), nil
+}
+
+func (r *ShardedRepository) GetProductsAfter(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, after *domain.Product, limit int64) ([]domain.Product, error) {
+	lists, err := fanOut(ctx, r.shards, func(ctx context.Context, shard ports.Repository) ([]domain.Product, error) {
+		return shard.GetProductsAfter(ctx, filter, sort, after, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page(mergeSorted(lists, sort), limit, 0), nil
+}
+
+func (r *ShardedRepository) ProductIdRange(ctx context.Context) (int64, int64, error) {
+	bounds, err := fanOut(ctx, r.shards, func(ctx context.Context, shard ports.Repository) ([2]int64, error) {
+		first, last, err := shard.ProductIdRange(ctx)
+		return [2]int64{first, last}, err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	var first, last int64
+	for _, b := range bounds {
+		// empty shards report zeros
+		if b[1] == 0 {
+			continue
+		}
+		if first == 0 || b[0] < first {
+			first = b[0]
+		}
+		last = max(last, b[1])
+	}
+	return first, last, nil
+}
+
+// GetProductsInRange only asks shards whose ranges overlap the one asked
+// for, or all shards under hash sharding
+func (r *ShardedRepository) GetProductsInRange(ctx context.Context, fromId int64, toId int64) ([]domain.Product, error) {
+	shards := r.shards
+	if r.starts != nil {
+		if toId <= fromId {
+			return []domain.Product{}, nil
+		}
+		shards = r.shards[r.ShardOf(fromId) : r.ShardOf(toId-1)+1]
+	}
+	lists, err := fanOut(ctx, shards, func(ctx context.Context, shard ports.Repository) ([]domain.Product, error) {
+		return shard.GetProductsInRange(ctx, fromId, toId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergeSorted(lists, nil), nil
+}
+
+func (r *ShardedRepository) CountProducts(ctx context.Context, filter domain.ProductFilter) (int64, error) {
+	counts, err := fanOut(ctx, r.shards, func(ctx context.Context, shard ports.Repository) (int64, error) {
+		return shard.CountProducts(ctx, filter)
+	})
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+	return total, nil
+}
+
+func (r *ShardedRepository) SuggestProductNames(ctx context.Context, prefix string, limit int64) ([]string, error) {
+	lists, err := fanOut(ctx, r.shards, func(ctx context.Context, shard ports.Repository) ([]string, error) {
+		return shard.SuggestProductNames(ctx, prefix, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	names := slices.Concat(lists...)
+	slices.Sort(names)
+	names = slices.Compact(names)
+	return page(names, limit, 0), nil
+}
+
+func (r *ShardedRepository) FuzzySearchProducts(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error) {
+	return r.search(ctx, query, ports.Repository.FuzzySearchProducts)
+}
+
+func (r *ShardedRepository) SearchProducts(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error) {
+	return r.search(ctx, query, ports.Repository.SearchProducts)
+}
+
+// search asks every shard for as many hits as the page needs and pages hits
+// merged by score
+func (r *ShardedRepository) search(ctx context.Context, query domain.SearchQuery, search func(ports.Repository, context.Context, domain.SearchQuery) (*domain.SearchResult, error)) (*domain.SearchResult, error) {
+	shardQuery := query
+	shardQuery.Limit, shardQuery.Offset = query.Limit+query.Offset, 0
+	results, err := fanOut(ctx, r.shards, func(ctx context.Context, shard ports.Repository) (*domain.SearchResult, error) {
+		return search(shard, ctx, shardQuery)
+	})
+	if err != nil {
+		return nil, err
+	}
+	merged := &domain.SearchResult{Hits: []domain.SearchHit{}}
+	for _, result := range results {
+		merged.Total += result.Total
+		merged.Hits = append(merged.Hits, result.Hits...)
+	}
+	slices.SortFunc(merged.Hits, func(a, b domain.SearchHit) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Product.Id, b.Product.Id))
+	})
+	merged.Hits = page(merged.Hits, query.Limit, query.Offset)
+	return merged, nil
+}
+
+// claimSkus fails with domain.ErrDuplicateSku if a product other than id,
+// on any shard, has one of skus. Skus stay locked until returned func is
+// called, after the write, so that writers of the same sku are checked one
+// after another. Locks are taken on the first shard, they hold for all
+// instances then
+func (r *ShardedRepository) claimSkus(ctx context.Context, id int64, skus ...string) (func(), error) {
+	skus = slices.DeleteFunc(slices.Clone(skus), func(sku string) bool { return sku == "" })
+	if len(skus) == 0 {
+		return func() {}, nil
+	}
+	unlock := r.skuMu.Unlock
+	if locker, ok := r.shards[0].(skuLocker); ok {
+		var err error
+		if unlock, err = locker.LockSkus(ctx, skus); err != nil {
+			return nil, err
+		}
+	} else {
+		r.skuMu.Lock()
+	}
+	for _, sku := range skus {
+		found, err := r.productsWithSku(ctx, sku)
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		for _, product := range found {
+			if product.Id != id {
+				unlock()
+				return nil, fmt.Errorf("%w: sku %q is used by product %d", domain.ErrDuplicateSku, sku, product.Id)
+			}
+		}
+	}
+	return unlock, nil
+}
+
+func (r *ShardedRepository) StoreProduct(ctx context.Context, product domain.NewProduct) (int64, error) {
+	unlock, err := r.claimSkus(ctx, 0, product.Sku)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	return r.shardForNew().StoreProduct(ctx, product)
+}
+
+func (r *ShardedRepository) StoreProducts(ctx context.Context, products []domain.NewProduct) ([]int64, error) {
+	skus := make([]string, len(products))
+	for i, product := range products {
+		skus[i] = product.Sku
+	}
+	unlock, err := r.claimSkus(ctx, 0, skus...)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return r.shardForNew().StoreProducts(ctx, products)
+}
+
+func (r *ShardedRepository) UpsertProduct(ctx context.Context, product domain.Product) (bool, error) {
+	unlock, err := r.claimSkus(ctx, product.Id, product.Sku)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	return r.shards[r.ShardOf(product.Id)].UpsertProduct(ctx, product)
+}
+
+func (r *ShardedRepository) UpdateProductById(ctx context.Context, id int64, product domain.NewProduct) (*domain.Product, error) {
+	unlock, err := r.claimSkus(ctx, id, product.Sku)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return r.shards[r.ShardOf(id)].UpdateProductById(ctx, id, product)
+}
+
+func (r *ShardedRepository) DeleteProductById(ctx context.Context, id int64) (*domain.Product, error) {
+	return r.shards[r.ShardOf(id)].DeleteProductById(ctx, id)
+}
+
+func (r *ShardedRepository) AddProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, error) {
+	return r.shards[r.ShardOf(id)].AddProductTags(ctx, id, tags)
+}
+
+func (r *ShardedRepository) RemoveProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, error) {
+	return r.shards[r.ShardOf(id)].RemoveProductTags(ctx, id, tags)
+}
+
+func (r *ShardedRepository) AdjustProductStock(ctx context.Context, id int64, delta int64) (*domain.Product, error) {
+	return r.shards[r.ShardOf(id)].AdjustProductStock(ctx, id, delta)
+}
+
+func (r *ShardedRepository) AddProductImage(ctx context.Context, id int64, image domain.ProductImage) (*domain.Product, error) {
+	return r.shards[r.ShardOf(id)].AddProductImage(ctx, id, image)
+}
+
+// ProductAudit reads audit trail from shard holding product, entries of
+// shards without one are empty
+func (r *ShardedRepository) ProductAudit(ctx context.Context, productId int64, before int64, limit int64) ([]domain.AuditEntry, error) {
+	trail, ok := r.shards[r.ShardOf(productId)].(ports.AuditTrail)
+	if !ok {
+		return []domain.AuditEntry{}, nil
+	}
+	return trail.ProductAudit(ctx, productId, before, limit)
+}
+
+func (r *ShardedRepository) DeleteAllProducts(ctx context.Context) (int64, error) {
+	counts, err := fanOut(ctx, r.shards, func(ctx context.Context, shard ports.Repository) (int64, error) {
+		return shard.DeleteAllProducts(ctx)
+	})
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+	return total, nil
+}
+
+// LockSkus takes advisory locks of skus on a connection held until returned
+// func is called. Locks are taken in one order, so that writers of
+// overlapping skus do not deadlock
+func (r *PostgresRepository) LockSkus(ctx context.Context, skus []string) (func(), error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, dbError(err, "failed to get connection")
+	}
+	// session locks outlive cancelled requests, connection must not go
+	// back to pool holding any
+	release := func() {
+		conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock_all()")
+		conn.Close()
+	}
+	_, err = conn.ExecContext(ctx,
+		"SELECT pg_advisory_lock($1, h) FROM (SELECT DISTINCT hashtext(sku) AS h FROM unnest($2::text[]) AS sku ORDER BY h) AS locks",
+		skuLockClass, pq.Array(skus))
+	if err != nil {
+		release()
+		return nil, dbError(err, "failed to lock skus")
+	}
+	return release, nil
+}
+
+// AlignIdSequence makes id sequence hand out only ids first + k*increment
+// above ids already in use, as IdSequence of sharded repository asks.
+// Aligning again is a no-op
+func (r *PostgresRepository) AlignIdSequence(ctx context.Context, first int64, increment int64) error {
+	if first < 1 || increment < 1 {
+		return fmt.Errorf("%w: invalid id sequence %d+k*%d", domain.ErrInvalidInput, first, increment)
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return dbError(err, "failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	var last int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT GREATEST(
+			(SELECT CASE WHEN is_called THEN last_value ELSE last_value - 1 END FROM products_id_seq),
+			(SELECT COALESCE(MAX(id), 0) FROM products))`).Scan(&last)
+	if err != nil {
+		return dbError(err, "failed to get last product id")
+	}
+	next := first
+	if last >= first {
+		next = first + ((last-first)/increment+1)*increment
+	}
+	// DDL takes no bind parameters, increment is a number
+	if _, err := tx.ExecContext(ctx, "ALTER SEQUENCE products_id_seq INCREMENT BY "+strconv.FormatInt(increment, 10)); err != nil {
+		return dbError(err, "failed to set id sequence increment")
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT setval('products_id_seq', $1, false)", next); err != nil {
+		return dbError(err, "failed to move id sequence")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return dbError(err, "failed to commit transaction")
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func product(id int64) domain.Product {
+	return domain.Product{Id: id, Name: "Product", AdditionalInfo: "Info"}
+}
+
+func TestHashShardedRepository(t *testing.T) {
+	ctx := context.Background()
+	// ids follow (id - 1) mod 3
+	shards := []*fakes.Repository{
+		fakes.NewRepository(product(1), product(4), product(7)),
+		fakes.NewRepository(product(2), product(5)),
+		fakes.NewRepository(product(3)),
+	}
+	repo := NewHashShardedRepository([]ports.Repository{shards[0], shards[1], shards[2]})
+
+	assert.Equal(t, 1, repo.ShardOf(5))
+	first, increment := repo.IdSequence(2)
+	assert.Equal(t, []int64{3, 3}, []int64{first, increment})
+
+	found, err := repo.GetProduct(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), found.Id)
+	_, err = repo.GetProduct(ctx, 0)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	all, err := repo.GetAllProducts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 7}, ids(all))
+
+	var streamed []domain.Product
+	err = repo.EachProduct(ctx, domain.ProductFilter{}, nil, func(p domain.Product) error {
+		streamed = append(streamed, p)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 7}, ids(streamed))
+
+	paged, err := repo.GetProductsPaged(ctx, domain.ProductFilter{}, nil, 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 5}, ids(paged))
+
+	inRange, err := repo.GetProductsInRange(ctx, 2, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 3, 4}, ids(inRange))
+
+	count, err := repo.CountProducts(ctx, domain.ProductFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), count)
+
+	low, high, err := repo.ProductIdRange(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 7}, []int64{low, high})
+
+	_, err = repo.UpdateProductById(ctx, 4, domain.NewProduct{Name: "Updated", AdditionalInfo: "Info"})
+	require.NoError(t, err)
+	assert.Equal(t, "Updated", shards[0].Products()[1].Name)
+
+	created, err := repo.UpsertProduct(ctx, product(6))
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Len(t, shards[2].Products(), 2, "upserted product goes to shard of its id")
+
+	// new products are spread round-robin
+	for range 3 {
+		_, err := repo.StoreProduct(ctx, domain.NewProduct{Name: "New", AdditionalInfo: "Info"})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []int{4, 3, 3}, []int{len(shards[0].Products()), len(shards[1].Products()), len(shards[2].Products())})
+
+	deleted, err := repo.DeleteAllProducts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), deleted)
+}
+
+func TestShardedRepositorySort(t *testing.T) {
+	ctx := context.Background()
+	shards := []*fakes.Repository{
+		fakes.NewRepository(
+			domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Info"},
+			domain.Product{Id: 3, Name: "Bed", AdditionalInfo: "Info"},
+		),
+		fakes.NewRepository(
+			domain.Product{Id: 2, Name: "Chair", AdditionalInfo: "Info"},
+			domain.Product{Id: 4, Name: "Armchair", AdditionalInfo: "Info"},
+		),
+	}
+	// ranges are merged too unless sorted by id
+	ranged, err := NewRangeShardedRepository([]ports.Repository{shards[0], shards[1]}, []int64{1, 2})
+	require.NoError(t, err)
+	for _, repo := range []*ShardedRepository{NewHashShardedRepository([]ports.Repository{shards[0], shards[1]}), ranged} {
+		sort := domain.ProductSort{{Field: "name", Desc: true}}
+		paged, err := repo.GetProductsPaged(ctx, domain.ProductFilter{}, sort, 2, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{2, 3}, ids(paged))
+
+		var streamed []domain.Product
+		err = repo.EachProduct(ctx, domain.ProductFilter{}, sort, func(p domain.Product) error {
+			streamed = append(streamed, p)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{1, 2, 3, 4}, ids(streamed))
+
+		after, err := repo.GetProductsAfter(ctx, domain.ProductFilter{}, sort, &paged[0], 2)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3, 4}, ids(after))
+
+		err = repo.EachProduct(ctx, domain.ProductFilter{}, domain.ProductSort{{Field: "price"}}, func(p domain.Product) error { return nil })
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	}
+}
+
+func TestRangeShardedRepository(t *testing.T) {
+	ctx := context.Background()
+	_, err := NewRangeShardedRepository([]ports.Repository{fakes.NewRepository(), fakes.NewRepository()}, []int64{1})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	_, err = NewRangeShardedRepository([]ports.Repository{fakes.NewRepository(), fakes.NewRepository()}, []int64{100, 1})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+
+	shards := []*fakes.Repository{
+		fakes.NewRepository(product(1), product(99)),
+		fakes.NewRepository(product(100), product(150)),
+		fakes.NewRepository(),
+	}
+	repo, err := NewRangeShardedRepository([]ports.Repository{shards[0], shards[1], shards[2]}, []int64{1, 100, 200})
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, repo.ShardOf(99))
+	assert.Equal(t, 1, repo.ShardOf(100))
+	assert.Equal(t, 2, repo.ShardOf(5000))
+	first, increment := repo.IdSequence(2)
+	assert.Equal(t, []int64{200, 1}, []int64{first, increment})
+
+	shards[2].FailWith("GetProductsInRange", domain.ErrInternalDb)
+	inRange, err := repo.GetProductsInRange(ctx, 50, 120)
+	require.NoError(t, err, "shards outside of range are not asked")
+	assert.Equal(t, []int64{99, 100}, ids(inRange))
+	shards[2].FailWith("GetProductsInRange", nil)
+
+	var streamed []domain.Product
+	err = repo.EachProduct(ctx, domain.ProductFilter{}, nil, func(p domain.Product) error {
+		streamed = append(streamed, p)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 99, 100, 150}, ids(streamed))
+
+	_, err = repo.StoreProducts(ctx, []domain.NewProduct{{Name: "New", AdditionalInfo: "Info"}, {Name: "Newer", AdditionalInfo: "Info"}})
+	require.NoError(t, err)
+	assert.Len(t, shards[2].Products(), 2, "new products go to the last shard")
+
+	low, high, err := repo.ProductIdRange(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), low)
+	assert.Positive(t, high)
+}
+
+func TestShardedRepositoryErrors(t *testing.T) {
+	ctx := context.Background()
+	shards := []*fakes.Repository{
+		fakes.NewRepository(product(1), product(3), product(5)),
+		fakes.NewRepository(product(2), product(4)),
+	}
+	repo := NewHashShardedRepository([]ports.Repository{shards[0], shards[1]})
+
+	stop := errors.New("stop")
+	var seen []int64
+	err := repo.EachProduct(ctx, domain.ProductFilter{}, nil, func(p domain.Product) error {
+		seen = append(seen, p.Id)
+		if p.Id == 3 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, []int64{1, 2, 3}, seen)
+
+	shards[1].FailWith("EachProduct", domain.ErrInternalDb)
+	err = repo.EachProduct(ctx, domain.ProductFilter{}, nil, func(p domain.Product) error { return nil })
+	assert.ErrorIs(t, err, domain.ErrInternalDb)
+
+	shards[1].FailWith("CountProducts", domain.ErrInternalDb)
+	_, err = repo.CountProducts(ctx, domain.ProductFilter{})
+	assert.ErrorIs(t, err, domain.ErrInternalDb, "failure of any shard fails fan-out")
+}
+
+func TestShardedRepositorySkus(t *testing.T) {
+	ctx := context.Background()
+	withSku := func(id int64, sku string) domain.Product {
+		p := product(id)
+		p.Sku = sku
+		return p
+	}
+	shards := []*fakes.Repository{
+		fakes.NewRepository(withSku(1, "LMP-1")),
+		fakes.NewRepository(withSku(2, "DSK-1")),
+	}
+	repo := NewHashShardedRepository([]ports.Repository{shards[0], shards[1]})
+
+	found, err := repo.GetProductBySku(ctx, "DSK-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), found.Id)
+
+	_, err = repo.StoreProduct(ctx, domain.NewProduct{Name: "Lamp", AdditionalInfo: "Info", Sku: "DSK-1"})
+	assert.ErrorIs(t, err, domain.ErrDuplicateSku, "sku of another shard")
+	_, err = repo.StoreProducts(ctx, []domain.NewProduct{{Name: "Lamp", AdditionalInfo: "Info", Sku: "NEW-1"}, {Name: "Desk", AdditionalInfo: "Info", Sku: "LMP-1"}})
+	assert.ErrorIs(t, err, domain.ErrDuplicateSku)
+	_, err = repo.UpdateProductById(ctx, 1, domain.NewProduct{Name: "Product", AdditionalInfo: "Info", Sku: "DSK-1"})
+	assert.ErrorIs(t, err, domain.ErrDuplicateSku)
+	_, err = repo.UpsertProduct(ctx, withSku(3, "LMP-1"))
+	assert.ErrorIs(t, err, domain.ErrDuplicateSku)
+	assert.Len(t, shards[0].Products(), 1)
+	assert.Len(t, shards[1].Products(), 1)
+
+	_, err = repo.UpdateProductById(ctx, 2, domain.NewProduct{Name: "Desk", AdditionalInfo: "Info", Sku: "DSK-1"})
+	require.NoError(t, err, "product keeps its own sku")
+	id, err := repo.StoreProduct(ctx, domain.NewProduct{Name: "Chair", AdditionalInfo: "Info", Sku: "CHR-1"})
+	require.NoError(t, err)
+	_, err = repo.UpsertProduct(ctx, withSku(id, "CHR-2"))
+	require.NoError(t, err)
+
+	shards[1].FailWith("GetProductBySku", domain.ErrInternalDb)
+	_, err = repo.StoreProduct(ctx, domain.NewProduct{Name: "Shelf", AdditionalInfo: "Info", Sku: "SHF-1"})
+	assert.ErrorIs(t, err, domain.ErrInternalDb, "sku must not be stored unchecked")
+	_, err = repo.StoreProduct(ctx, domain.NewProduct{Name: "Shelf", AdditionalInfo: "Info"})
+	assert.NoError(t, err, "products without sku need no check")
+}
+
+func TestParseShardRanges(t *testing.T) {
+	starts, err := ParseShardRanges("1, 1000000,2000000")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 1000000, 2000000}, starts)
+
+	for _, s := range []string{"", "1,,2", "0", "1,a"} {
+		_, err := ParseShardRanges(s)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput, s)
+	}
+}
+
+func ids(products []domain.Product) []int64 {
+	result := make([]int64, len(products))
+	for i, p := range products {
+		result[i] = p.Id
+	}
+	return result
+}
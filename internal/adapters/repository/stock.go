@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// AdjustProductStock adds delta to stock of product in a single statement,
+// which does not take stock below zero. Concurrent adjustments queue up on
+// the row rather than read stale stock, so one can not oversell
+func (r *PostgresRepository) AdjustProductStock(ctx context.Context, id int64, delta int64) (_ *domain.Product, err error) {
+	defer r.observe("adjust_product_stock", &err)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, dbError(err, "failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	var after domain.Product
+	err = tx.QueryRowContext(ctx,
+		`UPDATE products SET stock = stock + $2, version = version + 1, updated_at = now()
+		WHERE id = $1 AND stock + $2 >= 0
+		RETURNING id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, `+imagesColumn,
+		id, delta).
+		Scan(&after.Id, &after.Name, &after.AdditionalInfo, &after.Version, &after.CategoryId, &after.Sku, &after.Stock, pq.Array(&after.Tags), (*images)(&after.Images))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, r.adjustMissed(ctx, tx, id, delta)
+		}
+		return nil, dbError(err, "failed to adjust stock of product %d", id)
+	}
+	before := after
+	before.Stock, before.Version = after.Stock-delta, after.Version-1
+	if err := r.writeOutbox(tx, domain.EventProductUpdated, opUpdate, id, &before, &after); err != nil {
+		return nil, err
+	}
+	if err := r.writeAudit(ctx, tx, domain.AuditUpdate, id, &before, &after); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, dbError(err, "failed to commit transaction")
+	}
+	return &after, nil
+}
+
+// adjustMissed tells why stock adjustment changed no row
+func (r *PostgresRepository) adjustMissed(ctx context.Context, tx *sql.Tx, id int64, delta int64) error {
+	var stock int64
+	err := tx.QueryRowContext(ctx, "SELECT stock FROM products WHERE id = $1", id).Scan(&stock)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: failed to find product %d in DB", domain.ErrNotFound, id)
+	case err != nil:
+		return dbError(err, "failed to get stock of product %d", id)
+	}
+	return fmt.Errorf("%w: product %d has %d in stock, can not take %d", domain.ErrInsufficientStock, id, stock, -delta)
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/lib/pq"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func (r *PostgresRepository) AddProductTags(ctx context.Context, id int64, tags []string) (_ *domain.Product, err error) {
+	defer r.observe("add_product_tags", &err)
+	return r.retagProduct(ctx, id, func(current []string) []string {
+		return domain.AddTags(current, tags)
+	})
+}
+
+func (r *PostgresRepository) RemoveProductTags(ctx context.Context, id int64, tags []string) (_ *domain.Product, err error) {
+	defer r.observe("remove_product_tags", &err)
+	return r.retagProduct(ctx, id, func(current []string) []string {
+		return domain.RemoveTags(current, tags)
+	})
+}
+
+// retagProduct replaces tags of product with what retag makes of them, with
+// the row locked so that concurrent tagging is not lost. Unchanged tags are
+// not written
+func (r *PostgresRepository) retagProduct(ctx context.Context, id int64, retag func(current []string) []string) (*domain.Product, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, dbError(err, "failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	var before domain.Product
+	err = tx.QueryRowContext(ctx, "SELECT id, name, additional_info, version, COALESCE(category_id, 0), COALESCE(sku, ''), stock, tags, "+imagesColumn+" FROM products WHERE id = $1 FOR UPDATE", id).
+		Scan(&before.Id, &before.Name, &before.AdditionalInfo, &before.Version, &before.CategoryId, &before.Sku, &before.Stock, pq.Array(&before.Tags), (*images)(&before.Images))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: failed to find product %d in DB", domain.ErrNotFound, id)
+		}
+		return nil, dbError(err, "failed to get product %d", id)
+	}
+	after := before
+	after.Tags = retag(before.Tags)
+	if slices.Equal(before.Tags, after.Tags) {
+		return &before, nil
+	}
+
+	err = tx.QueryRowContext(ctx,
+		"UPDATE products SET tags = $2, version = version + 1, updated_at = now() WHERE id = $1 RETURNING version",
+		id, pq.Array(after.Tags)).Scan(&after.Version)
+	if err != nil {
+		return nil, dbError(err, "failed to tag product %d", id)
+	}
+	if err := r.writeOutbox(tx, domain.EventProductUpdated, opUpdate, id, &before, &after); err != nil {
+		return nil, err
+	}
+	if err := r.writeAudit(ctx, tx, domain.AuditUpdate, id, &before, &after); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, dbError(err, "failed to commit transaction")
+	}
+	return &after, nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// AddUsage adds to usage already recorded for the same client and day
+func (r *PostgresRepository) AddUsage(ctx context.Context, usage []domain.DailyUsage) (err error) {
+	defer r.observe("add_usage", &err)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return dbError(err, "failed to start transaction")
+	}
+	defer tx.Rollback()
+	for _, u := range usage {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO api_usage (client, day, requests, errors, bytes_in, bytes_out)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (client, day) DO UPDATE SET
+				requests = api_usage.requests + EXCLUDED.requests,
+				errors = api_usage.errors + EXCLUDED.errors,
+				bytes_in = api_usage.bytes_in + EXCLUDED.bytes_in,
+				bytes_out = api_usage.bytes_out + EXCLUDED.bytes_out`,
+			u.Client, u.Day.UTC().Format(time.DateOnly), u.Requests, u.Errors, u.BytesIn, u.BytesOut)
+		if err != nil {
+			return dbError(err, "failed to record usage")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return dbError(err, "failed to commit transaction")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetUsage(ctx context.Context, from time.Time, to time.Time) (_ []domain.Usage, err error) {
+	defer r.observe("get_usage", &err)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT client, SUM(requests), SUM(errors), SUM(bytes_in), SUM(bytes_out)
+		FROM api_usage
+		WHERE day BETWEEN $1 AND $2
+		GROUP BY client
+		ORDER BY SUM(requests) DESC, client`,
+		from.UTC().Format(time.DateOnly), to.UTC().Format(time.DateOnly))
+	if err != nil {
+		return nil, dbError(err, "failed to query usage")
+	}
+	defer rows.Close()
+	usage := []domain.Usage{}
+	for rows.Next() {
+		var u domain.Usage
+		if err := rows.Scan(&u.Client, &u.Requests, &u.Errors, &u.BytesIn, &u.BytesOut); err != nil {
+			return nil, dbError(err, "failed to scan usage")
+		}
+		usage = append(usage, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "failed to read usage")
+	}
+	return usage, nil
+}
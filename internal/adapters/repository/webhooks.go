@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func (r *PostgresRepository) CreateWebhook(ctx context.Context, webhook domain.Webhook) (_ domain.Webhook, err error) {
+	defer r.observe("create_webhook", &err)
+	err = r.db.QueryRowContext(ctx,
+		`INSERT INTO webhooks (url, secret, event_types) VALUES ($1, $2, $3) RETURNING id, created_at`,
+		webhook.URL, webhook.Secret, pq.Array(webhook.EventTypes)).Scan(&webhook.Id, &webhook.CreatedAt)
+	if err != nil {
+		return domain.Webhook{}, dbError(err, "failed to create webhook")
+	}
+	return webhook, nil
+}
+
+func (r *PostgresRepository) ListWebhooks(ctx context.Context) (_ []domain.Webhook, err error) {
+	defer r.observe("list_webhooks", &err)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, url, secret, event_types, created_at FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, dbError(err, "failed to list webhooks")
+	}
+	defer rows.Close()
+
+	webhooks := []domain.Webhook{}
+	for rows.Next() {
+		var webhook domain.Webhook
+		if err := rows.Scan(&webhook.Id, &webhook.URL, &webhook.Secret, pq.Array(&webhook.EventTypes), &webhook.CreatedAt); err != nil {
+			return nil, dbError(err, "failed to convert row into go type")
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error while iterating over rows")
+	}
+	return webhooks, nil
+}
+
+func (r *PostgresRepository) DeleteWebhook(ctx context.Context, id int64) (err error) {
+	defer r.observe("delete_webhook", &err)
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return dbError(err, "failed to delete webhook %d", id)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return dbError(err, "failed to delete webhook %d", id)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: failed to find webhook %d in DB", domain.ErrNotFound, id)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) EnqueueWebhookDeliveries(ctx context.Context, event domain.ProductEvent, payload []byte) (_ int, err error) {
+	defer r.observe("enqueue_webhook_deliveries", &err)
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		SELECT id, $1::uuid, $2::text, $3::jsonb FROM webhooks WHERE $2::text = ANY(event_types)`,
+		event.Id, event.Type, payload)
+	if err != nil {
+		return 0, dbError(err, "failed to enqueue webhook deliveries of event %s", event.Id)
+	}
+	queued, err := result.RowsAffected()
+	if err != nil {
+		return 0, dbError(err, "failed to enqueue webhook deliveries of event %s", event.Id)
+	}
+	return int(queued), nil
+}
+
+// ClaimWebhookDeliveries skips deliveries locked by concurrent claims, so
+// instances dispatching at the same time claim different ones
+func (r *PostgresRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) (_ []domain.WebhookDelivery, err error) {
+	defer r.observe("claim_webhook_deliveries", &err)
+	rows, err := r.db.QueryContext(ctx,
+		`UPDATE webhook_deliveries d SET attempts = d.attempts + 1, next_attempt_at = now() + $2::float8 * interval '1 millisecond'
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE failed_at IS NULL AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, id LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING d.id, d.webhook_id, w.url, w.secret, d.event_id, d.event_type, d.payload, d.attempts, COALESCE(d.payload->>'traceparent', '')`,
+		limit, lease.Milliseconds())
+	if err != nil {
+		return nil, dbError(err, "failed to claim webhook deliveries")
+	}
+	defer rows.Close()
+
+	deliveries := []domain.WebhookDelivery{}
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		if err := rows.Scan(&d.Id, &d.WebhookId, &d.URL, &d.Secret, &d.EventId, &d.EventType, &d.Payload, &d.Attempts, &d.Traceparent); err != nil {
+			return nil, dbError(err, "failed to convert row into go type")
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error while iterating over rows")
+	}
+	return deliveries, nil
+}
+
+func (r *PostgresRepository) CompleteWebhookDelivery(ctx context.Context, id int64) (err error) {
+	defer r.observe("complete_webhook_delivery", &err)
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE id = $1`, id); err != nil {
+		return dbError(err, "failed to complete webhook delivery %d", id)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) RetryWebhookDelivery(ctx context.Context, id int64, delay time.Duration, lastError string) (err error) {
+	defer r.observe("retry_webhook_delivery", &err)
+	_, err = r.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at = now() + $2::float8 * interval '1 millisecond', last_error = $3 WHERE id = $1`,
+		id, delay.Milliseconds(), lastError)
+	if err != nil {
+		return dbError(err, "failed to reschedule webhook delivery %d", id)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) FailWebhookDelivery(ctx context.Context, id int64, lastError string) (err error) {
+	defer r.observe("fail_webhook_delivery", &err)
+	_, err = r.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET failed_at = now(), last_error = $2 WHERE id = $1`, id, lastError)
+	if err != nil {
+		return dbError(err, "failed to fail webhook delivery %d", id)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// facetFields maps public facet names to keyword fields of the index
+var facetFields = map[string]string{
+	"name": "name.keyword",
+}
+
+// ElasticsearchIndex talks to Elasticsearch or OpenSearch over plain REST API,
+// relying only on endpoints both of them share
+type ElasticsearchIndex struct {
+	baseURL    string
+	index      string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+func NewElasticsearchIndex(httpClient *http.Client, baseURL string, index string, username string, password string) *ElasticsearchIndex {
+	return &ElasticsearchIndex{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		index:      index,
+		username:   username,
+		password:   password,
+		httpClient: httpClient,
+	}
+}
+
+// EnsureIndex creates index with explicit mapping if it does not exist yet.
+// Only mapped fields are searched, the rest of product is kept in source so
+// that hits come back as whole products
+func (e *ElasticsearchIndex) EnsureIndex(ctx context.Context) error {
+	status, _, err := e.request(ctx, http.MethodHead, "/"+e.index, nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusOK {
+		return nil
+	}
+	mapping := map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"dynamic": false,
+			"properties": map[string]interface{}{
+				"id": map[string]string{"type": "long"},
+				"name": map[string]interface{}{
+					"type": "text",
+					"fields": map[string]interface{}{
+						"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256},
+					},
+				},
+				"additionalInfo": map[string]string{"type": "text"},
+			},
+		},
+	}
+	status, body, err := e.request(ctx, http.MethodPut, "/"+e.index, mapping)
+	if err != nil {
+		return err
+	}
+	if status >= http.StatusBadRequest {
+		return fmt.Errorf("%w: failed to create index %s: status %d: %s", domain.ErrInternalIndex, e.index, status, body)
+	}
+	return nil
+}
+
+func (e *ElasticsearchIndex) Index(ctx context.Context, product *domain.Product) error {
+	status, body, err := e.request(ctx, http.MethodPut, fmt.Sprintf("/%s/_doc/%d", e.index, product.Id), product)
+	if err != nil {
+		return err
+	}
+	if status >= http.StatusBadRequest {
+		return fmt.Errorf("%w: failed to index product %d: status %d: %s", domain.ErrInternalIndex, product.Id, status, body)
+	}
+	return nil
+}
+
+func (e *ElasticsearchIndex) Delete(ctx context.Context, id int64) error {
+	status, body, err := e.request(ctx, http.MethodDelete, fmt.Sprintf("/%s/_doc/%d", e.index, id), nil)
+	if err != nil {
+		return err
+	}
+	// missing document is fine, the goal is to have it absent from index
+	if status >= http.StatusBadRequest && status != http.StatusNotFound {
+		return fmt.Errorf("%w: failed to delete product %d from index: status %d: %s", domain.ErrInternalIndex, id, status, body)
+	}
+	return nil
+}
+
+func (e *ElasticsearchIndex) DeleteAll(ctx context.Context) error {
+	query := map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}}
+	status, body, err := e.request(ctx, http.MethodPost, fmt.Sprintf("/%s/_delete_by_query?conflicts=proceed", e.index), query)
+	if err != nil {
+		return err
+	}
+	if status >= http.StatusBadRequest {
+		return fmt.Errorf("%w: failed to clear index: status %d: %s", domain.ErrInternalIndex, status, body)
+	}
+	return nil
+}
+
+type searchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			Id        string              `json:"_id"`
+			Score     float64             `json:"_score"`
+			Source    domain.Product      `json:"_source"`
+			Highlight map[string][]string `json:"highlight"`
+		} `json:"hits"`
+	} `json:"hits"`
+	Aggregations map[string]struct {
+		Buckets []struct {
+			Key      interface{} `json:"key"`
+			DocCount int64       `json:"doc_count"`
+		} `json:"buckets"`
+	} `json:"aggregations"`
+}
+
+// Query runs relevance-ranked full-text search over name (boosted) and additional info
+func (e *ElasticsearchIndex) Query(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error) {
+	aggs := map[string]interface{}{}
+	for _, facet := range query.Facets {
+		field, ok := facetFields[facet]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown facet %q", domain.ErrInvalidInput, facet)
+		}
+		aggs[facet] = map[string]interface{}{"terms": map[string]interface{}{"field": field, "size": 20}}
+	}
+	body := map[string]interface{}{
+		"from":             query.Offset,
+		"size":             query.Limit,
+		"track_total_hits": true,
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":     query.Text,
+				"fields":    []string{"name^2", "additionalInfo"},
+				"fuzziness": "AUTO",
+			},
+		},
+		"highlight": map[string]interface{}{
+			"fields": map[string]interface{}{
+				"name":           map[string]interface{}{},
+				"additionalInfo": map[string]interface{}{},
+			},
+		},
+	}
+	if len(aggs) > 0 {
+		body["aggs"] = aggs
+	}
+
+	status, respBody, err := e.request(ctx, http.MethodPost, fmt.Sprintf("/%s/_search", e.index), body)
+	if err != nil {
+		return nil, err
+	}
+	if status >= http.StatusBadRequest {
+		return nil, fmt.Errorf("%w: search failed: status %d: %s", domain.ErrInternalIndex, status, respBody)
+	}
+	var resp searchResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode search response: %s", domain.ErrInternalIndex, err.Error())
+	}
+
+	result := &domain.SearchResult{
+		Total: resp.Hits.Total.Value,
+		Hits:  make([]domain.SearchHit, 0, len(resp.Hits.Hits)),
+	}
+	for _, hit := range resp.Hits.Hits {
+		product := hit.Source
+		if product.Id == 0 {
+			product.Id, _ = strconv.ParseInt(hit.Id, 10, 64)
+		}
+		result.Hits = append(result.Hits, domain.SearchHit{
+			Product:    product,
+			Score:      hit.Score,
+			Highlights: hit.Highlight,
+		})
+	}
+	if len(resp.Aggregations) > 0 {
+		result.Facets = make(map[string][]domain.FacetBucket, len(resp.Aggregations))
+		for name, agg := range resp.Aggregations {
+			buckets := make([]domain.FacetBucket, 0, len(agg.Buckets))
+			for _, b := range agg.Buckets {
+				buckets = append(buckets, domain.FacetBucket{Value: fmt.Sprint(b.Key), Count: b.DocCount})
+			}
+			result.Facets[name] = buckets
+		}
+	}
+	return result, nil
+}
+
+func (e *ElasticsearchIndex) request(ctx context.Context, method string, path string, payload interface{}) (int, []byte, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return 0, nil, fmt.Errorf("%w: error marshalling request: %s", domain.ErrInternalIndex, err.Error())
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, e.baseURL+path, body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: failed to build request: %s", domain.ErrInternalIndex, err.Error())
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if tc, ok := domain.TraceContextFromContext(ctx); ok {
+		for key, value := range tc.TraceHeaders() {
+			req.Header.Set(key, value)
+		}
+	}
+	if e.username != "" {
+		req.SetBasicAuth(e.username, e.password)
+	}
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %s %s failed: %s", domain.ErrInternalIndex, method, path, err.Error())
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: failed to read response: %s", domain.ErrInternalIndex, err.Error())
+	}
+	return resp.StatusCode, respBody, nil
+}
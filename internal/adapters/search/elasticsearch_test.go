@@ -0,0 +1,161 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type recordedRequest struct {
+	method string
+	path   string
+	body   map[string]interface{}
+}
+
+func newFakeElasticsearch(t *testing.T, status int, response string) (*httptest.Server, *[]recordedRequest) {
+	requests := &[]recordedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := recordedRequest{method: r.Method, path: r.URL.Path}
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if len(data) > 0 {
+			require.NoError(t, json.Unmarshal(data, &rec.body))
+		}
+		*requests = append(*requests, rec)
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestQuery(t *testing.T) {
+	response := `{
+		"hits": {
+			"total": {"value": 1},
+			"hits": [{
+				"_id": "7",
+				"_score": 1.5,
+				"_source": {"id": 7, "name": "Red shoes", "additionalInfo": "Leather", "version": 2, "tags": ["sale"]},
+				"highlight": {"name": ["<em>Red</em> shoes"]}
+			}]
+		},
+		"aggregations": {
+			"name": {"buckets": [{"key": "Red shoes", "doc_count": 1}]}
+		}
+	}`
+	server, requests := newFakeElasticsearch(t, http.StatusOK, response)
+	index := NewElasticsearchIndex(server.Client(), server.URL, "products", "", "")
+
+	result, err := index.Query(context.Background(), domain.SearchQuery{Text: "red", Limit: 10, Facets: []string{"name"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), result.Total)
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, domain.Product{Id: 7, Name: "Red shoes", AdditionalInfo: "Leather", Version: 2, Tags: []string{"sale"}}, result.Hits[0].Product)
+	assert.Equal(t, []string{"<em>Red</em> shoes"}, result.Hits[0].Highlights["name"])
+	assert.Equal(t, []domain.FacetBucket{{Value: "Red shoes", Count: 1}}, result.Facets["name"])
+
+	require.Len(t, *requests, 1)
+	req := (*requests)[0]
+	assert.Equal(t, "/products/_search", req.path)
+	assert.Contains(t, req.body, "aggs")
+	assert.Equal(t, float64(10), req.body["size"])
+}
+
+func TestQueryUnknownFacet(t *testing.T) {
+	server, requests := newFakeElasticsearch(t, http.StatusOK, `{}`)
+	index := NewElasticsearchIndex(server.Client(), server.URL, "products", "", "")
+
+	_, err := index.Query(context.Background(), domain.SearchQuery{Text: "red", Limit: 10, Facets: []string{"price"}})
+	assert.True(t, errors.Is(err, domain.ErrInvalidInput))
+	assert.Empty(t, *requests)
+}
+
+func TestIndexWrites(t *testing.T) {
+	product := &domain.Product{Id: 3, Name: "Product", AdditionalInfo: "Info", Version: 2, Sku: "P-3", Tags: []string{"sale"}}
+	testCases := []struct {
+		name           string
+		write          func(index *ElasticsearchIndex) error
+		status         int
+		expectedMethod string
+		expectedPath   string
+		expectedError  error
+	}{
+		{
+			name:           "indexed",
+			write:          func(index *ElasticsearchIndex) error { return index.Index(context.Background(), product) },
+			status:         http.StatusCreated,
+			expectedMethod: http.MethodPut,
+			expectedPath:   "/products/_doc/3",
+		},
+		{
+			name:           "removed from index",
+			write:          func(index *ElasticsearchIndex) error { return index.Delete(context.Background(), 3) },
+			status:         http.StatusOK,
+			expectedMethod: http.MethodDelete,
+			expectedPath:   "/products/_doc/3",
+		},
+		{
+			name:           "missing document is fine",
+			write:          func(index *ElasticsearchIndex) error { return index.Delete(context.Background(), 3) },
+			status:         http.StatusNotFound,
+			expectedMethod: http.MethodDelete,
+			expectedPath:   "/products/_doc/3",
+		},
+		{
+			name:           "index cleared",
+			write:          func(index *ElasticsearchIndex) error { return index.DeleteAll(context.Background()) },
+			status:         http.StatusOK,
+			expectedMethod: http.MethodPost,
+			expectedPath:   "/products/_delete_by_query",
+		},
+		{
+			name:           "index unavailable",
+			write:          func(index *ElasticsearchIndex) error { return index.Index(context.Background(), product) },
+			status:         http.StatusServiceUnavailable,
+			expectedMethod: http.MethodPut,
+			expectedPath:   "/products/_doc/3",
+			expectedError:  domain.ErrInternalIndex,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, requests := newFakeElasticsearch(t, tc.status, `{}`)
+
+			err := tc.write(NewElasticsearchIndex(server.Client(), server.URL, "products", "", ""))
+			if tc.expectedError != nil {
+				assert.True(t, errors.Is(err, tc.expectedError))
+			} else {
+				assert.NoError(t, err)
+			}
+			require.Len(t, *requests, 1)
+			assert.Equal(t, tc.expectedMethod, (*requests)[0].method)
+			assert.Equal(t, tc.expectedPath, (*requests)[0].path)
+		})
+	}
+}
+
+func TestIndexKeepsWholeProduct(t *testing.T) {
+	server, requests := newFakeElasticsearch(t, http.StatusOK, `{}`)
+	index := NewElasticsearchIndex(server.Client(), server.URL, "products", "", "")
+
+	product := domain.Product{Id: 3, Name: "Product", AdditionalInfo: "Info", Version: 2, Sku: "P-3", Stock: 4, Tags: []string{"sale"}}
+	require.NoError(t, index.Index(context.Background(), &product))
+	require.Len(t, *requests, 1)
+	source, err := json.Marshal((*requests)[0].body)
+	require.NoError(t, err)
+	var indexed domain.Product
+	require.NoError(t, json.Unmarshal(source, &indexed))
+	assert.Equal(t, product, indexed)
+}
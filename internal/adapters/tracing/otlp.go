@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewTracerProvider exports spans in batches to OTLP collector listening for
+// gRPC at endpoint, e.g. "http://otel-collector:4317". Plain http scheme
+// disables TLS. Shutting provider down flushes spans not exported yet
+func NewTracerProvider(ctx context.Context, endpoint string, serviceName string, sampleRatio float64) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+		sdktrace.WithSampler(NewSampler(sampleRatio)),
+	), nil
+}
+
+// NewSampler follows sampling decision of caller. Traces are only started
+// by requests: a server span without parent is sampled at ratio, other
+// spans without parent, like those of health probes or background jobs,
+// are dropped so that they do not flood the collector
+func NewSampler(ratio float64) sdktrace.Sampler {
+	return sdktrace.ParentBased(rootSampler{ratio: sdktrace.TraceIDRatioBased(ratio)})
+}
+
+type rootSampler struct {
+	ratio sdktrace.Sampler
+}
+
+func (s rootSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if p.Kind != trace.SpanKindServer {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.Drop,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.ratio.ShouldSample(p)
+}
+
+func (s rootSampler) Description() string {
+	return "ServerRoots{" + s.ratio.Description() + "}"
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSampler(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder), sdktrace.WithSampler(NewSampler(1)))
+	tracer := provider.Tracer("test")
+	ctx := context.Background()
+
+	ctx, request := tracer.Start(ctx, "GET /product/{id}", trace.WithSpanKind(trace.SpanKindServer))
+	_, call := tracer.Start(ctx, "ResourseService.GetProductById")
+	call.End()
+	request.End()
+
+	_, probe := tracer.Start(context.Background(), "ping")
+	assert.True(t, probe.SpanContext().IsValid(), "dropped spans still have ids")
+	probe.End()
+
+	remote := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+		Remote:  true,
+	})
+	_, unsampled := tracer.Start(trace.ContextWithRemoteSpanContext(context.Background(), remote), "GET /products",
+		trace.WithSpanKind(trace.SpanKindServer))
+	unsampled.End()
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		names = append(names, span.Name())
+	}
+	assert.Equal(t, []string{"ResourseService.GetProductById", "GET /product/{id}"}, names,
+		"roots other than server spans and traces caller did not sample are dropped")
+
+	none := tracetest.NewSpanRecorder()
+	tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(none), sdktrace.WithSampler(NewSampler(0))).Tracer("test")
+	_, request = tracer.Start(context.Background(), "GET /products", trace.WithSpanKind(trace.SpanKindServer))
+	request.End()
+	assert.Empty(t, none.Ended())
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Limits are CPU and memory a container is allowed to use, zero if there
+// is no limit or it could not be detected
+type Limits struct {
+	CPUs        float64
+	MemoryBytes int64
+}
+
+// DetectLimits reads limits of cgroup the process belongs to, both cgroup
+// v2 and v1 are supported
+func DetectLimits() Limits {
+	return detectLimits(os.DirFS("/"))
+}
+
+func detectLimits(root fs.FS) Limits {
+	groups := processCgroups(root)
+	if group, ok := groups[""]; ok {
+		if file, ok := cgroupFile(root, "sys/fs/cgroup", group, "cpu.max"); ok {
+			dir := path.Dir(file)
+			return Limits{
+				CPUs:        cgroupV2CPUs(root, file),
+				MemoryBytes: readLimit(root, path.Join(dir, "memory.max")),
+			}
+		}
+	}
+
+	var limits Limits
+	for _, mount := range []string{"cpu,cpuacct", "cpu"} {
+		quota, ok := cgroupFile(root, "sys/fs/cgroup/"+mount, groups["cpu"], "cpu.cfs_quota_us")
+		if ok {
+			limits.CPUs = quotaCPUs(readTrimmed(root, quota), readTrimmed(root, path.Join(path.Dir(quota), "cpu.cfs_period_us")))
+			break
+		}
+	}
+	if file, ok := cgroupFile(root, "sys/fs/cgroup/memory", groups["memory"], "memory.limit_in_bytes"); ok {
+		limits.MemoryBytes = readLimit(root, file)
+	}
+	return limits
+}
+
+// processCgroups maps controllers to cgroup of the process, unified
+// hierarchy of cgroup v2 is under empty name
+func processCgroups(root fs.FS) map[string]string {
+	groups := make(map[string]string)
+	data, err := fs.ReadFile(root, "proc/self/cgroup")
+	if err != nil {
+		return groups
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// hierarchy-id:controllers:path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[1] == "" {
+			groups[""] = parts[2]
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			groups[controller] = parts[2]
+		}
+	}
+	return groups
+}
+
+// cgroupFile finds file of the process cgroup under mount. With a private
+// cgroup namespace, as in containers, the cgroup is the mount root itself
+func cgroupFile(root fs.FS, mount string, group string, name string) (string, bool) {
+	for _, dir := range []string{path.Join(mount, group), mount} {
+		file := path.Join(dir, name)
+		if _, err := fs.Stat(root, file); err == nil {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// cgroupV2CPUs parses cpu.max, "<quota> <period>" or "max <period>"
+func cgroupV2CPUs(root fs.FS, file string) float64 {
+	fields := strings.Fields(readTrimmed(root, file))
+	if len(fields) != 2 {
+		return 0
+	}
+	return quotaCPUs(fields[0], fields[1])
+}
+
+func quotaCPUs(quota string, period string) float64 {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return float64(q) / float64(p)
+}
+
+// unlimitedMemory is about what cgroup v1 reports without a limit, anything
+// that large means no limit either way
+const unlimitedMemory = 1 << 62
+
+func readLimit(root fs.FS, name string) int64 {
+	limit, err := strconv.ParseInt(readTrimmed(root, name), 10, 64)
+	if err != nil || limit <= 0 || limit >= unlimitedMemory {
+		return 0
+	}
+	return limit
+}
+
+func readTrimmed(root fs.FS, name string) string {
+	data, err := fs.ReadFile(root, name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func file(data string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(data)}
+}
+
+func TestDetectLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		root     fstest.MapFS
+		expected Limits
+	}{
+		{
+			name: "cgroup v2 in namespace",
+			root: fstest.MapFS{
+				"proc/self/cgroup":         file("0::/\n"),
+				"sys/fs/cgroup/cpu.max":    file("150000 100000\n"),
+				"sys/fs/cgroup/memory.max": file("536870912\n"),
+			},
+			expected: Limits{CPUs: 1.5, MemoryBytes: 512 << 20},
+		},
+		{
+			name: "cgroup v2 nested without limits",
+			root: fstest.MapFS{
+				"proc/self/cgroup": file("0::/system.slice/api.service\n"),
+				"sys/fs/cgroup/system.slice/api.service/cpu.max":    file("max 100000\n"),
+				"sys/fs/cgroup/system.slice/api.service/memory.max": file("max\n"),
+			},
+			expected: Limits{},
+		},
+		{
+			name: "cgroup v1 nested",
+			root: fstest.MapFS{
+				"proc/self/cgroup": file("12:memory:/docker/abc\n4:cpu,cpuacct:/docker/abc\n0::/\n"),
+				"sys/fs/cgroup/cpu,cpuacct/docker/abc/cpu.cfs_quota_us":  file("200000\n"),
+				"sys/fs/cgroup/cpu,cpuacct/docker/abc/cpu.cfs_period_us": file("100000\n"),
+				"sys/fs/cgroup/memory/docker/abc/memory.limit_in_bytes":  file("1073741824\n"),
+			},
+			expected: Limits{CPUs: 2, MemoryBytes: 1 << 30},
+		},
+		{
+			name: "cgroup v1 without limits",
+			root: fstest.MapFS{
+				"proc/self/cgroup":                           file("12:memory:/\n4:cpu,cpuacct:/\n"),
+				"sys/fs/cgroup/cpu/cpu.cfs_quota_us":         file("-1\n"),
+				"sys/fs/cgroup/cpu/cpu.cfs_period_us":        file("100000\n"),
+				"sys/fs/cgroup/memory/memory.limit_in_bytes": file("9223372036854771712\n"),
+			},
+			expected: Limits{},
+		},
+		{
+			name:     "no cgroup",
+			root:     fstest.MapFS{},
+			expected: Limits{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, detectLimits(tt.root))
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// AuditAction is kind of change audit trail records
+type AuditAction string
+
+const (
+	AuditCreate AuditAction = "create"
+	AuditUpdate AuditAction = "update"
+	AuditDelete AuditAction = "delete"
+)
+
+// AuditEntry is a change of a product. Before is nil for creates, After for
+// deletes. Entries outlive products they are about
+type AuditEntry struct {
+	Id        int64       `json:"id"`
+	ProductId int64       `json:"productId"`
+	Action    AuditAction `json:"action"`
+	// principal who made the change, empty for anonymous callers and
+	// changes the service made on its own, such as supplier sync
+	Actor  string    `json:"actor,omitempty"`
+	At     time.Time `json:"at"`
+	Before *Product  `json:"before"`
+	After  *Product  `json:"after"`
+}
+
+type actorKey struct{}
+
+// WithActor attributes changes made with ctx to actor
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
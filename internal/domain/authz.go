@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnauthenticated means caller presented no valid credentials
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden means caller is known but none of its roles allows action
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Role of a caller. Each role is granted everything roles below it are:
+// reader < writer < admin
+type Role string
+
+const (
+	RoleReader Role = "reader"
+	RoleWriter Role = "writer"
+	RoleAdmin  Role = "admin"
+)
+
+var roleRanks = map[Role]int{RoleReader: 1, RoleWriter: 2, RoleAdmin: 3}
+
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("%w: unknown role %q", ErrInvalidInput, s)
+	}
+	return role, nil
+}
+
+// Action is what a request does to products
+type Action string
+
+const (
+	ActionRead      Action = "read"
+	ActionWrite     Action = "write"
+	ActionDeleteAll Action = "delete_all"
+)
+
+// Policy tells the least role each action requires
+type Policy map[Action]Role
+
+// DefaultPolicy opens reads to readers and writes to writers, wiping the
+// whole catalog is left to admins
+func DefaultPolicy() Policy {
+	return Policy{
+		ActionRead:      RoleReader,
+		ActionWrite:     RoleWriter,
+		ActionDeleteAll: RoleAdmin,
+	}
+}
+
+// Authorize returns ErrForbidden unless one of roles is sufficient for
+// action. Actions policy does not mention are forbidden to everyone
+func (p Policy) Authorize(roles []Role, action Action) error {
+	required, ok := p[action]
+	if !ok {
+		return fmt.Errorf("%w: %s is not allowed", ErrForbidden, action)
+	}
+	for _, role := range roles {
+		if rank, known := roleRanks[role]; known && rank >= roleRanks[required] {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s requires role %s", ErrForbidden, action, required)
+}
+
+// APIKey is identity requests made with a key are attributed to
+type APIKey struct {
+	Principal string
+	Role      Role
+}
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	policy := DefaultPolicy()
+	tests := []struct {
+		name    string
+		roles   []Role
+		allowed []Action
+	}{
+		{name: "no roles"},
+		{name: "reader", roles: []Role{RoleReader}, allowed: []Action{ActionRead}},
+		{name: "writer", roles: []Role{RoleWriter}, allowed: []Action{ActionRead, ActionWrite}},
+		{name: "admin", roles: []Role{RoleAdmin}, allowed: []Action{ActionRead, ActionWrite, ActionDeleteAll}},
+		{name: "highest role counts", roles: []Role{RoleReader, RoleWriter}, allowed: []Action{ActionRead, ActionWrite}},
+		{name: "unknown role", roles: []Role{"root"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, action := range []Action{ActionRead, ActionWrite, ActionDeleteAll, "export"} {
+				err := policy.Authorize(tt.roles, action)
+				if slices.Contains(tt.allowed, action) {
+					assert.NoError(t, err, action)
+				} else {
+					assert.ErrorIs(t, err, ErrForbidden, action)
+				}
+			}
+		})
+	}
+
+	_, err := ParseRole("root")
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	role, err := ParseRole("writer")
+	assert.NoError(t, err)
+	assert.Equal(t, RoleWriter, role)
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "context"
+
+type freshReadKey struct{}
+
+// WithFreshRead asks reads made with ctx to skip cache and go to database,
+// for clients that must see their own writes
+func WithFreshRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshReadKey{}, true)
+}
+
+func FreshReadRequested(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshReadKey{}).(bool)
+	return fresh
+}
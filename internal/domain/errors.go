@@ -7,10 +7,48 @@ import (
 )
 
 var (
-	ErrNotFound      = errors.New("product not found")
-	ErrInvalidInput  = errors.New("invalid input")
-	ErrInternalDb    = errors.New("internal database error")
-	ErrInternalCache = errors.New("internal cache error")
+	ErrNotFound     = errors.New("product not found")
+	ErrInvalidInput = errors.New("invalid input")
+	ErrInternalDb   = errors.New("internal database error")
+	// ErrUnavailable marks failures to reach a dependency, as opposed to errors
+	// of a particular request, callers may retry later
+	ErrUnavailable = errors.New("dependency unavailable")
+	// ErrStaleRead is a non-critical error of reads served from cache while
+	// database is down, so the data might be outdated
+	ErrStaleRead      = errors.New("served from cache while database is unavailable")
+	ErrInternalCache  = errors.New("internal cache error")
+	ErrPublishEvent   = errors.New("failed to publish event")
+	ErrInternalIndex  = errors.New("internal search index error")
+	ErrSearchDisabled = errors.New("search is not configured")
+	ErrFeed           = errors.New("product feed error")
+	// ErrKeySet means keys to verify token signatures could not be fetched
+	ErrKeySet = errors.New("signing key set error")
+	// ErrCursorExpired means changes since cursor are not retained anymore,
+	// clients have to reload the catalog
+	ErrCursorExpired = errors.New("change cursor expired")
+	// ErrPoolSaturated means no room was freed in worker pool queue before
+	// submitter gave up, the task is dropped
+	ErrPoolSaturated = errors.New("worker pool queue is full")
+	ErrPoolClosed    = errors.New("worker pool is closed")
+	// ErrVersionConflict means product was changed since the version writer
+	// based its update on
+	ErrVersionConflict = errors.New("product version conflict")
+	// ErrVersionRequired means writer named no version product is expected
+	// at, while deployment requires every update to
+	ErrVersionRequired = errors.New("product version required")
+	// ErrUnknownCategory means product was to be put in a category that does
+	// not exist
+	ErrUnknownCategory = errors.New("unknown category")
+	// ErrCategoryInUse means category still has products, so it can not be
+	// deleted
+	ErrCategoryInUse = errors.New("category has products")
+	// ErrDuplicateSku means another product already has the sku
+	ErrDuplicateSku = errors.New("sku already in use")
+	// ErrInsufficientStock means stock adjustment would take product stock
+	// below zero
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrObjectStore       = errors.New("object store error")
+	ErrImagesDisabled    = errors.New("image storage is not configured")
 )
 
 type ErrorContainer struct {
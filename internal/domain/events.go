@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventProductCreated     = "product.created"
+	EventProductUpdated     = "product.updated"
+	EventProductDeleted     = "product.deleted"
+	EventProductsDeletedAll = "products.deleted_all"
+)
+
+// ProductEvent describes a change of product catalog. Id is unique per event,
+// so consumers can deduplicate redelivered events. Traceparent is trace
+// context of request that made the change, if any
+type ProductEvent struct {
+	Id          string    `json:"id"`
+	Type        string    `json:"type"`
+	ProductId   int64     `json:"productId,omitempty"`
+	Product     *Product  `json:"product,omitempty"`
+	OccurredAt  time.Time `json:"occurredAt"`
+	Traceparent string    `json:"traceparent,omitempty"`
+}
+
+func NewProductEvent(eventType string, productId int64, product *Product) ProductEvent {
+	return ProductEvent{
+		Id:         uuid.NewString(),
+		Type:       eventType,
+		ProductId:  productId,
+		Product:    product,
+		OccurredAt: time.Now().UTC(),
+	}
+}
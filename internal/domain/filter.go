@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// ProductFilter narrows product lists. Empty fields do not filter
+type ProductFilter struct {
+	// whole name, case-insensitively
+	Name string
+	// part of additional info, case-insensitively
+	InfoContains string
+	// products of category
+	CategoryId int64
+	// products tagged with tag
+	Tag string
+	// products created at or after
+	CreatedAfter time.Time
+	// products created before
+	CreatedBefore time.Time
+	// products updated at or after
+	UpdatedAfter time.Time
+}
+
+func (f ProductFilter) IsEmpty() bool {
+	return f == ProductFilter{}
+}
+
+// Matches tells whether product passes filter, the way database does.
+// Products do not carry timestamps, InTimeRange checks those bounds
+func (f ProductFilter) Matches(p Product) bool {
+	if f.Name != "" && !strings.EqualFold(p.Name, f.Name) {
+		return false
+	}
+	if f.InfoContains != "" && !strings.Contains(strings.ToLower(p.AdditionalInfo), strings.ToLower(f.InfoContains)) {
+		return false
+	}
+	if f.CategoryId != 0 && p.CategoryId != f.CategoryId {
+		return false
+	}
+	if f.Tag != "" && !slices.Contains(p.Tags, f.Tag) {
+		return false
+	}
+	return true
+}
+
+// InTimeRange tells whether product created and last updated at given times
+// passes timestamp bounds of filter
+func (f ProductFilter) InTimeRange(createdAt time.Time, updatedAt time.Time) bool {
+	if !f.CreatedAfter.IsZero() && createdAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !createdAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if !f.UpdatedAfter.IsZero() && updatedAt.Before(f.UpdatedAfter) {
+		return false
+	}
+	return true
+}
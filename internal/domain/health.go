@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// ComponentHealth is outcome of the latest probe of a dependency. Until
+// first probe completes CheckedAt is zero and component is not healthy
+type ComponentHealth struct {
+	Name string `json:"name"`
+	// unhealthy critical component makes instance not ready
+	Critical  bool      `json:"critical"`
+	Healthy   bool      `json:"healthy"`
+	LatencyMs float64   `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+	// consecutive failed probes
+	Failures int    `json:"failures,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
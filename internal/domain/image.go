@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"io"
+	"time"
+)
+
+// ProductImage is metadata of product image, the image itself is kept in
+// object store and served from URL
+type ProductImage struct {
+	Id          int64     `json:"id"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+	// key of the object in store, internal to the service
+	Key string `json:"-"`
+}
+
+// ImageUpload is content of an image to be stored for a product
+type ImageUpload struct {
+	ContentType string
+	// exact number of bytes in body
+	Size int64
+	Body io.Reader
+}
+
+// imageExtensions are media types images may be uploaded as
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// ImageExtension returns file extension objects of image media type are
+// stored with, false if images of the type are not accepted
+func ImageExtension(contentType string) (string, bool) {
+	extension, ok := imageExtensions[contentType]
+	return extension, ok
+}
@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+const (
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	// run was due while previous one was still in progress
+	JobSkipped = "skipped"
+)
+
+type JobRun struct {
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// JobStatus describes a scheduled job, history holds latest runs, most
+// recent first
+type JobStatus struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule"`
+	Running  bool       `json:"running"`
+	NextRun  *time.Time `json:"nextRun,omitempty"`
+	History  []JobRun   `json:"history"`
+}
+
+// WorkerPoolStats is a snapshot of worker pool load, counters are totals
+// since start
+type WorkerPoolStats struct {
+	Workers   int    `json:"workers"`
+	Queued    int    `json:"queued"`
+	QueueSize int    `json:"queueSize"`
+	Busy      int    `json:"busy"`
+	Succeeded uint64 `json:"succeeded"`
+	Failed    uint64 `json:"failed"`
+	Rejected  uint64 `json:"rejected"`
+}
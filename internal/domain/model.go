@@ -4,9 +4,38 @@ type Product struct {
 	Id             int64  `json:"id"`
 	Name           string `json:"name"`
 	AdditionalInfo string `json:"additionalInfo"`
+	// starts at 1 and is bumped by every write
+	Version int64 `json:"version"`
+	// zero if product belongs to no category
+	CategoryId int64 `json:"categoryId,omitempty"`
+	// stock keeping unit, unique among products. Empty if product has none
+	Sku string `json:"sku,omitempty"`
+	// quantity in stock, never negative. Only changed by stock adjustments
+	Stock int64 `json:"stock,omitempty"`
+	// sorted and distinct, only changed by tagging, writes of other fields
+	// leave tags as they are
+	Tags []string `json:"tags,omitempty"`
+	// in order of upload
+	Images []ProductImage `json:"images,omitempty"`
 }
 
+// MaxNameLength is the most characters product name may have
+const MaxNameLength = 50
+
 type NewProduct struct {
 	Name           string `json:"name"`
 	AdditionalInfo string `json:"additionalInfo"`
+	// version update expects product to be at, zero skips the check. Ignored
+	// on create
+	Version int64 `json:"version,omitempty"`
+	// must be an existing category, zero for none
+	CategoryId int64 `json:"categoryId,omitempty"`
+	// must not be used by another product, empty for none
+	Sku string `json:"sku,omitempty"`
+}
+
+// Category groups products, each product belongs to at most one
+type Category struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
 }
@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Patcher changes product in memory, it is applied by service to the stored
+// state and result is validated before writing
+type Patcher func(Product) (Product, error)
+
+// PatchOperation is a single RFC 6902 operation
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch returns patcher applying add, remove and replace operations in
+// order. Either all operations apply or none does
+func JSONPatch(operations []PatchOperation) Patcher {
+	return func(product Product) (Product, error) {
+		for i, operation := range operations {
+			field, err := patchField(&product, operation.Path)
+			if err != nil {
+				return product, fmt.Errorf("%w: operation #%d: %s", ErrInvalidInput, i, err.Error())
+			}
+			switch operation.Op {
+			case "add", "replace":
+				// product members always exist, so add acts as replace
+				if err := field.set(operation.Value); err != nil {
+					return product, fmt.Errorf("%w: operation #%d: %s", ErrInvalidInput, i, err.Error())
+				}
+			case "remove":
+				if len(operation.Value) > 0 {
+					return product, fmt.Errorf("%w: operation #%d: remove takes no value", ErrInvalidInput, i)
+				}
+				field.remove()
+			default:
+				return product, fmt.Errorf("%w: operation #%d: unsupported op %q", ErrInvalidInput, i, operation.Op)
+			}
+		}
+		return product, nil
+	}
+}
+
+// MergePatch returns RFC 7396 patcher, null removes a member. Version member
+// is not patched, product is expected to be at that version
+func MergePatch(document map[string]json.RawMessage) Patcher {
+	return func(product Product) (Product, error) {
+		if value, ok := document["version"]; ok {
+			var version int64
+			if err := json.Unmarshal(value, &version); err != nil || version <= 0 {
+				return product, fmt.Errorf("%w: version must be a positive integer", ErrInvalidInput)
+			}
+			if err := checkVersion(product, version); err != nil {
+				return product, err
+			}
+		}
+		for member, value := range document {
+			if member == "version" {
+				continue
+			}
+			field, err := patchField(&product, "/"+member)
+			if err != nil {
+				return product, fmt.Errorf("%w: %s", ErrInvalidInput, err.Error())
+			}
+			if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+				field.remove()
+				continue
+			}
+			if err := field.set(value); err != nil {
+				return product, fmt.Errorf("%w: %s", ErrInvalidInput, err.Error())
+			}
+		}
+		return product, nil
+	}
+}
+
+// ExpectVersion makes patch fail with ErrVersionConflict unless product is at
+// version
+func ExpectVersion(version int64, patch Patcher) Patcher {
+	return func(product Product) (Product, error) {
+		if err := checkVersion(product, version); err != nil {
+			return product, err
+		}
+		return patch(product)
+	}
+}
+
+func checkVersion(product Product, version int64) error {
+	if product.Version != version {
+		return fmt.Errorf("%w: product %d is at version %d, not %d", ErrVersionConflict, product.Id, product.Version, version)
+	}
+	return nil
+}
+
+// patchMember is a mutable product member, removing it resets it to zero
+type patchMember interface {
+	set(value json.RawMessage) error
+	remove()
+}
+
+// patchField resolves JSON pointer to a mutable product member. Id can not be
+// patched
+func patchField(product *Product, pointer string) (patchMember, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid path %q", pointer)
+	}
+	member := strings.NewReplacer("~1", "/", "~0", "~").Replace(pointer[1:])
+	switch member {
+	case "name":
+		return (*stringMember)(&product.Name), nil
+	case "additionalInfo":
+		return (*stringMember)(&product.AdditionalInfo), nil
+	case "categoryId":
+		return (*idMember)(&product.CategoryId), nil
+	case "sku":
+		return (*stringMember)(&product.Sku), nil
+	case "id":
+		return nil, fmt.Errorf("product id can not be changed")
+	case "version":
+		return nil, fmt.Errorf("product version can not be changed")
+	default:
+		return nil, fmt.Errorf("unknown path %q", pointer)
+	}
+}
+
+type stringMember string
+
+func (m *stringMember) set(value json.RawMessage) error {
+	if len(value) == 0 {
+		return fmt.Errorf("value is required")
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return fmt.Errorf("value must be a string")
+	}
+	*m = stringMember(s)
+	return nil
+}
+
+func (m *stringMember) remove() {
+	*m = ""
+}
+
+type idMember int64
+
+func (m *idMember) set(value json.RawMessage) error {
+	if len(value) == 0 {
+		return fmt.Errorf("value is required")
+	}
+	var id int64
+	if err := json.Unmarshal(value, &id); err != nil || id < 1 {
+		return fmt.Errorf("value must be a positive integer")
+	}
+	*m = idMember(id)
+	return nil
+}
+
+func (m *idMember) remove() {
+	*m = 0
+}
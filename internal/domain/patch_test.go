@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONPatch(t *testing.T) {
+	product := Product{Id: 1, Name: "Name", AdditionalInfo: "Info"}
+	tests := []struct {
+		name          string
+		operations    string
+		expected      Product
+		expectedError error
+	}{
+		{
+			name:       "replace and add",
+			operations: `[{"op":"replace","path":"/name","value":"New name"},{"op":"add","path":"/additionalInfo","value":"New info"}]`,
+			expected:   Product{Id: 1, Name: "New name", AdditionalInfo: "New info"},
+		},
+		{
+			name:       "remove then add",
+			operations: `[{"op":"remove","path":"/name"},{"op":"add","path":"/name","value":"Other"}]`,
+			expected:   Product{Id: 1, Name: "Other", AdditionalInfo: "Info"},
+		},
+		{
+			name:       "empty patch",
+			operations: `[]`,
+			expected:   product,
+		},
+		{
+			name:          "id is immutable",
+			operations:    `[{"op":"replace","path":"/id","value":2}]`,
+			expectedError: ErrInvalidInput,
+		},
+		{
+			name:          "unknown path",
+			operations:    `[{"op":"replace","path":"/price","value":"1"}]`,
+			expectedError: ErrInvalidInput,
+		},
+		{
+			name:          "unsupported op",
+			operations:    `[{"op":"move","from":"/name","path":"/additionalInfo"}]`,
+			expectedError: ErrInvalidInput,
+		},
+		{
+			name:          "missing value",
+			operations:    `[{"op":"replace","path":"/name"}]`,
+			expectedError: ErrInvalidInput,
+		},
+		{
+			name:          "non-string value",
+			operations:    `[{"op":"replace","path":"/name","value":5}]`,
+			expectedError: ErrInvalidInput,
+		},
+		{
+			name:       "category",
+			operations: `[{"op":"add","path":"/categoryId","value":7}]`,
+			expected:   Product{Id: 1, Name: "Name", AdditionalInfo: "Info", CategoryId: 7},
+		},
+		{
+			name:          "non-positive category",
+			operations:    `[{"op":"replace","path":"/categoryId","value":0}]`,
+			expectedError: ErrInvalidInput,
+		},
+		{
+			name:       "sku",
+			operations: `[{"op":"add","path":"/sku","value":"LMP-001"}]`,
+			expected:   Product{Id: 1, Name: "Name", AdditionalInfo: "Info", Sku: "LMP-001"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var operations []PatchOperation
+			assert.NoError(t, json.Unmarshal([]byte(tt.operations), &operations))
+			result, err := JSONPatch(operations)(product)
+			if tt.expectedError != nil {
+				assert.True(t, errors.Is(err, tt.expectedError))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	product := Product{Id: 1, Name: "Name", AdditionalInfo: "Info"}
+
+	var document map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal([]byte(`{"name":"New name","additionalInfo":null}`), &document))
+	result, err := MergePatch(document)(product)
+	assert.NoError(t, err)
+	assert.Equal(t, Product{Id: 1, Name: "New name"}, result)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":2}`), &document))
+	_, err = MergePatch(document)(product)
+	assert.True(t, errors.Is(err, ErrInvalidInput))
+
+	product.CategoryId = 7
+	document = nil
+	assert.NoError(t, json.Unmarshal([]byte(`{"categoryId":null}`), &document))
+	result, err = MergePatch(document)(product)
+	assert.NoError(t, err)
+	assert.Equal(t, Product{Id: 1, Name: "Name", AdditionalInfo: "Info"}, result)
+}
+
+func TestPatchVersion(t *testing.T) {
+	product := Product{Id: 1, Name: "Name", AdditionalInfo: "Info", Version: 3}
+
+	var document map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal([]byte(`{"name":"New name","version":3}`), &document))
+	result, err := MergePatch(document)(product)
+	assert.NoError(t, err)
+	assert.Equal(t, Product{Id: 1, Name: "New name", AdditionalInfo: "Info", Version: 3}, result)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"name":"New name","version":2}`), &document))
+	_, err = MergePatch(document)(product)
+	assert.True(t, errors.Is(err, ErrVersionConflict))
+
+	rename := JSONPatch([]PatchOperation{{Op: "replace", Path: "/name", Value: json.RawMessage(`"New name"`)}})
+	_, err = ExpectVersion(3, rename)(product)
+	assert.NoError(t, err)
+	_, err = ExpectVersion(2, rename)(product)
+	assert.True(t, errors.Is(err, ErrVersionConflict))
+
+	_, err = JSONPatch([]PatchOperation{{Op: "replace", Path: "/version", Value: json.RawMessage(`4`)}})(product)
+	assert.True(t, errors.Is(err, ErrInvalidInput))
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// RateLimit allows Requests per Period on average, up to Burst of them at
+// once
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Emission is interval between requests at sustained rate
+func (l RateLimit) Emission() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+type RateDecision struct {
+	Allowed bool
+	// requests that can be made right away
+	Remaining int
+	// when refused request can be retried
+	RetryAfter time.Duration
+	// when full burst is available again
+	ResetAfter time.Duration
+}
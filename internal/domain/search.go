@@ -0,0 +1,28 @@
+package domain
+
+type SearchQuery struct {
+	Text   string
+	Offset int64
+	Limit  int64
+	// fields to compute value counts for, e.g. "name"
+	Facets []string
+	// match names by similarity instead of full-text, tolerating typos
+	Fuzzy bool
+}
+
+type SearchHit struct {
+	Product    Product             `json:"product"`
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type SearchResult struct {
+	Total  int64                    `json:"total"`
+	Hits   []SearchHit              `json:"hits"`
+	Facets map[string][]FacetBucket `json:"facets,omitempty"`
+}
@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxSkuLength is the most characters a sku may have
+const MaxSkuLength = 64
+
+// ValidateSku checks sku is printable, without spaces or slashes, so that
+// it can be looked up by path as it is. Skus are case-sensitive
+func ValidateSku(sku string) error {
+	if sku == "" {
+		return fmt.Errorf("%w: sku is empty", ErrInvalidInput)
+	}
+	if utf8.RuneCountInString(sku) > MaxSkuLength {
+		return fmt.Errorf("%w: sku %q is longer than %d characters", ErrInvalidInput, sku, MaxSkuLength)
+	}
+	for _, r := range sku {
+		if !unicode.IsPrint(r) || unicode.IsSpace(r) || r == '/' {
+			return fmt.Errorf("%w: sku %q contains %q", ErrInvalidInput, sku, r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSku(t *testing.T) {
+	for _, sku := range []string{"LMP-001", "lmp.001_a", strings.Repeat("X", MaxSkuLength)} {
+		assert.NoError(t, ValidateSku(sku), sku)
+	}
+	for _, sku := range []string{"", "LMP 001", "LMP/001", "LMP\t001", strings.Repeat("X", MaxSkuLength+1)} {
+		assert.ErrorIs(t, ValidateSku(sku), ErrInvalidInput, sku)
+	}
+}
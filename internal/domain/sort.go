@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// SortField orders products by field, named as in API
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ProductSort orders products by fields in turn, ties left are broken by
+// id, so order is the same on every page. Empty sort orders by id.
+// Repositories reject fields they can not sort by
+type ProductSort []SortField
+
+// ParseProductSort reads comma separated fields with "-" before those
+// sorted in descending order, e.g. "name,-id". Empty value is empty sort
+func ParseProductSort(value string) (ProductSort, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var sort ProductSort
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		desc := strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(field, "-")
+		if field == "" {
+			return nil, fmt.Errorf("%w: empty field in sort %q", ErrInvalidInput, value)
+		}
+		sort = append(sort, SortField{Field: field, Desc: desc})
+	}
+	return sort, nil
+}
+
+// String formats sort like query parameter does, e.g. "name,-id"
+func (s ProductSort) String() string {
+	fields := make([]string, len(s))
+	for i, f := range s {
+		fields[i] = f.Field
+		if f.Desc {
+			fields[i] = "-" + f.Field
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// Compare orders products the way repositories do, comparing text
+// bytewise. Fields it does not know compare equal
+func (s ProductSort) Compare(a, b Product) int {
+	for _, f := range s {
+		var c int
+		switch f.Field {
+		case "id":
+			c = cmp.Compare(a.Id, b.Id)
+		case "name":
+			c = strings.Compare(a.Name, b.Name)
+		case "additionalInfo":
+			c = strings.Compare(a.AdditionalInfo, b.AdditionalInfo)
+		}
+		if f.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(a.Id, b.Id)
+}
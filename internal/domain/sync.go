@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+type SyncReport struct {
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+	Fetched   int           `json:"fetched"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Deleted   int           `json:"deleted"`
+	Unchanged int           `json:"unchanged"`
+	Errors    []string      `json:"errors,omitempty"`
+}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxTagLength is the most characters a tag may have
+const MaxTagLength = 50
+
+// NormalizeTag lowercases tag and checks it is a single word of letters,
+// digits, '-' and '_', so that it fits in a path segment or query as it is
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return "", fmt.Errorf("%w: tag is empty", ErrInvalidInput)
+	}
+	if utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", fmt.Errorf("%w: tag %q is longer than %d characters", ErrInvalidInput, tag, MaxTagLength)
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return "", fmt.Errorf("%w: tag %q contains %q", ErrInvalidInput, tag, r)
+		}
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalizes each of tags, returning them sorted and without
+// duplicates
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
+
+// AddTags merges added into tags, both sorted and distinct. No tags are nil,
+// as they are stored
+func AddTags(tags []string, added []string) []string {
+	merged := slices.Concat(tags, added)
+	slices.Sort(merged)
+	merged = slices.Compact(merged)
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// RemoveTags returns tags without removed ones, nil if none are left
+func RemoveTags(tags []string, removed []string) []string {
+	var kept []string
+	for _, tag := range tags {
+		if !slices.Contains(removed, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
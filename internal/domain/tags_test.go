@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := NormalizeTags([]string{" Sale ", "new-in", "sale", "été_2024"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"new-in", "sale", "été_2024"}, tags)
+
+	tags, err = NormalizeTags(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+
+	for _, tag := range []string{"", "  ", "on sale", "a/b", "50%", strings.Repeat("x", MaxTagLength+1)} {
+		_, err := NormalizeTag(tag)
+		assert.ErrorIs(t, err, ErrInvalidInput, tag)
+	}
+	tag, err := NormalizeTag(strings.Repeat("x", MaxTagLength))
+	assert.NoError(t, err)
+	assert.Len(t, tag, MaxTagLength)
+}
+
+func TestAddRemoveTags(t *testing.T) {
+	tags := AddTags(nil, []string{"sale", "new"})
+	assert.Equal(t, []string{"new", "sale"}, tags)
+	assert.Equal(t, []string{"new", "sale", "top"}, AddTags(tags, []string{"top", "sale"}))
+	assert.Nil(t, AddTags(nil, nil))
+
+	assert.Equal(t, []string{"new"}, RemoveTags(tags, []string{"sale", "unknown"}))
+	assert.Nil(t, RemoveTags(tags, tags))
+	assert.Nil(t, RemoveTags(nil, []string{"sale"}))
+}
@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TraceContext identifies a span of distributed trace in W3C trace context
+// terms: 32 hex digits of trace id and 16 of span id. It is only passed
+// along, spans are not recorded
+type TraceContext struct {
+	TraceId string
+	SpanId  string
+	Sampled bool
+}
+
+type traceContextKey struct{}
+
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// NewTraceContext starts a new trace
+func NewTraceContext() TraceContext {
+	return TraceContext{TraceId: randomHex(16), SpanId: randomHex(8)}
+}
+
+// Child is a span of the same trace, made by whoever received tc
+func (tc TraceContext) Child() TraceContext {
+	return TraceContext{TraceId: tc.TraceId, SpanId: randomHex(8), Sampled: tc.Sampled}
+}
+
+// Traceparent formats tc as W3C traceparent header
+func (tc TraceContext) Traceparent() string {
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	return "00-" + tc.TraceId + "-" + tc.SpanId + "-" + flags
+}
+
+// B3 formats tc as single B3 header
+func (tc TraceContext) B3() string {
+	sampled := "0"
+	if tc.Sampled {
+		sampled = "1"
+	}
+	return tc.TraceId + "-" + tc.SpanId + "-" + sampled
+}
+
+// TraceHeaders are headers propagating tc to HTTP services, whichever of
+// the formats they understand
+func (tc TraceContext) TraceHeaders() map[string]string {
+	return map[string]string{"traceparent": tc.Traceparent(), "b3": tc.B3()}
+}
+
+// ParseTraceparent accepts version 00 of W3C traceparent and, as the spec
+// asks, any later version with the same leading fields
+func ParseTraceparent(s string) (TraceContext, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || !isHex(parts[0]) || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q", s)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return TraceContext{}, fmt.Errorf("invalid traceparent flags %q", s)
+	}
+	tc := TraceContext{TraceId: parts[1], SpanId: parts[2], Sampled: flags[0]&1 == 1}
+	if !tc.valid() {
+		return TraceContext{}, fmt.Errorf("invalid traceparent ids %q", s)
+	}
+	return tc, nil
+}
+
+// ParseB3 accepts ids and sampling decision of B3 headers, either single
+// b3 header "traceid-spanid[-sampled[-parentspanid]]" or X-B3-* ones.
+// 64-bit trace ids are padded to 128 bits
+func ParseB3(traceId string, spanId string, sampled string) (TraceContext, error) {
+	if len(traceId) == 16 {
+		traceId = strings.Repeat("0", 16) + traceId
+	}
+	tc := TraceContext{
+		TraceId: strings.ToLower(traceId),
+		SpanId:  strings.ToLower(spanId),
+		Sampled: sampled == "1" || sampled == "d" || strings.EqualFold(sampled, "true"),
+	}
+	if !tc.valid() {
+		return TraceContext{}, fmt.Errorf("invalid b3 ids %q, %q", traceId, spanId)
+	}
+	return tc, nil
+}
+
+// ParseB3Single parses single b3 header
+func ParseB3Single(s string) (TraceContext, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		return TraceContext{}, fmt.Errorf("invalid b3 %q", s)
+	}
+	sampled := ""
+	if len(parts) > 2 {
+		sampled = parts[2]
+	}
+	return ParseB3(parts[0], parts[1], sampled)
+}
+
+// valid requires lowercase hex ids of right length that are not all zeros
+func (tc TraceContext) valid() bool {
+	return len(tc.TraceId) == 32 && len(tc.SpanId) == 16 &&
+		isHex(tc.TraceId) && isHex(tc.SpanId) &&
+		strings.Trim(tc.TraceId, "0") != "" && strings.Trim(tc.SpanId, "0") != ""
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   TraceContext
+		valid  bool
+	}{
+		{
+			name:   "sampled",
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:   TraceContext{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7", Sampled: true},
+			valid:  true,
+		},
+		{
+			name:   "not sampled",
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			want:   TraceContext{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7"},
+			valid:  true,
+		},
+		{
+			name:   "future version with extra field",
+			header: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			want:   TraceContext{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7", Sampled: true},
+			valid:  true,
+		},
+		{name: "version 00 with extra field", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{name: "forbidden version", header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "zero trace id", header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "uppercase", header: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{name: "short span id", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01"},
+		{name: "garbage", header: "trace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, err := ParseTraceparent(tt.header)
+			if !tt.valid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, tc)
+		})
+	}
+}
+
+func TestParseB3(t *testing.T) {
+	tc, err := ParseB3Single("80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90")
+	require.NoError(t, err)
+	assert.Equal(t, TraceContext{TraceId: "80f198ee56343ba864fe8b2a57d3eff7", SpanId: "e457b5a2e4d86bd1", Sampled: true}, tc)
+
+	tc, err = ParseB3("64fe8b2a57d3eff7", "E457B5A2E4D86BD1", "")
+	require.NoError(t, err)
+	assert.Equal(t, TraceContext{TraceId: "000000000000000064fe8b2a57d3eff7", SpanId: "e457b5a2e4d86bd1"}, tc, "64-bit trace id is padded")
+
+	_, err = ParseB3Single("1")
+	assert.Error(t, err, "sampling decision alone carries no trace")
+}
+
+func TestTraceContextFormats(t *testing.T) {
+	tc := TraceContext{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7", Sampled: true}
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", tc.Traceparent())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1", tc.B3())
+
+	child := tc.Child()
+	assert.Equal(t, tc.TraceId, child.TraceId)
+	assert.NotEqual(t, tc.SpanId, child.SpanId)
+	assert.True(t, child.Sampled)
+	parsed, err := ParseTraceparent(NewTraceContext().Traceparent())
+	require.NoError(t, err, "new trace context is valid")
+	assert.False(t, parsed.Sampled)
+}
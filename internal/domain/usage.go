@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// Usage is how much a client used the API
+type Usage struct {
+	Client   string `json:"client"`
+	Requests int64  `json:"requests"`
+	// responses with 4xx or 5xx status
+	Errors   int64 `json:"errors"`
+	BytesIn  int64 `json:"bytesIn"`
+	BytesOut int64 `json:"bytesOut"`
+}
+
+// DailyUsage is usage of a client within a day, UTC
+type DailyUsage struct {
+	Day time.Time
+	Usage
+}
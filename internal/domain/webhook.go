@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// WebhookEventTypes are events webhooks may subscribe to
+var WebhookEventTypes = []string{EventProductCreated, EventProductUpdated, EventProductDeleted, EventProductsDeletedAll}
+
+// IsWebhookEventType tells whether webhooks may subscribe to eventType
+func IsWebhookEventType(eventType string) bool {
+	return slices.Contains(WebhookEventTypes, eventType)
+}
+
+// Webhook subscribes URL to product events of EventTypes. Deliveries are
+// signed with Secret, it is never shown after the webhook is created
+type Webhook struct {
+	Id         int64     `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"-"`
+	EventTypes []string  `json:"eventTypes"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// WebhookDelivery is an event on its way to a webhook. Payload is the event
+// as it is sent, Attempts counts attempts made so far, the current one
+// included
+type WebhookDelivery struct {
+	Id        int64
+	WebhookId int64
+	URL       string
+	Secret    string
+	EventId   string
+	EventType string
+	Payload   []byte
+	Attempts  int
+	// trace context of request that made the change, if any
+	Traceparent string
+}
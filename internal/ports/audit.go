@@ -0,0 +1,15 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// AuditTrail reads changes recorded for products
+type AuditTrail interface {
+	// ProductAudit returns up to limit entries of product, newest first,
+	// only those older than entry with id before unless it is zero. Trail
+	// of a product that never existed is empty, not domain.ErrNotFound
+	ProductAudit(ctx context.Context, productId int64, before int64, limit int64) ([]domain.AuditEntry, error)
+}
@@ -0,0 +1,36 @@
+package ports
+
+import (
+	"context"
+	"crypto"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// APIKeyRepository keeps API keys by hex encoded SHA-256 hash, keys
+// themselves are never stored
+type APIKeyRepository interface {
+	// CreateAPIKey issues key with hash and returns its id
+	CreateAPIKey(ctx context.Context, key domain.APIKey, hash string) (int64, error)
+	// RevokeAPIKey returns domain.ErrNotFound if there is no such key
+	// or it is revoked already
+	RevokeAPIKey(ctx context.Context, id int64) error
+	// LookupAPIKey returns key with hash, domain.ErrNotFound if key is
+	// unknown or revoked
+	LookupAPIKey(ctx context.Context, hash string) (domain.APIKey, error)
+}
+
+// KeySet holds public keys bearer tokens are signed with
+type KeySet interface {
+	// Key returns key with id kid, domain.ErrNotFound if there is no such key
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// SignatureReplayStore remembers signatures of verified requests, shared by
+// all instances so that a signed request is accepted once
+type SignatureReplayStore interface {
+	// Claim marks signature as used for ttl. It returns false if signature
+	// has been claimed already
+	Claim(ctx context.Context, signature string, ttl time.Duration) (bool, error)
+}
@@ -0,0 +1,7 @@
+package ports
+
+// CircuitBreaker reports state of a breaker guarding a dependency
+type CircuitBreaker interface {
+	// Open reports whether calls are currently rejected without being tried
+	Open() bool
+}
@@ -8,7 +8,14 @@ import (
 
 type Cache interface {
 	SetProduct(ctx context.Context, product *domain.Product) error
+	// SetProducts stores products in a single round trip
+	SetProducts(ctx context.Context, products []domain.Product) error
 	GetJSONProductById(ctx context.Context, id int64) ([]byte, error)
+	// SetProductSku remembers id of product with sku. Skus move between
+	// products, so ids read back have to be checked against the product
+	SetProductSku(ctx context.Context, sku string, id int64) error
+	// GetProductIdBySku fails with domain.ErrNotFound unless sku is cached
+	GetProductIdBySku(ctx context.Context, sku string) (int64, error)
 	DeleteProductById(ctx context.Context, id int64) error
 	ClearCache(ctx context.Context) error
 }
@@ -0,0 +1,21 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// CategoryRepository stores categories products are put in
+type CategoryRepository interface {
+	// ListCategories returns all categories ordered by id
+	ListCategories(ctx context.Context) ([]domain.Category, error)
+	// GetCategory returns domain.ErrNotFound if there is no such category
+	GetCategory(ctx context.Context, id int64) (*domain.Category, error)
+	StoreCategory(ctx context.Context, name string) (int64, error)
+	// UpdateCategory returns domain.ErrNotFound if there is no such category
+	UpdateCategory(ctx context.Context, category domain.Category) error
+	// DeleteCategory returns domain.ErrNotFound if there is no such
+	// category, domain.ErrCategoryInUse while products belong to it
+	DeleteCategory(ctx context.Context, id int64) error
+}
@@ -0,0 +1,18 @@
+package ports
+
+import "io"
+
+// JSONCodec encodes and decodes JSON of product endpoints, so deployments
+// serving large lists can switch to a faster implementation than
+// encoding/json. Output is expected to match the one of encoding/json
+// Encoder, newline after every value included
+type JSONCodec interface {
+	NewEncoder(w io.Writer) JSONEncoder
+	// Decode reads a single JSON value, rejecting unknown object fields and
+	// anything that follows the value
+	Decode(r io.Reader, v any) error
+}
+
+type JSONEncoder interface {
+	Encode(v any) error
+}
@@ -0,0 +1,15 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// ConfirmationStore keeps short-lived single-use tokens confirming
+// destructive actions
+type ConfirmationStore interface {
+	Issue(ctx context.Context, action string, ttl time.Duration) (string, error)
+	// Consume reports whether token was issued for action and has not expired,
+	// valid token is invalidated right away
+	Consume(ctx context.Context, action string, token string) (bool, error)
+}
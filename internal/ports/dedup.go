@@ -0,0 +1,18 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// WriteDedupStore remembers recent write requests by key, so that repeated
+// ones can be rejected or answered with the original response
+type WriteDedupStore interface {
+	// Claim marks key as seen for ttl. For keys already seen it returns false
+	// along with response saved for them, nil while still in progress
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, []byte, error)
+	// Save stores response of claimed request for the rest of its ttl
+	Save(ctx context.Context, key string, response []byte) error
+	// Release forgets key, so that failed request can be retried right away
+	Release(ctx context.Context, key string) error
+}
@@ -0,0 +1,18 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type EventPublisher interface {
+	Publish(ctx context.Context, event domain.ProductEvent) error
+	Close() error
+}
+
+// ChangeFeed serves recent events to clients polling for changes
+type ChangeFeed interface {
+	Cursor() int64
+	Wait(ctx context.Context, cursor int64) ([]domain.ProductEvent, int64, error)
+}
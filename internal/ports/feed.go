@@ -0,0 +1,21 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// ProductFeed is an external source of truth for products, e.g. supplier catalog
+type ProductFeed interface {
+	Fetch(ctx context.Context) ([]domain.Product, error)
+}
+
+type SyncJob interface {
+	SyncOnce(ctx context.Context) *domain.SyncReport
+	LastReport() *domain.SyncReport
+}
+
+type JobScheduler interface {
+	Jobs() []domain.JobStatus
+}
@@ -0,0 +1,15 @@
+package ports
+
+import "github.com/pelyams/simpler_go_service/internal/domain"
+
+// HealthReporter tells state of dependencies as of their latest probes
+type HealthReporter interface {
+	Health() []domain.ComponentHealth
+	// Ready reports whether all critical dependencies are healthy
+	Ready() bool
+}
+
+// StartupReporter tells which one-off startup checks have not passed yet
+type StartupReporter interface {
+	Pending() []string
+}
@@ -0,0 +1,15 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// LeaderLease can be held by a single instance at a time. Holder has to
+// renew it before ttl passes, otherwise another instance may acquire it
+type LeaderLease interface {
+	Acquire(ctx context.Context, ttl time.Duration) (bool, error)
+	// Renew extends lease, it reports false if lease is not held anymore
+	Renew(ctx context.Context, ttl time.Duration) (bool, error)
+	Release(ctx context.Context) error
+}
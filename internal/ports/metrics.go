@@ -0,0 +1,28 @@
+package ports
+
+// BusinessMetrics records domain-level facts, as opposed to transport metrics
+type BusinessMetrics interface {
+	ProductsCreated(n int)
+	ProductRead(cacheHit bool)
+	DeleteAllInvoked()
+}
+
+// RequestMetrics records serving of HTTP requests by route, i.e. pattern
+// request was matched to, so that metrics do not grow with ids in paths
+type RequestMetrics interface {
+	// RequestStarted is called before request is served, returned func once
+	// response is written
+	RequestStarted(route string, method string) func(status int)
+}
+
+// CacheMetrics records outcome of cache lookups
+type CacheMetrics interface {
+	// CacheLookup records lookup of a single key, err is set if it could not
+	// be told whether key is cached
+	CacheLookup(hit bool, err error)
+}
+
+// QueryMetrics records database operations, named after repository methods
+type QueryMetrics interface {
+	QueryDone(operation string, err error)
+}
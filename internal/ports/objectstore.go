@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"context"
+	"io"
+)
+
+// ObjectStore keeps uploaded files, such as product images, outside of
+// database
+type ObjectStore interface {
+	// Put streams size bytes of body to object under key and returns URL
+	// the object is served from
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) (string, error)
+	// Delete removes object under key, removing missing object is not an error
+	Delete(ctx context.Context, key string) error
+}
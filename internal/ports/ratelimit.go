@@ -0,0 +1,15 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// RateLimiter keeps request rates in storage shared by replicas, so limits
+// hold no matter which replica serves the client
+type RateLimiter interface {
+	// Allow counts a request of key against limit, refused requests are not
+	// counted
+	Allow(ctx context.Context, key string, limit domain.RateLimit) (domain.RateDecision, error)
+}
@@ -8,10 +8,57 @@ import (
 
 type Repository interface {
 	GetProduct(ctx context.Context, id int64) (*domain.Product, error)
+	// GetProductBySku fails with domain.ErrNotFound unless a product has sku
+	GetProductBySku(ctx context.Context, sku string) (*domain.Product, error)
 	GetAllProducts(ctx context.Context) ([]domain.Product, error)
-	GetProductsPaged(ctx context.Context, limit int64, offset int64) ([]domain.Product, error)
+	// EachProduct passes products matching filter to fn in sort order as
+	// they are read, stopping at the first error fn returns. Database
+	// resources are held until it returns, so fn should not block for long
+	EachProduct(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, fn func(product domain.Product) error) error
+	// GetProductsPaged returns page of products matching filter in sort
+	// order
+	GetProductsPaged(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, limit int64, offset int64) ([]domain.Product, error)
+	// GetProductsAfter returns up to limit products matching filter that
+	// follow after in sort order, or the first ones if after is nil. Only
+	// fields of after that are sorted by, and id, are compared, so pages
+	// stay stable while products are added or removed
+	GetProductsAfter(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, after *domain.Product, limit int64) ([]domain.Product, error)
+	// ProductIdRange returns the lowest and highest product id, both are 0
+	// when there are no products
+	ProductIdRange(ctx context.Context) (int64, int64, error)
+	// GetProductsInRange returns products with fromId <= id < toId in order
+	// of id
+	GetProductsInRange(ctx context.Context, fromId int64, toId int64) ([]domain.Product, error)
+	// CountProducts counts products matching filter
+	CountProducts(ctx context.Context, filter domain.ProductFilter) (int64, error)
+	// SuggestProductNames returns up to limit distinct names starting with
+	// prefix, case-insensitively, in alphabetical order
+	SuggestProductNames(ctx context.Context, prefix string, limit int64) ([]string, error)
+	// FuzzySearchProducts finds products with names similar to query text,
+	// tolerating typos. Best matches come first, facets are not supported
+	FuzzySearchProducts(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error)
+	// SearchProducts finds products matching query text in name or
+	// additional info, best ranked first, with matches highlighted. Facets
+	// are not supported
+	SearchProducts(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error)
 	StoreProduct(ctx context.Context, product domain.NewProduct) (int64, error)
+	StoreProducts(ctx context.Context, products []domain.NewProduct) ([]int64, error)
+	UpsertProduct(ctx context.Context, product domain.Product) (bool, error)
 	UpdateProductById(ctx context.Context, id int64, product domain.NewProduct) (*domain.Product, error)
 	DeleteProductById(ctx context.Context, id int64) (*domain.Product, error)
+	// AddProductTags tags product with tags it does not have yet and
+	// returns it as it is after. Product is left as it is, version
+	// included, when it already has all of them
+	AddProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, error)
+	// RemoveProductTags removes tags from product the way AddProductTags
+	// adds them, tags product does not have are ignored
+	RemoveProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, error)
+	// AdjustProductStock adds delta, possibly negative, to product stock and
+	// returns the product. Fails with domain.ErrInsufficientStock rather than
+	// take stock below zero
+	AdjustProductStock(ctx context.Context, id int64, delta int64) (*domain.Product, error)
+	// AddProductImage records metadata of image already put in object store
+	// and returns the product with it
+	AddProductImage(ctx context.Context, id int64, image domain.ProductImage) (*domain.Product, error)
 	DeleteAllProducts(ctx context.Context) (int64, error)
 }
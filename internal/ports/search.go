@@ -0,0 +1,20 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// SearchIndex keeps copies of products for relevance-ranked search apart
+// from database. Service updates it on every write
+type SearchIndex interface {
+	// Index adds product or replaces its earlier copy
+	Index(ctx context.Context, product *domain.Product) error
+	// Delete removes product, removing missing product is not an error
+	Delete(ctx context.Context, id int64) error
+	DeleteAll(ctx context.Context) error
+	// Query finds products matching query text, best ranked first. Unknown
+	// facets are domain.ErrInvalidInput
+	Query(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error)
+}
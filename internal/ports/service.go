@@ -8,10 +8,33 @@ import (
 
 type ResourseService interface {
 	GetProductById(ctx context.Context, id int64) ([]byte, *domain.ServiceError)
+	// GetProductBySku reads product the way GetProductById does
+	GetProductBySku(ctx context.Context, sku string) ([]byte, *domain.ServiceError)
 	GetAllProducts(ctx context.Context) ([]domain.Product, *domain.ServiceError)
-	GetProductsPaged(ctx context.Context, limit int64, offset int64) ([]domain.Product, *domain.ServiceError)
+	EachProduct(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, fn func(product domain.Product) error) *domain.ServiceError
+	GetProductsPaged(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, limit int64, offset int64) ([]domain.Product, *domain.ServiceError)
+	GetProductsAfter(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, after *domain.Product, limit int64) ([]domain.Product, *domain.ServiceError)
+	// CountProducts counts products matching filter, e.g. to tell total
+	// of paged listing
+	CountProducts(ctx context.Context, filter domain.ProductFilter) (int64, *domain.ServiceError)
+	// ExportProducts passes the whole catalog to fn in pages, in order of id.
+	// There is at least one, possibly empty, page. Reading stops at the first
+	// error fn returns, which is returned as critical
+	ExportProducts(ctx context.Context, fn func(page []domain.Product) error) *domain.ServiceError
+	SearchProducts(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, *domain.ServiceError)
+	SuggestProductNames(ctx context.Context, prefix string, limit int64) ([]string, *domain.ServiceError)
 	CreateProduct(ctx context.Context, product domain.NewProduct) (int64, *domain.ServiceError)
+	CreateProducts(ctx context.Context, products []domain.NewProduct) ([]int64, *domain.ServiceError)
+	UpsertProduct(ctx context.Context, product domain.Product) (bool, *domain.ServiceError)
 	UpdateProductById(ctx context.Context, id int64, product domain.NewProduct) (*domain.Product, *domain.ServiceError)
+	PatchProduct(ctx context.Context, id int64, patch domain.Patcher) (*domain.Product, *domain.ServiceError)
 	DeleteProductById(ctx context.Context, id int64) (*domain.Product, *domain.ServiceError)
+	// AddProductTags tags product and returns it, tags are normalized first
+	AddProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, *domain.ServiceError)
+	RemoveProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, *domain.ServiceError)
+	// AdjustProductStock adds delta to product stock and returns the product
+	AdjustProductStock(ctx context.Context, id int64, delta int64) (*domain.Product, *domain.ServiceError)
+	// AddProductImage stores image and returns product with it
+	AddProductImage(ctx context.Context, id int64, upload domain.ImageUpload) (*domain.Product, *domain.ServiceError)
 	DeleteAllProducts(ctx context.Context) (int64, *domain.ServiceError)
 }
@@ -0,0 +1,22 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// UsageCounter aggregates API usage until it is flushed to UsageRepository
+type UsageCounter interface {
+	Add(ctx context.Context, usage domain.DailyUsage) error
+	// Drain returns counted usage and resets counters
+	Drain(ctx context.Context) ([]domain.DailyUsage, error)
+}
+
+type UsageRepository interface {
+	AddUsage(ctx context.Context, usage []domain.DailyUsage) error
+	// GetUsage sums usage of every client over days from and to, inclusive,
+	// heaviest users first
+	GetUsage(ctx context.Context, from time.Time, to time.Time) ([]domain.Usage, error)
+}
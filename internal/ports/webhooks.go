@@ -0,0 +1,34 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// WebhookRepository keeps webhook subscriptions and queue of events to be
+// delivered to them. Deliveries are pending until they are completed or
+// failed for good
+type WebhookRepository interface {
+	// CreateWebhook stores webhook and returns it with id and creation time
+	CreateWebhook(ctx context.Context, webhook domain.Webhook) (domain.Webhook, error)
+	// ListWebhooks returns all webhooks ordered by id
+	ListWebhooks(ctx context.Context) ([]domain.Webhook, error)
+	// DeleteWebhook drops webhook along with its deliveries, returns
+	// domain.ErrNotFound if there is no such webhook
+	DeleteWebhook(ctx context.Context, id int64) error
+	// EnqueueWebhookDeliveries queues payload of event for every webhook
+	// subscribed to its type and returns how many were queued
+	EnqueueWebhookDeliveries(ctx context.Context, event domain.ProductEvent, payload []byte) (int, error)
+	// ClaimWebhookDeliveries returns up to limit pending deliveries that are
+	// due, counting an attempt for each. They are not due again for lease,
+	// so that nobody else claims them while they are being delivered
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error)
+	// CompleteWebhookDelivery removes delivered delivery from queue
+	CompleteWebhookDelivery(ctx context.Context, id int64) error
+	// RetryWebhookDelivery makes delivery due again after delay
+	RetryWebhookDelivery(ctx context.Context, id int64, delay time.Duration, lastError string) error
+	// FailWebhookDelivery gives up on delivery, it is kept for inspection
+	FailWebhookDelivery(ctx context.Context, id int64, lastError string) error
+}
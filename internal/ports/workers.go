@@ -0,0 +1,18 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// WorkerPool runs side effects of requests asynchronously on a bounded
+// number of goroutines
+type WorkerPool interface {
+	// Submit queues task, tasks with the same key run one at a time in order
+	// of submission. While queue is full it waits until ctx is done and
+	// fails with domain.ErrPoolSaturated. Task context keeps values of ctx
+	// but is not cancelled along with it
+	Submit(ctx context.Context, key string, task func(ctx context.Context) error) error
+	Stats() domain.WorkerPoolStats
+}
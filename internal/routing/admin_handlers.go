@@ -0,0 +1,311 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+type AdminHandler struct {
+	logger          *Logger
+	syncJob         ports.SyncJob
+	businessMetrics http.Handler
+	metrics         http.Handler
+	faults          *FaultInjector
+	usage           ports.UsageRepository
+	apiKeys         ports.APIKeyRepository
+	webhooks        ports.WebhookRepository
+	dataset         ports.ResourseService
+	drainer         *Drainer
+	scheduler       ports.JobScheduler
+	health          ports.HealthReporter
+	startup         ports.StartupReporter
+	profiling       bool
+}
+
+type AdminOption func(*AdminHandler)
+
+func WithSyncJob(job ports.SyncJob) AdminOption {
+	return func(h *AdminHandler) {
+		h.syncJob = job
+	}
+}
+
+// WithBusinessMetrics exposes domain metrics in Prometheus text format
+func WithBusinessMetrics(handler http.Handler) AdminOption {
+	return func(h *AdminHandler) {
+		h.businessMetrics = handler
+	}
+}
+
+func WithScheduler(scheduler ports.JobScheduler) AdminOption {
+	return func(h *AdminHandler) {
+		h.scheduler = scheduler
+	}
+}
+
+func WithFaultInjector(faults *FaultInjector) AdminOption {
+	return func(h *AdminHandler) {
+		h.faults = faults
+	}
+}
+
+// WithUsage exposes API usage per client
+func WithUsage(repo ports.UsageRepository) AdminOption {
+	return func(h *AdminHandler) {
+		h.usage = repo
+	}
+}
+
+func NewAdminHandler(logger *Logger, opts ...AdminOption) *AdminHandler {
+	h := &AdminHandler{
+		logger: logger,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+type logSettings struct {
+	Level  string `json:"level,omitempty"`
+	Format string `json:"format,omitempty"`
+	// level is only set for duration if given, e.g. "15m"
+	Duration string `json:"duration,omitempty"`
+	// when level set for a duration is restored
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (h *AdminHandler) GetLogSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	settings := logSettings{
+		Level:  strings.ToLower(h.logger.Level().String()),
+		Format: h.logger.Format(),
+	}
+	if expires := h.logger.LevelExpires(); !expires.IsZero() {
+		settings.ExpiresAt = &expires
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settings)
+}
+
+// UpdateLogSettings changes level and/or format of the running logger,
+// omitted fields are left as they are. Level given with duration is
+// restored once duration passes
+func (h *AdminHandler) UpdateLogSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req logSettings
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if decodeErr := decoder.Decode(&req); decodeErr != nil {
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(fmt.Errorf("failed to decode payload: %w", decodeErr))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	// everything is validated before anything is applied, so that invalid
+	// request changes nothing
+	var err error
+	var message string
+	var duration time.Duration
+	switch {
+	case req.Level == "" && req.Format == "":
+		err = fmt.Errorf("admin handler error: neither level nor format provided")
+		message = "Invalid request body"
+	case req.Duration != "" && req.Level == "":
+		err = fmt.Errorf("admin handler error: duration provided without level")
+		message = "Invalid request body"
+	case req.Duration != "":
+		duration, err = time.ParseDuration(req.Duration)
+		if err == nil && duration <= 0 {
+			err = fmt.Errorf("admin handler error: duration must be positive, got %s", duration)
+		}
+		if err != nil {
+			message = "Invalid duration"
+		}
+	}
+	if err == nil && req.Level != "" {
+		if _, err = parseLevel(req.Level); err != nil {
+			message = "Invalid log level"
+		}
+	}
+	if err == nil && req.Format != "" {
+		if _, err = parseFormat(req.Format); err != nil {
+			message = "Invalid log format"
+		}
+	}
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	if err != nil {
+		errContainer.Add(err)
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, message)
+		return
+	}
+
+	switch {
+	case duration > 0:
+		err = h.logger.SetLevelFor(req.Level, duration)
+	case req.Level != "":
+		err = h.logger.SetLevel(req.Level)
+	}
+	if err == nil && req.Format != "" {
+		err = h.logger.SetFormat(req.Format)
+	}
+	if err != nil {
+		errContainer.Add(err)
+		writeServerError(w, r, err)
+		return
+	}
+
+	h.GetLogSettings(w, r)
+}
+
+func (h *AdminHandler) GetSyncReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.syncJob == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Supplier sync is not configured")
+		return
+	}
+	report := h.syncJob.LastReport()
+	if report == nil {
+		writeError(w, http.StatusNotFound, CodeNotFound, "Supplier sync has not run yet")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetJobs reports schedule and latest runs of every scheduled job
+func (h *AdminHandler) GetJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.scheduler == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Scheduler is not configured")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.scheduler.Jobs())
+}
+
+// RunSync triggers reconciliation right away and responds with its report
+func (h *AdminHandler) RunSync(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.syncJob == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Supplier sync is not configured")
+		return
+	}
+	report := h.syncJob.SyncOnce(r.Context())
+	if len(report.Errors) > 0 {
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(fmt.Errorf("admin handler error: supplier sync finished with %d error(s)", len(report.Errors)))
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+func (h *AdminHandler) GetBusinessMetrics(w http.ResponseWriter, r *http.Request) {
+	if h.businessMetrics == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Business metrics are not configured")
+		return
+	}
+	h.businessMetrics.ServeHTTP(w, r)
+}
+
+func (h *AdminHandler) GetFaultRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.faults == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Fault injection is not enabled")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.faults.Rules())
+}
+
+// UpdateFaultRules replaces all fault rules, an empty array disables faults
+func (h *AdminHandler) UpdateFaultRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.faults == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Fault injection is not enabled")
+		return
+	}
+	var rules []FaultRule
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&rules)
+	if err == nil {
+		err = h.faults.SetRules(rules)
+	}
+	if err != nil {
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(fmt.Errorf("admin handler error: invalid fault rules: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid fault rules")
+		return
+	}
+	h.GetFaultRules(w, r)
+}
+
+type clientUsage struct {
+	domain.Usage
+	ErrorRate float64 `json:"errorRate"`
+}
+
+type usageReport struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Clients []clientUsage `json:"clients"`
+}
+
+// GetUsage reports usage per client over days from and to (YYYY-MM-DD,
+// inclusive), last 30 days by default. Usage is flushed periodically, so the
+// latest requests may be missing
+func (h *AdminHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.usage == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Usage analytics are not enabled")
+		return
+	}
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -(defaultUsageDays - 1))
+	var err error
+	if value := r.URL.Query().Get("to"); value != "" {
+		to, err = time.Parse(time.DateOnly, value)
+	}
+	if value := r.URL.Query().Get("from"); err == nil && value != "" {
+		from, err = time.Parse(time.DateOnly, value)
+	}
+	if err == nil && from.After(to) {
+		err = fmt.Errorf("from %s is after to %s", from.Format(time.DateOnly), to.Format(time.DateOnly))
+	}
+	if err != nil {
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(fmt.Errorf("admin handler error: invalid usage range: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid date range")
+		return
+	}
+
+	usage, err := h.usage.GetUsage(r.Context(), from, to)
+	if err != nil {
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(err)
+		writeServerError(w, r, err)
+		return
+	}
+	report := usageReport{
+		From:    from.Format(time.DateOnly),
+		To:      to.Format(time.DateOnly),
+		Clients: make([]clientUsage, 0, len(usage)),
+	}
+	for _, u := range usage {
+		client := clientUsage{Usage: u}
+		if u.Requests > 0 {
+			client.ErrorRate = float64(u.Errors) / float64(u.Requests)
+		}
+		report.Clients = append(report.Clients, client)
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
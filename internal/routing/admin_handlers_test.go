@@ -0,0 +1,156 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers"
+)
+
+func TestUpdateLogSettings(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedLevel  slog.Level
+		expectedFormat string
+		expectedError  string
+	}{
+		{
+			name:           "update level - success",
+			body:           `{"level":"debug"}`,
+			expectedStatus: http.StatusOK,
+			expectedLevel:  slog.LevelDebug,
+			expectedFormat: LogFormatText,
+		},
+		{
+			name:           "update level and format - success",
+			body:           `{"level":"WARN","format":"json"}`,
+			expectedStatus: http.StatusOK,
+			expectedLevel:  slog.LevelWarn,
+			expectedFormat: LogFormatJSON,
+		},
+		{
+			name:           "update level for a while - success",
+			body:           `{"level":"debug","duration":"15m"}`,
+			expectedStatus: http.StatusOK,
+			expectedLevel:  slog.LevelDebug,
+			expectedFormat: LogFormatText,
+		},
+		{
+			name:           "update level for a while - invalid duration",
+			body:           `{"level":"debug","duration":"-1m"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedLevel:  slog.LevelInfo,
+			expectedFormat: LogFormatText,
+			expectedError:  "Invalid duration",
+		},
+		{
+			name:           "update - duration without level",
+			body:           `{"duration":"15m","format":"json"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedLevel:  slog.LevelInfo,
+			expectedFormat: LogFormatText,
+			expectedError:  "Invalid request body",
+		},
+		{
+			name:           "update level - unknown level",
+			body:           `{"level":"verbose"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedLevel:  slog.LevelInfo,
+			expectedFormat: LogFormatText,
+			expectedError:  "Invalid log level",
+		},
+		{
+			name:           "update level and format - unknown format changes nothing",
+			body:           `{"level":"debug","format":"xml"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedLevel:  slog.LevelInfo,
+			expectedFormat: LogFormatText,
+			expectedError:  "Invalid log format",
+		},
+		{
+			name:           "update level for a while - unknown format changes nothing",
+			body:           `{"level":"debug","duration":"15m","format":"xml"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedLevel:  slog.LevelInfo,
+			expectedFormat: LogFormatText,
+			expectedError:  "Invalid log format",
+		},
+		{
+			name:           "update format - unknown format",
+			body:           `{"format":"xml"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedLevel:  slog.LevelInfo,
+			expectedFormat: LogFormatText,
+			expectedError:  "Invalid log format",
+		},
+		{
+			name:           "update - empty body",
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedLevel:  slog.LevelInfo,
+			expectedFormat: LogFormatText,
+			expectedError:  "Invalid request body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+			require.NoError(t, err)
+			defer logger.Close()
+			router := logger.LoggerMiddleware(NewAdminRouter(NewAdminHandler(logger)).SetupRoutes())
+
+			req := httptest.NewRequest(http.MethodPut, "/admin/log", bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedLevel, logger.Level())
+			assert.Equal(t, tt.expectedFormat, logger.Format())
+
+			var response map[string]string
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+			if tt.expectedError != "" {
+				assert.Equal(t, tt.expectedError, response["error"])
+			} else {
+				assert.Equal(t, tt.expectedFormat, response["format"])
+			}
+		})
+	}
+}
+
+func TestGetJobs(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	scheduler := service.NewScheduler(logger.Slog())
+	require.NoError(t, scheduler.Register("supplier-sync", "0 */6 * * *", func(ctx context.Context) error { return nil }))
+
+	tests := []struct {
+		name    string
+		handler *AdminHandler
+	}{
+		{name: "get_jobs", handler: NewAdminHandler(logger, WithScheduler(scheduler))},
+		{name: "get_jobs_not_configured", handler: NewAdminHandler(logger)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := logger.LoggerMiddleware(NewAdminRouter(tt.handler).SetupRoutes())
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/jobs", nil))
+			testhelpers.AssertGolden(t, tt.name, rec)
+		})
+	}
+}
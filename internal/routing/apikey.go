@@ -0,0 +1,255 @@
+package routing
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// maxCachedAPIKeys bounds cache of lookups, unknown keys are cached too and
+// a client trying random keys must not grow it without limit
+const maxCachedAPIKeys = 10000
+
+// HashAPIKey is how keys are stored and looked up
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey returns a random key, 32 bytes hex encoded
+func GenerateAPIKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+type apiKeyEntry struct {
+	// zero for unknown or revoked keys
+	key       domain.APIKey
+	expiresAt time.Time
+}
+
+// APIKeyAuthenticator authenticates requests by X-API-Key header, making
+// principal the key was issued to the principal of request and granting it
+// role of the key. Lookups, of
+// unknown keys as well, are cached for ttl, so revoked keys keep working
+// until their entry expires
+type APIKeyAuthenticator struct {
+	keys ports.APIKeyRepository
+	// reads require a key too, otherwise only writes do
+	all bool
+	ttl time.Duration
+	now func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]apiKeyEntry
+}
+
+func NewAPIKeyAuthenticator(keys ports.APIKeyRepository, all bool, ttl time.Duration) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{
+		keys:  keys,
+		all:   all,
+		ttl:   ttl,
+		now:   time.Now,
+		cache: make(map[string]apiKeyEntry),
+	}
+}
+
+// Middleware rejects requests without a valid key with 401. Requests
+// already authenticated by client certificate or signature need no key.
+// Keys are checked whenever given, GraphQL mutations rely on that as the
+// request itself needs no key
+func (a *APIKeyAuthenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(apiKeyHeader)
+		if PrincipalFromContext(r.Context()) != "" || key == "" && !a.RequiresKey(requestAction(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		if key == "" {
+			errContainer.Add(errors.New("api key error: no API key provided"))
+			writeError(w, http.StatusUnauthorized, CodeUnauthenticated, "API key required")
+			return
+		}
+		ctx, err := a.Authenticate(r.Context(), key)
+		if err != nil {
+			errContainer.Add(fmt.Errorf("api key error: %w", err))
+			if errors.Is(err, domain.ErrUnauthenticated) {
+				writeError(w, http.StatusUnauthorized, CodeUnauthenticated, "Invalid API key")
+				return
+			}
+			writeServerError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Authenticate returns ctx with principal and role of key, or
+// ErrUnauthenticated if key is unknown or revoked. Calls over other
+// transports are authenticated by it too
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, key string) (context.Context, error) {
+	apiKey, err := a.lookup(ctx, HashAPIKey(key))
+	if err != nil {
+		return ctx, err
+	}
+	if apiKey.Principal == "" {
+		return ctx, fmt.Errorf("%w: unknown or revoked API key", domain.ErrUnauthenticated)
+	}
+	return WithRoles(WithPrincipal(ctx, apiKey.Principal), []domain.Role{apiKey.Role}), nil
+}
+
+// RequiresKey tells whether action can only be taken with a key
+func (a *APIKeyAuthenticator) RequiresKey(action domain.Action) bool {
+	return a.all || action != domain.ActionRead
+}
+
+// lookup returns key with hash, zero if key is unknown or revoked. Failures
+// to look key up are not cached
+func (a *APIKeyAuthenticator) lookup(ctx context.Context, hash string) (domain.APIKey, error) {
+	now := a.now()
+	a.mu.Lock()
+	entry, ok := a.cache[hash]
+	a.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.key, nil
+	}
+
+	key, err := a.keys.LookupAPIKey(ctx, hash)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return domain.APIKey{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.cache) >= maxCachedAPIKeys {
+		for k, e := range a.cache {
+			if !now.Before(e.expiresAt) {
+				delete(a.cache, k)
+			}
+		}
+		if len(a.cache) >= maxCachedAPIKeys {
+			clear(a.cache)
+		}
+	}
+	a.cache[hash] = apiKeyEntry{key: key, expiresAt: now.Add(a.ttl)}
+	return key, nil
+}
+
+// WithAPIKeys lets admins issue and revoke API keys
+func WithAPIKeys(keys ports.APIKeyRepository) AdminOption {
+	return func(h *AdminHandler) {
+		h.apiKeys = keys
+	}
+}
+
+type apiKeyRequest struct {
+	Principal string `json:"principal"`
+	// writer unless given
+	Role string `json:"role"`
+}
+
+type apiKeyResponse struct {
+	Id        int64       `json:"id"`
+	Principal string      `json:"principal"`
+	Role      domain.Role `json:"role"`
+	// only returned once, it can not be recovered from its hash
+	Key string `json:"key"`
+}
+
+// CreateAPIKey issues a new key to principal given in body, with role
+// given there. Like other
+// credentials, keys are only handed to authenticated admins
+func (h *AdminHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.apiKeys == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "API keys are not enabled")
+		return
+	}
+	if !authenticated(w, r) {
+		return
+	}
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	var request apiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		errContainer.Add(fmt.Errorf("admin handler error: failed to decode API key request: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	request.Principal = strings.TrimSpace(request.Principal)
+	if request.Principal == "" {
+		errContainer.Add(errors.New("admin handler error: API key principal is empty"))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Principal is required")
+		return
+	}
+	role := domain.RoleWriter
+	if request.Role != "" {
+		parsed, err := domain.ParseRole(request.Role)
+		if err != nil {
+			errContainer.Add(fmt.Errorf("admin handler error: %w", err))
+			writeError(w, http.StatusBadRequest, CodeInvalidBody, "Unknown role")
+			return
+		}
+		role = parsed
+	}
+	key, err := GenerateAPIKey()
+	if err != nil {
+		errContainer.Add(err)
+		writeServerError(w, r, err)
+		return
+	}
+	id, err := h.apiKeys.CreateAPIKey(r.Context(), domain.APIKey{Principal: request.Principal, Role: role}, HashAPIKey(key))
+	if err != nil {
+		errContainer.Add(err)
+		writeServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(apiKeyResponse{Id: id, Principal: request.Principal, Role: role, Key: key})
+}
+
+// RevokeAPIKey revokes key by id, instances keep accepting it while they
+// have it cached
+func (h *AdminHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.apiKeys == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "API keys are not enabled")
+		return
+	}
+	if !authenticated(w, r) {
+		return
+	}
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errContainer.Add(fmt.Errorf("admin handler error: invalid API key id: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidId, "Invalid API key id")
+		return
+	}
+	if err := h.apiKeys.RevokeAPIKey(r.Context(), id); err != nil {
+		errContainer.Add(err)
+		if errors.Is(err, domain.ErrNotFound) {
+			writeError(w, http.StatusNotFound, CodeNotFound, "API key not found")
+			return
+		}
+		writeServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,170 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	keys := fakes.NewAPIKeyRepository()
+	_, err := keys.CreateAPIKey(context.Background(), domain.APIKey{Principal: "partner", Role: domain.RoleWriter}, HashAPIKey("valid"))
+	require.NoError(t, err)
+	revoked, err := keys.CreateAPIKey(context.Background(), domain.APIKey{Principal: "former", Role: domain.RoleWriter}, HashAPIKey("revoked"))
+	require.NoError(t, err)
+	require.NoError(t, keys.RevokeAPIKey(context.Background(), revoked))
+
+	tests := []struct {
+		name              string
+		all               bool
+		method            string
+		path              string
+		key               string
+		principal         string
+		expectedStatus    int
+		expectedPrincipal string
+	}{
+		{name: "write with valid key", method: http.MethodPost, key: "valid", expectedStatus: http.StatusOK, expectedPrincipal: "partner"},
+		{name: "write without key", method: http.MethodDelete, expectedStatus: http.StatusUnauthorized},
+		{name: "write with unknown key", method: http.MethodPut, key: "guess", expectedStatus: http.StatusUnauthorized},
+		{name: "write with revoked key", method: http.MethodPatch, key: "revoked", expectedStatus: http.StatusUnauthorized},
+		{name: "read without key", method: http.MethodGet, expectedStatus: http.StatusOK},
+		{name: "read without key, all requests need one", all: true, method: http.MethodGet, expectedStatus: http.StatusUnauthorized},
+		{name: "read with key, all requests need one", all: true, method: http.MethodGet, key: "valid", expectedStatus: http.StatusOK, expectedPrincipal: "partner"},
+		{name: "already authenticated", method: http.MethodPost, principal: "cert-client", expectedStatus: http.StatusOK, expectedPrincipal: "cert-client"},
+		{name: "graphql without key", method: http.MethodPost, path: "/graphql", expectedStatus: http.StatusOK},
+		{name: "graphql with valid key", method: http.MethodPost, path: "/graphql", key: "valid", expectedStatus: http.StatusOK, expectedPrincipal: "partner"},
+		{name: "graphql with unknown key", method: http.MethodPost, path: "/graphql", key: "guess", expectedStatus: http.StatusUnauthorized},
+		{name: "graphql without key, all requests need one", all: true, method: http.MethodPost, path: "/graphql", expectedStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var principal string
+			var roles []domain.Role
+			handler := NewAPIKeyAuthenticator(keys, tt.all, time.Minute).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal = PrincipalFromContext(r.Context())
+				roles = RolesFromContext(r.Context())
+			}))
+
+			path := tt.path
+			if path == "" {
+				path = "/product/1"
+			}
+			req := httptest.NewRequest(tt.method, path, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			ctx := req.Context()
+			if tt.principal != "" {
+				ctx = WithPrincipal(ctx, tt.principal)
+			}
+			errs := domain.NewErrorContainer()
+			req = req.WithContext(context.WithValue(ctx, "errorContainer", &errs))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedStatus != http.StatusOK {
+				var body errorBody
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+				assert.Equal(t, CodeUnauthenticated, body.Code)
+				return
+			}
+			assert.Equal(t, tt.expectedPrincipal, principal)
+			if tt.key != "" {
+				assert.Equal(t, []domain.Role{domain.RoleWriter}, roles)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthenticatorCache(t *testing.T) {
+	keys := fakes.NewAPIKeyRepository()
+	id, err := keys.CreateAPIKey(context.Background(), domain.APIKey{Principal: "partner", Role: domain.RoleWriter}, HashAPIKey("valid"))
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	auth := NewAPIKeyAuthenticator(keys, false, time.Minute)
+	auth.now = func() time.Time { return now }
+	handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(key string) int {
+		req := httptest.NewRequest(http.MethodPost, "/product", nil)
+		req.Header.Set("X-API-Key", key)
+		errs := domain.NewErrorContainer()
+		req = req.WithContext(context.WithValue(req.Context(), "errorContainer", &errs))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("valid"))
+	assert.Equal(t, http.StatusUnauthorized, send("guess"))
+	require.NoError(t, keys.RevokeAPIKey(context.Background(), id))
+	assert.Equal(t, http.StatusOK, send("valid"), "revoked key works while cached")
+	assert.Equal(t, http.StatusUnauthorized, send("guess"))
+	assert.Equal(t, 2, keys.Lookups, "unknown keys are cached too")
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusUnauthorized, send("valid"))
+
+	keys.FailWith("LookupAPIKey", fmt.Errorf("%w: %w: connection refused", domain.ErrInternalDb, domain.ErrUnavailable))
+	assert.Equal(t, http.StatusServiceUnavailable, send("other"))
+	keys.FailWith("LookupAPIKey", nil)
+	assert.Equal(t, http.StatusUnauthorized, send("other"), "failures are not cached")
+}
+
+func TestAdminAPIKeys(t *testing.T) {
+	logger, err := NewLogger(t.TempDir() + "/test.log")
+	require.NoError(t, err)
+	defer logger.Close()
+	keys := fakes.NewAPIKeyRepository()
+	admin := NewAdminRouter(NewAdminHandler(logger, WithAPIKeys(keys))).SetupRoutes()
+	send := func(method, path, body, principal string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		ctx := req.Context()
+		if principal != "" {
+			ctx = WithPrincipal(ctx, principal)
+		}
+		errs := domain.NewErrorContainer()
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, req.WithContext(context.WithValue(ctx, "errorContainer", &errs)))
+		return rec
+	}
+
+	rec := send(http.MethodPost, "/admin/api-keys", `{"principal":"partner"}`, "ops")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created apiKeyResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+	assert.Equal(t, "partner", created.Principal)
+	assert.Equal(t, domain.RoleWriter, created.Role, "keys can write unless told otherwise")
+	key, err := keys.LookupAPIKey(context.Background(), HashAPIKey(created.Key))
+	require.NoError(t, err)
+	assert.Equal(t, domain.APIKey{Principal: "partner", Role: domain.RoleWriter}, key)
+
+	rec = send(http.MethodPost, "/admin/api-keys", `{"principal":"dashboard","role":"reader"}`, "ops")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var reader apiKeyResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&reader))
+	assert.Equal(t, domain.RoleReader, reader.Role)
+
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/admin/api-keys", `{"principal":"partner"}`, "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/admin/api-keys", `{"principal":" "}`, "ops").Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/admin/api-keys", `{"principal":"partner","role":"root"}`, "ops").Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodDelete, "/admin/api-keys/first", "", "ops").Code)
+
+	path := fmt.Sprintf("/admin/api-keys/%d", created.Id)
+	assert.Equal(t, http.StatusNoContent, send(http.MethodDelete, path, "", "ops").Code)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodDelete, path, "", "ops").Code)
+	_, err = keys.LookupAPIKey(context.Background(), HashAPIKey(created.Key))
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
@@ -0,0 +1,71 @@
+package routing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+type auditTrail struct {
+	Entries []domain.AuditEntry `json:"entries"`
+	// pass as before to get older entries, zero once there are none
+	Next int64 `json:"next,omitempty"`
+}
+
+// WithAuditTrail serves changes of products recorded by trail
+func WithAuditTrail(trail ports.AuditTrail) HandlerOption {
+	return func(h *ProductHandler) {
+		h.audit = trail
+	}
+}
+
+// GetProductAudit returns changes of product, newest first. Trail is read
+// page by page, passing next of a page as before of the following one.
+// Products deleted long ago still have their trail
+func (h *ProductHandler) GetProductAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	if h.audit == nil {
+		errContainer.Add(errors.New("handler error: audit trail is not configured"))
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Audit trail is not configured")
+		return
+	}
+	id, err := parseAndValidate(r.PathValue("id"), 0, "product id", errContainer, w)
+	if err != nil {
+		return
+	}
+	limit := int64(defaultAuditLimit)
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = parseAndValidate(value, 1, "limit", errContainer, w); err != nil {
+			return
+		}
+		limit = min(limit, maxAuditLimit)
+	}
+	var before int64
+	if value := r.URL.Query().Get("before"); value != "" {
+		if before, err = parseAndValidate(value, 1, "before", errContainer, w); err != nil {
+			return
+		}
+	}
+
+	entries, err := h.audit.ProductAudit(r.Context(), id, before, limit)
+	if err != nil {
+		errContainer.Add(err)
+		writeServerError(w, r, err)
+		return
+	}
+	trail := auditTrail{Entries: entries}
+	if int64(len(entries)) == limit {
+		trail.Next = entries[len(entries)-1].Id
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(trail)
+}
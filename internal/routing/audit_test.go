@@ -0,0 +1,95 @@
+package routing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestGetProductAudit(t *testing.T) {
+	trail := fakes.NewAuditTrail()
+	before := &domain.Product{Id: 1, Name: "Old", AdditionalInfo: "Info", Version: 1}
+	after := &domain.Product{Id: 1, Name: "New", AdditionalInfo: "Info", Version: 2}
+	trail.Record(domain.AuditEntry{ProductId: 1, Action: domain.AuditCreate, After: before})
+	trail.Record(domain.AuditEntry{ProductId: 2, Action: domain.AuditCreate})
+	trail.Record(domain.AuditEntry{ProductId: 1, Action: domain.AuditUpdate, Actor: "partner", Before: before, After: after})
+	trail.Record(domain.AuditEntry{ProductId: 1, Action: domain.AuditDelete, Actor: "admin", Before: after})
+
+	svc := service.NewResourceService(fakes.NewRepository(), fakes.NewCache())
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, WithAuditTrail(trail))).SetupRoutes())
+
+	get := func(path string) (*httptest.ResponseRecorder, auditTrail) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var body auditTrail
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		}
+		return rec, body
+	}
+
+	t.Run("returns trail newest first", func(t *testing.T) {
+		rec, body := get("/product/1/audit")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, body.Entries, 3)
+		assert.Equal(t, domain.AuditDelete, body.Entries[0].Action)
+		assert.Equal(t, "admin", body.Entries[0].Actor)
+		assert.Nil(t, body.Entries[0].After)
+		assert.Equal(t, before, body.Entries[1].Before)
+		assert.Equal(t, after, body.Entries[1].After)
+		assert.Nil(t, body.Entries[2].Before)
+		assert.Zero(t, body.Next)
+	})
+
+	t.Run("pages by before", func(t *testing.T) {
+		rec, body := get("/product/1/audit?limit=2")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, body.Entries, 2)
+		assert.Equal(t, int64(3), body.Next)
+
+		rec, body = get("/product/1/audit?limit=2&before=3")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, body.Entries, 1)
+		assert.Equal(t, domain.AuditCreate, body.Entries[0].Action)
+	})
+
+	t.Run("unknown product has empty trail", func(t *testing.T) {
+		rec, body := get("/product/42/audit")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, body.Entries)
+	})
+
+	t.Run("rejects invalid parameters", func(t *testing.T) {
+		for _, path := range []string{"/product/x/audit", "/product/1/audit?limit=0", "/product/1/audit?before=-1"} {
+			rec, _ := get(path)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+		}
+	})
+
+	t.Run("reports failures", func(t *testing.T) {
+		trail.FailWith("ProductAudit", errors.New("connection refused"))
+		defer trail.FailWith("ProductAudit", nil)
+		rec, _ := get("/product/1/audit")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("is not configured by default", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc)).SetupRoutes())
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/product/1/audit", nil))
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+}
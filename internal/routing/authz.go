@@ -0,0 +1,138 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type rolesKey struct{}
+
+// WithRoles stores roles granted to caller by its credentials in context
+func WithRoles(ctx context.Context, roles []domain.Role) context.Context {
+	return context.WithValue(ctx, rolesKey{}, roles)
+}
+
+// RolesFromContext returns roles granted to caller by its credentials, such
+// as API key or token
+func RolesFromContext(ctx context.Context) []domain.Role {
+	roles, _ := ctx.Value(rolesKey{}).([]domain.Role)
+	return roles
+}
+
+// ParseClientRoles parses "client1:writer,client2:admin"
+func ParseClientRoles(s string) (map[string]domain.Role, error) {
+	clientRoles := make(map[string]domain.Role)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		client, value, ok := strings.Cut(pair, ":")
+		if !ok || client == "" {
+			return nil, fmt.Errorf("invalid client role entry %q", pair)
+		}
+		role, err := domain.ParseRole(value)
+		if err != nil {
+			return nil, err
+		}
+		clientRoles[client] = role
+	}
+	return clientRoles, nil
+}
+
+// Authorizer enforces policy on requests, after they are authenticated.
+// Roles come from credentials of caller, or are assigned to clients by
+// principal, e.g. to clients presenting a certificate. Callers authenticated
+// without a role get authenticatedRole, anonymous ones anonymousRole. Empty
+// role grants nothing
+type Authorizer struct {
+	policy            domain.Policy
+	clientRoles       map[string]domain.Role
+	authenticatedRole domain.Role
+	anonymousRole     domain.Role
+}
+
+func NewAuthorizer(policy domain.Policy, clientRoles map[string]domain.Role, authenticatedRole domain.Role, anonymousRole domain.Role) *Authorizer {
+	return &Authorizer{
+		policy:            policy,
+		clientRoles:       clientRoles,
+		authenticatedRole: authenticatedRole,
+		anonymousRole:     anonymousRole,
+	}
+}
+
+// Middleware rejects requests policy does not allow, anonymous ones with
+// 401 as authenticating might help, others with 403
+func (a *Authorizer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action := requestAction(r)
+		err := a.Authorize(r.Context(), action)
+		if err == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(fmt.Errorf("authorization error: %w", err))
+		if errors.Is(err, domain.ErrUnauthenticated) {
+			writeError(w, http.StatusUnauthorized, CodeUnauthenticated, "Authentication required")
+			return
+		}
+		writeError(w, http.StatusForbidden, CodeForbidden, "Not allowed to "+strings.ReplaceAll(string(action), "_", " "))
+	})
+}
+
+// Authorize returns error unless policy allows caller in ctx to take
+// action. Anonymous callers get ErrUnauthenticated, as authenticating might
+// help, others ErrForbidden
+func (a *Authorizer) Authorize(ctx context.Context, action domain.Action) error {
+	err := a.policy.Authorize(a.roles(ctx), action)
+	if err == nil {
+		return nil
+	}
+	principal := PrincipalFromContext(ctx)
+	if principal == "" {
+		return fmt.Errorf("%w: anonymous caller: %w", domain.ErrUnauthenticated, err)
+	}
+	return fmt.Errorf("principal %q: %w", principal, err)
+}
+
+func (a *Authorizer) roles(ctx context.Context) []domain.Role {
+	// context is shared, roles in it must not be appended to in place
+	roles := slices.Clip(RolesFromContext(ctx))
+	principal := PrincipalFromContext(ctx)
+	if principal == "" {
+		return append(roles, a.anonymousRole)
+	}
+	if role, ok := a.clientRoles[principal]; ok {
+		return append(roles, role)
+	}
+	if len(roles) == 0 {
+		return []domain.Role{a.authenticatedRole}
+	}
+	return roles
+}
+
+// requestAction classifies request by method. Wiping the catalog is told
+// apart from other writes, it can not be undone. GraphQL requests are reads,
+// their mutations are authorized as writes by resolvers
+func requestAction(r *http.Request) domain.Action {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return domain.ActionRead
+	case http.MethodPost:
+		if r.URL.Path == "/graphql" {
+			return domain.ActionRead
+		}
+	case http.MethodDelete:
+		if r.URL.Path == "/products" {
+			return domain.ActionDeleteAll
+		}
+	}
+	return domain.ActionWrite
+}
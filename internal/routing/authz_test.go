@@ -0,0 +1,153 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestAuthorizer(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	authorizer := NewAuthorizer(domain.DefaultPolicy(), map[string]domain.Role{"ops": domain.RoleAdmin}, domain.RoleWriter, domain.RoleReader)
+	svc := service.NewResourceService(fakes.NewRepository(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"}), fakes.NewCache())
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, WithAuthorizer(authorizer))).SetupRoutes())
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		principal      string
+		roles          []domain.Role
+		expectedStatus int
+		expectedCode   ErrorCode
+	}{
+		{name: "anonymous read", method: http.MethodGet, path: "/product/1", expectedStatus: http.StatusOK},
+		{name: "anonymous write", method: http.MethodPut, path: "/product/1", body: `{"name":"New","additionalInfo":"Info"}`, expectedStatus: http.StatusUnauthorized, expectedCode: CodeUnauthenticated},
+		{name: "reader write", method: http.MethodPost, path: "/product", body: `{"name":"New","additionalInfo":"Info"}`, principal: "dashboard", roles: []domain.Role{domain.RoleReader}, expectedStatus: http.StatusForbidden, expectedCode: CodeForbidden},
+		{name: "writer write", method: http.MethodPost, path: "/product", body: `{"name":"New","additionalInfo":"Info"}`, principal: "partner", roles: []domain.Role{domain.RoleWriter}, expectedStatus: http.StatusCreated},
+		{name: "authenticated without role writes", method: http.MethodDelete, path: "/product/1", principal: "cert-client", expectedStatus: http.StatusOK},
+		{name: "writer deletes all", method: http.MethodDelete, path: "/products", principal: "partner", roles: []domain.Role{domain.RoleWriter}, expectedStatus: http.StatusForbidden, expectedCode: CodeForbidden},
+		{name: "client role deletes all", method: http.MethodDelete, path: "/products", principal: "ops", expectedStatus: http.StatusOK},
+		{name: "admin deletes all", method: http.MethodDelete, path: "/products", principal: "root", roles: []domain.Role{domain.RoleAdmin}, expectedStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			ctx := req.Context()
+			if tt.principal != "" {
+				ctx = WithPrincipal(ctx, tt.principal)
+			}
+			if tt.roles != nil {
+				ctx = WithRoles(ctx, tt.roles)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req.WithContext(ctx))
+
+			require.Equal(t, tt.expectedStatus, rec.Code, rec.Body.String())
+			if tt.expectedCode != "" {
+				var body errorBody
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+				assert.Equal(t, tt.expectedCode, body.Code)
+			}
+		})
+	}
+}
+
+func TestAuthorizerBatch(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	authorizer := NewAuthorizer(domain.DefaultPolicy(), nil, domain.RoleWriter, "")
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"})
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(service.NewResourceService(repo, fakes.NewCache()), WithAuthorizer(authorizer))).SetupRoutes())
+
+	batch := `[{"method":"GET","path":"/product/1"},{"method":"DELETE","path":"/products"}]`
+	req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(batch))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req.WithContext(WithPrincipal(context.Background(), "partner")))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var results []batchResult
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&results))
+	require.Len(t, results, 2)
+	assert.Equal(t, http.StatusOK, results[0].Status)
+	assert.Equal(t, http.StatusForbidden, results[1].Status, "operations are authorized one by one")
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/product/1", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "anonymous callers get no role")
+}
+
+func TestParseClientRoles(t *testing.T) {
+	roles, err := ParseClientRoles("ops:admin, partner:writer")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]domain.Role{"ops": domain.RoleAdmin, "partner": domain.RoleWriter}, roles)
+
+	_, err = ParseClientRoles("ops:root")
+	assert.Error(t, err)
+	_, err = ParseClientRoles("ops")
+	assert.Error(t, err)
+}
+
+func TestAuthorizerGraphQL(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	authorizer := NewAuthorizer(domain.DefaultPolicy(), nil, domain.RoleWriter, domain.RoleReader)
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"})
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(service.NewResourceService(repo, fakes.NewCache()), WithAuthorizer(authorizer))).SetupRoutes())
+	do := func(t *testing.T, ctx context.Context, query string) graphQLResponse {
+		t.Helper()
+		body, err := json.Marshal(map[string]string{"query": query})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))).WithContext(ctx))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp graphQLResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+	reader := WithRoles(WithPrincipal(context.Background(), "dashboard"), []domain.Role{domain.RoleReader})
+	mutation := `mutation { createProduct(name: "New", additionalInfo: "Info") { id } }`
+
+	t.Run("reader runs query", func(t *testing.T) {
+		resp := do(t, reader, `{ product(id: "1") { name } }`)
+		assert.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"name": "First"}`, string(resp.Data["product"]))
+	})
+
+	t.Run("reader is refused mutation", func(t *testing.T) {
+		resp := do(t, reader, mutation)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, string(CodeForbidden), resp.Errors[0].Extensions["code"])
+		assert.Len(t, repo.Products(), 1)
+	})
+
+	t.Run("anonymous caller is asked to authenticate", func(t *testing.T) {
+		resp := do(t, context.Background(), mutation)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, string(CodeUnauthenticated), resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("writer runs mutation", func(t *testing.T) {
+		resp := do(t, WithPrincipal(context.Background(), "partner"), mutation)
+		assert.Empty(t, resp.Errors)
+		assert.Len(t, repo.Products(), 2)
+	})
+}
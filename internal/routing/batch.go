@@ -0,0 +1,97 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+const maxBatchOperations = 100
+
+type batchOperation struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+type batchResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// batchHandler runs sub-operations one by one against the same routes, so
+// each of them behaves exactly like a standalone request. Operations are not
+// transactional: writes go through cache and event publishing as usual, so
+// a failed operation does not roll back the preceding ones. With
+// ?stopOnError=true remaining operations are skipped after the first failure
+func batchHandler(routes http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+
+		var operations []batchOperation
+		err := decodeBody(r.Body, &operations)
+		if err == nil {
+			err = validateBatch(operations)
+		}
+		if err != nil {
+			errContainer.Add(fmt.Errorf("handler error: invalid batch: %w", err))
+			writeError(w, http.StatusBadRequest, CodeInvalidBatch, "Invalid batch")
+			return
+		}
+		stopOnError := r.URL.Query().Get("stopOnError") == "true"
+
+		results := make([]batchResult, 0, len(operations))
+		for i, op := range operations {
+			req, err := http.NewRequestWithContext(r.Context(), op.Method, op.Path, bytes.NewReader(op.Body))
+			if err != nil {
+				errContainer.Add(fmt.Errorf("handler error: batch operation #%d: %w", i, err))
+				results = append(results, batchResult{Status: http.StatusBadRequest})
+			} else {
+				req.Header.Set("Content-Type", "application/json")
+				for name, value := range op.Headers {
+					req.Header.Set(name, value)
+				}
+				rec := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+				routes.ServeHTTP(rec, req)
+				result := batchResult{Status: rec.status}
+				if json.Valid(rec.body.Bytes()) {
+					result.Body = bytes.TrimSpace(rec.body.Bytes())
+				}
+				results = append(results, result)
+			}
+			if stopOnError && results[i].Status >= http.StatusBadRequest {
+				break
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(results)
+	}
+}
+
+func validateBatch(operations []batchOperation) error {
+	if len(operations) == 0 {
+		return errors.New("no operations")
+	}
+	if len(operations) > maxBatchOperations {
+		return fmt.Errorf("%d operations exceed limit of %d", len(operations), maxBatchOperations)
+	}
+	for i, op := range operations {
+		switch {
+		case op.Method == "":
+			return fmt.Errorf("operation #%d: method is empty", i)
+		case !strings.HasPrefix(op.Path, "/"):
+			return fmt.Errorf("operation #%d: path must be absolute", i)
+		case op.Path == "/batch" || strings.HasPrefix(op.Path, "/batch?"):
+			return fmt.Errorf("operation #%d: batches can not be nested", i)
+		}
+	}
+	return nil
+}
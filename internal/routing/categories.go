@@ -0,0 +1,153 @@
+package routing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// category names fit the same column size as product names
+const maxCategoryNameLength = 50
+
+// WithCategories serves categories of products from categories
+func WithCategories(categories ports.CategoryRepository) HandlerOption {
+	return func(h *ProductHandler) {
+		h.categories = categories
+	}
+}
+
+type categoryRequest struct {
+	Name string `json:"name"`
+}
+
+// categoriesConfigured responds with 501 unless categories are served
+func (h *ProductHandler) categoriesConfigured(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Content-Type", "application/json")
+	if h.categories != nil {
+		return true
+	}
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	errContainer.Add(errors.New("handler error: categories are not configured"))
+	writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Categories are not configured")
+	return false
+}
+
+// decodeCategory reads category name from body, responding with 400 if it is
+// missing or too long
+func decodeCategory(w http.ResponseWriter, r *http.Request) (string, bool) {
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	var request categoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		errContainer.Add(fmt.Errorf("handler error: failed to decode category: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return "", false
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxCategoryNameLength {
+		errContainer.Add(fmt.Errorf("handler error: invalid category name %q", request.Name))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Category name is required, at most 50 characters")
+		return "", false
+	}
+	return name, true
+}
+
+// writeCategoryError responds to failure of category repository
+func writeCategoryError(w http.ResponseWriter, r *http.Request, err error) {
+	r.Context().Value("errorContainer").(*domain.ErrorContainer).Add(err)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		writeError(w, http.StatusNotFound, CodeNotFound, "Category not found")
+	case errors.Is(err, domain.ErrCategoryInUse):
+		writeError(w, http.StatusConflict, CodeConflict, "Category has products")
+	default:
+		writeServerError(w, r, err)
+	}
+}
+
+func (h *ProductHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	if !h.categoriesConfigured(w, r) {
+		return
+	}
+	categories, err := h.categories.ListCategories(r.Context())
+	if err != nil {
+		writeCategoryError(w, r, err)
+		return
+	}
+	h.json.write(w, http.StatusOK, categories)
+}
+
+func (h *ProductHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	if !h.categoriesConfigured(w, r) {
+		return
+	}
+	name, ok := decodeCategory(w, r)
+	if !ok {
+		return
+	}
+	id, err := h.categories.StoreCategory(r.Context(), name)
+	if err != nil {
+		writeCategoryError(w, r, err)
+		return
+	}
+	h.json.write(w, http.StatusCreated, domain.Category{Id: id, Name: name})
+}
+
+func (h *ProductHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	if !h.categoriesConfigured(w, r) {
+		return
+	}
+	id, err := parseAndValidate(r.PathValue("id"), 1, "category id", r.Context().Value("errorContainer").(*domain.ErrorContainer), w)
+	if err != nil {
+		return
+	}
+	category, err := h.categories.GetCategory(r.Context(), id)
+	if err != nil {
+		writeCategoryError(w, r, err)
+		return
+	}
+	h.json.write(w, http.StatusOK, category)
+}
+
+// UpdateCategory renames category, products stay in it
+func (h *ProductHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	if !h.categoriesConfigured(w, r) {
+		return
+	}
+	id, err := parseAndValidate(r.PathValue("id"), 1, "category id", r.Context().Value("errorContainer").(*domain.ErrorContainer), w)
+	if err != nil {
+		return
+	}
+	name, ok := decodeCategory(w, r)
+	if !ok {
+		return
+	}
+	category := domain.Category{Id: id, Name: name}
+	if err := h.categories.UpdateCategory(r.Context(), category); err != nil {
+		writeCategoryError(w, r, err)
+		return
+	}
+	h.json.write(w, http.StatusOK, category)
+}
+
+// DeleteCategory fails with 409 while products belong to category, they
+// have to be moved or deleted first
+func (h *ProductHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	if !h.categoriesConfigured(w, r) {
+		return
+	}
+	id, err := parseAndValidate(r.PathValue("id"), 1, "category id", r.Context().Value("errorContainer").(*domain.ErrorContainer), w)
+	if err != nil {
+		return
+	}
+	if err := h.categories.DeleteCategory(r.Context(), id); err != nil {
+		writeCategoryError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,125 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestCategories(t *testing.T) {
+	repo := fakes.NewRepository()
+	svc := service.NewResourceService(repo, fakes.NewCache())
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, WithCategories(repo))).SetupRoutes())
+
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	code := func(rec *httptest.ResponseRecorder) ErrorCode {
+		var body errorBody
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		return body.Code
+	}
+
+	rec := do(http.MethodPost, "/categories", `{"name":" Lamps "}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var lamps domain.Category
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&lamps))
+	assert.Equal(t, domain.Category{Id: 1, Name: "Lamps"}, lamps)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/categories", `{"name":"Chairs"}`).Code)
+
+	t.Run("lists categories", func(t *testing.T) {
+		rec := do(http.MethodGet, "/categories", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var categories []domain.Category
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&categories))
+		assert.Equal(t, []domain.Category{{Id: 1, Name: "Lamps"}, {Id: 2, Name: "Chairs"}}, categories)
+	})
+
+	t.Run("rejects invalid names", func(t *testing.T) {
+		for _, body := range []string{`{"name":"  "}`, `{"name":"` + strings.Repeat("x", 51) + `"}`, `{`} {
+			rec := do(http.MethodPost, "/categories", body)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		}
+	})
+
+	t.Run("renames category", func(t *testing.T) {
+		rec := do(http.MethodPut, "/categories/2", `{"name":"Seats"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		rec = do(http.MethodGet, "/categories/2", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id":2,"name":"Seats"}`, rec.Body.String())
+
+		rec = do(http.MethodPut, "/categories/9", `{"name":"Desks"}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, CodeNotFound, code(rec))
+	})
+
+	t.Run("products are filtered by category", func(t *testing.T) {
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/product", `{"name":"Lamp","additionalInfo":"Brass","categoryId":1}`).Code)
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/product", `{"name":"Chair","additionalInfo":"Oak","categoryId":2}`).Code)
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/product", `{"name":"Desk","additionalInfo":"Pine"}`).Code)
+
+		rec := do(http.MethodGet, "/products?category=1", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var products []domain.Product
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&products))
+		require.Len(t, products, 1)
+		assert.Equal(t, "Lamp", products[0].Name)
+		assert.Equal(t, int64(1), products[0].CategoryId)
+
+		rec = do(http.MethodGet, "/products?category=lamps", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("products refer to existing categories", func(t *testing.T) {
+		rec := do(http.MethodPost, "/product", `{"name":"Sofa","additionalInfo":"Velvet","categoryId":9}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, CodeUnknownCategory, code(rec))
+
+		rec = do(http.MethodPut, "/product/3", `{"name":"Desk","additionalInfo":"Pine","categoryId":9}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, CodeUnknownCategory, code(rec))
+
+		rec = do(http.MethodPost, "/product", `{"name":"Sofa","additionalInfo":"Velvet","categoryId":-1}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, CodeInvalidBody, code(rec))
+	})
+
+	t.Run("category with products is not deleted", func(t *testing.T) {
+		rec := do(http.MethodDelete, "/categories/1", "")
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPatch, "/product/1", strings.NewReader(`{"categoryId":null}`))
+		req.Header.Set("Content-Type", contentTypeMergePatch)
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/categories/1", "").Code)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/categories/1", "").Code)
+	})
+
+	t.Run("is not configured by default", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc)).SetupRoutes())
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/categories", nil))
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+}
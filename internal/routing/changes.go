@@ -0,0 +1,70 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+type changes struct {
+	Changes []domain.ProductEvent `json:"changes"`
+	Cursor  int64                 `json:"cursor"`
+}
+
+// WithChangeFeed enables long polling for changes, holding requests for at
+// most maxWait
+func WithChangeFeed(feed ports.ChangeFeed, maxWait time.Duration) HandlerOption {
+	return func(h *ProductHandler) {
+		h.changes = feed
+		h.maxChangesWait = maxWait
+	}
+}
+
+// WaitForChanges answers as soon as there are changes after since cursor, or
+// with no changes once timeout elapses. Without since it waits for the next
+// change. Clients pass returned cursor to the next call
+func (h *ProductHandler) WaitForChanges(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	if h.changes == nil {
+		errContainer.Add(errors.New("handler error: change feed is not configured"))
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Change feed is not configured")
+		return
+	}
+	cursor := h.changes.Cursor()
+	if value := r.URL.Query().Get("since"); value != "" {
+		since, err := parseAndValidate(value, 0, "since", errContainer, w)
+		if err != nil {
+			return
+		}
+		cursor = since
+	}
+	wait := h.maxChangesWait
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		timeout, err := parseRequestTimeout(value)
+		if err != nil {
+			errContainer.Add(fmt.Errorf("handler error: %w", err))
+			writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid timeout")
+			return
+		}
+		wait = min(timeout, h.maxChangesWait)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+	events, cursor, err := h.changes.Wait(ctx, cursor)
+	if err != nil {
+		errContainer.Add(fmt.Errorf("handler error: %w", err))
+		writeError(w, http.StatusGone, CodeCursorExpired, "Changes since cursor are no longer available")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(changes{Changes: events, Cursor: cursor})
+}
@@ -0,0 +1,76 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/adapters/events"
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestWaitForChanges(t *testing.T) {
+	feed := events.NewChangeFeed(10)
+	svc := service.NewResourceService(fakes.NewRepository(), fakes.NewCache(), service.WithEventPublisher(feed))
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, WithChangeFeed(feed, time.Second))).SetupRoutes())
+
+	wait := func(path string) (*httptest.ResponseRecorder, changes) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var body changes
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		}
+		return rec, body
+	}
+
+	t.Run("times out without changes", func(t *testing.T) {
+		start := time.Now()
+		rec, body := wait("/products/changes/wait?since=0&timeout=20ms")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, body.Changes)
+		assert.Equal(t, int64(0), body.Cursor)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("answers once product changes", func(t *testing.T) {
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			svc.CreateProduct(context.Background(), domain.NewProduct{Name: "New", AdditionalInfo: "Created"})
+		}()
+		rec, body := wait("/products/changes/wait?since=0")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, body.Changes, 1)
+		assert.Equal(t, domain.EventProductCreated, body.Changes[0].Type)
+		assert.Equal(t, int64(1), body.Cursor)
+	})
+
+	t.Run("returns changes missed since cursor", func(t *testing.T) {
+		rec, body := wait("/products/changes/wait?since=0")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, body.Changes, 1)
+	})
+
+	t.Run("rejects unknown cursor", func(t *testing.T) {
+		rec, _ := wait("/products/changes/wait?since=5")
+		testhelpers.AssertGolden(t, "wait_changes_cursor_expired", rec)
+	})
+
+	t.Run("rejects invalid timeout", func(t *testing.T) {
+		rec, _ := wait("/products/changes/wait?timeout=soon")
+		testhelpers.AssertGolden(t, "wait_changes_invalid_timeout", rec)
+	})
+}
@@ -0,0 +1,59 @@
+package routing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// defaultMaxDecompressedBodyBytes bounds gzip request bodies once inflated
+const defaultMaxDecompressedBodyBytes = 10 << 20
+
+// decompressMiddleware transparently inflates gzip encoded request bodies,
+// so that handlers decode JSON as usual. Inflated body is limited to
+// maxBytes, a few kilobytes of gzip may expand to gigabytes otherwise
+func decompressMiddleware(maxBytes int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if encoding == "" || encoding == "identity" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		if encoding != "gzip" {
+			errContainer.Add(fmt.Errorf("%w: unsupported content encoding %q", domain.ErrInvalidInput, encoding))
+			writeError(w, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "Unsupported Content-Encoding, only gzip is supported")
+			return
+		}
+
+		reader, err := gzip.NewReader(r.Body)
+		if err == nil {
+			defer reader.Close()
+			var body []byte
+			body, err = io.ReadAll(io.LimitReader(reader, maxBytes+1))
+			if err == nil && int64(len(body)) > maxBytes {
+				errContainer.Add(fmt.Errorf("%w: decompressed body exceeds %d bytes", domain.ErrInvalidInput, maxBytes))
+				writeError(w, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "Decompressed request body is too large")
+				return
+			}
+			if err == nil {
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				r.ContentLength = int64(len(body))
+				r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+				r.Header.Del("Content-Encoding")
+			}
+		}
+		if err != nil {
+			errContainer.Add(fmt.Errorf("%w: failed to decompress body: %s", domain.ErrInvalidInput, err.Error()))
+			writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid gzip request body")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
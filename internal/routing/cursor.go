@@ -0,0 +1,114 @@
+package routing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+const nextCursorHeader = "X-Next-Cursor"
+
+// productCursor is position in product list, the last product of a page
+// reduced to what list is sorted by. Sort is kept to tell cursors of other
+// listings apart
+type productCursor struct {
+	Sort           string  `json:"s,omitempty"`
+	Id             int64   `json:"id"`
+	Name           *string `json:"n,omitempty"`
+	AdditionalInfo *string `json:"i,omitempty"`
+}
+
+// encodeCursor makes opaque token of position after product
+func encodeCursor(sort domain.ProductSort, product domain.Product) string {
+	cursor := productCursor{Sort: sort.String(), Id: product.Id}
+	for _, f := range sort {
+		switch f.Field {
+		case "name":
+			cursor.Name = &product.Name
+		case "additionalInfo":
+			cursor.AdditionalInfo = &product.AdditionalInfo
+		}
+	}
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor reads position from after parameter: either a token given
+// out with previous page of the same listing, or a product id when
+// listing is not sorted. Empty after starts from the beginning
+func decodeCursor(after string, sort domain.ProductSort) (*domain.Product, error) {
+	if after == "" {
+		return nil, nil
+	}
+	if id, err := strconv.ParseInt(after, 10, 64); err == nil {
+		if len(sort) > 0 {
+			return nil, fmt.Errorf("%w: sorted listing needs cursor token, not id", domain.ErrInvalidInput)
+		}
+		return &domain.Product{Id: id}, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(after)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor: %s", domain.ErrInvalidInput, err.Error())
+	}
+	var cursor productCursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor: %s", domain.ErrInvalidInput, err.Error())
+	}
+	if cursor.Sort != sort.String() {
+		return nil, fmt.Errorf("%w: cursor of listing sorted by %q used for %q", domain.ErrInvalidInput, cursor.Sort, sort.String())
+	}
+	product := &domain.Product{Id: cursor.Id}
+	for _, f := range sort {
+		switch {
+		case f.Field == "name" && cursor.Name != nil:
+			product.Name = *cursor.Name
+		case f.Field == "additionalInfo" && cursor.AdditionalInfo != nil:
+			product.AdditionalInfo = *cursor.AdditionalInfo
+		case f.Field == "name" || f.Field == "additionalInfo":
+			return nil, fmt.Errorf("%w: cursor lacks %s", domain.ErrInvalidInput, f.Field)
+		}
+	}
+	return product, nil
+}
+
+// getProductsAfter serves keyset pages. Pages followed by more products
+// carry cursor of their last product in X-Next-Cursor and in next link
+func (h *ProductHandler) getProductsAfter(w http.ResponseWriter, r *http.Request, filter domain.ProductFilter, sort domain.ProductSort) {
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	limit, err := parseAndValidate(r.URL.Query().Get("limit"), 1, "limit", errContainer, w)
+	if err != nil {
+		return
+	}
+	after, err := decodeCursor(r.URL.Query().Get("after"), sort)
+	if err != nil {
+		errContainer.Add(fmt.Errorf("handler error: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid cursor")
+		return
+	}
+
+	// one more product tells whether there is next page
+	products, serviceErr := h.svc.GetProductsAfter(r.Context(), filter, sort, after, limit+1)
+	if serviceErr != nil {
+		storeServiceErrToCtx(r.Context(), serviceErr)
+		if serviceErr.CriticalError != nil {
+			writeListError(w, r, serviceErr.CriticalError)
+			return
+		}
+	}
+	page := pageMeta{Limit: limit}
+	if int64(len(products)) > limit {
+		products = products[:limit]
+		page.NextCursor = encodeCursor(sort, products[len(products)-1])
+		page.HasMore = true
+		w.Header().Set(nextCursorHeader, page.NextCursor)
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, h.links.collectionAfter(r.URL.Query(), page.NextCursor)))
+	}
+	if !h.describePage(w, r, filter, page) {
+		return
+	}
+	h.writeProducts(w, r, products)
+}
@@ -0,0 +1,425 @@
+package routing
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+const (
+	dumpContentType   = "application/gzip"
+	ndjsonContentType = "application/x-ndjson"
+	// maxDumpLineBytes is far more than a product takes, it only stops
+	// reading garbage endlessly
+	maxDumpLineBytes = 1 << 20
+
+	restoreModeMerge    = "merge"
+	restoreModeTruncate = "truncate"
+
+	// what restore does with rows matching existing products
+	duplicatesOverwrite = "overwrite"
+	duplicatesSkip      = "skip"
+	duplicatesMerge     = "merge"
+
+	// how rows are matched to existing products
+	matchById   = "id"
+	matchByName = "name"
+
+	// maxReportedRowErrors keeps report of a thoroughly broken dump from
+	// outgrowing the dump itself
+	maxReportedRowErrors = 1000
+)
+
+type restoreReport struct {
+	Mode     string `json:"mode"`
+	Restored int    `json:"restored"`
+	Created  int    `json:"created"`
+	Updated  int    `json:"updated"`
+	Skipped  int    `json:"skipped"`
+	Deleted  int64  `json:"deleted"`
+}
+
+// dumpRowError is a problem with one line of dump. Field is empty if line
+// is not a product at all
+type dumpRowError struct {
+	Line   int    `json:"line"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (e dumpRowError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+	}
+	return fmt.Sprintf("line %d: %s %s", e.Line, e.Field, e.Reason)
+}
+
+// validationReport answers dry-run restore
+type validationReport struct {
+	Rows      int            `json:"rows"`
+	Valid     int            `json:"valid"`
+	Errors    []dumpRowError `json:"errors"`
+	Truncated bool           `json:"truncated,omitempty"`
+}
+
+// WithDataset enables dump and restore of all products. They go through
+// service, so cache and events stay consistent
+func WithDataset(svc ports.ResourseService) AdminOption {
+	return func(h *AdminHandler) {
+		h.dataset = svc
+	}
+}
+
+// authenticated lets through requests with a principal only, such as from
+// clients presenting a certificate
+func authenticated(w http.ResponseWriter, r *http.Request) bool {
+	if PrincipalFromContext(r.Context()) != "" {
+		return true
+	}
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	errContainer.Add(errors.New("admin handler error: request is not authenticated"))
+	writeError(w, http.StatusUnauthorized, CodeUnauthenticated, "Authentication required")
+	return false
+}
+
+// DumpProducts streams all products as gzip compressed NDJSON, one product
+// per line. Failure past the first page leaves the dump incomplete
+func (h *AdminHandler) DumpProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.dataset == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Dataset dump is not enabled")
+		return
+	}
+	if !authenticated(w, r) {
+		return
+	}
+
+	var archive *gzip.Writer
+	var encoder *json.Encoder
+	err := exportProducts(r.Context(), h.dataset, func(page []domain.Product) error {
+		if archive == nil {
+			w.Header().Set("Content-Type", dumpContentType)
+			w.Header().Set("Content-Disposition", `attachment; filename="products.ndjson.gz"`)
+			w.WriteHeader(http.StatusOK)
+			archive = gzip.NewWriter(w)
+			encoder = json.NewEncoder(archive)
+		}
+		for _, p := range page {
+			if err := encoder.Encode(p); err != nil {
+				return fmt.Errorf("dump error: %w", err)
+			}
+		}
+		return nil
+	})
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	switch {
+	case err != nil && archive == nil:
+		writeServerError(w, r, err)
+	case err != nil:
+		errContainer.Add(errors.New("dump error: dump cut short"))
+	default:
+		if err := archive.Close(); err != nil {
+			errContainer.Add(fmt.Errorf("dump error: %w", err))
+		}
+	}
+}
+
+// RestoreProducts loads a dump made by DumpProducts. In merge mode products
+// from dump are created or overwritten and the rest is left intact, truncate
+// mode deletes all products first. Dump is validated as a whole before any
+// change, but restore itself is not atomic: if it fails midway, products
+// restored so far stay. With dryRun=true dump is only validated, response
+// lists problems of every line so that they can be fixed at once.
+//
+// In merge mode rows are matched to existing products by matchBy (id or
+// name), duplicates decides what happens to matched ones: overwrite, skip,
+// or merge, where fields left empty in the row keep their current values
+func (h *AdminHandler) RestoreProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.dataset == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Dataset restore is not enabled")
+		return
+	}
+	if !authenticated(w, r) {
+		return
+	}
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	mode := r.URL.Query().Get("mode")
+	if mode != restoreModeMerge && mode != restoreModeTruncate {
+		errContainer.Add(fmt.Errorf("admin handler error: invalid restore mode %q", mode))
+		writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid restore mode")
+		return
+	}
+	dryRun := false
+	if value := r.URL.Query().Get("dryRun"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			errContainer.Add(fmt.Errorf("admin handler error: invalid dryRun %q", value))
+			writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid dryRun")
+			return
+		}
+	}
+	duplicates, ok := queryChoice(r, "duplicates", duplicatesOverwrite, duplicatesSkip, duplicatesMerge)
+	if !ok {
+		errContainer.Add(fmt.Errorf("admin handler error: invalid duplicates %q", r.URL.Query().Get("duplicates")))
+		writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid duplicates")
+		return
+	}
+	matchBy, ok := queryChoice(r, "matchBy", matchById, matchByName)
+	if !ok {
+		errContainer.Add(fmt.Errorf("admin handler error: invalid matchBy %q", r.URL.Query().Get("matchBy")))
+		writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid matchBy")
+		return
+	}
+	dump, err := readDump(r, duplicates == duplicatesMerge)
+	if err != nil {
+		errContainer.Add(fmt.Errorf("admin handler error: invalid dump: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid dump")
+		return
+	}
+
+	// truncate leaves nothing to match, plain overwrite by id is what
+	// upserts do anyway
+	var existing []domain.Product
+	if mode == restoreModeMerge && (matchBy != matchById || duplicates != duplicatesOverwrite) {
+		err := exportProducts(r.Context(), h.dataset, func(page []domain.Product) error {
+			existing = append(existing, page...)
+			return nil
+		})
+		if err != nil {
+			writeServerError(w, r, err)
+			return
+		}
+	}
+	steps, rowErrors := planRestore(dump, existing, matchBy, duplicates)
+
+	if dryRun {
+		report := validationReport{Rows: dump.rows, Valid: len(steps), Errors: rowErrors}
+		if len(report.Errors) > maxReportedRowErrors {
+			report.Errors, report.Truncated = report.Errors[:maxReportedRowErrors], true
+		}
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+	if len(rowErrors) > 0 {
+		errContainer.Add(fmt.Errorf("admin handler error: invalid dump: %w", rowErrors[0]))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid dump")
+		return
+	}
+
+	report := restoreReport{Mode: mode}
+	if mode == restoreModeTruncate {
+		deleted, serviceErr := h.dataset.DeleteAllProducts(r.Context())
+		if serviceErr != nil {
+			storeServiceErrToCtx(r.Context(), serviceErr)
+			if serviceErr.CriticalError != nil {
+				writeServerError(w, r, serviceErr.CriticalError)
+				return
+			}
+		}
+		report.Deleted = deleted
+	}
+	for _, step := range steps {
+		if step.skip {
+			report.Skipped++
+			continue
+		}
+		created, serviceErr := h.dataset.UpsertProduct(r.Context(), step.product)
+		if serviceErr != nil {
+			storeServiceErrToCtx(r.Context(), serviceErr)
+			if serviceErr.CriticalError != nil {
+				errContainer.Add(fmt.Errorf("restore error: stopped after %d of %d products", report.Restored, len(steps)))
+				writeServerError(w, r, serviceErr.CriticalError)
+				return
+			}
+		}
+		report.Restored++
+		if created {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// queryChoice returns query parameter name, which must be one of choices if
+// set, and the first choice otherwise
+func queryChoice(r *http.Request, name string, choices ...string) (string, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return choices[0], true
+	}
+	return value, slices.Contains(choices, value)
+}
+
+// restoreStep is upsert restore makes for a row, unless row is skipped
+type restoreStep struct {
+	product domain.Product
+	skip    bool
+}
+
+// planRestore resolves rows of dump against existing products. Rows left
+// incomplete by merge must match a product to take missing fields from,
+// rows matched by name keep id of the product they match, and unmatched
+// ones must not take id of another product
+func planRestore(dump parsedDump, existing []domain.Product, matchBy string, duplicates string) ([]restoreStep, []dumpRowError) {
+	byId := make(map[int64]domain.Product, len(existing))
+	byName := make(map[string]domain.Product, len(existing))
+	for _, p := range existing {
+		byId[p.Id] = p
+		// names are not unique, the oldest product is matched
+		if _, ok := byName[p.Name]; !ok {
+			byName[p.Name] = p
+		}
+	}
+	rowErrors := dump.rowErrors
+	steps := make([]restoreStep, 0, len(dump.products))
+	for i, row := range dump.products {
+		line := dump.lines[i]
+		match, matched := byId[row.Id]
+		if matchBy == matchByName {
+			match, matched = byName[row.Name]
+			if taken, ok := byId[row.Id]; !matched && ok {
+				rowErrors = append(rowErrors, dumpRowError{Line: line, Field: "id", Reason: fmt.Sprintf("belongs to product %q", taken.Name)})
+				continue
+			}
+		}
+		if !matched {
+			incomplete := validateDumpRow(line, row, false)
+			if len(incomplete) > 0 {
+				rowErrors = append(rowErrors, incomplete...)
+				continue
+			}
+			steps = append(steps, restoreStep{product: row})
+			continue
+		}
+		switch duplicates {
+		case duplicatesSkip:
+			steps = append(steps, restoreStep{product: match, skip: true})
+		case duplicatesMerge:
+			if row.Name != "" {
+				match.Name = row.Name
+			}
+			if row.AdditionalInfo != "" {
+				match.AdditionalInfo = row.AdditionalInfo
+			}
+			if row.CategoryId != 0 {
+				match.CategoryId = row.CategoryId
+			}
+			if row.Sku != "" {
+				match.Sku = row.Sku
+			}
+			steps = append(steps, restoreStep{product: match})
+		default:
+			steps = append(steps, restoreStep{product: domain.Product{Id: match.Id, Name: row.Name, AdditionalInfo: row.AdditionalInfo, CategoryId: row.CategoryId, Sku: row.Sku}})
+		}
+	}
+	slices.SortStableFunc(rowErrors, func(a, b dumpRowError) int { return a.Line - b.Line })
+	return steps, rowErrors
+}
+
+// parsedDump holds products of valid lines of dump and problems with the
+// rest, blank lines are not rows
+type parsedDump struct {
+	rows     int
+	products []domain.Product
+	// line of every product
+	lines     []int
+	rowErrors []dumpRowError
+}
+
+// readDump parses NDJSON products, gzip compressed unless sent as
+// application/x-ndjson. Invalid lines do not stop reading, error is only
+// returned if dump can not be read at all. Partial rows may leave name and
+// additional info empty
+func readDump(r *http.Request, partial bool) (parsedDump, error) {
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != ndjsonContentType {
+		archive, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return parsedDump{}, fmt.Errorf("dump is not gzip compressed: %w", err)
+		}
+		defer archive.Close()
+		body = archive
+	}
+
+	dump := parsedDump{products: []domain.Product{}, rowErrors: []dumpRowError{}}
+	seen := make(map[int64]int)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxDumpLineBytes)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		dump.rows++
+		var p domain.Product
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&p); err != nil {
+			dump.rowErrors = append(dump.rowErrors, decodeRowError(line, err))
+			continue
+		}
+		rowErrors := validateDumpRow(line, p, partial)
+		if first, ok := seen[p.Id]; ok {
+			rowErrors = append(rowErrors, dumpRowError{Line: line, Field: "id", Reason: fmt.Sprintf("duplicates line %d", first)})
+		}
+		if len(rowErrors) > 0 {
+			dump.rowErrors = append(dump.rowErrors, rowErrors...)
+			continue
+		}
+		seen[p.Id] = line
+		dump.products = append(dump.products, p)
+		dump.lines = append(dump.lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return parsedDump{}, err
+	}
+	return dump, nil
+}
+
+func validateDumpRow(line int, p domain.Product, partial bool) []dumpRowError {
+	var rowErrors []dumpRowError
+	if p.Id <= 0 {
+		rowErrors = append(rowErrors, dumpRowError{Line: line, Field: "id", Reason: "must be positive"})
+	}
+	if p.Sku != "" && domain.ValidateSku(p.Sku) != nil {
+		rowErrors = append(rowErrors, dumpRowError{Line: line, Field: "sku", Reason: "is invalid"})
+	}
+	if partial {
+		return rowErrors
+	}
+	if p.Name == "" {
+		rowErrors = append(rowErrors, dumpRowError{Line: line, Field: "name", Reason: "is required"})
+	}
+	if p.AdditionalInfo == "" {
+		rowErrors = append(rowErrors, dumpRowError{Line: line, Field: "additionalInfo", Reason: "is required"})
+	}
+	return rowErrors
+}
+
+// decodeRowError points at offending field where decoder tells it
+func decodeRowError(line int, err error) dumpRowError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return dumpRowError{Line: line, Field: typeErr.Field, Reason: "must be " + typeErr.Type.String()}
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return dumpRowError{Line: line, Field: strings.Trim(field, `"`), Reason: "is unknown"}
+	}
+	return dumpRowError{Line: line, Reason: err.Error()}
+}
@@ -0,0 +1,260 @@
+package routing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func datasetRouter(t *testing.T, repo *fakes.Repository) http.Handler {
+	t.Helper()
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	t.Cleanup(func() { logger.Close() })
+	handler := NewAdminHandler(logger, WithDataset(service.NewResourceService(repo, fakes.NewCache(), service.WithParallelExport(2, 2))))
+	admin := NewAdminRouter(handler).SetupRoutes()
+	// stands in for client certificate
+	return logger.LoggerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), "ops")))
+	}))
+}
+
+func TestDumpAndRestore(t *testing.T) {
+	source := fakes.NewRepository(
+		domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"},
+		domain.Product{Id: 2, Name: "Second", AdditionalInfo: "Second info"},
+		domain.Product{Id: 5, Name: "Fifth", AdditionalInfo: "Fifth info"},
+	)
+	rec := httptest.NewRecorder()
+	datasetRouter(t, source).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/dump", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, dumpContentType, rec.Header().Get("Content-Type"))
+	dump := rec.Body.Bytes()
+
+	archive, err := gzip.NewReader(bytes.NewReader(dump))
+	require.NoError(t, err)
+	lines, err := io.ReadAll(archive)
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(lines), "\n"), "one product per line")
+
+	t.Run("truncate", func(t *testing.T) {
+		target := fakes.NewRepository(
+			domain.Product{Id: 2, Name: "Stale", AdditionalInfo: "Stale info"},
+			domain.Product{Id: 9, Name: "Extra", AdditionalInfo: "Extra info"},
+		)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/restore?mode=truncate", bytes.NewReader(dump))
+		req.Header.Set("Content-Type", dumpContentType)
+		datasetRouter(t, target).ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var report restoreReport
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		assert.Equal(t, restoreReport{Mode: restoreModeTruncate, Restored: 3, Created: 3, Deleted: 2}, report)
+		assert.Equal(t, source.Products(), target.Products())
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		target := fakes.NewRepository(
+			domain.Product{Id: 2, Name: "Stale", AdditionalInfo: "Stale info"},
+			domain.Product{Id: 9, Name: "Extra", AdditionalInfo: "Extra info"},
+		)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/restore?mode=merge", strings.NewReader(string(lines)))
+		req.Header.Set("Content-Type", ndjsonContentType)
+		datasetRouter(t, target).ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var report restoreReport
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		assert.Equal(t, restoreReport{Mode: restoreModeMerge, Restored: 3, Created: 2, Updated: 1}, report)
+		merged := source.Products()
+		// product 2 was overwritten
+		merged[1].Version = 2
+		assert.Equal(t, append(merged, domain.Product{Id: 9, Name: "Extra", AdditionalInfo: "Extra info", Version: 1}), target.Products())
+	})
+}
+
+func TestRestoreRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{name: "restore_invalid_mode", path: "/admin/restore?mode=replace", body: `{"id":1,"name":"First","additionalInfo":"First info"}`},
+		{name: "restore_not_compressed", path: "/admin/restore?mode=merge", body: `{"id":1,"name":"First","additionalInfo":"First info"}`},
+		{name: "restore_invalid_duplicates", path: "/admin/restore?mode=merge&duplicates=replace", body: mustGzip("{\"id\":1,\"name\":\"First\",\"additionalInfo\":\"First info\"}\n")},
+		{name: "restore_invalid_dry_run", path: "/admin/restore?mode=merge&dryRun=maybe", body: mustGzip("{\"id\":1,\"name\":\"First\",\"additionalInfo\":\"First info\"}\n")},
+		{name: "restore_duplicate_product", path: "/admin/restore?mode=truncate", body: mustGzip("{\"id\":1,\"name\":\"First\",\"additionalInfo\":\"First info\"}\n{\"id\":1,\"name\":\"Again\",\"additionalInfo\":\"Again\"}\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := fakes.NewRepository(domain.Product{Id: 7, Name: "Kept", AdditionalInfo: "Kept info"})
+			rec := httptest.NewRecorder()
+			datasetRouter(t, repo).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			testhelpers.AssertGolden(t, tt.name, rec)
+			assert.Len(t, repo.Products(), 1, "nothing is deleted")
+		})
+	}
+}
+
+func TestRestoreDuplicates(t *testing.T) {
+	existing := []domain.Product{
+		{Id: 1, Name: "First", AdditionalInfo: "First info", Version: 1},
+		{Id: 2, Name: "Second", AdditionalInfo: "Second info", Version: 1},
+	}
+	complete := []string{
+		`{"id":1,"name":"First","additionalInfo":"New first info"}`,
+		`{"id":7,"name":"Second","additionalInfo":"Other second info"}`,
+		`{"id":8,"name":"Eighth","additionalInfo":"Eighth info"}`,
+	}
+	tests := []struct {
+		name     string
+		query    string
+		rows     []string
+		report   restoreReport
+		products []domain.Product
+	}{
+		{
+			name:   "overwrite by id",
+			query:  "duplicates=overwrite",
+			rows:   complete,
+			report: restoreReport{Mode: restoreModeMerge, Restored: 3, Created: 2, Updated: 1},
+			products: []domain.Product{
+				{Id: 1, Name: "First", AdditionalInfo: "New first info", Version: 2},
+				{Id: 2, Name: "Second", AdditionalInfo: "Second info", Version: 1},
+				{Id: 7, Name: "Second", AdditionalInfo: "Other second info", Version: 1},
+				{Id: 8, Name: "Eighth", AdditionalInfo: "Eighth info", Version: 1},
+			},
+		},
+		{
+			name:   "skip by id",
+			query:  "duplicates=skip&matchBy=id",
+			rows:   complete,
+			report: restoreReport{Mode: restoreModeMerge, Restored: 2, Created: 2, Skipped: 1},
+			products: []domain.Product{
+				{Id: 1, Name: "First", AdditionalInfo: "First info", Version: 1},
+				{Id: 2, Name: "Second", AdditionalInfo: "Second info", Version: 1},
+				{Id: 7, Name: "Second", AdditionalInfo: "Other second info", Version: 1},
+				{Id: 8, Name: "Eighth", AdditionalInfo: "Eighth info", Version: 1},
+			},
+		},
+		{
+			name:   "overwrite by name keeps id of match",
+			query:  "matchBy=name",
+			rows:   complete,
+			report: restoreReport{Mode: restoreModeMerge, Restored: 3, Created: 1, Updated: 2},
+			products: []domain.Product{
+				{Id: 1, Name: "First", AdditionalInfo: "New first info", Version: 2},
+				{Id: 2, Name: "Second", AdditionalInfo: "Other second info", Version: 2},
+				{Id: 8, Name: "Eighth", AdditionalInfo: "Eighth info", Version: 1},
+			},
+		},
+		{
+			name:   "skip by name",
+			query:  "duplicates=skip&matchBy=name",
+			rows:   complete,
+			report: restoreReport{Mode: restoreModeMerge, Restored: 1, Created: 1, Skipped: 2},
+			products: []domain.Product{
+				{Id: 1, Name: "First", AdditionalInfo: "First info", Version: 1},
+				{Id: 2, Name: "Second", AdditionalInfo: "Second info", Version: 1},
+				{Id: 8, Name: "Eighth", AdditionalInfo: "Eighth info", Version: 1},
+			},
+		},
+		{
+			name:  "merge keeps fields left empty",
+			query: "duplicates=merge",
+			rows: []string{
+				`{"id":1,"name":"","additionalInfo":"Merged info"}`,
+				`{"id":2,"name":"Renamed"}`,
+				`{"id":8,"name":"Eighth","additionalInfo":"Eighth info"}`,
+			},
+			report: restoreReport{Mode: restoreModeMerge, Restored: 3, Created: 1, Updated: 2},
+			products: []domain.Product{
+				{Id: 1, Name: "First", AdditionalInfo: "Merged info", Version: 2},
+				{Id: 2, Name: "Renamed", AdditionalInfo: "Second info", Version: 2},
+				{Id: 8, Name: "Eighth", AdditionalInfo: "Eighth info", Version: 1},
+			},
+		},
+		{
+			name:  "merge of unmatched row needs all fields",
+			query: "duplicates=merge",
+			rows:  []string{`{"id":1,"additionalInfo":"Merged info"}`, `{"id":9,"name":"Ninth"}`},
+		},
+		{
+			name:  "unmatched row must not take id of another product",
+			query: "matchBy=name",
+			rows:  []string{`{"id":2,"name":"Other","additionalInfo":"Other info"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := fakes.NewRepository(existing...)
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/admin/restore?mode=merge&"+tt.query, strings.NewReader(strings.Join(tt.rows, "\n")))
+			req.Header.Set("Content-Type", ndjsonContentType)
+			datasetRouter(t, repo).ServeHTTP(rec, req)
+
+			if tt.products == nil {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Equal(t, existing, repo.Products(), "nothing is written")
+				return
+			}
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var report restoreReport
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+			assert.Equal(t, tt.report, report)
+			assert.Equal(t, tt.products, repo.Products())
+		})
+	}
+}
+
+func TestRestoreDryRun(t *testing.T) {
+	dump := strings.Join([]string{
+		`{"id":1,"name":"First","additionalInfo":"First info"}`,
+		`{"id":0,"name":"","additionalInfo":"No name"}`,
+		``,
+		`{"id":1,"name":"Again","additionalInfo":"Again"}`,
+		`{"id":"4","name":"Fourth","additionalInfo":"Fourth info"}`,
+		`{"id":5,"name":"Fifth","additionalInfo":"Fifth info","price":10}`,
+		`not json`,
+	}, "\n")
+	repo := fakes.NewRepository(domain.Product{Id: 7, Name: "Kept", AdditionalInfo: "Kept info"})
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/admin/restore?mode=truncate&dryRun=true", strings.NewReader(dump))
+	req.Header.Set("Content-Type", ndjsonContentType)
+	datasetRouter(t, repo).ServeHTTP(rec, req)
+
+	testhelpers.AssertGolden(t, "restore_dry_run", rec)
+	assert.Equal(t, []domain.Product{{Id: 7, Name: "Kept", AdditionalInfo: "Kept info", Version: 1}}, repo.Products(), "nothing is written")
+}
+
+func TestDatasetRequiresAuthentication(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	handler := NewAdminHandler(logger, WithDataset(service.NewResourceService(fakes.NewRepository(), fakes.NewCache())))
+	router := logger.LoggerMiddleware(NewAdminRouter(handler).SetupRoutes())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/dump", nil))
+	testhelpers.AssertGolden(t, "dump_unauthenticated", rec)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/restore?mode=truncate", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
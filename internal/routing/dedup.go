@@ -0,0 +1,125 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+const (
+	duplicateRequestHeader = "X-Duplicate-Request"
+
+	// larger requests are not deduplicated, they are hardly double-submits
+	maxDedupBodyBytes = 1 << 20
+)
+
+type dedupResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body"`
+}
+
+// WriteDeduplicator protects against double-submits of POST and PUT requests:
+// identical request (same principal, method, URI and body) repeated within
+// window is rejected with 409 or, if replay is enabled, answered with
+// response of the original one. Only successful responses are kept, failed
+// requests are forgotten so that they can be retried right away. GraphQL
+// and batch requests are not deduplicated: repeating a query is no
+// double-submit, and their writes can not be told from reads by method
+type WriteDeduplicator struct {
+	store  ports.WriteDedupStore
+	window time.Duration
+	replay bool
+}
+
+func NewWriteDeduplicator(store ports.WriteDedupStore, window time.Duration, replay bool) *WriteDeduplicator {
+	return &WriteDeduplicator{store: store, window: window, replay: replay}
+}
+
+func dedupRequestKey(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%s\n", PrincipalFromContext(r.Context()), r.Method, r.URL.RequestURI())
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (d *WriteDeduplicator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut || r.URL.Path == "/graphql" || r.URL.Path == "/batch" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxDedupBodyBytes+1))
+		if err != nil || len(body) > maxDedupBodyBytes {
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := dedupRequestKey(r, body)
+		claimed, saved, err := d.store.Claim(r.Context(), key, d.window)
+		if err != nil {
+			// cache outage must not block writes
+			errContainer.Add(fmt.Errorf("dedup error: %w", err))
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !claimed {
+			d.duplicate(w, r, saved, errContainer)
+			return
+		}
+
+		rec := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+
+		// request context may be already done, outcome must be recorded anyway
+		ctx := context.WithoutCancel(r.Context())
+		if rec.status < http.StatusOK || rec.status >= http.StatusMultipleChoices {
+			if err := d.store.Release(ctx, key); err != nil {
+				errContainer.Add(fmt.Errorf("dedup error: %w", err))
+			}
+			return
+		}
+		if !d.replay {
+			return
+		}
+		response, _ := json.Marshal(dedupResponse{
+			Status:      rec.status,
+			ContentType: rec.header.Get("Content-Type"),
+			Body:        rec.body.Bytes(),
+		})
+		if err := d.store.Save(ctx, key, response); err != nil {
+			errContainer.Add(fmt.Errorf("dedup error: %w", err))
+		}
+	})
+}
+
+// duplicate replays saved response if there is one, original request may be
+// still in progress or replay disabled, duplicate is rejected then
+func (d *WriteDeduplicator) duplicate(w http.ResponseWriter, r *http.Request, saved []byte, errContainer *domain.ErrorContainer) {
+	w.Header().Set(duplicateRequestHeader, "true")
+	var response dedupResponse
+	if d.replay && saved != nil && json.Unmarshal(saved, &response) == nil {
+		if response.ContentType != "" {
+			w.Header().Set("Content-Type", response.ContentType)
+		}
+		w.WriteHeader(response.Status)
+		w.Write(response.Body)
+		return
+	}
+	errContainer.Add(fmt.Errorf("%w: duplicate %s %s", domain.ErrInvalidInput, r.Method, r.URL.RequestURI()))
+	writeError(w, http.StatusConflict, CodeDuplicateRequest, "Duplicate request")
+}
@@ -0,0 +1,124 @@
+package routing
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestWriteDeduplicator(t *testing.T) {
+	const body = `{"name":"New","additionalInfo":"Created"}`
+	tests := []struct {
+		name           string
+		replay         bool
+		status         int
+		second         string
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{
+			name:           "duplicate rejected",
+			status:         http.StatusCreated,
+			second:         body,
+			expectedStatus: http.StatusConflict,
+			expectedCalls:  1,
+		},
+		{
+			name:           "duplicate replayed",
+			replay:         true,
+			status:         http.StatusCreated,
+			second:         body,
+			expectedStatus: http.StatusCreated,
+			expectedCalls:  1,
+		},
+		{
+			name:           "different body is not a duplicate",
+			status:         http.StatusCreated,
+			second:         `{"name":"Other","additionalInfo":"Created"}`,
+			expectedStatus: http.StatusCreated,
+			expectedCalls:  2,
+		},
+		{
+			name:           "failed request can be retried",
+			replay:         true,
+			status:         http.StatusServiceUnavailable,
+			second:         body,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCalls:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			dedup := NewWriteDeduplicator(fakes.NewWriteDedupStore(), time.Minute, tt.replay)
+			router := newDedupTestRouter(t, dedup, func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprintf(w, `{"id":%d}`, calls)
+			})
+
+			first := httptest.NewRecorder()
+			router.ServeHTTP(first, httptest.NewRequest(http.MethodPost, "/product", bytes.NewBufferString(body)))
+			require.Equal(t, tt.status, first.Code)
+
+			second := httptest.NewRecorder()
+			router.ServeHTTP(second, httptest.NewRequest(http.MethodPost, "/product", bytes.NewBufferString(tt.second)))
+			assert.Equal(t, tt.expectedStatus, second.Code)
+			assert.Equal(t, tt.expectedCalls, calls)
+			if tt.replay && tt.expectedCalls == 1 {
+				assert.Equal(t, first.Body.String(), second.Body.String())
+				assert.Equal(t, "true", second.Header().Get(duplicateRequestHeader))
+			}
+		})
+	}
+}
+
+func TestWriteDeduplicatorSkipsGraphQLAndBatch(t *testing.T) {
+	var calls int
+	router := newDedupTestRouter(t, NewWriteDeduplicator(fakes.NewWriteDedupStore(), time.Minute, false), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+
+	for _, path := range []string{"/graphql", "/batch"} {
+		for range 2 {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(`{"query":"{ products { total } }"}`)))
+			assert.Equal(t, http.StatusOK, rec.Code, path)
+		}
+	}
+	assert.Equal(t, 4, calls, "repeated queries must be served")
+}
+
+func TestWriteDeduplicatorStoreFailure(t *testing.T) {
+	store := fakes.NewWriteDedupStore()
+	store.FailWith("Claim", domain.ErrInternalCache)
+	var calls int
+	router := newDedupTestRouter(t, NewWriteDeduplicator(store, time.Minute, false), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/product/1", bytes.NewBufferString(`{}`)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	assert.Equal(t, 2, calls, "writes must not be blocked by cache failure")
+}
+
+func newDedupTestRouter(t *testing.T, dedup *WriteDeduplicator, handler http.HandlerFunc) http.Handler {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	t.Cleanup(logger.Close)
+	return logger.LoggerMiddleware(dedup.Middleware(handler))
+}
@@ -0,0 +1,120 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// Deprecation marks routes as deprecated since Since. Method and path match
+// like in fault rules, so "/v1/" deprecates a whole version. Responses carry
+// Deprecation, Sunset and Link headers, and a warning. Once Sunset passes,
+// routes answer 410 instead
+type Deprecation struct {
+	Method string     `json:"method,omitempty"`
+	Path   string     `json:"path"`
+	Since  time.Time  `json:"since"`
+	Sunset *time.Time `json:"sunset,omitempty"`
+	// document describing migration, e.g. to successor route
+	Link string `json:"link,omitempty"`
+}
+
+func (d Deprecation) validate() error {
+	switch {
+	case d.Path == "" || !strings.HasPrefix(d.Path, "/"):
+		return fmt.Errorf("%w: deprecated route path must start with /", domain.ErrInvalidInput)
+	case d.Since.IsZero():
+		return fmt.Errorf("%w: deprecation of %s must have since", domain.ErrInvalidInput, d.Path)
+	case d.Sunset != nil && d.Sunset.Before(d.Since):
+		return fmt.Errorf("%w: sunset of %s must not precede deprecation", domain.ErrInvalidInput, d.Path)
+	}
+	return nil
+}
+
+// warning tells clients in plain words what headers say
+func (d Deprecation) warning() string {
+	route := d.Path
+	if d.Method != "" {
+		route = strings.ToUpper(d.Method) + " " + d.Path
+	}
+	message := fmt.Sprintf("%s is deprecated since %s", route, d.Since.UTC().Format(time.DateOnly))
+	if d.Sunset != nil {
+		message += fmt.Sprintf(" and will be removed on %s", d.Sunset.UTC().Format(time.DateOnly))
+	}
+	if d.Link != "" {
+		message += ", see " + d.Link
+	}
+	return message
+}
+
+// ParseDeprecations parses deprecations from JSON array, empty string means
+// none
+func ParseDeprecations(s string) ([]Deprecation, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var deprecations []Deprecation
+	if err := json.Unmarshal([]byte(s), &deprecations); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse deprecations: %s", domain.ErrInvalidInput, err.Error())
+	}
+	for _, d := range deprecations {
+		if err := d.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return deprecations, nil
+}
+
+type warningsKey struct{}
+
+// warningsFromContext returns warnings meant for client, such as of route
+// being deprecated
+func warningsFromContext(ctx context.Context) []string {
+	warnings, _ := ctx.Value(warningsKey{}).([]string)
+	return warnings
+}
+
+// deprecationMiddleware applies the first deprecation matching request.
+// Warning goes into Warning header and into envelope, if response has one
+func deprecationMiddleware(deprecations []Deprecation, next http.Handler) http.Handler {
+	if len(deprecations) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var deprecation *Deprecation
+		for i := range deprecations {
+			if matchesRoute(deprecations[i].Method, deprecations[i].Path, r) {
+				deprecation = &deprecations[i]
+				break
+			}
+		}
+		if deprecation == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(deprecation.Since.Unix(), 10))
+		if deprecation.Link != "" {
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"`, deprecation.Link))
+		}
+		if deprecation.Sunset != nil {
+			w.Header().Set("Sunset", deprecation.Sunset.UTC().Format(http.TimeFormat))
+			if !time.Now().Before(*deprecation.Sunset) {
+				errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+				errContainer.Add(fmt.Errorf("handler error: %s %s is past its sunset", r.Method, r.URL.Path))
+				writeError(w, http.StatusGone, CodeGone, "Route has been removed")
+				return
+			}
+		}
+		warning := deprecation.warning()
+		w.Header().Add("Warning", "299 - "+strconv.Quote(warning))
+		ctx := context.WithValue(r.Context(), warningsKey{}, append(warningsFromContext(r.Context()), warning))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
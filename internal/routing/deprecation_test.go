@@ -0,0 +1,84 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestParseDeprecations(t *testing.T) {
+	deprecations, err := ParseDeprecations(`[{"method": "get", "path": "/products/", "since": "2026-09-01T00:00:00Z", "sunset": "2027-03-01T00:00:00Z", "link": "https://docs.example.com/v2"}]`)
+	require.NoError(t, err)
+	require.Len(t, deprecations, 1)
+	assert.Equal(t, "/products/", deprecations[0].Path)
+	assert.Equal(t, time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC), *deprecations[0].Sunset)
+
+	deprecations, err = ParseDeprecations(" ")
+	require.NoError(t, err)
+	assert.Empty(t, deprecations)
+
+	for _, s := range []string{
+		`{"path": "/products"}`,
+		`[{"path": "products", "since": "2026-09-01T00:00:00Z"}]`,
+		`[{"path": "/products"}]`,
+		`[{"path": "/products", "since": "2026-09-01T00:00:00Z", "sunset": "2026-08-01T00:00:00Z"}]`,
+	} {
+		_, err := ParseDeprecations(s)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput, s)
+	}
+}
+
+func TestDeprecatedRoutes(t *testing.T) {
+	since := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	sunsetPassed := time.Now().Add(-time.Hour)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"})
+	handler := NewProductHandler(service.NewResourceService(repo, fakes.NewCache()), WithDeprecations([]Deprecation{
+		{Method: http.MethodGet, Path: "/product/", Since: since, Sunset: &sunset, Link: "https://docs.example.com/v2"},
+		{Method: http.MethodDelete, Path: "/product/", Since: since, Sunset: &sunsetPassed},
+	}))
+	router := logger.LoggerMiddleware(NewRouter(handler).SetupRoutes())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/product/1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "@1788220800", rec.Header().Get("Deprecation"))
+	assert.Equal(t, sunset.UTC().Format(http.TimeFormat), rec.Header().Get("Sunset"))
+	assert.Equal(t, `<https://docs.example.com/v2>; rel="deprecation"`, rec.Header().Get("Link"))
+	assert.Contains(t, rec.Header().Get("Warning"), `299 - "GET /product/ is deprecated since 2026-09-01`)
+
+	req := httptest.NewRequest(http.MethodGet, "/product/1", nil)
+	req.Header.Set(envelopeHeader, "true")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var wrapped envelope
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &wrapped))
+	require.Len(t, wrapped.Meta.Warnings, 1)
+	assert.Contains(t, wrapped.Meta.Warnings[0], "is deprecated since 2026-09-01")
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products", nil))
+	assert.Empty(t, rec.Header().Get("Deprecation"), "other routes are not deprecated")
+	assert.Empty(t, rec.Header().Get("Warning"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/product/1", nil))
+	assert.Equal(t, http.StatusGone, rec.Code)
+	assert.Contains(t, rec.Body.String(), string(CodeGone))
+	_, err = repo.GetProduct(context.Background(), 1)
+	assert.NoError(t, err, "route past its sunset does nothing")
+}
@@ -0,0 +1,124 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Drainer tracks whether instance is being taken out of rotation. Once
+// draining, readiness fails so that load balancers stop sending traffic, and
+// whoever listens on Started stops accepting connections. Draining can not be
+// undone, instance is expected to be terminated afterwards
+type Drainer struct {
+	mu      sync.Mutex
+	since   time.Time
+	started chan struct{}
+}
+
+func NewDrainer() *Drainer {
+	return &Drainer{started: make(chan struct{})}
+}
+
+// Drain starts draining, it returns false if draining has started already
+func (d *Drainer) Drain() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.since.IsZero() {
+		return false
+	}
+	d.since = time.Now().UTC()
+	close(d.started)
+	return true
+}
+
+// Since returns when draining started, zero time if it has not
+func (d *Drainer) Since() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.since
+}
+
+// Started is closed once draining starts
+func (d *Drainer) Started() <-chan struct{} {
+	return d.started
+}
+
+func WithDrainer(d *Drainer) AdminOption {
+	return func(h *AdminHandler) {
+		h.drainer = d
+	}
+}
+
+type drainStatus struct {
+	Draining bool       `json:"draining"`
+	Since    *time.Time `json:"since,omitempty"`
+}
+
+// readyStatus is drain status, with startup checks yet to pass and critical
+// dependencies found unhealthy if those are configured
+type readyStatus struct {
+	drainStatus
+	Starting  []string `json:"starting,omitempty"`
+	Unhealthy []string `json:"unhealthy,omitempty"`
+}
+
+func (h *AdminHandler) drainStatus() drainStatus {
+	if h.drainer == nil {
+		return drainStatus{}
+	}
+	since := h.drainer.Since()
+	if since.IsZero() {
+		return drainStatus{}
+	}
+	return drainStatus{Draining: true, Since: &since}
+}
+
+// Ready is readiness probe, it fails until startup checks pass, once
+// instance is draining or while a critical dependency is unhealthy. State of dependencies comes from
+// background probes, so readiness checks do not load them
+func (h *AdminHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	status := readyStatus{drainStatus: h.drainStatus()}
+	if h.startup != nil {
+		status.Starting = h.startup.Pending()
+	}
+	if h.health != nil && !h.health.Ready() {
+		status.Unhealthy = []string{}
+		for _, dependency := range h.health.Health() {
+			if dependency.Critical && !dependency.Healthy {
+				status.Unhealthy = append(status.Unhealthy, dependency.Name)
+			}
+		}
+	}
+	if status.Draining || status.Starting != nil || status.Unhealthy != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+func (h *AdminHandler) GetDrainStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.drainer == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Draining is not configured")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.drainStatus())
+}
+
+// Drain takes instance out of rotation, see Drainer. Repeated calls are no-op
+func (h *AdminHandler) Drain(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.drainer == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Draining is not configured")
+		return
+	}
+	h.drainer.Drain()
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(h.drainStatus())
+}
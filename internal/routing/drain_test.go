@@ -0,0 +1,66 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrain(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	drainer := NewDrainer()
+	router := logger.LoggerMiddleware(NewAdminRouter(NewAdminHandler(logger, WithDrainer(drainer))).SetupRoutes())
+	send := func(method, path string) (int, drainStatus) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		var status drainStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		return rec.Code, status
+	}
+
+	code, status := send(http.MethodGet, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, status.Draining)
+
+	code, status = send(http.MethodPost, "/admin/drain")
+	assert.Equal(t, http.StatusAccepted, code)
+	require.True(t, status.Draining)
+	since := *status.Since
+	select {
+	case <-drainer.Started():
+	default:
+		t.Fatal("draining must be started")
+	}
+
+	code, status = send(http.MethodGet, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.True(t, status.Draining)
+
+	// draining again changes nothing
+	code, status = send(http.MethodPost, "/admin/drain")
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, since, *status.Since)
+	assert.False(t, drainer.Drain())
+
+	code, status = send(http.MethodGet, "/admin/drain")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, status.Draining)
+}
+
+func TestReadyWithoutDrainer(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewAdminRouter(NewAdminHandler(logger)).SetupRoutes())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,101 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+const envelopeHeader = "X-Response-Envelope"
+
+type envelope struct {
+	Data   json.RawMessage `json:"data"`
+	Meta   envelopeMeta    `json:"meta"`
+	Errors []envelopeError `json:"errors,omitempty"`
+}
+
+type envelopeMeta struct {
+	Status    int       `json:"status"`
+	RequestId string    `json:"requestId,omitempty"`
+	Count     *int      `json:"count,omitempty"`
+	Page      *pageMeta `json:"page,omitempty"`
+	Warnings  []string  `json:"warnings,omitempty"`
+}
+
+// pageMeta describes page of product listing. Offset is given for offset
+// pages, next cursor for keyset ones
+type pageMeta struct {
+	Total      int64  `json:"total"`
+	Limit      int64  `json:"limit"`
+	Offset     *int64 `json:"offset,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
+}
+
+type pageKey struct{}
+
+// pageHolder is where handlers leave page metadata for envelope, it is only
+// in context of enveloped requests
+type pageHolder struct {
+	page *pageMeta
+}
+
+type envelopeError struct {
+	Code    ErrorCode `json:"code,omitempty"`
+	Message string    `json:"message"`
+}
+
+// wantsEnvelope lets X-Response-Envelope: true/false override configured default
+func wantsEnvelope(r *http.Request, byDefault bool) bool {
+	if value, err := strconv.ParseBool(r.Header.Get(envelopeHeader)); err == nil {
+		return value
+	}
+	return byDefault
+}
+
+// envelopeMiddleware wraps plain JSON responses into {"data", "meta",
+// "errors"}, so handlers stay unaware of it. Responses of other media types,
+// JSON:API documents included, are passed as they are
+func envelopeMiddleware(byDefault bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", envelopeHeader)
+		if !wantsEnvelope(r, byDefault) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+		holder := &pageHolder{}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), pageKey{}, holder)))
+
+		mediaType, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type"))
+		if mediaType != "application/json" || !json.Valid(rec.body.Bytes()) {
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+			return
+		}
+
+		wrapped := envelope{Meta: envelopeMeta{
+			Status:    rec.status,
+			RequestId: RequestIdFromContext(r.Context()),
+			Warnings:  warningsFromContext(r.Context()),
+		}}
+		var errBody errorBody
+		if rec.status >= http.StatusBadRequest && json.Unmarshal(rec.body.Bytes(), &errBody) == nil && errBody.Error != "" {
+			wrapped.Data = json.RawMessage("null")
+			wrapped.Errors = []envelopeError{{Code: errBody.Code, Message: errBody.Error}}
+		} else {
+			wrapped.Data = rec.body.Bytes()
+			var items []json.RawMessage
+			if json.Unmarshal(wrapped.Data, &items) == nil {
+				count := len(items)
+				wrapped.Meta.Count = &count
+				wrapped.Meta.Page = holder.page
+			}
+		}
+
+		writeJSON(w, rec.status, wrapped)
+	})
+}
@@ -0,0 +1,92 @@
+package routing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// ErrorCode is a stable machine-readable counterpart of error message.
+// Messages may be reworded, codes must not change once released
+type ErrorCode string
+
+const (
+	CodeProductNotFound          ErrorCode = "PRODUCT_NOT_FOUND"
+	CodeInvalidId                ErrorCode = "INVALID_ID"
+	CodeInvalidParameter         ErrorCode = "INVALID_PARAMETER"
+	CodeInvalidBody              ErrorCode = "INVALID_BODY"
+	CodeBodyTooLarge             ErrorCode = "BODY_TOO_LARGE"
+	CodeInvalidPatch             ErrorCode = "INVALID_PATCH"
+	CodeInvalidQuery             ErrorCode = "INVALID_QUERY"
+	CodeInvalidBatch             ErrorCode = "INVALID_BATCH"
+	CodeInvalidConfirmationToken ErrorCode = "INVALID_CONFIRMATION_TOKEN"
+	CodeInvalidSignature         ErrorCode = "INVALID_SIGNATURE"
+	CodeUnauthenticated          ErrorCode = "UNAUTHENTICATED"
+	CodeForbidden                ErrorCode = "FORBIDDEN"
+	CodeUnsupportedMediaType     ErrorCode = "UNSUPPORTED_MEDIA_TYPE"
+	CodeNotAcceptable            ErrorCode = "NOT_ACCEPTABLE"
+	CodeMethodNotAllowed         ErrorCode = "METHOD_NOT_ALLOWED"
+	CodeConflict                 ErrorCode = "CONFLICT"
+	CodePreconditionRequired     ErrorCode = "PRECONDITION_REQUIRED"
+	CodeUnknownCategory          ErrorCode = "UNKNOWN_CATEGORY"
+	CodeDuplicateSku             ErrorCode = "DUPLICATE_SKU"
+	CodeInsufficientStock        ErrorCode = "INSUFFICIENT_STOCK"
+	CodeDuplicateRequest         ErrorCode = "DUPLICATE_REQUEST"
+	CodeRateLimited              ErrorCode = "RATE_LIMITED"
+	CodeNotFound                 ErrorCode = "NOT_FOUND"
+	CodeGone                     ErrorCode = "GONE"
+	CodeCursorExpired            ErrorCode = "CURSOR_EXPIRED"
+	CodeNotConfigured            ErrorCode = "NOT_CONFIGURED"
+	CodeSearchUnavailable        ErrorCode = "SEARCH_UNAVAILABLE"
+	CodeImageStoreUnavailable    ErrorCode = "IMAGE_STORE_UNAVAILABLE"
+	CodeRequestTimeout           ErrorCode = "REQUEST_TIMEOUT"
+	CodeDbUnavailable            ErrorCode = "DB_UNAVAILABLE"
+	CodeCacheUnavailable         ErrorCode = "CACHE_UNAVAILABLE"
+	CodeInjectedFault            ErrorCode = "INJECTED_FAULT"
+	CodeInternal                 ErrorCode = "INTERNAL_ERROR"
+)
+
+type errorBody struct {
+	Error     string    `json:"error"`
+	Code      ErrorCode `json:"code"`
+	RequestId string    `json:"requestId,omitempty"`
+}
+
+// writeError responds with errorBody. Request id is the one
+// RequestIdMiddleware has already set in response header, so that clients
+// reporting errors can tell which request failed
+func writeError(w http.ResponseWriter, status int, code ErrorCode, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorBody{Error: message, Code: code, RequestId: w.Header().Get(requestIdHeader)})
+}
+
+// retryAfterSeconds is suggested to clients when a dependency is unreachable
+const retryAfterSeconds = 5
+
+// writeServerError tells clients which dependency failed, message stays
+// generic so that no details leak. Unreachable dependencies are reported
+// with 503 and Retry-After, as the request is likely to succeed later.
+// Requests that ran out of their deadline get 504 regardless of err
+func writeServerError(w http.ResponseWriter, r *http.Request, err error) {
+	code := CodeInternal
+	switch {
+	case deadlineExceeded(r):
+		writeError(w, http.StatusGatewayTimeout, CodeRequestTimeout, "Request timed out")
+		return
+	case errors.Is(err, domain.ErrInternalDb) && errors.Is(err, domain.ErrUnavailable):
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+		writeError(w, http.StatusServiceUnavailable, CodeDbUnavailable, "Service temporarily unavailable")
+		return
+	case errors.Is(err, domain.ErrInternalCache):
+		code = CodeCacheUnavailable
+	}
+	writeError(w, http.StatusInternalServerError, code, "Internal server error")
+}
+
+func methodNotAllowed(w http.ResponseWriter) {
+	writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+}
@@ -0,0 +1,111 @@
+package routing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// productETag identifies state of product by its version, which every write
+// bumps, and a digest of its content. Versions start over when product is
+// deleted and created again with the same id, the digest tells those states
+// apart. Every instance computes the same tag whether product comes from
+// cache or database. It is the same for all representations of the product,
+// and clients send it back in If-Match to update that state only
+func productETag(product domain.Product) string {
+	// marshaling a product does not fail
+	content, _ := json.Marshal(product)
+	sum := sha256.Sum256(content)
+	return `"` + strconv.FormatInt(product.Version, 10) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches tells whether header, a list of entity tags or "*", matches
+// etag. Comparison is weak, W/ prefixes are ignored, as If-None-Match
+// requires
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified answers conditional GET with 304 if client already has product
+// in the state etag identifies
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagMatches(header, etag) {
+		return false
+	}
+	// no body, response middlewares must not translate it
+	w.Header().Del("Content-Type")
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// ifMatchTag returns tag If-Match header expects product at and version in
+// it, empty if there is no header or it is "*", any state then. Only a single
+// tag this service issued can be matched, a write can expect one state only
+func ifMatchTag(r *http.Request) (string, int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return "", 0, nil
+	}
+	// If-Match compares strongly, weak tags never match
+	unquoted, ok := strings.CutPrefix(header, `"`)
+	if ok {
+		unquoted, ok = strings.CutSuffix(unquoted, `"`)
+	}
+	versionStr, digest, found := strings.Cut(unquoted, "-")
+	version, err := strconv.ParseInt(versionStr, 10, 64)
+	if !ok || !found || digest == "" || err != nil || version <= 0 {
+		return "", 0, fmt.Errorf("invalid If-Match header %q", header)
+	}
+	return header, version, nil
+}
+
+// expectedState combines tag in If-Match header with version given in body.
+// Version is zero if neither is given, tag is empty unless header is
+func expectedState(r *http.Request, bodyVersion int64) (string, int64, error) {
+	etag, version, err := ifMatchTag(r)
+	if err != nil {
+		return "", 0, err
+	}
+	switch {
+	case bodyVersion < 0:
+		return "", 0, fmt.Errorf("invalid version %d", bodyVersion)
+	case version != 0 && bodyVersion != 0 && version != bodyVersion:
+		return "", 0, errors.New("If-Match header and version in body differ")
+	case version != 0:
+		return etag, version, nil
+	}
+	return "", bodyVersion, nil
+}
+
+// expectETag makes patch fail with ErrVersionConflict unless product is in
+// the state etag identifies
+func expectETag(etag string, patch domain.Patcher) domain.Patcher {
+	return func(product domain.Product) (domain.Product, error) {
+		if err := checkETag(product, etag); err != nil {
+			return product, err
+		}
+		return patch(product)
+	}
+}
+
+func checkETag(product domain.Product, etag string) error {
+	if current := productETag(product); current != etag {
+		return fmt.Errorf("%w: product %d has tag %s, not %s", domain.ErrVersionConflict, product.Id, current, etag)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestConditionalGet(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"})
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(service.NewResourceService(repo, fakes.NewCache()))).SetupRoutes())
+	get := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/product/1", nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, etag, get(nil).Header().Get("ETag"), "tag of unchanged product is stable")
+
+	tests := []struct {
+		name           string
+		header         http.Header
+		expectedStatus int
+	}{
+		{name: "matching tag", header: http.Header{"If-None-Match": {etag}}, expectedStatus: http.StatusNotModified},
+		{name: "tag in list", header: http.Header{"If-None-Match": {`"other", ` + etag}}, expectedStatus: http.StatusNotModified},
+		{name: "weak tag", header: http.Header{"If-None-Match": {"W/" + etag}}, expectedStatus: http.StatusNotModified},
+		{name: "any tag", header: http.Header{"If-None-Match": {"*"}}, expectedStatus: http.StatusNotModified},
+		{name: "other tag", header: http.Header{"If-None-Match": {`"other"`}}, expectedStatus: http.StatusOK},
+		{
+			name:           "matching tag, XML",
+			header:         http.Header{"If-None-Match": {etag}, "Accept": {"application/xml"}},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "matching tag, envelope",
+			header:         http.Header{"If-None-Match": {etag}, "X-Response-Envelope": {"true"}},
+			expectedStatus: http.StatusNotModified,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(tt.header)
+			require.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, etag, rec.Header().Get("ETag"))
+			if tt.expectedStatus == http.StatusNotModified {
+				assert.Empty(t, rec.Body.String())
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/product/1", strings.NewReader(`{"name":"Renamed","additionalInfo":"First info"}`))
+	req.Header.Set("Content-Type", "application/json")
+	updated := httptest.NewRecorder()
+	router.ServeHTTP(updated, req)
+	require.Equal(t, http.StatusOK, updated.Code)
+	assert.NotEqual(t, etag, updated.Header().Get("ETag"))
+
+	rec = get(http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusOK, rec.Code, "changed product is sent again")
+	assert.Equal(t, updated.Header().Get("ETag"), rec.Header().Get("ETag"))
+
+	t.Run("product created again", func(t *testing.T) {
+		deleted := httptest.NewRecorder()
+		router.ServeHTTP(deleted, httptest.NewRequest(http.MethodDelete, "/product/1", nil))
+		require.Equal(t, http.StatusOK, deleted.Code)
+		created, err := repo.UpsertProduct(context.Background(), domain.Product{Id: 1, Name: "Second", AdditionalInfo: "Second info"})
+		require.NoError(t, err)
+		require.True(t, created)
+
+		rec := get(http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, http.StatusOK, rec.Code, "version starts over, tag does not")
+		assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+		assert.Contains(t, rec.Body.String(), "Second")
+	})
+}
+
+func TestConditionalUpdate(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	newRouter := func(opts ...HandlerOption) (http.Handler, *fakes.Repository) {
+		repo := fakes.NewRepository(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"})
+		handler := NewProductHandler(service.NewResourceService(repo, fakes.NewCache()), opts...)
+		return logger.LoggerMiddleware(NewRouter(handler).SetupRoutes()), repo
+	}
+	write := func(router http.Handler, method string, contentType string, body string, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/product/1", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	current := productETag(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info", Version: 1})
+	stale := productETag(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info", Version: 3})
+	// of a product deleted and created again, with the same id and version
+	recreated := productETag(domain.Product{Id: 1, Name: "Other", AdditionalInfo: "Other info", Version: 1})
+	tests := []struct {
+		name           string
+		method         string
+		contentType    string
+		body           string
+		ifMatch        string
+		expectedStatus int
+		expectedCode   ErrorCode
+	}{
+		{name: "put, no precondition", method: http.MethodPut, body: `{"name":"Renamed","additionalInfo":"Info"}`, expectedStatus: http.StatusOK},
+		{name: "put, current tag", method: http.MethodPut, body: `{"name":"Renamed","additionalInfo":"Info"}`, ifMatch: current, expectedStatus: http.StatusOK},
+		{name: "put, any tag", method: http.MethodPut, body: `{"name":"Renamed","additionalInfo":"Info"}`, ifMatch: "*", expectedStatus: http.StatusOK},
+		{name: "put, current version in body", method: http.MethodPut, body: `{"name":"Renamed","additionalInfo":"Info","version":1}`, expectedStatus: http.StatusOK},
+		{name: "put, stale tag", method: http.MethodPut, body: `{"name":"Renamed","additionalInfo":"Info"}`, ifMatch: stale, expectedStatus: http.StatusConflict, expectedCode: CodeConflict},
+		{name: "put, tag of other incarnation", method: http.MethodPut, body: `{"name":"Renamed","additionalInfo":"Info"}`, ifMatch: recreated, expectedStatus: http.StatusConflict, expectedCode: CodeConflict},
+		{name: "put, stale version in body", method: http.MethodPut, body: `{"name":"Renamed","additionalInfo":"Info","version":3}`, expectedStatus: http.StatusConflict, expectedCode: CodeConflict},
+		{name: "put, weak tag", method: http.MethodPut, body: `{"name":"Renamed","additionalInfo":"Info"}`, ifMatch: "W/" + current, expectedStatus: http.StatusBadRequest, expectedCode: CodeInvalidParameter},
+		{name: "put, version only tag", method: http.MethodPut, body: `{"name":"Renamed","additionalInfo":"Info"}`, ifMatch: `"1"`, expectedStatus: http.StatusBadRequest, expectedCode: CodeInvalidParameter},
+		{name: "put, tag and version differ", method: http.MethodPut, body: `{"name":"Renamed","additionalInfo":"Info","version":2}`, ifMatch: current, expectedStatus: http.StatusBadRequest, expectedCode: CodeInvalidParameter},
+		{name: "json patch, current tag", method: http.MethodPatch, contentType: contentTypeJSONPatch, body: `[{"op":"replace","path":"/name","value":"Renamed"}]`, ifMatch: current, expectedStatus: http.StatusOK},
+		{name: "json patch, stale tag", method: http.MethodPatch, contentType: contentTypeJSONPatch, body: `[{"op":"replace","path":"/name","value":"Renamed"}]`, ifMatch: stale, expectedStatus: http.StatusConflict, expectedCode: CodeConflict},
+		{name: "json patch, tag of other incarnation", method: http.MethodPatch, contentType: contentTypeJSONPatch, body: `[{"op":"replace","path":"/name","value":"Renamed"}]`, ifMatch: recreated, expectedStatus: http.StatusConflict, expectedCode: CodeConflict},
+		{name: "merge patch, current version", method: http.MethodPatch, contentType: contentTypeMergePatch, body: `{"name":"Renamed","version":1}`, expectedStatus: http.StatusOK},
+		{name: "merge patch, stale version", method: http.MethodPatch, contentType: contentTypeMergePatch, body: `{"name":"Renamed","version":3}`, expectedStatus: http.StatusConflict, expectedCode: CodeConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, repo := newRouter()
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			rec := write(router, tt.method, contentType, tt.body, tt.ifMatch)
+			require.Equal(t, tt.expectedStatus, rec.Code, rec.Body.String())
+			if tt.expectedCode != "" {
+				var body errorBody
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+				assert.Equal(t, tt.expectedCode, body.Code)
+				assert.Equal(t, "First", repo.Products()[0].Name, "product is left as it was")
+				return
+			}
+			assert.Equal(t, domain.Product{Id: 1, Name: "Renamed", AdditionalInfo: repo.Products()[0].AdditionalInfo, Version: 2}, repo.Products()[0])
+			assert.Equal(t, productETag(repo.Products()[0]), rec.Header().Get("ETag"), "tag is of the state written")
+		})
+	}
+
+	t.Run("version required", func(t *testing.T) {
+		router, _ := newRouter(WithVersionRequired())
+		rec := write(router, http.MethodPut, "application/json", `{"name":"Renamed","additionalInfo":"Info"}`, "")
+		require.Equal(t, http.StatusPreconditionRequired, rec.Code)
+		var body errorBody
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		assert.Equal(t, CodePreconditionRequired, body.Code)
+
+		rec = write(router, http.MethodPatch, contentTypeMergePatch, `{"name":"Renamed","version":1}`, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		rec = write(router, http.MethodPut, "application/json", `{"name":"Renamed again","additionalInfo":"Info"}`, rec.Header().Get("ETag"))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
@@ -0,0 +1,133 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+var productColumns = []xlsxColumn{
+	{title: "id", width: 10, numeric: true},
+	{title: "name", width: 40},
+	{title: "additionalInfo", width: 80},
+}
+
+// exportProducts passes the whole catalog to fn page by page, there is at
+// least one, possibly empty, page. Reading stops at the first failure of
+// either fn or service, which is returned
+func exportProducts(ctx context.Context, svc ports.ResourseService, fn func(page []domain.Product) error) error {
+	if serviceErr := svc.ExportProducts(ctx, fn); serviceErr != nil {
+		storeServiceErrToCtx(ctx, serviceErr)
+		return serviceErr.CriticalError
+	}
+	return nil
+}
+
+// ExportProducts streams the whole catalog as a file, either xlsx sheet or
+// NDJSON. Failure after the start of the file can not change response
+// status anymore, export is cut short then and the file is left incomplete
+func (h *ProductHandler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	switch format := r.URL.Query().Get("format"); format {
+	case "xlsx":
+		h.exportXLSX(w, r)
+	case "ndjson":
+		h.exportNDJSON(w, r)
+	default:
+		errContainer.Add(fmt.Errorf("handler error: unsupported export format %q", format))
+		writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Unsupported export format")
+	}
+}
+
+// exportXLSX writes products sheet, reading the catalog chunk by chunk
+func (h *ProductHandler) exportXLSX(w http.ResponseWriter, r *http.Request) {
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	var sheet *xlsxWriter
+	started := false
+	err := exportProducts(r.Context(), h.svc, func(page []domain.Product) error {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", xlsxContentType)
+			w.Header().Set("Content-Disposition", `attachment; filename="products.xlsx"`)
+			w.WriteHeader(http.StatusOK)
+			var err error
+			if sheet, err = newXLSXWriter(w, productColumns); err != nil {
+				return fmt.Errorf("export error: %w", err)
+			}
+		}
+		for _, p := range page {
+			if err := sheet.WriteRow(strconv.FormatInt(p.Id, 10), p.Name, p.AdditionalInfo); err != nil {
+				return fmt.Errorf("export error: %w", err)
+			}
+		}
+		return nil
+	})
+	switch {
+	case err != nil && !started:
+		writeServerError(w, r, err)
+	case err != nil:
+		errContainer.Add(errors.New("export error: export cut short"))
+	default:
+		if err := sheet.Close(); err != nil {
+			errContainer.Add(fmt.Errorf("export error: %w", err))
+		}
+	}
+}
+
+// exportNDJSON writes one product per line in order of id while products
+// are scanned, output is sent every streamFlushBytes. Unlike xlsx, lines
+// written before a failure are complete products
+func (h *ProductHandler) exportNDJSON(w http.ResponseWriter, r *http.Request) {
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	b := h.json.getBuffer()
+	defer h.json.putBuffer(b)
+
+	started := false
+	send := func() error {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.Header().Set("Content-Disposition", `attachment; filename="products.ndjson"`)
+			w.WriteHeader(http.StatusOK)
+		}
+		_, err := w.Write(b.buf.Bytes())
+		b.buf.Reset()
+		if err != nil {
+			return err
+		}
+		if err := http.NewResponseController(w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+
+	serviceErr := h.svc.EachProduct(r.Context(), domain.ProductFilter{}, nil, func(product domain.Product) error {
+		// Encode ends every product with newline
+		if err := b.enc.Encode(product); err != nil {
+			return fmt.Errorf("export error: failed to encode product %d: %w", product.Id, err)
+		}
+		if b.buf.Len() >= streamFlushBytes {
+			return send()
+		}
+		return nil
+	})
+	if serviceErr != nil {
+		storeServiceErrToCtx(r.Context(), serviceErr)
+		if serviceErr.CriticalError != nil {
+			if !started {
+				writeServerError(w, r, serviceErr.CriticalError)
+				return
+			}
+			errContainer.Add(errors.New("export error: export cut short"))
+			return
+		}
+	}
+	if err := send(); err != nil {
+		errContainer.Add(fmt.Errorf("export error: failed to send products: %w", err))
+	}
+}
@@ -0,0 +1,50 @@
+package routing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestExportProductsNDJSON(t *testing.T) {
+	// enough to be sent in several flushes
+	var products []domain.Product
+	for i := int64(1); i <= 100; i++ {
+		products = append(products, domain.Product{Id: i, Name: fmt.Sprintf("Product %d", i), AdditionalInfo: strings.Repeat("info ", 200), Version: 1})
+	}
+	repo := fakes.NewRepository(products...)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(service.NewResourceService(repo, fakes.NewCache()))).SetupRoutes())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/export?format=ndjson", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, ndjsonContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="products.ndjson"`, rec.Header().Get("Content-Disposition"))
+	assert.True(t, rec.Flushed)
+
+	var exported []domain.Product
+	scanner := bufio.NewScanner(rec.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var product domain.Product
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &product))
+		exported = append(exported, product)
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, products, exported)
+}
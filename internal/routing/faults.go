@@ -0,0 +1,165 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// FaultRule describes faults injected into requests matching method and path.
+// Empty method matches any method, path ending with "/" matches by prefix.
+// Latency is added first, then either connection is dropped or error returned
+type FaultRule struct {
+	Method      string  `json:"method,omitempty"`
+	Path        string  `json:"path"`
+	LatencyMs   int     `json:"latencyMs,omitempty"`
+	JitterMs    int     `json:"jitterMs,omitempty"`
+	ErrorRate   float64 `json:"errorRate,omitempty"`
+	ErrorStatus int     `json:"errorStatus,omitempty"`
+	DropRate    float64 `json:"dropRate,omitempty"`
+}
+
+func (rule FaultRule) validate() error {
+	switch {
+	case rule.Path == "" || !strings.HasPrefix(rule.Path, "/"):
+		return fmt.Errorf("%w: fault rule path must start with /", domain.ErrInvalidInput)
+	case rule.LatencyMs < 0 || rule.JitterMs < 0:
+		return fmt.Errorf("%w: fault rule latency must not be negative", domain.ErrInvalidInput)
+	case rule.ErrorRate < 0 || rule.ErrorRate > 1 || rule.DropRate < 0 || rule.DropRate > 1:
+		return fmt.Errorf("%w: fault rule rates must be within [0, 1]", domain.ErrInvalidInput)
+	case rule.ErrorStatus != 0 && (rule.ErrorStatus < 400 || rule.ErrorStatus > 599):
+		return fmt.Errorf("%w: fault rule error status must be 4xx or 5xx", domain.ErrInvalidInput)
+	}
+	return nil
+}
+
+func (rule FaultRule) matches(r *http.Request) bool {
+	return matchesRoute(rule.Method, rule.Path, r)
+}
+
+// matchesRoute matches any method if method is empty, and by prefix if
+// path ends with "/"
+func matchesRoute(method string, path string, r *http.Request) bool {
+	if method != "" && !strings.EqualFold(method, r.Method) {
+		return false
+	}
+	if strings.HasSuffix(path, "/") {
+		return strings.HasPrefix(r.URL.Path, path)
+	}
+	return r.URL.Path == path
+}
+
+// FaultInjector is a chaos testing middleware. Rules can be replaced at
+// runtime, the first matching rule applies
+type FaultInjector struct {
+	mu     sync.RWMutex
+	rules  []FaultRule
+	random func() float64
+}
+
+func NewFaultInjector(rules []FaultRule) (*FaultInjector, error) {
+	f := &FaultInjector{random: rand.Float64}
+	if err := f.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ParseFaultRules parses rules from JSON array, empty string means no rules
+func ParseFaultRules(s string) ([]FaultRule, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var rules []FaultRule
+	if err := json.Unmarshal([]byte(s), &rules); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse fault rules: %s", domain.ErrInvalidInput, err.Error())
+	}
+	return rules, nil
+}
+
+func (f *FaultInjector) Rules() []FaultRule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]FaultRule{}, f.rules...)
+}
+
+func (f *FaultInjector) SetRules(rules []FaultRule) error {
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append([]FaultRule{}, rules...)
+	return nil
+}
+
+func (f *FaultInjector) match(r *http.Request) (FaultRule, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, rule := range f.rules {
+		if rule.matches(r) {
+			return rule, true
+		}
+	}
+	return FaultRule{}, false
+}
+
+func (f *FaultInjector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := f.match(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if rule.LatencyMs > 0 || rule.JitterMs > 0 {
+			delay := time.Duration(rule.LatencyMs) * time.Millisecond
+			if rule.JitterMs > 0 {
+				delay += time.Duration(f.random() * float64(time.Duration(rule.JitterMs)*time.Millisecond))
+			}
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if rule.DropRate > 0 && f.random() < rule.DropRate {
+			addFaultError(r, fmt.Errorf("fault injection: connection dropped"))
+			if hijacker, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hijacker.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+			// aborts response without logging a stack trace
+			panic(http.ErrAbortHandler)
+		}
+
+		if rule.ErrorRate > 0 && f.random() < rule.ErrorRate {
+			status := rule.ErrorStatus
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+			addFaultError(r, fmt.Errorf("fault injection: responded with status %d", status))
+			writeError(w, status, CodeInjectedFault, "Injected fault")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func addFaultError(r *http.Request, err error) {
+	if errContainer, ok := r.Context().Value("errorContainer").(*domain.ErrorContainer); ok {
+		errContainer.Add(err)
+	}
+}
@@ -0,0 +1,178 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFaultInjector(t *testing.T, rules []FaultRule, random float64) *FaultInjector {
+	faults, err := NewFaultInjector(rules)
+	require.NoError(t, err)
+	faults.random = func() float64 { return random }
+	return faults
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestFaultInjectorErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		rule           FaultRule
+		random         float64
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{
+			name:           "exact path - error injected",
+			rule:           FaultRule{Path: "/products", ErrorRate: 0.5, ErrorStatus: http.StatusServiceUnavailable},
+			random:         0.1,
+			method:         http.MethodGet,
+			path:           "/products",
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "error rate not hit - passed through",
+			rule:           FaultRule{Path: "/products", ErrorRate: 0.5},
+			random:         0.9,
+			method:         http.MethodGet,
+			path:           "/products",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "prefix path - default status",
+			rule:           FaultRule{Method: "put", Path: "/product/", ErrorRate: 1},
+			method:         http.MethodPut,
+			path:           "/product/3",
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "other method - passed through",
+			rule:           FaultRule{Method: http.MethodPut, Path: "/product/", ErrorRate: 1},
+			method:         http.MethodGet,
+			path:           "/product/3",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "exact path does not match subpath",
+			rule:           FaultRule{Path: "/products", ErrorRate: 1},
+			method:         http.MethodGet,
+			path:           "/products/search",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			faults := newTestFaultInjector(t, []FaultRule{tt.rule}, tt.random)
+			rec := httptest.NewRecorder()
+			faults.Middleware(okHandler).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestFaultInjectorLatency(t *testing.T) {
+	faults := newTestFaultInjector(t, []FaultRule{{Path: "/products", LatencyMs: 30, JitterMs: 20}}, 0.5)
+	rec := httptest.NewRecorder()
+	started := time.Now()
+	faults.Middleware(okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products", nil))
+	assert.GreaterOrEqual(t, time.Since(started), 40*time.Millisecond)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestFaultInjectorDrop(t *testing.T) {
+	faults := newTestFaultInjector(t, []FaultRule{{Path: "/products", DropRate: 1}}, 0)
+	server := httptest.NewServer(faults.Middleware(okHandler))
+	defer server.Close()
+
+	_, err := server.Client().Get(server.URL + "/products")
+	assert.Error(t, err)
+}
+
+func TestFaultRulesValidation(t *testing.T) {
+	invalid := []FaultRule{
+		{Path: "products"},
+		{Path: "/products", LatencyMs: -1},
+		{Path: "/products", ErrorRate: 1.5},
+		{Path: "/products", DropRate: -0.1},
+		{Path: "/products", ErrorStatus: 200},
+	}
+	for _, rule := range invalid {
+		_, err := NewFaultInjector([]FaultRule{rule})
+		assert.Error(t, err, rule)
+	}
+
+	rules, err := ParseFaultRules(`[{"path":"/products","latencyMs":100}]`)
+	require.NoError(t, err)
+	assert.Equal(t, []FaultRule{{Path: "/products", LatencyMs: 100}}, rules)
+}
+
+func TestUpdateFaultRules(t *testing.T) {
+	tests := []struct {
+		name           string
+		enabled        bool
+		body           string
+		expectedStatus int
+		expectedRules  []FaultRule
+	}{
+		{
+			name:           "update rules - success",
+			enabled:        true,
+			body:           `[{"path":"/products","errorRate":0.1}]`,
+			expectedStatus: http.StatusOK,
+			expectedRules:  []FaultRule{{Path: "/products", ErrorRate: 0.1}},
+		},
+		{
+			name:           "update rules - invalid rule",
+			enabled:        true,
+			body:           `[{"path":"/products","errorRate":2}]`,
+			expectedStatus: http.StatusBadRequest,
+			expectedRules:  []FaultRule{},
+		},
+		{
+			name:           "update rules - fault injection disabled",
+			body:           `[]`,
+			expectedStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+			require.NoError(t, err)
+			defer logger.Close()
+			var opts []AdminOption
+			var faults *FaultInjector
+			if tt.enabled {
+				faults = newTestFaultInjector(t, nil, 0)
+				opts = append(opts, WithFaultInjector(faults))
+			}
+			router := logger.LoggerMiddleware(NewAdminRouter(NewAdminHandler(logger, opts...)).SetupRoutes())
+
+			req := httptest.NewRequest(http.MethodPut, "/admin/faults", bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if faults != nil {
+				assert.Equal(t, tt.expectedRules, faults.Rules())
+			}
+			if tt.expectedStatus == http.StatusOK {
+				var rules []FaultRule
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&rules))
+				assert.Equal(t, tt.expectedRules, rules)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+// newFuzzHandler returns handler over fakes holding products 1 and 2
+func newFuzzHandler() *ProductHandler {
+	repo := fakes.NewRepository(
+		domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"},
+		domain.Product{Id: 2, Name: "Second", AdditionalInfo: "Second info"},
+	)
+	return NewProductHandler(service.NewResourceService(repo, fakes.NewCache()))
+}
+
+// serveFuzz calls handler directly rather than through ServeMux, which would
+// redirect unclean paths before handlers see them
+func serveFuzz(handler http.HandlerFunc, method string, path string, query string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/", bytes.NewReader(body))
+	req.URL.Path = path
+	req.URL.RawQuery = query
+	errContainer := domain.NewErrorContainer()
+	req = req.WithContext(context.WithValue(req.Context(), "errorContainer", &errContainer))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func assertJSONResponse(t *testing.T, rec *httptest.ResponseRecorder, allowed ...int) {
+	t.Helper()
+	statusAllowed := false
+	for _, status := range allowed {
+		statusAllowed = statusAllowed || rec.Code == status
+	}
+	if !statusAllowed {
+		t.Fatalf("unexpected status %d, allowed %v, body %q", rec.Code, allowed, rec.Body.String())
+	}
+	if !json.Valid(rec.Body.Bytes()) {
+		t.Fatalf("response body is not valid json: %q", rec.Body.String())
+	}
+}
+
+func FuzzParseAndValidate(f *testing.F) {
+	for _, seed := range []string{"0", "1", "-1", "9223372036854775807", "9223372036854775808", "", " 1", "1e3", "0x10", "+5"} {
+		f.Add(seed, int64(0))
+	}
+	f.Fuzz(func(t *testing.T, s string, lb int64) {
+		errContainer := domain.NewErrorContainer()
+		rec := httptest.NewRecorder()
+		value, err := parseAndValidate(s, lb, "offset", &errContainer, rec)
+
+		parsed, parseErr := strconv.ParseInt(s, 10, 64)
+		valid := parseErr == nil && parsed >= lb
+		if valid != (err == nil) {
+			t.Fatalf("input %q, lb %d: valid=%v, got err %v", s, lb, valid, err)
+		}
+		if err != nil {
+			assertJSONResponse(t, rec, http.StatusBadRequest)
+			if len(errContainer.Unwrap()) == 0 {
+				t.Fatal("error is not recorded to error container")
+			}
+			return
+		}
+		if value != parsed || rec.Body.Len() != 0 {
+			t.Fatalf("input %q: got %d and body %q", s, value, rec.Body.String())
+		}
+	})
+}
+
+func FuzzGetProducts(f *testing.F) {
+	for _, seed := range [][2]string{{"0", "20"}, {"1", "1"}, {"-1", "5"}, {"0", "0"}, {"abc", "1"}, {"", "10"}, {"9223372036854775807", "9223372036854775807"}} {
+		f.Add(seed[0], seed[1])
+	}
+	handler := newFuzzHandler()
+	f.Fuzz(func(t *testing.T, offset string, limit string) {
+		query := url.Values{"offset": {offset}, "limit": {limit}}.Encode()
+		rec := serveFuzz(handler.GetProducts, http.MethodGet, "/products", query, nil)
+		assertJSONResponse(t, rec, http.StatusOK, http.StatusBadRequest)
+	})
+}
+
+func FuzzProductId(f *testing.F) {
+	for _, seed := range []string{"1", "2", "42", "0", "-1", "abc", "1/2", "", "%00", "١"} {
+		f.Add(seed)
+	}
+	handler := newFuzzHandler()
+	f.Fuzz(func(t *testing.T, id string) {
+		rec := serveFuzz(handler.GetProductById, http.MethodGet, "/product/"+id, "", nil)
+		assertJSONResponse(t, rec, http.StatusOK, http.StatusBadRequest, http.StatusNotFound)
+		if rec.Code == http.StatusOK {
+			if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+				t.Fatalf("id %q is not a number but product was found", id)
+			}
+		}
+	})
+}
+
+func FuzzProductBody(f *testing.F) {
+	for _, seed := range []string{
+		`{"name":"Product","additionalInfo":"Info"}`,
+		`{"name":"","additionalInfo":"Info"}`,
+		`{"name":"Product"}`,
+		`{"name":"Product","additionalInfo":"Info","price":1}`,
+		`{"name":1}`,
+		`[]`,
+		`null`,
+		`{"name":"Product","additionalInfo":"Info"} trailing`,
+		``,
+		`{`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		handler := newFuzzHandler()
+		created := serveFuzz(handler.CreateProduct, http.MethodPost, "/product", "", body)
+		assertJSONResponse(t, created, http.StatusCreated, http.StatusBadRequest)
+
+		updated := serveFuzz(handler.UpdateProduct, http.MethodPut, "/product/1", "", body)
+		assertJSONResponse(t, updated, http.StatusOK, http.StatusBadRequest)
+
+		// both endpoints take the same payload, so they must agree on its validity
+		if (created.Code == http.StatusCreated) != (updated.Code == http.StatusOK) {
+			t.Fatalf("create responded %d, update responded %d for %q", created.Code, updated.Code, body)
+		}
+		if created.Code == http.StatusCreated {
+			var product domain.NewProduct
+			if err := json.Unmarshal(body, &product); err != nil || product.Name == "" || product.AdditionalInfo == "" {
+				t.Fatalf("invalid body %q was accepted", body)
+			}
+		}
+	})
+}
@@ -0,0 +1,568 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// graphQLError is reported to clients with code in extensions, so they can
+// tell errors apart as they do with codes of REST endpoints
+type graphQLError struct {
+	message string
+	code    ErrorCode
+}
+
+func (e graphQLError) Error() string {
+	return e.message
+}
+
+func (e graphQLError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+// productPage is resolved lazily, total is only counted if asked for
+type productPage struct {
+	items   []domain.Product
+	filter  domain.ProductFilter
+	hasMore bool
+}
+
+// PostGraphQL executes GraphQL query or mutation against product schema.
+// Like other GraphQL servers it responds 200 to any request it could parse,
+// errors of fields are in errors of response
+func (h *ProductHandler) PostGraphQL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req graphQLRequest
+	if err := h.json.decode(r.Body, &req); err != nil || req.Query == "" {
+		if err == nil {
+			err = errors.New("query is empty")
+		}
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(fmt.Errorf("handler error: failed to decode graphql request: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	result := graphql.Do(graphql.Params{
+		Schema:         h.graphQLSchema(),
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        r.Context(),
+	})
+	h.json.write(w, http.StatusOK, result)
+}
+
+// graphQLSchema is built once per handler, resolvers call its service
+func (h *ProductHandler) graphQLSchema() graphql.Schema {
+	h.graphQLOnce.Do(func() {
+		schema, err := newGraphQLSchema(h)
+		if err != nil {
+			// schema is static, it can only be wrong if code is
+			panic(err)
+		}
+		h.graphQL = schema
+	})
+	return h.graphQL
+}
+
+func newGraphQLSchema(h *ProductHandler) (graphql.Schema, error) {
+	image := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ProductImage",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return strconv.FormatInt(p.Source.(domain.ProductImage).Id, 10), nil
+				},
+			},
+			"url": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(domain.ProductImage).URL, nil
+				},
+			},
+			"contentType": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(domain.ProductImage).ContentType, nil
+				},
+			},
+			"size": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Size of image in bytes",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(domain.ProductImage).Size, nil
+				},
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.DateTime),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(domain.ProductImage).CreatedAt, nil
+				},
+			},
+		},
+	})
+	product := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return strconv.FormatInt(p.Source.(domain.Product).Id, 10), nil
+				},
+			},
+			"name": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(domain.Product).Name, nil
+				},
+			},
+			"additionalInfo": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(domain.Product).AdditionalInfo, nil
+				},
+			},
+			"version": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(domain.Product).Version, nil
+				},
+			},
+			"categoryId": &graphql.Field{
+				Type:        graphql.ID,
+				Description: "Category product belongs to, null if none",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if id := p.Source.(domain.Product).CategoryId; id != 0 {
+						return strconv.FormatInt(id, 10), nil
+					}
+					return nil, nil
+				},
+			},
+			"sku": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Stock keeping unit, unique among products, null if none",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if sku := p.Source.(domain.Product).Sku; sku != "" {
+						return sku, nil
+					}
+					return nil, nil
+				},
+			},
+			"stock": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Quantity in stock",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(domain.Product).Stock, nil
+				},
+			},
+			"tags": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if tags := p.Source.(domain.Product).Tags; tags != nil {
+						return tags, nil
+					}
+					return []string{}, nil
+				},
+			},
+			"images": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(image))),
+				Description: "Images of product in order of upload",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if images := p.Source.(domain.Product).Images; images != nil {
+						return images, nil
+					}
+					return []domain.ProductImage{}, nil
+				},
+			},
+		},
+	})
+	page := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ProductPage",
+		Fields: graphql.Fields{
+			"items": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(product))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*productPage).items, nil
+				},
+			},
+			"hasMore": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*productPage).hasMore, nil
+				},
+			},
+			"total": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Count of products matching filter, on all pages",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					total, serviceErr := h.svc.CountProducts(p.Context, p.Source.(*productPage).filter)
+					if err := graphQLServiceError(p.Context, serviceErr); err != nil {
+						return nil, err
+					}
+					return int(total), nil
+				},
+			},
+		},
+	})
+
+	idArg := &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}
+	productArgs := graphql.FieldConfigArgument{
+		"name":           &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"additionalInfo": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"categoryId":     &graphql.ArgumentConfig{Type: graphql.ID},
+		"sku":            &graphql.ArgumentConfig{Type: graphql.String},
+	}
+	tagsArgs := graphql.FieldConfigArgument{
+		"id":   idArg,
+		"tags": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+	}
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"product": &graphql.Field{
+				Type:        product,
+				Description: "Product by id, null if there is none",
+				Args:        graphql.FieldConfigArgument{"id": idArg},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := graphQLId(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					body, serviceErr := h.svc.GetProductById(p.Context, id)
+					if serviceErr != nil && errors.Is(serviceErr.CriticalError, domain.ErrNotFound) {
+						return nil, nil
+					}
+					if err := graphQLServiceError(p.Context, serviceErr); err != nil {
+						return nil, err
+					}
+					var found domain.Product
+					if err := json.Unmarshal(body, &found); err != nil {
+						return nil, graphQLServiceError(p.Context, domain.NewServiceError(err, nil))
+					}
+					return found, nil
+				},
+			},
+			"products": &graphql.Field{
+				Type:        graphql.NewNonNull(page),
+				Description: "Products in order of id or of sort, e.g. \"name,-id\". Without limit all products from offset are returned",
+				Args: graphql.FieldConfigArgument{
+					"limit":        &graphql.ArgumentConfig{Type: graphql.Int},
+					"offset":       &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+					"name":         &graphql.ArgumentConfig{Type: graphql.String},
+					"infoContains": &graphql.ArgumentConfig{Type: graphql.String},
+					"category":     &graphql.ArgumentConfig{Type: graphql.ID},
+					"tag":          &graphql.ArgumentConfig{Type: graphql.String},
+					"sort":         &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: h.resolveProducts,
+			},
+		},
+	})
+	mutations := graphql.Fields{
+		"createProduct": &graphql.Field{
+			Type: graphql.NewNonNull(product),
+			Args: productArgs,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				input, err := graphQLProductInput(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				id, serviceErr := h.svc.CreateProduct(p.Context, input)
+				if err := graphQLServiceError(p.Context, serviceErr); err != nil {
+					return nil, err
+				}
+				return domain.Product{Id: id, Name: input.Name, AdditionalInfo: input.AdditionalInfo, Version: 1, CategoryId: input.CategoryId, Sku: input.Sku}, nil
+			},
+		},
+		"updateProduct": &graphql.Field{
+			Type: graphql.NewNonNull(product),
+			Args: graphql.FieldConfigArgument{
+				"id":             idArg,
+				"name":           productArgs["name"],
+				"additionalInfo": productArgs["additionalInfo"],
+				"categoryId":     productArgs["categoryId"],
+				"sku":            productArgs["sku"],
+				"version": &graphql.ArgumentConfig{
+					Type:        graphql.Int,
+					Description: "Version product is expected to be at, update fails with CONFLICT otherwise. Deployments may require it, updates without fail with PRECONDITION_REQUIRED then",
+				},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := graphQLId(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				input, err := graphQLProductInput(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				if version, ok := p.Args["version"].(int); ok {
+					input.Version = int64(version)
+				}
+				// service returns product as it was before the update
+				old, serviceErr := h.svc.UpdateProductById(p.Context, id, input)
+				if err := graphQLServiceError(p.Context, serviceErr); err != nil {
+					return nil, err
+				}
+				return domain.Product{Id: id, Name: input.Name, AdditionalInfo: input.AdditionalInfo, Version: old.Version + 1, CategoryId: input.CategoryId, Sku: input.Sku, Stock: old.Stock, Tags: old.Tags, Images: old.Images}, nil
+			},
+		},
+		"deleteProduct": &graphql.Field{
+			Type:        graphql.NewNonNull(product),
+			Description: "Deletes product, returns it as it was",
+			Args:        graphql.FieldConfigArgument{"id": idArg},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := graphQLId(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				deleted, serviceErr := h.svc.DeleteProductById(p.Context, id)
+				if err := graphQLServiceError(p.Context, serviceErr); err != nil {
+					return nil, err
+				}
+				return *deleted, nil
+			},
+		},
+		"addProductTags": &graphql.Field{
+			Type:        graphql.NewNonNull(product),
+			Description: "Tags product, tags it already has are left as they are",
+			Args:        tagsArgs,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return h.resolveRetag(p, h.svc.AddProductTags)
+			},
+		},
+		"removeProductTags": &graphql.Field{
+			Type:        graphql.NewNonNull(product),
+			Description: "Removes tags from product, tags it does not have are ignored",
+			Args:        tagsArgs,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return h.resolveRetag(p, h.svc.RemoveProductTags)
+			},
+		},
+		"adjustProductStock": &graphql.Field{
+			Type:        graphql.NewNonNull(product),
+			Description: "Adds delta to product stock, fails with INSUFFICIENT_STOCK rather than take stock below zero",
+			Args: graphql.FieldConfigArgument{
+				"id":    idArg,
+				"delta": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := graphQLId(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				delta, _ := p.Args["delta"].(int)
+				if delta == 0 {
+					return nil, graphQLError{message: "Stock delta is zero", code: CodeInvalidParameter}
+				}
+				product, serviceErr := h.svc.AdjustProductStock(p.Context, id, int64(delta))
+				if err := graphQLServiceError(p.Context, serviceErr); err != nil {
+					return nil, err
+				}
+				return *product, nil
+			},
+		},
+	}
+	// queries are authorized as reads with the request, mutations are
+	// authorized as writes one by one
+	for _, field := range mutations {
+		field.Resolve = h.authorizeGraphQLWrite(field.Resolve)
+	}
+	mutation := graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: mutations})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+// authorizeGraphQLWrite lets mutation resolve only if caller may write and
+// has a key, if writes need one, unless neither is enabled
+func (h *ProductHandler) authorizeGraphQLWrite(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	if h.authorizer == nil && h.apiKeys == nil {
+		return resolve
+	}
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		errContainer := p.Context.Value("errorContainer").(*domain.ErrorContainer)
+		// any authenticated caller needs no key, as with API key middleware
+		if h.apiKeys != nil && h.apiKeys.RequiresKey(domain.ActionWrite) && PrincipalFromContext(p.Context) == "" {
+			errContainer.Add(errors.New("api key error: no API key provided"))
+			return nil, graphQLError{message: "API key required", code: CodeUnauthenticated}
+		}
+		if h.authorizer == nil {
+			return resolve(p)
+		}
+		err := h.authorizer.Authorize(p.Context, domain.ActionWrite)
+		if err == nil {
+			return resolve(p)
+		}
+		errContainer.Add(fmt.Errorf("authorization error: %w", err))
+		if errors.Is(err, domain.ErrUnauthenticated) {
+			return nil, graphQLError{message: "Authentication required", code: CodeUnauthenticated}
+		}
+		return nil, graphQLError{message: "Not allowed to write", code: CodeForbidden}
+	}
+}
+
+func (h *ProductHandler) resolveProducts(p graphql.ResolveParams) (interface{}, error) {
+	offset, _ := p.Args["offset"].(int)
+	limit, limited := p.Args["limit"].(int)
+	if offset < 0 || (limited && limit < 1) {
+		return nil, graphQLError{message: "Invalid limit or offset", code: CodeInvalidParameter}
+	}
+	name, _ := p.Args["name"].(string)
+	infoContains, _ := p.Args["infoContains"].(string)
+	category, err := graphQLCategoryId(p.Args, "category")
+	if err != nil {
+		return nil, err
+	}
+	filter := domain.ProductFilter{Name: strings.TrimSpace(name), InfoContains: infoContains, CategoryId: category}
+	if tag, _ := p.Args["tag"].(string); tag != "" {
+		if filter.Tag, err = domain.NormalizeTag(tag); err != nil {
+			return nil, graphQLError{message: "Invalid tag", code: CodeInvalidParameter}
+		}
+	}
+	sortValue, _ := p.Args["sort"].(string)
+	sort, err := domain.ParseProductSort(sortValue)
+	if err != nil {
+		return nil, graphQLError{message: "Invalid sort", code: CodeInvalidParameter}
+	}
+
+	page := &productPage{filter: filter}
+	var serviceErr *domain.ServiceError
+	if limited {
+		// one more product tells whether there is next page
+		page.items, serviceErr = h.svc.GetProductsPaged(p.Context, filter, sort, int64(limit)+1, int64(offset))
+		if len(page.items) > limit {
+			page.items, page.hasMore = page.items[:limit], true
+		}
+	} else {
+		skipped := 0
+		serviceErr = h.svc.EachProduct(p.Context, filter, sort, func(product domain.Product) error {
+			if skipped < offset {
+				skipped++
+				return nil
+			}
+			page.items = append(page.items, product)
+			return nil
+		})
+	}
+	if serviceErr != nil && errors.Is(serviceErr.CriticalError, domain.ErrInvalidInput) {
+		storeServiceErrToCtx(p.Context, serviceErr)
+		return nil, graphQLError{message: "Invalid sort", code: CodeInvalidParameter}
+	}
+	if err := graphQLServiceError(p.Context, serviceErr); err != nil {
+		return nil, err
+	}
+	if page.items == nil {
+		page.items = []domain.Product{}
+	}
+	return page, nil
+}
+
+// resolveRetag changes tags of product by write, returning it as it is after
+func (h *ProductHandler) resolveRetag(p graphql.ResolveParams, write func(ctx context.Context, id int64, tags []string) (*domain.Product, *domain.ServiceError)) (interface{}, error) {
+	id, err := graphQLId(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	values, _ := p.Args["tags"].([]interface{})
+	tags := make([]string, 0, len(values))
+	for _, value := range values {
+		tag, _ := value.(string)
+		if _, err := domain.NormalizeTag(tag); err != nil {
+			return nil, graphQLError{message: "Invalid tag", code: CodeInvalidParameter}
+		}
+		tags = append(tags, tag)
+	}
+	product, serviceErr := write(p.Context, id, tags)
+	if err := graphQLServiceError(p.Context, serviceErr); err != nil {
+		return nil, err
+	}
+	return *product, nil
+}
+
+func graphQLId(args map[string]interface{}) (int64, error) {
+	value, _ := args["id"].(string)
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, graphQLError{message: "Invalid product id", code: CodeInvalidId}
+	}
+	return id, nil
+}
+
+func graphQLProductInput(args map[string]interface{}) (domain.NewProduct, error) {
+	name, _ := args["name"].(string)
+	info, _ := args["additionalInfo"].(string)
+	if name == "" || info == "" {
+		return domain.NewProduct{}, graphQLError{message: "Product name or additional info is empty", code: CodeInvalidBody}
+	}
+	category, err := graphQLCategoryId(args, "categoryId")
+	if err != nil {
+		return domain.NewProduct{}, err
+	}
+	sku, _ := args["sku"].(string)
+	if sku != "" && domain.ValidateSku(sku) != nil {
+		return domain.NewProduct{}, graphQLError{message: "Invalid SKU", code: CodeInvalidParameter}
+	}
+	return domain.NewProduct{Name: name, AdditionalInfo: info, CategoryId: category, Sku: sku}, nil
+}
+
+// graphQLCategoryId reads optional category id argument, zero if absent
+func graphQLCategoryId(args map[string]interface{}, name string) (int64, error) {
+	value, ok := args[name].(string)
+	if !ok {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 1 {
+		return 0, graphQLError{message: "Invalid category id", code: CodeInvalidParameter}
+	}
+	return id, nil
+}
+
+// graphQLServiceError leaves service errors for request log and reports
+// critical one with the code REST endpoints would respond with
+func graphQLServiceError(ctx context.Context, serviceErr *domain.ServiceError) error {
+	if serviceErr == nil {
+		return nil
+	}
+	storeServiceErrToCtx(ctx, serviceErr)
+	err := serviceErr.CriticalError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, domain.ErrNotFound):
+		return graphQLError{message: "Product not found", code: CodeProductNotFound}
+	case errors.Is(err, domain.ErrVersionConflict):
+		return graphQLError{message: "Product was changed since the version given", code: CodeConflict}
+	case errors.Is(err, domain.ErrVersionRequired):
+		return graphQLError{message: "Version is required", code: CodePreconditionRequired}
+	case errors.Is(err, domain.ErrUnknownCategory):
+		return graphQLError{message: "Category does not exist", code: CodeUnknownCategory}
+	case errors.Is(err, domain.ErrDuplicateSku):
+		return graphQLError{message: "SKU is already used by another product", code: CodeDuplicateSku}
+	case errors.Is(err, domain.ErrInsufficientStock):
+		return graphQLError{message: "Not enough items in stock", code: CodeInsufficientStock}
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return graphQLError{message: "Request timed out", code: CodeRequestTimeout}
+	case errors.Is(err, domain.ErrInternalDb) && errors.Is(err, domain.ErrUnavailable):
+		return graphQLError{message: "Service temporarily unavailable", code: CodeDbUnavailable}
+	case errors.Is(err, domain.ErrInternalCache):
+		return graphQLError{message: "Internal server error", code: CodeCacheUnavailable}
+	}
+	return graphQLError{message: "Internal server error", code: CodeInternal}
+}
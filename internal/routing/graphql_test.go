@@ -0,0 +1,186 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+type graphQLResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string            `json:"message"`
+		Extensions map[string]string `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestGraphQL(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	repo := fakes.NewRepository()
+	svc := service.NewResourceService(repo, fakes.NewCache())
+	// enveloping must not apply to GraphQL responses
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, WithResponseEnvelope(true))).SetupRoutes())
+	for _, p := range []domain.NewProduct{{Name: "lamp", AdditionalInfo: "brass"}, {Name: "desk", AdditionalInfo: "oak"}, {Name: "chair", AdditionalInfo: "oak"}} {
+		_, serviceErr := svc.CreateProduct(context.Background(), p)
+		require.Nil(t, serviceErr)
+	}
+
+	do := func(t *testing.T, query string, variables map[string]interface{}) graphQLResponse {
+		t.Helper()
+		body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp graphQLResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	t.Run("product by id", func(t *testing.T) {
+		resp := do(t, `query($id: ID!) { product(id: $id) { id name } }`, map[string]interface{}{"id": "1"})
+		assert.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"id": "1", "name": "lamp"}`, string(resp.Data["product"]))
+
+		resp = do(t, `{ product(id: "42") { id } }`, nil)
+		assert.Empty(t, resp.Errors)
+		assert.JSONEq(t, `null`, string(resp.Data["product"]))
+	})
+
+	t.Run("filtered page", func(t *testing.T) {
+		resp := do(t, `{ products(infoContains: "oak", sort: "name", limit: 1) { items { name } hasMore total } }`, nil)
+		assert.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"items": [{"name": "chair"}], "hasMore": true, "total": 2}`, string(resp.Data["products"]))
+
+		resp = do(t, `{ products(offset: 1) { items { id } hasMore } }`, nil)
+		assert.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"items": [{"id": "2"}, {"id": "3"}], "hasMore": false}`, string(resp.Data["products"]))
+	})
+
+	t.Run("mutations", func(t *testing.T) {
+		resp := do(t, `mutation { createProduct(name: "shelf", additionalInfo: "pine") { id name } }`, nil)
+		assert.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"id": "4", "name": "shelf"}`, string(resp.Data["createProduct"]))
+
+		resp = do(t, `mutation { updateProduct(id: "4", name: "shelf", additionalInfo: "birch") { additionalInfo } }`, nil)
+		assert.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"additionalInfo": "birch"}`, string(resp.Data["updateProduct"]))
+
+		resp = do(t, `mutation { deleteProduct(id: "4") { name } }`, nil)
+		assert.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"name": "shelf"}`, string(resp.Data["deleteProduct"]))
+		_, err := repo.GetProduct(context.Background(), 4)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("errors carry codes", func(t *testing.T) {
+		tests := []struct {
+			query string
+			code  ErrorCode
+		}{
+			{`mutation { deleteProduct(id: "42") { id } }`, CodeProductNotFound},
+			{`mutation { createProduct(name: "", additionalInfo: "pine") { id } }`, CodeInvalidBody},
+			{`{ product(id: "lamp") { id } }`, CodeInvalidId},
+			{`{ products(sort: "price", limit: 5) { total } }`, CodeInvalidParameter},
+			{`{ products(limit: 0) { total } }`, CodeInvalidParameter},
+		}
+		for _, tt := range tests {
+			resp := do(t, tt.query, nil)
+			require.Len(t, resp.Errors, 1, tt.query)
+			assert.Equal(t, string(tt.code), resp.Errors[0].Extensions["code"], tt.query)
+		}
+
+		resp := do(t, `{ products { price } }`, nil)
+		assert.NotEmpty(t, resp.Errors, "unknown fields fail validation")
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": ""}`)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/graphql", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "POST, OPTIONS", rec.Header().Get("Allow"))
+	})
+}
+
+func TestGraphQLVersionRequired(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "lamp", AdditionalInfo: "brass", Version: 1})
+	svc := service.NewResourceService(repo, fakes.NewCache(), service.WithVersionRequired())
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, WithVersionRequired())).SetupRoutes())
+
+	do := func(query string) graphQLResponse {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(query)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp graphQLResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := do(`{"query": "mutation { updateProduct(id: \"1\", name: \"lamp\", additionalInfo: \"copper\") { version } }"}`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, string(CodePreconditionRequired), resp.Errors[0].Extensions["code"])
+	assert.Equal(t, "brass", repo.Products()[0].AdditionalInfo)
+
+	resp = do(`{"query": "mutation { updateProduct(id: \"1\", name: \"lamp\", additionalInfo: \"copper\", version: 1) { version } }"}`)
+	assert.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"version": 2}`, string(resp.Data["updateProduct"]))
+}
+
+func TestGraphQLMutationsNeedAPIKey(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	keys := fakes.NewAPIKeyRepository()
+	_, err = keys.CreateAPIKey(context.Background(), domain.APIKey{Principal: "partner", Role: domain.RoleWriter}, HashAPIKey("valid"))
+	require.NoError(t, err)
+	apiKeys := NewAPIKeyAuthenticator(keys, false, time.Minute)
+	svc := service.NewResourceService(fakes.NewRepository(domain.Product{Id: 1, Name: "lamp", AdditionalInfo: "brass"}), fakes.NewCache())
+	router := logger.LoggerMiddleware(apiKeys.Middleware(NewRouter(NewProductHandler(svc, WithAPIKeyPolicy(apiKeys))).SetupRoutes()))
+
+	do := func(query string, key string) graphQLResponse {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(query))
+		if key != "" {
+			req.Header.Set(apiKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp graphQLResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := do(`{"query": "{ product(id: \"1\") { name } }"}`, "")
+	assert.Empty(t, resp.Errors, "queries need no key")
+	assert.JSONEq(t, `{"name": "lamp"}`, string(resp.Data["product"]))
+
+	mutation := `{"query": "mutation { deleteProduct(id: \"1\") { name } }"}`
+	resp = do(mutation, "")
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, string(CodeUnauthenticated), resp.Errors[0].Extensions["code"])
+
+	resp = do(mutation, "valid")
+	assert.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"name": "lamp"}`, string(resp.Data["deleteProduct"]))
+}
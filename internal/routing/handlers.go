@@ -1,36 +1,198 @@
 package routing
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/graphql-go/graphql"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/pelyams/simpler_go_service/internal/domain"
 	"github.com/pelyams/simpler_go_service/internal/ports"
 )
 
 type ProductHandler struct {
-	svc ports.ResourseService
+	svc            ports.ResourseService
+	links          *LinkBuilder
+	confirmations  ports.ConfirmationStore
+	confirmTTL     time.Duration
+	envelope       bool
+	maxBodyBytes   int64
+	maxImageBytes  int64
+	changes        ports.ChangeFeed
+	maxChangesWait time.Duration
+	listMemo       *listMemo
+	json           *jsonCodec
+	deprecations   []Deprecation
+	openAPI        []byte
+	swaggerUI      bool
+	graphQLOnce    sync.Once
+	graphQL        graphql.Schema
+	authorizer     *Authorizer
+	apiKeys        *APIKeyAuthenticator
+	requireVersion bool
+	audit          ports.AuditTrail
+	categories     ports.CategoryRepository
+	gateway        http.Handler
+}
+
+type HandlerOption func(*ProductHandler)
+
+// WithAuthorizer checks every request against policy of authorizer, batch
+// operations one by one
+func WithAuthorizer(authorizer *Authorizer) HandlerOption {
+	return func(h *ProductHandler) {
+		h.authorizer = authorizer
+	}
+}
+
+// WithAPIKeyPolicy makes GraphQL mutations require a key whenever writes do,
+// requests they come in are reads as far as API key middleware is concerned
+func WithAPIKeyPolicy(apiKeys *APIKeyAuthenticator) HandlerOption {
+	return func(h *ProductHandler) {
+		h.apiKeys = apiKeys
+	}
+}
+
+// authorize leaves next as it is unless authorization is enabled
+func (h *ProductHandler) authorize(next http.Handler) http.Handler {
+	if h.authorizer == nil {
+		return next
+	}
+	return h.authorizer.Middleware(next)
+}
+
+// WithVersionRequired rejects updates and patches that name no version to
+// apply to, with If-Match or in body, so that no writer overwrites changes
+// it has not seen
+func WithVersionRequired() HandlerOption {
+	return func(h *ProductHandler) {
+		h.requireVersion = true
+	}
+}
+
+// WithBasePath sets path prefix of links in responses, for deployments
+// serving API under a prefix
+func WithBasePath(basePath string) HandlerOption {
+	return func(h *ProductHandler) {
+		h.links = NewLinkBuilder(basePath)
+	}
+}
+
+// WithDeleteConfirmation makes DELETE /products a two-step operation: first
+// call only issues a token valid for ttl, second call presenting the token
+// in X-Confirmation-Token header deletes products
+func WithDeleteConfirmation(store ports.ConfirmationStore, ttl time.Duration) HandlerOption {
+	return func(h *ProductHandler) {
+		h.confirmations = store
+		h.confirmTTL = ttl
+	}
+}
+
+// WithResponseEnvelope wraps JSON responses into {"data", "meta", "errors"}
+// by default, clients can still opt out with X-Response-Envelope: false
+func WithResponseEnvelope(enabled bool) HandlerOption {
+	return func(h *ProductHandler) {
+		h.envelope = enabled
+	}
+}
+
+// WithMaxDecompressedBody limits size of gzip encoded request bodies once
+// decompressed
+func WithMaxDecompressedBody(bytes int64) HandlerOption {
+	return func(h *ProductHandler) {
+		h.maxBodyBytes = bytes
+	}
+}
+
+// WithGateway serves gateway of gRPC ProductService under /v1/
+func WithGateway(gateway http.Handler) HandlerOption {
+	return func(h *ProductHandler) {
+		h.gateway = gateway
+	}
+}
+
+// WithMaxImageBytes limits size of uploaded product images
+func WithMaxImageBytes(bytes int64) HandlerOption {
+	return func(h *ProductHandler) {
+		h.maxImageBytes = bytes
+	}
+}
+
+// WithListMemo shares paged GET /products responses between identical
+// requests arriving within window of each other. Unpaged listing is
+// streamed and never shared
+func WithListMemo(window time.Duration) HandlerOption {
+	return func(h *ProductHandler) {
+		h.listMemo = newListMemo(window)
+	}
 }
 
-func NewProductHandler(svc ports.ResourseService) *ProductHandler {
-	return &ProductHandler{
-		svc: svc,
+// WithDeprecations marks routes as deprecated, see Deprecation
+func WithDeprecations(deprecations []Deprecation) HandlerOption {
+	return func(h *ProductHandler) {
+		h.deprecations = deprecations
 	}
 }
 
+// WithJSONCodec replaces encoding/json in product endpoints
+func WithJSONCodec(codec ports.JSONCodec) HandlerOption {
+	return func(h *ProductHandler) {
+		h.json = newJSONCodec(codec)
+	}
+}
+
+func NewProductHandler(svc ports.ResourseService, opts ...HandlerOption) *ProductHandler {
+	h := &ProductHandler{
+		svc:           svc,
+		links:         NewLinkBuilder(""),
+		maxBodyBytes:  defaultMaxDecompressedBodyBytes,
+		maxImageBytes: defaultMaxImageBytes,
+		json:          stdCodec,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
 func (h *ProductHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	offset := r.URL.Query().Get("offset")
 	limit := r.URL.Query().Get("limit")
+	filter, err := productFilter(r)
+	if err != nil {
+		r.Context().Value("errorContainer").(*domain.ErrorContainer).Add(fmt.Errorf("handler error: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid filter")
+		return
+	}
+	sort, err := productSort(r)
+	if err != nil {
+		r.Context().Value("errorContainer").(*domain.ErrorContainer).Add(fmt.Errorf("handler error: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid sort")
+		return
+	}
+
+	// after selects keyset pagination, it stays stable while products are
+	// inserted or deleted between pages
+	if r.URL.Query().Has("after") {
+		h.getProductsAfter(w, r, filter, sort)
+		return
+	}
 
 	// if both offset and limit are provided, pagination is used
 	if offset != "" && limit != "" {
-		offsetInt, err := parseAndValidate(offset, 1, "offset", r.Context().Value("errorContainer").(*domain.ErrorContainer), w)
+		offsetInt, err := parseAndValidate(offset, 0, "offset", r.Context().Value("errorContainer").(*domain.ErrorContainer), w)
 		if err != nil {
 			return
 		}
@@ -39,54 +201,382 @@ func (h *ProductHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		products, serviceErr := h.svc.GetProductsPaged(r.Context(), offsetInt, limitInt)
+		if h.listMemo != nil {
+			h.getProductsMemoized(w, r, filter, sort, limitInt, offsetInt)
+			return
+		}
+		products, serviceErr := h.svc.GetProductsPaged(r.Context(), filter, sort, limitInt, offsetInt)
 		if serviceErr != nil {
 			storeServiceErrToCtx(r.Context(), serviceErr)
 			if serviceErr.CriticalError != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
+				writeListError(w, r, serviceErr.CriticalError)
 				return
 			}
 
 		}
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(products)
+		if !h.describePage(w, r, filter, pageMeta{Limit: limitInt, Offset: &offsetInt}) {
+			return
+		}
+		h.writeProducts(w, r, products)
 		return
 	}
 
 	// if no pagination parameters, or they are presented partially🥴, return all products
-	products, serviceErr := h.svc.GetAllProducts(r.Context())
+	h.streamProducts(w, r, filter, sort)
+}
+
+// productFilter reads list filters from query, absent ones do not filter.
+// Category is given by id, tag is normalized as tags are stored, timestamp
+// bounds are RFC3339
+func productFilter(r *http.Request) (domain.ProductFilter, error) {
+	query := r.URL.Query()
+	filter := domain.ProductFilter{
+		Name:         strings.TrimSpace(query.Get("name")),
+		InfoContains: query.Get("info_contains"),
+	}
+	if value := query.Get("category"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id < 1 {
+			return filter, fmt.Errorf("invalid category %q", value)
+		}
+		filter.CategoryId = id
+	}
+	if value := query.Get("tag"); value != "" {
+		tag, err := domain.NormalizeTag(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.Tag = tag
+	}
+	for param, bound := range map[string]*time.Time{
+		"createdAfter":  &filter.CreatedAfter,
+		"createdBefore": &filter.CreatedBefore,
+		"updatedAfter":  &filter.UpdatedAfter,
+	} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s %q: %w", param, value, err)
+		}
+		*bound = t
+	}
+	return filter, nil
+}
+
+// formatBound keys timestamp bound of filter, empty if there is none
+func formatBound(bound time.Time) string {
+	if bound.IsZero() {
+		return ""
+	}
+	return bound.UTC().Format(time.RFC3339Nano)
+}
+
+// productSort reads sort from query, comma separated fields with "-"
+// before those sorted in descending order, e.g. "name,-id". Repository
+// decides which fields can be sorted by
+func productSort(r *http.Request) (domain.ProductSort, error) {
+	return domain.ParseProductSort(r.URL.Query().Get("sort"))
+}
+
+// writeListError reports failure of product listing, sort by unknown field
+// is found out by repository
+func writeListError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, domain.ErrInvalidInput) {
+		writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid sort")
+		return
+	}
+	writeServerError(w, r, err)
+}
+
+// describePage leaves page metadata for envelope, if response is enveloped.
+// Counting total costs another query, so it is done only then. Returns false
+// if it has written error response instead
+func (h *ProductHandler) describePage(w http.ResponseWriter, r *http.Request, filter domain.ProductFilter, page pageMeta) bool {
+	holder, ok := r.Context().Value(pageKey{}).(*pageHolder)
+	if !ok {
+		return true
+	}
+	total, serviceErr := h.svc.CountProducts(r.Context(), filter)
 	if serviceErr != nil {
 		storeServiceErrToCtx(r.Context(), serviceErr)
 		if serviceErr.CriticalError != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
-			return
+			writeServerError(w, r, serviceErr.CriticalError)
+			return false
 		}
 	}
+	page.Total = total
+	if page.Offset != nil {
+		page.HasMore = *page.Offset+page.Limit < total
+	}
+	holder.page = &page
+	return true
+}
 
+func (h *ProductHandler) getProductsMemoized(w http.ResponseWriter, r *http.Request, filter domain.ProductFilter, sort domain.ProductSort, limit int64, offset int64) {
+	key := strconv.FormatInt(limit, 10) + ":" + strconv.FormatInt(offset, 10)
+	if !filter.IsEmpty() {
+		key += ":" + strconv.Quote(filter.Name) + ":" + strconv.Quote(filter.InfoContains) + ":" + strconv.FormatInt(filter.CategoryId, 10) + ":" + filter.Tag +
+			":" + formatBound(filter.CreatedAfter) + ":" + formatBound(filter.CreatedBefore) + ":" + formatBound(filter.UpdatedAfter)
+	}
+	if len(sort) > 0 {
+		key += ":" + sort.String()
+	}
+	protobuf := wantsProtobuf(r)
+	if protobuf {
+		key += ":protobuf"
+	}
+	body, serviceErr := h.listMemo.do(r.Context(), key, func(ctx context.Context) ([]byte, *domain.ServiceError) {
+		products, serviceErr := h.svc.GetProductsPaged(ctx, filter, sort, limit, offset)
+		if serviceErr != nil && serviceErr.CriticalError != nil {
+			return nil, serviceErr
+		}
+		if protobuf {
+			body, err := proto.Marshal(productListMessage(products))
+			if err != nil {
+				return nil, domain.NewServiceError(err, nil)
+			}
+			return body, serviceErr
+		}
+		b := h.json.getBuffer()
+		defer h.json.putBuffer(b)
+		if err := b.enc.Encode(h.links.productResources(products)); err != nil {
+			return nil, domain.NewServiceError(err, nil)
+		}
+		return bytes.Clone(b.buf.Bytes()), serviceErr
+	})
+	if serviceErr != nil {
+		storeServiceErrToCtx(r.Context(), serviceErr)
+		if serviceErr.CriticalError != nil {
+			writeListError(w, r, serviceErr.CriticalError)
+			return
+		}
+	}
+	if !h.describePage(w, r, filter, pageMeta{Limit: limit, Offset: &offset}) {
+		return
+	}
+	if protobuf {
+		w.Header().Set("Content-Type", protobufMediaTypes[0])
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(products)
+	w.Write(body)
+}
+
+// streamFlushBytes is how much of streamed response is buffered before it
+// is sent to client
+const streamFlushBytes = 32 << 10
+
+// streamProducts writes the whole catalog as JSON array, or as protobuf
+// product list, while reading it, so memory use does not grow with catalog
+// size. Output is sent every streamFlushBytes, failure after that can not
+// change response status anymore, array is left unterminated then
+func (h *ProductHandler) streamProducts(w http.ResponseWriter, r *http.Request, filter domain.ProductFilter, sort domain.ProductSort) {
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	b := h.json.getBuffer()
+	defer h.json.putBuffer(b)
+	protobuf := wantsProtobuf(r)
+	if protobuf {
+		w.Header().Set("Content-Type", protobufMediaTypes[0])
+	}
+
+	started := false
+	send := func() error {
+		if !started {
+			started = true
+			w.WriteHeader(http.StatusOK)
+		}
+		_, err := w.Write(b.buf.Bytes())
+		b.buf.Reset()
+		if err != nil {
+			return err
+		}
+		// buffering middleware can not flush, response is sent once complete
+		if err := http.NewResponseController(w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+
+	var message []byte
+	if !protobuf {
+		b.buf.WriteByte('[')
+	}
+	first := true
+	serviceErr := h.svc.EachProduct(r.Context(), filter, sort, func(product domain.Product) error {
+		if protobuf {
+			var err error
+			if message, err = appendProductListItem(message[:0], product); err != nil {
+				return fmt.Errorf("handler error: failed to encode product %d: %w", product.Id, err)
+			}
+			b.buf.Write(message)
+			if b.buf.Len() >= streamFlushBytes {
+				return send()
+			}
+			return nil
+		}
+		if !first {
+			b.buf.WriteByte(',')
+		}
+		first = false
+		if err := b.enc.Encode(h.links.productResource(product)); err != nil {
+			return fmt.Errorf("handler error: failed to encode product %d: %w", product.Id, err)
+		}
+		// newline Encode appends
+		b.buf.Truncate(b.buf.Len() - 1)
+		if b.buf.Len() >= streamFlushBytes {
+			return send()
+		}
+		return nil
+	})
+	if serviceErr != nil {
+		storeServiceErrToCtx(r.Context(), serviceErr)
+		if serviceErr.CriticalError != nil {
+			if !started {
+				writeListError(w, r, serviceErr.CriticalError)
+				return
+			}
+			errContainer.Add(errors.New("handler error: product listing cut short"))
+			return
+		}
+	}
+	if !protobuf {
+		b.buf.WriteString("]\n")
+	}
+	if !started {
+		w.Header().Set("Content-Length", strconv.Itoa(b.buf.Len()))
+	}
+	if err := send(); err != nil {
+		errContainer.Add(fmt.Errorf("handler error: failed to send products: %w", err))
+	}
+}
+
+func (h *ProductHandler) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	query := domain.SearchQuery{
+		Text:   strings.TrimSpace(r.URL.Query().Get("q")),
+		Offset: 0,
+		Limit:  20,
+		Facets: r.URL.Query()["facet"],
+	}
+	if query.Text == "" {
+		errContainer.Add(errors.New("handler error: search query is empty"))
+		writeError(w, http.StatusBadRequest, CodeInvalidQuery, "Invalid search query")
+		return
+	}
+	if fuzzy := r.URL.Query().Get("fuzzy"); fuzzy != "" {
+		fuzzyBool, err := strconv.ParseBool(fuzzy)
+		if err != nil {
+			errContainer.Add(fmt.Errorf("handler error: failed to parse fuzzy: %w", err))
+			writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid fuzzy")
+			return
+		}
+		query.Fuzzy = fuzzyBool
+	}
+	if offset := r.URL.Query().Get("offset"); offset != "" {
+		offsetInt, err := parseAndValidate(offset, 0, "offset", errContainer, w)
+		if err != nil {
+			return
+		}
+		query.Offset = offsetInt
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		limitInt, err := parseAndValidate(limit, 1, "limit", errContainer, w)
+		if err != nil {
+			return
+		}
+		query.Limit = limitInt
+	}
+
+	result, serviceErr := h.svc.SearchProducts(r.Context(), query)
+	if serviceErr != nil {
+		storeServiceErrToCtx(r.Context(), serviceErr)
+		if serviceErr.CriticalError != nil {
+			switch {
+			case errors.Is(serviceErr.CriticalError, domain.ErrInvalidInput) && query.Fuzzy:
+				writeError(w, http.StatusBadRequest, CodeInvalidQuery, "Facets are not supported by fuzzy search")
+			case errors.Is(serviceErr.CriticalError, domain.ErrInvalidInput):
+				writeError(w, http.StatusBadRequest, CodeInvalidQuery, "Invalid facet")
+			case errors.Is(serviceErr.CriticalError, domain.ErrSearchDisabled):
+				writeError(w, http.StatusNotImplemented, CodeSearchUnavailable, "Faceted search is not available")
+			default:
+				writeServerError(w, r, serviceErr.CriticalError)
+			}
+			return
+		}
+	}
+	h.json.write(w, http.StatusOK, result)
+}
+
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 50
+	// product names are at most 50 characters, longer prefixes never match
+	maxSuggestPrefixLength = 50
+	// suggestions are fine to be a minute stale, let browsers and CDNs keep them
+	suggestMaxAgeSeconds = 60
+)
+
+type suggestions struct {
+	Suggestions []string `json:"suggestions"`
+}
+
+// SuggestProducts serves search-box typeahead: names of products starting
+// with q, in alphabetical order
+func (h *ProductHandler) SuggestProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	prefix := strings.TrimSpace(r.URL.Query().Get("q"))
+	if prefix == "" || utf8.RuneCountInString(prefix) > maxSuggestPrefixLength {
+		errContainer.Add(fmt.Errorf("handler error: invalid suggestion prefix %q", prefix))
+		writeError(w, http.StatusBadRequest, CodeInvalidQuery, "Invalid suggestion prefix")
+		return
+	}
+	limit := int64(defaultSuggestLimit)
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limitInt, err := parseAndValidate(value, 1, "limit", errContainer, w)
+		if err != nil {
+			return
+		}
+		limit = min(limitInt, maxSuggestLimit)
+	}
+
+	names, serviceErr := h.svc.SuggestProductNames(r.Context(), prefix, limit)
+	if serviceErr != nil {
+		storeServiceErrToCtx(r.Context(), serviceErr)
+		if serviceErr.CriticalError != nil {
+			writeServerError(w, r, serviceErr.CriticalError)
+			return
+		}
+	}
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(suggestMaxAgeSeconds))
+	h.json.write(w, http.StatusOK, suggestions{Suggestions: names})
 }
 
 func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var req domain.NewProduct
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
-	decodeErr := decoder.Decode(&req)
+	decodeErr := h.json.decode(r.Body, &req)
 	var err error
 	switch {
 	case decodeErr != nil:
 		err = fmt.Errorf("failed to decode payload: %w", decodeErr)
 	case req.Name == "" || req.AdditionalInfo == "":
 		err = errors.New("failed to decode payload: product name or additional info is empty")
+	case req.CategoryId < 0:
+		err = fmt.Errorf("failed to decode payload: invalid category id %d", req.CategoryId)
+	case req.Sku != "":
+		if skuErr := domain.ValidateSku(req.Sku); skuErr != nil {
+			err = fmt.Errorf("failed to decode payload: %w", skuErr)
+		}
 	}
 	if err != nil {
 		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
 		errContainer.Add(err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
 		return
 	}
 
@@ -94,18 +584,25 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	if serviceErr != nil {
 		storeServiceErrToCtx(r.Context(), serviceErr)
 		if serviceErr.CriticalError != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
+			switch {
+			case errors.Is(serviceErr.CriticalError, domain.ErrUnknownCategory):
+				writeError(w, http.StatusBadRequest, CodeUnknownCategory, "Category does not exist")
+			case errors.Is(serviceErr.CriticalError, domain.ErrDuplicateSku):
+				writeError(w, http.StatusConflict, CodeDuplicateSku, "SKU is already used by another product")
+			default:
+				writeServerError(w, r, serviceErr.CriticalError)
+			}
 			return
 		}
 	}
 	productId := struct {
-		ID int64 `json:"id"`
+		ID    int64        `json:"id"`
+		Links productLinks `json:"_links"`
 	}{
-		ID: res,
+		ID:    res,
+		Links: h.links.productLinks(res),
 	}
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(productId)
+	h.json.write(w, http.StatusCreated, productId)
 }
 
 func (h *ProductHandler) GetProductById(w http.ResponseWriter, r *http.Request) {
@@ -115,26 +612,70 @@ func (h *ProductHandler) GetProductById(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return
 	}
-	product, serviceErr := h.svc.GetProductById(r.Context(), id)
+	product, serviceErr := h.svc.GetProductById(readContext(r), id)
+	h.writeRead(w, r, product, serviceErr)
+}
+
+// readContext is request context, marked for fresh read if client asks for it
+func readContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if wantsFreshRead(r) {
+		ctx = domain.WithFreshRead(ctx)
+	}
+	return ctx
+}
+
+// writeRead responds with product read as JSON, or with error of the read
+func (h *ProductHandler) writeRead(w http.ResponseWriter, r *http.Request, product []byte, serviceErr *domain.ServiceError) {
 	if serviceErr != nil {
 		storeServiceErrToCtx(r.Context(), serviceErr)
+		if errors.Is(errors.Join(serviceErr.NonCriticalErrors...), domain.ErrStaleRead) {
+			w.Header().Set(staleHeader, "true")
+			w.Header().Set("Warning", `110 - "Response is Stale"`)
+		}
 		if serviceErr.CriticalError != nil {
 			if errors.Is(serviceErr.CriticalError, domain.ErrNotFound) {
-				w.WriteHeader(http.StatusNotFound)
-				json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+				writeError(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
 				return
 			}
 
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
+			writeServerError(w, r, serviceErr.CriticalError)
 			return
 		}
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(product)
+	var resource productResource
+	if err := json.Unmarshal(product, &resource.Product); err != nil {
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(fmt.Errorf("handler error: failed to decode product: %w", err))
+		writeServerError(w, r, err)
+		return
+	}
+	etag := productETag(resource.Product)
+	if notModified(w, r, etag) {
+		return
+	}
+	w.Header().Set("ETag", etag)
+	h.writeProduct(w, r, http.StatusOK, resource.Product)
 }
 
+// wantsFreshRead lets clients that just wrote a product read it from
+// database with Cache-Control: no-cache, whichever instance serves them
+func wantsFreshRead(r *http.Request) bool {
+	for _, value := range r.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// staleHeader marks products served from cache while database is down,
+// along with Warning header for generic HTTP tooling
+const staleHeader = "X-Stale"
+
 func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	idStr := strings.TrimPrefix(r.URL.Path, "/product/")
@@ -144,38 +685,191 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var req domain.NewProduct
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
-	decodeErr := decoder.Decode(&req)
+	decodeErr := h.json.decode(r.Body, &req)
 	switch {
 	case decodeErr != nil:
 		err = fmt.Errorf("failed to decode payload: %w", decodeErr)
 	case req.Name == "" || req.AdditionalInfo == "":
 		err = errors.New("failed to decode payload: product name or additional info is empty")
+	case req.CategoryId < 0:
+		err = fmt.Errorf("failed to decode payload: invalid category id %d", req.CategoryId)
+	case req.Sku != "":
+		if skuErr := domain.ValidateSku(req.Sku); skuErr != nil {
+			err = fmt.Errorf("failed to decode payload: %w", skuErr)
+		}
 	}
 	if err != nil {
 		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
 		errContainer.Add(err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	etag, ok := h.versionGiven(w, r, &req.Version)
+	if !ok {
+		return
+	}
+	if etag != "" && !h.stateMatches(w, r, id, etag) {
 		return
 	}
 	product, serviceErr := h.svc.UpdateProductById(r.Context(), id, req)
+	if serviceErr != nil {
+		storeServiceErrToCtx(r.Context(), serviceErr)
+		if serviceErr.CriticalError != nil {
+			switch {
+			case errors.Is(serviceErr.CriticalError, domain.ErrNotFound):
+				writeError(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+			case errors.Is(serviceErr.CriticalError, domain.ErrVersionConflict):
+				writeError(w, http.StatusConflict, CodeConflict, "Product was changed since the version given")
+			case errors.Is(serviceErr.CriticalError, domain.ErrVersionRequired):
+				writeError(w, http.StatusPreconditionRequired, CodePreconditionRequired, "If-Match header or version is required")
+			case errors.Is(serviceErr.CriticalError, domain.ErrUnknownCategory):
+				writeError(w, http.StatusBadRequest, CodeUnknownCategory, "Category does not exist")
+			case errors.Is(serviceErr.CriticalError, domain.ErrDuplicateSku):
+				writeError(w, http.StatusConflict, CodeDuplicateSku, "SKU is already used by another product")
+			default:
+				writeServerError(w, r, serviceErr.CriticalError)
+			}
+			return
+		}
+	}
+	// response carries previous state, tag is of the one just written
+	written := domain.Product{Id: id, Name: req.Name, AdditionalInfo: req.AdditionalInfo, Version: product.Version + 1, CategoryId: req.CategoryId, Sku: req.Sku, Stock: product.Stock, Tags: product.Tags, Images: product.Images}
+	w.Header().Set("ETag", productETag(written))
+	h.writeProduct(w, r, http.StatusOK, *product)
+}
+
+// versionGiven resolves version write expects product at into version and
+// returns tag expected in If-Match, responding with an error if it can not
+func (h *ProductHandler) versionGiven(w http.ResponseWriter, r *http.Request, version *int64) (string, bool) {
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	etag, expected, err := expectedState(r, *version)
+	if err != nil {
+		errContainer.Add(fmt.Errorf("handler error: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid version precondition")
+		return "", false
+	}
+	if expected == 0 && h.requireVersion {
+		errContainer.Add(errors.New("handler error: no version precondition"))
+		writeError(w, http.StatusPreconditionRequired, CodePreconditionRequired, "If-Match header or version is required")
+		return "", false
+	}
+	*version = expected
+	return etag, true
+}
+
+// stateMatches tells whether product is in the state etag identifies,
+// responding with an error if it is not. Version alone does not tell states
+// of a product deleted and created again apart, the write checks it anyway
+func (h *ProductHandler) stateMatches(w http.ResponseWriter, r *http.Request, id int64, etag string) bool {
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	data, serviceErr := h.svc.GetProductById(domain.WithFreshRead(r.Context()), id)
 	if serviceErr != nil {
 		storeServiceErrToCtx(r.Context(), serviceErr)
 		if serviceErr.CriticalError != nil {
 			if errors.Is(serviceErr.CriticalError, domain.ErrNotFound) {
-				w.WriteHeader(http.StatusNotFound)
-				json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
-				return
+				writeError(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+				return false
+			}
+			writeServerError(w, r, serviceErr.CriticalError)
+			return false
+		}
+	}
+	var current domain.Product
+	if err := json.Unmarshal(data, &current); err != nil {
+		errContainer.Add(fmt.Errorf("handler error: failed to decode product: %w", err))
+		writeServerError(w, r, err)
+		return false
+	}
+	if err := checkETag(current, etag); err != nil {
+		errContainer.Add(fmt.Errorf("handler error: %w", err))
+		writeError(w, http.StatusConflict, CodeConflict, "Product was changed since the version given")
+		return false
+	}
+	return true
+}
+
+const (
+	contentTypeJSONPatch  = "application/json-patch+json"
+	contentTypeMergePatch = "application/merge-patch+json"
+)
+
+// PatchProduct accepts either JSON Patch (RFC 6902) or JSON Merge Patch
+// (RFC 7396) depending on Content-Type, responds with the patched product
+func (h *ProductHandler) PatchProduct(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	idStr := strings.TrimPrefix(r.URL.Path, "/product/")
+
+	id, err := parseAndValidate(idStr, 0, "product id", r.Context().Value("errorContainer").(*domain.ErrorContainer), w)
+	if err != nil {
+		return
+	}
+
+	var patch domain.Patcher
+	var version int64
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case contentTypeJSONPatch:
+		var operations []domain.PatchOperation
+		if err = h.json.decode(r.Body, &operations); err == nil {
+			patch = domain.JSONPatch(operations)
+		}
+	case contentTypeMergePatch:
+		var document map[string]json.RawMessage
+		if err = h.json.decode(r.Body, &document); err == nil {
+			patch = domain.MergePatch(document)
+			// version member is a precondition, malformed ones are
+			// reported by the patch
+			if member, ok := document["version"]; ok {
+				json.Unmarshal(member, &version)
+			}
+		}
+	default:
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(fmt.Errorf("handler error: unsupported patch media type %q", mediaType))
+		w.Header().Set("Accept-Patch", contentTypeJSONPatch+", "+contentTypeMergePatch)
+		writeError(w, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "Unsupported media type")
+		return
+	}
+	if err != nil {
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(fmt.Errorf("failed to decode payload: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	etag, ok := h.versionGiven(w, r, &version)
+	if !ok {
+		return
+	}
+	if etag != "" {
+		patch = expectETag(etag, patch)
+	}
+	if version > 0 {
+		patch = domain.ExpectVersion(version, patch)
+	}
+
+	product, serviceErr := h.svc.PatchProduct(r.Context(), id, patch)
+	if serviceErr != nil {
+		storeServiceErrToCtx(r.Context(), serviceErr)
+		if serviceErr.CriticalError != nil {
+			switch {
+			case errors.Is(serviceErr.CriticalError, domain.ErrNotFound):
+				writeError(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+			case errors.Is(serviceErr.CriticalError, domain.ErrVersionConflict):
+				writeError(w, http.StatusConflict, CodeConflict, "Product was changed since the version given")
+			case errors.Is(serviceErr.CriticalError, domain.ErrUnknownCategory):
+				writeError(w, http.StatusBadRequest, CodeUnknownCategory, "Category does not exist")
+			case errors.Is(serviceErr.CriticalError, domain.ErrDuplicateSku):
+				writeError(w, http.StatusConflict, CodeDuplicateSku, "SKU is already used by another product")
+			case errors.Is(serviceErr.CriticalError, domain.ErrInvalidInput):
+				writeError(w, http.StatusBadRequest, CodeInvalidPatch, "Invalid patch")
+			default:
+				writeServerError(w, r, serviceErr.CriticalError)
 			}
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
 			return
 		}
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(product)
+	w.Header().Set("ETag", productETag(*product))
+	h.writeProduct(w, r, http.StatusOK, *product)
 }
 
 func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
@@ -191,28 +885,52 @@ func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		storeServiceErrToCtx(r.Context(), serviceErr)
 		if serviceErr.CriticalError != nil {
 			if errors.Is(serviceErr.CriticalError, domain.ErrNotFound) {
-				w.WriteHeader(http.StatusNotFound)
-				json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+				writeError(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
 				return
 			}
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
+			writeServerError(w, r, serviceErr.CriticalError)
 			return
 		}
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(deletedProduct)
+	if wantsProtobuf(r) {
+		writeProtobuf(w, r, http.StatusOK, ProductMessage(*deletedProduct))
+		return
+	}
+	h.json.write(w, http.StatusOK, deletedProduct)
 
 }
 
+const (
+	deleteAllAction         = "delete_all_products"
+	confirmationTokenHeader = "X-Confirmation-Token"
+)
+
 func (h *ProductHandler) DeleteAll(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	if h.confirmations != nil {
+		token := r.Header.Get(confirmationTokenHeader)
+		if token == "" {
+			h.issueDeleteAllToken(w, r)
+			return
+		}
+		confirmed, err := h.confirmations.Consume(r.Context(), deleteAllAction, token)
+		if err != nil || !confirmed {
+			errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+			if err != nil {
+				errContainer.Add(fmt.Errorf("handler error: failed to check confirmation token: %w", err))
+				writeServerError(w, r, err)
+				return
+			}
+			errContainer.Add(errors.New("handler error: invalid or expired confirmation token"))
+			writeError(w, http.StatusForbidden, CodeInvalidConfirmationToken, "Invalid or expired confirmation token")
+			return
+		}
+	}
 	deletedRows, serviceErr := h.svc.DeleteAllProducts(r.Context())
 	if serviceErr != nil {
 		storeServiceErrToCtx(r.Context(), serviceErr)
 		if serviceErr.CriticalError != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
+			writeServerError(w, r, serviceErr.CriticalError)
 			return
 		}
 	}
@@ -221,9 +939,26 @@ func (h *ProductHandler) DeleteAll(w http.ResponseWriter, r *http.Request) {
 	}{
 		DeletedRows: deletedRows,
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(deletedCount)
+	h.json.write(w, http.StatusOK, deletedCount)
+
+}
 
+func (h *ProductHandler) issueDeleteAllToken(w http.ResponseWriter, r *http.Request) {
+	token, err := h.confirmations.Issue(r.Context(), deleteAllAction, h.confirmTTL)
+	if err != nil {
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(fmt.Errorf("handler error: failed to issue confirmation token: %w", err))
+		writeServerError(w, r, err)
+		return
+	}
+	confirmation := struct {
+		ConfirmationToken string    `json:"confirmationToken"`
+		ExpiresAt         time.Time `json:"expiresAt"`
+	}{
+		ConfirmationToken: token,
+		ExpiresAt:         time.Now().Add(h.confirmTTL).UTC(),
+	}
+	h.json.write(w, http.StatusAccepted, confirmation)
 }
 
 func parseAndValidate(s string, lb int64, name string, c *domain.ErrorContainer, w http.ResponseWriter) (int64, error) {
@@ -237,8 +972,11 @@ func parseAndValidate(s string, lb int64, name string, c *domain.ErrorContainer,
 	}
 	if err != nil {
 		c.Add(err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("Invalid %s", name)})
+		code := CodeInvalidParameter
+		if name == "product id" {
+			code = CodeInvalidId
+		}
+		writeError(w, http.StatusBadRequest, code, fmt.Sprintf("Invalid %s", name))
 		return 0, errors.New(fmt.Sprintf("failed to get valid value while parsing"))
 	}
 	return value, nil
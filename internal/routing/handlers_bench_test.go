@@ -0,0 +1,102 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/pelyams/simpler_go_service/internal/adapters/codec"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func BenchmarkGetProducts(b *testing.B) {
+	products := testhelpers.GenerateProducts(100)
+	for i := range products {
+		products[i].Id = int64(i + 1)
+	}
+	logger, err := NewLogger(filepath.Join(b.TempDir(), "bench.log"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { logger.Close() })
+	svc := service.NewResourceService(fakes.NewRepository(products...), fakes.NewCache())
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc)).SetupRoutes())
+
+	for _, accept := range []string{"application/json", "application/x-protobuf"} {
+		for _, path := range []string{"/product/1", "/products?limit=20&offset=0", "/products"} {
+			b.Run(accept+path, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					rec := httptest.NewRecorder()
+					req := httptest.NewRequest(http.MethodGet, path, nil)
+					req.Header.Set("Accept", accept)
+					router.ServeHTTP(rec, req)
+					if rec.Code != http.StatusOK {
+						b.Fatalf("unexpected status %d", rec.Code)
+					}
+				}
+			})
+		}
+	}
+}
+
+// discardResponse is a ResponseWriter cheap enough not to blur encoding costs
+type discardResponse struct {
+	header http.Header
+}
+
+func (d discardResponse) Header() http.Header         { return d.header }
+func (d discardResponse) WriteHeader(int)             {}
+func (d discardResponse) Write(p []byte) (int, error) { return len(p), nil }
+
+func BenchmarkWriteJSON(b *testing.B) {
+	products := testhelpers.GenerateProducts(20)
+	resources := NewLinkBuilder("").productResources(products)
+	w := discardResponse{header: make(http.Header)}
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			writeJSON(w, http.StatusOK, resources)
+		}
+	})
+	b.Run("buffer per response", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var buf bytes.Buffer
+			json.NewEncoder(&buf).Encode(resources)
+			w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+			w.WriteHeader(http.StatusOK)
+			w.Write(buf.Bytes())
+		}
+	})
+}
+
+func BenchmarkJSONCodec(b *testing.B) {
+	products := testhelpers.GenerateProducts(100)
+	resources := NewLinkBuilder("").productResources(products)
+	w := discardResponse{header: make(http.Header)}
+
+	for _, bc := range []struct {
+		name  string
+		codec ports.JSONCodec
+	}{
+		{name: "std", codec: stdJSON{}},
+		{name: "jsoniter", codec: codec.NewJsoniter()},
+	} {
+		c := newJSONCodec(bc.codec)
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c.write(w, http.StatusOK, resources)
+			}
+		})
+	}
+}
@@ -0,0 +1,741 @@
+package routing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/adapters/codec"
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+// TestProductAPIContract pins response shapes, run with UPDATE_GOLDEN=1 after
+// intended API changes to record new golden files
+func TestProductAPIContract(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		contentType string
+		accept      string
+		headers     map[string]string
+		setup       func(repo *fakes.Repository, cache *fakes.Cache)
+	}{
+		{name: "get_product", method: http.MethodGet, path: "/product/1"},
+		{name: "get_product_not_found", method: http.MethodGet, path: "/product/42"},
+		{
+			name:    "get_product_no_cache",
+			method:  http.MethodGet,
+			path:    "/product/1",
+			headers: map[string]string{"Cache-Control": "max-age=0, no-cache"},
+			setup: func(repo *fakes.Repository, cache *fakes.Cache) {
+				cache.SetProduct(context.Background(), &domain.Product{Id: 1, Name: "Stale", AdditionalInfo: "Stale info"})
+			},
+		},
+		{name: "get_product_invalid_id", method: http.MethodGet, path: "/product/abc"},
+		{name: "get_products", method: http.MethodGet, path: "/products"},
+		{name: "get_products_paged", method: http.MethodGet, path: "/products?limit=1&offset=1"},
+		{name: "get_products_after", method: http.MethodGet, path: "/products?after=&limit=1&sort=-name"},
+		{name: "search_products", method: http.MethodGet, path: "/products/search?q=second"},
+		{name: "search_products_facets_unavailable", method: http.MethodGet, path: "/products/search?q=second&facet=name"},
+		{name: "search_products_fuzzy", method: http.MethodGet, path: "/products/search?q=secnd&fuzzy=true"},
+		{name: "search_products_fuzzy_facets", method: http.MethodGet, path: "/products/search?q=secnd&fuzzy=true&facet=name"},
+		{name: "search_products_fuzzy_invalid", method: http.MethodGet, path: "/products/search?q=secnd&fuzzy=maybe"},
+		{name: "suggest_products", method: http.MethodGet, path: "/products/suggest?q=f"},
+		{name: "suggest_products_invalid", method: http.MethodGet, path: "/products/suggest?q=%20"},
+		{name: "wait_changes_not_configured", method: http.MethodGet, path: "/products/changes/wait"},
+		{name: "export_products_invalid_format", method: http.MethodGet, path: "/products/export?format=csv"},
+		{
+			name:   "export_products_db_unavailable",
+			method: http.MethodGet,
+			path:   "/products/export?format=xlsx",
+			setup: func(repo *fakes.Repository, cache *fakes.Cache) {
+				repo.FailWith("ProductIdRange", fmt.Errorf("%w: %w", domain.ErrInternalDb, domain.ErrUnavailable))
+			},
+		},
+		{
+			name:   "export_products_ndjson_db_unavailable",
+			method: http.MethodGet,
+			path:   "/products/export?format=ndjson",
+			setup: func(repo *fakes.Repository, cache *fakes.Cache) {
+				repo.FailWith("EachProduct", fmt.Errorf("%w: %w", domain.ErrInternalDb, domain.ErrUnavailable))
+			},
+		},
+		{name: "create_product", method: http.MethodPost, path: "/product", body: `{"name":"New","additionalInfo":"Created"}`},
+		{name: "create_product_invalid", method: http.MethodPost, path: "/product", body: `{"name":""}`},
+		{name: "create_product_gzip", method: http.MethodPost, path: "/product", body: mustGzip(`{"name":"New","additionalInfo":"Created"}`), headers: map[string]string{"Content-Encoding": "gzip"}},
+		{name: "create_product_gzip_invalid", method: http.MethodPost, path: "/product", body: `{"name":"New"}`, headers: map[string]string{"Content-Encoding": "gzip"}},
+		{name: "create_product_gzip_too_large", method: http.MethodPost, path: "/product", body: mustGzip(strings.Repeat(" ", defaultMaxDecompressedBodyBytes+1)), headers: map[string]string{"Content-Encoding": "gzip"}},
+		{name: "create_product_unsupported_encoding", method: http.MethodPost, path: "/product", body: `{"name":"New","additionalInfo":"Created"}`, headers: map[string]string{"Content-Encoding": "br"}},
+		{name: "update_product", method: http.MethodPut, path: "/product/2", body: `{"name":"Renamed","additionalInfo":"Updated"}`},
+		{
+			name:        "patch_product_json_patch",
+			method:      http.MethodPatch,
+			path:        "/product/1",
+			body:        `[{"op":"replace","path":"/name","value":"Patched"}]`,
+			contentType: "application/json-patch+json",
+		},
+		{
+			name:        "patch_product_merge_patch",
+			method:      http.MethodPatch,
+			path:        "/product/1",
+			body:        `{"additionalInfo":"Merged"}`,
+			contentType: "application/merge-patch+json",
+		},
+		{
+			name:        "patch_product_invalid",
+			method:      http.MethodPatch,
+			path:        "/product/1",
+			body:        `[{"op":"remove","path":"/name"}]`,
+			contentType: "application/json-patch+json",
+		},
+		{
+			name:        "patch_product_not_found",
+			method:      http.MethodPatch,
+			path:        "/product/42",
+			body:        `[]`,
+			contentType: "application/json-patch+json",
+		},
+		{
+			name:        "patch_product_unsupported_media_type",
+			method:      http.MethodPatch,
+			path:        "/product/1",
+			body:        `{"name":"Patched"}`,
+			contentType: "application/json",
+		},
+		{name: "delete_product", method: http.MethodDelete, path: "/product/2"},
+		{name: "delete_all", method: http.MethodDelete, path: "/products", headers: map[string]string{confirmationTokenHeader: "token-1"}},
+		{name: "delete_all_request_confirmation", method: http.MethodDelete, path: "/products"},
+		{name: "delete_all_invalid_token", method: http.MethodDelete, path: "/products", headers: map[string]string{confirmationTokenHeader: "token-2"}},
+		{name: "jsonapi_get_product", method: http.MethodGet, path: "/product/1", accept: "application/vnd.api+json"},
+		{name: "jsonapi_get_product_not_found", method: http.MethodGet, path: "/product/42", accept: "application/vnd.api+json"},
+		{name: "jsonapi_get_products_paged", method: http.MethodGet, path: "/products?limit=1&offset=1", accept: "application/vnd.api+json"},
+		{name: "jsonapi_get_products_after", method: http.MethodGet, path: "/products?after=1&limit=1", accept: "application/vnd.api+json"},
+		{
+			name:   "jsonapi_create_product",
+			method: http.MethodPost,
+			path:   "/product",
+			body:   `{"name":"New","additionalInfo":"Created"}`,
+			accept: "application/vnd.api+json",
+		},
+		{name: "jsonapi_delete_all", method: http.MethodDelete, path: "/products", accept: "application/vnd.api+json", headers: map[string]string{confirmationTokenHeader: "token-1"}},
+		{name: "xml_get_product", method: http.MethodGet, path: "/product/1", accept: "application/xml"},
+		{name: "xml_get_products", method: http.MethodGet, path: "/products", accept: "text/xml, application/json;q=0.5"},
+		{name: "xml_get_product_not_found", method: http.MethodGet, path: "/product/42", accept: "application/xml"},
+		{name: "jsonapi_media_type_params", method: http.MethodGet, path: "/product/1", accept: `application/vnd.api+json; ext="bulk"`},
+		{name: "envelope_get_product", method: http.MethodGet, path: "/product/1", headers: map[string]string{envelopeHeader: "true"}},
+		{name: "envelope_get_products", method: http.MethodGet, path: "/products", headers: map[string]string{envelopeHeader: "true"}},
+		{name: "envelope_get_products_paged", method: http.MethodGet, path: "/products?limit=1&offset=0", headers: map[string]string{envelopeHeader: "true"}},
+		{name: "envelope_get_products_after", method: http.MethodGet, path: "/products?after=&limit=1", headers: map[string]string{envelopeHeader: "true"}},
+		{name: "envelope_get_product_not_found", method: http.MethodGet, path: "/product/42", headers: map[string]string{envelopeHeader: "true"}},
+		{
+			name:   "batch",
+			method: http.MethodPost,
+			path:   "/batch",
+			body: `[
+				{"method":"POST","path":"/product","body":{"name":"New","additionalInfo":"Created"}},
+				{"method":"GET","path":"/product/42"},
+				{"method":"PATCH","path":"/product/1","headers":{"Content-Type":"application/merge-patch+json"},"body":{"name":"Patched"}},
+				{"method":"DELETE","path":"/product/2"}
+			]`,
+		},
+		{
+			name:   "batch_stop_on_error",
+			method: http.MethodPost,
+			path:   "/batch?stopOnError=true",
+			body:   `[{"method":"GET","path":"/product/42"},{"method":"DELETE","path":"/products","headers":{"X-Confirmation-Token":"token-1"}}]`,
+		},
+		{name: "batch_nested", method: http.MethodPost, path: "/batch", body: `[{"method":"POST","path":"/batch"}]`},
+		{
+			name:   "get_products_db_failure",
+			method: http.MethodGet,
+			path:   "/products",
+			setup: func(repo *fakes.Repository, cache *fakes.Cache) {
+				repo.FailWith("EachProduct", domain.ErrInternalDb)
+			},
+		},
+		{
+			name:   "get_products_db_unavailable",
+			method: http.MethodGet,
+			path:   "/products",
+			setup: func(repo *fakes.Repository, cache *fakes.Cache) {
+				repo.FailWith("EachProduct", fmt.Errorf("%w: %w", domain.ErrInternalDb, domain.ErrUnavailable))
+			},
+		},
+	}
+
+	// codecs must not change responses, all of them share golden files
+	codecs := []struct {
+		name  string
+		codec ports.JSONCodec
+	}{
+		{name: "std", codec: stdJSON{}},
+		{name: "jsoniter", codec: codec.NewJsoniter()},
+	}
+	for _, jsonCodec := range codecs {
+		for _, tt := range tests {
+			t.Run(jsonCodec.name+"/"+tt.name, func(t *testing.T) {
+				repo := fakes.NewRepository(
+					domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"},
+					domain.Product{Id: 2, Name: "Second", AdditionalInfo: "Second info"},
+				)
+				cache := fakes.NewCache()
+				if tt.setup != nil {
+					tt.setup(repo, cache)
+				}
+				logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+				require.NoError(t, err)
+				defer logger.Close()
+				// every test starts with a single issued token, "token-1"
+				confirmations := fakes.NewConfirmationStore()
+				_, err = confirmations.Issue(context.Background(), deleteAllAction, time.Minute)
+				require.NoError(t, err)
+				handler := NewProductHandler(service.NewResourceService(repo, cache), WithDeleteConfirmation(confirmations, time.Minute), WithJSONCodec(jsonCodec.codec))
+				router := logger.LoggerMiddleware(NewRouter(handler).SetupRoutes())
+
+				req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+				if tt.contentType != "" {
+					req.Header.Set("Content-Type", tt.contentType)
+				}
+				if tt.accept != "" {
+					req.Header.Set("Accept", tt.accept)
+				}
+				for name, value := range tt.headers {
+					req.Header.Set(name, value)
+				}
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				testhelpers.AssertGolden(t, tt.name, rec, testhelpers.WithGoldenHeaders("Accept-Patch", "Retry-After", "Cache-Control", nextCursorHeader), testhelpers.IgnoreGoldenFields("expiresAt"))
+			})
+		}
+	}
+}
+
+func mustGzip(s string) string {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		panic(err)
+	}
+	if err := zw.Close(); err != nil {
+		panic(err)
+	}
+	return buf.String()
+}
+
+type openBreaker struct{}
+
+func (openBreaker) Open() bool { return true }
+
+func TestGetProductStale(t *testing.T) {
+	repo := fakes.NewRepository()
+	repo.FailWith("GetProduct", fmt.Errorf("%w: %w", domain.ErrInternalDb, domain.ErrUnavailable))
+	cache := fakes.NewCache()
+	require.NoError(t, cache.SetProduct(context.Background(), &domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info", Version: 1}))
+	svc := service.NewResourceService(repo, cache, service.WithCircuitBreaker(openBreaker{}), service.WithDegradedReads())
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc)).SetupRoutes())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/product/1", nil))
+	testhelpers.AssertGolden(t, "get_product_stale", rec, testhelpers.WithGoldenHeaders(staleHeader, "Warning"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/product/2", nil))
+	testhelpers.AssertGolden(t, "get_product_stale_not_cached", rec, testhelpers.WithGoldenHeaders(staleHeader, "Retry-After"))
+}
+
+// cutShortService fails listing after passing the first products to caller
+type cutShortService struct {
+	ports.ResourseService
+	after int
+}
+
+func (s cutShortService) EachProduct(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, fn func(product domain.Product) error) *domain.ServiceError {
+	n := 0
+	serviceErr := s.ResourseService.EachProduct(ctx, filter, sort, func(product domain.Product) error {
+		if n == s.after {
+			return domain.ErrInternalDb
+		}
+		n++
+		return fn(product)
+	})
+	if serviceErr != nil {
+		return serviceErr
+	}
+	return domain.NewServiceError(domain.ErrInternalDb, nil)
+}
+
+func TestGetProductsStreaming(t *testing.T) {
+	products := testhelpers.GenerateProducts(2000)
+	for i := range products {
+		products[i].Id = int64(i + 1)
+		products[i].Version = 1
+	}
+	svc := service.NewResourceService(fakes.NewRepository(products...), fakes.NewCache())
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	get := func(t *testing.T, svc ports.ResourseService) (*http.Response, []byte) {
+		server := httptest.NewServer(logger.LoggerMiddleware(NewRouter(NewProductHandler(svc)).SetupRoutes()))
+		defer server.Close()
+		resp, err := http.Get(server.URL + "/products")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, body
+	}
+
+	t.Run("large catalog is sent in chunks", func(t *testing.T) {
+		resp, body := get(t, svc)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int64(-1), resp.ContentLength)
+		var listed []productResource
+		require.NoError(t, json.Unmarshal(body, &listed))
+		require.Len(t, listed, len(products))
+		for i, p := range listed {
+			assert.Equal(t, products[i], p.Product)
+		}
+	})
+
+	t.Run("small catalog is sent at once", func(t *testing.T) {
+		small := service.NewResourceService(fakes.NewRepository(products[:3]...), fakes.NewCache())
+		resp, body := get(t, small)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int64(len(body)), resp.ContentLength)
+	})
+
+	t.Run("failure before first chunk is reported", func(t *testing.T) {
+		resp, body := get(t, cutShortService{ResourseService: svc, after: 100})
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Contains(t, string(body), string(CodeInternal))
+	})
+
+	t.Run("failure after first chunk leaves array unterminated", func(t *testing.T) {
+		resp, body := get(t, cutShortService{ResourseService: svc, after: 1000})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.False(t, json.Valid(body))
+	})
+}
+
+func TestGetProductsFiltered(t *testing.T) {
+	repo := fakes.NewRepository(
+		domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Warm light"},
+		domain.Product{Id: 2, Name: "Lamp", AdditionalInfo: "Cold light"},
+		domain.Product{Id: 3, Name: "Chair", AdditionalInfo: "Goes well with warm light"},
+	)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	svc := service.NewResourceService(repo, fakes.NewCache())
+
+	for _, memo := range []bool{false, true} {
+		var opts []HandlerOption
+		if memo {
+			opts = append(opts, WithListMemo(time.Second))
+		}
+		router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, opts...)).SetupRoutes())
+		for target, expected := range map[string][]int64{
+			"/products?name=lamp":                                 {1, 2},
+			"/products?info_contains=WARM":                        {1, 3},
+			"/products?name=lamp&info_contains=warm":              {1},
+			"/products?name=lamp&offset=1&limit=5":                {2},
+			"/products?info_contains=warm&offset=0&limit=1":       {1},
+			"/products?info_contains=nothing&offset=0&limit=1":    {},
+			"/products?name=%20Chair%20&info_contains=warm+light": {3},
+		} {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			require.Equal(t, http.StatusOK, rec.Code, target)
+			var listed []productResource
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed), target)
+			ids := []int64{}
+			for _, p := range listed {
+				ids = append(ids, p.Id)
+			}
+			assert.Equal(t, expected, ids, target)
+		}
+	}
+}
+
+func TestGetProductsByTimestamps(t *testing.T) {
+	repo := fakes.NewRepository(
+		domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Info"},
+		domain.Product{Id: 2, Name: "Chair", AdditionalInfo: "Info"},
+		domain.Product{Id: 3, Name: "Sofa", AdditionalInfo: "Info"},
+	)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	repo.SetTimes(1, day(1), day(1))
+	repo.SetTimes(2, day(2), day(9))
+	repo.SetTimes(3, day(3), day(3))
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	svc := service.NewResourceService(repo, fakes.NewCache())
+
+	for _, memo := range []bool{false, true} {
+		var opts []HandlerOption
+		if memo {
+			opts = append(opts, WithListMemo(time.Second))
+		}
+		router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, opts...)).SetupRoutes())
+		for target, expected := range map[string][]int64{
+			"/products?createdAfter=2024-03-02T00:00:00Z":                                    {2, 3},
+			"/products?createdBefore=2024-03-02T00:00:00Z":                                   {1},
+			"/products?createdAfter=2024-03-02T00:00:00Z&createdBefore=2024-03-03T00:00:00Z": {2},
+			"/products?updatedAfter=2024-03-04T00:00:00Z&offset=0&limit=5":                   {2},
+			"/products?createdAfter=2024-03-02T03:00:00%2B03:00&offset=0&limit=5":            {2, 3},
+			"/products?createdAfter=2024-03-02T00:00:00Z&updatedAfter=2024-03-04T00:00:00Z":  {2},
+			"/products?name=sofa&createdBefore=2024-03-04T00:00:00Z&offset=0&limit=5":        {3},
+			"/products?createdBefore=2024-03-01T00:00:00Z&updatedAfter=2024-03-01T00:00:00Z": {},
+		} {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			require.Equal(t, http.StatusOK, rec.Code, target)
+			var listed []productResource
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed), target)
+			ids := []int64{}
+			for _, p := range listed {
+				ids = append(ids, p.Id)
+			}
+			assert.Equal(t, expected, ids, target)
+		}
+
+		for _, target := range []string{
+			"/products?createdAfter=2024-03-02",
+			"/products?createdBefore=yesterday",
+			"/products?updatedAfter=1709337600&offset=0&limit=5",
+		} {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			require.Equal(t, http.StatusBadRequest, rec.Code, target)
+			var body errorBody
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body), target)
+			assert.Equal(t, CodeInvalidParameter, body.Code, target)
+		}
+	}
+}
+
+func TestGetProductsSorted(t *testing.T) {
+	repo := fakes.NewRepository(
+		domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Info"},
+		domain.Product{Id: 2, Name: "Chair", AdditionalInfo: "Info"},
+		domain.Product{Id: 3, Name: "Chair", AdditionalInfo: "Info"},
+	)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(service.NewResourceService(repo, fakes.NewCache()))).SetupRoutes())
+
+	for target, expected := range map[string][]int64{
+		"/products?sort=name":                        {2, 3, 1},
+		"/products?sort=name,-id":                    {3, 2, 1},
+		"/products?sort=-name&offset=1&limit=5":      {2, 3},
+		"/products?sort=name&name=chair":             {2, 3},
+		"/products?sort=+-id&offset=0&limit=2&sort=": {3, 2},
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusOK, rec.Code, target)
+		var listed []productResource
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed), target)
+		ids := []int64{}
+		for _, p := range listed {
+			ids = append(ids, p.Id)
+		}
+		assert.Equal(t, expected, ids, target)
+	}
+
+	for _, target := range []string{"/products?sort=price", "/products?sort=name,", "/products?sort=-&offset=0&limit=1", "/products?sort=price&offset=0&limit=1"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+		assert.Contains(t, rec.Body.String(), string(CodeInvalidParameter), target)
+	}
+}
+
+func TestGetProductsAfter(t *testing.T) {
+	repo := fakes.NewRepository(
+		domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Info"},
+		domain.Product{Id: 2, Name: "Chair", AdditionalInfo: "Info"},
+		domain.Product{Id: 3, Name: "Chair", AdditionalInfo: "Info"},
+		domain.Product{Id: 4, Name: "Bed", AdditionalInfo: "Info"},
+		domain.Product{Id: 5, Name: "Armchair", AdditionalInfo: "Info"},
+	)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(service.NewResourceService(repo, fakes.NewCache()))).SetupRoutes())
+
+	list := func(target string) (*httptest.ResponseRecorder, []int64) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusOK, rec.Code, target)
+		var listed []productResource
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed), target)
+		ids := []int64{}
+		for _, p := range listed {
+			ids = append(ids, p.Id)
+		}
+		return rec, ids
+	}
+
+	rec, ids := list("/products?after=&limit=2&sort=-name")
+	assert.Equal(t, []int64{1, 2}, ids)
+	cursor := rec.Header().Get(nextCursorHeader)
+	require.NotEmpty(t, cursor)
+	assert.Equal(t, `</products?after=`+cursor+`&limit=2&sort=-name>; rel="next"`, rec.Header().Get("Link"))
+
+	// products inserted before cursor do not shift the next page
+	_, err = repo.UpsertProduct(context.Background(), domain.Product{Id: 6, Name: "Desk", AdditionalInfo: "Info"})
+	require.NoError(t, err)
+	rec, ids = list("/products?after=" + cursor + "&limit=2&sort=-name")
+	assert.Equal(t, []int64{3, 4}, ids)
+	rec, ids = list("/products?after=" + rec.Header().Get(nextCursorHeader) + "&limit=2&sort=-name")
+	assert.Equal(t, []int64{5}, ids)
+	assert.Empty(t, rec.Header().Get(nextCursorHeader), "the last page has no next")
+	assert.Empty(t, rec.Header().Get("Link"))
+
+	_, ids = list("/products?after=3&limit=10&name=chair")
+	assert.Empty(t, ids)
+	_, ids = list("/products?after=2&limit=2")
+	assert.Equal(t, []int64{3, 4}, ids, "without sort after takes product id")
+
+	for _, target := range []string{
+		"/products?after=&limit=0",
+		"/products?after=2",
+		"/products?after=not+a+cursor&limit=2",
+		"/products?after=2&limit=2&sort=name",
+		"/products?after=" + cursor + "&limit=2&sort=name",
+		"/products?after=" + cursor + "&limit=2",
+		"/products?after=&limit=2&sort=price",
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+		assert.Contains(t, rec.Body.String(), string(CodeInvalidParameter), target)
+	}
+}
+
+func TestGetProductsPageMeta(t *testing.T) {
+	repo := fakes.NewRepository(
+		domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Info"},
+		domain.Product{Id: 2, Name: "Chair", AdditionalInfo: "Info"},
+		domain.Product{Id: 3, Name: "Chair", AdditionalInfo: "Info"},
+	)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	svc := service.NewResourceService(repo, fakes.NewCache())
+	routers := map[string]http.Handler{
+		"plain":    logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, WithResponseEnvelope(true))).SetupRoutes()),
+		"memoized": logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, WithResponseEnvelope(true), WithListMemo(time.Minute))).SetupRoutes()),
+	}
+	offset := func(n int64) *int64 { return &n }
+
+	for name, router := range routers {
+		for target, expected := range map[string]pageMeta{
+			"/products?limit=2&offset=0":                     {Total: 3, Limit: 2, Offset: offset(0), HasMore: true},
+			"/products?limit=2&offset=1":                     {Total: 3, Limit: 2, Offset: offset(1)},
+			"/products?limit=1&offset=1&name=chair":          {Total: 2, Limit: 1, Offset: offset(1)},
+			"/products?after=1&limit=5":                      {Total: 3, Limit: 5},
+			"/products?after=&limit=1&name=chair":            {Total: 2, Limit: 1, NextCursor: "eyJpZCI6Mn0", HasMore: true},
+			"/products?after=eyJpZCI6Mn0&limit=1&name=chair": {Total: 2, Limit: 1},
+		} {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			require.Equal(t, http.StatusOK, rec.Code, target)
+			var wrapped envelope
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &wrapped), target)
+			require.NotNil(t, wrapped.Meta.Page, name+" "+target)
+			assert.Equal(t, expected, *wrapped.Meta.Page, name+" "+target)
+		}
+	}
+
+	router := routers["plain"]
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products", nil))
+	var wrapped envelope
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &wrapped))
+	assert.Nil(t, wrapped.Meta.Page, "unpaged listing has no page")
+
+	repo.FailWith("CountProducts", domain.ErrInternalDb)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products?limit=2&offset=0", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/products?limit=2&offset=0", nil)
+	req.Header.Set(envelopeHeader, "false")
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, "total is not counted without envelope")
+}
+
+// gatedService holds paged listings until gate is closed and counts them
+type gatedService struct {
+	ports.ResourseService
+	gate  chan struct{}
+	calls atomic.Int64
+	fail  atomic.Bool
+}
+
+func (s *gatedService) GetProductsPaged(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, limit int64, offset int64) ([]domain.Product, *domain.ServiceError) {
+	s.calls.Add(1)
+	select {
+	case <-s.gate:
+	case <-ctx.Done():
+		return nil, domain.NewServiceError(ctx.Err(), nil)
+	}
+	if s.fail.Load() {
+		return nil, domain.NewServiceError(domain.ErrInternalDb, nil)
+	}
+	return s.ResourseService.GetProductsPaged(ctx, filter, sort, limit, offset)
+}
+
+func TestGetProductsMemoized(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	newServer := func(svc ports.ResourseService, window time.Duration) *httptest.Server {
+		handler := NewProductHandler(svc, WithListMemo(window))
+		return httptest.NewServer(logger.LoggerMiddleware(NewRouter(handler).SetupRoutes()))
+	}
+	get := func(t *testing.T, url string) (int, string) {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+	newService := func() *gatedService {
+		products := testhelpers.GenerateProducts(10)
+		for i := range products {
+			products[i].Id = int64(i + 1)
+		}
+		repo := fakes.NewRepository(products...)
+		return &gatedService{ResourseService: service.NewResourceService(repo, fakes.NewCache()), gate: make(chan struct{})}
+	}
+	// fanOut sends n requests and lets listing through once all of them wait
+	fanOut := func(t *testing.T, svc *gatedService, url string, n int) []string {
+		bodies := make([]string, n)
+		var wg sync.WaitGroup
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, bodies[i] = get(t, url)
+			}()
+		}
+		require.Eventually(t, func() bool { return svc.calls.Load() > 0 }, time.Second, time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		close(svc.gate)
+		wg.Wait()
+		return bodies
+	}
+
+	t.Run("identical requests share one listing", func(t *testing.T) {
+		svc := newService()
+		server := newServer(svc, time.Minute)
+		defer server.Close()
+
+		bodies := fanOut(t, svc, server.URL+"/products?limit=5&offset=0", 50)
+		assert.Equal(t, int64(1), svc.calls.Load())
+		for _, body := range bodies {
+			assert.JSONEq(t, bodies[0], body)
+		}
+		var listed []productResource
+		require.NoError(t, json.Unmarshal([]byte(bodies[0]), &listed))
+		assert.Len(t, listed, 5)
+
+		// within window response is still shared, other pages are not
+		get(t, server.URL+"/products?limit=5&offset=0")
+		assert.Equal(t, int64(1), svc.calls.Load())
+		get(t, server.URL+"/products?limit=5&offset=5")
+		assert.Equal(t, int64(2), svc.calls.Load())
+	})
+
+	t.Run("response is computed again after window", func(t *testing.T) {
+		svc := newService()
+		close(svc.gate)
+		server := newServer(svc, 10*time.Millisecond)
+		defer server.Close()
+
+		get(t, server.URL+"/products?limit=5&offset=0")
+		require.Eventually(t, func() bool {
+			get(t, server.URL+"/products?limit=5&offset=0")
+			return svc.calls.Load() == 2
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("failure is shared with waiters only", func(t *testing.T) {
+		svc := newService()
+		svc.fail.Store(true)
+		server := newServer(svc, time.Minute)
+		defer server.Close()
+
+		bodies := fanOut(t, svc, server.URL+"/products?limit=5&offset=0", 10)
+		assert.Equal(t, int64(1), svc.calls.Load())
+		for _, body := range bodies {
+			assert.Contains(t, body, string(CodeInternal))
+		}
+
+		svc.fail.Store(false)
+		status, _ := get(t, server.URL+"/products?limit=5&offset=0")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, int64(2), svc.calls.Load())
+	})
+
+	t.Run("waiters outlive canceled leader", func(t *testing.T) {
+		svc := newService()
+		server := newServer(svc, time.Minute)
+		defer server.Close()
+		url := server.URL + "/products?limit=5&offset=0"
+
+		ctx, cancel := context.WithCancel(context.Background())
+		leaderDone := make(chan struct{})
+		go func() {
+			defer close(leaderDone)
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		require.Eventually(t, func() bool { return svc.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+		status := make(chan int)
+		go func() {
+			code, _ := get(t, url)
+			status <- code
+		}()
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		<-leaderDone
+		require.Eventually(t, func() bool { return svc.calls.Load() == 2 }, time.Second, time.Millisecond)
+		close(svc.gate)
+		assert.Equal(t, http.StatusOK, <-status)
+	})
+}
@@ -0,0 +1,68 @@
+package routing
+
+import (
+	"net/http"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusDegraded    = "degraded"
+	healthStatusUnavailable = "unavailable"
+)
+
+// WithHealth makes readiness follow critical dependencies and enables
+// GET /healthz
+func WithHealth(health ports.HealthReporter) AdminOption {
+	return func(h *AdminHandler) {
+		h.health = health
+	}
+}
+
+// WithStartup keeps readiness off until startup checks pass
+func WithStartup(startup ports.StartupReporter) AdminOption {
+	return func(h *AdminHandler) {
+		h.startup = startup
+	}
+}
+
+// Live is liveness probe, it only tells that process serves requests.
+// Dependencies, startup and draining are left to readiness, failing
+// liveness gets process restarted, which none of them would help
+func (h *AdminHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]string{"status": healthStatusOK})
+}
+
+type healthReport struct {
+	// ok, degraded if a non-critical dependency is unhealthy, unavailable
+	// if a critical one is
+	Status       string                   `json:"status"`
+	Dependencies []domain.ComponentHealth `json:"dependencies"`
+}
+
+// Health reports state of every dependency as of its latest probe. Like
+// readiness, it fails with 503 while a critical dependency is unhealthy
+func (h *AdminHandler) Health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if h.health == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Health checks are not configured")
+		return
+	}
+	report := healthReport{Status: healthStatusOK, Dependencies: h.health.Health()}
+	for _, dependency := range report.Dependencies {
+		if !dependency.Healthy {
+			report.Status = healthStatusDegraded
+		}
+	}
+	status := http.StatusOK
+	if !h.health.Ready() {
+		report.Status = healthStatusUnavailable
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
@@ -0,0 +1,153 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// stubHealth reports fixed state of dependencies
+type stubHealth []domain.ComponentHealth
+
+func (s stubHealth) Health() []domain.ComponentHealth {
+	return s
+}
+
+func (s stubHealth) Ready() bool {
+	for _, h := range s {
+		if h.Critical && !h.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		health     stubHealth
+		status     string
+		code       int
+		unhealthy  []string
+		readyzCode int
+	}{
+		{
+			name: "ok",
+			health: stubHealth{
+				{Name: "postgres", Critical: true, Healthy: true},
+				{Name: "redis", Healthy: true},
+			},
+			status:     healthStatusOK,
+			code:       http.StatusOK,
+			readyzCode: http.StatusOK,
+		},
+		{
+			name: "degraded",
+			health: stubHealth{
+				{Name: "postgres", Critical: true, Healthy: true},
+				{Name: "redis", Failures: 3, Error: "connection refused"},
+			},
+			status:     healthStatusDegraded,
+			code:       http.StatusOK,
+			readyzCode: http.StatusOK,
+		},
+		{
+			name: "unavailable",
+			health: stubHealth{
+				{Name: "postgres", Critical: true},
+				{Name: "redis", Healthy: true},
+			},
+			status:     healthStatusUnavailable,
+			code:       http.StatusServiceUnavailable,
+			unhealthy:  []string{"postgres"},
+			readyzCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+			require.NoError(t, err)
+			defer logger.Close()
+			router := logger.LoggerMiddleware(NewAdminRouter(NewAdminHandler(logger, WithHealth(tt.health))).SetupRoutes())
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			assert.Equal(t, tt.code, rec.Code)
+			var report healthReport
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+			assert.Equal(t, tt.status, report.Status)
+			assert.Equal(t, []domain.ComponentHealth(tt.health), report.Dependencies)
+
+			rec = httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tt.readyzCode, rec.Code)
+			var ready readyStatus
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ready))
+			assert.Equal(t, tt.unhealthy, ready.Unhealthy)
+		})
+	}
+}
+
+func TestHealthNotConfigured(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewAdminRouter(NewAdminHandler(logger)).SetupRoutes())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+}
+
+// stubStartup reports fixed pending startup checks
+type stubStartup []string
+
+func (s stubStartup) Pending() []string {
+	return s
+}
+
+func TestLivenessIsIndependentOfReadiness(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	drainer := NewDrainer()
+	unhealthy := stubHealth{{Name: "postgres", Critical: true}}
+	startup := stubStartup{"postgres-schema"}
+	router := logger.LoggerMiddleware(NewAdminRouter(NewAdminHandler(logger,
+		WithDrainer(drainer), WithHealth(unhealthy), WithStartup(startup))).SetupRoutes())
+	drainer.Drain()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var ready readyStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ready))
+	assert.True(t, ready.Draining)
+	assert.Equal(t, []string{"postgres-schema"}, ready.Starting)
+	assert.Equal(t, []string{"postgres"}, ready.Unhealthy)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.JSONEq(t, `{"status": "ok"}`, rec.Body.String())
+}
+
+func TestReadyOnceStarted(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewAdminRouter(NewAdminHandler(logger, WithStartup(stubStartup(nil)))).SetupRoutes())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "starting")
+}
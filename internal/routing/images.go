@@ -0,0 +1,66 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// defaultMaxImageBytes bounds size of uploaded images
+const defaultMaxImageBytes = 10 << 20
+
+// AddProductImage streams raw image in body to image store and adds it to
+// product. Body is the image itself, typed by Content-Type, and its length
+// has to be given upfront. Responds with the product
+func (h *ProductHandler) AddProductImage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	id, err := parseAndValidate(r.PathValue("id"), 0, "product id", errContainer, w)
+	if err != nil {
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if _, ok := domain.ImageExtension(mediaType); !ok {
+		errContainer.Add(fmt.Errorf("handler error: unsupported image media type %q", mediaType))
+		writeError(w, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "Unsupported image type")
+		return
+	}
+	switch {
+	case r.ContentLength < 0:
+		errContainer.Add(errors.New("handler error: image length is not given"))
+		writeError(w, http.StatusLengthRequired, CodeInvalidBody, "Content-Length is required")
+		return
+	case r.ContentLength == 0:
+		errContainer.Add(errors.New("handler error: image is empty"))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Image is empty")
+		return
+	case r.ContentLength > h.maxImageBytes:
+		errContainer.Add(fmt.Errorf("handler error: image of %d bytes exceeds %d bytes", r.ContentLength, h.maxImageBytes))
+		writeError(w, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "Image is too large")
+		return
+	}
+
+	upload := domain.ImageUpload{ContentType: mediaType, Size: r.ContentLength, Body: r.Body}
+	product, serviceErr := h.svc.AddProductImage(r.Context(), id, upload)
+	if serviceErr != nil {
+		storeServiceErrToCtx(r.Context(), serviceErr)
+		if serviceErr.CriticalError != nil {
+			switch {
+			case errors.Is(serviceErr.CriticalError, domain.ErrNotFound):
+				writeError(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+			case errors.Is(serviceErr.CriticalError, domain.ErrImagesDisabled):
+				writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Image storage is not configured")
+			case errors.Is(serviceErr.CriticalError, domain.ErrObjectStore):
+				writeError(w, http.StatusBadGateway, CodeImageStoreUnavailable, "Image storage is unavailable")
+			default:
+				writeServerError(w, r, serviceErr.CriticalError)
+			}
+			return
+		}
+	}
+	w.Header().Set("ETag", productETag(*product))
+	h.writeProduct(w, r, http.StatusCreated, *product)
+}
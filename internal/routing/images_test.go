@@ -0,0 +1,120 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestAddProductImage(t *testing.T) {
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Brass"})
+	objects := fakes.NewObjectStore()
+	svc := service.NewResourceService(repo, fakes.NewCache(), service.WithObjectStore(objects))
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, WithMaxImageBytes(8))).SetupRoutes())
+
+	upload := func(path string, contentType string, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	code := func(rec *httptest.ResponseRecorder) ErrorCode {
+		var body errorBody
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		return body.Code
+	}
+
+	t.Run("uploads image", func(t *testing.T) {
+		rec := upload("/product/1/images", "image/png", "png")
+		require.Equal(t, http.StatusCreated, rec.Code)
+		etag := rec.Header().Get("ETag")
+		var product domain.Product
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&product))
+		assert.Equal(t, productETag(product), etag)
+		assert.True(t, strings.HasPrefix(etag, `"2-`), etag)
+		require.Len(t, product.Images, 1)
+		image := product.Images[0]
+		assert.True(t, strings.HasPrefix(image.URL, fakes.ObjectURL+"products/1/"), image.URL)
+		assert.True(t, strings.HasSuffix(image.URL, ".png"), image.URL)
+		assert.Equal(t, "image/png", image.ContentType)
+		assert.Equal(t, int64(3), image.Size)
+		assert.Equal(t, 1, objects.Len())
+
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/product/1", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"url":"`+image.URL+`"`)
+
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "{ product(id: \"1\") { images { url size } } }"}`)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp graphQLResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"images": [{"url": "`+image.URL+`", "size": 3}]}`, string(resp.Data["product"]))
+	})
+
+	t.Run("rejects invalid uploads", func(t *testing.T) {
+		rec := upload("/product/1/images", "text/plain", "png")
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		assert.Equal(t, CodeUnsupportedMediaType, code(rec))
+
+		rec = upload("/product/1/images", "image/png", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, CodeInvalidBody, code(rec))
+
+		rec = upload("/product/1/images", "image/png", "too large")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Equal(t, CodeBodyTooLarge, code(rec))
+
+		rec = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/product/1/images", strings.NewReader("png"))
+		req.Header.Set("Content-Type", "image/png")
+		req.ContentLength = -1
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusLengthRequired, rec.Code)
+		assert.Equal(t, 1, objects.Len(), "nothing more is stored")
+	})
+
+	t.Run("unknown product", func(t *testing.T) {
+		rec := upload("/product/9/images", "image/png", "png")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, CodeProductNotFound, code(rec))
+	})
+
+	t.Run("image store failure", func(t *testing.T) {
+		objects.FailWith("Put", fmt.Errorf("%w: connection refused", domain.ErrObjectStore))
+		defer objects.FailWith("Put", nil)
+
+		rec := upload("/product/1/images", "image/png", "png")
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+		assert.Equal(t, CodeImageStoreUnavailable, code(rec))
+	})
+
+	t.Run("image store is not configured", func(t *testing.T) {
+		svc := service.NewResourceService(repo, fakes.NewCache())
+		router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc)).SetupRoutes())
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/product/1/images", strings.NewReader("png"))
+		req.Header.Set("Content-Type", "image/png")
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+		assert.Equal(t, CodeNotConfigured, code(rec))
+	})
+}
@@ -0,0 +1,317 @@
+package routing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+const (
+	csvContentType = "text/csv"
+	// maxImportRows bounds how much of the file is held while it is
+	// validated as a whole
+	maxImportRows = 50000
+	// importBatchSize is how many products are stored in one transaction
+	importBatchSize = 500
+)
+
+// importColumns are columns CSV header may name, in any order. Name and
+// additional info are required, empty category id means no category
+var importColumns = []string{"name", "additionalInfo", "categoryId", "sku"}
+
+var errTooManyImportRows = fmt.Errorf("import has more than %d rows", maxImportRows)
+
+// importReport answers bulk import. Created and errors refer to lines of
+// the file, rows with errors are not imported
+type importReport struct {
+	Rows      int            `json:"rows"`
+	Imported  int            `json:"imported"`
+	Failed    int            `json:"failed"`
+	Created   []importedRow  `json:"created"`
+	Errors    []dumpRowError `json:"errors"`
+	Truncated bool           `json:"truncated,omitempty"`
+}
+
+type importedRow struct {
+	Line int   `json:"line"`
+	Id   int64 `json:"id"`
+}
+
+// importRow is a valid product of the file and line it comes from
+type importRow struct {
+	line    int
+	product domain.NewProduct
+}
+
+// parsedImport holds valid rows of the file and problems with the rest
+type parsedImport struct {
+	rows      int
+	valid     []importRow
+	rowErrors []dumpRowError
+	// line each sku was first seen on
+	skus map[string]int
+}
+
+// ImportProducts creates products from a CSV file with a header row or from
+// NDJSON, one product per line. Every row is validated before anything is
+// stored, then valid rows are stored in batches, one transaction each.
+// Invalid rows do not stop import, response reports each of them and lines
+// of created products. If storing fails for a reason other than a row, the
+// rest of the file is reported as not imported
+func (h *ProductHandler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	var parsed parsedImport
+	var err error
+	switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+	case csvContentType:
+		parsed, err = readImportCSV(r.Body)
+	case ndjsonContentType:
+		parsed, err = readImportNDJSON(r.Body)
+	default:
+		errContainer.Add(fmt.Errorf("handler error: unsupported import content type %q", mediaType))
+		writeError(w, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "Import must be text/csv or application/x-ndjson")
+		return
+	}
+	switch {
+	case errors.Is(err, errTooManyImportRows):
+		errContainer.Add(fmt.Errorf("handler error: %w", err))
+		writeError(w, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, fmt.Sprintf("Import may have at most %d rows", maxImportRows))
+		return
+	case err != nil:
+		errContainer.Add(fmt.Errorf("handler error: invalid import: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid import file")
+		return
+	}
+
+	report := importReport{Rows: parsed.rows, Created: []importedRow{}, Errors: parsed.rowErrors}
+	for start := 0; start < len(parsed.valid); start += importBatchSize {
+		batch := parsed.valid[start:min(start+importBatchSize, len(parsed.valid))]
+		done, err := h.importBatch(r.Context(), batch, &report)
+		if err == nil {
+			continue
+		}
+		if len(report.Created) == 0 {
+			writeServerError(w, r, err)
+			return
+		}
+		errContainer.Add(fmt.Errorf("import error: stopped after %d of %d products: %w", len(report.Created), len(parsed.valid), err))
+		for _, row := range parsed.valid[start+done:] {
+			report.Errors = append(report.Errors, dumpRowError{Line: row.line, Reason: "is not imported, import was interrupted"})
+		}
+		break
+	}
+
+	report.Imported = len(report.Created)
+	report.Failed = report.Rows - report.Imported
+	slices.SortStableFunc(report.Errors, func(a, b dumpRowError) int { return a.Line - b.Line })
+	if len(report.Errors) > maxReportedRowErrors {
+		report.Errors, report.Truncated = report.Errors[:maxReportedRowErrors], true
+	}
+	h.json.write(w, http.StatusOK, report)
+}
+
+// importBatch stores batch in one transaction. A row the database rejects
+// rolls back the whole batch, rows are then stored one by one to tell which
+// it was. Returns how many rows of batch are done, imported or rejected,
+// before an error that is not about a row
+func (h *ProductHandler) importBatch(ctx context.Context, batch []importRow, report *importReport) (int, error) {
+	products := make([]domain.NewProduct, len(batch))
+	for i, row := range batch {
+		products[i] = row.product
+	}
+	ids, serviceErr := h.svc.CreateProducts(ctx, products)
+	if serviceErr != nil {
+		storeServiceErrToCtx(ctx, serviceErr)
+	}
+	if serviceErr == nil || serviceErr.CriticalError == nil {
+		for i, id := range ids {
+			report.Created = append(report.Created, importedRow{Line: batch[i].line, Id: id})
+		}
+		return len(batch), nil
+	}
+	if _, ok := rejectedRow(0, serviceErr.CriticalError); !ok {
+		return 0, serviceErr.CriticalError
+	}
+
+	for i, row := range batch {
+		id, serviceErr := h.svc.CreateProduct(ctx, row.product)
+		if serviceErr != nil {
+			storeServiceErrToCtx(ctx, serviceErr)
+		}
+		if serviceErr == nil || serviceErr.CriticalError == nil {
+			report.Created = append(report.Created, importedRow{Line: row.line, Id: id})
+			continue
+		}
+		rowErr, ok := rejectedRow(row.line, serviceErr.CriticalError)
+		if !ok {
+			return i, serviceErr.CriticalError
+		}
+		report.Errors = append(report.Errors, rowErr)
+	}
+	return len(batch), nil
+}
+
+// rejectedRow tells whether err is the database rejecting row of line,
+// rather than failing
+func rejectedRow(line int, err error) (dumpRowError, bool) {
+	switch {
+	case errors.Is(err, domain.ErrUnknownCategory):
+		return dumpRowError{Line: line, Field: "categoryId", Reason: "does not exist"}, true
+	case errors.Is(err, domain.ErrDuplicateSku):
+		return dumpRowError{Line: line, Field: "sku", Reason: "is already used by another product"}, true
+	}
+	return dumpRowError{}, false
+}
+
+// add validates product of line and keeps it if it is valid
+func (p *parsedImport) add(line int, product domain.NewProduct) {
+	var rowErrors []dumpRowError
+	switch {
+	case product.Name == "":
+		rowErrors = append(rowErrors, dumpRowError{Line: line, Field: "name", Reason: "is required"})
+	case utf8.RuneCountInString(product.Name) > domain.MaxNameLength:
+		rowErrors = append(rowErrors, dumpRowError{Line: line, Field: "name", Reason: fmt.Sprintf("is longer than %d characters", domain.MaxNameLength)})
+	}
+	if product.AdditionalInfo == "" {
+		rowErrors = append(rowErrors, dumpRowError{Line: line, Field: "additionalInfo", Reason: "is required"})
+	}
+	if product.CategoryId < 0 {
+		rowErrors = append(rowErrors, dumpRowError{Line: line, Field: "categoryId", Reason: "must not be negative"})
+	}
+	if product.Sku != "" {
+		if domain.ValidateSku(product.Sku) != nil {
+			rowErrors = append(rowErrors, dumpRowError{Line: line, Field: "sku", Reason: "is invalid"})
+		} else if first, ok := p.skus[product.Sku]; ok {
+			rowErrors = append(rowErrors, dumpRowError{Line: line, Field: "sku", Reason: fmt.Sprintf("duplicates line %d", first)})
+		}
+	}
+	if len(rowErrors) > 0 {
+		p.rowErrors = append(p.rowErrors, rowErrors...)
+		return
+	}
+	if product.Sku != "" {
+		p.skus[product.Sku] = line
+	}
+	p.valid = append(p.valid, importRow{line: line, product: product})
+}
+
+// readImportCSV parses CSV with a header row naming importColumns. Invalid
+// rows do not stop reading, error is only returned if the file can not be
+// read at all or has too many rows
+func readImportCSV(body io.Reader) (parsedImport, error) {
+	parsed := parsedImport{rowErrors: []dumpRowError{}, skus: make(map[string]int)}
+	reader := csv.NewReader(body)
+	// rows of wrong length are reported rather than failing the file
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return parsedImport{}, errors.New("header row is missing")
+	}
+	if err != nil {
+		return parsedImport{}, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		if i == 0 {
+			// spreadsheets tend to save CSV with byte order mark
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		column = strings.TrimSpace(column)
+		if !slices.Contains(importColumns, column) {
+			return parsedImport{}, fmt.Errorf("unknown column %q", column)
+		}
+		if _, ok := columns[column]; ok {
+			return parsedImport{}, fmt.Errorf("column %q is given twice", column)
+		}
+		columns[column] = i
+	}
+	for _, required := range importColumns[:2] {
+		if _, ok := columns[required]; !ok {
+			return parsedImport{}, fmt.Errorf("column %q is missing", required)
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		parsed.rows++
+		if parsed.rows > maxImportRows {
+			return parsedImport{}, errTooManyImportRows
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			parsed.rowErrors = append(parsed.rowErrors, dumpRowError{Line: parseErr.StartLine, Reason: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return parsedImport{}, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			parsed.rowErrors = append(parsed.rowErrors, dumpRowError{Line: line, Reason: fmt.Sprintf("has %d fields, header has %d", len(record), len(header))})
+			continue
+		}
+		field := func(column string) string {
+			if i, ok := columns[column]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		product := domain.NewProduct{Name: field("name"), AdditionalInfo: field("additionalInfo"), Sku: field("sku")}
+		if categoryId := field("categoryId"); categoryId != "" {
+			if product.CategoryId, err = strconv.ParseInt(categoryId, 10, 64); err != nil {
+				parsed.rowErrors = append(parsed.rowErrors, dumpRowError{Line: line, Field: "categoryId", Reason: "must be int64"})
+				continue
+			}
+		}
+		parsed.add(line, product)
+	}
+	return parsed, nil
+}
+
+// readImportNDJSON parses one product per line, blank lines are not rows.
+// Invalid lines do not stop reading, error is only returned if the file
+// can not be read at all or has too many rows
+func readImportNDJSON(body io.Reader) (parsedImport, error) {
+	parsed := parsedImport{rowErrors: []dumpRowError{}, skus: make(map[string]int)}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxDumpLineBytes)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		parsed.rows++
+		if parsed.rows > maxImportRows {
+			return parsedImport{}, errTooManyImportRows
+		}
+		var product domain.NewProduct
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&product); err != nil {
+			parsed.rowErrors = append(parsed.rowErrors, decodeRowError(line, err))
+			continue
+		}
+		parsed.add(line, product)
+	}
+	if err := scanner.Err(); err != nil {
+		return parsedImport{}, err
+	}
+	return parsed, nil
+}
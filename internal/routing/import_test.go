@@ -0,0 +1,152 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestImportProducts(t *testing.T) {
+	setup := func(t *testing.T, products ...domain.Product) (*fakes.Repository, http.Handler) {
+		repo := fakes.NewRepository(products...)
+		logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+		require.NoError(t, err)
+		t.Cleanup(func() { logger.Close() })
+		return repo, logger.LoggerMiddleware(NewRouter(NewProductHandler(service.NewResourceService(repo, fakes.NewCache()))).SetupRoutes())
+	}
+	importFile := func(t *testing.T, router http.Handler, contentType string, body string) (*httptest.ResponseRecorder, importReport) {
+		req := httptest.NewRequest(http.MethodPost, "/products/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var report importReport
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		}
+		return rec, report
+	}
+
+	t.Run("csv rows are imported and invalid ones reported", func(t *testing.T) {
+		repo, router := setup(t, domain.Product{Id: 1, Name: "Old", AdditionalInfo: "Info", Sku: "TAKEN"})
+		category, err := repo.StoreCategory(context.Background(), "Lighting")
+		require.NoError(t, err)
+		csv := "\ufeffname,additionalInfo,categoryId,sku\n" +
+			fmt.Sprintf("Lamp,\"Brass, tall\",%d,LAMP-1\n", category) +
+			"Desk,Oak,,\n" +
+			",Missing name,,\n" +
+			"Chair,Oak,abc,\n" +
+			"Stool,Oak,,LAMP-1\n" +
+			"Shelf,Oak,999,\n" +
+			"Sofa,Leather,,TAKEN\n" +
+			"Bench,Oak\n"
+
+		rec, report := importFile(t, router, "text/csv; charset=utf-8", csv)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, 8, report.Rows)
+		assert.Equal(t, 2, report.Imported)
+		assert.Equal(t, 6, report.Failed)
+		require.Len(t, report.Created, 2)
+		assert.Equal(t, 2, report.Created[0].Line)
+		assert.Equal(t, 3, report.Created[1].Line)
+		assert.Equal(t, []dumpRowError{
+			{Line: 4, Field: "name", Reason: "is required"},
+			{Line: 5, Field: "categoryId", Reason: "must be int64"},
+			{Line: 6, Field: "sku", Reason: "duplicates line 2"},
+			{Line: 7, Field: "categoryId", Reason: "does not exist"},
+			{Line: 8, Field: "sku", Reason: "is already used by another product"},
+			{Line: 9, Reason: "has 2 fields, header has 4"},
+		}, report.Errors)
+
+		lamp, err := repo.GetProduct(context.Background(), report.Created[0].Id)
+		require.NoError(t, err)
+		assert.Equal(t, "Brass, tall", lamp.AdditionalInfo)
+		assert.Equal(t, category, lamp.CategoryId)
+		assert.Equal(t, "LAMP-1", lamp.Sku)
+	})
+
+	t.Run("ndjson rows are imported and invalid ones reported", func(t *testing.T) {
+		_, router := setup(t)
+		ndjson := `{"name":"Lamp","additionalInfo":"Brass"}` + "\n\n" +
+			`{"name":"Desk","additionalInfo":"Oak","color":"brown"}` + "\n" +
+			`{"name":"` + strings.Repeat("a", domain.MaxNameLength+1) + `","additionalInfo":"Oak"}` + "\n" +
+			`{"name":"Chair","additionalInfo":"Oak","categoryId":"one"}` + "\n"
+
+		rec, report := importFile(t, router, ndjsonContentType, ndjson)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, 4, report.Rows)
+		assert.Equal(t, 1, report.Imported)
+		assert.Equal(t, 3, report.Failed)
+		assert.Equal(t, []importedRow{{Line: 1, Id: 1}}, report.Created)
+		assert.Equal(t, []dumpRowError{
+			{Line: 3, Field: "color", Reason: "is unknown"},
+			{Line: 4, Field: "name", Reason: fmt.Sprintf("is longer than %d characters", domain.MaxNameLength)},
+			{Line: 5, Field: "categoryId", Reason: "must be int64"},
+		}, report.Errors)
+	})
+
+	t.Run("rows past a failure are reported as not imported", func(t *testing.T) {
+		repo, router := setup(t)
+		var ndjson strings.Builder
+		for i := range importBatchSize + 2 {
+			fmt.Fprintf(&ndjson, `{"name":"Product %d","additionalInfo":"Info"}`+"\n", i)
+		}
+		calls := 0
+		repo.OnCall(func(method string) error {
+			if method != "StoreProducts" {
+				return nil
+			}
+			if calls++; calls > 1 {
+				return domain.ErrInternalDb
+			}
+			return nil
+		})
+
+		rec, report := importFile(t, router, ndjsonContentType, ndjson.String())
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, importBatchSize, report.Imported)
+		assert.Equal(t, 2, report.Failed)
+		require.Len(t, report.Errors, 2)
+		assert.Equal(t, dumpRowError{Line: importBatchSize + 1, Reason: "is not imported, import was interrupted"}, report.Errors[0])
+	})
+
+	t.Run("failure before any row is imported", func(t *testing.T) {
+		repo, router := setup(t)
+		repo.FailWith("StoreProducts", domain.ErrInternalDb)
+
+		rec, _ := importFile(t, router, csvContentType, "name,additionalInfo\nLamp,Brass\n")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("invalid files", func(t *testing.T) {
+		_, router := setup(t)
+		for name, test := range map[string]struct {
+			contentType string
+			body        string
+			status      int
+		}{
+			"unsupported type":     {"application/json", `[]`, http.StatusUnsupportedMediaType},
+			"missing header":       {csvContentType, "", http.StatusBadRequest},
+			"unknown column":       {csvContentType, "name,additionalInfo,price\n", http.StatusBadRequest},
+			"missing column":       {csvContentType, "name,sku\n", http.StatusBadRequest},
+			"column given twice":   {csvContentType, "name,additionalInfo,name\n", http.StatusBadRequest},
+			"too many ndjson rows": {ndjsonContentType, strings.Repeat("{}\n", maxImportRows+1), http.StatusRequestEntityTooLarge},
+		} {
+			t.Run(name, func(t *testing.T) {
+				rec, _ := importFile(t, router, test.contentType, test.body)
+				assert.Equal(t, test.status, rec.Code, rec.Body.String())
+			})
+		}
+	})
+}
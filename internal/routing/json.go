@@ -0,0 +1,103 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// maxPooledJSONBuffer keeps buffers grown by a few huge responses, such as
+// unpaged product lists, from being held by the pool for good
+const maxPooledJSONBuffer = 64 << 10
+
+// jsonBuffer pairs a buffer with an encoder writing into it, so neither is
+// allocated per response
+type jsonBuffer struct {
+	buf bytes.Buffer
+	enc ports.JSONEncoder
+}
+
+// jsonCodec keeps buffers of encoders of one codec
+type jsonCodec struct {
+	codec   ports.JSONCodec
+	buffers sync.Pool
+}
+
+func newJSONCodec(codec ports.JSONCodec) *jsonCodec {
+	c := &jsonCodec{codec: codec}
+	c.buffers.New = func() any {
+		b := &jsonBuffer{}
+		b.enc = codec.NewEncoder(&b.buf)
+		return b
+	}
+	return c
+}
+
+func (c *jsonCodec) getBuffer() *jsonBuffer {
+	return c.buffers.Get().(*jsonBuffer)
+}
+
+func (c *jsonCodec) putBuffer(b *jsonBuffer) {
+	if b.buf.Cap() <= maxPooledJSONBuffer {
+		b.buf.Reset()
+		c.buffers.Put(b)
+	}
+}
+
+// write encodes v as the response body with given status, Content-Type
+// is left to callers. Body is encoded before anything is written, so
+// encoding failure is reported as an error response rather than cut short
+// JSON, and Content-Length is known up front
+func (c *jsonCodec) write(w http.ResponseWriter, status int, v any) {
+	b := c.getBuffer()
+	defer c.putBuffer(b)
+
+	if err := b.enc.Encode(v); err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Internal server error")
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(b.buf.Len()))
+	w.WriteHeader(status)
+	w.Write(b.buf.Bytes())
+}
+
+func (c *jsonCodec) decode(body io.Reader, v any) error {
+	return c.codec.Decode(body, v)
+}
+
+// stdJSON is encoding/json, used unless handler is given another codec
+type stdJSON struct{}
+
+func (stdJSON) NewEncoder(w io.Writer) ports.JSONEncoder {
+	return json.NewEncoder(w)
+}
+
+func (stdJSON) Decode(r io.Reader, v any) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("unexpected data after json value")
+	}
+	return nil
+}
+
+// stdCodec serves responses outside of product handlers, such as errors
+// and admin endpoints
+var stdCodec = newJSONCodec(stdJSON{})
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	stdCodec.write(w, status, v)
+}
+
+func decodeBody(body io.Reader, v any) error {
+	return stdCodec.decode(body, v)
+}
@@ -0,0 +1,243 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	contentTypeJSONAPI = "application/vnd.api+json"
+	jsonAPIProductType = "products"
+)
+
+type jsonAPIDocument struct {
+	Data   interface{}            `json:"data,omitempty"`
+	Errors []jsonAPIError         `json:"errors,omitempty"`
+	Meta   interface{}            `json:"meta,omitempty"`
+	Links  map[string]interface{} `json:"links,omitempty"`
+}
+
+type jsonAPIResource struct {
+	Type       string                     `json:"type"`
+	Id         string                     `json:"id"`
+	Attributes map[string]json.RawMessage `json:"attributes,omitempty"`
+	Links      map[string]interface{}     `json:"links,omitempty"`
+}
+
+type jsonAPIError struct {
+	Status string    `json:"status"`
+	Code   ErrorCode `json:"code,omitempty"`
+	Title  string    `json:"title"`
+}
+
+// acceptsJSONAPI reports whether client asked for JSON:API. Per spec, the
+// media type is only acceptable without parameters, so ok is false when
+// every JSON:API entry of Accept carries them
+func acceptsJSONAPI(accept string) (requested bool, ok bool) {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != contentTypeJSONAPI {
+			continue
+		}
+		requested = true
+		delete(params, "q")
+		if len(params) == 0 {
+			return true, true
+		}
+	}
+	return requested, false
+}
+
+// jsonAPIMiddleware translates handler responses to JSON:API documents for
+// clients negotiating application/vnd.api+json, other clients get plain JSON
+func jsonAPIMiddleware(links *LinkBuilder, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		requested, ok := acceptsJSONAPI(r.Header.Get("Accept"))
+		if !requested {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !ok {
+			writeJSONAPI(w, http.StatusNotAcceptable, jsonAPIDocument{
+				Errors: []jsonAPIError{{
+					Status: strconv.Itoa(http.StatusNotAcceptable),
+					Code:   CodeNotAcceptable,
+					Title:  "Media type parameters are not supported",
+				}},
+			})
+			return
+		}
+
+		rec := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		mediaType, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type"))
+		if mediaType != "application/json" {
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+			return
+		}
+		document, err := toJSONAPI(links, r, rec.status, rec.header.Get(nextCursorHeader), rec.body.Bytes())
+		if err != nil {
+			// leave response as is rather than fail a request that succeeded
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+			return
+		}
+		writeJSONAPI(w, rec.status, document)
+	})
+}
+
+func writeJSONAPI(w http.ResponseWriter, status int, document jsonAPIDocument) {
+	w.Header().Set("Content-Type", contentTypeJSONAPI)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(document)
+}
+
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+func toJSONAPI(links *LinkBuilder, r *http.Request, status int, nextCursor string, body []byte) (jsonAPIDocument, error) {
+	var document jsonAPIDocument
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return document, err
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if message, ok := v["error"].(string); ok && status >= http.StatusBadRequest {
+			code, _ := v["code"].(string)
+			document.Errors = []jsonAPIError{{Status: strconv.Itoa(status), Code: ErrorCode(code), Title: message}}
+			return document, nil
+		}
+		if _, ok := v["id"]; !ok {
+			// not a resource, e.g. search result or deleted rows count
+			document.Meta = v
+			return document, nil
+		}
+		resource, err := toJSONAPIResource(body)
+		if err != nil {
+			return document, err
+		}
+		document.Data = resource
+	case []interface{}:
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			return document, err
+		}
+		resources := make([]jsonAPIResource, 0, len(items))
+		for _, item := range items {
+			resource, err := toJSONAPIResource(item)
+			if err != nil {
+				return document, err
+			}
+			resources = append(resources, resource)
+		}
+		document.Data = resources
+		document.Links = collectionLinks(links, r, len(resources), nextCursor)
+	default:
+		return document, fmt.Errorf("unexpected response body of type %T", value)
+	}
+	return document, nil
+}
+
+func toJSONAPIResource(body []byte) (jsonAPIResource, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return jsonAPIResource{}, err
+	}
+	var id int64
+	if err := json.Unmarshal(fields["id"], &id); err != nil {
+		return jsonAPIResource{}, fmt.Errorf("invalid resource id: %w", err)
+	}
+	var productLinks Links
+	if raw, ok := fields["_links"]; ok {
+		if err := json.Unmarshal(raw, &productLinks); err != nil {
+			return jsonAPIResource{}, err
+		}
+	}
+	delete(fields, "id")
+	delete(fields, "_links")
+
+	resource := jsonAPIResource{
+		Type:       jsonAPIProductType,
+		Id:         strconv.FormatInt(id, 10),
+		Attributes: fields,
+	}
+	if len(productLinks) > 0 {
+		resource.Links = make(map[string]interface{}, len(productLinks))
+		for rel, link := range productLinks {
+			if link.Method == "" {
+				resource.Links[rel] = link.Href
+				continue
+			}
+			resource.Links[rel] = map[string]interface{}{
+				"href": link.Href,
+				"meta": map[string]string{"method": link.Method},
+			}
+		}
+	}
+	return resource, nil
+}
+
+// collectionLinks builds pagination links for offset/limit and keyset pages.
+// Total count is unknown, so next is only given when the page is full
+func collectionLinks(links *LinkBuilder, r *http.Request, count int, nextCursor string) map[string]interface{} {
+	collection := links.Collection()
+	query := r.URL.Query()
+	if query.Has("after") {
+		result := map[string]interface{}{
+			"self":  links.collectionAfter(query, query.Get("after")),
+			"first": links.collectionAfter(query, ""),
+		}
+		if nextCursor != "" {
+			result["next"] = links.collectionAfter(query, nextCursor)
+		}
+		return result
+	}
+	offset, offsetErr := strconv.ParseInt(query.Get("offset"), 10, 64)
+	limit, limitErr := strconv.ParseInt(query.Get("limit"), 10, 64)
+	if offsetErr != nil || limitErr != nil {
+		return map[string]interface{}{"self": collection}
+	}
+
+	page := func(offset int64) string {
+		return fmt.Sprintf("%s?offset=%d&limit=%d", collection, offset, limit)
+	}
+	result := map[string]interface{}{
+		"self":  page(offset),
+		"first": page(0),
+	}
+	if offset > 0 {
+		result["prev"] = page(max(offset-limit, 0))
+	}
+	if int64(count) == limit {
+		result["next"] = page(offset + limit)
+	}
+	return result
+}
@@ -0,0 +1,162 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// tokenLeeway tolerates clock skew between issuer and service
+const tokenLeeway = 30 * time.Second
+
+// asymmetric algorithms only, keys from a key set are public
+var tokenAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+type scopesKey struct{}
+
+// WithScopes stores scopes granted to caller in context
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// ScopesFromContext returns scopes granted to caller, nil unless request was
+// authenticated by a token
+func ScopesFromContext(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesKey{}).([]string)
+	return scopes
+}
+
+// HasScope tells whether caller was granted scope
+func HasScope(ctx context.Context, scope string) bool {
+	return slices.Contains(ScopesFromContext(ctx), scope)
+}
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	// space separated, as in OAuth 2.0 token responses
+	Scope string `json:"scope"`
+	// list of scopes, as some issuers send them
+	Scp jwt.ClaimStrings `json:"scp"`
+	// roles unknown to the service are ignored
+	Roles jwt.ClaimStrings `json:"roles"`
+}
+
+func (c tokenClaims) scopes() []string {
+	scopes := strings.Fields(c.Scope)
+	for _, scope := range c.Scp {
+		scopes = append(scopes, strings.Fields(scope)...)
+	}
+	slices.Sort(scopes)
+	return slices.Compact(scopes)
+}
+
+func (c tokenClaims) roles() []domain.Role {
+	var roles []domain.Role
+	for _, value := range c.Roles {
+		if role, err := domain.ParseRole(value); err == nil {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// JWTAuthenticator authenticates requests by bearer tokens signed with a key
+// of issuer's key set. Subject of token becomes principal of request, its
+// scopes and roles are put in context for authorization
+type JWTAuthenticator struct {
+	keys   ports.KeySet
+	issuer string
+	// accepted tokens must be issued for audience, if set
+	audience string
+}
+
+func NewJWTAuthenticator(keys ports.KeySet, issuer string, audience string) *JWTAuthenticator {
+	return &JWTAuthenticator{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+// Middleware rejects requests with an invalid token with 401. Requests
+// without a token are let through, so that other authentication may apply
+func (a *JWTAuthenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		scheme, token, _ := strings.Cut(authorization, " ")
+		if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			errContainer.Add(fmt.Errorf("token error: unsupported authorization scheme %q", scheme))
+			w.Header().Set("WWW-Authenticate", `Bearer`)
+			writeError(w, http.StatusUnauthorized, CodeUnauthenticated, "Bearer token required")
+			return
+		}
+		ctx, err := a.Authenticate(r.Context(), strings.TrimSpace(token))
+		if err != nil {
+			errContainer.Add(fmt.Errorf("token error: %w", err))
+			if !errors.Is(err, domain.ErrUnauthenticated) {
+				writeServerError(w, r, err)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, CodeUnauthenticated, "Invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Authenticate returns ctx with subject of token as principal and its
+// scopes and roles, or ErrUnauthenticated if token is not valid. Calls over
+// other transports are authenticated by it too
+func (a *JWTAuthenticator) Authenticate(ctx context.Context, token string) (context.Context, error) {
+	claims, err := a.verify(ctx, token)
+	if err != nil {
+		if errors.Is(err, domain.ErrKeySet) {
+			return ctx, err
+		}
+		return ctx, fmt.Errorf("%w: %w", domain.ErrUnauthenticated, err)
+	}
+	ctx = WithScopes(WithPrincipal(ctx, claims.Subject), claims.scopes())
+	if roles := claims.roles(); len(roles) > 0 {
+		ctx = WithRoles(ctx, roles)
+	}
+	return ctx, nil
+}
+
+func (a *JWTAuthenticator) verify(ctx context.Context, token string) (*tokenClaims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(tokenAlgorithms),
+		jwt.WithIssuer(a.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(tokenLeeway),
+	}
+	if a.audience != "" {
+		opts = append(opts, jwt.WithAudience(a.audience))
+	}
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.keys.Key(ctx, kid)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return &claims, nil
+}
@@ -0,0 +1,137 @@
+package routing
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type staticKeySet map[string]crypto.PublicKey
+
+func (s staticKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok := s[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: no signing key with id %q", domain.ErrNotFound, kid)
+	}
+	return key, nil
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keys := staticKeySet{"current": &signingKey.PublicKey}
+	sign := func(kid string, key *ecdsa.PrivateKey, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return "Bearer " + signed
+	}
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":   "https://issuer.example.com",
+			"aud":   "products",
+			"sub":   "partner",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "products:write products:read",
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name              string
+		authorization     string
+		expectedStatus    int
+		expectedPrincipal string
+		expectedScopes    []string
+		expectedRoles     []domain.Role
+	}{
+		{
+			name:              "valid token",
+			authorization:     sign("current", signingKey, claims(nil)),
+			expectedStatus:    http.StatusOK,
+			expectedPrincipal: "partner",
+			expectedScopes:    []string{"products:read", "products:write"},
+		},
+		{
+			name:              "scopes as list",
+			authorization:     sign("current", signingKey, claims(jwt.MapClaims{"scope": nil, "scp": []string{"products:read"}})),
+			expectedStatus:    http.StatusOK,
+			expectedPrincipal: "partner",
+			expectedScopes:    []string{"products:read"},
+		},
+		{
+			name:              "roles claim",
+			authorization:     sign("current", signingKey, claims(jwt.MapClaims{"roles": []string{"admin", "superuser"}})),
+			expectedStatus:    http.StatusOK,
+			expectedPrincipal: "partner",
+			expectedScopes:    []string{"products:read", "products:write"},
+			expectedRoles:     []domain.Role{domain.RoleAdmin},
+		},
+		{name: "no token", expectedStatus: http.StatusOK},
+		{name: "other scheme", authorization: "Basic dXNlcjpwYXNz", expectedStatus: http.StatusUnauthorized},
+		{name: "malformed token", authorization: "Bearer not.a.token", expectedStatus: http.StatusUnauthorized},
+		{name: "expired", authorization: sign("current", signingKey, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})), expectedStatus: http.StatusUnauthorized},
+		{name: "no expiration", authorization: sign("current", signingKey, claims(jwt.MapClaims{"exp": nil})), expectedStatus: http.StatusUnauthorized},
+		{name: "other issuer", authorization: sign("current", signingKey, claims(jwt.MapClaims{"iss": "https://evil.example.com"})), expectedStatus: http.StatusUnauthorized},
+		{name: "other audience", authorization: sign("current", signingKey, claims(jwt.MapClaims{"aud": "billing"})), expectedStatus: http.StatusUnauthorized},
+		{name: "no subject", authorization: sign("current", signingKey, claims(jwt.MapClaims{"sub": nil})), expectedStatus: http.StatusUnauthorized},
+		{name: "unknown key", authorization: sign("retired", signingKey, claims(nil)), expectedStatus: http.StatusUnauthorized},
+		{name: "wrong signature", authorization: sign("current", otherKey, claims(nil)), expectedStatus: http.StatusUnauthorized},
+		{name: "unsigned", authorization: "Bearer " + mustUnsigned(t, claims(nil)), expectedStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var principal string
+			var scopes []string
+			var roles []domain.Role
+			handler := NewJWTAuthenticator(keys, "https://issuer.example.com", "products").Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal = PrincipalFromContext(r.Context())
+				scopes = ScopesFromContext(r.Context())
+				roles = RolesFromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/product", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			errs := domain.NewErrorContainer()
+			req = req.WithContext(context.WithValue(req.Context(), "errorContainer", &errs))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+				assert.Contains(t, rec.Body.String(), string(CodeUnauthenticated))
+				return
+			}
+			assert.Equal(t, tt.expectedPrincipal, principal)
+			assert.Equal(t, tt.expectedScopes, scopes)
+			assert.Equal(t, tt.expectedRoles, roles)
+		})
+	}
+}
+
+func mustUnsigned(t *testing.T, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	return token
+}
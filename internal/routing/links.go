@@ -0,0 +1,96 @@
+package routing
+
+import (
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type Link struct {
+	Href   string `json:"href"`
+	Method string `json:"method,omitempty"`
+}
+
+type Links map[string]Link
+
+// productLinks holds the same links as ProductLinks, as a struct it is
+// encoded without allocating per product. Fields are in alphabetical order,
+// so output matches encoded Links
+type productLinks struct {
+	Collection Link `json:"collection"`
+	Delete     Link `json:"delete"`
+	Self       Link `json:"self"`
+	Update     Link `json:"update"`
+}
+
+// productResource is product representation with hypermedia links
+type productResource struct {
+	domain.Product
+	Links productLinks `json:"_links"`
+}
+
+// LinkBuilder is the only place resource URLs are generated, so they follow
+// base path (e.g. "/api/v1" behind a proxy) wherever they appear
+type LinkBuilder struct {
+	basePath string
+}
+
+func NewLinkBuilder(basePath string) *LinkBuilder {
+	basePath = strings.TrimRight(basePath, "/")
+	if basePath != "" && !strings.HasPrefix(basePath, "/") {
+		basePath = "/" + basePath
+	}
+	return &LinkBuilder{basePath: basePath}
+}
+
+func (b *LinkBuilder) Collection() string {
+	return b.basePath + "/products"
+}
+
+// collectionAfter links page of the same listing after cursor
+func (b *LinkBuilder) collectionAfter(query url.Values, cursor string) string {
+	query = maps.Clone(query)
+	query.Set("after", cursor)
+	query.Del("offset")
+	return b.Collection() + "?" + query.Encode()
+}
+
+func (b *LinkBuilder) Product(id int64) string {
+	return b.basePath + "/product/" + strconv.FormatInt(id, 10)
+}
+
+func (b *LinkBuilder) ProductLinks(id int64) Links {
+	links := b.productLinks(id)
+	return Links{
+		"self":       links.Self,
+		"collection": links.Collection,
+		"update":     links.Update,
+		"delete":     links.Delete,
+	}
+}
+
+func (b *LinkBuilder) productLinks(id int64) productLinks {
+	self := b.Product(id)
+	return productLinks{
+		Collection: Link{Href: b.Collection()},
+		Delete:     Link{Href: self, Method: http.MethodDelete},
+		Self:       Link{Href: self},
+		Update:     Link{Href: self, Method: http.MethodPut},
+	}
+}
+
+func (b *LinkBuilder) productResource(product domain.Product) productResource {
+	return productResource{Product: product, Links: b.productLinks(product.Id)}
+}
+
+func (b *LinkBuilder) productResources(products []domain.Product) []productResource {
+	resources := make([]productResource, len(products))
+	for i, p := range products {
+		resources[i] = b.productResource(p)
+	}
+	return resources
+}
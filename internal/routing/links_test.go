@@ -0,0 +1,30 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkBuilderBasePath(t *testing.T) {
+	tests := []struct {
+		name       string
+		basePath   string
+		product    string
+		collection string
+	}{
+		{name: "no base path", basePath: "", product: "/product/5", collection: "/products"},
+		{name: "base path", basePath: "/api/v1", product: "/api/v1/product/5", collection: "/api/v1/products"},
+		{name: "trailing slash", basePath: "/api/v1/", product: "/api/v1/product/5", collection: "/api/v1/products"},
+		{name: "no leading slash", basePath: "api", product: "/api/product/5", collection: "/api/products"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links := NewLinkBuilder(tt.basePath).ProductLinks(5)
+			assert.Equal(t, Link{Href: tt.product}, links["self"])
+			assert.Equal(t, Link{Href: tt.collection}, links["collection"])
+			assert.Equal(t, Link{Href: tt.product, Method: "PUT"}, links["update"])
+			assert.Equal(t, Link{Href: tt.product, Method: "DELETE"}, links["delete"])
+		})
+	}
+}
@@ -4,54 +4,320 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"gopkg.in/natefinch/lumberjack.v2"
+
 	"github.com/pelyams/simpler_go_service/internal/domain"
 )
 
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
 type Logger struct {
-	requestCount uint64
-	file         *os.File
-	logger       *log.Logger
+	file   io.WriteCloser
+	output io.Writer
+	level  *slog.LevelVar
+	mu     sync.RWMutex
+	format string
+	logger *slog.Logger
+	// set while level is overridden for a while
+	override *levelOverride
 }
 
-func NewLogger(startingRequestId uint64, fileName string) (*Logger, error) {
-	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// levelOverride restores previous level once it expires
+type levelOverride struct {
+	previous slog.Level
+	expires  time.Time
+	timer    *time.Timer
+}
+
+// Rotation limits log file: once it grows over MaxSizeMB it is renamed
+// with a timestamp and a new file is started. Backups over MaxBackups or
+// older than MaxAge are removed, zero keeps them regardless
+type Rotation struct {
+	MaxSizeMB  int
+	MaxAge     time.Duration
+	MaxBackups int
+	// gzip backups
+	Compress bool
+}
+
+type LoggerOption func(*loggerOptions)
+
+type loggerOptions struct {
+	rotation *Rotation
+}
+
+// WithRotation rotates log file instead of appending to it forever
+func WithRotation(rotation Rotation) LoggerOption {
+	return func(o *loggerOptions) {
+		o.rotation = &rotation
+	}
+}
+
+func NewLogger(fileName string, opts ...LoggerOption) (*Logger, error) {
+	var options loggerOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	file, err := openLogFile(fileName, options.rotation)
 	if err != nil {
-		log.Fatalf("Failed to open log file: %v", err)
+		return nil, err
 	}
 	mw := io.MultiWriter(file, os.Stdout)
-	logger := log.New(mw, "", log.LstdFlags)
-	return &Logger{
-		requestCount: startingRequestId,
-		file:         file,
-		logger:       logger,
-	}, nil
+	l := &Logger{
+		file:   file,
+		output: mw,
+		level:  new(slog.LevelVar),
+	}
+	l.SetFormat(LogFormatText)
+	return l, nil
+}
+
+func openLogFile(fileName string, rotation *Rotation) (io.WriteCloser, error) {
+	if rotation == nil {
+		file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("logger error: failed to open log file: %w", err)
+		}
+		return file, nil
+	}
+	if rotation.MaxSizeMB <= 0 {
+		return nil, fmt.Errorf("logger error: max size of rotated log must be positive, got %d", rotation.MaxSizeMB)
+	}
+	file := &lumberjack.Logger{
+		Filename:   fileName,
+		MaxSize:    rotation.MaxSizeMB,
+		MaxBackups: rotation.MaxBackups,
+		Compress:   rotation.Compress,
+	}
+	if rotation.MaxAge > 0 {
+		// age is counted in days, partial ones round up
+		file.MaxAge = int((rotation.MaxAge + 24*time.Hour - 1) / (24 * time.Hour))
+	}
+	// fail on start rather than on first record if file can not be written
+	if _, err := file.Write(nil); err != nil {
+		return nil, fmt.Errorf("logger error: failed to open log file: %w", err)
+	}
+	return file, nil
 }
 
 func (l *Logger) Close() {
+	l.mu.Lock()
+	if l.override != nil {
+		l.override.timer.Stop()
+	}
+	l.mu.Unlock()
 	l.file.Close()
 }
 
-func (l *Logger) getNewRequestId() uint64 {
-	request_id := l.requestCount
-	l.requestCount++
-	return request_id
+// Level returns current minimal level of records being written
+func (l *Logger) Level() slog.Level {
+	return l.level.Level()
+}
+
+// SetLevel accepts debug, info, warn or error (case-insensitive). It ends
+// override of level, if any
+func (l *Logger) SetLevel(level string) error {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.override != nil {
+		l.override.timer.Stop()
+		l.override = nil
+	}
+	l.level.Set(lvl)
+	return nil
+}
+
+// SetLevelFor overrides level for d, e.g. to debug an incident, then
+// restores level set before the first of overrides in a row
+func (l *Logger) SetLevelFor(level string, d time.Duration) error {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("logger error: level override must last a positive duration, got %s", d)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	previous := l.level.Level()
+	if l.override != nil {
+		l.override.timer.Stop()
+		previous = l.override.previous
+	}
+	override := &levelOverride{previous: previous, expires: time.Now().Add(d)}
+	override.timer = time.AfterFunc(d, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.override == override {
+			l.level.Set(override.previous)
+			l.override = nil
+		}
+	})
+	l.override = override
+	l.level.Set(lvl)
+	return nil
+}
+
+// LevelExpires returns when overridden level is restored, zero time unless
+// level is overridden
+func (l *Logger) LevelExpires() time.Time {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.override == nil {
+		return time.Time{}
+	}
+	return l.override.expires
+}
+
+func parseLevel(level string) (slog.Level, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return lvl, fmt.Errorf("logger error: unknown log level %q", level)
+	}
+	return lvl, nil
+}
+
+func (l *Logger) Format() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.format
+}
+
+// SetFormat switches output between text and json handlers. Level is shared
+// between them, so switching format does not reset it
+func (l *Logger) SetFormat(format string) error {
+	format, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: l.level}
+	var handler slog.Handler
+	if format == LogFormatJSON {
+		handler = slog.NewJSONHandler(l.output, opts)
+	} else {
+		handler = slog.NewTextHandler(l.output, opts)
+	}
+	l.mu.Lock()
+	l.format = format
+	l.logger = slog.New(handler)
+	l.mu.Unlock()
+	return nil
+}
+
+func parseFormat(format string) (string, error) {
+	switch format = strings.ToLower(format); format {
+	case LogFormatText, LogFormatJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("logger error: unknown log format %q", format)
+}
+
+func (l *Logger) current() *slog.Logger {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.logger
+}
+
+// Slog returns a logger for components living outside of http middleware
+// (consumers, background jobs). It follows runtime level and format changes
+func (l *Logger) Slog() *slog.Logger {
+	return slog.New(&dynamicHandler{logger: l})
+}
+
+// dynamicHandler resolves the underlying handler on every record, replaying
+// accumulated attrs and groups on top of it
+type dynamicHandler struct {
+	logger *Logger
+	wrap   func(slog.Handler) slog.Handler
+}
+
+func (h *dynamicHandler) inner() slog.Handler {
+	handler := h.logger.current().Handler()
+	if h.wrap != nil {
+		handler = h.wrap(handler)
+	}
+	return handler
+}
+
+func (h *dynamicHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.logger.level.Level()
+}
+
+// Handle adds request id and trace of ctx, so logs of work done on behalf
+// of a request can be found by either
+func (h *dynamicHandler) Handle(ctx context.Context, record slog.Record) error {
+	requestId := RequestIdFromContext(ctx)
+	tc, traced := domain.TraceContextFromContext(ctx)
+	if requestId != "" || traced {
+		record = record.Clone()
+	}
+	if requestId != "" {
+		record.AddAttrs(slog.String("request_id", requestId))
+	}
+	if traced {
+		record.AddAttrs(traceAttrs(tc)...)
+	}
+	return h.inner().Handle(ctx, record)
 }
 
+func traceAttrs(tc domain.TraceContext) []slog.Attr {
+	return []slog.Attr{slog.String("trace_id", tc.TraceId), slog.String("span_id", tc.SpanId)}
+}
+
+func (h *dynamicHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.chain(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *dynamicHandler) WithGroup(name string) slog.Handler {
+	return h.chain(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *dynamicHandler) chain(next func(slog.Handler) slog.Handler) slog.Handler {
+	prev := h.wrap
+	return &dynamicHandler{
+		logger: h.logger,
+		wrap: func(handler slog.Handler) slog.Handler {
+			if prev != nil {
+				handler = prev(handler)
+			}
+			return next(handler)
+		},
+	}
+}
+
+// LoggerMiddleware writes a record per request once it is served, with
+// request id, method, path, status, duration and errors handlers attached
+// to request context as separate fields. Requests that failed with errors
+// or server error status are logged at error level. Request id is set by
+// RequestIdMiddleware, records of requests it did not see go without it
 func (l *Logger) LoggerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		req_id := l.getNewRequestId()
 		method := r.Method
 		path := r.URL.Path
 		started := time.Now()
 		errContainer := domain.NewErrorContainer()
 		ctx := context.WithValue(r.Context(), "errorContainer", &errContainer)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		rec := &countingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
 		duration := time.Since(started)
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
 		body := "none"
 		if method != "GET" && method != "DELETE" {
 			bodyBytes, err := io.ReadAll(r.Body)
@@ -65,26 +331,37 @@ func (l *Logger) LoggerMiddleware(next http.Handler) http.Handler {
 				body = string(bodyBytes)
 			}
 		}
+		logger := l.current()
+		var attrs []slog.Attr
+		if requestId := RequestIdFromContext(ctx); requestId != "" {
+			attrs = append(attrs, slog.String("request_id", requestId))
+		}
+		attrs = append(attrs,
+			slog.String("method", method),
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.String("body", body),
+			slog.Duration("duration", duration),
+		)
+		if principal := PrincipalFromContext(ctx); principal != "" {
+			attrs = append(attrs, slog.String("principal", principal))
+		}
+		if tc, ok := domain.TraceContextFromContext(ctx); ok {
+			attrs = append(attrs, traceAttrs(tc)...)
+		}
 		if errs := ctx.Value("errorContainer").(*domain.ErrorContainer); errs != nil && len(errs.Unwrap()) > 0 {
-			l.logger.Printf(
-				"Request: %d | ERROR | Method: %s | Path: %s | Body: %s | Duration: %v | Error(s):\n",
-				req_id,
-				method,
-				path,
-				body,
-				duration)
-			for i, error := range errs.Unwrap() {
-				l.logger.Printf(" %d. %v\n", i+1, error)
+			errMessages := make([]string, 0, len(errs.Unwrap()))
+			for _, err := range errs.Unwrap() {
+				errMessages = append(errMessages, err.Error())
 			}
+			attrs = append(attrs, slog.Any("errors", errMessages))
+			logger.LogAttrs(ctx, slog.LevelError, "request handled with errors", attrs...)
 			return
-		} else {
-			l.logger.Printf(
-				"Request: %d | OK | Method: %s | Path: %s | Body: %s | Duration: %v\n",
-				req_id,
-				method,
-				path,
-				body,
-				duration)
 		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(ctx, level, "request handled", attrs...)
 	})
 }
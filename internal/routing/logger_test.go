@@ -0,0 +1,117 @@
+package routing
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func TestLoggerMiddleware(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "test.log")
+	logger, err := NewLogger(logFile)
+	require.NoError(t, err)
+	defer logger.Close()
+	require.NoError(t, logger.SetFormat(LogFormatJSON))
+
+	handler := RequestIdMiddleware(logger.LoggerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/product/1" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		r.Context().Value("errorContainer").(*domain.ErrorContainer).Add(errors.New("db is down"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})))
+	req := httptest.NewRequest(http.MethodDelete, "/product/1", nil)
+	req.Header.Set("X-Request-ID", "req-7")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products", nil))
+
+	file, err := os.Open(logFile)
+	require.NoError(t, err)
+	defer file.Close()
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record), "every line is a JSON record")
+		records = append(records, record)
+	}
+	require.Len(t, records, 2)
+
+	assert.Equal(t, "INFO", records[0]["level"])
+	assert.Equal(t, "request handled", records[0]["msg"])
+	assert.Equal(t, "req-7", records[0]["request_id"])
+	assert.Equal(t, "DELETE", records[0]["method"])
+	assert.Equal(t, "/product/1", records[0]["path"])
+	assert.EqualValues(t, http.StatusNoContent, records[0]["status"])
+	assert.Contains(t, records[0], "duration")
+	assert.NotContains(t, records[0], "errors")
+
+	assert.Equal(t, "ERROR", records[1]["level"])
+	assert.Equal(t, rec.Header().Get("X-Request-ID"), records[1]["request_id"])
+	assert.EqualValues(t, http.StatusServiceUnavailable, records[1]["status"])
+	assert.Equal(t, []interface{}{"db is down"}, records[1]["errors"])
+}
+
+func TestLogRotation(t *testing.T) {
+	dir := t.TempDir()
+	file, err := openLogFile(filepath.Join(dir, "app.log"), &Rotation{MaxSizeMB: 1, MaxBackups: 1})
+	require.NoError(t, err)
+	defer file.Close()
+
+	line := []byte(strings.Repeat("x", 1023) + "\n")
+	for range 3 * 1024 {
+		_, err := file.Write(line)
+		require.NoError(t, err)
+	}
+
+	// backups are removed in background
+	require.Eventually(t, func() bool {
+		entries, err := os.ReadDir(dir)
+		return err == nil && len(entries) == 2
+	}, time.Second, 10*time.Millisecond, "current file and a single backup are kept")
+	info, err := os.Stat(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(1<<20))
+
+	_, err = openLogFile(filepath.Join(dir, "app.log"), &Rotation{})
+	assert.Error(t, err, "size limit is required")
+}
+
+func TestLevelOverride(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	require.NoError(t, logger.SetLevel("warn"))
+
+	require.NoError(t, logger.SetLevelFor("debug", time.Hour))
+	require.NoError(t, logger.SetLevelFor("info", 20*time.Millisecond))
+	assert.Equal(t, slog.LevelInfo, logger.Level())
+	assert.False(t, logger.LevelExpires().IsZero())
+	require.Eventually(t, func() bool {
+		return logger.Level() == slog.LevelWarn
+	}, time.Second, 5*time.Millisecond, "level set before overrides is restored")
+	assert.True(t, logger.LevelExpires().IsZero())
+
+	require.NoError(t, logger.SetLevelFor("debug", 20*time.Millisecond))
+	require.NoError(t, logger.SetLevel("error"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, slog.LevelError, logger.Level(), "setting level ends override")
+
+	assert.Error(t, logger.SetLevelFor("debug", 0))
+	assert.Error(t, logger.SetLevelFor("verbose", time.Minute))
+}
@@ -0,0 +1,90 @@
+package routing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type memoEntry struct {
+	done       chan struct{}
+	body       []byte
+	serviceErr *domain.ServiceError
+	// leader was canceled or panicked, waiters do not get its result
+	abandoned bool
+}
+
+// listMemo coalesces identical list requests. First one computes encoded
+// response, ones arriving while it runs or within window after it is done
+// get the same body. Dashboards fan out hundreds of identical calls at
+// once, this way Postgres is asked once per window
+type listMemo struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*memoEntry
+}
+
+func newListMemo(window time.Duration) *listMemo {
+	return &listMemo{window: window, entries: make(map[string]*memoEntry)}
+}
+
+// do returns memoized response for key or computes it. Failures are shared
+// only with requests already waiting and are not kept for the window.
+// Waiters whose leader was canceled compute response themselves
+func (m *listMemo) do(ctx context.Context, key string, compute func(ctx context.Context) ([]byte, *domain.ServiceError)) ([]byte, *domain.ServiceError) {
+	for {
+		m.mu.Lock()
+		entry, ok := m.entries[key]
+		if !ok {
+			entry = &memoEntry{done: make(chan struct{})}
+			m.entries[key] = entry
+			m.mu.Unlock()
+			return m.lead(ctx, key, entry, compute)
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, domain.NewServiceError(ctx.Err(), nil)
+		}
+		if !entry.abandoned {
+			return entry.body, entry.serviceErr
+		}
+	}
+}
+
+// lead computes response for entry. Non-critical errors, such as cache
+// misses, are reported to leader only
+func (m *listMemo) lead(ctx context.Context, key string, entry *memoEntry, compute func(ctx context.Context) ([]byte, *domain.ServiceError)) ([]byte, *domain.ServiceError) {
+	entry.abandoned = true
+	defer func() {
+		if entry.abandoned || entry.serviceErr != nil {
+			m.forget(key, entry)
+		} else {
+			time.AfterFunc(m.window, func() { m.forget(key, entry) })
+		}
+		close(entry.done)
+	}()
+
+	body, serviceErr := compute(ctx)
+	if serviceErr != nil && serviceErr.CriticalError != nil {
+		entry.serviceErr = serviceErr
+		entry.abandoned = ctx.Err() != nil
+		return body, serviceErr
+	}
+	entry.body = body
+	entry.abandoned = false
+	return body, serviceErr
+}
+
+func (m *listMemo) forget(key string, entry *memoEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries[key] == entry {
+		delete(m.entries, key)
+	}
+}
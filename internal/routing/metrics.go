@@ -0,0 +1,44 @@
+package routing
+
+import (
+	"net/http"
+
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// unmatchedRoute labels requests no route matched, so that scanners do not
+// make a metric per path they probe
+const unmatchedRoute = "unmatched"
+
+// MetricsMiddleware records count, duration and in-flight requests by
+// route, which route tells of request
+func MetricsMiddleware(metrics ports.RequestMetrics, route func(r *http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done := metrics.RequestStarted(route(r), r.Method)
+		rec := &countingResponseWriter{ResponseWriter: w}
+		defer func() {
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			done(status)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// WithMetrics exposes http, cache, database and runtime metrics in
+// Prometheus text format at /metrics
+func WithMetrics(handler http.Handler) AdminOption {
+	return func(h *AdminHandler) {
+		h.metrics = handler
+	}
+}
+
+func (h *AdminHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	if h.metrics == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Metrics are not configured")
+		return
+	}
+	h.metrics.ServeHTTP(w, r)
+}
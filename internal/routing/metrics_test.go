@@ -0,0 +1,86 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+// recordingMetrics keeps "route method status" of finished requests
+type recordingMetrics struct {
+	mu       sync.Mutex
+	requests []string
+	inFlight int
+}
+
+func (m *recordingMetrics) RequestStarted(route string, method string) func(status int) {
+	m.mu.Lock()
+	m.inFlight++
+	m.mu.Unlock()
+	return func(status int) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.inFlight--
+		m.requests = append(m.requests, strings.Join([]string{route, method, http.StatusText(status)}, " "))
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	svc := service.NewResourceService(fakes.NewRepository(), fakes.NewCache())
+	routes := NewRouter(NewProductHandler(svc))
+	metrics := &recordingMetrics{}
+	router := MetricsMiddleware(metrics, routes.Route, logger.LoggerMiddleware(routes.SetupRoutes()))
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/product/1"},
+		{http.MethodGet, "/product/2"},
+		{http.MethodPost, "/product"},
+		{http.MethodGet, "/products?limit=1&offset=0"},
+		{http.MethodGet, "/products/export?format=xlsx"},
+		{http.MethodGet, "/wp-login.php"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, strings.NewReader("{}")))
+	}
+
+	assert.Equal(t, []string{
+		"/product/{id} GET Not Found",
+		"/product/{id} GET Not Found",
+		"/product POST Bad Request",
+		"/products GET OK",
+		"/products/export GET OK",
+		"unmatched GET Not Found",
+	}, metrics.requests)
+	assert.Zero(t, metrics.inFlight)
+}
+
+func TestServeMetrics(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	exposition := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("http_requests_total 1\n"))
+	})
+
+	router := logger.LoggerMiddleware(NewAdminRouter(NewAdminHandler(logger, WithMetrics(exposition))).SetupRoutes())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "http_requests_total 1\n", rec.Body.String())
+
+	router = logger.LoggerMiddleware(NewAdminRouter(NewAdminHandler(logger)).SetupRoutes())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+}
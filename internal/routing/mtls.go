@@ -0,0 +1,73 @@
+package routing
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type principalKey struct{}
+
+// WithPrincipal stores identity of an authenticated caller in context, and
+// attributes changes the request makes to it
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return domain.WithActor(context.WithValue(ctx, principalKey{}, principal), principal)
+}
+
+// PrincipalFromContext returns identity of an authenticated caller, empty if
+// request was not authenticated
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// NewClientCertTLSConfig requires clients to present a certificate signed by
+// one of CAs in caFile. If allowedSubjects is not empty, common name of
+// client certificate must be one of them
+func NewClientCertTLSConfig(caFile string, allowedSubjects []string) (*tls.Config, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA file %q", caFile)
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+		VerifyPeerCertificate: func(_ [][]byte, chains [][]*x509.Certificate) error {
+			if len(allowedSubjects) == 0 {
+				return nil
+			}
+			if len(chains) == 0 || len(chains[0]) == 0 {
+				return errors.New("no verified client certificate")
+			}
+			subject := chains[0][0].Subject.CommonName
+			if !slices.Contains(allowedSubjects, subject) {
+				return fmt.Errorf("client certificate subject %q is not allowed", subject)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// ClientCertMiddleware makes common name of verified client certificate the
+// principal of request. Requests without one are passed as they are
+func ClientCertMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			principal := r.TLS.VerifiedChains[0][0].Subject.CommonName
+			r = r.WithContext(WithPrincipal(r.Context(), principal))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
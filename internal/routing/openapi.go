@@ -0,0 +1,72 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// OpenAPIJSON converts OpenAPI document from YAML to JSON. Servers are
+// replaced with base path, so the document describes the deployment
+// serving it
+func OpenAPIJSON(spec []byte, basePath string) ([]byte, error) {
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(spec, &document); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse OpenAPI document: %s", domain.ErrInvalidInput, err.Error())
+	}
+	if _, ok := document["openapi"]; !ok {
+		return nil, fmt.Errorf("%w: OpenAPI document lacks openapi version", domain.ErrInvalidInput)
+	}
+	server := NewLinkBuilder(basePath).basePath
+	if server == "" {
+		server = "/"
+	}
+	document["servers"] = []map[string]string{{"url": server}}
+	return json.Marshal(document)
+}
+
+// WithOpenAPI serves OpenAPI document, already converted to JSON, at
+// /openapi.json. swaggerUI adds /docs page rendering it
+func WithOpenAPI(document []byte, swaggerUI bool) HandlerOption {
+	return func(h *ProductHandler) {
+		h.openAPI = document
+		h.swaggerUI = swaggerUI
+	}
+}
+
+func (h *ProductHandler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(h.openAPI)
+}
+
+// swagger UI is loaded from CDN, the service only serves page pointing it
+// to the document
+var swaggerPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Simpler REST service</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: {{.}}, dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`))
+
+func (h *ProductHandler) GetDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	swaggerPage.Execute(w, h.links.basePath+"/openapi.json")
+}
@@ -0,0 +1,76 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/api"
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestOpenAPIJSON(t *testing.T) {
+	document, err := OpenAPIJSON(api.OpenAPI, "api/v1/")
+	require.NoError(t, err)
+	var parsed struct {
+		OpenAPI string                     `json:"openapi"`
+		Servers []map[string]string        `json:"servers"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(document, &parsed))
+	assert.Equal(t, "3.1.0", parsed.OpenAPI)
+	assert.Equal(t, []map[string]string{{"url": "/api/v1"}}, parsed.Servers)
+	for _, path := range []string{"/products", "/products/search", "/products/export", "/product", "/product/{id}", "/batch", "/graphql"} {
+		assert.Contains(t, parsed.Paths, path)
+	}
+
+	for _, spec := range []string{"openapi: [", "info: {}"} {
+		_, err := OpenAPIJSON([]byte(spec), "")
+		assert.ErrorIs(t, err, domain.ErrInvalidInput, spec)
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	document, err := OpenAPIJSON(api.OpenAPI, "")
+	require.NoError(t, err)
+	svc := service.NewResourceService(fakes.NewRepository(), fakes.NewCache())
+	newRouter := func(opts ...HandlerOption) http.Handler {
+		return logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, opts...)).SetupRoutes())
+	}
+
+	router := newRouter(WithOpenAPI(document, true), WithResponseEnvelope(true))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, string(document), rec.Body.String(), "document is not enveloped")
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `url: "/openapi.json"`)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/openapi.json", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	router = newRouter(WithOpenAPI(document, false))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code, "swagger UI is optional")
+
+	router = newRouter()
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
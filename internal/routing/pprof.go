@@ -0,0 +1,35 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// WithProfiling serves net/http/pprof handlers under /debug/pprof/. Being on
+// admin router they are only reachable through admin port
+func WithProfiling(enabled bool) AdminOption {
+	return func(h *AdminHandler) {
+		h.profiling = enabled
+	}
+}
+
+// setupProfiling registers pprof handlers. Index also serves named
+// profiles, e.g. /debug/pprof/heap or /debug/pprof/goroutine?debug=2
+func setupProfiling(mux *http.ServeMux) {
+	mux.Handle("/debug/pprof/", methods{
+		http.MethodGet: pprof.Index,
+	})
+	mux.Handle("/debug/pprof/cmdline", methods{
+		http.MethodGet: pprof.Cmdline,
+	})
+	mux.Handle("/debug/pprof/profile", methods{
+		http.MethodGet: pprof.Profile,
+	})
+	mux.Handle("/debug/pprof/symbol", methods{
+		http.MethodGet:  pprof.Symbol,
+		http.MethodPost: pprof.Symbol,
+	})
+	mux.Handle("/debug/pprof/trace", methods{
+		http.MethodGet: pprof.Trace,
+	})
+}
@@ -0,0 +1,100 @@
+package routing
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/pelyams/simpler_go_service/api/productpb"
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+var protobufMediaTypes = []string{"application/x-protobuf", "application/protobuf"}
+
+// wantsProtobuf reports whether client prefers protobuf to JSON. Only
+// products and product lists are encoded, see api/proto/product.proto,
+// other responses, errors included, stay JSON
+func wantsProtobuf(r *http.Request) bool {
+	_, ok := preferredToJSON(r.Header.Get("Accept"), protobufMediaTypes)
+	return ok
+}
+
+// ProductMessage is product as served over protobuf, by REST as well as
+// gRPC, so that both carry the same fields
+func ProductMessage(product domain.Product) *productpb.Product {
+	message := &productpb.Product{
+		Id:             product.Id,
+		Name:           product.Name,
+		AdditionalInfo: product.AdditionalInfo,
+		Version:        product.Version,
+		CategoryId:     product.CategoryId,
+		Sku:            product.Sku,
+		Stock:          product.Stock,
+		Tags:           product.Tags,
+	}
+	for _, image := range product.Images {
+		message.Images = append(message.Images, &productpb.ProductImage{
+			Id:          image.Id,
+			Url:         image.URL,
+			ContentType: image.ContentType,
+			Size:        image.Size,
+			CreatedAt:   image.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return message
+}
+
+func productListMessage(products []domain.Product) *productpb.ProductList {
+	list := &productpb.ProductList{Products: make([]*productpb.Product, len(products))}
+	for i, p := range products {
+		list.Products[i] = ProductMessage(p)
+	}
+	return list
+}
+
+// appendProductListItem appends product to encoded ProductList. Repeated
+// fields of concatenated messages are merged, so list can be streamed
+// product by product
+func appendProductListItem(b []byte, product domain.Product) ([]byte, error) {
+	message, err := proto.Marshal(ProductMessage(product))
+	if err != nil {
+		return b, err
+	}
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	return protowire.AppendBytes(b, message), nil
+}
+
+func writeProtobuf(w http.ResponseWriter, r *http.Request, status int, message proto.Message) {
+	body, err := proto.Marshal(message)
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", protobufMediaTypes[0])
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// writeProduct writes product as protobuf or as JSON resource with links,
+// whichever client prefers
+func (h *ProductHandler) writeProduct(w http.ResponseWriter, r *http.Request, status int, product domain.Product) {
+	if wantsProtobuf(r) {
+		writeProtobuf(w, r, status, ProductMessage(product))
+		return
+	}
+	h.json.write(w, status, h.links.productResource(product))
+}
+
+// writeProducts writes product list as protobuf or as JSON array of
+// resources with links, whichever client prefers
+func (h *ProductHandler) writeProducts(w http.ResponseWriter, r *http.Request, products []domain.Product) {
+	if wantsProtobuf(r) {
+		writeProtobuf(w, r, http.StatusOK, productListMessage(products))
+		return
+	}
+	h.json.write(w, http.StatusOK, h.links.productResources(products))
+}
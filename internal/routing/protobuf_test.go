@@ -0,0 +1,154 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/pelyams/simpler_go_service/api/productpb"
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestProtobufResponses(t *testing.T) {
+	// enough products for streamed listing to be sent in several chunks
+	products := testhelpers.GenerateProducts(2000)
+	for i := range products {
+		products[i].Id = int64(i + 1)
+	}
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	svc := service.NewResourceService(fakes.NewRepository(products...), fakes.NewCache())
+	routers := map[string]http.Handler{
+		"plain":    logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, WithResponseEnvelope(true))).SetupRoutes()),
+		"memoized": logger.LoggerMiddleware(NewRouter(NewProductHandler(svc, WithListMemo(time.Minute))).SetupRoutes()),
+	}
+	get := func(router http.Handler, path string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	ids := func(list *productpb.ProductList) []int64 {
+		result := []int64{}
+		for _, p := range list.Products {
+			result = append(result, p.Id)
+		}
+		return result
+	}
+
+	for name, router := range routers {
+		rec := get(router, "/product/2", "application/x-protobuf")
+		require.Equal(t, http.StatusOK, rec.Code, name)
+		assert.Equal(t, "application/x-protobuf", rec.Header().Get("Content-Type"), name)
+		var product productpb.Product
+		require.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &product), name)
+		assert.Equal(t, products[1].Name, product.Name, name)
+		assert.Equal(t, products[1].AdditionalInfo, product.AdditionalInfo, name)
+
+		for path, expected := range map[string][]int64{
+			"/products?limit=3&offset=1":   {2, 3, 4},
+			"/products?after=1997&limit=5": {1998, 1999, 2000},
+		} {
+			for range 2 {
+				rec = get(router, path, "application/protobuf, application/json;q=0.5")
+				require.Equal(t, http.StatusOK, rec.Code, name+path)
+				assert.Equal(t, "application/x-protobuf", rec.Header().Get("Content-Type"), name+path)
+				var list productpb.ProductList
+				require.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &list), name+path)
+				assert.Equal(t, expected, ids(&list), name+path)
+			}
+		}
+
+		rec = get(router, "/products", "application/x-protobuf")
+		require.Equal(t, http.StatusOK, rec.Code, name)
+		var list productpb.ProductList
+		require.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &list), name)
+		require.Len(t, list.Products, len(products), name)
+		assert.Equal(t, products[1999].Name, list.Products[1999].Name, name)
+
+		rec = get(router, "/product/0", "application/x-protobuf")
+		assert.Equal(t, http.StatusNotFound, rec.Code, name)
+		assert.Contains(t, rec.Body.String(), string(CodeProductNotFound), "errors stay JSON")
+	}
+
+	// JSON is not displaced by memoized protobuf page
+	rec := get(routers["memoized"], "/products?limit=3&offset=1", "application/json")
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	rec = get(routers["plain"], "/product/2", "application/x-protobuf;q=0.5, application/json")
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "/product/2", nil)
+	req.Header.Set("Accept", "application/x-protobuf")
+	routers["plain"].ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var deleted productpb.Product
+	require.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &deleted))
+	assert.Equal(t, int64(2), deleted.Id)
+	_, serviceErr := svc.GetProductById(req.Context(), 2)
+	assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrNotFound)
+}
+
+// TestProductMessageDrift fails once domain.Product, or its images, gets a
+// field protobuf messages lack, or ProductMessage leaves one out
+func TestProductMessageDrift(t *testing.T) {
+	jsonFields := func(v interface{}) []string {
+		var fields []string
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if name != "-" {
+				fields = append(fields, name)
+			}
+		}
+		return fields
+	}
+	protoFields := func(message proto.Message) []string {
+		var fields []string
+		descriptor := message.ProtoReflect().Descriptor().Fields()
+		for i := 0; i < descriptor.Len(); i++ {
+			fields = append(fields, descriptor.Get(i).JSONName())
+		}
+		return fields
+	}
+	assert.ElementsMatch(t, jsonFields(domain.Product{}), protoFields(&productpb.Product{}))
+	assert.ElementsMatch(t, jsonFields(domain.ProductImage{}), protoFields(&productpb.ProductImage{}))
+
+	createdAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	message := ProductMessage(domain.Product{
+		Id:             1,
+		Name:           "lamp",
+		AdditionalInfo: "brass",
+		Version:        3,
+		CategoryId:     2,
+		Sku:            "LMP-1",
+		Stock:          5,
+		Tags:           []string{"desk", "light"},
+		Images:         []domain.ProductImage{{Id: 4, URL: "https://cdn/4.png", ContentType: "image/png", Size: 100, CreatedAt: createdAt, Key: "4.png"}},
+	})
+	assertAllSet := func(message protoreflect.Message) {
+		fields := message.Descriptor().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			assert.True(t, message.Has(fields.Get(i)), "%s is not mapped", fields.Get(i).FullName())
+		}
+	}
+	assertAllSet(message.ProtoReflect())
+	require.Len(t, message.Images, 1)
+	assertAllSet(message.Images[0].ProtoReflect())
+	assert.Equal(t, "2026-10-01T12:00:00Z", message.Images[0].CreatedAt)
+}
@@ -0,0 +1,152 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// defaultRateLimitTier applies to clients not assigned a tier, including
+// anonymous ones
+const defaultRateLimitTier = "default"
+
+// RateLimiter refuses requests of clients exceeding rate of their tier.
+// Clients are told apart by principal, anonymous ones by address, so it
+// has to run after authentication
+type RateLimiter struct {
+	limiter     ports.RateLimiter
+	tiers       map[string]domain.RateLimit
+	clientTiers map[string]string
+}
+
+// NewRateLimiter requires "default" tier, clientTiers map principals to
+// other tiers
+func NewRateLimiter(limiter ports.RateLimiter, tiers map[string]domain.RateLimit, clientTiers map[string]string) (*RateLimiter, error) {
+	if _, ok := tiers[defaultRateLimitTier]; !ok {
+		return nil, fmt.Errorf("rate limit tier %q is required", defaultRateLimitTier)
+	}
+	for client, tier := range clientTiers {
+		if _, ok := tiers[tier]; !ok {
+			return nil, fmt.Errorf("unknown rate limit tier %q of client %q", tier, client)
+		}
+	}
+	return &RateLimiter{limiter: limiter, tiers: tiers, clientTiers: clientTiers}, nil
+}
+
+// ParseRateLimitTiers parses "tier:requests/period[/burst],..." entries,
+// e.g. "default:100/1m,premium:1000/1m/200". Burst defaults to requests
+func ParseRateLimitTiers(s string) (map[string]domain.RateLimit, error) {
+	tiers := make(map[string]domain.RateLimit)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tier, spec, ok := strings.Cut(entry, ":")
+		if !ok || tier == "" {
+			return nil, fmt.Errorf("invalid rate limit tier %q", entry)
+		}
+		parts := strings.Split(spec, "/")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid rate limit tier %q", entry)
+		}
+		requests, err := strconv.Atoi(parts[0])
+		if err != nil || requests <= 0 {
+			return nil, fmt.Errorf("invalid requests of rate limit tier %q", entry)
+		}
+		period, err := time.ParseDuration(parts[1])
+		if err != nil || period < time.Duration(requests)*time.Microsecond {
+			return nil, fmt.Errorf("invalid period of rate limit tier %q", entry)
+		}
+		burst := requests
+		if len(parts) == 3 {
+			burst, err = strconv.Atoi(parts[2])
+			if err != nil || burst <= 0 {
+				return nil, fmt.Errorf("invalid burst of rate limit tier %q", entry)
+			}
+		}
+		tiers[tier] = domain.RateLimit{Requests: requests, Period: period, Burst: burst}
+	}
+	if len(tiers) == 0 {
+		return nil, errors.New("no rate limit tiers provided")
+	}
+	return tiers, nil
+}
+
+// ParseClientTiers parses "client:tier,..." pairs
+func ParseClientTiers(s string) (map[string]string, error) {
+	clientTiers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		client, tier, ok := strings.Cut(pair, ":")
+		if !ok || client == "" || tier == "" {
+			return nil, fmt.Errorf("invalid client tier entry %q", pair)
+		}
+		clientTiers[client] = tier
+	}
+	return clientTiers, nil
+}
+
+// rateLimitKey identifies client and limit it is counted against, so
+// changing tier of a client starts afresh
+func (l *RateLimiter) rateLimitKey(r *http.Request) (string, domain.RateLimit) {
+	principal := PrincipalFromContext(r.Context())
+	if principal == "" {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return defaultRateLimitTier + ":ip:" + host, l.tiers[defaultRateLimitTier]
+	}
+	tier, ok := l.clientTiers[principal]
+	if !ok {
+		tier = defaultRateLimitTier
+	}
+	return tier + ":client:" + principal, l.tiers[tier]
+}
+
+// seconds rounds d up, so clients waiting that long are not refused again
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// Middleware reports limit of client in RateLimit-* headers and answers
+// requests over it with 429. Requests are let through if limiter fails,
+// so Redis outage does not take API down
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, limit := l.rateLimitKey(r)
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second)
+		decision, err := l.limiter.Allow(ctx, key, limit)
+		cancel()
+		if err != nil {
+			if errContainer, ok := r.Context().Value("errorContainer").(*domain.ErrorContainer); ok {
+				errContainer.Add(fmt.Errorf("rate limit error: %w", err))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		w.Header().Set("RateLimit-Reset", seconds(decision.ResetAfter))
+		if !decision.Allowed {
+			w.Header().Set("Retry-After", seconds(decision.RetryAfter))
+			writeError(w, http.StatusTooManyRequests, CodeRateLimited, "Rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,91 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestParseRateLimitTiers(t *testing.T) {
+	tiers, err := ParseRateLimitTiers("default:100/1m, premium:1000/1m/200")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]domain.RateLimit{
+		"default": {Requests: 100, Period: time.Minute, Burst: 100},
+		"premium": {Requests: 1000, Period: time.Minute, Burst: 200},
+	}, tiers)
+
+	for _, s := range []string{"", "default", "default:100", "default:0/1m", "default:100/minute", "default:100/1m/0", "default:100/1m/10/1"} {
+		_, err := ParseRateLimitTiers(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	tiers := map[string]domain.RateLimit{"premium": {Requests: 10, Period: time.Second, Burst: 10}}
+	_, err := NewRateLimiter(fakes.NewRateLimiter(), tiers, nil)
+	assert.Error(t, err, "default tier is required")
+
+	tiers["default"] = domain.RateLimit{Requests: 1, Period: time.Second, Burst: 1}
+	_, err = NewRateLimiter(fakes.NewRateLimiter(), tiers, map[string]string{"shop": "gold"})
+	assert.Error(t, err, "client tier must exist")
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	store := fakes.NewRateLimiter()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	store.SetClock(func() time.Time { return now })
+	limiter, err := NewRateLimiter(store, map[string]domain.RateLimit{
+		"default": {Requests: 1, Period: 10 * time.Second, Burst: 1},
+		"premium": {Requests: 2, Period: 10 * time.Second, Burst: 2},
+	}, map[string]string{"shop": "premium"})
+	require.NoError(t, err)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	handler := logger.LoggerMiddleware(limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	serve := func(principal string, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.RemoteAddr = remoteAddr
+		if principal != "" {
+			req = req.WithContext(WithPrincipal(req.Context(), principal))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("shop", "10.0.0.1:1234")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "5", rec.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, http.StatusNoContent, serve("shop", "10.0.0.2:1234").Code, "premium tier allows burst of two")
+
+	rec = serve("shop", "10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "5", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Contains(t, rec.Body.String(), string(CodeRateLimited))
+
+	assert.Equal(t, http.StatusNoContent, serve("", "10.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve("", "10.0.0.1:4321").Code, "anonymous clients are told apart by address")
+	assert.Equal(t, http.StatusNoContent, serve("", "10.0.0.2:1234").Code)
+	assert.Equal(t, http.StatusNoContent, serve("other", "10.0.0.1:1234").Code, "clients without tier get default one")
+
+	now = now.Add(5 * time.Second)
+	assert.Equal(t, http.StatusNoContent, serve("shop", "10.0.0.1:1234").Code, "quota is restored over time")
+
+	store.FailWith("Allow", domain.ErrInternalCache)
+	assert.Equal(t, http.StatusNoContent, serve("shop", "10.0.0.1:1234").Code, "failing limiter lets requests through")
+}
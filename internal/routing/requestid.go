@@ -0,0 +1,52 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const requestIdHeader = "X-Request-ID"
+
+// maxRequestIdLength bounds ids taken from callers, which end up in logs
+const maxRequestIdLength = 128
+
+type requestIdKey struct{}
+
+// RequestIdFromContext returns id of request being served, empty outside of
+// RequestIdMiddleware
+func RequestIdFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// RequestIdMiddleware identifies request by X-Request-ID of caller, so that
+// one request can be followed through proxies, or by a new UUID if caller
+// sent none or an unusable one. Id is put in request context and echoed in
+// response header before handlers run, so logs and error responses carry
+// it. It has to wrap logger middleware
+func RequestIdMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIdHeader)
+		if !validRequestId(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIdHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIdKey{}, id)))
+	})
+}
+
+// validRequestId accepts printable ASCII without spaces, any format of id
+// a proxy may use, but nothing that could break a log line
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
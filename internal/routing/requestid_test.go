@@ -0,0 +1,100 @@
+package routing
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestRequestIdMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		kept     bool
+	}{
+		{name: "caller's id is kept", incoming: "a1b2c3-proxy", kept: true},
+		{name: "no id", incoming: ""},
+		{name: "id with spaces", incoming: "a1b2 c3"},
+		{name: "too long id", incoming: strings.Repeat("a", maxRequestIdLength+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inContext string
+			handler := RequestIdMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				inContext = RequestIdFromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/products", nil)
+			if tt.incoming != "" {
+				req.Header.Set("X-Request-ID", tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, inContext, rec.Header().Get("X-Request-ID"))
+			if tt.kept {
+				assert.Equal(t, tt.incoming, inContext)
+				return
+			}
+			_, err := uuid.Parse(inContext)
+			assert.NoError(t, err, "new id is generated")
+		})
+	}
+
+	t.Run("concurrent requests get distinct ids", func(t *testing.T) {
+		ids := make(chan string, 100)
+		handler := RequestIdMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ids <- RequestIdFromContext(r.Context())
+		}))
+		done := make(chan struct{})
+		for range cap(ids) {
+			go func() {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products", nil))
+				done <- struct{}{}
+			}()
+		}
+		seen := make(map[string]bool)
+		for range cap(ids) {
+			<-done
+			seen[<-ids] = true
+		}
+		assert.Len(t, seen, cap(ids))
+	})
+}
+
+func TestRequestIdInErrors(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	logger.SetLevel(slog.LevelError.String())
+	svc := service.NewResourceService(fakes.NewRepository(), fakes.NewCache())
+	router := RequestIdMiddleware(logger.LoggerMiddleware(NewRouter(NewProductHandler(svc)).SetupRoutes()))
+
+	req := httptest.NewRequest(http.MethodGet, "/product/42", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	var body errorBody
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, "req-42", body.RequestId)
+
+	req = httptest.NewRequest(http.MethodGet, "/product/42", nil)
+	req.Header.Set("X-Request-ID", "req-43")
+	req.Header.Set("X-Response-Envelope", "true")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var wrapped envelope
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&wrapped))
+	assert.Equal(t, "req-43", wrapped.Meta.RequestId)
+}
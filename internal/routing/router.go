@@ -2,10 +2,41 @@ package routing
 
 import (
 	"net/http"
+	"slices"
+	"strings"
 )
 
+// methods routes request to handler of its method. OPTIONS is answered and
+// other methods get 405, both listing handled methods in Allow header
+type methods map[string]http.HandlerFunc
+
+func (m methods) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if handler, ok := m[r.Method]; ok {
+		handler(w, r)
+		return
+	}
+	w.Header().Set("Allow", m.allow())
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	methodNotAllowed(w)
+}
+
+func (m methods) allow() string {
+	allowed := make([]string, 0, len(m)+1)
+	for method := range m {
+		allowed = append(allowed, method)
+	}
+	slices.Sort(allowed)
+	return strings.Join(append(allowed, http.MethodOptions), ", ")
+}
+
 type Router struct {
 	handler *ProductHandler
+	// set up by SetupRoutes, Route looks requests up in them
+	root *http.ServeMux
+	mux  *http.ServeMux
 }
 
 func NewRouter(handler *ProductHandler) *Router {
@@ -17,39 +48,212 @@ func NewRouter(handler *ProductHandler) *Router {
 func (router *Router) SetupRoutes() http.Handler {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/products", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			router.handler.GetProducts(w, r)
-		case http.MethodDelete:
-			router.handler.DeleteAll(w, r)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
+	mux.Handle("/products", methods{
+		http.MethodGet:    router.handler.GetProducts,
+		http.MethodDelete: router.handler.DeleteAll,
 	})
 
-	mux.HandleFunc("/product", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPost:
-			router.handler.CreateProduct(w, r)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-		return
+	mux.Handle("/products/import", methods{
+		http.MethodPost: router.handler.ImportProducts,
+	})
+
+	mux.Handle("/products/search", methods{
+		http.MethodGet: router.handler.SearchProducts,
+	})
+
+	mux.Handle("/products/suggest", methods{
+		http.MethodGet: router.handler.SuggestProducts,
+	})
+
+	mux.Handle("/products/changes/wait", methods{
+		http.MethodGet: router.handler.WaitForChanges,
+	})
+
+	mux.Handle("/product", methods{
+		http.MethodPost: router.handler.CreateProduct,
+	})
+
+	mux.Handle("/product/{id}", methods{
+		http.MethodGet:    router.handler.GetProductById,
+		http.MethodPut:    router.handler.UpdateProduct,
+		http.MethodPatch:  router.handler.PatchProduct,
+		http.MethodDelete: router.handler.DeleteProduct,
 	})
 
-	mux.HandleFunc("/product/{id}", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			router.handler.GetProductById(w, r)
-		case http.MethodPut:
-			router.handler.UpdateProduct(w, r)
-		case http.MethodDelete:
-			router.handler.DeleteProduct(w, r)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	mux.Handle("/product/{id}/audit", methods{
+		http.MethodGet: router.handler.GetProductAudit,
+	})
+
+	mux.Handle("/product/{id}/tags", methods{
+		http.MethodPost: router.handler.AddProductTags,
+	})
+
+	mux.Handle("/product/{id}/tags/{tag}", methods{
+		http.MethodDelete: router.handler.RemoveProductTag,
+	})
+
+	mux.Handle("/product/{id}/stock-adjust", methods{
+		http.MethodPost: router.handler.AdjustProductStock,
+	})
+
+	mux.Handle("/product/{id}/images", methods{
+		http.MethodPost: router.handler.AddProductImage,
+	})
+
+	mux.Handle("/categories", methods{
+		http.MethodGet:  router.handler.GetCategories,
+		http.MethodPost: router.handler.CreateCategory,
+	})
+
+	mux.Handle("/categories/{id}", methods{
+		http.MethodGet:    router.handler.GetCategory,
+		http.MethodPut:    router.handler.UpdateCategory,
+		http.MethodDelete: router.handler.DeleteCategory,
+	})
+
+	mux.Handle("/batch", methods{
+		http.MethodPost: batchHandler(router.handler.authorize(mux)),
+	})
+
+	// exports are streamed, response middlewares would buffer them whole
+	root := http.NewServeMux()
+	root.Handle("/", envelopeMiddleware(router.handler.envelope,
+		jsonAPIMiddleware(router.handler.links, xmlMiddleware(decompressMiddleware(router.handler.maxBodyBytes, mux)))))
+	// lookup by sku would conflict with /product/{id}/... routes in mux, it
+	// is told apart from them here and served through the same middlewares
+	root.Handle("/product/sku/{sku}", envelopeMiddleware(router.handler.envelope,
+		jsonAPIMiddleware(router.handler.links, xmlMiddleware(decompressMiddleware(router.handler.maxBodyBytes, methods{
+			http.MethodGet: router.handler.GetProductBySku,
+		})))))
+	root.Handle("/products/export", methods{
+		http.MethodGet: router.handler.ExportProducts,
+	})
+	// GraphQL responses have their own shape, errors included
+	root.Handle("/graphql", decompressMiddleware(router.handler.maxBodyBytes, methods{
+		http.MethodPost: router.handler.PostGraphQL,
+	}))
+	// gateway responses follow protobuf JSON mapping, like GraphQL ones
+	// they are not translated
+	if router.handler.gateway != nil {
+		root.Handle("/v1/", decompressMiddleware(router.handler.maxBodyBytes, router.handler.gateway))
+	}
+	// documentation is not an API resource, it is neither enveloped nor
+	// translated to JSON:API
+	if router.handler.openAPI != nil {
+		root.Handle("/openapi.json", methods{
+			http.MethodGet: router.handler.GetOpenAPI,
+		})
+		if router.handler.swaggerUI {
+			root.Handle("/docs", methods{
+				http.MethodGet: router.handler.GetDocs,
+			})
 		}
+	}
+	router.root, router.mux = root, mux
+	return deprecationMiddleware(router.handler.deprecations, router.handler.authorize(root))
+}
+
+// Route returns pattern of route request matches, e.g. "/product/{id}",
+// to label metrics with. Call it once routes are set up
+func (router *Router) Route(r *http.Request) string {
+	if _, pattern := router.root.Handler(r); pattern != "/" && pattern != "" {
+		return pattern
+	}
+	if _, pattern := router.mux.Handler(r); pattern != "" {
+		return pattern
+	}
+	return unmatchedRoute
+}
+
+type AdminRouter struct {
+	handler *AdminHandler
+}
+
+func NewAdminRouter(handler *AdminHandler) *AdminRouter {
+	return &AdminRouter{
+		handler: handler,
+	}
+}
+
+func (router *AdminRouter) SetupRoutes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("/admin/log", methods{
+		http.MethodGet: router.handler.GetLogSettings,
+		http.MethodPut: router.handler.UpdateLogSettings,
+	})
+
+	mux.Handle("/admin/faults", methods{
+		http.MethodGet: router.handler.GetFaultRules,
+		http.MethodPut: router.handler.UpdateFaultRules,
+	})
+
+	mux.Handle("/metrics", methods{
+		http.MethodGet: router.handler.GetMetrics,
+	})
+
+	mux.Handle("/metrics/business", methods{
+		http.MethodGet: router.handler.GetBusinessMetrics,
+	})
+
+	mux.Handle("/admin/usage", methods{
+		http.MethodGet: router.handler.GetUsage,
+	})
+
+	mux.Handle("/admin/api-keys", methods{
+		http.MethodPost: router.handler.CreateAPIKey,
+	})
+
+	mux.Handle("/admin/api-keys/{id}", methods{
+		http.MethodDelete: router.handler.RevokeAPIKey,
 	})
 
+	mux.Handle("/admin/webhooks", methods{
+		http.MethodGet:  router.handler.GetWebhooks,
+		http.MethodPost: router.handler.CreateWebhook,
+	})
+
+	mux.Handle("/admin/webhooks/{id}", methods{
+		http.MethodDelete: router.handler.DeleteWebhook,
+	})
+
+	mux.Handle("/livez", methods{
+		http.MethodGet: router.handler.Live,
+	})
+
+	mux.Handle("/readyz", methods{
+		http.MethodGet: router.handler.Ready,
+	})
+
+	mux.Handle("/healthz", methods{
+		http.MethodGet: router.handler.Health,
+	})
+
+	mux.Handle("/admin/drain", methods{
+		http.MethodGet:  router.handler.GetDrainStatus,
+		http.MethodPost: router.handler.Drain,
+	})
+
+	mux.Handle("/admin/dump", methods{
+		http.MethodGet: router.handler.DumpProducts,
+	})
+
+	mux.Handle("/admin/restore", methods{
+		http.MethodPost: router.handler.RestoreProducts,
+	})
+
+	mux.Handle("/admin/jobs", methods{
+		http.MethodGet: router.handler.GetJobs,
+	})
+
+	mux.Handle("/admin/supplier-sync", methods{
+		http.MethodGet:  router.handler.GetSyncReport,
+		http.MethodPost: router.handler.RunSync,
+	})
+
+	if router.handler.profiling {
+		setupProfiling(mux)
+	}
+
 	return mux
 }
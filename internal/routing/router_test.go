@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestRouterMethods(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	handler := NewProductHandler(service.NewResourceService(fakes.NewRepository(), fakes.NewCache()), WithResponseEnvelope(true))
+	router := logger.LoggerMiddleware(NewRouter(handler).SetupRoutes())
+
+	for path, allow := range map[string]string{
+		"/products":         "DELETE, GET, OPTIONS",
+		"/product":          "POST, OPTIONS",
+		"/product/1":        "DELETE, GET, PATCH, PUT, OPTIONS",
+		"/products/export":  "GET, OPTIONS",
+		"/products/suggest": "GET, OPTIONS",
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, path, nil))
+		assert.Equal(t, http.StatusNoContent, rec.Code, path)
+		assert.Equal(t, allow, rec.Header().Get("Allow"), path)
+		assert.Empty(t, rec.Body.String(), path)
+
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("TRACE", path, nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code, path)
+		assert.Equal(t, allow, rec.Header().Get("Allow"), path)
+		assert.Contains(t, rec.Body.String(), string(CodeMethodNotAllowed), path)
+	}
+
+	admin := NewAdminRouter(NewAdminHandler(logger)).SetupRoutes()
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/log", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, PUT, OPTIONS", rec.Header().Get("Allow"))
+}
+
+func TestRouterGateway(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	gateway := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"1"}`))
+	})
+	authorizer := NewAuthorizer(domain.DefaultPolicy(), nil, domain.RoleWriter, domain.RoleReader)
+	svc := service.NewResourceService(fakes.NewRepository(), fakes.NewCache())
+	routes := NewRouter(NewProductHandler(svc, WithResponseEnvelope(true), WithAuthorizer(authorizer), WithGateway(gateway)))
+	router := logger.LoggerMiddleware(routes.SetupRoutes())
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/products/1", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"id":"1"}`, rec.Body.String(), "gateway responses are not enveloped")
+	assert.Equal(t, "/v1/", routes.Route(req))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/products", strings.NewReader(`{"name":"desk"}`)))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "gateway writes are authorized as other writes")
+
+	router = logger.LoggerMiddleware(NewRouter(NewProductHandler(svc)).SetupRoutes())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/products/1", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code, "gateway is only served if configured")
+}
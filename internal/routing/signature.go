@@ -0,0 +1,157 @@
+package routing
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+const (
+	clientIdHeader  = "X-Client-Id"
+	timestampHeader = "X-Timestamp"
+	signatureHeader = "X-Signature"
+)
+
+// SignatureVerifier authenticates requests signed with a secret shared with
+// the client: X-Signature is hex encoded HMAC-SHA256 of
+// "METHOD\nREQUEST_URI\nTIMESTAMP\nBODY", where timestamp is unix seconds
+// sent in X-Timestamp and client is identified by X-Client-Id. Requests
+// outside of replay window, as well as signatures already claimed in replay
+// store, are rejected. Bodies are read whole to be verified, up to
+// maxBodyBytes. Requests carrying neither X-Signature nor X-Client-Id are
+// left to other authenticators
+type SignatureVerifier struct {
+	secrets      map[string][]byte
+	replays      ports.SignatureReplayStore
+	window       time.Duration
+	maxBodyBytes int64
+	now          func() time.Time
+}
+
+func NewSignatureVerifier(secrets map[string]string, replays ports.SignatureReplayStore, window time.Duration, maxBodyBytes int64) *SignatureVerifier {
+	v := &SignatureVerifier{
+		secrets:      make(map[string][]byte, len(secrets)),
+		replays:      replays,
+		window:       window,
+		maxBodyBytes: maxBodyBytes,
+		now:          time.Now,
+	}
+	for client, secret := range secrets {
+		v.secrets[client] = []byte(secret)
+	}
+	return v
+}
+
+// ParseClientSecrets parses "client1:secret1,client2:secret2"
+func ParseClientSecrets(s string) (map[string]string, error) {
+	secrets := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		client, secret, ok := strings.Cut(pair, ":")
+		if !ok || client == "" || secret == "" {
+			return nil, fmt.Errorf("invalid client secret entry %q", pair)
+		}
+		secrets[client] = secret
+	}
+	if len(secrets) == 0 {
+		return nil, errors.New("no client secrets provided")
+	}
+	return secrets, nil
+}
+
+// Sign computes signature of a request, same as clients are expected to
+func Sign(secret []byte, method string, requestURI string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n", method, requestURI, timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (v *SignatureVerifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(signatureHeader) == "" && r.Header.Get(clientIdHeader) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		body, err := io.ReadAll(io.LimitReader(r.Body, v.maxBodyBytes+1))
+		if err == nil && int64(len(body)) > v.maxBodyBytes {
+			errContainer.Add(fmt.Errorf("signature error: %w: body exceeds %d bytes", domain.ErrInvalidInput, v.maxBodyBytes))
+			writeError(w, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "Request body is too large")
+			return
+		}
+		var replayKey string
+		var ttl time.Duration
+		if err == nil {
+			replayKey, ttl, err = v.verify(r, body)
+		}
+		if err != nil {
+			errContainer.Add(fmt.Errorf("signature error: %w", err))
+			writeError(w, http.StatusUnauthorized, CodeInvalidSignature, "Invalid request signature")
+			return
+		}
+		claimed, err := v.replays.Claim(r.Context(), replayKey, ttl)
+		if err != nil {
+			// replays cannot be told apart without the store
+			errContainer.Add(fmt.Errorf("signature error: %w", err))
+			writeServerError(w, r, err)
+			return
+		}
+		if !claimed {
+			errContainer.Add(errors.New("signature error: signature has already been used"))
+			writeError(w, http.StatusUnauthorized, CodeInvalidSignature, "Invalid request signature")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		// client certificate, if any, stays the principal
+		if PrincipalFromContext(r.Context()) == "" {
+			r = r.WithContext(WithPrincipal(r.Context(), r.Header.Get(clientIdHeader)))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// verify checks signature of request and returns key it is claimed by in
+// replay store, along with how long it has to be kept: until its timestamp
+// leaves replay window, after that it is rejected as expired anyway
+func (v *SignatureVerifier) verify(r *http.Request, body []byte) (string, time.Duration, error) {
+	client := r.Header.Get(clientIdHeader)
+	secret, ok := v.secrets[client]
+	if !ok {
+		return "", 0, fmt.Errorf("unknown client %q", client)
+	}
+	timestamp := r.Header.Get(timestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	now := v.now()
+	signedAt := time.Unix(seconds, 0)
+	if signedAt.Before(now.Add(-v.window)) || signedAt.After(now.Add(v.window)) {
+		return "", 0, fmt.Errorf("timestamp %s is outside of replay window", timestamp)
+	}
+	signature, err := hex.DecodeString(r.Header.Get(signatureHeader))
+	if err != nil {
+		return "", 0, errors.New("signature is not hex encoded")
+	}
+	expected, _ := hex.DecodeString(Sign(secret, r.Method, r.URL.RequestURI(), timestamp, body))
+	if !hmac.Equal(signature, expected) {
+		return "", 0, fmt.Errorf("signature mismatch for client %q", client)
+	}
+	// a second more, zero ttl would keep the key forever
+	return client + ":" + hex.EncodeToString(signature), signedAt.Add(v.window).Sub(now) + time.Second, nil
+}
@@ -0,0 +1,166 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestSignatureVerifier(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := `{"name":"New","additionalInfo":"Created"}`
+	signed := func(client, secret string, at time.Time, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/product?x=1", bytes.NewBufferString(body))
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		req.Header.Set(clientIdHeader, client)
+		req.Header.Set(timestampHeader, timestamp)
+		req.Header.Set(signatureHeader, Sign([]byte(secret), http.MethodPost, "/product?x=1", timestamp, []byte(body)))
+		return req
+	}
+
+	tests := []struct {
+		name           string
+		request        func() *http.Request
+		expectedStatus int
+		// passed on without principal
+		unsigned bool
+	}{
+		{
+			name:           "valid signature",
+			request:        func() *http.Request { return signed("partner", "secret", now, body) },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "unsigned request is passed on",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/product?x=1", bytes.NewBufferString(body))
+			},
+			expectedStatus: http.StatusOK,
+			unsigned:       true,
+		},
+		{
+			name: "client without signature",
+			request: func() *http.Request {
+				req := signed("partner", "secret", now, body)
+				req.Header.Del(signatureHeader)
+				return req
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "unknown client",
+			request:        func() *http.Request { return signed("stranger", "secret", now, body) },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "wrong secret",
+			request:        func() *http.Request { return signed("partner", "guess", now, body) },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "tampered body",
+			request: func() *http.Request {
+				req := signed("partner", "secret", now, body)
+				req.Body = io.NopCloser(bytes.NewBufferString(`{"name":"Evil","additionalInfo":"Created"}`))
+				return req
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "timestamp outside of window",
+			request:        func() *http.Request { return signed("partner", "secret", now.Add(-10*time.Minute), body) },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "body at limit",
+			request:        func() *http.Request { return signed("partner", "secret", now, strings.Repeat(" ", 64-len(body))+body) },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "body over limit",
+			request:        func() *http.Request { return signed("partner", "secret", now, strings.Repeat(" ", 65-len(body))+body) },
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewSignatureVerifier(map[string]string{"partner": "secret"}, fakes.NewSignatureReplayStore(), 5*time.Minute, 64)
+			verifier.now = func() time.Time { return now }
+			var received []byte
+			var principal string
+			handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received, _ = io.ReadAll(r.Body)
+				principal = PrincipalFromContext(r.Context())
+			}))
+
+			req := tt.request()
+			errs := domain.NewErrorContainer()
+			req = req.WithContext(context.WithValue(req.Context(), "errorContainer", &errs))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, body, strings.TrimLeft(string(received), " "), "body must be passed on intact")
+				if tt.unsigned {
+					assert.Empty(t, principal)
+				} else {
+					assert.Equal(t, "partner", principal)
+				}
+			}
+		})
+	}
+}
+
+func TestSignatureVerifierReplay(t *testing.T) {
+	replays := fakes.NewSignatureReplayStore()
+	verifier := NewSignatureVerifier(map[string]string{"partner": "secret"}, replays, time.Minute, 64)
+	handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	send := func() int {
+		req := httptest.NewRequest(http.MethodDelete, "/product/1", nil)
+		req.Header.Set(clientIdHeader, "partner")
+		req.Header.Set(timestampHeader, timestamp)
+		req.Header.Set(signatureHeader, Sign([]byte("secret"), http.MethodDelete, "/product/1", timestamp, nil))
+		errs := domain.NewErrorContainer()
+		req = req.WithContext(context.WithValue(req.Context(), "errorContainer", &errs))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, send())
+	assert.Equal(t, http.StatusUnauthorized, send(), "replayed request must be rejected")
+
+	// another instance sharing the store must reject it too
+	other := NewSignatureVerifier(map[string]string{"partner": "secret"}, replays, time.Minute, 64)
+	handler = other.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	assert.Equal(t, http.StatusUnauthorized, send())
+
+	replays.FailWith("Claim", domain.ErrInternalCache)
+	timestamp = strconv.FormatInt(time.Now().Unix()+1, 10)
+	assert.Equal(t, http.StatusInternalServerError, send(), "signatures must not be accepted unchecked")
+}
+
+func TestParseClientSecrets(t *testing.T) {
+	secrets, err := ParseClientSecrets("a:one, b:two:with:colons")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "one", "b": "two:with:colons"}, secrets)
+
+	_, err = ParseClientSecrets("a")
+	assert.Error(t, err)
+	_, err = ParseClientSecrets("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,23 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// GetProductBySku responds with product that has sku in path, just like
+// GetProductById does
+func (h *ProductHandler) GetProductBySku(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sku := r.PathValue("sku")
+	if err := domain.ValidateSku(sku); err != nil {
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(fmt.Errorf("handler error: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid SKU")
+		return
+	}
+	product, serviceErr := h.svc.GetProductBySku(readContext(r), sku)
+	h.writeRead(w, r, product, serviceErr)
+}
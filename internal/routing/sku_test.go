@@ -0,0 +1,86 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestProductSku(t *testing.T) {
+	repo := fakes.NewRepository(
+		domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Brass", Sku: "LMP-1"},
+		domain.Product{Id: 2, Name: "Chair", AdditionalInfo: "Oak"},
+	)
+	svc := service.NewResourceService(repo, fakes.NewCache())
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := NewRouter(NewProductHandler(svc))
+	handler := logger.LoggerMiddleware(router.SetupRoutes())
+
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	code := func(rec *httptest.ResponseRecorder) ErrorCode {
+		var body errorBody
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		return body.Code
+	}
+
+	t.Run("gets product by sku", func(t *testing.T) {
+		rec := do(http.MethodGet, "/product/sku/LMP-1", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var product domain.Product
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&product))
+		assert.Equal(t, domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Brass", Version: 1, Sku: "LMP-1"}, product)
+		assert.Equal(t, productETag(product), rec.Header().Get("ETag"))
+
+		req := httptest.NewRequest(http.MethodGet, "/product/sku/LMP-1", nil)
+		assert.Equal(t, "/product/sku/{sku}", router.Route(req))
+		assert.Equal(t, "/product/{id}/tags", router.Route(httptest.NewRequest(http.MethodPost, "/product/1/tags", nil)))
+	})
+
+	t.Run("unknown or invalid sku", func(t *testing.T) {
+		rec := do(http.MethodGet, "/product/sku/LMP-9", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, CodeProductNotFound, code(rec))
+
+		rec = do(http.MethodGet, "/product/sku/LMP%201", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, CodeInvalidParameter, code(rec))
+	})
+
+	t.Run("duplicate sku conflicts", func(t *testing.T) {
+		rec := do(http.MethodPost, "/product", `{"name":"Table","additionalInfo":"Pine","sku":"LMP-1"}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, CodeDuplicateSku, code(rec))
+
+		rec = do(http.MethodPut, "/product/2", `{"name":"Chair","additionalInfo":"Oak","sku":"LMP-1"}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, CodeDuplicateSku, code(rec))
+
+		rec = do(http.MethodPut, "/product/2", `{"name":"Chair","additionalInfo":"Oak","sku":"CHR-1"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/product/sku/CHR-1", "").Code)
+	})
+
+	t.Run("rejects invalid sku in body", func(t *testing.T) {
+		rec := do(http.MethodPost, "/product", `{"name":"Table","additionalInfo":"Pine","sku":"a/b"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, CodeInvalidBody, code(rec))
+	})
+}
@@ -0,0 +1,54 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type stockAdjustRequest struct {
+	Delta int64 `json:"delta"`
+}
+
+// AdjustProductStock adds delta in body to product stock, negative delta
+// takes items out. Responds with the product, or with 409 if there are not
+// enough items in stock
+func (h *ProductHandler) AdjustProductStock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	id, err := parseAndValidate(r.PathValue("id"), 0, "product id", errContainer, w)
+	if err != nil {
+		return
+	}
+	var request stockAdjustRequest
+	if err := h.json.decode(r.Body, &request); err != nil || request.Delta == 0 {
+		if err == nil {
+			err = errors.New("stock delta is zero")
+		}
+		errContainer.Add(fmt.Errorf("failed to decode payload: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	product, serviceErr := h.svc.AdjustProductStock(r.Context(), id, request.Delta)
+	if serviceErr != nil {
+		storeServiceErrToCtx(r.Context(), serviceErr)
+		if serviceErr.CriticalError != nil {
+			switch {
+			case errors.Is(serviceErr.CriticalError, domain.ErrNotFound):
+				writeError(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+			case errors.Is(serviceErr.CriticalError, domain.ErrInsufficientStock):
+				writeError(w, http.StatusConflict, CodeInsufficientStock, "Not enough items in stock")
+			case errors.Is(serviceErr.CriticalError, domain.ErrInvalidInput):
+				writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+			default:
+				writeServerError(w, r, serviceErr.CriticalError)
+			}
+			return
+		}
+	}
+	w.Header().Set("ETag", productETag(*product))
+	h.writeProduct(w, r, http.StatusOK, *product)
+}
@@ -0,0 +1,76 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestAdjustProductStock(t *testing.T) {
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Brass"})
+	cache := fakes.NewCache()
+	svc := service.NewResourceService(repo, cache)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc)).SetupRoutes())
+
+	do := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/product/1/stock-adjust", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	code := func(rec *httptest.ResponseRecorder) ErrorCode {
+		var body errorBody
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		return body.Code
+	}
+
+	t.Run("adjusts stock", func(t *testing.T) {
+		rec := do(`{"delta":5}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var product domain.Product
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&product))
+		assert.Equal(t, domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Brass", Version: 2, Stock: 5}, product)
+		assert.Equal(t, productETag(product), rec.Header().Get("ETag"))
+
+		rec = do(`{"delta":-3}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"stock":2`)
+	})
+
+	t.Run("insufficient stock conflicts", func(t *testing.T) {
+		rec := do(`{"delta":-3}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, CodeInsufficientStock, code(rec))
+		assert.Equal(t, int64(2), repo.Products()[0].Stock)
+	})
+
+	t.Run("rejects invalid body", func(t *testing.T) {
+		for _, body := range []string{`{"delta":0}`, `{}`, `{"delta":"1"}`, `{`} {
+			rec := do(body)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+			assert.Equal(t, CodeInvalidBody, code(rec), body)
+		}
+	})
+
+	t.Run("unknown product", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/product/9/stock-adjust", strings.NewReader(`{"delta":1}`))
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, CodeProductNotFound, code(rec))
+	})
+}
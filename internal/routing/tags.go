@@ -0,0 +1,71 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type tagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// AddProductTags tags product with tags given in body, ones it already has
+// are left as they are. Responds with the tagged product
+func (h *ProductHandler) AddProductTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	id, err := parseAndValidate(r.PathValue("id"), 0, "product id", errContainer, w)
+	if err != nil {
+		return
+	}
+	var request tagsRequest
+	if err := h.json.decode(r.Body, &request); err != nil || len(request.Tags) == 0 {
+		if err == nil {
+			err = errors.New("no tags given")
+		}
+		errContainer.Add(fmt.Errorf("failed to decode payload: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	product, serviceErr := h.svc.AddProductTags(r.Context(), id, request.Tags)
+	h.writeTagged(w, r, product, serviceErr, CodeInvalidBody)
+}
+
+// RemoveProductTag takes tag in path off product, removing a tag product
+// does not have is not an error. Responds with the product
+func (h *ProductHandler) RemoveProductTag(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	id, err := parseAndValidate(r.PathValue("id"), 0, "product id", errContainer, w)
+	if err != nil {
+		return
+	}
+
+	product, serviceErr := h.svc.RemoveProductTags(r.Context(), id, []string{r.PathValue("tag")})
+	h.writeTagged(w, r, product, serviceErr, CodeInvalidParameter)
+}
+
+// writeTagged responds with product tagging returned, invalid tags are
+// reported with code of where they were given
+func (h *ProductHandler) writeTagged(w http.ResponseWriter, r *http.Request, product *domain.Product, serviceErr *domain.ServiceError, invalid ErrorCode) {
+	if serviceErr != nil {
+		storeServiceErrToCtx(r.Context(), serviceErr)
+		if serviceErr.CriticalError != nil {
+			switch {
+			case errors.Is(serviceErr.CriticalError, domain.ErrNotFound):
+				writeError(w, http.StatusNotFound, CodeProductNotFound, "Product not found")
+			case errors.Is(serviceErr.CriticalError, domain.ErrInvalidInput):
+				writeError(w, http.StatusBadRequest, invalid, "Invalid tag")
+			default:
+				writeServerError(w, r, serviceErr.CriticalError)
+			}
+			return
+		}
+	}
+	w.Header().Set("ETag", productETag(*product))
+	h.writeProduct(w, r, http.StatusOK, *product)
+}
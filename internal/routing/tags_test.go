@@ -0,0 +1,117 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestProductTags(t *testing.T) {
+	repo := fakes.NewRepository(
+		domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Brass"},
+		domain.Product{Id: 2, Name: "Chair", AdditionalInfo: "Oak"},
+	)
+	cache := fakes.NewCache()
+	svc := service.NewResourceService(repo, cache)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(svc)).SetupRoutes())
+
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	code := func(rec *httptest.ResponseRecorder) ErrorCode {
+		var body errorBody
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		return body.Code
+	}
+
+	decodeProduct := func(t *testing.T, rec *httptest.ResponseRecorder) domain.Product {
+		var product domain.Product
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&product))
+		return product
+	}
+
+	t.Run("tags product", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, do(http.MethodGet, "/product/1", "").Code)
+		require.True(t, cache.Has(1))
+
+		rec := do(http.MethodPost, "/product/1/tags", `{"tags":["Sale","new"]}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		etag := rec.Header().Get("ETag")
+		product := decodeProduct(t, rec)
+		assert.Equal(t, domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Brass", Version: 2, Tags: []string{"new", "sale"}}, product)
+		assert.Equal(t, productETag(product), etag)
+		assert.False(t, cache.Has(1), "cached product is invalidated")
+
+		rec = do(http.MethodGet, "/product/1", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"tags":["new","sale"]`)
+		require.Equal(t, http.StatusOK, do(http.MethodPost, "/product/2/tags", `{"tags":["new"]}`).Code)
+	})
+
+	t.Run("lists products by tag", func(t *testing.T) {
+		rec := do(http.MethodGet, "/products?tag=SALE", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var products []domain.Product
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&products))
+		require.Len(t, products, 1)
+		assert.Equal(t, int64(1), products[0].Id)
+
+		rec = do(http.MethodGet, "/products?tag=new&limit=10&offset=0", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&products))
+		assert.Len(t, products, 2)
+
+		rec = do(http.MethodGet, "/products?tag=on%20sale", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, CodeInvalidParameter, code(rec))
+	})
+
+	t.Run("removes tag", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, do(http.MethodGet, "/product/1", "").Code)
+
+		rec := do(http.MethodDelete, "/product/1/tags/sale", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Brass", Version: 3, Tags: []string{"new"}}, decodeProduct(t, rec))
+		assert.False(t, cache.Has(1))
+
+		rec = do(http.MethodDelete, "/product/1/tags/sale", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, int64(3), decodeProduct(t, rec).Version, "removing missing tag changes nothing")
+	})
+
+	t.Run("rejects invalid tags", func(t *testing.T) {
+		for _, body := range []string{`{"tags":[]}`, `{"tags":["on sale"]}`, `{`} {
+			rec := do(http.MethodPost, "/product/1/tags", body)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+			assert.Equal(t, CodeInvalidBody, code(rec), body)
+		}
+		rec := do(http.MethodDelete, "/product/1/tags/50%25", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, CodeInvalidParameter, code(rec))
+	})
+
+	t.Run("unknown product", func(t *testing.T) {
+		rec := do(http.MethodPost, "/product/9/tags", `{"tags":["sale"]}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, CodeProductNotFound, code(rec))
+		rec = do(http.MethodDelete, "/product/9/tags/sale", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,67 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+const requestTimeoutHeader = "X-Request-Timeout"
+
+// parseRequestTimeout accepts Go durations ("250ms", "2s") as well as plain
+// number of seconds
+func parseRequestTimeout(value string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, parseErr := strconv.ParseFloat(value, 64)
+		if parseErr != nil {
+			return 0, fmt.Errorf("invalid request timeout %q", value)
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("request timeout %q must be positive", value)
+	}
+	return timeout, nil
+}
+
+// RequestTimeoutMiddleware sets deadline of request context. Clients may ask
+// for a shorter or longer one with X-Request-Timeout, capped at maxTimeout.
+// Without default timeout requests have no deadline unless they ask for one,
+// so that long exports are not cut off. Zero maxTimeout means no cap
+func RequestTimeoutMiddleware(defaultTimeout time.Duration, maxTimeout time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := defaultTimeout
+		if value := r.Header.Get(requestTimeoutHeader); value != "" {
+			requested, err := parseRequestTimeout(value)
+			if err != nil {
+				errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+				errContainer.Add(fmt.Errorf("%w: %s", domain.ErrInvalidInput, err.Error()))
+				writeError(w, http.StatusBadRequest, CodeInvalidParameter, "Invalid "+requestTimeoutHeader+" header")
+				return
+			}
+			timeout = requested
+		}
+		if maxTimeout > 0 && timeout > maxTimeout {
+			timeout = maxTimeout
+		}
+		if timeout == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// deadlineExceeded tells whether request failed because its deadline passed,
+// whatever error dependencies reported for it
+func deadlineExceeded(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.DeadlineExceeded)
+}
@@ -0,0 +1,113 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func TestRequestTimeoutMiddleware(t *testing.T) {
+	tests := []struct {
+		name             string
+		header           string
+		defaultTimeout   time.Duration
+		expectedStatus   int
+		expectedDeadline time.Duration
+	}{
+		{
+			name:             "default timeout",
+			defaultTimeout:   time.Minute,
+			expectedStatus:   http.StatusOK,
+			expectedDeadline: time.Minute,
+		},
+		{
+			name:           "no default timeout - no deadline",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "header as duration",
+			header:           "250ms",
+			defaultTimeout:   time.Minute,
+			expectedStatus:   http.StatusOK,
+			expectedDeadline: 250 * time.Millisecond,
+		},
+		{
+			name:             "header as seconds",
+			header:           "90",
+			defaultTimeout:   time.Minute,
+			expectedStatus:   http.StatusOK,
+			expectedDeadline: 90 * time.Second,
+		},
+		{
+			name:             "header capped by maximum",
+			header:           "1h",
+			expectedStatus:   http.StatusOK,
+			expectedDeadline: 2 * time.Minute,
+		},
+		{
+			name:           "invalid header",
+			header:         "soon",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "negative header",
+			header:         "-1s",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deadline time.Time
+			var hasDeadline bool
+			handler := RequestTimeoutMiddleware(tt.defaultTimeout, 2*time.Minute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				deadline, hasDeadline = r.Context().Deadline()
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/products", nil)
+			if tt.header != "" {
+				req.Header.Set(requestTimeoutHeader, tt.header)
+			}
+			errs := domain.NewErrorContainer()
+			req = req.WithContext(context.WithValue(req.Context(), "errorContainer", &errs))
+			rec := httptest.NewRecorder()
+			started := time.Now()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			require.Equal(t, tt.expectedDeadline != 0, hasDeadline)
+			if hasDeadline {
+				assert.WithinDuration(t, started.Add(tt.expectedDeadline), deadline, time.Second)
+			}
+		})
+	}
+}
+
+func TestRequestTimeoutExceeded(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	handler := logger.LoggerMiddleware(RequestTimeoutMiddleware(0, time.Minute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		writeServerError(w, r, fmt.Errorf("%w: %w: query cancelled", domain.ErrInternalDb, domain.ErrUnavailable))
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set(requestTimeoutHeader, "10ms")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	assert.JSONEq(t, `{"error":"Request timed out","code":"REQUEST_TIMEOUT"}`, rec.Body.String())
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+}
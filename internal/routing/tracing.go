@@ -0,0 +1,114 @@
+package routing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// incomingTrace reads trace context of caller from traceparent, falling
+// back to B3 headers. Malformed headers are ignored as the spec asks
+func incomingTrace(r *http.Request) (domain.TraceContext, bool) {
+	if header := r.Header.Get("traceparent"); header != "" {
+		if tc, err := domain.ParseTraceparent(header); err == nil {
+			return tc, true
+		}
+	}
+	if header := r.Header.Get("b3"); header != "" {
+		if tc, err := domain.ParseB3Single(header); err == nil {
+			return tc, true
+		}
+	}
+	if traceId := r.Header.Get("X-B3-TraceId"); traceId != "" {
+		sampled := r.Header.Get("X-B3-Sampled")
+		if r.Header.Get("X-B3-Flags") == "1" {
+			sampled = "d"
+		}
+		if tc, err := domain.ParseB3(traceId, r.Header.Get("X-B3-SpanId"), sampled); err == nil {
+			return tc, true
+		}
+	}
+	return domain.TraceContext{}, false
+}
+
+// TraceMiddleware continues trace of caller, or starts a new one, with a
+// span of its own in request context. Logs, outgoing requests and events
+// carry it on, so it has to wrap logger middleware
+func TraceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc, ok := incomingTrace(r)
+		if ok {
+			tc = tc.Child()
+		} else {
+			tc = domain.NewTraceContext()
+		}
+		next.ServeHTTP(w, r.WithContext(domain.WithTraceContext(r.Context(), tc)))
+	})
+}
+
+// SpanMiddleware is TraceMiddleware recording spans: it starts server span
+// named after route, child of caller's span if there is one, and puts it
+// in request context for service, database and cache spans to descend
+// from. Trace context in request context refers to the span, so logs and
+// events can be looked up by it. Like TraceMiddleware it has to wrap logger
+// middleware
+func SpanMiddleware(provider trace.TracerProvider, route func(r *http.Request) string, next http.Handler) http.Handler {
+	tracer := provider.Tracer("github.com/pelyams/simpler_go_service/internal/routing")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if tc, ok := incomingTrace(r); ok {
+			ctx = trace.ContextWithRemoteSpanContext(ctx, spanContext(tc))
+		}
+		pattern := route(r)
+		name := r.Method
+		if pattern != unmatchedRoute {
+			name += " " + pattern
+		}
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(pattern),
+				semconv.URLPath(r.URL.Path),
+			))
+		defer span.End()
+		sc := span.SpanContext()
+		ctx = domain.WithTraceContext(ctx, domain.TraceContext{
+			TraceId: sc.TraceID().String(),
+			SpanId:  sc.SpanID().String(),
+			Sampled: sc.IsSampled(),
+		})
+
+		rec := &countingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		// client errors are not errors of server span
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// spanContext is remote span context of caller's trace context
+func spanContext(tc domain.TraceContext) trace.SpanContext {
+	traceId, _ := trace.TraceIDFromHex(tc.TraceId)
+	spanId, _ := trace.SpanIDFromHex(tc.SpanId)
+	var flags trace.TraceFlags
+	if tc.Sampled {
+		flags = trace.FlagsSampled
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: flags,
+		Remote:     true,
+	})
+}
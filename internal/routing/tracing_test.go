@@ -0,0 +1,150 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestTraceMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		traceId string
+		sampled bool
+	}{
+		{
+			name:    "traceparent",
+			headers: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			traceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+			sampled: true,
+		},
+		{
+			name: "traceparent wins over b3",
+			headers: map[string]string{
+				"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+				"b3":          "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1",
+			},
+			traceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name: "malformed traceparent falls back to b3",
+			headers: map[string]string{
+				"traceparent": "00-garbage",
+				"b3":          "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1",
+			},
+			traceId: "80f198ee56343ba864fe8b2a57d3eff7",
+			sampled: true,
+		},
+		{
+			name: "multiple b3 headers",
+			headers: map[string]string{
+				"X-B3-TraceId": "64fe8b2a57d3eff7",
+				"X-B3-SpanId":  "e457b5a2e4d86bd1",
+				"X-B3-Flags":   "1",
+			},
+			traceId: "000000000000000064fe8b2a57d3eff7",
+			sampled: true,
+		},
+		{name: "no trace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tc domain.TraceContext
+			var ok bool
+			handler := TraceMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tc, ok = domain.TraceContextFromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/products", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			require.True(t, ok)
+			_, err := domain.ParseTraceparent(tc.Traceparent())
+			require.NoError(t, err)
+			if tt.traceId != "" {
+				assert.Equal(t, tt.traceId, tc.TraceId, "trace of caller is continued")
+			}
+			assert.NotEqual(t, "00f067aa0ba902b7", tc.SpanId, "request gets span of its own")
+			assert.Equal(t, tt.sampled, tc.Sampled)
+		})
+	}
+}
+
+func TestSpanMiddleware(t *testing.T) {
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	svc := service.NewResourceService(fakes.NewRepository(), fakes.NewCache(), service.WithTracerProvider(provider))
+	_, serviceErr := svc.CreateProduct(context.Background(), domain.NewProduct{Name: "lamp", AdditionalInfo: "brass"})
+	require.Nil(t, serviceErr)
+	recorder.Reset()
+
+	routes := NewRouter(NewProductHandler(svc))
+	var tc domain.TraceContext
+	router := routes.SetupRoutes()
+	handler := SpanMiddleware(provider, routes.Route, logger.LoggerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc, _ = domain.TraceContextFromContext(r.Context())
+		router.ServeHTTP(w, r)
+	})))
+
+	t.Run("request span is parent of service span", func(t *testing.T) {
+		recorder.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/product/1", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 2)
+		call, request := spans[0], spans[1]
+		assert.Equal(t, "ResourseService.GetProductById", call.Name())
+		assert.Equal(t, "GET /product/{id}", request.Name())
+		assert.Equal(t, request.SpanContext().SpanID(), call.Parent().SpanID())
+		assert.Equal(t, "00f067aa0ba902b7", request.Parent().SpanID().String(), "trace of caller is continued")
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", call.SpanContext().TraceID().String())
+		assert.Equal(t, request.SpanContext().SpanID().String(), tc.SpanId, "logs refer to request span")
+		assert.Contains(t, request.Attributes(), semconv.HTTPResponseStatusCode(http.StatusOK))
+	})
+
+	t.Run("client errors do not fail spans", func(t *testing.T) {
+		recorder.Reset()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/product/42", nil))
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 2)
+		for _, span := range spans {
+			assert.NotEqual(t, codes.Error, span.Status().Code, span.Name())
+		}
+		assert.Contains(t, spans[1].Attributes(), semconv.HTTPResponseStatusCode(http.StatusNotFound))
+		assert.NotEmpty(t, spans[0].Events(), "service span records the error")
+	})
+
+	t.Run("unmatched requests are named after method", func(t *testing.T) {
+		recorder.Reset()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "GET", spans[0].Name())
+		assert.False(t, spans[0].Parent().IsValid(), "request without trace context starts a trace")
+		assert.True(t, strings.HasPrefix(tc.Traceparent(), "00-"+spans[0].SpanContext().TraceID().String()))
+	})
+}
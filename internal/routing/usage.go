@@ -0,0 +1,94 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// anonymousClient is usage of requests without authenticated principal
+const anonymousClient = "anonymous"
+
+// defaultUsageDays is the range GET /admin/usage reports if not given one
+const defaultUsageDays = 30
+
+// countingResponseWriter records status and size of response
+type countingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (c *countingResponseWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *countingResponseWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	n, err := c.ResponseWriter.Write(p)
+	c.bytes += int64(n)
+	return n, err
+}
+
+func (c *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// countingReader records how much of request body was read
+type countingReader struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.bytes += int64(n)
+	return n, err
+}
+
+// UsageMiddleware counts requests, error responses and bandwidth per
+// principal, so it has to run after authentication. Failing to count does
+// not fail the request
+func UsageMiddleware(counter ports.UsageCounter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+		rec := &countingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		principal := PrincipalFromContext(r.Context())
+		if principal == "" {
+			principal = anonymousClient
+		}
+		usage := domain.DailyUsage{
+			Day: time.Now().UTC().Truncate(24 * time.Hour),
+			Usage: domain.Usage{
+				Client:   principal,
+				Requests: 1,
+				BytesIn:  body.bytes,
+				BytesOut: rec.bytes,
+			},
+		}
+		if rec.status >= http.StatusBadRequest {
+			usage.Errors = 1
+		}
+		// request context may be cancelled already, counting should not be
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), time.Second)
+		defer cancel()
+		if err := counter.Add(ctx, usage); err != nil {
+			if errContainer, ok := r.Context().Value("errorContainer").(*domain.ErrorContainer); ok {
+				errContainer.Add(fmt.Errorf("usage error: %w", err))
+			}
+		}
+	})
+}
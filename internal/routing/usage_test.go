@@ -0,0 +1,103 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestUsageMiddleware(t *testing.T) {
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"})
+	counter := fakes.NewUsageCounter()
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	router := NewRouter(NewProductHandler(service.NewResourceService(repo, fakes.NewCache()))).SetupRoutes()
+	handler := logger.LoggerMiddleware(UsageMiddleware(counter, router))
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/product/1", nil),
+		httptest.NewRequest(http.MethodGet, "/product/42", nil),
+		httptest.NewRequest(http.MethodPost, "/product", strings.NewReader(`{"name":"New","additionalInfo":"Created"}`)),
+	}
+	requests[2] = requests[2].WithContext(WithPrincipal(requests[2].Context(), "shop"))
+	var responseBytes int64
+	for _, req := range requests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		responseBytes += int64(rec.Body.Len())
+	}
+
+	drained, err := counter.Drain(context.Background())
+	require.NoError(t, err)
+	require.Len(t, drained, 2)
+	anonymous, shop := drained[0], drained[1]
+	assert.Equal(t, anonymousClient, anonymous.Client)
+	assert.Equal(t, int64(2), anonymous.Requests)
+	assert.Equal(t, int64(1), anonymous.Errors, "not found is an error")
+	assert.Equal(t, domain.Usage{
+		Client:   "shop",
+		Requests: 1,
+		BytesIn:  int64(len(`{"name":"New","additionalInfo":"Created"}`)),
+		BytesOut: responseBytes - anonymous.BytesOut,
+	}, shop.Usage)
+	assert.Equal(t, time.Now().UTC().Truncate(24*time.Hour), shop.Day)
+}
+
+func TestUsageMiddlewareCounterFailure(t *testing.T) {
+	counter := fakes.NewUsageCounter()
+	counter.FailWith("Add", domain.ErrInternalCache)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	handler := logger.LoggerMiddleware(UsageMiddleware(counter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestGetUsage(t *testing.T) {
+	usage := fakes.NewUsageRepository()
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, usage.AddUsage(context.Background(), []domain.DailyUsage{
+		{Day: day, Usage: domain.Usage{Client: "shop", Requests: 4, Errors: 1, BytesIn: 100, BytesOut: 1000}},
+		{Day: day.AddDate(0, 0, 1), Usage: domain.Usage{Client: "anonymous", Requests: 1}},
+	}))
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		handler *AdminHandler
+	}{
+		{name: "get_usage", path: "/admin/usage?from=2026-10-01&to=2026-10-02", handler: NewAdminHandler(logger, WithUsage(usage))},
+		{name: "get_usage_single_day", path: "/admin/usage?from=2026-10-01&to=2026-10-01", handler: NewAdminHandler(logger, WithUsage(usage))},
+		{name: "get_usage_invalid_range", path: "/admin/usage?from=2026-10-02&to=2026-10-01", handler: NewAdminHandler(logger, WithUsage(usage))},
+		{name: "get_usage_not_configured", path: "/admin/usage", handler: NewAdminHandler(logger)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := logger.LoggerMiddleware(NewAdminRouter(tt.handler).SetupRoutes())
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			testhelpers.AssertGolden(t, tt.name, rec)
+		})
+	}
+}
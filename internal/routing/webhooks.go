@@ -0,0 +1,145 @@
+package routing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// WithWebhooks lets admins subscribe webhooks to product events
+func WithWebhooks(webhooks ports.WebhookRepository) AdminOption {
+	return func(h *AdminHandler) {
+		h.webhooks = webhooks
+	}
+}
+
+type webhookRequest struct {
+	URL string `json:"url"`
+	// all event types unless given
+	EventTypes []string `json:"eventTypes"`
+}
+
+type webhookResponse struct {
+	domain.Webhook
+	// only returned once, deliveries are verified with it
+	Secret string `json:"secret"`
+}
+
+// generateWebhookSecret returns a random secret, 32 bytes hex encoded
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// GetWebhooks lists webhooks, without their secrets
+func (h *AdminHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.webhooks == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Webhooks are not enabled")
+		return
+	}
+	if !authenticated(w, r) {
+		return
+	}
+	webhooks, err := h.webhooks.ListWebhooks(r.Context())
+	if err != nil {
+		errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+		errContainer.Add(err)
+		writeServerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, webhooks)
+}
+
+// CreateWebhook subscribes URL in body to event types given there. Secret
+// deliveries are signed with is generated and only returned in response
+func (h *AdminHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.webhooks == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Webhooks are not enabled")
+		return
+	}
+	if !authenticated(w, r) {
+		return
+	}
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	var request webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		errContainer.Add(fmt.Errorf("admin handler error: failed to decode webhook request: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	target, err := url.Parse(request.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		errContainer.Add(fmt.Errorf("admin handler error: invalid webhook url %q", request.URL))
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "Webhook URL must be an absolute http or https URL")
+		return
+	}
+	eventTypes := slices.Clone(request.EventTypes)
+	if len(eventTypes) == 0 {
+		eventTypes = slices.Clone(domain.WebhookEventTypes)
+	}
+	for _, eventType := range eventTypes {
+		if !domain.IsWebhookEventType(eventType) {
+			errContainer.Add(fmt.Errorf("admin handler error: unknown webhook event type %q", eventType))
+			writeError(w, http.StatusBadRequest, CodeInvalidBody, "Unknown event type")
+			return
+		}
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		errContainer.Add(err)
+		writeServerError(w, r, err)
+		return
+	}
+	slices.Sort(eventTypes)
+	webhook, err := h.webhooks.CreateWebhook(r.Context(), domain.Webhook{URL: request.URL, Secret: secret, EventTypes: slices.Compact(eventTypes)})
+	if err != nil {
+		errContainer.Add(err)
+		writeServerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, webhookResponse{Webhook: webhook, Secret: secret})
+}
+
+// DeleteWebhook unsubscribes webhook by id, events queued for it are not
+// delivered anymore
+func (h *AdminHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.webhooks == nil {
+		writeError(w, http.StatusNotImplemented, CodeNotConfigured, "Webhooks are not enabled")
+		return
+	}
+	if !authenticated(w, r) {
+		return
+	}
+	errContainer := r.Context().Value("errorContainer").(*domain.ErrorContainer)
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errContainer.Add(fmt.Errorf("admin handler error: invalid webhook id: %w", err))
+		writeError(w, http.StatusBadRequest, CodeInvalidId, "Invalid webhook id")
+		return
+	}
+	if err := h.webhooks.DeleteWebhook(r.Context(), id); err != nil {
+		errContainer.Add(err)
+		if errors.Is(err, domain.ErrNotFound) {
+			writeError(w, http.StatusNotFound, CodeNotFound, "Webhook not found")
+			return
+		}
+		writeServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
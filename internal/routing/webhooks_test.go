@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestAdminWebhooks(t *testing.T) {
+	logger, err := NewLogger(t.TempDir() + "/test.log")
+	require.NoError(t, err)
+	defer logger.Close()
+	webhooks := fakes.NewWebhookRepository()
+	admin := NewAdminRouter(NewAdminHandler(logger, WithWebhooks(webhooks))).SetupRoutes()
+	send := func(method, path, body, principal string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		ctx := req.Context()
+		if principal != "" {
+			ctx = WithPrincipal(ctx, principal)
+		}
+		errs := domain.NewErrorContainer()
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, req.WithContext(context.WithValue(ctx, "errorContainer", &errs)))
+		return rec
+	}
+
+	rec := send(http.MethodPost, "/admin/webhooks", `{"url":"https://partner.example.com/hooks","eventTypes":["product.deleted","product.created","product.deleted"]}`, "ops")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created webhookResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+	assert.Equal(t, "https://partner.example.com/hooks", created.URL)
+	assert.Equal(t, []string{domain.EventProductCreated, domain.EventProductDeleted}, created.EventTypes)
+	assert.Len(t, created.Secret, 64)
+
+	rec = send(http.MethodPost, "/admin/webhooks", `{"url":"http://localhost:9000/"}`, "ops")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var all webhookResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&all))
+	assert.ElementsMatch(t, domain.WebhookEventTypes, all.EventTypes, "all events unless told otherwise")
+	assert.NotEqual(t, created.Secret, all.Secret)
+
+	rec = send(http.MethodGet, "/admin/webhooks", "", "ops")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), created.Secret, "secrets are only shown once")
+	var listed []map[string]any
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&listed))
+	require.Len(t, listed, 2)
+	assert.Equal(t, "https://partner.example.com/hooks", listed[0]["url"])
+
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/admin/webhooks", `{"url":"https://partner.example.com/hooks"}`, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/admin/webhooks", "", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/admin/webhooks", `{"url":"partner.example.com/hooks"}`, "ops").Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/admin/webhooks", `{"url":"ftp://partner.example.com/hooks"}`, "ops").Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/admin/webhooks", `{"url":"https://partner.example.com/hooks","eventTypes":["product.sold"]}`, "ops").Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodDelete, "/admin/webhooks/first", "", "ops").Code)
+
+	path := fmt.Sprintf("/admin/webhooks/%d", created.Id)
+	assert.Equal(t, http.StatusNoContent, send(http.MethodDelete, path, "", "ops").Code)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodDelete, path, "", "ops").Code)
+	remaining, err := webhooks.ListWebhooks(context.Background())
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, all.Id, remaining[0].Id)
+
+	unconfigured := NewAdminRouter(NewAdminHandler(logger)).SetupRoutes()
+	rec = httptest.NewRecorder()
+	unconfigured.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil))
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+}
@@ -0,0 +1,156 @@
+package routing
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// static parts of a single-sheet workbook, sheet itself is streamed
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Products" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+	// style 1 is bold, used for header row
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+		`</styleSheet>`},
+}
+
+// xlsxColumn is a typed column, numbers are stored as numbers so that Excel
+// sorts and sums them without conversion
+type xlsxColumn struct {
+	title   string
+	width   int
+	numeric bool
+}
+
+// xlsxWriter streams rows into a single-sheet workbook. Rows go straight
+// into zip entry of the sheet, memory use does not depend on their number
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   io.Writer
+	columns []xlsxColumn
+	rows    int
+}
+
+func newXLSXWriter(w io.Writer, columns []xlsxColumn) (*xlsxWriter, error) {
+	x := &xlsxWriter{archive: zip.NewWriter(w), columns: columns}
+	for _, part := range xlsxParts {
+		entry, err := x.archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(entry, part.content); err != nil {
+			return nil, err
+		}
+	}
+	sheet, err := x.archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x.sheet = sheet
+
+	var head strings.Builder
+	head.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	// header row stays in place while scrolling
+	head.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews><cols>`)
+	for i, column := range columns {
+		fmt.Fprintf(&head, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, column.width)
+	}
+	head.WriteString(`</cols><sheetData>`)
+	if _, err := io.WriteString(x.sheet, head.String()); err != nil {
+		return nil, err
+	}
+
+	titles := make([]string, len(columns))
+	for i, column := range columns {
+		titles[i] = column.title
+	}
+	return x, x.writeRow(titles, true)
+}
+
+// WriteRow writes a row of values, one per column. Values of numeric columns
+// must be numbers
+func (x *xlsxWriter) WriteRow(values ...string) error {
+	if len(values) != len(x.columns) {
+		return fmt.Errorf("xlsx row has %d values, %d columns expected", len(values), len(x.columns))
+	}
+	return x.writeRow(values, false)
+}
+
+func (x *xlsxWriter) writeRow(values []string, header bool) error {
+	n := x.rows + 1
+	var row strings.Builder
+	fmt.Fprintf(&row, `<row r="%d">`, n)
+	for i, value := range values {
+		ref := xlsxColumnName(i) + strconv.Itoa(n)
+		switch {
+		case header:
+			fmt.Fprintf(&row, `<c r="%s" t="inlineStr" s="1"><is><t>`, ref)
+		case x.columns[i].numeric:
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return fmt.Errorf("xlsx column %q is numeric, got %q", x.columns[i].title, value)
+			}
+			fmt.Fprintf(&row, `<c r="%s"><v>%s</v></c>`, ref, value)
+			continue
+		default:
+			fmt.Fprintf(&row, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+		}
+		xml.EscapeText(&row, []byte(value))
+		row.WriteString(`</t></is></c>`)
+	}
+	row.WriteString(`</row>`)
+	if _, err := io.WriteString(x.sheet, row.String()); err != nil {
+		return err
+	}
+	x.rows = n
+	return nil
+}
+
+// Close completes the workbook, it is not valid until then
+func (x *xlsxWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.archive.Close()
+}
+
+// xlsxColumnName converts zero-based index into A, B, ..., Z, AA, AB...
+func xlsxColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
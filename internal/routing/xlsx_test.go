@@ -0,0 +1,119 @@
+package routing
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+type sheetCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Style  string `xml:"s,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+type sheetXML struct {
+	Rows []struct {
+		Cells []sheetCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readSheet unzips workbook and parses its only worksheet
+func readSheet(t *testing.T, data []byte) sheetXML {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	names := map[string]bool{}
+	var sheet sheetXML
+	for _, f := range archive.File {
+		names[f.Name] = true
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		require.NoError(t, err)
+		raw, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		require.NoError(t, xml.Unmarshal(raw, &sheet))
+	}
+	for _, part := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		assert.True(t, names[part], "missing part %s", part)
+	}
+	return sheet
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	x, err := newXLSXWriter(&buf, productColumns)
+	require.NoError(t, err)
+	require.NoError(t, x.WriteRow("1", "Chair", "Wood & <steel>"))
+	require.NoError(t, x.WriteRow("2", "=HYPERLINK(\"x\")", ""))
+	require.Error(t, x.WriteRow("three", "Bad", ""))
+	require.NoError(t, x.Close())
+
+	sheet := readSheet(t, buf.Bytes())
+	require.Len(t, sheet.Rows, 3)
+	assert.Equal(t, []sheetCell{
+		{Ref: "A1", Type: "inlineStr", Style: "1", Inline: "id"},
+		{Ref: "B1", Type: "inlineStr", Style: "1", Inline: "name"},
+		{Ref: "C1", Type: "inlineStr", Style: "1", Inline: "additionalInfo"},
+	}, sheet.Rows[0].Cells)
+	assert.Equal(t, []sheetCell{
+		{Ref: "A2", Value: "1"},
+		{Ref: "B2", Type: "inlineStr", Inline: "Chair"},
+		{Ref: "C2", Type: "inlineStr", Inline: "Wood & <steel>"},
+	}, sheet.Rows[1].Cells)
+	// strings are never formulas, whatever they look like
+	assert.Equal(t, "B3", sheet.Rows[2].Cells[1].Ref)
+	assert.Equal(t, "inlineStr", sheet.Rows[2].Cells[1].Type)
+	assert.Equal(t, `=HYPERLINK("x")`, sheet.Rows[2].Cells[1].Inline)
+}
+
+func TestXLSXColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		assert.Equal(t, want, xlsxColumnName(i))
+	}
+}
+
+func TestExportProducts(t *testing.T) {
+	var products []domain.Product
+	for i := int64(1); i <= 5; i++ {
+		products = append(products, domain.Product{Id: i, Name: fmt.Sprintf("Product %d", i), AdditionalInfo: "info"})
+	}
+	repo := fakes.NewRepository(products...)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "test.log"))
+	require.NoError(t, err)
+	defer logger.Close()
+	// chunks of 2 ids, so export spans several of them
+	router := logger.LoggerMiddleware(NewRouter(NewProductHandler(service.NewResourceService(repo, fakes.NewCache(), service.WithParallelExport(2, 2)))).SetupRoutes())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/export?format=xlsx", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, xlsxContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="products.xlsx"`, rec.Header().Get("Content-Disposition"))
+
+	sheet := readSheet(t, rec.Body.Bytes())
+	require.Len(t, sheet.Rows, 6)
+	for i, row := range sheet.Rows[1:] {
+		assert.Equal(t, strconv.Itoa(i+1), row.Cells[0].Value)
+		assert.Equal(t, fmt.Sprintf("Product %d", i+1), row.Cells[1].Inline)
+	}
+}
@@ -0,0 +1,173 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var xmlMediaTypes = []string{"application/xml", "text/xml"}
+
+// preferredToJSON returns the one of mediaTypes client prefers to JSON, if
+// any. JSON is the default, so it has to be ranked strictly higher
+func preferredToJSON(accept string, mediaTypes []string) (string, bool) {
+	var preferred string
+	var preferredQ, jsonQ float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		switch {
+		case slices.Contains(mediaTypes, mediaType):
+			if q > preferredQ {
+				preferred, preferredQ = mediaType, q
+			}
+		case mediaType == "application/json" || mediaType == "application/*" || mediaType == "*/*":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	if preferredQ == 0 || preferredQ <= jsonQ {
+		return "", false
+	}
+	return preferred, true
+}
+
+// xmlMiddleware translates JSON responses to XML for clients preferring it.
+// Products become <product>, lists <products> and errors <error> with code
+// and message, other payloads keep their field names as elements
+func xmlMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(w.Header().Values("Vary"), "Accept") {
+			w.Header().Add("Vary", "Accept")
+		}
+		mediaType, ok := preferredToJSON(r.Header.Get("Accept"), xmlMediaTypes)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		contentType, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type"))
+		if contentType != "application/json" {
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+			return
+		}
+		body, err := toXML(rec.status, rec.body.Bytes())
+		if err != nil {
+			// leave response as is rather than fail a request that succeeded
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+			return
+		}
+		w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(rec.status)
+		w.Write(body)
+	})
+}
+
+func toXML(status int, body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// keep numbers as they are, ids do not fit float64 precisely
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	root := "response"
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if message, ok := v["error"].(string); ok && status >= http.StatusBadRequest {
+			value = map[string]interface{}{"code": v["code"], "message": message}
+			root = "error"
+		} else if _, ok := v["id"]; ok {
+			root = "product"
+		}
+	case []interface{}:
+		root = "products"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	if err := encodeXML(encoder, root, value); err != nil {
+		return nil, err
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var xmlName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+// encodeXML writes value as element name. Object fields become child
+// elements, in alphabetical order like in JSON responses, and keys that are
+// not valid element names are kept in key attribute of <entry>. Array items
+// are <item> elements, <product> within product lists
+func encodeXML(encoder *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !xmlName.MatchString(name) {
+		start = xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+		}
+	}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			if err := encodeXML(encoder, key, v[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		item := "item"
+		if name == "products" {
+			item = "product"
+		}
+		for _, element := range v {
+			if err := encodeXML(encoder, item, element); err != nil {
+				return err
+			}
+		}
+	case nil:
+	case string:
+		if err := encoder.EncodeToken(xml.CharData(v)); err != nil {
+			return err
+		}
+	case json.Number:
+		if err := encoder.EncodeToken(xml.CharData(v.String())); err != nil {
+			return err
+		}
+	case bool:
+		if err := encoder.EncodeToken(xml.CharData(strconv.FormatBool(v))); err != nil {
+			return err
+		}
+	}
+	return encoder.EncodeToken(start.End())
+}
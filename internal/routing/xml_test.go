@@ -0,0 +1,55 @@
+package routing
+
+import (
+	"encoding/xml"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreferredToJSON(t *testing.T) {
+	for accept, expected := range map[string]string{
+		"application/xml":                         "application/xml",
+		"text/xml, application/xml;q=0.9":         "text/xml",
+		"application/json;q=0.8, application/xml": "application/xml",
+		"application/xml, */*":                    "",
+		"application/json, application/xml":       "",
+		"application/xml;q=0":                     "",
+		"application/xml;q=x":                     "",
+		"":                                        "",
+	} {
+		mediaType, ok := preferredToJSON(accept, xmlMediaTypes)
+		assert.Equal(t, expected != "", ok, accept)
+		assert.Equal(t, expected, mediaType, accept)
+	}
+}
+
+func TestToXML(t *testing.T) {
+	body, err := toXML(http.StatusOK, []byte(`{"id": 9007199254740993, "name": "Lamp <LED>", "additionalInfo": "", "tags": ["a", "b"], "facets": {"two words": 1}, "deleted": null}`))
+	require.NoError(t, err)
+	var product struct {
+		XMLName xml.Name `xml:"product"`
+		Id      int64    `xml:"id"`
+		Name    string   `xml:"name"`
+		Tags    []string `xml:"tags>item"`
+		Facet   struct {
+			Key   string `xml:"key,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"facets>entry"`
+	}
+	require.NoError(t, xml.Unmarshal(body, &product))
+	assert.Equal(t, int64(9007199254740993), product.Id, "numbers keep precision")
+	assert.Equal(t, "Lamp <LED>", product.Name)
+	assert.Equal(t, []string{"a", "b"}, product.Tags)
+	assert.Equal(t, "two words", product.Facet.Key)
+	assert.Equal(t, "1", product.Facet.Value)
+
+	body, err = toXML(http.StatusOK, []byte(`{"deleted": 3}`))
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "<response><deleted>3</deleted></response>")
+
+	_, err = toXML(http.StatusOK, []byte(`[{"id": 1}`))
+	assert.Error(t, err)
+}
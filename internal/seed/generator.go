@@ -0,0 +1,83 @@
+// Package seed generates realistic looking catalog data for demos and load tests
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type category struct {
+	name    string
+	nouns   []string
+	minCost float64
+	maxCost float64
+}
+
+var categories = []category{
+	{name: "Kitchen", nouns: []string{"Chef's Knife", "Frying Pan", "Cutting Board", "Kettle", "Coffee Grinder", "Mixing Bowl Set"}, minCost: 8, maxCost: 180},
+	{name: "Electronics", nouns: []string{"Wireless Headphones", "Bluetooth Speaker", "USB-C Charger", "Smart Watch", "Power Bank", "Mechanical Keyboard"}, minCost: 15, maxCost: 450},
+	{name: "Outdoor", nouns: []string{"Hiking Backpack", "Camping Tent", "Sleeping Bag", "Trekking Poles", "Headlamp", "Water Bottle"}, minCost: 10, maxCost: 350},
+	{name: "Home", nouns: []string{"Desk Lamp", "Throw Blanket", "Wall Clock", "Scented Candle", "Storage Basket", "Picture Frame"}, minCost: 6, maxCost: 120},
+	{name: "Sports", nouns: []string{"Yoga Mat", "Running Shoes", "Dumbbell Set", "Jump Rope", "Cycling Gloves", "Tennis Racket"}, minCost: 7, maxCost: 260},
+	{name: "Office", nouns: []string{"Notebook", "Fountain Pen", "Desk Organizer", "Monitor Stand", "Ergonomic Chair", "Whiteboard"}, minCost: 3, maxCost: 400},
+}
+
+var (
+	adjectives = []string{"Classic", "Compact", "Premium", "Eco", "Ultra", "Heavy-Duty", "Portable", "Minimalist", "Deluxe", "Everyday"}
+	colors     = []string{"Black", "White", "Graphite", "Navy", "Forest Green", "Sand", "Crimson", "Slate Grey"}
+	materials  = []string{"stainless steel", "recycled plastic", "bamboo", "aluminium", "organic cotton", "oak", "silicone", "carbon fiber"}
+	features   = []string{
+		"built to last for years of daily use",
+		"lightweight enough to take anywhere",
+		"easy to clean and maintain",
+		"designed with comfort in mind",
+		"backed by a two-year warranty",
+		"packed in plastic-free packaging",
+		"tested to withstand heavy use",
+	}
+)
+
+// Generator produces deterministic sequences for a given seed
+type Generator struct {
+	rand *rand.Rand
+}
+
+func NewGenerator(seed int64) *Generator {
+	return &Generator{rand: rand.New(rand.NewSource(seed))}
+}
+
+func (g *Generator) pick(values []string) string {
+	return values[g.rand.Intn(len(values))]
+}
+
+// Product returns a single product. Catalog schema has no price or category
+// columns, so both are carried in additional info
+func (g *Generator) Product() domain.NewProduct {
+	c := categories[g.rand.Intn(len(categories))]
+	name := fmt.Sprintf("%s %s %s", g.pick(adjectives), g.pick(colors), g.pick(c.nouns))
+	// prices end with .99 or .49 like real ones do
+	price := float64(int(c.minCost+g.rand.Float64()*(c.maxCost-c.minCost))) + []float64{0.99, 0.49}[g.rand.Intn(2)]
+
+	first, second := g.pick(features), g.pick(features)
+	for second == first {
+		second = g.pick(features)
+	}
+	description := fmt.Sprintf("Made of %s, %s and %s.", g.pick(materials), first, second)
+	info := strings.Join([]string{
+		"Category: " + c.name,
+		fmt.Sprintf("Price: $%.2f", price),
+		description,
+	}, ". ")
+	return domain.NewProduct{Name: name, AdditionalInfo: info}
+}
+
+func (g *Generator) Products(n int) []domain.NewProduct {
+	products := make([]domain.NewProduct, n)
+	for i := range products {
+		products[i] = g.Product()
+	}
+	return products
+}
@@ -0,0 +1,21 @@
+package seed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	first := NewGenerator(42).Products(50)
+	second := NewGenerator(42).Products(50)
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, NewGenerator(43).Products(50))
+}
+
+func TestGeneratedProducts(t *testing.T) {
+	for _, p := range NewGenerator(1).Products(200) {
+		assert.NotEmpty(t, p.Name)
+		assert.Regexp(t, `^Category: \w+\. Price: \$\d+\.(99|49)\. Made of .+\.$`, p.AdditionalInfo)
+	}
+}
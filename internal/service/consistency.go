@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// recentWrites remembers products written by this instance for a while.
+// Cache of such products may still be refilled with data read before the
+// write, by a request that raced with it
+type recentWrites struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu sync.Mutex
+	// until when reads of product skip cache
+	products map[int64]time.Time
+	// until when reads of all products do, after the catalog is wiped
+	all     time.Time
+	sweepAt int
+}
+
+func newRecentWrites(window time.Duration) *recentWrites {
+	return &recentWrites{window: window, now: time.Now, products: make(map[int64]time.Time), sweepAt: 64}
+}
+
+func (w *recentWrites) wrote(ids ...int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := w.now()
+	for _, id := range ids {
+		w.products[id] = now.Add(w.window)
+	}
+	if len(w.products) >= w.sweepAt {
+		for id, until := range w.products {
+			if !now.Before(until) {
+				delete(w.products, id)
+			}
+		}
+		w.sweepAt = max(2*len(w.products), 64)
+	}
+}
+
+func (w *recentWrites) wroteAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.all = w.now().Add(w.window)
+	clear(w.products)
+}
+
+func (w *recentWrites) recent(id int64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := w.now()
+	until, ok := w.products[id]
+	return now.Before(w.all) || ok && now.Before(until)
+}
+
+// WithReadYourWrites makes reads of a product skip cache for window after
+// the product is written, refreshing cached copy instead. Written products
+// are tracked per instance, clients balanced across instances should ask
+// for fresh reads with domain.WithFreshRead
+func WithReadYourWrites(window time.Duration) Option {
+	return func(s *ResourseService) {
+		s.recentWrites = newRecentWrites(window)
+	}
+}
+
+func (s *ResourseService) wrote(ids ...int64) {
+	if s.recentWrites != nil {
+		s.recentWrites.wrote(ids...)
+	}
+}
+
+func (s *ResourseService) wroteAll() {
+	if s.recentWrites != nil {
+		s.recentWrites.wroteAll()
+	}
+}
+
+// readFresh tells whether cache is to be skipped when reading product
+func (s *ResourseService) readFresh(ctx context.Context, id int64) bool {
+	return domain.FreshReadRequested(ctx) || s.recentWrites != nil && s.recentWrites.recent(id)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+// requireNoCritical tolerates cache misses and such
+func requireNoCritical(t *testing.T, serviceErr *domain.ServiceError) {
+	t.Helper()
+	if serviceErr != nil {
+		require.NoError(t, serviceErr.CriticalError)
+	}
+}
+
+func readProduct(t *testing.T, svc *ResourseService, ctx context.Context, id int64) domain.Product {
+	t.Helper()
+	res, serviceErr := svc.GetProductById(ctx, id)
+	requireNoCritical(t, serviceErr)
+	var product domain.Product
+	require.NoError(t, json.Unmarshal(res, &product))
+	return product
+}
+
+func TestReadYourWrites(t *testing.T) {
+	ctx := context.Background()
+	stale := domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"}
+	updated := domain.NewProduct{Name: "Renamed", AdditionalInfo: "Updated"}
+
+	t.Run("written product is read from database", func(t *testing.T) {
+		cache := fakes.NewCache()
+		svc := NewResourceService(fakes.NewRepository(stale), cache, WithReadYourWrites(time.Minute))
+		now := time.Now()
+		svc.recentWrites.now = func() time.Time { return now }
+
+		_, serviceErr := svc.UpdateProductById(ctx, 1, updated)
+		requireNoCritical(t, serviceErr)
+		// read racing with the update put the old state back
+		require.NoError(t, cache.SetProduct(ctx, &stale))
+
+		assert.Equal(t, "Renamed", readProduct(t, svc, ctx, 1).Name)
+		cached, err := cache.GetJSONProductById(ctx, 1)
+		require.NoError(t, err)
+		assert.Contains(t, string(cached), "Renamed", "cache is refreshed")
+
+		// past the window cache is trusted again
+		require.NoError(t, cache.SetProduct(ctx, &stale))
+		now = now.Add(time.Minute)
+		assert.Equal(t, "First", readProduct(t, svc, ctx, 1).Name)
+	})
+
+	t.Run("deleted product is dropped from cache", func(t *testing.T) {
+		cache := fakes.NewCache()
+		svc := NewResourceService(fakes.NewRepository(stale), cache, WithReadYourWrites(time.Minute))
+
+		_, serviceErr := svc.DeleteProductById(ctx, 1)
+		requireNoCritical(t, serviceErr)
+		require.NoError(t, cache.SetProduct(ctx, &stale))
+
+		_, serviceErr = svc.GetProductById(ctx, 1)
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrNotFound)
+		assert.False(t, cache.Has(1))
+	})
+
+	t.Run("all products after wipe", func(t *testing.T) {
+		cache := fakes.NewCache()
+		svc := NewResourceService(fakes.NewRepository(stale), cache, WithReadYourWrites(time.Minute))
+
+		_, serviceErr := svc.DeleteAllProducts(ctx)
+		requireNoCritical(t, serviceErr)
+		require.NoError(t, cache.SetProduct(ctx, &stale))
+
+		_, serviceErr = svc.GetProductById(ctx, 1)
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrNotFound)
+	})
+
+	t.Run("fresh read requested by client", func(t *testing.T) {
+		cache := fakes.NewCache()
+		svc := NewResourceService(fakes.NewRepository(stale), cache)
+
+		_, serviceErr := svc.UpdateProductById(ctx, 1, updated)
+		requireNoCritical(t, serviceErr)
+		require.NoError(t, cache.SetProduct(ctx, &stale))
+
+		assert.Equal(t, "First", readProduct(t, svc, ctx, 1).Name, "cache is trusted without window")
+		assert.Equal(t, "Renamed", readProduct(t, svc, domain.WithFreshRead(ctx), 1).Name)
+		assert.Equal(t, "Renamed", readProduct(t, svc, ctx, 1).Name, "cache is refreshed")
+	})
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// a thursday
+	after := time.Date(2026, 10, 15, 10, 30, 45, 0, time.UTC)
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{spec: "* * * * *", expected: time.Date(2026, 10, 15, 10, 31, 0, 0, time.UTC)},
+		{spec: "*/15 * * * *", expected: time.Date(2026, 10, 15, 10, 45, 0, 0, time.UTC)},
+		{spec: "0 */6 * * *", expected: time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)},
+		{spec: "5,10 9-11 * * *", expected: time.Date(2026, 10, 15, 11, 5, 0, 0, time.UTC)},
+		{spec: "0 3 * * 1-5", expected: time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)},
+		{spec: "0 0 * * 7", expected: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 1 1 *", expected: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// either day of month or day of week matching is enough
+		{spec: "0 0 20 * 5", expected: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 29 2 *", expected: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 30 2 *", expected: time.Time{}},
+		{spec: "@hourly", expected: time.Date(2026, 10, 15, 11, 0, 0, 0, time.UTC)},
+		{spec: "@daily", expected: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{spec: "@weekly", expected: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{spec: "@monthly", expected: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "@every 90s", expected: after.Add(90 * time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, schedule.Next(after))
+		})
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@often",
+		"@every",
+		"@every 0s",
+		"@every soon",
+	} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
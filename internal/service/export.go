@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// defaultExportChunk is how many ids a single export query covers
+const defaultExportChunk = 1000
+
+// WithParallelExport makes exports read chunks of chunkSize ids on workers
+// goroutines at once. Chunks are passed on in order of id, at most workers
+// of them are held in memory waiting for their turn
+func WithParallelExport(workers int, chunkSize int64) Option {
+	return func(s *ResourseService) {
+		s.exportWorkers = max(workers, 1)
+		if chunkSize > 0 {
+			s.exportChunk = chunkSize
+		}
+	}
+}
+
+type exportChunk struct {
+	products []domain.Product
+	err      error
+}
+
+// ExportProducts splits id range into chunks rather than paging with
+// offsets, so chunks can be read concurrently and rows do not shift between
+// pages when products are deleted during export. Ids are sparse after
+// deletes, so chunks may come out short or empty, empty ones are skipped
+func (s *ResourseService) ExportProducts(ctx context.Context, fn func(page []domain.Product) error) *domain.ServiceError {
+	first, last, err := s.db.ProductIdRange(ctx)
+	if err != nil {
+		return domain.NewServiceError(err, nil)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	var readers sync.WaitGroup
+	defer readers.Wait()
+	defer cancel()
+
+	// chunks are queued in order of id, each is filled by its own reader.
+	// A slot is taken per chunk until it is passed on, bounding read-ahead
+	slots := make(chan struct{}, s.exportWorkers)
+	chunks := make(chan chan exportChunk, s.exportWorkers)
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		defer close(chunks)
+		for from := first; from <= last; from += s.exportChunk {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			result := make(chan exportChunk, 1)
+			chunks <- result
+			readers.Add(1)
+			go func(from int64) {
+				defer readers.Done()
+				products, err := s.db.GetProductsInRange(ctx, from, min(from+s.exportChunk, last+1))
+				result <- exportChunk{products: products, err: err}
+			}(from)
+		}
+	}()
+
+	passed := false
+	for result := range chunks {
+		chunk := <-result
+		<-slots
+		if chunk.err != nil {
+			return domain.NewServiceError(chunk.err, nil)
+		}
+		if len(chunk.products) == 0 {
+			continue
+		}
+		passed = true
+		if err := fn(chunk.products); err != nil {
+			return domain.NewServiceError(err, nil)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return domain.NewServiceError(err, nil)
+	}
+	if !passed {
+		if err := fn([]domain.Product{}); err != nil {
+			return domain.NewServiceError(err, nil)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+// slowRangeRepository delays every range read, chunks are done out of order
+// unless delay is fixed
+type slowRangeRepository struct {
+	ports.Repository
+	delay    func() time.Duration
+	inFlight atomic.Int64
+	maxReads atomic.Int64
+}
+
+func (r *slowRangeRepository) GetProductsInRange(ctx context.Context, fromId int64, toId int64) ([]domain.Product, error) {
+	n := r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	for {
+		seen := r.maxReads.Load()
+		if n <= seen || r.maxReads.CompareAndSwap(seen, n) {
+			break
+		}
+	}
+	select {
+	case <-time.After(r.delay()):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return r.Repository.GetProductsInRange(ctx, fromId, toId)
+}
+
+func sparseProducts(n int) []domain.Product {
+	var products []domain.Product
+	for i := range n {
+		// every third id is missing, as after deletes
+		if i%3 != 2 {
+			products = append(products, domain.Product{Id: int64(i + 1), Name: "Product", AdditionalInfo: "Info", Version: 1})
+		}
+	}
+	return products
+}
+
+func TestExportProducts(t *testing.T) {
+	products := sparseProducts(100)
+	repo := &slowRangeRepository{
+		Repository: fakes.NewRepository(products...),
+		delay:      func() time.Duration { return time.Duration(rand.Intn(5)) * time.Millisecond },
+	}
+	svc := NewResourceService(repo, fakes.NewCache(), WithParallelExport(4, 7))
+
+	var exported []domain.Product
+	serviceErr := svc.ExportProducts(context.Background(), func(page []domain.Product) error {
+		assert.NotEmpty(t, page)
+		exported = append(exported, page...)
+		return nil
+	})
+	require.Nil(t, serviceErr)
+	assert.Equal(t, products, exported, "chunks are passed on in order of id")
+	assert.LessOrEqual(t, repo.maxReads.Load(), int64(4))
+	assert.Greater(t, repo.maxReads.Load(), int64(1))
+}
+
+func TestExportProductsEmpty(t *testing.T) {
+	svc := NewResourceService(fakes.NewRepository(), fakes.NewCache(), WithParallelExport(4, 7))
+	var pages [][]domain.Product
+	serviceErr := svc.ExportProducts(context.Background(), func(page []domain.Product) error {
+		pages = append(pages, page)
+		return nil
+	})
+	require.Nil(t, serviceErr)
+	assert.Equal(t, [][]domain.Product{{}}, pages, "one empty page")
+}
+
+func TestExportProductsFailure(t *testing.T) {
+	t.Run("fn failure stops reading", func(t *testing.T) {
+		repo := &slowRangeRepository{
+			Repository: fakes.NewRepository(sparseProducts(100)...),
+			delay:      func() time.Duration { return time.Millisecond },
+		}
+		svc := NewResourceService(repo, fakes.NewCache(), WithParallelExport(4, 7))
+		stop := errors.New("client is gone")
+		pages := 0
+		serviceErr := svc.ExportProducts(context.Background(), func(page []domain.Product) error {
+			pages++
+			return stop
+		})
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, stop)
+		assert.Equal(t, 1, pages)
+		assert.Zero(t, repo.inFlight.Load(), "readers are done once export returns")
+	})
+
+	t.Run("repository failure", func(t *testing.T) {
+		repo := fakes.NewRepository(sparseProducts(100)...)
+		repo.FailWith("GetProductsInRange", domain.ErrInternalDb)
+		svc := NewResourceService(repo, fakes.NewCache(), WithParallelExport(4, 7))
+		serviceErr := svc.ExportProducts(context.Background(), func(page []domain.Product) error { return nil })
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrInternalDb)
+	})
+
+	t.Run("canceled export", func(t *testing.T) {
+		repo := &slowRangeRepository{
+			Repository: fakes.NewRepository(sparseProducts(100)...),
+			delay:      func() time.Duration { return time.Hour },
+		}
+		svc := NewResourceService(repo, fakes.NewCache(), WithParallelExport(4, 7))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		serviceErr := svc.ExportProducts(ctx, func(page []domain.Product) error { return nil })
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, context.DeadlineExceeded)
+	})
+}
+
+// generatedRepository makes up products of ids 1..last on every read,
+// unlike fake repository it does not serialize reads
+type generatedRepository struct {
+	ports.Repository
+	last int64
+}
+
+func (r generatedRepository) ProductIdRange(ctx context.Context) (int64, int64, error) {
+	return 1, r.last, nil
+}
+
+func (r generatedRepository) GetProductsInRange(ctx context.Context, fromId int64, toId int64) ([]domain.Product, error) {
+	products := make([]domain.Product, 0, toId-fromId)
+	for id := fromId; id < min(toId, r.last+1); id++ {
+		products = append(products, domain.Product{Id: id, Name: "Product", AdditionalInfo: "Info"})
+	}
+	return products, nil
+}
+
+// BenchmarkExportProducts reads 20000 products in chunks of 1000, every
+// chunk taking 2ms, roughly a query to a remote database
+func BenchmarkExportProducts(b *testing.B) {
+	repo := &slowRangeRepository{
+		Repository: generatedRepository{last: 20000},
+		delay:      func() time.Duration { return 2 * time.Millisecond },
+	}
+	for _, workers := range []int{1, 4, 8} {
+		svc := NewResourceService(repo, fakes.NewCache(), WithParallelExport(workers, 1000))
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if serviceErr := svc.ExportProducts(context.Background(), func(page []domain.Product) error { return nil }); serviceErr != nil {
+					b.Fatal(serviceErr.CriticalError)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// HealthProbe checks one dependency. OnChange, if set, is called once probes
+// find dependency unhealthy and again once it recovers, e.g. to hold
+// circuit breaker open
+type HealthProbe struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+	OnChange func(healthy bool)
+}
+
+// HealthWatcher probes dependencies in background, so that readiness and
+// degraded modes follow their state instead of requests discovering
+// outages by timing out. Dependency turns unhealthy after threshold
+// consecutive failed probes, or the first one if it has not been checked
+// yet, and healthy again after a successful one
+type HealthWatcher struct {
+	probes    []HealthProbe
+	interval  time.Duration
+	timeout   time.Duration
+	threshold int
+	logger    *slog.Logger
+
+	mu     sync.RWMutex
+	health []domain.ComponentHealth
+}
+
+func NewHealthWatcher(probes []HealthProbe, interval time.Duration, timeout time.Duration, threshold int, logger *slog.Logger) *HealthWatcher {
+	health := make([]domain.ComponentHealth, len(probes))
+	for i, probe := range probes {
+		health[i] = domain.ComponentHealth{Name: probe.Name, Critical: probe.Critical}
+	}
+	return &HealthWatcher{
+		probes:    probes,
+		interval:  interval,
+		timeout:   timeout,
+		threshold: max(threshold, 1),
+		logger:    logger.With(slog.String("component", "health_watcher")),
+		health:    health,
+	}
+}
+
+// Run probes right away and then every interval until ctx is done
+func (w *HealthWatcher) Run(ctx context.Context) error {
+	w.CheckOnce(ctx)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.CheckOnce(ctx)
+		}
+	}
+}
+
+func (w *HealthWatcher) Close() error {
+	return nil
+}
+
+// CheckOnce probes all dependencies concurrently, so one hanging probe does
+// not delay the others
+func (w *HealthWatcher) CheckOnce(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := range w.probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.check(ctx, i)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *HealthWatcher) check(ctx context.Context, i int) {
+	probe := w.probes[i]
+	probeCtx, cancel := context.WithTimeout(ctx, w.timeout)
+	started := time.Now()
+	err := probe.Check(probeCtx)
+	latency := time.Since(started)
+	cancel()
+	// probe cut short by shutdown says nothing of dependency
+	if ctx.Err() != nil {
+		return
+	}
+
+	w.mu.Lock()
+	h := w.health[i]
+	checked, wasHealthy := !h.CheckedAt.IsZero(), h.Healthy
+	h.CheckedAt = time.Now().UTC()
+	h.LatencyMs = float64(latency.Microseconds()) / 1000
+	if err == nil {
+		h.Healthy, h.Failures, h.Error = true, 0, ""
+	} else {
+		h.Failures++
+		h.Error = err.Error()
+		if h.Failures >= w.threshold || !checked {
+			h.Healthy = false
+		}
+	}
+	w.health[i] = h
+	w.mu.Unlock()
+
+	if checked && h.Healthy == wasHealthy {
+		return
+	}
+	switch {
+	case !h.Healthy:
+		w.logger.Warn("dependency is unhealthy",
+			slog.String("dependency", h.Name),
+			slog.Int("failures", h.Failures),
+			slog.String("error", h.Error))
+	case checked:
+		w.logger.Info("dependency recovered", slog.String("dependency", h.Name))
+	}
+	if probe.OnChange != nil {
+		probe.OnChange(h.Healthy)
+	}
+}
+
+// Health returns state of dependencies in order of probes
+func (w *HealthWatcher) Health() []domain.ComponentHealth {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return append([]domain.ComponentHealth{}, w.health...)
+}
+
+// Ready reports whether all critical dependencies are healthy. It is false
+// until they are probed
+func (w *HealthWatcher) Ready() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, h := range w.health {
+		if h.Critical && !h.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// componentBreaker is open while probes find component unhealthy
+type componentBreaker struct {
+	watcher *HealthWatcher
+	i       int
+}
+
+func (b componentBreaker) Open() bool {
+	b.watcher.mu.RLock()
+	defer b.watcher.mu.RUnlock()
+	h := b.watcher.health[b.i]
+	return !h.CheckedAt.IsZero() && !h.Healthy
+}
+
+// Breaker reports state of named dependency as circuit breaker, open while
+// it is known to be unhealthy. Breaker of unknown dependency is never open
+func (w *HealthWatcher) Breaker(name string) ports.CircuitBreaker {
+	for i, probe := range w.probes {
+		if probe.Name == name {
+			return componentBreaker{watcher: w, i: i}
+		}
+	}
+	return neverOpen{}
+}
+
+type neverOpen struct{}
+
+func (neverOpen) Open() bool {
+	return false
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestHealthWatcher(t *testing.T) {
+	ctx := context.Background()
+	var dbErr, cacheErr error
+	var changes []bool
+	watcher := NewHealthWatcher([]HealthProbe{
+		{
+			Name:     "postgres",
+			Critical: true,
+			Check:    func(ctx context.Context) error { return dbErr },
+			OnChange: func(healthy bool) { changes = append(changes, healthy) },
+		},
+		{Name: "redis", Check: func(ctx context.Context) error { return cacheErr }},
+	}, time.Minute, time.Second, 2, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	cacheBreaker := watcher.Breaker("redis")
+
+	assert.False(t, watcher.Ready(), "not ready until probed")
+	assert.False(t, cacheBreaker.Open(), "unprobed dependency is not known to be down")
+
+	cacheErr = errors.New("connection refused")
+	watcher.CheckOnce(ctx)
+	assert.True(t, watcher.Ready(), "non-critical dependency does not matter")
+	assert.True(t, cacheBreaker.Open(), "first probe failing is enough")
+	assert.Equal(t, []bool{true}, changes)
+
+	dbErr = errors.New("connection refused")
+	watcher.CheckOnce(ctx)
+	assert.True(t, watcher.Ready(), "single failure is tolerated")
+	watcher.CheckOnce(ctx)
+	assert.False(t, watcher.Ready())
+	assert.Equal(t, []bool{true, false}, changes)
+	health := watcher.Health()
+	require.Len(t, health, 2)
+	assert.Equal(t, "postgres", health[0].Name)
+	assert.False(t, health[0].Healthy)
+	assert.Equal(t, 2, health[0].Failures)
+	assert.Equal(t, "connection refused", health[0].Error)
+	assert.False(t, health[0].CheckedAt.IsZero())
+
+	dbErr, cacheErr = nil, nil
+	watcher.CheckOnce(ctx)
+	assert.True(t, watcher.Ready())
+	assert.False(t, cacheBreaker.Open())
+	assert.Equal(t, []bool{true, false, true}, changes)
+	assert.False(t, watcher.Breaker("kafka").Open())
+}
+
+func TestHealthWatcherTimeout(t *testing.T) {
+	watcher := NewHealthWatcher([]HealthProbe{{
+		Name:     "postgres",
+		Critical: true,
+		Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}}, time.Minute, 10*time.Millisecond, 1, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	watcher.CheckOnce(context.Background())
+	assert.False(t, watcher.Ready(), "hanging dependency is unhealthy")
+
+	// probes cut short by shutdown are not counted
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	watcher.CheckOnce(ctx)
+	assert.Equal(t, 1, watcher.Health()[0].Failures)
+}
+
+func TestCacheBreakerSkipsCache(t *testing.T) {
+	ctx := context.Background()
+	cache := fakes.NewCache()
+	var cacheCalls int
+	cache.OnCall(func(method string) error {
+		cacheCalls++
+		return nil
+	})
+	repo := fakes.NewRepository(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"})
+	svc := NewResourceService(repo, cache, WithCacheBreaker(stubBreaker(true)))
+
+	res, serviceErr := svc.GetProductById(ctx, 1)
+	require.Nil(t, serviceErr, "cache is neither read nor filled")
+	assert.JSONEq(t, `{"id":1,"name":"First","additionalInfo":"First info","version":1}`, string(res))
+	assert.Zero(t, cacheCalls)
+
+	svc = NewResourceService(repo, cache, WithCacheBreaker(stubBreaker(false)))
+	_, serviceErr = svc.GetProductById(ctx, 1)
+	requireNoCritical(t, serviceErr)
+	assert.Equal(t, 2, cacheCalls, "cache miss is filled")
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// AddProductImage streams image to object store and records it with
+// product, returning the product. Stored object is removed again if it
+// can not be recorded
+func (s *ResourseService) AddProductImage(ctx context.Context, id int64, upload domain.ImageUpload) (_ *domain.Product, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "AddProductImage", attribute.Int64("product.id", id), attribute.Int64("image.size", upload.Size))
+	defer endSpan(span, &serviceErr)
+
+	if s.objects == nil {
+		return nil, domain.NewServiceError(domain.ErrImagesDisabled, nil)
+	}
+	extension, ok := domain.ImageExtension(upload.ContentType)
+	if !ok {
+		return nil, domain.NewServiceError(fmt.Errorf("%w: images of type %q are not accepted", domain.ErrInvalidInput, upload.ContentType), nil)
+	}
+	// image of a missing product would be left in store
+	if _, err := s.db.GetProduct(ctx, id); err != nil {
+		return nil, domain.NewServiceError(err, nil)
+	}
+
+	key := fmt.Sprintf("products/%d/%s%s", id, uuid.NewString(), extension)
+	url, err := s.objects.Put(ctx, key, upload.Body, upload.Size, upload.ContentType)
+	if err != nil {
+		return nil, domain.NewServiceError(err, nil)
+	}
+	image := domain.ProductImage{Key: key, URL: url, ContentType: upload.ContentType, Size: upload.Size}
+	product, serviceErr := s.modify(ctx, id, func(ctx context.Context) (*domain.Product, error) {
+		return s.db.AddProductImage(ctx, id, image)
+	})
+	if serviceErr != nil && serviceErr.CriticalError != nil {
+		if err := s.objects.Delete(context.WithoutCancel(ctx), key); err != nil {
+			serviceErr.NonCriticalErrors = append(serviceErr.NonCriticalErrors, err)
+		}
+	}
+	return product, serviceErr
+}
+
+// deleteImages removes objects of images of deleted product
+func (s *ResourseService) deleteImages(ctx context.Context, images []domain.ProductImage) []error {
+	if s.objects == nil {
+		return nil
+	}
+	var errs []error
+	for _, image := range images {
+		key := image.Key
+		err := s.async(ctx, "image:"+key, func(ctx context.Context) error {
+			return s.objects.Delete(ctx, key)
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestAddProductImage(t *testing.T) {
+	ctx := context.Background()
+	product := domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Info"}
+	png := func() domain.ImageUpload {
+		return domain.ImageUpload{ContentType: "image/png", Size: 3, Body: strings.NewReader("png")}
+	}
+
+	t.Run("upload is stored and invalidates cached product", func(t *testing.T) {
+		cache := fakes.NewCache()
+		objects := fakes.NewObjectStore()
+		svc := NewResourceService(fakes.NewRepository(product), cache, WithObjectStore(objects))
+		readProduct(t, svc, ctx, 1)
+		require.True(t, cache.Has(1))
+
+		uploaded, serviceErr := svc.AddProductImage(ctx, 1, png())
+		requireNoCritical(t, serviceErr)
+		require.Len(t, uploaded.Images, 1)
+		image := uploaded.Images[0]
+		data, ok := objects.Get(image.Key)
+		require.True(t, ok)
+		assert.Equal(t, "png", string(data))
+		assert.Equal(t, fakes.ObjectURL+image.Key, image.URL)
+		assert.Equal(t, int64(2), uploaded.Version)
+		assert.False(t, cache.Has(1))
+		assert.Equal(t, image.URL, readProduct(t, svc, ctx, 1).Images[0].URL)
+	})
+
+	t.Run("unknown product stores nothing", func(t *testing.T) {
+		objects := fakes.NewObjectStore()
+		svc := NewResourceService(fakes.NewRepository(), fakes.NewCache(), WithObjectStore(objects))
+
+		_, serviceErr := svc.AddProductImage(ctx, 1, png())
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrNotFound)
+		assert.Zero(t, objects.Len())
+	})
+
+	t.Run("object is removed if image is not recorded", func(t *testing.T) {
+		repo := fakes.NewRepository(product)
+		repo.FailWith("AddProductImage", domain.ErrInternalDb)
+		objects := fakes.NewObjectStore()
+		svc := NewResourceService(repo, fakes.NewCache(), WithObjectStore(objects))
+
+		_, serviceErr := svc.AddProductImage(ctx, 1, png())
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrInternalDb)
+		assert.Zero(t, objects.Len())
+	})
+
+	t.Run("unsupported image type", func(t *testing.T) {
+		objects := fakes.NewObjectStore()
+		svc := NewResourceService(fakes.NewRepository(product), fakes.NewCache(), WithObjectStore(objects))
+
+		_, serviceErr := svc.AddProductImage(ctx, 1, domain.ImageUpload{ContentType: "image/tiff", Size: 4, Body: strings.NewReader("tiff")})
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrInvalidInput)
+		assert.Zero(t, objects.Len())
+	})
+
+	t.Run("images are disabled without object store", func(t *testing.T) {
+		svc := NewResourceService(fakes.NewRepository(product), fakes.NewCache())
+
+		_, serviceErr := svc.AddProductImage(ctx, 1, png())
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrImagesDisabled)
+	})
+
+	t.Run("deleting product removes its images", func(t *testing.T) {
+		objects := fakes.NewObjectStore()
+		svc := NewResourceService(fakes.NewRepository(product), fakes.NewCache(), WithObjectStore(objects))
+		for range 2 {
+			_, serviceErr := svc.AddProductImage(ctx, 1, png())
+			requireNoCritical(t, serviceErr)
+		}
+		require.Equal(t, 2, objects.Len())
+
+		_, serviceErr := svc.DeleteProductById(ctx, 1)
+		requireNoCritical(t, serviceErr)
+		assert.Zero(t, objects.Len())
+	})
+
+	t.Run("failing image removal does not fail delete", func(t *testing.T) {
+		objects := fakes.NewObjectStore()
+		svc := NewResourceService(fakes.NewRepository(product), fakes.NewCache(), WithObjectStore(objects))
+		_, serviceErr := svc.AddProductImage(ctx, 1, png())
+		requireNoCritical(t, serviceErr)
+		objects.FailWith("Delete", errors.New("unavailable"))
+
+		_, serviceErr = svc.DeleteProductById(ctx, 1)
+		require.NotNil(t, serviceErr)
+		assert.NoError(t, serviceErr.CriticalError)
+		assert.NotEmpty(t, serviceErr.NonCriticalErrors)
+	})
+}
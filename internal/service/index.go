@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// indexKey makes worker pool apply index updates one at a time, in order,
+// so that a product deleted right after an update does not come back
+const indexKey = "index"
+
+// syncIndex brings search index up to date with write event describes
+func (s *ResourseService) syncIndex(ctx context.Context, event domain.ProductEvent) error {
+	switch event.Type {
+	case domain.EventProductCreated, domain.EventProductUpdated:
+		if event.Product == nil {
+			return nil
+		}
+		return s.index.Index(ctx, event.Product)
+	case domain.EventProductDeleted:
+		return s.index.Delete(ctx, event.ProductId)
+	case domain.EventProductsDeletedAll:
+		return s.index.DeleteAll(ctx)
+	}
+	return nil
+}
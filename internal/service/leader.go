@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// LeaderElector keeps competing for leader lease with other instances, so
+// that jobs which must not run concurrently run on a single one
+type LeaderElector struct {
+	lease  ports.LeaderLease
+	ttl    time.Duration
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	leading bool
+	// closed and replaced whenever leadership changes
+	changed chan struct{}
+}
+
+func NewLeaderElector(lease ports.LeaderLease, ttl time.Duration, logger *slog.Logger) *LeaderElector {
+	return &LeaderElector{
+		lease:   lease,
+		ttl:     ttl,
+		logger:  logger.With(slog.String("component", "leader_elector")),
+		changed: make(chan struct{}),
+	}
+}
+
+// Run acquires or renews lease every third of ttl until ctx is done. Lease
+// is released then, so that another instance takes over without waiting for
+// it to expire
+func (e *LeaderElector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		e.campaign(ctx)
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *LeaderElector) Close() error {
+	return nil
+}
+
+func (e *LeaderElector) IsLeader() bool {
+	leading, _ := e.watch()
+	return leading
+}
+
+// watch returns whether instance leads and a channel closed once it changes
+func (e *LeaderElector) watch() (bool, <-chan struct{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leading, e.changed
+}
+
+func (e *LeaderElector) campaign(ctx context.Context) {
+	var held bool
+	var err error
+	if e.IsLeader() {
+		held, err = e.lease.Renew(ctx, e.ttl)
+	} else {
+		held, err = e.lease.Acquire(ctx, e.ttl)
+	}
+	if err != nil {
+		// lease might still be ours, but as it can not be renewed it will
+		// expire and someone else may take over
+		e.logger.Error("failed to hold leader lease", slog.String("error", err.Error()))
+		held = false
+	}
+	e.setLeading(held)
+}
+
+func (e *LeaderElector) resign() {
+	if !e.IsLeader() {
+		return
+	}
+	e.setLeading(false)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := e.lease.Release(ctx); err != nil {
+		e.logger.Error("failed to release leader lease", slog.String("error", err.Error()))
+	}
+}
+
+func (e *LeaderElector) setLeading(leading bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.leading == leading {
+		return
+	}
+	e.leading = leading
+	close(e.changed)
+	e.changed = make(chan struct{})
+	if leading {
+		e.logger.Info("became leader")
+	} else {
+		e.logger.Info("stopped being leader")
+	}
+}
+
+// Job is a background job, such as SupplierSync
+type Job interface {
+	Run(ctx context.Context) error
+	Close() error
+}
+
+// LeaderOnlyJob runs job only while instance is the leader. Job is stopped,
+// by cancelling its context, once leadership is lost and started again when
+// it is regained
+type LeaderOnlyJob struct {
+	elector *LeaderElector
+	job     Job
+}
+
+func LeaderOnly(elector *LeaderElector, job Job) *LeaderOnlyJob {
+	return &LeaderOnlyJob{elector: elector, job: job}
+}
+
+func (l *LeaderOnlyJob) Run(ctx context.Context) error {
+	for {
+		leading, changed := l.elector.watch()
+		if !leading {
+			select {
+			case <-changed:
+				continue
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		jobCtx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() {
+			done <- l.job.Run(jobCtx)
+		}()
+		select {
+		case <-changed:
+			cancel()
+			if err := <-done; err != nil {
+				return err
+			}
+		case err := <-done:
+			cancel()
+			if err != nil {
+				return err
+			}
+			// job is over, nothing to do until it may be started again
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return nil
+			}
+		case <-ctx.Done():
+			cancel()
+			return <-done
+		}
+	}
+}
+
+func (l *LeaderOnlyJob) Close() error {
+	return l.job.Close()
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+// countingJob counts how many of its runs are in progress
+type countingJob struct {
+	running *atomic.Int32
+}
+
+func (j countingJob) Run(ctx context.Context) error {
+	j.running.Add(1)
+	defer j.running.Add(-1)
+	<-ctx.Done()
+	return nil
+}
+
+func (j countingJob) Close() error {
+	return nil
+}
+
+func TestLeaderElection(t *testing.T) {
+	const ttl = 60 * time.Millisecond
+	store := fakes.NewLeaseStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var running atomic.Int32
+
+	start := func(instance string) (*LeaderElector, *fakes.LeaderLease, context.CancelFunc) {
+		lease := store.Lease(instance)
+		elector := NewLeaderElector(lease, ttl, logger)
+		ctx, cancel := context.WithCancel(context.Background())
+		go elector.Run(ctx)
+		go LeaderOnly(elector, countingJob{running: &running}).Run(ctx)
+		return elector, lease, cancel
+	}
+	first, _, stopFirst := start("first")
+	require.Eventually(t, first.IsLeader, time.Second, 5*time.Millisecond)
+	second, secondLease, stopSecond := start("second")
+	defer stopSecond()
+
+	require.Eventually(t, func() bool { return running.Load() == 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(ttl)
+	assert.False(t, second.IsLeader())
+	assert.Equal(t, int32(1), running.Load(), "job runs on leader only")
+
+	// stopped leader releases lease right away
+	stopFirst()
+	require.Eventually(t, second.IsLeader, ttl, 5*time.Millisecond)
+	require.Eventually(t, func() bool { return running.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	// leader unable to renew steps down and stops its job
+	secondLease.FailWith("Renew", domain.ErrInternalCache)
+	secondLease.FailWith("Acquire", domain.ErrInternalCache)
+	require.Eventually(t, func() bool { return !second.IsLeader() }, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool { return running.Load() == 0 }, time.Second, 5*time.Millisecond)
+}
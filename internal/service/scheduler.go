@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// jobHistorySize is how many latest runs are kept per job
+const jobHistorySize = 20
+
+type scheduledJob struct {
+	name     string
+	spec     string
+	schedule Schedule
+	run      func(ctx context.Context) error
+	running  bool
+	next     time.Time
+	history  []domain.JobRun
+}
+
+func (j *scheduledJob) record(run domain.JobRun) {
+	j.history = append([]domain.JobRun{run}, j.history[:min(len(j.history), jobHistorySize-1)]...)
+}
+
+// Scheduler runs registered jobs on their schedules. A run due while the
+// previous one is still in progress is skipped, so runs of a job never
+// overlap
+type Scheduler struct {
+	logger *slog.Logger
+
+	mu   sync.Mutex
+	jobs []*scheduledJob
+}
+
+func NewScheduler(logger *slog.Logger) *Scheduler {
+	return &Scheduler{logger: logger.With(slog.String("component", "scheduler"))}
+}
+
+// Register adds job running on schedule spec, see ParseSchedule. Jobs have
+// to be registered before scheduler is run
+func (s *Scheduler) Register(name string, spec string, run func(ctx context.Context) error) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.name == name {
+			return fmt.Errorf("job %s is already registered", name)
+		}
+	}
+	s.jobs = append(s.jobs, &scheduledJob{name: name, spec: spec, schedule: schedule, run: run})
+	return nil
+}
+
+// Run schedules jobs until ctx is done, then waits for runs in progress,
+// which get cancelled context
+func (s *Scheduler) Run(ctx context.Context) error {
+	var runs sync.WaitGroup
+	defer func() {
+		runs.Wait()
+		// nothing is due until scheduler runs again
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, j := range s.jobs {
+			j.next = time.Time{}
+		}
+	}()
+
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		j.next = j.schedule.Next(time.Now())
+	}
+	s.mu.Unlock()
+	for {
+		timer := time.NewTimer(time.Until(s.nextWakeUp()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		s.mu.Lock()
+		for _, j := range s.jobs {
+			if j.next.IsZero() || j.next.After(now) {
+				continue
+			}
+			j.next = j.schedule.Next(now)
+			if j.running {
+				s.logger.Warn("job skipped, previous run is still in progress", slog.String("job", j.name))
+				j.record(domain.JobRun{StartedAt: now.UTC(), Status: domain.JobSkipped})
+				continue
+			}
+			j.running = true
+			runs.Add(1)
+			go func() {
+				defer runs.Done()
+				s.execute(ctx, j)
+			}()
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *Scheduler) Close() error {
+	return nil
+}
+
+// nextWakeUp is when the earliest job is due, far in the future if none is
+func (s *Scheduler) nextWakeUp() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wakeUp := time.Now().Add(24 * time.Hour)
+	for _, j := range s.jobs {
+		if !j.next.IsZero() && j.next.Before(wakeUp) {
+			wakeUp = j.next
+		}
+	}
+	return wakeUp
+}
+
+func (s *Scheduler) execute(ctx context.Context, j *scheduledJob) {
+	run := domain.JobRun{StartedAt: time.Now().UTC(), Status: domain.JobSucceeded}
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		return j.run(ctx)
+	}()
+	run.Duration = time.Since(run.StartedAt)
+	if err != nil {
+		run.Status = domain.JobFailed
+		run.Error = err.Error()
+		s.logger.Error("job failed", slog.String("job", j.name), slog.String("error", run.Error), slog.Duration("duration", run.Duration))
+	} else {
+		s.logger.Info("job finished", slog.String("job", j.name), slog.Duration("duration", run.Duration))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.running = false
+	j.record(run)
+}
+
+// Jobs returns status of every job in order of registration
+func (s *Scheduler) Jobs() []domain.JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]domain.JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		status := domain.JobStatus{
+			Name:     j.name,
+			Schedule: j.spec,
+			Running:  j.running,
+			History:  append([]domain.JobRun{}, j.history...),
+		}
+		if !j.next.IsZero() {
+			next := j.next.UTC()
+			status.NextRun = &next
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// ParseJobSchedules parses "name=schedule;name=schedule" pairs, semicolons
+// separate them as cron expressions may contain commas
+func ParseJobSchedules(value string) (map[string]string, error) {
+	schedules := make(map[string]string)
+	for _, pair := range strings.Split(value, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(pair, "=")
+		name, spec = strings.TrimSpace(name), strings.TrimSpace(spec)
+		if !ok || name == "" || spec == "" {
+			return nil, fmt.Errorf("invalid job schedule %q, name=schedule expected", pair)
+		}
+		schedules[name] = spec
+	}
+	return schedules, nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+func TestScheduler(t *testing.T) {
+	scheduler := NewScheduler(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	release := make(chan struct{})
+	require.NoError(t, scheduler.Register("slow", "@every 10ms", func(ctx context.Context) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	}))
+	require.NoError(t, scheduler.Register("failing", "@every 10ms", func(ctx context.Context) error {
+		return errors.New("feed unreachable")
+	}))
+	require.NoError(t, scheduler.Register("panicking", "@every 10ms", func(ctx context.Context) error {
+		panic("boom")
+	}))
+	assert.Error(t, scheduler.Register("slow", "@hourly", nil), "duplicate name")
+	assert.Error(t, scheduler.Register("broken", "@sometimes", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- scheduler.Run(ctx) }()
+
+	history := func(job int) []domain.JobRun {
+		return scheduler.Jobs()[job].History
+	}
+	require.Eventually(t, func() bool { return len(history(0)) >= 2 }, time.Second, 5*time.Millisecond)
+	jobs := scheduler.Jobs()
+	assert.Equal(t, "slow", jobs[0].Name)
+	assert.Equal(t, "@every 10ms", jobs[0].Schedule)
+	assert.True(t, jobs[0].Running)
+	assert.NotNil(t, jobs[0].NextRun)
+	for _, run := range jobs[0].History {
+		assert.Equal(t, domain.JobSkipped, run.Status, "runs must not overlap")
+	}
+
+	close(release)
+	require.Eventually(t, func() bool {
+		return len(history(2)) > 0 && history(0)[0].Status == domain.JobSucceeded
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, domain.JobFailed, history(1)[0].Status)
+	assert.Equal(t, "feed unreachable", history(1)[0].Error)
+	assert.Equal(t, domain.JobFailed, history(2)[0].Status)
+	assert.Contains(t, history(2)[0].Error, "boom")
+
+	require.Eventually(t, func() bool { return len(history(1)) == jobHistorySize }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+	for _, job := range scheduler.Jobs() {
+		assert.False(t, job.Running)
+		assert.Nil(t, job.NextRun)
+	}
+}
+
+func TestParseJobSchedules(t *testing.T) {
+	schedules, err := ParseJobSchedules(" supplier-sync = 0 */6 * * 1,3 ; reindex=@daily;")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"supplier-sync": "0 */6 * * 1,3", "reindex": "@daily"}, schedules)
+
+	schedules, err = ParseJobSchedules("")
+	require.NoError(t, err)
+	assert.Empty(t, schedules)
+
+	_, err = ParseJobSchedules("supplier-sync")
+	assert.Error(t, err)
+	_, err = ParseJobSchedules("=@daily")
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestSearchProductsWithoutIndex(t *testing.T) {
+	ctx := context.Background()
+	svc := NewResourceService(fakes.NewRepository(
+		domain.Product{Id: 1, Name: "Desk lamp", AdditionalInfo: "Brass"},
+		domain.Product{Id: 2, Name: "Chair", AdditionalInfo: "Oak"},
+	), fakes.NewCache())
+
+	t.Run("database full-text search serves", func(t *testing.T) {
+		result, serviceErr := svc.SearchProducts(ctx, domain.SearchQuery{Text: "lamp", Limit: 10})
+		requireNoCritical(t, serviceErr)
+		assert.Equal(t, int64(1), result.Total)
+		require.Len(t, result.Hits, 1)
+		assert.Equal(t, int64(1), result.Hits[0].Product.Id)
+		assert.Equal(t, []string{"Desk <em>lamp</em>"}, result.Hits[0].Highlights["name"])
+	})
+
+	t.Run("facets need search index", func(t *testing.T) {
+		_, serviceErr := svc.SearchProducts(ctx, domain.SearchQuery{Text: "lamp", Limit: 10, Facets: []string{"name"}})
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrSearchDisabled)
+	})
+}
+
+func TestSearchIndexSync(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("writes are indexed", func(t *testing.T) {
+		index := fakes.NewSearchIndex()
+		svc := NewResourceService(fakes.NewRepository(), fakes.NewCache(), WithSearchIndex(index))
+
+		id, serviceErr := svc.CreateProduct(ctx, domain.NewProduct{Name: "Desk lamp", AdditionalInfo: "Brass"})
+		requireNoCritical(t, serviceErr)
+		indexed, ok := index.Get(id)
+		require.True(t, ok)
+		assert.Equal(t, "Desk lamp", indexed.Name)
+
+		_, serviceErr = svc.UpdateProductById(ctx, id, domain.NewProduct{Name: "Floor lamp", AdditionalInfo: "Brass"})
+		requireNoCritical(t, serviceErr)
+		_, serviceErr = svc.AddProductTags(ctx, id, []string{"sale"})
+		requireNoCritical(t, serviceErr)
+		indexed, _ = index.Get(id)
+		assert.Equal(t, "Floor lamp", indexed.Name)
+		assert.Equal(t, []string{"sale"}, indexed.Tags)
+		assert.Equal(t, int64(3), indexed.Version)
+
+		result, serviceErr := svc.SearchProducts(ctx, domain.SearchQuery{Text: "floor", Limit: 10, Facets: []string{"name"}})
+		requireNoCritical(t, serviceErr)
+		require.Len(t, result.Hits, 1)
+		assert.Equal(t, []domain.FacetBucket{{Value: "Floor lamp", Count: 1}}, result.Facets["name"])
+
+		_, serviceErr = svc.DeleteProductById(ctx, id)
+		requireNoCritical(t, serviceErr)
+		assert.Zero(t, index.Len())
+	})
+
+	t.Run("deleting all clears index", func(t *testing.T) {
+		index := fakes.NewSearchIndex()
+		svc := NewResourceService(fakes.NewRepository(), fakes.NewCache(), WithSearchIndex(index))
+		_, serviceErr := svc.CreateProducts(ctx, []domain.NewProduct{{Name: "Lamp", AdditionalInfo: "Info"}, {Name: "Desk", AdditionalInfo: "Info"}})
+		requireNoCritical(t, serviceErr)
+		require.Equal(t, 2, index.Len())
+
+		_, serviceErr = svc.DeleteAllProducts(ctx)
+		requireNoCritical(t, serviceErr)
+		assert.Zero(t, index.Len())
+	})
+
+	t.Run("failing index does not fail write", func(t *testing.T) {
+		index := fakes.NewSearchIndex()
+		index.FailWith("Index", domain.ErrInternalIndex)
+		svc := NewResourceService(fakes.NewRepository(), fakes.NewCache(), WithSearchIndex(index))
+
+		_, serviceErr := svc.CreateProduct(ctx, domain.NewProduct{Name: "Lamp", AdditionalInfo: "Info"})
+		require.NotNil(t, serviceErr)
+		assert.NoError(t, serviceErr.CriticalError)
+		require.Len(t, serviceErr.NonCriticalErrors, 1)
+		assert.ErrorIs(t, serviceErr.NonCriticalErrors[0], domain.ErrInternalIndex)
+	})
+}
@@ -5,39 +5,248 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/pelyams/simpler_go_service/internal/domain"
 	"github.com/pelyams/simpler_go_service/internal/ports"
 )
 
 type ResourseService struct {
-	db    ports.Repository
-	cache ports.Cache
+	db        ports.Repository
+	cache     ports.Cache
+	publisher ports.EventPublisher
+	index     ports.SearchIndex
+	objects   ports.ObjectStore
+	metrics   ports.BusinessMetrics
+	breaker   ports.CircuitBreaker
+	pool      ports.WorkerPool
+	tracer    trace.Tracer
+	// open while cache is known to be down
+	cacheBreaker ports.CircuitBreaker
+	// serve cached products while breaker is open
+	degradedReads bool
+	// reject updates naming no version
+	requireVersion bool
+	suggestions    *suggestionCache
+	recentWrites   *recentWrites
+	// export reads id ranges of exportChunk ids on exportWorkers goroutines
+	exportWorkers int
+	exportChunk   int64
+}
+
+type Option func(*ResourseService)
+
+// WithEventPublisher makes service emit product events after successful writes
+func WithEventPublisher(publisher ports.EventPublisher) Option {
+	return func(s *ResourseService) {
+		s.publisher = publisher
+	}
+}
+
+// WithSearchIndex serves SearchProducts from index rather than database.
+// Service keeps index in sync with its writes
+func WithSearchIndex(index ports.SearchIndex) Option {
+	return func(s *ResourseService) {
+		s.index = index
+	}
+}
+
+// WithObjectStore enables product images, which are kept in store
+func WithObjectStore(store ports.ObjectStore) Option {
+	return func(s *ResourseService) {
+		s.objects = store
+	}
+}
+
+func WithBusinessMetrics(metrics ports.BusinessMetrics) Option {
+	return func(s *ResourseService) {
+		s.metrics = metrics
+	}
+}
+
+// WithCircuitBreaker lets service know when database is considered down.
+// Unless degraded reads are enabled, open breaker fails reads right away,
+// including those that could be served from cache
+func WithCircuitBreaker(breaker ports.CircuitBreaker) Option {
+	return func(s *ResourseService) {
+		s.breaker = breaker
+	}
+}
+
+// WithCacheBreaker lets service know when cache is considered down. Reads
+// then go straight to database instead of waiting for cache to time out.
+// Writes still try to invalidate cache, so that no stale entry outlives
+// the outage
+func WithCacheBreaker(breaker ports.CircuitBreaker) Option {
+	return func(s *ResourseService) {
+		s.cacheBreaker = breaker
+	}
+}
+
+// WithDegradedReads keeps serving cached products while circuit breaker is
+// open. Such reads carry domain.ErrStaleRead among non-critical errors
+func WithDegradedReads() Option {
+	return func(s *ResourseService) {
+		s.degradedReads = true
+	}
+}
+
+// WithWorkerPool moves side effects off request path: cache fills after
+// database reads, event publishing and indexing. Events share a single key,
+// so they are still published in order, index updates share another one.
+// Failures are logged by pool instead of being reported as non-critical
+// errors
+func WithWorkerPool(pool ports.WorkerPool) Option {
+	return func(s *ResourseService) {
+		s.pool = pool
+	}
+}
+
+// WithVersionRequired rejects updates that name no version product is
+// expected at with ErrVersionRequired, whichever API they come through
+func WithVersionRequired() Option {
+	return func(s *ResourseService) {
+		s.requireVersion = true
+	}
+}
+
+func NewResourceService(db ports.Repository, cache ports.Cache, opts ...Option) *ResourseService {
+	s := &ResourseService{
+		db:            db,
+		cache:         cache,
+		exportWorkers: 1,
+		exportChunk:   defaultExportChunk,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// eventsKey makes worker pool publish events one at a time, in order
+const eventsKey = "events"
+
+// publish tells publisher and search index of the write, either is skipped
+// if not configured. Failing to publish does not roll back the write, so
+// the error is always non-critical
+func (s *ResourseService) publish(ctx context.Context, eventType string, productId int64, product *domain.Product) error {
+	event := domain.NewProductEvent(eventType, productId, product)
+	if tc, ok := domain.TraceContextFromContext(ctx); ok {
+		event.Traceparent = tc.Traceparent()
+	}
+	var errs []error
+	if s.index != nil {
+		errs = append(errs, s.async(ctx, indexKey, func(ctx context.Context) error {
+			return s.syncIndex(ctx, event)
+		}))
+	}
+	if s.publisher != nil {
+		errs = append(errs, s.async(ctx, eventsKey, func(ctx context.Context) error {
+			return s.publisher.Publish(ctx, event)
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// async submits task to worker pool, if there is one. Otherwise, or once
+// pool is closed on shutdown, task runs right away and its error is returned
+func (s *ResourseService) async(ctx context.Context, key string, task func(ctx context.Context) error) error {
+	if s.pool == nil {
+		return task(ctx)
+	}
+	err := s.pool.Submit(ctx, key, task)
+	if errors.Is(err, domain.ErrPoolClosed) {
+		return task(ctx)
+	}
+	return err
 }
 
-func NewResourceService(db ports.Repository, cache ports.Cache) *ResourseService {
-	return &ResourseService{
-		db:    db,
-		cache: cache,
+// modify runs write of product with id, which returns the product written.
+// Cached product is invalidated beforehand and an update event published after
+func (s *ResourseService) modify(ctx context.Context, id int64, write func(ctx context.Context) (*domain.Product, error)) (*domain.Product, *domain.ServiceError) {
+	var nonCriticalErrors []error
+	cacheErr := s.cache.DeleteProductById(ctx, id)
+	if cacheErr != nil {
+		if errors.Is(cacheErr, domain.ErrNotFound) {
+			nonCriticalErrors = append(nonCriticalErrors, cacheErr)
+		} else {
+			return nil, domain.NewServiceError(cacheErr, nil)
+		}
+	}
+	product, dbErr := write(ctx)
+	if dbErr != nil {
+		return nil, domain.NewServiceError(dbErr, nonCriticalErrors)
+	}
+	s.wrote(id)
+	if err := s.publish(ctx, domain.EventProductUpdated, id, product); err != nil {
+		nonCriticalErrors = append(nonCriticalErrors, err)
+	}
+	if nonCriticalErrors != nil {
+		return product, domain.NewServiceError(nil, nonCriticalErrors)
 	}
+	return product, nil
 }
 
-func (s *ResourseService) GetProductById(ctx context.Context, id int64) ([]byte, *domain.ServiceError) {
+func (s *ResourseService) GetProductById(ctx context.Context, id int64) (_ []byte, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "GetProductById", attribute.Int64("product.id", id))
+	defer endSpan(span, &serviceErr)
+
 	var nonCriticalErrors []error
-	cacheRes, cacheErr := s.cache.GetJSONProductById(ctx, id)
-	if cacheErr == nil {
-		return cacheRes, nil
-	} else {
-		nonCriticalErrors = append(nonCriticalErrors, cacheErr)
+	degraded := s.breaker != nil && s.breaker.Open()
+	if degraded && !s.degradedReads {
+		return nil, domain.NewServiceError(
+			fmt.Errorf("%w: %w: database circuit breaker is open", domain.ErrInternalDb, domain.ErrUnavailable), nil)
 	}
+	// with database down there is nothing fresher than cache
+	fresh := !degraded && s.readFresh(ctx, id)
+	cacheDown := s.cacheBreaker != nil && s.cacheBreaker.Open()
+	if !fresh && !cacheDown {
+		cacheRes, cacheErr := s.cache.GetJSONProductById(ctx, id)
+		if s.metrics != nil {
+			s.metrics.ProductRead(cacheErr == nil)
+		}
+		span.SetAttributes(attribute.Bool("cache.hit", cacheErr == nil))
+		if cacheErr == nil {
+			if degraded {
+				return cacheRes, domain.NewServiceError(nil, []error{
+					fmt.Errorf("%w: product %d", domain.ErrStaleRead, id),
+				})
+			}
+			return cacheRes, nil
+		} else {
+			nonCriticalErrors = append(nonCriticalErrors, cacheErr)
+		}
+	}
+	cacheKey := "product:" + strconv.FormatInt(id, 10)
 	dbRes, dbErr := s.db.GetProduct(ctx, id)
 	if dbErr != nil {
+		if fresh && errors.Is(dbErr, domain.ErrNotFound) {
+			// cache might have been refilled while product was being deleted
+			err := s.async(ctx, cacheKey, func(ctx context.Context) error {
+				if err := s.cache.DeleteProductById(ctx, id); err != nil && !errors.Is(err, domain.ErrNotFound) {
+					return err
+				}
+				return nil
+			})
+			if err != nil {
+				nonCriticalErrors = append(nonCriticalErrors, err)
+			}
+		}
 		return nil, domain.NewServiceError(dbErr, nonCriticalErrors)
 	}
 
-	err := s.cache.SetProduct(ctx, dbRes)
-	if err != nil {
-		nonCriticalErrors = append(nonCriticalErrors, err)
+	// cache fills of a product run in order, the one queued by a fresh read
+	// overwrites those queued by reads that raced with a write
+	if !cacheDown {
+		err := s.async(ctx, cacheKey, func(ctx context.Context) error {
+			return s.cache.SetProduct(ctx, dbRes)
+		})
+		if err != nil {
+			nonCriticalErrors = append(nonCriticalErrors, err)
+		}
 	}
 	res, err := json.Marshal(dbRes)
 	if err != nil {
@@ -59,34 +268,192 @@ func (s *ResourseService) GetAllProducts(ctx context.Context) ([]domain.Product,
 	return products, nil
 }
 
-func (s *ResourseService) GetProductsPaged(ctx context.Context, limit int64, offset int64) ([]domain.Product, *domain.ServiceError) {
+func (s *ResourseService) EachProduct(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, fn func(product domain.Product) error) (serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "EachProduct")
+	defer endSpan(span, &serviceErr)
+
+	if err := s.db.EachProduct(ctx, filter, sort, fn); err != nil {
+		return domain.NewServiceError(err, nil)
+	}
+	return nil
+}
+
+func (s *ResourseService) GetProductsPaged(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, limit int64, offset int64) (_ []domain.Product, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "GetProductsPaged", attribute.Int64("page.limit", limit), attribute.Int64("page.offset", offset))
+	defer endSpan(span, &serviceErr)
+	products, err := s.db.GetProductsPaged(ctx, filter, sort, limit, offset)
+	if err != nil {
+		return nil, domain.NewServiceError(err, nil)
+	}
+	return products, nil
+}
+
+func (s *ResourseService) GetProductsAfter(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, after *domain.Product, limit int64) (_ []domain.Product, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "GetProductsAfter", attribute.Int64("page.limit", limit))
+	defer endSpan(span, &serviceErr)
 
-	products, err := s.db.GetProductsPaged(ctx, limit, offset)
+	products, err := s.db.GetProductsAfter(ctx, filter, sort, after, limit)
 	if err != nil {
 		return nil, domain.NewServiceError(err, nil)
 	}
 	return products, nil
 }
 
-func (s *ResourseService) CreateProduct(ctx context.Context, product domain.NewProduct) (int64, *domain.ServiceError) {
+func (s *ResourseService) CountProducts(ctx context.Context, filter domain.ProductFilter) (_ int64, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "CountProducts")
+	defer endSpan(span, &serviceErr)
+
+	count, err := s.db.CountProducts(ctx, filter)
+	if err != nil {
+		return 0, domain.NewServiceError(err, nil)
+	}
+	return count, nil
+}
+
+// SearchProducts queries search index if there is one, database full-text
+// search otherwise. Fuzzy queries always go to database. Facets are only
+// available from search index
+func (s *ResourseService) SearchProducts(ctx context.Context, query domain.SearchQuery) (_ *domain.SearchResult, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "SearchProducts", attribute.Bool("search.fuzzy", query.Fuzzy))
+	defer endSpan(span, &serviceErr)
+
+	if query.Fuzzy {
+		if len(query.Facets) > 0 {
+			return nil, domain.NewServiceError(
+				fmt.Errorf("%w: facets are not supported by fuzzy search", domain.ErrInvalidInput), nil)
+		}
+		result, err := s.db.FuzzySearchProducts(ctx, query)
+		if err != nil {
+			return nil, domain.NewServiceError(err, nil)
+		}
+		return result, nil
+	}
+	if s.index != nil {
+		result, err := s.index.Query(ctx, query)
+		if err != nil {
+			return nil, domain.NewServiceError(err, nil)
+		}
+		return result, nil
+	}
+	// without search index full-text search of the database serves
+	if len(query.Facets) > 0 {
+		return nil, domain.NewServiceError(
+			fmt.Errorf("%w: facets need search index", domain.ErrSearchDisabled), nil)
+	}
+	result, err := s.db.SearchProducts(ctx, query)
+	if err != nil {
+		return nil, domain.NewServiceError(err, nil)
+	}
+	return result, nil
+}
+
+func (s *ResourseService) CreateProduct(ctx context.Context, product domain.NewProduct) (_ int64, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "CreateProduct")
+	defer endSpan(span, &serviceErr)
+
 	id, dbErr := s.db.StoreProduct(ctx, product)
 	if dbErr != nil {
 		return 0, domain.NewServiceError(dbErr, nil)
 	}
+	if s.metrics != nil {
+		s.metrics.ProductsCreated(1)
+	}
 
 	//lets set product to cache as well for no reason
 	//assuming cache access is fast
 	newlyStoredProduct := domain.Product{
-		Id: id, Name: product.Name, AdditionalInfo: product.AdditionalInfo,
+		Id: id, Name: product.Name, AdditionalInfo: product.AdditionalInfo, Version: 1, CategoryId: product.CategoryId, Sku: product.Sku,
 	}
+	var nonCriticalErrors []error
 	cacheErr := s.cache.SetProduct(ctx, &newlyStoredProduct)
 	if cacheErr != nil {
-		return id, domain.NewServiceError(nil, []error{cacheErr})
+		nonCriticalErrors = append(nonCriticalErrors, cacheErr)
+	}
+	if err := s.publish(ctx, domain.EventProductCreated, id, &newlyStoredProduct); err != nil {
+		nonCriticalErrors = append(nonCriticalErrors, err)
+	}
+	if nonCriticalErrors != nil {
+		return id, domain.NewServiceError(nil, nonCriticalErrors)
 	}
 	return id, nil
 }
 
-func (s *ResourseService) UpdateProductById(ctx context.Context, id int64, product domain.NewProduct) (*domain.Product, *domain.ServiceError) {
+// CreateProducts stores products in a single transaction, then caches all
+// of them in one round trip. Events are published per product
+func (s *ResourseService) CreateProducts(ctx context.Context, products []domain.NewProduct) (_ []int64, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "CreateProducts", attribute.Int("products.count", len(products)))
+	defer endSpan(span, &serviceErr)
+
+	ids, dbErr := s.db.StoreProducts(ctx, products)
+	if dbErr != nil {
+		return nil, domain.NewServiceError(dbErr, nil)
+	}
+	if s.metrics != nil {
+		s.metrics.ProductsCreated(len(ids))
+	}
+
+	stored := make([]domain.Product, len(ids))
+	for i, id := range ids {
+		stored[i] = domain.Product{Id: id, Name: products[i].Name, AdditionalInfo: products[i].AdditionalInfo, Version: 1, CategoryId: products[i].CategoryId, Sku: products[i].Sku}
+	}
+	var nonCriticalErrors []error
+	if cacheErr := s.cache.SetProducts(ctx, stored); cacheErr != nil {
+		nonCriticalErrors = append(nonCriticalErrors, cacheErr)
+	}
+	for i := range stored {
+		if err := s.publish(ctx, domain.EventProductCreated, stored[i].Id, &stored[i]); err != nil {
+			nonCriticalErrors = append(nonCriticalErrors, err)
+		}
+	}
+	if nonCriticalErrors != nil {
+		return ids, domain.NewServiceError(nil, nonCriticalErrors)
+	}
+	return ids, nil
+}
+
+// UpsertProduct creates or overwrites product with given id. Since it is
+// idempotent, it is safe for at-least-once delivery sources like message queues
+func (s *ResourseService) UpsertProduct(ctx context.Context, product domain.Product) (_ bool, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "UpsertProduct", attribute.Int64("product.id", product.Id))
+	defer endSpan(span, &serviceErr)
+
+	var nonCriticalErrors []error
+	cacheErr := s.cache.DeleteProductById(ctx, product.Id)
+	if cacheErr != nil {
+		if errors.Is(cacheErr, domain.ErrNotFound) {
+			nonCriticalErrors = append(nonCriticalErrors, cacheErr)
+		} else {
+			return false, domain.NewServiceError(cacheErr, nil)
+		}
+	}
+	created, dbErr := s.db.UpsertProduct(ctx, product)
+	if dbErr != nil {
+		return false, domain.NewServiceError(dbErr, nonCriticalErrors)
+	}
+	s.wrote(product.Id)
+	eventType := domain.EventProductUpdated
+	if created {
+		eventType = domain.EventProductCreated
+		if s.metrics != nil {
+			s.metrics.ProductsCreated(1)
+		}
+	}
+	if err := s.publish(ctx, eventType, product.Id, &product); err != nil {
+		nonCriticalErrors = append(nonCriticalErrors, err)
+	}
+	if nonCriticalErrors != nil {
+		return created, domain.NewServiceError(nil, nonCriticalErrors)
+	}
+	return created, nil
+}
+
+func (s *ResourseService) UpdateProductById(ctx context.Context, id int64, product domain.NewProduct) (_ *domain.Product, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "UpdateProductById", attribute.Int64("product.id", id))
+	defer endSpan(span, &serviceErr)
+
+	if s.requireVersion && product.Version == 0 {
+		return nil, domain.NewServiceError(fmt.Errorf("%w: update of product %d names no version", domain.ErrVersionRequired, id), nil)
+	}
 	var nonCriticalErrors []error
 	cacheErr := s.cache.DeleteProductById(ctx, id)
 	if cacheErr != nil {
@@ -100,13 +467,52 @@ func (s *ResourseService) UpdateProductById(ctx context.Context, id int64, produ
 	if dbErr != nil {
 		return nil, domain.NewServiceError(dbErr, nonCriticalErrors)
 	}
+	s.wrote(id)
+	updatedProduct := domain.Product{Id: id, Name: product.Name, AdditionalInfo: product.AdditionalInfo, Version: oldProduct.Version + 1, CategoryId: product.CategoryId, Sku: product.Sku, Stock: oldProduct.Stock, Tags: oldProduct.Tags, Images: oldProduct.Images}
+	if err := s.publish(ctx, domain.EventProductUpdated, id, &updatedProduct); err != nil {
+		nonCriticalErrors = append(nonCriticalErrors, err)
+	}
 	if nonCriticalErrors != nil {
 		return oldProduct, domain.NewServiceError(nil, nonCriticalErrors)
 	}
 	return oldProduct, nil
 }
 
-func (s *ResourseService) DeleteProductById(ctx context.Context, id int64) (*domain.Product, *domain.ServiceError) {
+// PatchProduct applies patch to stored product state and writes the result as
+// a regular update, expecting product to still be at the version patched.
+// Unlike UpdateProductById, it returns the new state
+func (s *ResourseService) PatchProduct(ctx context.Context, id int64, patch domain.Patcher) (_ *domain.Product, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "PatchProduct", attribute.Int64("product.id", id))
+	defer endSpan(span, &serviceErr)
+
+	current, dbErr := s.db.GetProduct(ctx, id)
+	if dbErr != nil {
+		return nil, domain.NewServiceError(dbErr, nil)
+	}
+	patched, err := patch(*current)
+	if err != nil {
+		return nil, domain.NewServiceError(err, nil)
+	}
+	if patched.Name == "" || patched.AdditionalInfo == "" {
+		return nil, domain.NewServiceError(fmt.Errorf("%w: product name or additional info is empty", domain.ErrInvalidInput), nil)
+	}
+	if patched.Sku != "" {
+		if err := domain.ValidateSku(patched.Sku); err != nil {
+			return nil, domain.NewServiceError(err, nil)
+		}
+	}
+	_, updateErr := s.UpdateProductById(ctx, id, domain.NewProduct{Name: patched.Name, AdditionalInfo: patched.AdditionalInfo, Version: current.Version, CategoryId: patched.CategoryId, Sku: patched.Sku})
+	if updateErr != nil && updateErr.CriticalError != nil {
+		return nil, updateErr
+	}
+	patched.Id, patched.Version = id, current.Version+1
+	return &patched, updateErr
+}
+
+func (s *ResourseService) DeleteProductById(ctx context.Context, id int64) (_ *domain.Product, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "DeleteProductById", attribute.Int64("product.id", id))
+	defer endSpan(span, &serviceErr)
+
 	var nonCriticalErrors []error
 	cacheErr := s.cache.DeleteProductById(ctx, id)
 	if cacheErr != nil {
@@ -120,13 +526,24 @@ func (s *ResourseService) DeleteProductById(ctx context.Context, id int64) (*dom
 	if dbErr != nil {
 		return nil, domain.NewServiceError(dbErr, nonCriticalErrors)
 	}
+	s.wrote(id)
+	if err := s.publish(ctx, domain.EventProductDeleted, id, deletedProduct); err != nil {
+		nonCriticalErrors = append(nonCriticalErrors, err)
+	}
+	nonCriticalErrors = append(nonCriticalErrors, s.deleteImages(ctx, deletedProduct.Images)...)
 	if nonCriticalErrors != nil {
 		return deletedProduct, domain.NewServiceError(nil, nonCriticalErrors)
 	}
 	return deletedProduct, nil
 }
 
-func (s *ResourseService) DeleteAllProducts(ctx context.Context) (int64, *domain.ServiceError) {
+func (s *ResourseService) DeleteAllProducts(ctx context.Context) (_ int64, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "DeleteAllProducts")
+	defer endSpan(span, &serviceErr)
+
+	if s.metrics != nil {
+		s.metrics.DeleteAllInvoked()
+	}
 	cacheErr := s.cache.ClearCache(ctx)
 	if cacheErr != nil {
 		return 0, domain.NewServiceError(cacheErr, nil)
@@ -136,5 +553,9 @@ func (s *ResourseService) DeleteAllProducts(ctx context.Context) (int64, *domain
 	if dbErr != nil {
 		return 0, domain.NewServiceError(dbErr, nil)
 	}
+	s.wroteAll()
+	if err := s.publish(ctx, domain.EventProductsDeletedAll, 0, nil); err != nil {
+		return rowsDeleted, domain.NewServiceError(nil, []error{err})
+	}
 	return rowsDeleted, nil
 }
@@ -2,12 +2,17 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
 )
 
 type MockRepository struct {
@@ -19,21 +24,86 @@ func (m *MockRepository) GetProduct(ctx context.Context, id int64) (*domain.Prod
 	return args.Get(0).(*domain.Product), args.Error(1)
 }
 
+func (m *MockRepository) GetProductBySku(ctx context.Context, sku string) (*domain.Product, error) {
+	args := m.Called(ctx, sku)
+	return args.Get(0).(*domain.Product), args.Error(1)
+}
+
+func (m *MockRepository) AdjustProductStock(ctx context.Context, id int64, delta int64) (*domain.Product, error) {
+	args := m.Called(ctx, id, delta)
+	return args.Get(0).(*domain.Product), args.Error(1)
+}
+
+func (m *MockRepository) AddProductImage(ctx context.Context, id int64, image domain.ProductImage) (*domain.Product, error) {
+	args := m.Called(ctx, id, image)
+	return args.Get(0).(*domain.Product), args.Error(1)
+}
+
 func (m *MockRepository) GetAllProducts(ctx context.Context) ([]domain.Product, error) {
 	args := m.Called(ctx)
 	return args.Get(0).([]domain.Product), args.Error(1)
 }
 
-func (m *MockRepository) GetProductsPaged(ctx context.Context, limit int64, offset int64) ([]domain.Product, error) {
-	args := m.Called(ctx, limit, offset)
+func (m *MockRepository) EachProduct(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, fn func(product domain.Product) error) error {
+	args := m.Called(ctx, filter, sort, fn)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetProductsPaged(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, limit int64, offset int64) ([]domain.Product, error) {
+	args := m.Called(ctx, filter, sort, limit, offset)
+	return args.Get(0).([]domain.Product), args.Error(1)
+}
+
+func (m *MockRepository) GetProductsAfter(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, after *domain.Product, limit int64) ([]domain.Product, error) {
+	args := m.Called(ctx, filter, sort, after, limit)
 	return args.Get(0).([]domain.Product), args.Error(1)
 }
 
+func (m *MockRepository) CountProducts(ctx context.Context, filter domain.ProductFilter) (int64, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) ProductIdRange(ctx context.Context) (int64, int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockRepository) GetProductsInRange(ctx context.Context, fromId int64, toId int64) ([]domain.Product, error) {
+	args := m.Called(ctx, fromId, toId)
+	return args.Get(0).([]domain.Product), args.Error(1)
+}
+
+func (m *MockRepository) SuggestProductNames(ctx context.Context, prefix string, limit int64) ([]string, error) {
+	args := m.Called(ctx, prefix, limit)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepository) FuzzySearchProducts(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(*domain.SearchResult), args.Error(1)
+}
+
+func (m *MockRepository) SearchProducts(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(*domain.SearchResult), args.Error(1)
+}
+
 func (m *MockRepository) StoreProduct(ctx context.Context, product domain.NewProduct) (int64, error) {
 	args := m.Called(ctx, product)
 	return args.Get(0).(int64), args.Error(1)
 }
 
+func (m *MockRepository) StoreProducts(ctx context.Context, products []domain.NewProduct) ([]int64, error) {
+	args := m.Called(ctx, products)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepository) UpsertProduct(ctx context.Context, product domain.Product) (bool, error) {
+	args := m.Called(ctx, product)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockRepository) UpdateProductById(ctx context.Context, id int64, product domain.NewProduct) (*domain.Product, error) {
 	args := m.Called(ctx, id, product)
 	return args.Get(0).(*domain.Product), args.Error(1)
@@ -44,6 +114,16 @@ func (m *MockRepository) DeleteProductById(ctx context.Context, id int64) (*doma
 	return args.Get(0).(*domain.Product), args.Error(1)
 }
 
+func (m *MockRepository) AddProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, error) {
+	args := m.Called(ctx, id, tags)
+	return args.Get(0).(*domain.Product), args.Error(1)
+}
+
+func (m *MockRepository) RemoveProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, error) {
+	args := m.Called(ctx, id, tags)
+	return args.Get(0).(*domain.Product), args.Error(1)
+}
+
 func (m *MockRepository) DeleteAllProducts(ctx context.Context) (int64, error) {
 	args := m.Called(ctx)
 	return args.Get(0).(int64), args.Error(1)
@@ -57,10 +137,22 @@ func (m *MockCache) SetProduct(ctx context.Context, product *domain.Product) err
 	args := m.Called(ctx, product)
 	return args.Error(0)
 }
+func (m *MockCache) SetProducts(ctx context.Context, products []domain.Product) error {
+	args := m.Called(ctx, products)
+	return args.Error(0)
+}
 func (m *MockCache) GetJSONProductById(ctx context.Context, id int64) ([]byte, error) {
 	args := m.Called(ctx, id)
 	return args.Get(0).([]byte), args.Error(1)
 }
+func (m *MockCache) SetProductSku(ctx context.Context, sku string, id int64) error {
+	args := m.Called(ctx, sku, id)
+	return args.Error(0)
+}
+func (m *MockCache) GetProductIdBySku(ctx context.Context, sku string) (int64, error) {
+	args := m.Called(ctx, sku)
+	return args.Get(0).(int64), args.Error(1)
+}
 func (m *MockCache) DeleteProductById(ctx context.Context, id int64) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
@@ -124,7 +216,7 @@ func (suite *ServiceTestSuite) TestGetProductById() {
 		{
 			name:           "Product not in cache but found in storage",
 			productId:      2,
-			expectedResult: []byte(`{"id":2,"name":"Stored Product","additionalInfo":"Additional info for stored product"}`),
+			expectedResult: []byte(`{"id":2,"name":"Stored Product","additionalInfo":"Additional info for stored product","version":1}`),
 			expectedError:  &domain.ServiceError{CriticalError: nil, NonCriticalErrors: []error{domain.ErrNotFound}},
 			setupMocks: func() {
 				suite.mockCache.On("GetJSONProductById", suite.ctx, int64(2)).Return([]byte(nil), domain.ErrNotFound).Once()
@@ -132,11 +224,13 @@ func (suite *ServiceTestSuite) TestGetProductById() {
 					Id:             int64(2),
 					Name:           "Stored Product",
 					AdditionalInfo: "Additional info for stored product",
+					Version:        1,
 				}).Return(nil).Once()
 				suite.mockRepository.On("GetProduct", suite.ctx, int64(2)).Return(&domain.Product{
 					Id:             int64(2),
 					Name:           "Stored Product",
 					AdditionalInfo: "Additional info for stored product",
+					Version:        1,
 				}, nil).Once()
 			},
 		},
@@ -163,7 +257,7 @@ func (suite *ServiceTestSuite) TestGetProductById() {
 		{
 			name:           "Product found in storage, cache returns internal error",
 			productId:      5,
-			expectedResult: []byte(`{"id":5,"name":"Stored Product","additionalInfo":"Additional info for stored product"}`),
+			expectedResult: []byte(`{"id":5,"name":"Stored Product","additionalInfo":"Additional info for stored product","version":1}`),
 			expectedError:  &domain.ServiceError{CriticalError: nil, NonCriticalErrors: []error{domain.ErrInternalCache}},
 			setupMocks: func() {
 				suite.mockCache.On("GetJSONProductById", suite.ctx, int64(5)).Return([]byte(nil), domain.ErrInternalCache).Once()
@@ -171,10 +265,11 @@ func (suite *ServiceTestSuite) TestGetProductById() {
 					Id:             int64(5),
 					Name:           "Stored Product",
 					AdditionalInfo: "Additional info for stored product",
+					Version:        1,
 				}).Return(nil).Once()
 				suite.mockRepository.On("GetProduct", suite.ctx, int64(5)).Return(&domain.Product{
 					Id: 5, Name: "Stored Product",
-					AdditionalInfo: "Additional info for stored product"}, nil).Once()
+					AdditionalInfo: "Additional info for stored product", Version: 1}, nil).Once()
 			},
 		},
 		{
@@ -277,7 +372,7 @@ func (suite *ServiceTestSuite) TestGetProductsPaged() {
 			},
 			expectedError: nil,
 			setupMocks: func() {
-				suite.mockRepository.On("GetProductsPaged", suite.ctx, int64(3), int64(3)).Return([]domain.Product{
+				suite.mockRepository.On("GetProductsPaged", suite.ctx, domain.ProductFilter{}, domain.ProductSort(nil), int64(3), int64(3)).Return([]domain.Product{
 					{Id: 4, Name: "Stored Product", AdditionalInfo: "Additional info for stored product"},
 					{Id: 5, Name: "Stored Product", AdditionalInfo: "Additional info for stored product"},
 					{Id: 6, Name: "Stored Product", AdditionalInfo: "Additional info for stored product"},
@@ -291,7 +386,7 @@ func (suite *ServiceTestSuite) TestGetProductsPaged() {
 			expectedResult: nil,
 			expectedError:  &domain.ServiceError{CriticalError: domain.ErrInternalDb, NonCriticalErrors: nil},
 			setupMocks: func() {
-				suite.mockRepository.On("GetProductsPaged", suite.ctx, int64(3), int64(6)).Return([]domain.Product(nil), domain.ErrInternalDb).Once()
+				suite.mockRepository.On("GetProductsPaged", suite.ctx, domain.ProductFilter{}, domain.ProductSort(nil), int64(3), int64(6)).Return([]domain.Product(nil), domain.ErrInternalDb).Once()
 			},
 		},
 	}
@@ -299,7 +394,7 @@ func (suite *ServiceTestSuite) TestGetProductsPaged() {
 	for _, tc := range testCases {
 		suite.Run(tc.name, func() {
 			tc.setupMocks()
-			result, err := suite.service.GetProductsPaged(suite.ctx, tc.limit, tc.offset)
+			result, err := suite.service.GetProductsPaged(suite.ctx, domain.ProductFilter{}, nil, tc.limit, tc.offset)
 			if tc.expectedError != nil {
 				suite.Error(err)
 				suite.EqualError(err, tc.expectedError.Error())
@@ -312,6 +407,18 @@ func (suite *ServiceTestSuite) TestGetProductsPaged() {
 
 }
 
+func (suite *ServiceTestSuite) TestCountProducts() {
+	filter := domain.ProductFilter{Name: "Lamp"}
+	suite.mockRepository.On("CountProducts", suite.ctx, filter).Return(int64(2), nil).Once()
+	count, err := suite.service.CountProducts(suite.ctx, filter)
+	suite.Nil(err)
+	suite.Equal(int64(2), count)
+
+	suite.mockRepository.On("CountProducts", suite.ctx, filter).Return(int64(0), domain.ErrInternalDb).Once()
+	_, err = suite.service.CountProducts(suite.ctx, filter)
+	suite.ErrorIs(err.CriticalError, domain.ErrInternalDb)
+}
+
 func (suite *ServiceTestSuite) TestCreateProduct() {
 	testCases := []struct {
 		name           string
@@ -349,6 +456,7 @@ func (suite *ServiceTestSuite) TestCreateProduct() {
 						Id:             int64(1),
 						Name:           "New product to be stored",
 						AdditionalInfo: "Product description",
+						Version:        1,
 					},
 				).Return(nil).Once()
 			},
@@ -369,7 +477,7 @@ func (suite *ServiceTestSuite) TestCreateProduct() {
 			},
 			setupMocks: func() {
 				suite.mockRepository.On("StoreProduct", suite.ctx, domain.NewProduct{Name: "New product to be stored", AdditionalInfo: "Product description"}).Return(int64(2), nil).Once()
-				suite.mockCache.On("SetProduct", suite.ctx, &domain.Product{Id: int64(2), Name: "New product to be stored", AdditionalInfo: "Product description"}).Return(domain.ErrInternalCache).Once()
+				suite.mockCache.On("SetProduct", suite.ctx, &domain.Product{Id: int64(2), Name: "New product to be stored", AdditionalInfo: "Product description", Version: 1}).Return(domain.ErrInternalCache).Once()
 			},
 		},
 		{
@@ -407,6 +515,137 @@ func (suite *ServiceTestSuite) TestCreateProduct() {
 
 }
 
+func (suite *ServiceTestSuite) TestCreateProducts() {
+	products := []domain.NewProduct{
+		{Name: "First", AdditionalInfo: "First description"},
+		{Name: "Second", AdditionalInfo: "Second description"},
+	}
+	stored := []domain.Product{
+		{Id: 7, Name: "First", AdditionalInfo: "First description", Version: 1},
+		{Id: 8, Name: "Second", AdditionalInfo: "Second description", Version: 1},
+	}
+	testCases := []struct {
+		name           string
+		expectedResult []int64
+		expectedError  error
+		setupMocks     func()
+	}{
+		{
+			name:           "Create products - stored and cached at once",
+			expectedResult: []int64{7, 8},
+			setupMocks: func() {
+				suite.mockRepository.On("StoreProducts", suite.ctx, products).Return([]int64{7, 8}, nil).Once()
+				suite.mockCache.On("SetProducts", suite.ctx, stored).Return(nil).Once()
+			},
+		},
+		{
+			name:           "Create products - stored, cache internal error",
+			expectedResult: []int64{7, 8},
+			expectedError: &domain.ServiceError{
+				NonCriticalErrors: []error{domain.ErrInternalCache},
+			},
+			setupMocks: func() {
+				suite.mockRepository.On("StoreProducts", suite.ctx, products).Return([]int64{7, 8}, nil).Once()
+				suite.mockCache.On("SetProducts", suite.ctx, stored).Return(domain.ErrInternalCache).Once()
+			},
+		},
+		{
+			name: "Create products - db internal error",
+			expectedError: &domain.ServiceError{
+				CriticalError: domain.ErrInternalDb,
+			},
+			setupMocks: func() {
+				suite.mockRepository.On("StoreProducts", suite.ctx, products).Return([]int64(nil), domain.ErrInternalDb).Once()
+			},
+		},
+	}
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			tc.setupMocks()
+			result, err := suite.service.CreateProducts(suite.ctx, products)
+			if tc.expectedError != nil {
+				suite.Error(err)
+				suite.EqualError(err, tc.expectedError.Error())
+			} else {
+				suite.Nil(err)
+			}
+			suite.Equal(tc.expectedResult, result)
+		})
+	}
+}
+
+func (suite *ServiceTestSuite) TestUpsertProduct() {
+	product := domain.Product{
+		Id:             12,
+		Name:           "Upserted product",
+		AdditionalInfo: "Upserted product description",
+	}
+	testCases := []struct {
+		name           string
+		expectedResult bool
+		expectedError  error
+		setupMocks     func()
+	}{
+		{
+			name:           "Product upserted - created, no errors",
+			expectedResult: true,
+			expectedError:  nil,
+			setupMocks: func() {
+				suite.mockCache.On("DeleteProductById", suite.ctx, int64(12)).Return(nil).Once()
+				suite.mockRepository.On("UpsertProduct", suite.ctx, product).Return(true, nil).Once()
+			},
+		},
+		{
+			name:           "Product upserted - overwritten, cache miss",
+			expectedResult: false,
+			expectedError: &domain.ServiceError{
+				CriticalError:     nil,
+				NonCriticalErrors: []error{domain.ErrNotFound},
+			},
+			setupMocks: func() {
+				suite.mockCache.On("DeleteProductById", suite.ctx, int64(12)).Return(domain.ErrNotFound).Once()
+				suite.mockRepository.On("UpsertProduct", suite.ctx, product).Return(false, nil).Once()
+			},
+		},
+		{
+			name:           "Product upsert - cache returns internal error",
+			expectedResult: false,
+			expectedError: &domain.ServiceError{
+				CriticalError:     domain.ErrInternalCache,
+				NonCriticalErrors: nil,
+			},
+			setupMocks: func() {
+				suite.mockCache.On("DeleteProductById", suite.ctx, int64(12)).Return(domain.ErrInternalCache).Once()
+			},
+		},
+		{
+			name:           "Product upsert - db returns internal error",
+			expectedResult: false,
+			expectedError: &domain.ServiceError{
+				CriticalError:     domain.ErrInternalDb,
+				NonCriticalErrors: nil,
+			},
+			setupMocks: func() {
+				suite.mockCache.On("DeleteProductById", suite.ctx, int64(12)).Return(nil).Once()
+				suite.mockRepository.On("UpsertProduct", suite.ctx, product).Return(false, domain.ErrInternalDb).Once()
+			},
+		},
+	}
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			tc.setupMocks()
+			result, err := suite.service.UpsertProduct(suite.ctx, product)
+			if tc.expectedError != nil {
+				suite.Error(err)
+				suite.EqualError(err, tc.expectedError.Error())
+			} else {
+				suite.Nil(err)
+			}
+			suite.Equal(tc.expectedResult, result)
+		})
+	}
+}
+
 func (suite *ServiceTestSuite) TestUpdateProductById() {
 	testCases := []struct {
 		name           string
@@ -687,3 +926,336 @@ func (suite *ServiceTestSuite) TestDeleteAllProducts() {
 		})
 	}
 }
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) Publish(ctx context.Context, event domain.ProductEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockPublisher) Close() error {
+	return nil
+}
+
+func eventOfType(eventType string, productId int64) interface{} {
+	return mock.MatchedBy(func(e domain.ProductEvent) bool {
+		return e.Type == eventType && e.ProductId == productId && e.Id != ""
+	})
+}
+
+func TestEventPublishing(t *testing.T) {
+	ctx := context.Background()
+	newProduct := domain.NewProduct{Name: "Product", AdditionalInfo: "Description"}
+
+	testCases := []struct {
+		name          string
+		expectedError error
+		setupMocks    func(repo *MockRepository, cache *MockCache, publisher *MockPublisher)
+		call          func(s *ResourseService) *domain.ServiceError
+	}{
+		{
+			name: "create product - event published",
+			setupMocks: func(repo *MockRepository, cache *MockCache, publisher *MockPublisher) {
+				repo.On("StoreProduct", ctx, newProduct).Return(int64(1), nil).Once()
+				cache.On("SetProduct", ctx, &domain.Product{Id: 1, Name: "Product", AdditionalInfo: "Description", Version: 1}).Return(nil).Once()
+				publisher.On("Publish", ctx, eventOfType(domain.EventProductCreated, 1)).Return(nil).Once()
+			},
+			call: func(s *ResourseService) *domain.ServiceError {
+				_, err := s.CreateProduct(ctx, newProduct)
+				return err
+			},
+		},
+		{
+			name: "update product - publishing failure is non-critical",
+			expectedError: &domain.ServiceError{
+				CriticalError:     nil,
+				NonCriticalErrors: []error{domain.ErrPublishEvent},
+			},
+			setupMocks: func(repo *MockRepository, cache *MockCache, publisher *MockPublisher) {
+				cache.On("DeleteProductById", ctx, int64(2)).Return(nil).Once()
+				repo.On("UpdateProductById", ctx, int64(2), newProduct).Return(&domain.Product{Id: 2}, nil).Once()
+				publisher.On("Publish", ctx, eventOfType(domain.EventProductUpdated, 2)).Return(domain.ErrPublishEvent).Once()
+			},
+			call: func(s *ResourseService) *domain.ServiceError {
+				_, err := s.UpdateProductById(ctx, 2, newProduct)
+				return err
+			},
+		},
+		{
+			name: "delete product - event published",
+			setupMocks: func(repo *MockRepository, cache *MockCache, publisher *MockPublisher) {
+				cache.On("DeleteProductById", ctx, int64(3)).Return(nil).Once()
+				repo.On("DeleteProductById", ctx, int64(3)).Return(&domain.Product{Id: 3}, nil).Once()
+				publisher.On("Publish", ctx, eventOfType(domain.EventProductDeleted, 3)).Return(nil).Once()
+			},
+			call: func(s *ResourseService) *domain.ServiceError {
+				_, err := s.DeleteProductById(ctx, 3)
+				return err
+			},
+		},
+		{
+			name: "delete product - no event on db failure",
+			expectedError: &domain.ServiceError{
+				CriticalError:     domain.ErrInternalDb,
+				NonCriticalErrors: nil,
+			},
+			setupMocks: func(repo *MockRepository, cache *MockCache, publisher *MockPublisher) {
+				cache.On("DeleteProductById", ctx, int64(4)).Return(nil).Once()
+				repo.On("DeleteProductById", ctx, int64(4)).Return((*domain.Product)(nil), domain.ErrInternalDb).Once()
+			},
+			call: func(s *ResourseService) *domain.ServiceError {
+				_, err := s.DeleteProductById(ctx, 4)
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, cache, publisher := new(MockRepository), new(MockCache), new(MockPublisher)
+			tc.setupMocks(repo, cache, publisher)
+			s := NewResourceService(repo, cache, WithEventPublisher(publisher))
+
+			err := tc.call(s)
+			if tc.expectedError != nil {
+				assert.EqualError(t, err, tc.expectedError.Error())
+			} else {
+				assert.Nil(t, err)
+			}
+			repo.AssertExpectations(t)
+			cache.AssertExpectations(t)
+			publisher.AssertExpectations(t)
+		})
+	}
+}
+
+type MockBusinessMetrics struct {
+	mock.Mock
+}
+
+func (m *MockBusinessMetrics) ProductsCreated(n int) {
+	m.Called(n)
+}
+
+func (m *MockBusinessMetrics) ProductRead(cacheHit bool) {
+	m.Called(cacheHit)
+}
+
+func (m *MockBusinessMetrics) DeleteAllInvoked() {
+	m.Called()
+}
+
+func TestBusinessMetrics(t *testing.T) {
+	ctx := context.Background()
+	newProduct := domain.NewProduct{Name: "Product", AdditionalInfo: "Description"}
+
+	testCases := []struct {
+		name       string
+		setupMocks func(repo *MockRepository, cache *MockCache, metrics *MockBusinessMetrics)
+		call       func(s *ResourseService)
+	}{
+		{
+			name: "get product - cache hit",
+			setupMocks: func(repo *MockRepository, cache *MockCache, metrics *MockBusinessMetrics) {
+				cache.On("GetJSONProductById", ctx, int64(1)).Return([]byte(`{"id":1}`), nil).Once()
+				metrics.On("ProductRead", true).Once()
+			},
+			call: func(s *ResourseService) { s.GetProductById(ctx, 1) },
+		},
+		{
+			name: "get product - cache miss",
+			setupMocks: func(repo *MockRepository, cache *MockCache, metrics *MockBusinessMetrics) {
+				cache.On("GetJSONProductById", ctx, int64(1)).Return([]byte(nil), domain.ErrNotFound).Once()
+				repo.On("GetProduct", ctx, int64(1)).Return(&domain.Product{Id: 1}, nil).Once()
+				cache.On("SetProduct", ctx, &domain.Product{Id: 1}).Return(nil).Once()
+				metrics.On("ProductRead", false).Once()
+			},
+			call: func(s *ResourseService) { s.GetProductById(ctx, 1) },
+		},
+		{
+			name: "create product - counted",
+			setupMocks: func(repo *MockRepository, cache *MockCache, metrics *MockBusinessMetrics) {
+				repo.On("StoreProduct", ctx, newProduct).Return(int64(1), nil).Once()
+				cache.On("SetProduct", ctx, &domain.Product{Id: 1, Name: "Product", AdditionalInfo: "Description", Version: 1}).Return(nil).Once()
+				metrics.On("ProductsCreated", 1).Once()
+			},
+			call: func(s *ResourseService) { s.CreateProduct(ctx, newProduct) },
+		},
+		{
+			name: "create product - not counted on db failure",
+			setupMocks: func(repo *MockRepository, cache *MockCache, metrics *MockBusinessMetrics) {
+				repo.On("StoreProduct", ctx, newProduct).Return(int64(0), domain.ErrInternalDb).Once()
+			},
+			call: func(s *ResourseService) { s.CreateProduct(ctx, newProduct) },
+		},
+		{
+			name: "upsert product - only creation counted",
+			setupMocks: func(repo *MockRepository, cache *MockCache, metrics *MockBusinessMetrics) {
+				product := domain.Product{Id: 2, Name: "Product", AdditionalInfo: "Description"}
+				cache.On("DeleteProductById", ctx, int64(2)).Return(nil).Twice()
+				repo.On("UpsertProduct", ctx, product).Return(true, nil).Once()
+				repo.On("UpsertProduct", ctx, product).Return(false, nil).Once()
+				metrics.On("ProductsCreated", 1).Once()
+			},
+			call: func(s *ResourseService) {
+				product := domain.Product{Id: 2, Name: "Product", AdditionalInfo: "Description"}
+				s.UpsertProduct(ctx, product)
+				s.UpsertProduct(ctx, product)
+			},
+		},
+		{
+			name: "delete all products - invocation counted",
+			setupMocks: func(repo *MockRepository, cache *MockCache, metrics *MockBusinessMetrics) {
+				cache.On("ClearCache", ctx).Return(nil).Once()
+				repo.On("DeleteAllProducts", ctx).Return(int64(3), nil).Once()
+				metrics.On("DeleteAllInvoked").Once()
+			},
+			call: func(s *ResourseService) { s.DeleteAllProducts(ctx) },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, cache, metrics := new(MockRepository), new(MockCache), new(MockBusinessMetrics)
+			tc.setupMocks(repo, cache, metrics)
+			s := NewResourceService(repo, cache, WithBusinessMetrics(metrics))
+
+			tc.call(s)
+			repo.AssertExpectations(t)
+			cache.AssertExpectations(t)
+			metrics.AssertExpectations(t)
+		})
+	}
+}
+
+type stubBreaker bool
+
+func (b stubBreaker) Open() bool {
+	return bool(b)
+}
+
+func TestDegradedReads(t *testing.T) {
+	ctx := context.Background()
+	cached := []byte(`{"id":1,"name":"Product","additionalInfo":"Description"}`)
+
+	testCases := []struct {
+		name          string
+		breakerOpen   bool
+		degradedReads bool
+		setupMocks    func(repo *MockRepository, cache *MockCache)
+		expectedRes   []byte
+		expectedErr   error
+		expectedStale bool
+	}{
+		{
+			name: "breaker closed - cache hit",
+			setupMocks: func(repo *MockRepository, cache *MockCache) {
+				cache.On("GetJSONProductById", ctx, int64(1)).Return(cached, nil).Once()
+			},
+			expectedRes: cached,
+		},
+		{
+			name:        "breaker open - reads fail without degraded mode",
+			breakerOpen: true,
+			setupMocks:  func(repo *MockRepository, cache *MockCache) {},
+			expectedErr: domain.ErrUnavailable,
+		},
+		{
+			name:          "breaker open - cache hit served as stale",
+			breakerOpen:   true,
+			degradedReads: true,
+			setupMocks: func(repo *MockRepository, cache *MockCache) {
+				cache.On("GetJSONProductById", ctx, int64(1)).Return(cached, nil).Once()
+			},
+			expectedRes:   cached,
+			expectedStale: true,
+		},
+		{
+			name:          "breaker open - cache miss still fails",
+			breakerOpen:   true,
+			degradedReads: true,
+			setupMocks: func(repo *MockRepository, cache *MockCache) {
+				cache.On("GetJSONProductById", ctx, int64(1)).Return([]byte(nil), domain.ErrNotFound).Once()
+				repo.On("GetProduct", ctx, int64(1)).Return((*domain.Product)(nil), domain.ErrUnavailable).Once()
+			},
+			expectedErr: domain.ErrUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			cache := new(MockCache)
+			tc.setupMocks(repo, cache)
+			opts := []Option{WithCircuitBreaker(stubBreaker(tc.breakerOpen))}
+			if tc.degradedReads {
+				opts = append(opts, WithDegradedReads())
+			}
+			s := NewResourceService(repo, cache, opts...)
+
+			res, serviceErr := s.GetProductById(ctx, 1)
+			assert.Equal(t, tc.expectedRes, res)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, serviceErr.CriticalError, tc.expectedErr)
+			} else if serviceErr != nil {
+				assert.NoError(t, serviceErr.CriticalError)
+			}
+			stale := serviceErr != nil && errors.Is(errors.Join(serviceErr.NonCriticalErrors...), domain.ErrStaleRead)
+			assert.Equal(t, tc.expectedStale, stale)
+			repo.AssertExpectations(t)
+			cache.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSuggestionCache(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepository)
+	repo.On("SuggestProductNames", ctx, "La", int64(5)).Return([]string{"Lamp", "laptop"}, nil).Twice()
+	repo.On("SuggestProductNames", ctx, "la", int64(10)).Return([]string{"Lamp"}, nil).Once()
+	svc := NewResourceService(repo, new(MockCache), WithSuggestionCache(time.Minute, 10))
+	now := time.Now()
+	svc.suggestions.now = func() time.Time { return now }
+
+	for range 2 {
+		names, err := svc.SuggestProductNames(ctx, "La", 5)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Lamp", "laptop"}, names)
+	}
+	// same prefix with different limit is a different list
+	names, err := svc.SuggestProductNames(ctx, "la", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Lamp"}, names)
+
+	now = now.Add(time.Minute)
+	names, err = svc.SuggestProductNames(ctx, "La", 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Lamp", "laptop"}, names)
+	repo.AssertExpectations(t)
+}
+
+func TestVersionRequired(t *testing.T) {
+	ctx := context.Background()
+	product := domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Info", Version: 1}
+	repo := fakes.NewRepository(product)
+	s := NewResourceService(repo, fakes.NewCache(), WithVersionRequired())
+
+	_, serviceErr := s.UpdateProductById(ctx, 1, domain.NewProduct{Name: "Lamp", AdditionalInfo: "Brass"})
+	require.NotNil(t, serviceErr)
+	assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrVersionRequired)
+	assert.Equal(t, "Info", repo.Products()[0].AdditionalInfo)
+
+	_, serviceErr = s.UpdateProductById(ctx, 1, domain.NewProduct{Name: "Lamp", AdditionalInfo: "Brass", Version: 1})
+	requireNoCritical(t, serviceErr)
+	assert.Equal(t, "Brass", repo.Products()[0].AdditionalInfo)
+
+	patched, serviceErr := s.PatchProduct(ctx, 1, func(p domain.Product) (domain.Product, error) {
+		p.AdditionalInfo = "Copper"
+		return p, nil
+	})
+	requireNoCritical(t, serviceErr)
+	assert.Equal(t, "Copper", patched.AdditionalInfo)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// GetProductBySku reads product with sku, answering from cache when the id
+// sku was last seen with still has it. Cache only maps skus to ids, products
+// themselves are cached under their own keys
+func (s *ResourseService) GetProductBySku(ctx context.Context, sku string) (_ []byte, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "GetProductBySku", attribute.String("product.sku", sku))
+	defer endSpan(span, &serviceErr)
+
+	var nonCriticalErrors []error
+	cacheDown := s.cacheBreaker != nil && s.cacheBreaker.Open()
+	if !cacheDown && !domain.FreshReadRequested(ctx) {
+		id, cacheErr := s.cache.GetProductIdBySku(ctx, sku)
+		span.SetAttributes(attribute.Bool("cache.hit", cacheErr == nil))
+		if cacheErr == nil {
+			res, readErr := s.GetProductById(ctx, id)
+			if readErr != nil {
+				nonCriticalErrors = append(nonCriticalErrors, readErr.NonCriticalErrors...)
+			}
+			if readErr == nil || readErr.CriticalError == nil {
+				var product domain.Product
+				if err := json.Unmarshal(res, &product); err == nil && product.Sku == sku {
+					if nonCriticalErrors != nil {
+						return res, domain.NewServiceError(nil, nonCriticalErrors)
+					}
+					return res, nil
+				}
+			}
+			// sku has moved to another product or the product is gone
+		} else {
+			nonCriticalErrors = append(nonCriticalErrors, cacheErr)
+		}
+	}
+
+	dbRes, dbErr := s.db.GetProductBySku(ctx, sku)
+	if dbErr != nil {
+		return nil, domain.NewServiceError(dbErr, nonCriticalErrors)
+	}
+	if !cacheDown {
+		err := s.async(ctx, "product:"+strconv.FormatInt(dbRes.Id, 10), func(ctx context.Context) error {
+			return s.cache.SetProduct(ctx, dbRes)
+		})
+		if err != nil {
+			nonCriticalErrors = append(nonCriticalErrors, err)
+		}
+		err = s.async(ctx, "sku:"+sku, func(ctx context.Context) error {
+			return s.cache.SetProductSku(ctx, sku, dbRes.Id)
+		})
+		if err != nil {
+			nonCriticalErrors = append(nonCriticalErrors, err)
+		}
+	}
+	res, err := json.Marshal(dbRes)
+	if err != nil {
+		marshallingErr := fmt.Errorf("service layer error: %w", err)
+		nonCriticalErrors = append(nonCriticalErrors, marshallingErr)
+		return nil, domain.NewServiceError(marshallingErr, nonCriticalErrors)
+	}
+	if nonCriticalErrors != nil {
+		return res, domain.NewServiceError(nil, nonCriticalErrors)
+	}
+	return res, nil
+}
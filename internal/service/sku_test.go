@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestGetProductBySku(t *testing.T) {
+	ctx := context.Background()
+	product := domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Info", Sku: "LMP-1"}
+	readBySku := func(t *testing.T, svc *ResourseService, sku string) domain.Product {
+		t.Helper()
+		res, serviceErr := svc.GetProductBySku(ctx, sku)
+		requireNoCritical(t, serviceErr)
+		var product domain.Product
+		require.NoError(t, json.Unmarshal(res, &product))
+		return product
+	}
+
+	t.Run("sku is served from cache once read", func(t *testing.T) {
+		repo := fakes.NewRepository(product)
+		svc := NewResourceService(repo, fakes.NewCache())
+		assert.Equal(t, int64(1), readBySku(t, svc, "LMP-1").Id)
+
+		repo.FailWith("GetProductBySku", errors.New("db is down"))
+		repo.FailWith("GetProduct", errors.New("db is down"))
+		assert.Equal(t, int64(1), readBySku(t, svc, "LMP-1").Id)
+	})
+
+	t.Run("moved sku is read from db", func(t *testing.T) {
+		svc := NewResourceService(fakes.NewRepository(product), fakes.NewCache())
+		readBySku(t, svc, "LMP-1")
+
+		_, serviceErr := svc.UpdateProductById(ctx, 1, domain.NewProduct{Name: "Lamp", AdditionalInfo: "Info", Sku: "LMP-2"})
+		requireNoCritical(t, serviceErr)
+		id, serviceErr := svc.CreateProduct(ctx, domain.NewProduct{Name: "Table", AdditionalInfo: "Info", Sku: "LMP-1"})
+		requireNoCritical(t, serviceErr)
+
+		assert.Equal(t, id, readBySku(t, svc, "LMP-1").Id)
+		assert.Equal(t, int64(1), readBySku(t, svc, "LMP-2").Id)
+	})
+
+	t.Run("unknown sku", func(t *testing.T) {
+		svc := NewResourceService(fakes.NewRepository(product), fakes.NewCache())
+		_, serviceErr := svc.GetProductBySku(ctx, "LMP-9")
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrNotFound)
+	})
+
+	t.Run("skus stay unique", func(t *testing.T) {
+		svc := NewResourceService(fakes.NewRepository(product), fakes.NewCache())
+		_, serviceErr := svc.CreateProduct(ctx, domain.NewProduct{Name: "Table", AdditionalInfo: "Info", Sku: "LMP-1"})
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrDuplicateSku)
+	})
+
+	t.Run("patched sku is validated", func(t *testing.T) {
+		repo := fakes.NewRepository(product)
+		svc := NewResourceService(repo, fakes.NewCache())
+		_, serviceErr := svc.PatchProduct(ctx, 1, domain.MergePatch(map[string]json.RawMessage{"sku": json.RawMessage(`"LMP 2"`)}))
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrInvalidInput)
+		assert.Equal(t, "LMP-1", repo.Products()[0].Sku)
+	})
+}
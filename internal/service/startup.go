@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// StartupCheck has to pass once before instance takes traffic, e.g. schema
+// being migrated. Unlike HealthProbe it is not repeated after it passed
+type StartupCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// StartupGate runs startup checks until all of them pass, retrying failed
+// ones every interval. Readiness stays off meanwhile, liveness does not
+// depend on it, so slow dependencies do not get instance restarted
+type StartupGate struct {
+	checks   []StartupCheck
+	interval time.Duration
+	timeout  time.Duration
+	logger   *slog.Logger
+
+	mu     sync.RWMutex
+	passed []bool
+}
+
+func NewStartupGate(checks []StartupCheck, interval time.Duration, timeout time.Duration, logger *slog.Logger) *StartupGate {
+	return &StartupGate{
+		checks:   checks,
+		interval: interval,
+		timeout:  timeout,
+		logger:   logger.With(slog.String("component", "startup")),
+		passed:   make([]bool, len(checks)),
+	}
+}
+
+// Run checks right away and then every interval until all checks passed or
+// ctx is done
+func (g *StartupGate) Run(ctx context.Context) error {
+	started := time.Now()
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for !g.CheckOnce(ctx) {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+	g.logger.Info("startup checks passed", slog.Duration("duration", time.Since(started)))
+	return nil
+}
+
+func (g *StartupGate) Close() error {
+	return nil
+}
+
+// CheckOnce runs checks that have not passed yet, one after another, as
+// later ones may rely on earlier, and reports whether all have passed
+func (g *StartupGate) CheckOnce(ctx context.Context) bool {
+	done := true
+	for i, check := range g.checks {
+		g.mu.RLock()
+		passed := g.passed[i]
+		g.mu.RUnlock()
+		if passed {
+			continue
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, g.timeout)
+		err := check.Check(checkCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				g.logger.Warn("startup check failed",
+					slog.String("check", check.Name),
+					slog.String("error", err.Error()))
+			}
+			done = false
+			continue
+		}
+		g.mu.Lock()
+		g.passed[i] = true
+		g.mu.Unlock()
+	}
+	return done
+}
+
+// Pending returns names of checks that have not passed yet, none once
+// instance has started
+func (g *StartupGate) Pending() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	var pending []string
+	for i, passed := range g.passed {
+		if !passed {
+			pending = append(pending, g.checks[i].Name)
+		}
+	}
+	return pending
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartupGate(t *testing.T) {
+	ctx := context.Background()
+	schemaErr := errors.New("relation \"products\" does not exist")
+	var cacheCalls int
+	gate := NewStartupGate([]StartupCheck{
+		{Name: "postgres-schema", Check: func(ctx context.Context) error { return schemaErr }},
+		{Name: "redis", Check: func(ctx context.Context) error {
+			cacheCalls++
+			return nil
+		}},
+	}, time.Millisecond, time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	assert.Equal(t, []string{"postgres-schema", "redis"}, gate.Pending(), "nothing passed before checks run")
+	assert.False(t, gate.CheckOnce(ctx))
+	assert.Equal(t, []string{"postgres-schema"}, gate.Pending())
+
+	schemaErr = nil
+	assert.True(t, gate.CheckOnce(ctx))
+	assert.Empty(t, gate.Pending())
+	assert.Equal(t, 1, cacheCalls, "passed checks are not repeated")
+}
+
+func TestStartupGateRunStopsOncePassed(t *testing.T) {
+	attempts := 0
+	gate := NewStartupGate([]StartupCheck{
+		{Name: "postgres-pool", Check: func(ctx context.Context) error {
+			if attempts++; attempts < 3 {
+				return errors.New("connection refused")
+			}
+			return nil
+		}},
+	}, time.Millisecond, time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	done := make(chan error)
+	go func() { done <- gate.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("gate must stop once checks pass")
+	}
+	assert.Equal(t, 3, attempts)
+	assert.Empty(t, gate.Pending())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	failing := NewStartupGate([]StartupCheck{
+		{Name: "redis", Check: func(ctx context.Context) error { return errors.New("connection refused") }},
+	}, time.Hour, time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	assert.NoError(t, failing.Run(ctx))
+	assert.Equal(t, []string{"redis"}, failing.Pending())
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// AdjustProductStock adds delta to product stock, negative delta takes
+// items out. Fails with domain.ErrInsufficientStock if there are fewer items
+// than delta takes, stock is left as it was then
+func (s *ResourseService) AdjustProductStock(ctx context.Context, id int64, delta int64) (_ *domain.Product, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "AdjustProductStock", attribute.Int64("product.id", id), attribute.Int64("stock.delta", delta))
+	defer endSpan(span, &serviceErr)
+
+	if delta == 0 {
+		return nil, domain.NewServiceError(fmt.Errorf("%w: stock delta is zero", domain.ErrInvalidInput), nil)
+	}
+	return s.modify(ctx, id, func(ctx context.Context) (*domain.Product, error) {
+		return s.db.AdjustProductStock(ctx, id, delta)
+	})
+}
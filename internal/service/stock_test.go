@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestAdjustProductStock(t *testing.T) {
+	ctx := context.Background()
+	product := domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Info"}
+
+	t.Run("adjustment invalidates cached product", func(t *testing.T) {
+		cache := fakes.NewCache()
+		svc := NewResourceService(fakes.NewRepository(product), cache)
+		readProduct(t, svc, ctx, 1)
+		require.True(t, cache.Has(1))
+
+		adjusted, serviceErr := svc.AdjustProductStock(ctx, 1, 5)
+		requireNoCritical(t, serviceErr)
+		assert.Equal(t, int64(5), adjusted.Stock)
+		assert.False(t, cache.Has(1))
+		assert.Equal(t, int64(5), readProduct(t, svc, ctx, 1).Stock)
+
+		adjusted, serviceErr = svc.AdjustProductStock(ctx, 1, -5)
+		requireNoCritical(t, serviceErr)
+		assert.Zero(t, adjusted.Stock)
+		assert.Zero(t, readProduct(t, svc, ctx, 1).Stock)
+	})
+
+	t.Run("stock does not go negative", func(t *testing.T) {
+		repo := fakes.NewRepository(product)
+		svc := NewResourceService(repo, fakes.NewCache())
+		_, serviceErr := svc.AdjustProductStock(ctx, 1, 2)
+		requireNoCritical(t, serviceErr)
+
+		_, serviceErr = svc.AdjustProductStock(ctx, 1, -3)
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrInsufficientStock)
+		assert.Equal(t, int64(2), repo.Products()[0].Stock)
+	})
+
+	t.Run("zero delta is rejected", func(t *testing.T) {
+		svc := NewResourceService(fakes.NewRepository(product), fakes.NewCache())
+		_, serviceErr := svc.AdjustProductStock(ctx, 1, 0)
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrInvalidInput)
+	})
+
+	t.Run("update keeps stock", func(t *testing.T) {
+		svc := NewResourceService(fakes.NewRepository(product), fakes.NewCache())
+		_, serviceErr := svc.AdjustProductStock(ctx, 1, 4)
+		requireNoCritical(t, serviceErr)
+		_, serviceErr = svc.UpdateProductById(ctx, 1, domain.NewProduct{Name: "Renamed", AdditionalInfo: "Info"})
+		requireNoCritical(t, serviceErr)
+		assert.Equal(t, int64(4), readProduct(t, svc, ctx, 1).Stock)
+	})
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type suggestionEntry struct {
+	names     []string
+	expiresAt time.Time
+}
+
+// suggestionCache keeps name suggestions in memory, typeahead sends a
+// request per keystroke and a few seconds of staleness go unnoticed there
+type suggestionCache struct {
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]suggestionEntry
+}
+
+func newSuggestionCache(ttl time.Duration, size int) *suggestionCache {
+	return &suggestionCache{ttl: ttl, size: size, now: time.Now, entries: make(map[string]suggestionEntry)}
+}
+
+func (c *suggestionCache) get(key string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.names, true
+}
+
+func (c *suggestionCache) set(key string, names []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= c.size {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) >= c.size {
+		// still full of live entries, starting over is cheaper than LRU here
+		clear(c.entries)
+	}
+	c.entries[key] = suggestionEntry{names: names, expiresAt: now.Add(c.ttl)}
+}
+
+// WithSuggestionCache keeps up to size name suggestion lists for ttl in
+// memory of the instance
+func WithSuggestionCache(ttl time.Duration, size int) Option {
+	return func(s *ResourseService) {
+		s.suggestions = newSuggestionCache(ttl, size)
+	}
+}
+
+func (s *ResourseService) SuggestProductNames(ctx context.Context, prefix string, limit int64) ([]string, *domain.ServiceError) {
+	key := strconv.FormatInt(limit, 10) + ":" + strings.ToLower(prefix)
+	if s.suggestions != nil {
+		if names, ok := s.suggestions.get(key); ok {
+			return names, nil
+		}
+	}
+	names, err := s.db.SuggestProductNames(ctx, prefix, limit)
+	if err != nil {
+		return nil, domain.NewServiceError(err, nil)
+	}
+	if s.suggestions != nil {
+		s.suggestions.set(key, names)
+	}
+	return names, nil
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// SupplierSync periodically reconciles catalog with external feed. Changes are
+// applied through service, so cache and events stay consistent with API writes
+type SupplierSync struct {
+	feed          ports.ProductFeed
+	svc           ports.ResourseService
+	logger        *slog.Logger
+	interval      time.Duration
+	deleteMissing bool
+
+	runMu      sync.Mutex
+	reportMu   sync.RWMutex
+	lastReport *domain.SyncReport
+}
+
+// NewSupplierSync creates sync job. If deleteMissing is set, products absent
+// from the feed are deleted, otherwise they are left intact
+func NewSupplierSync(feed ports.ProductFeed, svc ports.ResourseService, logger *slog.Logger, interval time.Duration, deleteMissing bool) *SupplierSync {
+	return &SupplierSync{
+		feed:          feed,
+		svc:           svc,
+		logger:        logger.With(slog.String("component", "supplier_sync")),
+		interval:      interval,
+		deleteMissing: deleteMissing,
+	}
+}
+
+func (s *SupplierSync) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.SyncOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *SupplierSync) Close() error {
+	return nil
+}
+
+func (s *SupplierSync) LastReport() *domain.SyncReport {
+	s.reportMu.RLock()
+	defer s.reportMu.RUnlock()
+	return s.lastReport
+}
+
+// SyncOnce runs a single reconciliation. Concurrent calls are serialized
+func (s *SupplierSync) SyncOnce(ctx context.Context) *domain.SyncReport {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	report := &domain.SyncReport{StartedAt: time.Now().UTC()}
+	s.sync(ctx, report)
+	report.Duration = time.Since(report.StartedAt)
+
+	attrs := []any{
+		slog.Int("fetched", report.Fetched),
+		slog.Int("created", report.Created),
+		slog.Int("updated", report.Updated),
+		slog.Int("deleted", report.Deleted),
+		slog.Int("unchanged", report.Unchanged),
+		slog.Duration("duration", report.Duration),
+	}
+	if len(report.Errors) > 0 {
+		s.logger.Error("supplier sync finished with errors", append(attrs, slog.Any("errors", report.Errors))...)
+	} else {
+		s.logger.Info("supplier sync finished", attrs...)
+	}
+
+	s.reportMu.Lock()
+	s.lastReport = report
+	s.reportMu.Unlock()
+	return report
+}
+
+func (s *SupplierSync) sync(ctx context.Context, report *domain.SyncReport) {
+	incoming, err := s.feed.Fetch(ctx)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return
+	}
+	report.Fetched = len(incoming)
+
+	existing, serviceErr := s.svc.GetAllProducts(ctx)
+	if serviceErr != nil && serviceErr.CriticalError != nil {
+		report.Errors = append(report.Errors, serviceErr.CriticalError.Error())
+		return
+	}
+	current := make(map[int64]domain.Product, len(existing))
+	for _, p := range existing {
+		current[p.Id] = p
+	}
+
+	seen := make(map[int64]struct{}, len(incoming))
+	for _, product := range incoming {
+		seen[product.Id] = struct{}{}
+		if old, ok := current[product.Id]; ok && sameContent(old, product) {
+			report.Unchanged++
+			continue
+		}
+		created, serviceErr := s.svc.UpsertProduct(ctx, product)
+		if serviceErr != nil && serviceErr.CriticalError != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("product %d: %s", product.Id, serviceErr.CriticalError.Error()))
+			continue
+		}
+		if created {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+	}
+
+	if !s.deleteMissing {
+		return
+	}
+	// an empty feed is far more likely a supplier-side failure than an empty catalog
+	if len(incoming) == 0 {
+		report.Errors = append(report.Errors, "feed is empty, refusing to delete missing products")
+		return
+	}
+	for id := range current {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		_, serviceErr := s.svc.DeleteProductById(ctx, id)
+		if serviceErr != nil && serviceErr.CriticalError != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("product %d: %s", id, serviceErr.CriticalError.Error()))
+			continue
+		}
+		report.Deleted++
+	}
+}
+
+// sameContent compares fields feed supplies, it carries neither versions nor
+// tags
+func sameContent(a domain.Product, b domain.Product) bool {
+	return a.Name == b.Name && a.AdditionalInfo == b.AdditionalInfo && a.CategoryId == b.CategoryId && a.Sku == b.Sku
+}
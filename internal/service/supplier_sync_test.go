@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type fakeFeed struct {
+	products []domain.Product
+	err      error
+}
+
+func (f *fakeFeed) Fetch(ctx context.Context) ([]domain.Product, error) {
+	return f.products, f.err
+}
+
+func TestSupplierSync(t *testing.T) {
+	ctx := context.Background()
+	unchanged := domain.Product{Id: 1, Name: "Unchanged", AdditionalInfo: "Info"}
+	changed := domain.Product{Id: 2, Name: "Changed", AdditionalInfo: "New info"}
+	added := domain.Product{Id: 3, Name: "Added", AdditionalInfo: "Info"}
+	existing := []domain.Product{
+		unchanged,
+		{Id: 2, Name: "Changed", AdditionalInfo: "Old info"},
+		{Id: 4, Name: "Gone", AdditionalInfo: "Info"},
+	}
+
+	testCases := []struct {
+		name           string
+		feed           *fakeFeed
+		deleteMissing  bool
+		expectedReport domain.SyncReport
+		setupMocks     func(repo *MockRepository, cache *MockCache)
+	}{
+		{
+			name:          "sync - create, update and delete",
+			feed:          &fakeFeed{products: []domain.Product{unchanged, changed, added}},
+			deleteMissing: true,
+			expectedReport: domain.SyncReport{
+				Fetched: 3, Created: 1, Updated: 1, Deleted: 1, Unchanged: 1,
+			},
+			setupMocks: func(repo *MockRepository, cache *MockCache) {
+				repo.On("GetAllProducts", ctx).Return(existing, nil).Once()
+				cache.On("DeleteProductById", ctx, mock.Anything).Return(nil)
+				repo.On("UpsertProduct", ctx, changed).Return(false, nil).Once()
+				repo.On("UpsertProduct", ctx, added).Return(true, nil).Once()
+				repo.On("DeleteProductById", ctx, int64(4)).Return(&existing[2], nil).Once()
+			},
+		},
+		{
+			name: "sync - missing products kept",
+			feed: &fakeFeed{products: []domain.Product{unchanged}},
+			expectedReport: domain.SyncReport{
+				Fetched: 1, Unchanged: 1,
+			},
+			setupMocks: func(repo *MockRepository, cache *MockCache) {
+				repo.On("GetAllProducts", ctx).Return(existing, nil).Once()
+			},
+		},
+		{
+			name:          "sync - empty feed never wipes catalog",
+			feed:          &fakeFeed{products: []domain.Product{}},
+			deleteMissing: true,
+			expectedReport: domain.SyncReport{
+				Errors: []string{"feed is empty, refusing to delete missing products"},
+			},
+			setupMocks: func(repo *MockRepository, cache *MockCache) {
+				repo.On("GetAllProducts", ctx).Return(existing, nil).Once()
+			},
+		},
+		{
+			name: "sync - feed unavailable",
+			feed: &fakeFeed{err: domain.ErrFeed},
+			expectedReport: domain.SyncReport{
+				Errors: []string{domain.ErrFeed.Error()},
+			},
+			setupMocks: func(repo *MockRepository, cache *MockCache) {},
+		},
+		{
+			name: "sync - failed upsert reported",
+			feed: &fakeFeed{products: []domain.Product{added}},
+			expectedReport: domain.SyncReport{
+				Fetched: 1,
+				Errors:  []string{"product 3: " + domain.ErrInternalDb.Error()},
+			},
+			setupMocks: func(repo *MockRepository, cache *MockCache) {
+				repo.On("GetAllProducts", ctx).Return(existing, nil).Once()
+				cache.On("DeleteProductById", ctx, int64(3)).Return(nil).Once()
+				repo.On("UpsertProduct", ctx, added).Return(false, domain.ErrInternalDb).Once()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, cache := new(MockRepository), new(MockCache)
+			tc.setupMocks(repo, cache)
+			job := NewSupplierSync(tc.feed, NewResourceService(repo, cache), slog.New(slog.NewTextHandler(io.Discard, nil)), time.Hour, tc.deleteMissing)
+
+			report := job.SyncOnce(ctx)
+			report.StartedAt, report.Duration = time.Time{}, 0
+			assert.Equal(t, tc.expectedReport, *report)
+			assert.Equal(t, report, job.LastReport())
+			repo.AssertExpectations(t)
+		})
+	}
+}
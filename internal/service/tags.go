@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// AddProductTags normalizes tags and adds those product does not have yet,
+// returning the tagged product. Cached product is invalidated and an update
+// event published even if product had all the tags already
+func (s *ResourseService) AddProductTags(ctx context.Context, id int64, tags []string) (_ *domain.Product, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "AddProductTags", attribute.Int64("product.id", id))
+	defer endSpan(span, &serviceErr)
+
+	return s.retag(ctx, id, tags, s.db.AddProductTags)
+}
+
+// RemoveProductTags removes tags from product the way AddProductTags adds
+// them
+func (s *ResourseService) RemoveProductTags(ctx context.Context, id int64, tags []string) (_ *domain.Product, serviceErr *domain.ServiceError) {
+	ctx, span := s.startSpan(ctx, "RemoveProductTags", attribute.Int64("product.id", id))
+	defer endSpan(span, &serviceErr)
+
+	return s.retag(ctx, id, tags, s.db.RemoveProductTags)
+}
+
+func (s *ResourseService) retag(ctx context.Context, id int64, tags []string, write func(ctx context.Context, id int64, tags []string) (*domain.Product, error)) (*domain.Product, *domain.ServiceError) {
+	tags, err := domain.NormalizeTags(tags)
+	if err != nil {
+		return nil, domain.NewServiceError(err, nil)
+	}
+	return s.modify(ctx, id, func(ctx context.Context) (*domain.Product, error) {
+		return write(ctx, id, tags)
+	})
+}
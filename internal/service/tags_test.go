@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestProductTags(t *testing.T) {
+	ctx := context.Background()
+	product := domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Info"}
+
+	t.Run("tagging invalidates cached product", func(t *testing.T) {
+		cache := fakes.NewCache()
+		svc := NewResourceService(fakes.NewRepository(product), cache)
+		readProduct(t, svc, ctx, 1)
+		require.True(t, cache.Has(1))
+
+		tagged, serviceErr := svc.AddProductTags(ctx, 1, []string{" Sale", "new"})
+		requireNoCritical(t, serviceErr)
+		assert.Equal(t, []string{"new", "sale"}, tagged.Tags)
+		assert.False(t, cache.Has(1))
+		assert.Equal(t, []string{"new", "sale"}, readProduct(t, svc, ctx, 1).Tags)
+
+		untagged, serviceErr := svc.RemoveProductTags(ctx, 1, []string{"SALE"})
+		requireNoCritical(t, serviceErr)
+		assert.Equal(t, []string{"new"}, untagged.Tags)
+		assert.False(t, cache.Has(1))
+		assert.Equal(t, []string{"new"}, readProduct(t, svc, ctx, 1).Tags)
+	})
+
+	t.Run("invalid tags are rejected", func(t *testing.T) {
+		repo := fakes.NewRepository(product)
+		svc := NewResourceService(repo, fakes.NewCache())
+
+		_, serviceErr := svc.AddProductTags(ctx, 1, []string{"sale", "on sale"})
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrInvalidInput)
+		assert.Empty(t, repo.Products()[0].Tags)
+	})
+
+	t.Run("unknown product", func(t *testing.T) {
+		svc := NewResourceService(fakes.NewRepository(), fakes.NewCache())
+
+		_, serviceErr := svc.AddProductTags(ctx, 1, []string{"sale"})
+		require.NotNil(t, serviceErr)
+		assert.ErrorIs(t, serviceErr.CriticalError, domain.ErrNotFound)
+	})
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+const tracerName = "github.com/pelyams/simpler_go_service/internal/service"
+
+// WithTracerProvider records a span per service call, child of the span in
+// request context. Database and cache spans recorded by instrumented
+// clients become its children in turn
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(s *ResourseService) {
+		s.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// startSpan leaves ctx as it is unless tracing is enabled
+func (s *ResourseService) startSpan(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if s.tracer == nil {
+		return ctx, noop.Span{}
+	}
+	return s.tracer.Start(ctx, "ResourseService."+method, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span of service call, serviceErr points to its result.
+// Non-critical errors are only recorded as events. Critical errors caused
+// by client, such as missing product, do not fail the span
+func endSpan(span trace.Span, serviceErr **domain.ServiceError) {
+	defer span.End()
+	if *serviceErr == nil {
+		return
+	}
+	for _, err := range (*serviceErr).NonCriticalErrors {
+		span.RecordError(err)
+	}
+	err := (*serviceErr).CriticalError
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	if !errors.Is(err, domain.ErrNotFound) && !errors.Is(err, domain.ErrInvalidInput) {
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// UsageFlusher periodically moves usage counted in UsageCounter into
+// UsageRepository. Usage failed to be stored is counted again, so that it is
+// retried with the next flush
+type UsageFlusher struct {
+	counter  ports.UsageCounter
+	repo     ports.UsageRepository
+	logger   *slog.Logger
+	interval time.Duration
+}
+
+func NewUsageFlusher(counter ports.UsageCounter, repo ports.UsageRepository, logger *slog.Logger, interval time.Duration) *UsageFlusher {
+	return &UsageFlusher{
+		counter:  counter,
+		repo:     repo,
+		logger:   logger.With(slog.String("component", "usage_flusher")),
+		interval: interval,
+	}
+}
+
+func (f *UsageFlusher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// whatever was counted since last tick is flushed on shutdown
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			f.FlushOnce(flushCtx)
+			return nil
+		case <-ticker.C:
+			f.FlushOnce(ctx)
+		}
+	}
+}
+
+func (f *UsageFlusher) Close() error {
+	return nil
+}
+
+func (f *UsageFlusher) FlushOnce(ctx context.Context) {
+	usage, err := f.counter.Drain(ctx)
+	if err != nil {
+		// counters drained before the failure are gone from counter already
+		f.logger.Error("failed to drain usage counters", slog.String("error", err.Error()))
+	}
+	if len(usage) == 0 {
+		return
+	}
+	if err := f.repo.AddUsage(ctx, usage); err != nil {
+		f.logger.Error("failed to store usage", slog.String("error", err.Error()), slog.Int("records", len(usage)))
+		for _, u := range usage {
+			if err := f.counter.Add(ctx, u); err != nil {
+				f.logger.Error("failed to count back usage, it is lost",
+					slog.String("client", u.Client), slog.String("error", err.Error()))
+			}
+		}
+		return
+	}
+	f.logger.Debug("usage flushed", slog.Int("records", len(usage)))
+}
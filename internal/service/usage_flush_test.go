@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestUsageFlusher(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	counter := fakes.NewUsageCounter()
+	repo := fakes.NewUsageRepository()
+	flusher := NewUsageFlusher(counter, repo, slog.New(slog.NewTextHandler(io.Discard, nil)), time.Minute)
+
+	require.NoError(t, counter.Add(ctx, domain.DailyUsage{Day: day, Usage: domain.Usage{Client: "shop", Requests: 2, BytesOut: 40}}))
+	repo.FailWith("AddUsage", domain.ErrInternalDb)
+	flusher.FlushOnce(ctx)
+	usage, err := repo.GetUsage(ctx, day, day)
+	require.NoError(t, err)
+	assert.Empty(t, usage)
+
+	// usage failed to be stored is retried with the next flush
+	repo.FailWith("AddUsage", nil)
+	require.NoError(t, counter.Add(ctx, domain.DailyUsage{Day: day, Usage: domain.Usage{Client: "shop", Requests: 1, Errors: 1}}))
+	flusher.FlushOnce(ctx)
+	usage, err = repo.GetUsage(ctx, day, day)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Usage{{Client: "shop", Requests: 3, Errors: 1, BytesOut: 40}}, usage)
+
+	drained, err := counter.Drain(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, drained)
+}
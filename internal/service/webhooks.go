@@ -0,0 +1,185 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+const (
+	webhookIdHeader        = "X-Webhook-Id"
+	webhookEventHeader     = "X-Webhook-Event"
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+	webhookSignatureHeader = "X-Webhook-Signature"
+
+	// webhookBatchSize is how many deliveries are claimed and made at once
+	webhookBatchSize = 20
+	// maxWebhookResponseBytes of response are read, so that connection can
+	// be reused, the rest is dropped along with connection
+	maxWebhookResponseBytes = 64 << 10
+)
+
+// SignWebhook computes signature of delivery: hex encoded HMAC-SHA256 of
+// "TIMESTAMP\nBODY" with secret of webhook, timestamp being unix seconds
+// sent in X-Webhook-Timestamp. Receivers compute it the same way to check
+// X-Webhook-Signature, which has it prefixed with "sha256="
+func SignWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookDispatcher delivers events queued for webhooks by POSTing them as
+// JSON, signed with secret of the webhook. Deliveries that fail, non-2xx
+// responses included, are retried after backoff doubled with every attempt
+// up to maxBackoff, until maxAttempts are made. Delivery is at least once:
+// it is retried if its outcome is not recorded, e.g. on shutdown, so
+// receivers should deduplicate events by X-Webhook-Id. Instances may
+// dispatch concurrently, claimed deliveries are leased to one of them
+type WebhookDispatcher struct {
+	repo        ports.WebhookRepository
+	client      *http.Client
+	logger      *slog.Logger
+	interval    time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	// long enough for the whole batch to be delivered
+	lease time.Duration
+	now   func() time.Time
+}
+
+// NewWebhookDispatcher polls queue every interval, giving each delivery
+// attempt timeout to complete
+func NewWebhookDispatcher(repo ports.WebhookRepository, logger *slog.Logger, interval time.Duration, timeout time.Duration, maxAttempts int, backoff time.Duration, maxBackoff time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		repo:        repo,
+		client:      &http.Client{Timeout: timeout},
+		logger:      logger.With(slog.String("component", "webhook_dispatcher")),
+		interval:    interval,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		maxBackoff:  maxBackoff,
+		lease:       2 * timeout,
+		now:         time.Now,
+	}
+}
+
+func (d *WebhookDispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// backlog is worked off without waiting for the next tick
+			for d.DispatchOnce(ctx) == webhookBatchSize {
+				if ctx.Err() != nil {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (d *WebhookDispatcher) Close() error {
+	d.client.CloseIdleConnections()
+	return nil
+}
+
+// DispatchOnce makes deliveries that are due, a batch at most, and returns
+// how many it made
+func (d *WebhookDispatcher) DispatchOnce(ctx context.Context) int {
+	deliveries, err := d.repo.ClaimWebhookDeliveries(ctx, webhookBatchSize, d.lease)
+	if err != nil {
+		d.logger.Error("failed to claim webhook deliveries", slog.String("error", err.Error()))
+		return 0
+	}
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+	return len(deliveries)
+}
+
+// deliver makes delivery attempt and records its outcome
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery domain.WebhookDelivery) {
+	logger := d.logger.With(slog.Int64("webhook", delivery.WebhookId), slog.String("event", delivery.EventId), slog.Int("attempt", delivery.Attempts))
+	sendErr := d.send(ctx, delivery)
+	var err error
+	switch {
+	case sendErr == nil:
+		err = d.repo.CompleteWebhookDelivery(ctx, delivery.Id)
+	case delivery.Attempts >= d.maxAttempts:
+		logger.Warn("webhook delivery failed for good", slog.String("error", sendErr.Error()))
+		err = d.repo.FailWebhookDelivery(ctx, delivery.Id, sendErr.Error())
+	default:
+		logger.Debug("webhook delivery failed, will retry", slog.String("error", sendErr.Error()))
+		err = d.repo.RetryWebhookDelivery(ctx, delivery.Id, d.retryDelay(delivery.Attempts), sendErr.Error())
+	}
+	if err != nil {
+		// delivery is attempted again once its lease runs out
+		logger.Error("failed to record webhook delivery attempt", slog.String("error", err.Error()))
+	}
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, delivery domain.WebhookDelivery) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(webhookIdHeader, delivery.EventId)
+	request.Header.Set(webhookEventHeader, delivery.EventType)
+	request.Header.Set(webhookTimestampHeader, timestamp)
+	request.Header.Set(webhookSignatureHeader, "sha256="+SignWebhook(delivery.Secret, timestamp, delivery.Payload))
+	// receivers continue trace of the change, as consumers of events do
+	if tc, err := domain.ParseTraceparent(delivery.Traceparent); err == nil {
+		for name, value := range tc.Child().TraceHeaders() {
+			request.Header.Set(name, value)
+		}
+	}
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxWebhookResponseBytes))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", response.Status)
+	}
+	return nil
+}
+
+// retryDelay is backoff doubled for every attempt after the first one,
+// capped at maxBackoff
+func (d *WebhookDispatcher) retryDelay(attempts int) time.Duration {
+	delay := d.backoff
+	for range attempts - 1 {
+		if delay >= d.maxBackoff/2 {
+			return d.maxBackoff
+		}
+		delay *= 2
+	}
+	return min(delay, d.maxBackoff)
+}
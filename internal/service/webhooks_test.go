@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestWebhookDispatcher(t *testing.T) {
+	ctx := context.Background()
+	event := domain.NewProductEvent(domain.EventProductCreated, 1, &domain.Product{Id: 1, Name: "Lamp", AdditionalInfo: "Info"})
+	trace := domain.NewTraceContext()
+	event.Traceparent = trace.Traceparent()
+	payload := []byte(`{"type":"product.created"}`)
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	receiver := func(t *testing.T, status int) (*httptest.Server, func() []received) {
+		var mu sync.Mutex
+		var requests []received
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			requests = append(requests, received{header: r.Header.Clone(), body: body})
+			mu.Unlock()
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(server.Close)
+		return server, func() []received {
+			mu.Lock()
+			defer mu.Unlock()
+			return requests
+		}
+	}
+	setup := func(t *testing.T, url string, maxAttempts int) (*fakes.WebhookRepository, *WebhookDispatcher) {
+		repo := fakes.NewWebhookRepository()
+		_, err := repo.CreateWebhook(ctx, domain.Webhook{URL: url, Secret: "secret", EventTypes: []string{domain.EventProductCreated}})
+		require.NoError(t, err)
+		queued, err := repo.EnqueueWebhookDeliveries(ctx, event, payload)
+		require.NoError(t, err)
+		require.Equal(t, 1, queued)
+		dispatcher := NewWebhookDispatcher(repo, slog.New(slog.NewTextHandler(io.Discard, nil)), time.Second, time.Second, maxAttempts, time.Minute, time.Hour)
+		t.Cleanup(func() { dispatcher.Close() })
+		return repo, dispatcher
+	}
+
+	t.Run("signed event is delivered", func(t *testing.T) {
+		server, requests := receiver(t, http.StatusNoContent)
+		repo, dispatcher := setup(t, server.URL, 3)
+
+		assert.Equal(t, 1, dispatcher.DispatchOnce(ctx))
+		require.Len(t, requests(), 1)
+		request := requests()[0]
+		assert.Equal(t, payload, request.body)
+		assert.Equal(t, event.Id, request.header.Get(webhookIdHeader))
+		assert.Equal(t, domain.EventProductCreated, request.header.Get(webhookEventHeader))
+		timestamp := request.header.Get(webhookTimestampHeader)
+		sent, err := strconv.ParseInt(timestamp, 10, 64)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), time.Unix(sent, 0), time.Minute)
+		assert.Equal(t, "sha256="+SignWebhook("secret", timestamp, payload), request.header.Get(webhookSignatureHeader))
+		tc, err := domain.ParseTraceparent(request.header.Get("traceparent"))
+		require.NoError(t, err)
+		assert.Equal(t, trace.TraceId, tc.TraceId, "delivery continues trace of the change")
+		assert.NotEqual(t, trace.SpanId, tc.SpanId)
+		assert.Equal(t, tc.B3(), request.header.Get("b3"))
+		assert.Empty(t, repo.Deliveries())
+
+		assert.Zero(t, dispatcher.DispatchOnce(ctx), "nothing is left to deliver")
+	})
+
+	t.Run("failed delivery is retried until attempts run out", func(t *testing.T) {
+		server, requests := receiver(t, http.StatusServiceUnavailable)
+		repo, dispatcher := setup(t, server.URL, 2)
+
+		assert.Equal(t, 1, dispatcher.DispatchOnce(ctx))
+		deliveries := repo.Deliveries()
+		require.Len(t, deliveries, 1)
+		assert.False(t, deliveries[0].Failed)
+		assert.Contains(t, deliveries[0].LastError, "503")
+		assert.WithinDuration(t, time.Now().Add(time.Minute), deliveries[0].NextAttemptAt, 5*time.Second)
+		assert.Zero(t, dispatcher.DispatchOnce(ctx), "retry is not due yet")
+
+		repo.MakeDue()
+		assert.Equal(t, 1, dispatcher.DispatchOnce(ctx))
+		assert.Len(t, requests(), 2)
+		deliveries = repo.Deliveries()
+		require.Len(t, deliveries, 1)
+		assert.True(t, deliveries[0].Failed)
+		assert.Equal(t, 2, deliveries[0].Attempts)
+
+		repo.MakeDue()
+		assert.Zero(t, dispatcher.DispatchOnce(ctx), "failed delivery is not retried")
+	})
+
+	t.Run("unreachable webhook is retried", func(t *testing.T) {
+		server, _ := receiver(t, http.StatusOK)
+		server.Close()
+		repo, dispatcher := setup(t, server.URL, 3)
+
+		assert.Equal(t, 1, dispatcher.DispatchOnce(ctx))
+		deliveries := repo.Deliveries()
+		require.Len(t, deliveries, 1)
+		assert.False(t, deliveries[0].Failed)
+		assert.NotEmpty(t, deliveries[0].LastError)
+	})
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	dispatcher := NewWebhookDispatcher(fakes.NewWebhookRepository(), slog.New(slog.NewTextHandler(io.Discard, nil)), time.Second, time.Second, 100, 30*time.Second, time.Hour)
+	for attempts, delay := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		7:  32 * time.Minute,
+		8:  time.Hour,
+		90: time.Hour,
+	} {
+		assert.Equal(t, delay, dispatcher.retryDelay(attempts), "after %d attempts", attempts)
+	}
+}
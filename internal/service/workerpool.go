@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type poolTask struct {
+	ctx  context.Context
+	key  string
+	task func(ctx context.Context) error
+}
+
+// WorkerPool runs submitted tasks on a fixed number of workers. Every worker
+// has its own queue and tasks are assigned by key, so tasks sharing a key
+// run in order of submission. Tasks without key go round robin. Submitters
+// are held back while the queue of their worker is full, which slows
+// request handling down instead of piling goroutines up
+type WorkerPool struct {
+	logger      *slog.Logger
+	taskTimeout time.Duration
+	queueSize   int
+
+	mu     sync.RWMutex
+	closed bool
+	queues []chan poolTask
+
+	next      atomic.Uint64
+	busy      atomic.Int64
+	succeeded atomic.Uint64
+	failed    atomic.Uint64
+	rejected  atomic.Uint64
+}
+
+// NewWorkerPool creates pool of workers, queueSize tasks may wait for each
+// of them. Every task is given at most taskTimeout. Tasks can be submitted
+// right away, they start once pool is run
+func NewWorkerPool(workers int, queueSize int, taskTimeout time.Duration, logger *slog.Logger) *WorkerPool {
+	p := &WorkerPool{
+		logger:      logger.With(slog.String("component", "worker_pool")),
+		taskTimeout: taskTimeout,
+		queueSize:   queueSize,
+		queues:      make([]chan poolTask, max(workers, 1)),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan poolTask, queueSize)
+	}
+	return p
+}
+
+func (p *WorkerPool) Submit(ctx context.Context, key string, task func(ctx context.Context) error) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return domain.ErrPoolClosed
+	}
+	queue := p.queues[p.worker(key)]
+	t := poolTask{ctx: context.WithoutCancel(ctx), key: key, task: task}
+	select {
+	case queue <- t:
+		return nil
+	default:
+	}
+	select {
+	case queue <- t:
+		return nil
+	case <-ctx.Done():
+		p.rejected.Add(1)
+		return fmt.Errorf("%w: %w", domain.ErrPoolSaturated, ctx.Err())
+	}
+}
+
+func (p *WorkerPool) worker(key string) int {
+	if key == "" {
+		return int(p.next.Add(1) % uint64(len(p.queues)))
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// Run starts workers and keeps them until ctx is done. Then no more tasks
+// are accepted and Run returns once queued ones are done
+func (p *WorkerPool) Run(ctx context.Context) error {
+	var workers sync.WaitGroup
+	for _, queue := range p.queues {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for t := range queue {
+				p.execute(t)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	p.mu.Lock()
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.mu.Unlock()
+	workers.Wait()
+	return nil
+}
+
+func (p *WorkerPool) Close() error {
+	return nil
+}
+
+func (p *WorkerPool) execute(t poolTask) {
+	p.busy.Add(1)
+	defer p.busy.Add(-1)
+	ctx, cancel := context.WithTimeout(t.ctx, p.taskTimeout)
+	defer cancel()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("task panicked: %v", r)
+			}
+		}()
+		return t.task(ctx)
+	}()
+	if err != nil {
+		p.failed.Add(1)
+		p.logger.Error("task failed", slog.String("key", t.key), slog.String("error", err.Error()))
+		return
+	}
+	p.succeeded.Add(1)
+}
+
+func (p *WorkerPool) Stats() domain.WorkerPoolStats {
+	stats := domain.WorkerPoolStats{
+		Workers:   len(p.queues),
+		QueueSize: p.queueSize * len(p.queues),
+		Busy:      int(p.busy.Load()),
+		Succeeded: p.succeeded.Load(),
+		Failed:    p.failed.Load(),
+		Rejected:  p.rejected.Load(),
+	}
+	for _, queue := range p.queues {
+		stats.Queued += len(queue)
+	}
+	return stats
+}
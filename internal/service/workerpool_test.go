@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func newTestPool(workers int, queueSize int) *WorkerPool {
+	return NewWorkerPool(workers, queueSize, time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestWorkerPool(t *testing.T) {
+	pool := newTestPool(4, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- pool.Run(ctx) }()
+
+	var mu sync.Mutex
+	var order []int
+	for i := range 100 {
+		require.NoError(t, pool.Submit(context.Background(), "ordered", func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, i)
+			return nil
+		}))
+	}
+	require.NoError(t, pool.Submit(context.Background(), "", func(ctx context.Context) error {
+		return errors.New("cache is down")
+	}))
+	require.NoError(t, pool.Submit(context.Background(), "", func(ctx context.Context) error {
+		panic("boom")
+	}))
+
+	// task context outlives the one it was submitted with
+	submitCtx, cancelSubmit := context.WithCancel(context.Background())
+	taskErr := make(chan error, 1)
+	require.NoError(t, pool.Submit(submitCtx, "", func(ctx context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		taskErr <- ctx.Err()
+		return nil
+	}))
+	cancelSubmit()
+	assert.NoError(t, <-taskErr)
+
+	cancel()
+	require.NoError(t, <-done)
+	for i, v := range order {
+		require.Equal(t, i, v, "tasks sharing key run in order")
+	}
+	assert.Len(t, order, 100)
+	stats := pool.Stats()
+	assert.Equal(t, domain.WorkerPoolStats{Workers: 4, QueueSize: 400, Succeeded: 101, Failed: 2}, stats)
+
+	err := pool.Submit(context.Background(), "", func(ctx context.Context) error { return nil })
+	assert.ErrorIs(t, err, domain.ErrPoolClosed)
+}
+
+func TestWorkerPoolBackpressure(t *testing.T) {
+	pool := newTestPool(1, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- pool.Run(ctx) }()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	require.NoError(t, pool.Submit(context.Background(), "", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}))
+	<-started
+	require.NoError(t, pool.Submit(context.Background(), "", func(ctx context.Context) error { return nil }))
+
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelWait()
+	err := pool.Submit(waitCtx, "", func(ctx context.Context) error { return nil })
+	assert.ErrorIs(t, err, domain.ErrPoolSaturated)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, domain.WorkerPoolStats{Workers: 1, QueueSize: 1, Queued: 1, Busy: 1, Rejected: 1}, pool.Stats())
+
+	// submitter waits for room instead of failing
+	submitted := make(chan error)
+	go func() {
+		submitted <- pool.Submit(context.Background(), "", func(ctx context.Context) error { return nil })
+	}()
+	close(release)
+	assert.NoError(t, <-submitted)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, uint64(3), pool.Stats().Succeeded)
+}
+
+// recordingPublisher keeps published events
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []domain.ProductEvent
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event domain.ProductEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error {
+	return nil
+}
+
+func (p *recordingPublisher) published() []domain.ProductEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]domain.ProductEvent{}, p.events...)
+}
+
+func TestServiceWithWorkerPool(t *testing.T) {
+	pool := newTestPool(4, 100)
+	publisher := &recordingPublisher{}
+	cache := fakes.NewCache()
+	svc := NewResourceService(
+		fakes.NewRepository(domain.Product{Id: 1, Name: "First", AdditionalInfo: "First info"}),
+		cache,
+		WithEventPublisher(publisher),
+		WithWorkerPool(pool),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- pool.Run(ctx) }()
+
+	// cache miss is reported as non-critical
+	_, serviceErr := svc.GetProductById(context.Background(), 1)
+	require.NotNil(t, serviceErr)
+	require.NoError(t, serviceErr.CriticalError)
+	require.Eventually(t, func() bool {
+		_, err := cache.GetJSONProductById(context.Background(), 1)
+		return err == nil
+	}, time.Second, 5*time.Millisecond, "cache is filled in background")
+
+	trace := domain.NewTraceContext()
+	for range 20 {
+		_, serviceErr = svc.CreateProduct(domain.WithTraceContext(context.Background(), trace), domain.NewProduct{Name: "New", AdditionalInfo: "Created"})
+		require.Nil(t, serviceErr)
+	}
+	require.Eventually(t, func() bool { return len(publisher.published()) == 20 }, time.Second, 5*time.Millisecond)
+	for i, event := range publisher.published() {
+		assert.Equal(t, int64(i+2), event.ProductId, "events are published in order")
+		assert.Equal(t, trace.Traceparent(), event.Traceparent, "events carry trace of request")
+	}
+
+	// once pool is closed on shutdown, side effects run inline
+	cancel()
+	require.NoError(t, <-done)
+	_, serviceErr = svc.DeleteProductById(context.Background(), 1)
+	require.Nil(t, serviceErr)
+	assert.Len(t, publisher.published(), 21)
+}
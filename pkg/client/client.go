@@ -0,0 +1,315 @@
+// Package client is a typed Go client for the products REST API
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Product struct {
+	Id             int64  `json:"id"`
+	Name           string `json:"name"`
+	AdditionalInfo string `json:"additionalInfo"`
+	Version        int64  `json:"version"`
+	// zero if product belongs to no category
+	CategoryId int64    `json:"categoryId,omitempty"`
+	Sku        string   `json:"sku,omitempty"`
+	Stock      int64    `json:"stock,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	// in order of upload
+	Images []Image `json:"images,omitempty"`
+}
+
+// Image is an image of product, served from URL
+type Image struct {
+	Id          int64     `json:"id"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type NewProduct struct {
+	Name           string `json:"name"`
+	AdditionalInfo string `json:"additionalInfo"`
+	// update fails with ErrConflict unless product is at this version, if set
+	Version int64 `json:"version,omitempty"`
+	// update takes product out of its category unless set
+	CategoryId int64 `json:"categoryId,omitempty"`
+	// update clears sku of product unless set
+	Sku string `json:"sku,omitempty"`
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	retryWait  time.Duration
+	clientId   string
+	secret     []byte
+	apiKey     string
+	gzip       bool
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout sets timeout of a single attempt, not of a whole call with retries
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithRetries sets how many times idempotent requests (GET, PUT, DELETE) are
+// retried on network errors and 5xx responses. Wait doubles after every attempt
+func WithRetries(maxRetries int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryWait = wait
+	}
+}
+
+// WithHMAC signs every request with a secret shared with the server. Each
+// attempt is signed anew, so retries are not rejected as replays
+func WithHMAC(clientId string, secret string) Option {
+	return func(c *Client) {
+		c.clientId = clientId
+		c.secret = []byte(secret)
+	}
+}
+
+// WithAPIKey authenticates every request with a key issued by admins
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithGzip compresses request bodies, worth it for large payloads only
+func WithGzip() Option {
+	return func(c *Client) {
+		c.gzip = true
+	}
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		maxRetries: 2,
+		retryWait:  200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Client) Get(ctx context.Context, id int64) (*Product, error) {
+	var product Product
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/product/%d", id), nil, &product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// List returns a single page of products
+func (c *Client) List(ctx context.Context, offset int64, limit int64) ([]Product, error) {
+	query := url.Values{}
+	query.Set("offset", strconv.FormatInt(offset, 10))
+	query.Set("limit", strconv.FormatInt(limit, 10))
+	var products []Product
+	if err := c.do(ctx, http.MethodGet, "/products?"+query.Encode(), nil, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// ListAll fetches whole catalog in one request, prefer Iterate for large catalogs
+func (c *Client) ListAll(ctx context.Context) ([]Product, error) {
+	var products []Product
+	if err := c.do(ctx, http.MethodGet, "/products", nil, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (c *Client) Create(ctx context.Context, product NewProduct) (int64, error) {
+	var res struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/product", product, &res); err != nil {
+		return 0, err
+	}
+	return res.ID, nil
+}
+
+// Update replaces product and returns its previous state
+func (c *Client) Update(ctx context.Context, id int64, product NewProduct) (*Product, error) {
+	var previous Product
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/product/%d", id), product, &previous); err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+// Delete removes product and returns its last state
+func (c *Client) Delete(ctx context.Context, id int64) (*Product, error) {
+	var deleted Product
+	if err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/product/%d", id), nil, &deleted); err != nil {
+		return nil, err
+	}
+	return &deleted, nil
+}
+
+// DeleteAll removes all products. Server requires the deletion to be
+// confirmed with a token it issues on the first call, the token is
+// presented right away
+func (c *Client) DeleteAll(ctx context.Context) (int64, error) {
+	var res struct {
+		DeletedRows       int64  `json:"deletedRows"`
+		ConfirmationToken string `json:"confirmationToken"`
+	}
+	if err := c.do(ctx, http.MethodDelete, "/products", nil, &res); err != nil {
+		return 0, err
+	}
+	if res.ConfirmationToken == "" {
+		// server does not require confirmation
+		return res.DeletedRows, nil
+	}
+	header := http.Header{"X-Confirmation-Token": []string{res.ConfirmationToken}}
+	if err := c.doWithHeader(ctx, http.MethodDelete, "/products", header, nil, &res); err != nil {
+		return 0, err
+	}
+	return res.DeletedRows, nil
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	return c.doWithHeader(ctx, method, path, nil, body, out)
+}
+
+func (c *Client) doWithHeader(ctx context.Context, method string, path string, header http.Header, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("client error: failed to encode request body: %w", err)
+		}
+	}
+
+	retries := c.maxRetries
+	if method == http.MethodPost {
+		retries = 0
+	}
+	wait := c.retryWait
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			wait *= 2
+		}
+		var retriable bool
+		retriable, lastErr = c.attempt(ctx, method, path, header, payload, out)
+		if lastErr == nil || !retriable {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+func (c *Client) attempt(ctx context.Context, method string, path string, header http.Header, payload []byte, out interface{}) (bool, error) {
+	var body io.Reader
+	if payload != nil && c.gzip {
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		zw.Write(payload)
+		zw.Close()
+		payload = compressed.Bytes()
+	}
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return false, fmt.Errorf("client error: failed to build request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+		if c.gzip {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.secret != nil {
+		c.sign(req, payload)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("client error: %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var errBody struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errBody); err == nil {
+			apiErr.Message = errBody.Error
+			apiErr.Code = errBody.Code
+		} else {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return resp.StatusCode >= http.StatusInternalServerError, apiErr
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return false, fmt.Errorf("client error: failed to decode response: %w", err)
+		}
+	}
+	return false, nil
+}
+
+// sign sets X-Signature to hex encoded HMAC-SHA256 of
+// "METHOD\nREQUEST_URI\nTIMESTAMP\nBODY", as the server expects
+func (c *Client) sign(req *http.Request, payload []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, c.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n", req.Method, req.URL.RequestURI(), timestamp)
+	mac.Write(payload)
+	req.Header.Set("X-Client-Id", c.clientId)
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+}
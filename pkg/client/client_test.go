@@ -0,0 +1,212 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/routing"
+	"github.com/pelyams/simpler_go_service/testhelpers/fakes"
+)
+
+func TestIterate(t *testing.T) {
+	catalog := make([]Product, 7)
+	for i := range catalog {
+		catalog[i] = Product{Id: int64(i + 1), Name: "Product", AdditionalInfo: "Info"}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		end := min(offset+limit, len(catalog))
+		json.NewEncoder(w).Encode(catalog[min(offset, end):end])
+	}))
+	defer server.Close()
+
+	it := New(server.URL).Iterate(context.Background(), 3)
+	var ids []int64
+	for it.Next() {
+		ids = append(ids, it.Product().Id)
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7}, ids)
+}
+
+func TestDeleteAllConfirmation(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("X-Confirmation-Token") != "secret" {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"confirmationToken":"secret","expiresAt":"2030-01-01T00:00:00Z"}`))
+			return
+		}
+		w.Write([]byte(`{"deletedRows":4}`))
+	}))
+	defer server.Close()
+
+	deleted, err := New(server.URL).DeleteAll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), deleted)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestHMACSigning(t *testing.T) {
+	verifier := routing.NewSignatureVerifier(map[string]string{"partner": "secret"}, fakes.NewSignatureReplayStore(), time.Minute, 1<<20)
+	handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":7}`))
+	}))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errs := domain.NewErrorContainer()
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "errorContainer", &errs)))
+	}))
+	defer server.Close()
+
+	id, err := New(server.URL, WithHMAC("partner", "secret")).Create(context.Background(), NewProduct{Name: "n", AdditionalInfo: "i"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), id)
+
+	_, err = New(server.URL, WithHMAC("partner", "guess")).Create(context.Background(), NewProduct{Name: "n", AdditionalInfo: "i"})
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "INVALID_SIGNATURE", apiErr.Code)
+}
+
+func TestAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Invalid API key","code":"UNAUTHENTICATED"}`))
+			return
+		}
+		w.Write([]byte(`{"id":1,"name":"n","additionalInfo":"i"}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, WithAPIKey("key")).Delete(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = New(server.URL).Delete(context.Background(), 1)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "UNAUTHENTICATED", apiErr.Code)
+}
+
+func TestGzipRequestBody(t *testing.T) {
+	var received NewProduct
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		zr, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(zr).Decode(&received))
+		w.Write([]byte(`{"id":7}`))
+	}))
+	defer server.Close()
+
+	id, err := New(server.URL, WithGzip()).Create(context.Background(), NewProduct{Name: "n", AdditionalInfo: "i"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), id)
+	assert.Equal(t, NewProduct{Name: "n", AdditionalInfo: "i"}, received)
+}
+
+func TestErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		status        int
+		body          string
+		expectedError error
+		expectedCode  string
+		expectedCalls int32
+		call          func(c *Client) error
+	}{
+		{
+			name:          "get - not found",
+			status:        http.StatusNotFound,
+			body:          `{"error":"Product not found","code":"PRODUCT_NOT_FOUND"}`,
+			expectedError: ErrNotFound,
+			expectedCode:  "PRODUCT_NOT_FOUND",
+			expectedCalls: 1,
+			call: func(c *Client) error {
+				_, err := c.Get(context.Background(), 5)
+				return err
+			},
+		},
+		{
+			name:          "update - bad request is not retried",
+			status:        http.StatusBadRequest,
+			body:          `{"error":"Invalid request body"}`,
+			expectedError: ErrBadRequest,
+			expectedCalls: 1,
+			call: func(c *Client) error {
+				_, err := c.Update(context.Background(), 5, NewProduct{})
+				return err
+			},
+		},
+		{
+			name:          "update - version conflict is not retried",
+			status:        http.StatusConflict,
+			body:          `{"error":"Product was changed since the version given","code":"CONFLICT"}`,
+			expectedError: ErrConflict,
+			expectedCode:  "CONFLICT",
+			expectedCalls: 1,
+			call: func(c *Client) error {
+				_, err := c.Update(context.Background(), 5, NewProduct{Name: "n", AdditionalInfo: "i", Version: 2})
+				return err
+			},
+		},
+		{
+			name:          "delete - internal error is retried",
+			status:        http.StatusInternalServerError,
+			body:          `{"error":"Internal server error"}`,
+			expectedError: ErrInternal,
+			expectedCalls: 3,
+			call: func(c *Client) error {
+				_, err := c.Delete(context.Background(), 5)
+				return err
+			},
+		},
+		{
+			name:          "create - internal error is not retried",
+			status:        http.StatusInternalServerError,
+			body:          `{"error":"Internal server error"}`,
+			expectedError: ErrInternal,
+			expectedCalls: 1,
+			call: func(c *Client) error {
+				_, err := c.Create(context.Background(), NewProduct{Name: "n", AdditionalInfo: "i"})
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			c := New(server.URL, WithRetries(2, time.Millisecond))
+			err := tc.call(c)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, tc.expectedError))
+			var apiErr *APIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, tc.status, apiErr.StatusCode)
+			assert.Equal(t, tc.expectedCode, apiErr.Code)
+			assert.Equal(t, tc.expectedCalls, calls.Load())
+		})
+	}
+}
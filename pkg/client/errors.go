@@ -0,0 +1,43 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrBadRequest = errors.New("bad request")
+	ErrNotFound   = errors.New("product not found")
+	// ErrConflict means product changed since the version update was based on,
+	// or that sku given is used by another product
+	ErrConflict = errors.New("conflict")
+	ErrInternal = errors.New("internal server error")
+)
+
+// APIError is returned for every non-2xx response. It matches ErrBadRequest,
+// ErrNotFound, ErrConflict or ErrInternal with errors.Is depending on status code. Code is
+// the stable error code, e.g. "PRODUCT_NOT_FOUND", empty if server sent none
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error: status %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrInternal:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
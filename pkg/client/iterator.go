@@ -0,0 +1,71 @@
+package client
+
+import "context"
+
+// ProductIterator walks the catalog page by page:
+//
+//	it := c.Iterate(ctx, 100)
+//	for it.Next() {
+//		product := it.Product()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type ProductIterator struct {
+	ctx      context.Context
+	client   *Client
+	pageSize int64
+	offset   int64
+	page     []Product
+	current  int
+	done     bool
+	err      error
+}
+
+func (c *Client) Iterate(ctx context.Context, pageSize int64) *ProductIterator {
+	if pageSize < 1 {
+		pageSize = 100
+	}
+	return &ProductIterator{
+		ctx:      ctx,
+		client:   c,
+		pageSize: pageSize,
+		current:  -1,
+	}
+}
+
+// Next advances to the next product, fetching the next page when needed.
+// Returns false when catalog is exhausted or an error occurred
+func (it *ProductIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	it.current++
+	if it.current < len(it.page) {
+		return true
+	}
+	if it.done {
+		return false
+	}
+
+	page, err := it.client.List(it.ctx, it.offset, it.pageSize)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.page = page
+	it.current = 0
+	it.offset += int64(len(page))
+	if int64(len(page)) < it.pageSize {
+		it.done = true
+	}
+	return len(page) > 0
+}
+
+func (it *ProductIterator) Product() Product {
+	return it.page[it.current]
+}
+
+func (it *ProductIterator) Err() error {
+	return it.err
+}
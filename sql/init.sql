@@ -2,4 +2,127 @@ CREATE TABLE IF NOT EXISTS products (
     id SERIAL PRIMARY KEY,
     name VARCHAR(50) NOT NULL, 
     additional_info TEXT NOT NULL
-);
\ No newline at end of file
+);
+
+-- kept by the database, ALTER covers tables created before the columns existed
+ALTER TABLE products ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE products ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS products_created_at_idx ON products (created_at);
+CREATE INDEX IF NOT EXISTS products_updated_at_idx ON products (updated_at);
+-- bumped by every write, clients send it back to detect concurrent edits
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+-- categories group products, a product belongs to at most one. Categories
+-- products belong to can not be deleted
+CREATE TABLE IF NOT EXISTS categories (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL
+);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS category_id BIGINT REFERENCES categories (id);
+CREATE INDEX IF NOT EXISTS products_category_idx ON products (category_id);
+-- stock keeping unit, NULL for products without one, so that they do not
+-- collide
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(64) UNIQUE;
+-- quantity in stock, adjusted atomically so concurrent orders can not
+-- oversell
+ALTER TABLE products ADD COLUMN IF NOT EXISTS stock BIGINT NOT NULL DEFAULT 0 CHECK (stock >= 0);
+-- sorted and distinct, NULL rather than empty when product has no tags. GIN
+-- index serves containment, tags @> ARRAY['sale']
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tags TEXT[];
+CREATE INDEX IF NOT EXISTS products_tags_idx ON products USING gin (tags);
+-- prefix matches of name suggestions
+CREATE INDEX IF NOT EXISTS products_name_prefix_idx ON products (lower(name) text_pattern_ops);
+-- typo-tolerant search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS products_name_trgm_idx ON products USING gin (name gin_trgm_ops);
+-- full-text search, names weigh more than additional info
+ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', name), 'A') || setweight(to_tsvector('english', additional_info), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS products_search_idx ON products USING gin (search_vector);
+
+-- images of products, objects themselves are kept in object store under
+-- object_key
+CREATE TABLE IF NOT EXISTS product_images (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    object_key TEXT NOT NULL UNIQUE,
+    url TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS product_images_product_idx ON product_images (product_id, id);
+
+-- transactional outbox, columns follow Debezium outbox event router conventions
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY,
+    aggregatetype VARCHAR(255) NOT NULL,
+    aggregateid VARCHAR(255) NOT NULL,
+    type VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- API usage per client and day, flushed from counters in Redis
+CREATE TABLE IF NOT EXISTS api_usage (
+    client TEXT NOT NULL,
+    day DATE NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    bytes_in BIGINT NOT NULL DEFAULT 0,
+    bytes_out BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (client, day)
+);
+CREATE INDEX IF NOT EXISTS api_usage_day_idx ON api_usage (day);
+
+-- API keys are only stored as hex encoded SHA-256 of the key, principal is
+-- the identity requests made with the key are attributed to
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    principal TEXT NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+-- role granted to requests made with the key, keys issued before roles
+-- existed could write
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'writer';
+
+-- audit trail of product changes, written in the same transaction as the
+-- change. No foreign key, entries outlive products they are about
+CREATE TABLE IF NOT EXISTS product_audit (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL,
+    action TEXT NOT NULL,
+    actor TEXT,
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS product_audit_product_idx ON product_audit (product_id, id);
+
+-- webhook subscriptions to product events, secret signs deliveries so it
+-- is kept as is
+CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    event_types TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- events queued for webhooks. Delivered ones are removed, failed ones are
+-- kept with failed_at set
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT,
+    failed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE failed_at IS NULL;
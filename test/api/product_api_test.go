@@ -12,7 +12,6 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
-	"strings"
 	"testing"
 	"time"
 
@@ -48,9 +47,6 @@ type TestSuite struct {
 func (suite *TestSuite) SetupSuite() {
 	suite.ctx = context.Background()
 
-}
-
-func (suite *TestSuite) SetupTest() {
 	redisContainer, err := testhelpers.CreateRedisContainer(suite.ctx)
 	if err != nil {
 		log.Fatal(err)
@@ -61,9 +57,6 @@ func (suite *TestSuite) SetupTest() {
 		Addr: redisContainer.ConnectionString,
 		DB:   0,
 	})
-	redisClient.ConfigSet(context.Background(), "maxmemory", "10mb")
-	redisClient.ConfigSet(context.Background(), "maxmemory-policy", "allkeys-lru")
-
 	suite.cache = redisClient
 
 	pgContainer, err := testhelpers.CreatePostgresContainer(suite.ctx)
@@ -80,41 +73,47 @@ func (suite *TestSuite) SetupTest() {
 	suite.db = databaseClient
 
 	repo := repository.NewPostgresRepository(databaseClient)
+	confirmations := cache.NewRedisConfirmationStore(redisClient)
 	cache := cache.NewRedisCache(redisClient)
 	service := service.NewResourceService(repo, cache)
 
-	handler := routing.NewProductHandler(service)
+	handler := routing.NewProductHandler(service,
+		routing.WithDeleteConfirmation(confirmations, time.Minute))
 	router := routing.NewRouter(handler).SetupRoutes()
 
-	logger, err := routing.NewLogger(0, "test_log.log")
+	logger, err := routing.NewLogger("test_log.log")
 	suite.Require().NoError(err)
 
 	suite.server = httptest.NewServer(logger.LoggerMiddleware(router))
 	suite.client = &http.Client{Timeout: 5 * time.Second}
-	suite.pgContainerAlive = true
-	suite.cacheContainerAlive = true
 }
 
-func (s *TestSuite) TearDownTest() {
+func (s *TestSuite) TearDownSuite() {
+	s.server.Close()
+	s.db.Close()
+	s.cache.Close()
 	s.pgContainer.Terminate(s.ctx)
 	s.cacheContainer.Terminate(s.ctx)
-	s.server.Close()
 }
 
+// SetupTest brings back containers stopped by previous test and resets them
+func (s *TestSuite) SetupTest() {
+	s.Require().NoError(s.pgContainer.Reset(s.ctx))
+	s.Require().NoError(s.cacheContainer.Reset(s.ctx))
+	s.cache.ConfigSet(s.ctx, "maxmemory", "10mb")
+	s.cache.ConfigSet(s.ctx, "maxmemory-policy", "allkeys-lru")
+	s.pgContainerAlive = true
+	s.cacheContainerAlive = true
+}
+
+// SetupSubTest leaves containers stopped earlier in the same test as they are
 func (s *TestSuite) SetupSubTest() {
 	if s.pgContainerAlive {
-		_, err := s.db.Exec("TRUNCATE TABLE products")
-		if err != nil {
-			s.T().Fatal(err)
-		}
+		s.Require().NoError(s.pgContainer.Reset(s.ctx))
 	}
 	if s.cacheContainerAlive {
-		_, err := s.cache.FlushDB(s.ctx).Result()
-		if err != nil {
-			s.T().Fatal(err)
-		}
+		s.Require().NoError(s.cacheContainer.Reset(s.ctx))
 	}
-
 }
 
 func (s *TestSuite) makeRequest(method, path string, body interface{}) (*http.Response, error) {
@@ -136,6 +135,33 @@ func (s *TestSuite) makeRequest(method, path string, body interface{}) (*http.Re
 	return s.client.Do(req)
 }
 
+// deleteAll goes through both steps of DELETE /products. Response of the
+// first step is returned if it does not issue a token. Token is replaced
+// with the given one unless it is empty
+func (s *TestSuite) deleteAll(token string) (*http.Response, error) {
+	resp, err := s.makeRequest("DELETE", "/products", nil)
+	if err != nil || resp.StatusCode != http.StatusAccepted {
+		return resp, err
+	}
+	defer resp.Body.Close()
+	var confirmation struct {
+		ConfirmationToken string `json:"confirmationToken"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&confirmation); err != nil {
+		return nil, err
+	}
+	if token == "" {
+		token = confirmation.ConfirmationToken
+	}
+
+	req, err := http.NewRequest("DELETE", s.server.URL+"/products", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Confirmation-Token", token)
+	return s.client.Do(req)
+}
+
 func TestAPISuite(t *testing.T) {
 	suite.Run(t, new(TestSuite))
 }
@@ -189,8 +215,8 @@ func (s *TestSuite) TestGetProductById() {
 			name:      "get product - db disconnected",
 			productId: "13",
 
-			expectedStatus: http.StatusInternalServerError,
-			expectedError:  "Internal server error",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  "Service temporarily unavailable",
 		},
 	}
 
@@ -320,8 +346,8 @@ func (s *TestSuite) TestUpdateProduct() {
 				"name":           "Renewed product name",
 				"additionalInfo": "Some additional info for renewed product",
 			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedError:  "Internal server error",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  "Service temporarily unavailable",
 		},
 		{
 			name:      "update product - cache disconnected",
@@ -430,8 +456,8 @@ func (s *TestSuite) TestDeleteProduct() {
 		{
 			name:           "delete product - db disconnected",
 			productId:      "2013",
-			expectedStatus: http.StatusInternalServerError,
-			expectedError:  "Internal server error",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  "Service temporarily unavailable",
 		},
 		{
 			name:           "delete product - cache disconnected",
@@ -551,8 +577,8 @@ func (s *TestSuite) TestCreateUser() {
 				"name":           "Product #13",
 				"additionalInfo": "Product #13 description",
 			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedError:  "Internal server error",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  "Service temporarily unavailable",
 		},
 	}
 
@@ -618,6 +644,7 @@ func (s *TestSuite) TestDeleteAll() {
 
 		setupProducts  bool
 		garbageData    []domain.Product
+		token          string
 		expectedResult int64
 		expectedStatus int
 		expectedError  string
@@ -630,25 +657,43 @@ func (s *TestSuite) TestDeleteAll() {
 					Id:             1,
 					Name:           "Test product #1",
 					AdditionalInfo: "Test product #1 info",
+					Version:        1,
 				},
 				domain.Product{
 					Id:             3,
 					Name:           "Test product #3",
 					AdditionalInfo: "Test product #3 info",
+					Version:        1,
 				},
 				domain.Product{
 					Id:             7,
 					Name:           "Test product #7",
 					AdditionalInfo: "Test product #7 info",
+					Version:        1,
 				},
 			},
 			expectedResult: 3,
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:          "delete all products - invalid confirmation token",
+			setupProducts: true,
+			garbageData: []domain.Product{
+				domain.Product{
+					Id:             1,
+					Name:           "Test product #1",
+					AdditionalInfo: "Test product #1 info",
+					Version:        1,
+				},
+			},
+			token:          "forged",
+			expectedStatus: http.StatusForbidden,
+			expectedError:  "Invalid or expired confirmation token",
+		},
 		{
 			name:           "delete all products - db disconnected",
-			expectedStatus: http.StatusInternalServerError,
-			expectedError:  "Internal server error",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  "Service temporarily unavailable",
 		},
 		{
 			name:           "delete all products - cache disconnected",
@@ -660,13 +705,7 @@ func (s *TestSuite) TestDeleteAll() {
 	for _, tt := range testCases {
 		s.Run(tt.name, func() {
 			if tt.setupProducts {
-				query := "INSERT INTO products (id, name, additional_info) VALUES "
-				dataToInsert := make([]string, len(tt.garbageData))
-				for i, data := range tt.garbageData {
-					dataToInsert[i] = fmt.Sprintf("(%d, '%s', '%s')", data.Id, data.Name, data.AdditionalInfo)
-				}
-				query = fmt.Sprintf("%s %s", query, strings.Join(dataToInsert, ", "))
-				_, err := s.db.Query(query)
+				_, err := testhelpers.InsertProducts(s.db, tt.garbageData...)
 				s.Require().NoError(err)
 			}
 			if tt.name == "delete all products - cache disconnected" {
@@ -680,7 +719,8 @@ func (s *TestSuite) TestDeleteAll() {
 				require.NoError(s.T(), err)
 				s.pgContainerAlive = false
 			}
-			resp, err := s.makeRequest("DELETE", "/products", nil)
+			resp, err := s.deleteAll(tt.token)
+			s.Require().NoError(err)
 			defer resp.Body.Close()
 
 			s.Assert().Equal(tt.expectedStatus, resp.StatusCode)
@@ -734,26 +774,31 @@ func (s *TestSuite) TestGetProducts() {
 					Id:             1,
 					Name:           "Test product #1",
 					AdditionalInfo: "Test product #1 info",
+					Version:        1,
 				},
 				domain.Product{
 					Id:             3,
 					Name:           "Test product #3",
 					AdditionalInfo: "Test product #3 info",
+					Version:        1,
 				},
 				domain.Product{
 					Id:             7,
 					Name:           "Test product #7",
 					AdditionalInfo: "Test product #7 info",
+					Version:        1,
 				},
 				domain.Product{
 					Id:             11,
 					Name:           "Test product #11",
 					AdditionalInfo: "Test product #11 info",
+					Version:        1,
 				},
 				domain.Product{
 					Id:             12,
 					Name:           "Test product #12",
 					AdditionalInfo: "Test product #12 info",
+					Version:        1,
 				},
 			},
 			expectedStatus: http.StatusOK,
@@ -770,11 +815,41 @@ func (s *TestSuite) TestGetProducts() {
 					Id:             7,
 					Name:           "Test product #7",
 					AdditionalInfo: "Test product #7 info",
+					Version:        1,
 				},
 				domain.Product{
 					Id:             11,
 					Name:           "Test product #11",
 					AdditionalInfo: "Test product #11 info",
+					Version:        1,
+				},
+			},
+		},
+		{
+			name:           "get products paged - first page",
+			paginated:      true,
+			limit:          "3",
+			offset:         "0",
+			setupProducts:  true,
+			expectedStatus: http.StatusOK,
+			expectedResult: []domain.Product{
+				domain.Product{
+					Id:             1,
+					Name:           "Test product #1",
+					AdditionalInfo: "Test product #1 info",
+					Version:        1,
+				},
+				domain.Product{
+					Id:             3,
+					Name:           "Test product #3",
+					AdditionalInfo: "Test product #3 info",
+					Version:        1,
+				},
+				domain.Product{
+					Id:             7,
+					Name:           "Test product #7",
+					AdditionalInfo: "Test product #7 info",
+					Version:        1,
 				},
 			},
 		},
@@ -796,22 +871,15 @@ func (s *TestSuite) TestGetProducts() {
 		},
 		{
 			name:           "get products - db disconnected",
-			expectedStatus: http.StatusInternalServerError,
-			expectedError:  "Internal server error",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  "Service temporarily unavailable",
 		},
 	}
 
 	for _, tt := range testCases {
 		s.Run(tt.name, func() {
 			if tt.setupProducts {
-				_, err := s.db.Query(`
-					INSERT INTO products (id, name, additional_info) VALUES
-					(1, 'Test product #1', 'Test product #1 info'),
-					(3, 'Test product #3', 'Test product #3 info'),
-					(7, 'Test product #7', 'Test product #7 info'),
-					(11, 'Test product #11', 'Test product #11 info'),
-					(12, 'Test product #12', 'Test product #12 info')
-				`)
+				_, err := testhelpers.LoadProducts(s.db, "fixtures/products.yaml")
 				s.Require().NoError(err)
 			}
 
@@ -0,0 +1,111 @@
+package contract
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// RunCacheTests runs cache contract. newCache is called for every subtest and
+// must return an empty cache
+func RunCacheTests(t *testing.T, newCache func(t *testing.T) ports.Cache) {
+	ctx := context.Background()
+	product := &domain.Product{Id: 1, Name: "Product", AdditionalInfo: "Info", Version: 1}
+
+	t.Run("get missing product - not found", func(t *testing.T) {
+		cache := newCache(t)
+		data, err := cache.GetJSONProductById(ctx, 1)
+		assert.Nil(t, data)
+		assert.True(t, errors.Is(err, domain.ErrNotFound))
+	})
+
+	t.Run("set and get product as json", func(t *testing.T) {
+		cache := newCache(t)
+		require.NoError(t, cache.SetProduct(ctx, product))
+
+		data, err := cache.GetJSONProductById(ctx, 1)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"id":1,"name":"Product","additionalInfo":"Info","version":1}`, string(data))
+	})
+
+	t.Run("set overwrites product", func(t *testing.T) {
+		cache := newCache(t)
+		require.NoError(t, cache.SetProduct(ctx, product))
+		require.NoError(t, cache.SetProduct(ctx, &domain.Product{Id: 1, Name: "Renamed", AdditionalInfo: "Info", Version: 2}))
+
+		data, err := cache.GetJSONProductById(ctx, 1)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"id":1,"name":"Renamed","additionalInfo":"Info","version":2}`, string(data))
+	})
+
+	t.Run("set products at once", func(t *testing.T) {
+		cache := newCache(t)
+		require.NoError(t, cache.SetProduct(ctx, product))
+		require.NoError(t, cache.SetProducts(ctx, []domain.Product{
+			{Id: 1, Name: "Renamed", AdditionalInfo: "Info", Version: 2},
+			{Id: 2, Name: "Second", AdditionalInfo: "Info", Version: 1},
+		}))
+		require.NoError(t, cache.SetProducts(ctx, nil))
+
+		data, err := cache.GetJSONProductById(ctx, 1)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"id":1,"name":"Renamed","additionalInfo":"Info","version":2}`, string(data))
+		data, err = cache.GetJSONProductById(ctx, 2)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"id":2,"name":"Second","additionalInfo":"Info","version":1}`, string(data))
+	})
+
+	t.Run("delete product", func(t *testing.T) {
+		cache := newCache(t)
+		require.NoError(t, cache.SetProduct(ctx, product))
+		require.NoError(t, cache.DeleteProductById(ctx, 1))
+
+		_, err := cache.GetJSONProductById(ctx, 1)
+		assert.True(t, errors.Is(err, domain.ErrNotFound))
+	})
+
+	t.Run("delete missing product - not found", func(t *testing.T) {
+		cache := newCache(t)
+		err := cache.DeleteProductById(ctx, 1)
+		assert.True(t, errors.Is(err, domain.ErrNotFound))
+	})
+
+	t.Run("sku is cached apart from product", func(t *testing.T) {
+		cache := newCache(t)
+		_, err := cache.GetProductIdBySku(ctx, "LMP-1")
+		assert.True(t, errors.Is(err, domain.ErrNotFound))
+
+		require.NoError(t, cache.SetProductSku(ctx, "LMP-1", 1))
+		id, err := cache.GetProductIdBySku(ctx, "LMP-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), id)
+		_, err = cache.GetJSONProductById(ctx, 1)
+		assert.True(t, errors.Is(err, domain.ErrNotFound))
+
+		require.NoError(t, cache.SetProductSku(ctx, "LMP-1", 2))
+		id, err = cache.GetProductIdBySku(ctx, "LMP-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), id)
+	})
+
+	t.Run("clear cache", func(t *testing.T) {
+		cache := newCache(t)
+		require.NoError(t, cache.SetProductSku(ctx, "LMP-1", 1))
+		require.NoError(t, cache.SetProduct(ctx, product))
+		require.NoError(t, cache.SetProduct(ctx, &domain.Product{Id: 2, Name: "Second", AdditionalInfo: "Info"}))
+		require.NoError(t, cache.ClearCache(ctx))
+
+		for _, id := range []int64{1, 2} {
+			_, err := cache.GetJSONProductById(ctx, id)
+			assert.True(t, errors.Is(err, domain.ErrNotFound))
+		}
+		_, err := cache.GetProductIdBySku(ctx, "LMP-1")
+		assert.True(t, errors.Is(err, domain.ErrNotFound))
+	})
+}
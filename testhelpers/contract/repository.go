@@ -0,0 +1,632 @@
+// Package contract holds conformance suites every ports implementation must
+// pass, so adapters stay interchangeable
+package contract
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+)
+
+// RunRepositoryTests runs repository contract. newRepo is called for every
+// subtest and must return an empty repository
+func RunRepositoryTests(t *testing.T, newRepo func(t *testing.T) ports.Repository) {
+	ctx := context.Background()
+	newProduct := domain.NewProduct{Name: "Product", AdditionalInfo: "Info"}
+
+	t.Run("get missing product - not found", func(t *testing.T) {
+		repo := newRepo(t)
+		product, err := repo.GetProduct(ctx, 1)
+		assert.Nil(t, product)
+		assert.True(t, errors.Is(err, domain.ErrNotFound))
+	})
+
+	t.Run("store and get product", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.StoreProduct(ctx, newProduct)
+		require.NoError(t, err)
+		assert.Positive(t, id)
+
+		product, err := repo.GetProduct(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, domain.Product{Id: id, Name: newProduct.Name, AdditionalInfo: newProduct.AdditionalInfo, Version: 1}, *product)
+	})
+
+	t.Run("stored ids are unique and increasing", func(t *testing.T) {
+		repo := newRepo(t)
+		first, err := repo.StoreProduct(ctx, newProduct)
+		require.NoError(t, err)
+		second, err := repo.StoreProduct(ctx, newProduct)
+		require.NoError(t, err)
+		assert.Greater(t, second, first)
+	})
+
+	t.Run("store products in batch", func(t *testing.T) {
+		repo := newRepo(t)
+		batch := []domain.NewProduct{
+			{Name: "First", AdditionalInfo: "Info"},
+			{Name: "Second", AdditionalInfo: "Info"},
+			{Name: "Third", AdditionalInfo: "Info"},
+		}
+		stored, err := repo.StoreProducts(ctx, batch)
+		require.NoError(t, err)
+		require.Len(t, stored, len(batch))
+		for i, id := range stored {
+			product, err := repo.GetProduct(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, batch[i].Name, product.Name)
+		}
+
+		none, err := repo.StoreProducts(ctx, nil)
+		require.NoError(t, err)
+		assert.Empty(t, none)
+	})
+
+	t.Run("empty repository", func(t *testing.T) {
+		repo := newRepo(t)
+		all, err := repo.GetAllProducts(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, all)
+
+		page, err := repo.GetProductsPaged(ctx, domain.ProductFilter{}, nil, 10, 0)
+		require.NoError(t, err)
+		assert.Empty(t, page)
+
+		count, err := repo.CountProducts(ctx, domain.ProductFilter{})
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		first, last, err := repo.ProductIdRange(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, first)
+		assert.Zero(t, last)
+	})
+
+	t.Run("listing is ordered by id", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, id := range []int64{7, 3, 5, 1} {
+			_, err := repo.UpsertProduct(ctx, domain.Product{Id: id, Name: "Product", AdditionalInfo: "Info"})
+			require.NoError(t, err)
+		}
+
+		all, err := repo.GetAllProducts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{1, 3, 5, 7}, ids(all))
+
+		var each []domain.Product
+		require.NoError(t, repo.EachProduct(ctx, domain.ProductFilter{}, nil, func(product domain.Product) error {
+			each = append(each, product)
+			return nil
+		}))
+		assert.Equal(t, all, each)
+
+		stop := errors.New("stop")
+		each = nil
+		err = repo.EachProduct(ctx, domain.ProductFilter{}, nil, func(product domain.Product) error {
+			each = append(each, product)
+			return stop
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, []int64{1}, ids(each))
+
+		page, err := repo.GetProductsPaged(ctx, domain.ProductFilter{}, nil, 2, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3, 5}, ids(page))
+
+		page, err = repo.GetProductsPaged(ctx, domain.ProductFilter{}, nil, 2, 4)
+		require.NoError(t, err)
+		assert.Empty(t, page)
+
+		first, last, err := repo.ProductIdRange(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{1, 7}, []int64{first, last})
+
+		page, err = repo.GetProductsInRange(ctx, 3, 7)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3, 5}, ids(page))
+
+		page, err = repo.GetProductsInRange(ctx, 8, 100)
+		require.NoError(t, err)
+		assert.Empty(t, page)
+
+		count, err := repo.CountProducts(ctx, domain.ProductFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), count)
+	})
+
+	t.Run("listing is filtered", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, p := range []domain.Product{
+			{Id: 1, Name: "Lamp", AdditionalInfo: "Warm light"},
+			{Id: 2, Name: "lamp", AdditionalInfo: "Cold light, 100% LED"},
+			{Id: 3, Name: "Lamp shade", AdditionalInfo: "Fits any LIGHT"},
+			{Id: 4, Name: "Chair", AdditionalInfo: "Oak"},
+		} {
+			_, err := repo.UpsertProduct(ctx, p)
+			require.NoError(t, err)
+		}
+
+		page, err := repo.GetProductsPaged(ctx, domain.ProductFilter{Name: "LAMP"}, nil, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{1, 2}, ids(page), "name matches whole, case-insensitively")
+
+		page, err = repo.GetProductsPaged(ctx, domain.ProductFilter{InfoContains: "light"}, nil, 2, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{2, 3}, ids(page))
+
+		page, err = repo.GetProductsPaged(ctx, domain.ProductFilter{InfoContains: "0%"}, nil, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{2}, ids(page), "wildcards match literally")
+
+		var each []domain.Product
+		require.NoError(t, repo.EachProduct(ctx, domain.ProductFilter{Name: "lamp", InfoContains: "warm"}, nil, func(product domain.Product) error {
+			each = append(each, product)
+			return nil
+		}))
+		assert.Equal(t, []int64{1}, ids(each))
+
+		count, err := repo.CountProducts(ctx, domain.ProductFilter{InfoContains: "light"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	})
+
+	t.Run("products are put in categories", func(t *testing.T) {
+		repo := newRepo(t)
+		categories, ok := repo.(ports.CategoryRepository)
+		if !ok {
+			t.Skip("repository does not store categories")
+		}
+		lamps, err := categories.StoreCategory(ctx, "Lamps")
+		require.NoError(t, err)
+		chairs, err := categories.StoreCategory(ctx, "Chairs")
+		require.NoError(t, err)
+		list, err := categories.ListCategories(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []domain.Category{{Id: lamps, Name: "Lamps"}, {Id: chairs, Name: "Chairs"}}, list)
+
+		lamp, err := repo.StoreProduct(ctx, domain.NewProduct{Name: "Lamp", AdditionalInfo: "Info", CategoryId: lamps})
+		require.NoError(t, err)
+		stored, err := repo.StoreProducts(ctx, []domain.NewProduct{{Name: "Chair", AdditionalInfo: "Info", CategoryId: chairs}, newProduct})
+		require.NoError(t, err)
+		product, err := repo.GetProduct(ctx, lamp)
+		require.NoError(t, err)
+		assert.Equal(t, lamps, product.CategoryId)
+
+		page, err := repo.GetProductsPaged(ctx, domain.ProductFilter{CategoryId: chairs}, nil, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, stored[:1], ids(page))
+		count, err := repo.CountProducts(ctx, domain.ProductFilter{CategoryId: lamps})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		_, err = repo.StoreProduct(ctx, domain.NewProduct{Name: "Desk", AdditionalInfo: "Info", CategoryId: chairs + 100})
+		assert.ErrorIs(t, err, domain.ErrUnknownCategory)
+		_, err = repo.UpdateProductById(ctx, lamp, domain.NewProduct{Name: "Lamp", AdditionalInfo: "Info", CategoryId: chairs + 100})
+		assert.ErrorIs(t, err, domain.ErrUnknownCategory)
+
+		assert.ErrorIs(t, categories.DeleteCategory(ctx, lamps), domain.ErrCategoryInUse)
+		old, err := repo.UpdateProductById(ctx, lamp, domain.NewProduct{Name: "Lamp", AdditionalInfo: "Info"})
+		require.NoError(t, err)
+		assert.Equal(t, lamps, old.CategoryId)
+		require.NoError(t, categories.DeleteCategory(ctx, lamps))
+		assert.ErrorIs(t, categories.DeleteCategory(ctx, lamps), domain.ErrNotFound)
+
+		require.NoError(t, categories.UpdateCategory(ctx, domain.Category{Id: chairs, Name: "Seats"}))
+		category, err := categories.GetCategory(ctx, chairs)
+		require.NoError(t, err)
+		assert.Equal(t, "Seats", category.Name)
+		assert.ErrorIs(t, categories.UpdateCategory(ctx, domain.Category{Id: lamps, Name: "Lamps"}), domain.ErrNotFound)
+		_, err = categories.GetCategory(ctx, lamps)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("products are tagged", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.StoreProduct(ctx, newProduct)
+		require.NoError(t, err)
+		other, err := repo.StoreProduct(ctx, newProduct)
+		require.NoError(t, err)
+
+		product, err := repo.AddProductTags(ctx, id, []string{"sale", "new"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"new", "sale"}, product.Tags)
+		assert.Equal(t, int64(2), product.Version)
+		product, err = repo.AddProductTags(ctx, id, []string{"sale"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), product.Version, "unchanged tags are not written")
+		_, err = repo.AddProductTags(ctx, other, []string{"new"})
+		require.NoError(t, err)
+
+		page, err := repo.GetProductsPaged(ctx, domain.ProductFilter{Tag: "sale"}, nil, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{id}, ids(page))
+		count, err := repo.CountProducts(ctx, domain.ProductFilter{Tag: "new"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		_, err = repo.UpdateProductById(ctx, id, domain.NewProduct{Name: "Renamed", AdditionalInfo: "Info"})
+		require.NoError(t, err)
+		product, err = repo.RemoveProductTags(ctx, id, []string{"new", "unknown"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"sale"}, product.Tags, "updates keep tags")
+		assert.Equal(t, "Renamed", product.Name)
+		product, err = repo.RemoveProductTags(ctx, id, []string{"sale"})
+		require.NoError(t, err)
+		assert.Empty(t, product.Tags)
+		product, err = repo.GetProduct(ctx, id)
+		require.NoError(t, err)
+		assert.Empty(t, product.Tags)
+
+		_, err = repo.AddProductTags(ctx, other+100, []string{"sale"})
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = repo.RemoveProductTags(ctx, other+100, []string{"sale"})
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("skus are unique", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.StoreProduct(ctx, domain.NewProduct{Name: "Lamp", AdditionalInfo: "Info", Sku: "LMP-1"})
+		require.NoError(t, err)
+		// products without sku do not collide
+		stored, err := repo.StoreProducts(ctx, []domain.NewProduct{newProduct, newProduct})
+		require.NoError(t, err)
+
+		product, err := repo.GetProductBySku(ctx, "LMP-1")
+		require.NoError(t, err)
+		assert.Equal(t, id, product.Id)
+		assert.Equal(t, "LMP-1", product.Sku)
+		_, err = repo.GetProductBySku(ctx, "lmp-1")
+		assert.ErrorIs(t, err, domain.ErrNotFound, "skus are case-sensitive")
+
+		_, err = repo.StoreProduct(ctx, domain.NewProduct{Name: "Lamp", AdditionalInfo: "Info", Sku: "LMP-1"})
+		assert.ErrorIs(t, err, domain.ErrDuplicateSku)
+		_, err = repo.StoreProducts(ctx, []domain.NewProduct{{Name: "Desk", AdditionalInfo: "Info", Sku: "DSK-1"}, {Name: "Desk", AdditionalInfo: "Info", Sku: "DSK-1"}})
+		assert.ErrorIs(t, err, domain.ErrDuplicateSku)
+		_, err = repo.UpdateProductById(ctx, stored[0], domain.NewProduct{Name: "Lamp", AdditionalInfo: "Info", Sku: "LMP-1"})
+		assert.ErrorIs(t, err, domain.ErrDuplicateSku)
+		_, err = repo.UpsertProduct(ctx, domain.Product{Id: stored[1], Name: "Lamp", AdditionalInfo: "Info", Sku: "LMP-1"})
+		assert.ErrorIs(t, err, domain.ErrDuplicateSku)
+
+		// sku is free again once its product lets go of it
+		_, err = repo.UpdateProductById(ctx, id, domain.NewProduct{Name: "Lamp", AdditionalInfo: "Info", Sku: "LMP-1"})
+		require.NoError(t, err, "product keeps its own sku")
+		_, err = repo.UpdateProductById(ctx, id, domain.NewProduct{Name: "Lamp", AdditionalInfo: "Info"})
+		require.NoError(t, err)
+		_, err = repo.UpdateProductById(ctx, stored[0], domain.NewProduct{Name: "Lamp", AdditionalInfo: "Info", Sku: "LMP-1"})
+		require.NoError(t, err)
+		product, err = repo.GetProductBySku(ctx, "LMP-1")
+		require.NoError(t, err)
+		assert.Equal(t, stored[0], product.Id)
+	})
+
+	t.Run("stock is adjusted but never negative", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.StoreProduct(ctx, newProduct)
+		require.NoError(t, err)
+
+		product, err := repo.AdjustProductStock(ctx, id, 5)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), product.Stock)
+		assert.Equal(t, int64(2), product.Version)
+		product, err = repo.AdjustProductStock(ctx, id, -3)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), product.Stock)
+
+		_, err = repo.AdjustProductStock(ctx, id, -3)
+		assert.ErrorIs(t, err, domain.ErrInsufficientStock)
+		_, err = repo.AdjustProductStock(ctx, id+100, 1)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+
+		_, err = repo.UpdateProductById(ctx, id, domain.NewProduct{Name: "Renamed", AdditionalInfo: "Info"})
+		require.NoError(t, err)
+		_, err = repo.UpsertProduct(ctx, domain.Product{Id: id, Name: "Upserted", AdditionalInfo: "Info"})
+		require.NoError(t, err)
+		product, err = repo.GetProduct(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), product.Stock, "writes keep stock")
+		assert.Equal(t, int64(5), product.Version)
+	})
+
+	t.Run("images are read with product", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.StoreProduct(ctx, newProduct)
+		require.NoError(t, err)
+
+		image := domain.ProductImage{Key: "products/1/a.png", URL: "https://cdn.example.com/products/1/a.png", ContentType: "image/png", Size: 42}
+		product, err := repo.AddProductImage(ctx, id, image)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), product.Version)
+		require.Len(t, product.Images, 1)
+		assert.Positive(t, product.Images[0].Id)
+		assert.False(t, product.Images[0].CreatedAt.IsZero())
+		_, err = repo.AddProductImage(ctx, id, domain.ProductImage{Key: "products/1/b.jpg", URL: "https://cdn.example.com/products/1/b.jpg", ContentType: "image/jpeg", Size: 7})
+		require.NoError(t, err)
+
+		_, err = repo.UpdateProductById(ctx, id, domain.NewProduct{Name: "Renamed", AdditionalInfo: "Info"})
+		require.NoError(t, err)
+		product, err = repo.GetProduct(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, product.Images, 2, "updates keep images")
+		assert.Equal(t, "products/1/a.png", product.Images[0].Key)
+		assert.Equal(t, image.URL, product.Images[0].URL)
+		assert.Equal(t, "image/jpeg", product.Images[1].ContentType)
+		assert.Equal(t, int64(7), product.Images[1].Size)
+		page, err := repo.GetProductsPaged(ctx, domain.ProductFilter{}, nil, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, page, 1)
+		assert.Len(t, page[0].Images, 2)
+
+		deleted, err := repo.DeleteProductById(ctx, id)
+		require.NoError(t, err)
+		assert.Len(t, deleted.Images, 2, "deleted product tells which images to remove from store")
+		_, err = repo.AddProductImage(ctx, id, domain.ProductImage{Key: "products/1/c.png", URL: "u", ContentType: "image/png", Size: 1})
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("listing is sorted", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, p := range []domain.Product{
+			{Id: 1, Name: "Chair", AdditionalInfo: "b"},
+			{Id: 2, Name: "Lamp", AdditionalInfo: "a"},
+			{Id: 3, Name: "Chair", AdditionalInfo: "c"},
+			{Id: 4, Name: "Armchair", AdditionalInfo: "a"},
+		} {
+			_, err := repo.UpsertProduct(ctx, p)
+			require.NoError(t, err)
+		}
+
+		page, err := repo.GetProductsPaged(ctx, domain.ProductFilter{}, domain.ProductSort{{Field: "name"}}, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{4, 1, 3, 2}, ids(page), "ties are broken by id")
+
+		page, err = repo.GetProductsPaged(ctx, domain.ProductFilter{}, domain.ProductSort{{Field: "name"}, {Field: "id", Desc: true}}, 2, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3, 1}, ids(page))
+
+		var each []domain.Product
+		sort := domain.ProductSort{{Field: "additionalInfo", Desc: true}}
+		require.NoError(t, repo.EachProduct(ctx, domain.ProductFilter{}, sort, func(product domain.Product) error {
+			each = append(each, product)
+			return nil
+		}))
+		assert.Equal(t, []int64{3, 1, 2, 4}, ids(each))
+
+		_, err = repo.GetProductsPaged(ctx, domain.ProductFilter{}, domain.ProductSort{{Field: "price"}}, 10, 0)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+		err = repo.EachProduct(ctx, domain.ProductFilter{}, domain.ProductSort{{Field: "id; DROP TABLE products"}}, func(domain.Product) error { return nil })
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("keyset pages follow the last product", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, p := range []domain.Product{
+			{Id: 1, Name: "Chair", AdditionalInfo: "b"},
+			{Id: 2, Name: "Lamp", AdditionalInfo: "a"},
+			{Id: 3, Name: "Chair", AdditionalInfo: "c"},
+			{Id: 4, Name: "Armchair", AdditionalInfo: "a"},
+		} {
+			_, err := repo.UpsertProduct(ctx, p)
+			require.NoError(t, err)
+		}
+
+		page, err := repo.GetProductsAfter(ctx, domain.ProductFilter{}, nil, nil, 3)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{1, 2, 3}, ids(page))
+		page, err = repo.GetProductsAfter(ctx, domain.ProductFilter{}, nil, &page[2], 3)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{4}, ids(page))
+
+		// only sorted fields and id of the last product matter
+		sort := domain.ProductSort{{Field: "name", Desc: true}}
+		page, err = repo.GetProductsAfter(ctx, domain.ProductFilter{}, sort, &domain.Product{Id: 1, Name: "Chair"}, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3, 4}, ids(page))
+
+		page, err = repo.GetProductsAfter(ctx, domain.ProductFilter{Name: "chair"}, domain.ProductSort{{Field: "additionalInfo"}}, &domain.Product{Id: 1, AdditionalInfo: "b"}, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3}, ids(page))
+
+		_, err = repo.GetProductsAfter(ctx, domain.ProductFilter{}, domain.ProductSort{{Field: "price"}}, nil, 10)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("upsert creates then updates", func(t *testing.T) {
+		repo := newRepo(t)
+		product := domain.Product{Id: 10, Name: "Product", AdditionalInfo: "Info"}
+		created, err := repo.UpsertProduct(ctx, product)
+		require.NoError(t, err)
+		assert.True(t, created)
+
+		product.Name = "Renamed"
+		created, err = repo.UpsertProduct(ctx, product)
+		require.NoError(t, err)
+		assert.False(t, created)
+		product.Version = 2
+
+		stored, err := repo.GetProduct(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, product, *stored)
+
+		// ids taken by upsert are never handed out again
+		id, err := repo.StoreProduct(ctx, newProduct)
+		require.NoError(t, err)
+		assert.Greater(t, id, int64(10))
+	})
+
+	t.Run("update product returns previous state", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.StoreProduct(ctx, newProduct)
+		require.NoError(t, err)
+
+		previous, err := repo.UpdateProductById(ctx, id, domain.NewProduct{Name: "New name", AdditionalInfo: "New info"})
+		require.NoError(t, err)
+		assert.Equal(t, domain.Product{Id: id, Name: newProduct.Name, AdditionalInfo: newProduct.AdditionalInfo, Version: 1}, *previous)
+
+		stored, err := repo.GetProduct(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, domain.Product{Id: id, Name: "New name", AdditionalInfo: "New info", Version: 2}, *stored)
+	})
+
+	t.Run("update at expected version", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.StoreProduct(ctx, newProduct)
+		require.NoError(t, err)
+
+		_, err = repo.UpdateProductById(ctx, id, domain.NewProduct{Name: "First edit", AdditionalInfo: "Info", Version: 1})
+		require.NoError(t, err)
+		// second writer read version 1 as well
+		updated, err := repo.UpdateProductById(ctx, id, domain.NewProduct{Name: "Second edit", AdditionalInfo: "Info", Version: 1})
+		assert.Nil(t, updated)
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+
+		stored, err := repo.GetProduct(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, domain.Product{Id: id, Name: "First edit", AdditionalInfo: "Info", Version: 2}, *stored)
+
+		_, err = repo.UpdateProductById(ctx, id+1, domain.NewProduct{Name: "Edit", AdditionalInfo: "Info", Version: 1})
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("update missing product - not found", func(t *testing.T) {
+		repo := newRepo(t)
+		updated, err := repo.UpdateProductById(ctx, 1, newProduct)
+		assert.Nil(t, updated)
+		assert.True(t, errors.Is(err, domain.ErrNotFound))
+	})
+
+	t.Run("delete product returns deleted state", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.StoreProduct(ctx, newProduct)
+		require.NoError(t, err)
+
+		deleted, err := repo.DeleteProductById(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, domain.Product{Id: id, Name: newProduct.Name, AdditionalInfo: newProduct.AdditionalInfo, Version: 1}, *deleted)
+
+		_, err = repo.GetProduct(ctx, id)
+		assert.True(t, errors.Is(err, domain.ErrNotFound))
+	})
+
+	t.Run("delete missing product - not found", func(t *testing.T) {
+		repo := newRepo(t)
+		deleted, err := repo.DeleteProductById(ctx, 1)
+		assert.Nil(t, deleted)
+		assert.True(t, errors.Is(err, domain.ErrNotFound))
+	})
+
+	t.Run("suggest product names by prefix", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, name := range []string{"Lamp", "laptop", "Lamp", "Ladder", "Cable", "La%", "Lab"} {
+			_, err := repo.StoreProduct(ctx, domain.NewProduct{Name: name, AdditionalInfo: "Info"})
+			require.NoError(t, err)
+		}
+
+		names, err := repo.SuggestProductNames(ctx, "la", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"La%", "Lab", "Ladder", "Lamp", "laptop"}, names)
+
+		names, err = repo.SuggestProductNames(ctx, "LA", 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"La%", "Lab"}, names)
+
+		names, err = repo.SuggestProductNames(ctx, "la%", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"La%"}, names, "wildcards must match literally")
+
+		names, err = repo.SuggestProductNames(ctx, "x", 10)
+		require.NoError(t, err)
+		assert.Empty(t, names)
+	})
+
+	t.Run("fuzzy search tolerates typos", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, name := range []string{"Cable", "Laptops", "Laptop", "Desk lamp"} {
+			_, err := repo.StoreProduct(ctx, domain.NewProduct{Name: name, AdditionalInfo: "Info"})
+			require.NoError(t, err)
+		}
+
+		result, err := repo.FuzzySearchProducts(ctx, domain.SearchQuery{Text: "laptp", Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Total)
+		require.Len(t, result.Hits, 2)
+		assert.Equal(t, "Laptop", result.Hits[0].Product.Name, "closer match must come first")
+		assert.Equal(t, "Laptops", result.Hits[1].Product.Name)
+		assert.Greater(t, result.Hits[0].Score, result.Hits[1].Score)
+
+		result, err = repo.FuzzySearchProducts(ctx, domain.SearchQuery{Text: "laptp", Offset: 1, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Total)
+		require.Len(t, result.Hits, 1)
+
+		result, err = repo.FuzzySearchProducts(ctx, domain.SearchQuery{Text: "chair", Limit: 10})
+		require.NoError(t, err)
+		assert.Zero(t, result.Total)
+		assert.Empty(t, result.Hits)
+	})
+
+	t.Run("full-text search ranks name matches first", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, product := range []domain.NewProduct{
+			{Name: "Brass desk", AdditionalInfo: "Goes well with a lamp"},
+			{Name: "Desk lamp", AdditionalInfo: "Brass"},
+			{Name: "Cable", AdditionalInfo: "Black"},
+		} {
+			_, err := repo.StoreProduct(ctx, product)
+			require.NoError(t, err)
+		}
+
+		result, err := repo.SearchProducts(ctx, domain.SearchQuery{Text: "Lamp", Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Total)
+		require.Len(t, result.Hits, 2)
+		assert.Equal(t, "Desk lamp", result.Hits[0].Product.Name, "name match must come first")
+		assert.Equal(t, "Brass desk", result.Hits[1].Product.Name)
+		assert.Greater(t, result.Hits[0].Score, result.Hits[1].Score)
+		assert.Equal(t, map[string][]string{"name": {"Desk <em>lamp</em>"}}, result.Hits[0].Highlights)
+		assert.Contains(t, result.Hits[1].Highlights["additionalInfo"][0], "<em>lamp</em>")
+
+		result, err = repo.SearchProducts(ctx, domain.SearchQuery{Text: "lamp", Offset: 1, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Total)
+		require.Len(t, result.Hits, 1)
+
+		result, err = repo.SearchProducts(ctx, domain.SearchQuery{Text: "brass lamp", Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Total, "every word has to match, in any field")
+
+		result, err = repo.SearchProducts(ctx, domain.SearchQuery{Text: "chair", Limit: 10})
+		require.NoError(t, err)
+		assert.Zero(t, result.Total)
+		assert.Empty(t, result.Hits)
+	})
+
+	t.Run("delete all products returns count", func(t *testing.T) {
+		repo := newRepo(t)
+		for range 3 {
+			_, err := repo.StoreProduct(ctx, newProduct)
+			require.NoError(t, err)
+		}
+
+		deleted, err := repo.DeleteAllProducts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), deleted)
+
+		count, err := repo.CountProducts(ctx, domain.ProductFilter{})
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
+
+func ids(products []domain.Product) []int64 {
+	result := make([]int64, len(products))
+	for i, p := range products {
+		result[i] = p.Id
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type apiKey struct {
+	domain.APIKey
+	hash    string
+	revoked bool
+}
+
+// APIKeyRepository is an in-memory ports.APIKeyRepository
+type APIKeyRepository struct {
+	hooks
+	mu   sync.Mutex
+	keys []apiKey
+	// Lookups counts LookupAPIKey calls that got past hooks
+	Lookups int
+}
+
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{}
+}
+
+// OnCall sets a hook consulted before every call
+func (r *APIKeyRepository) OnCall(hook ErrorHook) {
+	r.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (r *APIKeyRepository) FailWith(method string, err error) {
+	r.failWith(method, err)
+}
+
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key domain.APIKey, hash string) (int64, error) {
+	if err := r.check("CreateAPIKey"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = append(r.keys, apiKey{APIKey: key, hash: hash})
+	return int64(len(r.keys)), nil
+}
+
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id int64) error {
+	if err := r.check("RevokeAPIKey"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id < 1 || id > int64(len(r.keys)) || r.keys[id-1].revoked {
+		return fmt.Errorf("%w: failed to find API key %d", domain.ErrNotFound, id)
+	}
+	r.keys[id-1].revoked = true
+	return nil
+}
+
+func (r *APIKeyRepository) LookupAPIKey(ctx context.Context, hash string) (domain.APIKey, error) {
+	if err := r.check("LookupAPIKey"); err != nil {
+		return domain.APIKey{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Lookups++
+	for _, key := range r.keys {
+		if key.hash == hash && !key.revoked {
+			return key.APIKey, nil
+		}
+	}
+	return domain.APIKey{}, fmt.Errorf("%w: unknown or revoked API key", domain.ErrNotFound)
+}
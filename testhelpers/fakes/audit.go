@@ -0,0 +1,54 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// AuditTrail is an in-memory ports.AuditTrail
+type AuditTrail struct {
+	hooks
+	mu      sync.Mutex
+	entries []domain.AuditEntry
+}
+
+func NewAuditTrail() *AuditTrail {
+	return &AuditTrail{}
+}
+
+// OnCall sets a hook consulted before every call
+func (a *AuditTrail) OnCall(hook ErrorHook) {
+	a.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (a *AuditTrail) FailWith(method string, err error) {
+	a.failWith(method, err)
+}
+
+// Record appends entry to the trail, assigning it the next id
+func (a *AuditTrail) Record(entry domain.AuditEntry) int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry.Id = int64(len(a.entries)) + 1
+	a.entries = append(a.entries, entry)
+	return entry.Id
+}
+
+func (a *AuditTrail) ProductAudit(ctx context.Context, productId int64, before int64, limit int64) ([]domain.AuditEntry, error) {
+	if err := a.check("ProductAudit"); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	result := make([]domain.AuditEntry, 0)
+	for i := len(a.entries) - 1; i >= 0 && int64(len(result)) < limit; i-- {
+		entry := a.entries[i]
+		if entry.ProductId == productId && (before == 0 || entry.Id < before) {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,142 @@
+package fakes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// Cache is an in-memory ports.Cache storing products as JSON, same as redis
+// adapter does
+type Cache struct {
+	hooks
+	mu      sync.Mutex
+	entries map[int64][]byte
+	skus    map[string]int64
+}
+
+func NewCache() *Cache {
+	return &Cache{entries: make(map[int64][]byte), skus: make(map[string]int64)}
+}
+
+// OnCall sets a hook consulted before every call
+func (c *Cache) OnCall(hook ErrorHook) {
+	c.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (c *Cache) FailWith(method string, err error) {
+	c.failWith(method, err)
+}
+
+// Has reports whether product is cached
+func (c *Cache) Has(id int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[id]
+	return ok
+}
+
+// Len returns number of cached products
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *Cache) SetProduct(ctx context.Context, product *domain.Product) error {
+	if err := c.check("SetProduct"); err != nil {
+		return err
+	}
+	data, err := json.Marshal(product)
+	if err != nil {
+		return fmt.Errorf("%w: failed to marshal product. %s", domain.ErrInternalCache, err.Error())
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[product.Id] = data
+	return nil
+}
+
+func (c *Cache) GetJSONProductById(ctx context.Context, id int64) ([]byte, error) {
+	if err := c.check("GetJSONProductById"); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.entries[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: failed to find product %d in cache", domain.ErrNotFound, id)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (c *Cache) SetProductSku(ctx context.Context, sku string, id int64) error {
+	if err := c.check("SetProductSku"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.skus[sku] = id
+	return nil
+}
+
+func (c *Cache) GetProductIdBySku(ctx context.Context, sku string) (int64, error) {
+	if err := c.check("GetProductIdBySku"); err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, ok := c.skus[sku]
+	if !ok {
+		return 0, fmt.Errorf("%w: failed to find sku %q in cache", domain.ErrNotFound, sku)
+	}
+	return id, nil
+}
+
+func (c *Cache) DeleteProductById(ctx context.Context, id int64) error {
+	if err := c.check("DeleteProductById"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[id]; !ok {
+		return fmt.Errorf("%w: product with id=%d not found in cache", domain.ErrNotFound, id)
+	}
+	delete(c.entries, id)
+	return nil
+}
+
+func (c *Cache) SetProducts(ctx context.Context, products []domain.Product) error {
+	if err := c.check("SetProducts"); err != nil {
+		return err
+	}
+	entries := make(map[int64][]byte, len(products))
+	for _, product := range products {
+		data, err := json.Marshal(product)
+		if err != nil {
+			return fmt.Errorf("%w: failed to marshal product. %s", domain.ErrInternalCache, err.Error())
+		}
+		entries[product.Id] = data
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, data := range entries {
+		c.entries[id] = data
+	}
+	return nil
+}
+
+func (c *Cache) ClearCache(ctx context.Context) error {
+	if err := c.check("ClearCache"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[int64][]byte)
+	c.skus = make(map[string]int64)
+	return nil
+}
@@ -0,0 +1,66 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ConfirmationStore is an in-memory ports.ConfirmationStore issuing
+// predictable tokens "token-1", "token-2" and so on
+type ConfirmationStore struct {
+	hooks
+	mu     sync.Mutex
+	issued int
+	tokens map[string]time.Time
+	now    func() time.Time
+}
+
+func NewConfirmationStore() *ConfirmationStore {
+	return &ConfirmationStore{tokens: make(map[string]time.Time), now: time.Now}
+}
+
+// OnCall sets a hook consulted before every call
+func (s *ConfirmationStore) OnCall(hook ErrorHook) {
+	s.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (s *ConfirmationStore) FailWith(method string, err error) {
+	s.failWith(method, err)
+}
+
+// SetClock replaces time source used for expiration
+func (s *ConfirmationStore) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+func (s *ConfirmationStore) Issue(ctx context.Context, action string, ttl time.Duration) (string, error) {
+	if err := s.check("Issue"); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.issued++
+	token := fmt.Sprintf("token-%d", s.issued)
+	s.tokens[action+":"+token] = s.now().Add(ttl)
+	return token, nil
+}
+
+func (s *ConfirmationStore) Consume(ctx context.Context, action string, token string) (bool, error) {
+	if err := s.check("Consume"); err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := action + ":" + token
+	expiresAt, ok := s.tokens[key]
+	if !ok {
+		return false, nil
+	}
+	delete(s.tokens, key)
+	return s.now().Before(expiresAt), nil
+}
@@ -0,0 +1,77 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type dedupEntry struct {
+	response  []byte
+	expiresAt time.Time
+}
+
+// WriteDedupStore is an in-memory ports.WriteDedupStore
+type WriteDedupStore struct {
+	hooks
+	mu      sync.Mutex
+	entries map[string]dedupEntry
+	now     func() time.Time
+}
+
+func NewWriteDedupStore() *WriteDedupStore {
+	return &WriteDedupStore{entries: make(map[string]dedupEntry), now: time.Now}
+}
+
+// OnCall sets a hook consulted before every call
+func (s *WriteDedupStore) OnCall(hook ErrorHook) {
+	s.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (s *WriteDedupStore) FailWith(method string, err error) {
+	s.failWith(method, err)
+}
+
+// SetClock replaces time source used for expiration
+func (s *WriteDedupStore) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+func (s *WriteDedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, []byte, error) {
+	if err := s.check("Claim"); err != nil {
+		return false, nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok && s.now().Before(entry.expiresAt) {
+		return false, entry.response, nil
+	}
+	s.entries[key] = dedupEntry{expiresAt: s.now().Add(ttl)}
+	return true, nil, nil
+}
+
+func (s *WriteDedupStore) Save(ctx context.Context, key string, response []byte) error {
+	if err := s.check("Save"); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok {
+		entry.response = response
+		s.entries[key] = entry
+	}
+	return nil
+}
+
+func (s *WriteDedupStore) Release(ctx context.Context, key string) error {
+	if err := s.check("Release"); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
@@ -0,0 +1,99 @@
+package fakes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+	"github.com/pelyams/simpler_go_service/internal/ports"
+	"github.com/pelyams/simpler_go_service/internal/service"
+	"github.com/pelyams/simpler_go_service/testhelpers/contract"
+)
+
+var (
+	_ ports.Repository        = (*Repository)(nil)
+	_ ports.Cache             = (*Cache)(nil)
+	_ ports.ObjectStore       = (*ObjectStore)(nil)
+	_ ports.SearchIndex       = (*SearchIndex)(nil)
+	_ ports.WebhookRepository = (*WebhookRepository)(nil)
+)
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepository(domain.Product{Id: 5, Name: "Seeded", AdditionalInfo: "Info"})
+
+	id, err := repo.StoreProduct(ctx, domain.NewProduct{Name: "New", AdditionalInfo: "Info"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), id)
+
+	created, err := repo.UpsertProduct(ctx, domain.Product{Id: 2, Name: "Upserted", AdditionalInfo: "Info"})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	page, err := repo.GetProductsPaged(ctx, domain.ProductFilter{}, nil, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{5, 6}, ids(page))
+
+	_, err = repo.DeleteProductById(ctx, 42)
+	assert.True(t, errors.Is(err, domain.ErrNotFound))
+
+	deleted, err := repo.DeleteAllProducts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.Empty(t, repo.Products())
+}
+
+func TestErrorInjection(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepository()
+	repo.FailWith("StoreProduct", domain.ErrInternalDb)
+
+	_, err := repo.StoreProduct(ctx, domain.NewProduct{Name: "New", AdditionalInfo: "Info"})
+	assert.True(t, errors.Is(err, domain.ErrInternalDb))
+
+	repo.FailWith("StoreProduct", nil)
+	_, err = repo.StoreProduct(ctx, domain.NewProduct{Name: "New", AdditionalInfo: "Info"})
+	assert.NoError(t, err)
+
+	cache := NewCache()
+	var calls []string
+	cache.OnCall(func(method string) error {
+		calls = append(calls, method)
+		return domain.ErrInternalCache
+	})
+	assert.True(t, errors.Is(cache.ClearCache(ctx), domain.ErrInternalCache))
+	assert.Equal(t, []string{"ClearCache"}, calls)
+}
+
+func TestWithService(t *testing.T) {
+	ctx := context.Background()
+	repo, cache := NewRepository(), NewCache()
+	svc := service.NewResourceService(repo, cache)
+
+	id, serviceErr := svc.CreateProduct(ctx, domain.NewProduct{Name: "Product", AdditionalInfo: "Info"})
+	require.Nil(t, serviceErr)
+	assert.True(t, cache.Has(id))
+
+	cache.FailWith("GetJSONProductById", domain.ErrInternalCache)
+	data, serviceErr := svc.GetProductById(ctx, id)
+	require.NotNil(t, serviceErr)
+	assert.Nil(t, serviceErr.CriticalError)
+	assert.JSONEq(t, `{"id":1,"name":"Product","additionalInfo":"Info","version":1}`, string(data))
+}
+
+func ids(products []domain.Product) []int64 {
+	result := make([]int64, len(products))
+	for i, p := range products {
+		result[i] = p.Id
+	}
+	return result
+}
+
+func TestContract(t *testing.T) {
+	contract.RunRepositoryTests(t, func(t *testing.T) ports.Repository { return NewRepository() })
+	contract.RunCacheTests(t, func(t *testing.T) ports.Cache { return NewCache() })
+}
@@ -0,0 +1,51 @@
+package fakes
+
+import (
+	"regexp"
+	"strings"
+)
+
+var wordPattern = regexp.MustCompile(`[\pL\pN]+`)
+
+// fullTextMatch roughly mimics Postgres full-text search of a product: every
+// word of query has to occur in name or info, case-insensitively. Unlike
+// Postgres, words are not stemmed and query syntax is not understood. Name
+// matches weigh more, as search_vector weighs them
+func fullTextMatch(name string, info string, query string) (float64, map[string][]string, bool) {
+	words := make(map[string]bool)
+	for _, word := range wordPattern.FindAllString(strings.ToLower(query), -1) {
+		words[word] = true
+	}
+	if len(words) == 0 {
+		return 0, nil, false
+	}
+	nameHighlight, nameMatches := highlightWords(name, words)
+	infoHighlight, infoMatches := highlightWords(info, words)
+	for word := range words {
+		if !nameMatches[word] && !infoMatches[word] {
+			return 0, nil, false
+		}
+	}
+	highlights := make(map[string][]string)
+	if len(nameMatches) > 0 {
+		highlights["name"] = []string{nameHighlight}
+	}
+	if len(infoMatches) > 0 {
+		highlights["additionalInfo"] = []string{infoHighlight}
+	}
+	return float64(2*len(nameMatches) + len(infoMatches)), highlights, true
+}
+
+// highlightWords wraps words of text found in words into <em> tags, returns
+// words it found
+func highlightWords(text string, words map[string]bool) (string, map[string]bool) {
+	matches := make(map[string]bool)
+	highlighted := wordPattern.ReplaceAllStringFunc(text, func(word string) string {
+		if !words[strings.ToLower(word)] {
+			return word
+		}
+		matches[strings.ToLower(word)] = true
+		return "<em>" + word + "</em>"
+	})
+	return highlighted, matches
+}
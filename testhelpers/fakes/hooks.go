@@ -0,0 +1,47 @@
+// Package fakes provides deterministic in-memory implementations of ports
+// for unit tests that need neither mocks nor containers
+package fakes
+
+import "sync"
+
+// ErrorHook is called before every method with its name, e.g. "GetProduct".
+// A non-nil result is returned from the method instead of doing the work
+type ErrorHook func(method string) error
+
+type hooks struct {
+	mu     sync.Mutex
+	hook   ErrorHook
+	errors map[string]error
+}
+
+func (h *hooks) setHook(hook ErrorHook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hook = hook
+}
+
+func (h *hooks) failWith(method string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.errors == nil {
+		h.errors = make(map[string]error)
+	}
+	if err == nil {
+		delete(h.errors, method)
+		return
+	}
+	h.errors[method] = err
+}
+
+func (h *hooks) check(method string) error {
+	h.mu.Lock()
+	hook, err := h.hook, h.errors[method]
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if hook != nil {
+		return hook(method)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LeaseStore holds a single lease instances compete for, see Lease
+type LeaseStore struct {
+	mu        sync.Mutex
+	holder    string
+	expiresAt time.Time
+}
+
+func NewLeaseStore() *LeaseStore {
+	return &LeaseStore{}
+}
+
+// Holder returns instance holding unexpired lease, empty if there is none
+func (s *LeaseStore) Holder() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Now().After(s.expiresAt) {
+		return ""
+	}
+	return s.holder
+}
+
+// Lease returns in-memory ports.LeaderLease of instance
+func (s *LeaseStore) Lease(instance string) *LeaderLease {
+	return &LeaderLease{store: s, instance: instance}
+}
+
+type LeaderLease struct {
+	hooks
+	store    *LeaseStore
+	instance string
+}
+
+// OnCall sets a hook consulted before every call
+func (l *LeaderLease) OnCall(hook ErrorHook) {
+	l.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (l *LeaderLease) FailWith(method string, err error) {
+	l.failWith(method, err)
+}
+
+func (l *LeaderLease) Acquire(ctx context.Context, ttl time.Duration) (bool, error) {
+	if err := l.check("Acquire"); err != nil {
+		return false, err
+	}
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	if l.store.holder != "" && time.Now().Before(l.store.expiresAt) {
+		return false, nil
+	}
+	l.store.holder = l.instance
+	l.store.expiresAt = time.Now().Add(ttl)
+	return true, nil
+}
+
+func (l *LeaderLease) Renew(ctx context.Context, ttl time.Duration) (bool, error) {
+	if err := l.check("Renew"); err != nil {
+		return false, err
+	}
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	if l.store.holder != l.instance || time.Now().After(l.store.expiresAt) {
+		return false, nil
+	}
+	l.store.expiresAt = time.Now().Add(ttl)
+	return true, nil
+}
+
+func (l *LeaderLease) Release(ctx context.Context) error {
+	if err := l.check("Release"); err != nil {
+		return err
+	}
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	if l.store.holder == l.instance {
+		l.store.holder = ""
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package fakes
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// ObjectURL is the base URL objects of ObjectStore are served from
+const ObjectURL = "https://objects.example.com/"
+
+type object struct {
+	data        []byte
+	contentType string
+}
+
+// ObjectStore is an in-memory ports.ObjectStore
+type ObjectStore struct {
+	hooks
+	mu      sync.Mutex
+	objects map[string]object
+}
+
+func NewObjectStore() *ObjectStore {
+	return &ObjectStore{objects: make(map[string]object)}
+}
+
+// OnCall sets a hook consulted before every call
+func (s *ObjectStore) OnCall(hook ErrorHook) {
+	s.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (s *ObjectStore) FailWith(method string, err error) {
+	s.failWith(method, err)
+}
+
+// Get returns content of object under key, false if there is none
+func (s *ObjectStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[key]
+	return o.data, ok
+}
+
+// Len returns number of objects stored
+func (s *ObjectStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.objects)
+}
+
+func (s *ObjectStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) (string, error) {
+	if err := s.check("Put"); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = object{data: data, contentType: contentType}
+	return ObjectURL + key, nil
+}
+
+func (s *ObjectStore) Delete(ctx context.Context, key string) error {
+	if err := s.check("Delete"); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
@@ -0,0 +1,65 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// RateLimiter is an in-memory ports.RateLimiter using the same generic cell
+// rate algorithm as Redis one
+type RateLimiter struct {
+	hooks
+	mu sync.Mutex
+	// theoretical arrival time of the next request per key
+	tats map[string]time.Time
+	now  func() time.Time
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{tats: make(map[string]time.Time), now: time.Now}
+}
+
+// OnCall sets a hook consulted before every call
+func (l *RateLimiter) OnCall(hook ErrorHook) {
+	l.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (l *RateLimiter) FailWith(method string, err error) {
+	l.failWith(method, err)
+}
+
+// SetClock replaces time source requests are counted by
+func (l *RateLimiter) SetClock(now func() time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.now = now
+}
+
+func (l *RateLimiter) Allow(ctx context.Context, key string, limit domain.RateLimit) (domain.RateDecision, error) {
+	if err := l.check("Allow"); err != nil {
+		return domain.RateDecision{}, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	emission := limit.Emission()
+	tat := l.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(emission)
+	diff := now.Sub(next.Add(-emission * time.Duration(limit.Burst)))
+	if diff < 0 {
+		return domain.RateDecision{RetryAfter: -diff, ResetAfter: tat.Sub(now)}, nil
+	}
+	l.tats[key] = next
+	return domain.RateDecision{
+		Allowed:    true,
+		Remaining:  int(diff / emission),
+		ResetAfter: next.Sub(now),
+	}, nil
+}
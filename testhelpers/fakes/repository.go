@@ -0,0 +1,590 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// Repository is an in-memory ports.Repository and ports.CategoryRepository.
+// Products are kept ordered by id, ids are assigned sequentially like
+// postgres serial does. Products may only refer to existing categories.
+// Creation and update times are kept aside, as products do not carry them
+type Repository struct {
+	hooks
+	mu             sync.Mutex
+	products       map[int64]domain.Product
+	times          map[int64]productTimes
+	lastId         int64
+	categories     map[int64]domain.Category
+	lastCategoryId int64
+	lastImageId    int64
+}
+
+// NewRepository seeds products, those without a version get the first one.
+// Categories seeded products refer to are not checked
+func NewRepository(products ...domain.Product) *Repository {
+	r := &Repository{products: make(map[int64]domain.Product), times: make(map[int64]productTimes), categories: make(map[int64]domain.Category)}
+	for _, p := range products {
+		p.Version = max(p.Version, 1)
+		r.products[p.Id] = p
+		r.created(p.Id)
+		r.lastId = max(r.lastId, p.Id)
+	}
+	return r
+}
+
+// OnCall sets a hook consulted before every call
+func (r *Repository) OnCall(hook ErrorHook) {
+	r.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (r *Repository) FailWith(method string, err error) {
+	r.failWith(method, err)
+}
+
+type productTimes struct {
+	createdAt time.Time
+	updatedAt time.Time
+}
+
+// SetTimes sets when product was created and last updated
+func (r *Repository) SetTimes(id int64, createdAt time.Time, updatedAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.times[id] = productTimes{createdAt: createdAt, updatedAt: updatedAt}
+}
+
+func (r *Repository) created(id int64) {
+	now := time.Now()
+	r.times[id] = productTimes{createdAt: now, updatedAt: now}
+}
+
+func (r *Repository) updated(id int64) {
+	times := r.times[id]
+	times.updatedAt = time.Now()
+	r.times[id] = times
+}
+
+// matches applies filter, timestamp bounds included
+func (r *Repository) matches(filter domain.ProductFilter, p domain.Product) bool {
+	times := r.times[p.Id]
+	return filter.Matches(p) && filter.InTimeRange(times.createdAt, times.updatedAt)
+}
+
+// Products returns a copy of stored products ordered by id
+func (r *Repository) Products() []domain.Product {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sorted()
+}
+
+func (r *Repository) sorted() []domain.Product {
+	result := make([]domain.Product, 0, len(r.products))
+	for _, p := range r.products {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result
+}
+
+// sortableFields mirrors columns postgres repository sorts by
+var sortableFields = []string{"id", "name", "additionalInfo"}
+
+func checkSort(sort domain.ProductSort) error {
+	for _, f := range sort {
+		if !slices.Contains(sortableFields, f.Field) {
+			return fmt.Errorf("%w: products can not be sorted by %q", domain.ErrInvalidInput, f.Field)
+		}
+	}
+	return nil
+}
+
+func notFound(id int64) error {
+	return fmt.Errorf("%w: failed to find product %d in DB", domain.ErrNotFound, id)
+}
+
+// checkCategory fails unless category is zero or exists, as foreign key
+// does
+func (r *Repository) checkCategory(id int64) error {
+	if _, ok := r.categories[id]; id != 0 && !ok {
+		return fmt.Errorf("%w: category %d does not exist", domain.ErrUnknownCategory, id)
+	}
+	return nil
+}
+
+// checkSku fails if a product other than id has sku, as unique constraint
+// does. Products without sku do not collide
+func (r *Repository) checkSku(sku string, id int64) error {
+	if sku == "" {
+		return nil
+	}
+	for _, p := range r.products {
+		if p.Sku == sku && p.Id != id {
+			return fmt.Errorf("%w: sku %q belongs to product %d", domain.ErrDuplicateSku, sku, p.Id)
+		}
+	}
+	return nil
+}
+
+func (r *Repository) GetProduct(ctx context.Context, id int64) (*domain.Product, error) {
+	if err := r.check("GetProduct"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.products[id]
+	if !ok {
+		return nil, notFound(id)
+	}
+	return &p, nil
+}
+
+func (r *Repository) GetProductBySku(ctx context.Context, sku string) (*domain.Product, error) {
+	if err := r.check("GetProductBySku"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.products {
+		if p.Sku == sku {
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: failed to find product with sku %q in DB", domain.ErrNotFound, sku)
+}
+
+func (r *Repository) GetAllProducts(ctx context.Context) ([]domain.Product, error) {
+	if err := r.check("GetAllProducts"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sorted(), nil
+}
+
+func (r *Repository) EachProduct(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, fn func(product domain.Product) error) error {
+	if err := r.check("EachProduct"); err != nil {
+		return err
+	}
+	if err := checkSort(sort); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	all := slices.DeleteFunc(r.sorted(), func(p domain.Product) bool { return !r.matches(filter, p) })
+	r.mu.Unlock()
+	slices.SortStableFunc(all, sort.Compare)
+	for _, p := range all {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) GetProductsPaged(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, limit int64, offset int64) ([]domain.Product, error) {
+	if err := r.check("GetProductsPaged"); err != nil {
+		return nil, err
+	}
+	if err := checkSort(sort); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := slices.DeleteFunc(r.sorted(), func(p domain.Product) bool { return !r.matches(filter, p) })
+	slices.SortStableFunc(all, sort.Compare)
+	if offset >= int64(len(all)) {
+		return []domain.Product{}, nil
+	}
+	end := min(offset+limit, int64(len(all)))
+	return all[offset:end], nil
+}
+
+func (r *Repository) GetProductsAfter(ctx context.Context, filter domain.ProductFilter, sort domain.ProductSort, after *domain.Product, limit int64) ([]domain.Product, error) {
+	if err := r.check("GetProductsAfter"); err != nil {
+		return nil, err
+	}
+	if err := checkSort(sort); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := slices.DeleteFunc(r.sorted(), func(p domain.Product) bool {
+		return !r.matches(filter, p) || after != nil && sort.Compare(p, *after) <= 0
+	})
+	slices.SortStableFunc(all, sort.Compare)
+	return all[:min(limit, int64(len(all)))], nil
+}
+
+func (r *Repository) ProductIdRange(ctx context.Context) (int64, int64, error) {
+	if err := r.check("ProductIdRange"); err != nil {
+		return 0, 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := r.sorted()
+	if len(all) == 0 {
+		return 0, 0, nil
+	}
+	return all[0].Id, all[len(all)-1].Id, nil
+}
+
+func (r *Repository) GetProductsInRange(ctx context.Context, fromId int64, toId int64) ([]domain.Product, error) {
+	if err := r.check("GetProductsInRange"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	products := []domain.Product{}
+	for _, p := range r.sorted() {
+		if p.Id >= fromId && p.Id < toId {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
+
+func (r *Repository) CountProducts(ctx context.Context, filter domain.ProductFilter) (int64, error) {
+	if err := r.check("CountProducts"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, p := range r.products {
+		if r.matches(filter, p) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *Repository) SuggestProductNames(ctx context.Context, prefix string, limit int64) ([]string, error) {
+	if err := r.check("SuggestProductNames"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, p := range r.products {
+		if !seen[p.Name] && strings.HasPrefix(strings.ToLower(p.Name), strings.ToLower(prefix)) {
+			seen[p.Name] = true
+			names = append(names, p.Name)
+		}
+	}
+	sort.Strings(names)
+	return names[:min(int64(len(names)), limit)], nil
+}
+
+// FuzzySearchProducts uses pg_trgm default similarity threshold, 0.3
+func (r *Repository) FuzzySearchProducts(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error) {
+	if err := r.check("FuzzySearchProducts"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hits := []domain.SearchHit{}
+	for _, p := range r.sorted() {
+		if score := trigramSimilarity(p.Name, query.Text); score >= 0.3 {
+			hits = append(hits, domain.SearchHit{Product: p, Score: score})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	total := int64(len(hits))
+	start := min(query.Offset, total)
+	end := min(start+query.Limit, total)
+	return &domain.SearchResult{Total: total, Hits: hits[start:end]}, nil
+}
+
+// SearchProducts matches whole words, see fullTextMatch
+func (r *Repository) SearchProducts(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error) {
+	if err := r.check("SearchProducts"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hits := []domain.SearchHit{}
+	for _, p := range r.sorted() {
+		if score, highlights, ok := fullTextMatch(p.Name, p.AdditionalInfo, query.Text); ok {
+			hits = append(hits, domain.SearchHit{Product: p, Score: score, Highlights: highlights})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	total := int64(len(hits))
+	start := min(query.Offset, total)
+	end := min(start+query.Limit, total)
+	return &domain.SearchResult{Total: total, Hits: hits[start:end]}, nil
+}
+
+func (r *Repository) StoreProduct(ctx context.Context, product domain.NewProduct) (int64, error) {
+	if err := r.check("StoreProduct"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.checkCategory(product.CategoryId); err != nil {
+		return 0, err
+	}
+	if err := r.checkSku(product.Sku, 0); err != nil {
+		return 0, err
+	}
+	r.lastId++
+	r.products[r.lastId] = domain.Product{Id: r.lastId, Name: product.Name, AdditionalInfo: product.AdditionalInfo, Version: 1, CategoryId: product.CategoryId, Sku: product.Sku}
+	r.created(r.lastId)
+	return r.lastId, nil
+}
+
+func (r *Repository) StoreProducts(ctx context.Context, products []domain.NewProduct) ([]int64, error) {
+	if err := r.check("StoreProducts"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	skus := make(map[string]bool)
+	for _, product := range products {
+		if err := r.checkCategory(product.CategoryId); err != nil {
+			return nil, err
+		}
+		if err := r.checkSku(product.Sku, 0); err != nil {
+			return nil, err
+		}
+		if product.Sku != "" && skus[product.Sku] {
+			return nil, fmt.Errorf("%w: sku %q is given twice", domain.ErrDuplicateSku, product.Sku)
+		}
+		skus[product.Sku] = true
+	}
+	ids := make([]int64, len(products))
+	for i, product := range products {
+		r.lastId++
+		r.products[r.lastId] = domain.Product{Id: r.lastId, Name: product.Name, AdditionalInfo: product.AdditionalInfo, Version: 1, CategoryId: product.CategoryId, Sku: product.Sku}
+		r.created(r.lastId)
+		ids[i] = r.lastId
+	}
+	return ids, nil
+}
+
+func (r *Repository) UpsertProduct(ctx context.Context, product domain.Product) (bool, error) {
+	if err := r.check("UpsertProduct"); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.checkCategory(product.CategoryId); err != nil {
+		return false, err
+	}
+	if err := r.checkSku(product.Sku, product.Id); err != nil {
+		return false, err
+	}
+	old, exists := r.products[product.Id]
+	product.Version, product.Stock, product.Tags, product.Images = old.Version+1, old.Stock, old.Tags, old.Images
+	r.products[product.Id] = product
+	if exists {
+		r.updated(product.Id)
+	} else {
+		r.created(product.Id)
+	}
+	r.lastId = max(r.lastId, product.Id)
+	return !exists, nil
+}
+
+// UpdateProductById returns product state before the update, as postgres
+// adapter does
+func (r *Repository) UpdateProductById(ctx context.Context, id int64, product domain.NewProduct) (*domain.Product, error) {
+	if err := r.check("UpdateProductById"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old, ok := r.products[id]
+	if !ok {
+		return nil, notFound(id)
+	}
+	if product.Version != 0 && product.Version != old.Version {
+		return nil, fmt.Errorf("%w: product %d is at version %d, not %d", domain.ErrVersionConflict, id, old.Version, product.Version)
+	}
+	if err := r.checkCategory(product.CategoryId); err != nil {
+		return nil, err
+	}
+	if err := r.checkSku(product.Sku, id); err != nil {
+		return nil, err
+	}
+	r.products[id] = domain.Product{Id: id, Name: product.Name, AdditionalInfo: product.AdditionalInfo, Version: old.Version + 1, CategoryId: product.CategoryId, Sku: product.Sku, Stock: old.Stock, Tags: old.Tags, Images: old.Images}
+	r.updated(id)
+	return &old, nil
+}
+
+func (r *Repository) DeleteProductById(ctx context.Context, id int64) (*domain.Product, error) {
+	if err := r.check("DeleteProductById"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.products[id]
+	if !ok {
+		return nil, notFound(id)
+	}
+	delete(r.products, id)
+	delete(r.times, id)
+	return &p, nil
+}
+
+func (r *Repository) AddProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, error) {
+	if err := r.check("AddProductTags"); err != nil {
+		return nil, err
+	}
+	return r.retag(id, func(current []string) []string { return domain.AddTags(current, tags) })
+}
+
+func (r *Repository) RemoveProductTags(ctx context.Context, id int64, tags []string) (*domain.Product, error) {
+	if err := r.check("RemoveProductTags"); err != nil {
+		return nil, err
+	}
+	return r.retag(id, func(current []string) []string { return domain.RemoveTags(current, tags) })
+}
+
+// retag bumps version only when tags change, as postgres adapter does
+func (r *Repository) retag(id int64, retag func(current []string) []string) (*domain.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.products[id]
+	if !ok {
+		return nil, notFound(id)
+	}
+	tags := retag(p.Tags)
+	if !slices.Equal(p.Tags, tags) {
+		p.Tags = tags
+		p.Version++
+		r.products[id] = p
+	}
+	return &p, nil
+}
+
+func (r *Repository) AdjustProductStock(ctx context.Context, id int64, delta int64) (*domain.Product, error) {
+	if err := r.check("AdjustProductStock"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.products[id]
+	if !ok {
+		return nil, notFound(id)
+	}
+	if p.Stock+delta < 0 {
+		return nil, fmt.Errorf("%w: product %d has %d in stock, can not take %d", domain.ErrInsufficientStock, id, p.Stock, -delta)
+	}
+	p.Stock += delta
+	p.Version++
+	r.products[id] = p
+	return &p, nil
+}
+
+func (r *Repository) AddProductImage(ctx context.Context, id int64, image domain.ProductImage) (*domain.Product, error) {
+	if err := r.check("AddProductImage"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.products[id]
+	if !ok {
+		return nil, notFound(id)
+	}
+	r.lastImageId++
+	image.Id, image.CreatedAt = r.lastImageId, time.Now().UTC()
+	p.Images = append(slices.Clip(p.Images), image)
+	p.Version++
+	r.products[id] = p
+	return &p, nil
+}
+
+func (r *Repository) DeleteAllProducts(ctx context.Context) (int64, error) {
+	if err := r.check("DeleteAllProducts"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deleted := int64(len(r.products))
+	r.products = make(map[int64]domain.Product)
+	r.times = make(map[int64]productTimes)
+	return deleted, nil
+}
+
+func (r *Repository) ListCategories(ctx context.Context) ([]domain.Category, error) {
+	if err := r.check("ListCategories"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	categories := make([]domain.Category, 0, len(r.categories))
+	for _, category := range r.categories {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Id < categories[j].Id })
+	return categories, nil
+}
+
+func (r *Repository) GetCategory(ctx context.Context, id int64) (*domain.Category, error) {
+	if err := r.check("GetCategory"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	category, ok := r.categories[id]
+	if !ok {
+		return nil, categoryNotFound(id)
+	}
+	return &category, nil
+}
+
+func (r *Repository) StoreCategory(ctx context.Context, name string) (int64, error) {
+	if err := r.check("StoreCategory"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastCategoryId++
+	r.categories[r.lastCategoryId] = domain.Category{Id: r.lastCategoryId, Name: name}
+	return r.lastCategoryId, nil
+}
+
+func (r *Repository) UpdateCategory(ctx context.Context, category domain.Category) error {
+	if err := r.check("UpdateCategory"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.categories[category.Id]; !ok {
+		return categoryNotFound(category.Id)
+	}
+	r.categories[category.Id] = category
+	return nil
+}
+
+func (r *Repository) DeleteCategory(ctx context.Context, id int64) error {
+	if err := r.check("DeleteCategory"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.categories[id]; !ok {
+		return categoryNotFound(id)
+	}
+	for _, p := range r.products {
+		if p.CategoryId == id {
+			return fmt.Errorf("%w: category %d", domain.ErrCategoryInUse, id)
+		}
+	}
+	delete(r.categories, id)
+	return nil
+}
+
+func categoryNotFound(id int64) error {
+	return fmt.Errorf("%w: failed to find category %d in DB", domain.ErrNotFound, id)
+}
@@ -0,0 +1,122 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// SearchIndex is an in-memory ports.SearchIndex, matching the way
+// Repository.SearchProducts does. Facets are counted over names
+type SearchIndex struct {
+	hooks
+	mu       sync.Mutex
+	products map[int64]domain.Product
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{products: make(map[int64]domain.Product)}
+}
+
+// OnCall sets a hook consulted before every call
+func (s *SearchIndex) OnCall(hook ErrorHook) {
+	s.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (s *SearchIndex) FailWith(method string, err error) {
+	s.failWith(method, err)
+}
+
+// Get returns indexed copy of product, false if there is none
+func (s *SearchIndex) Get(id int64) (domain.Product, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	product, ok := s.products[id]
+	return product, ok
+}
+
+// Len returns number of indexed products
+func (s *SearchIndex) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.products)
+}
+
+func (s *SearchIndex) Index(ctx context.Context, product *domain.Product) error {
+	if err := s.check("Index"); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.products[product.Id] = *product
+	return nil
+}
+
+func (s *SearchIndex) Delete(ctx context.Context, id int64) error {
+	if err := s.check("Delete"); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.products, id)
+	return nil
+}
+
+func (s *SearchIndex) DeleteAll(ctx context.Context) error {
+	if err := s.check("DeleteAll"); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.products)
+	return nil
+}
+
+func (s *SearchIndex) Query(ctx context.Context, query domain.SearchQuery) (*domain.SearchResult, error) {
+	if err := s.check("Query"); err != nil {
+		return nil, err
+	}
+	for _, facet := range query.Facets {
+		if facet != "name" {
+			return nil, fmt.Errorf("%w: unknown facet %q", domain.ErrInvalidInput, facet)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hits := []domain.SearchHit{}
+	names := make(map[string]int64)
+	for _, p := range s.products {
+		if score, highlights, ok := fullTextMatch(p.Name, p.AdditionalInfo, query.Text); ok {
+			hits = append(hits, domain.SearchHit{Product: p, Score: score, Highlights: highlights})
+			names[p.Name]++
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Product.Id < hits[j].Product.Id
+	})
+	total := int64(len(hits))
+	start := min(query.Offset, total)
+	end := min(start+query.Limit, total)
+	result := &domain.SearchResult{Total: total, Hits: hits[start:end]}
+	if len(query.Facets) > 0 {
+		buckets := []domain.FacetBucket{}
+		for name, count := range names {
+			buckets = append(buckets, domain.FacetBucket{Value: name, Count: count})
+		}
+		sort.Slice(buckets, func(i, j int) bool {
+			if buckets[i].Count != buckets[j].Count {
+				return buckets[i].Count > buckets[j].Count
+			}
+			return buckets[i].Value < buckets[j].Value
+		})
+		result.Facets = map[string][]domain.FacetBucket{"name": buckets}
+	}
+	return result, nil
+}
@@ -0,0 +1,37 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SignatureReplayStore is an in-memory ports.SignatureReplayStore
+type SignatureReplayStore struct {
+	hooks
+	mu      sync.Mutex
+	claimed map[string]time.Time
+	now     func() time.Time
+}
+
+func NewSignatureReplayStore() *SignatureReplayStore {
+	return &SignatureReplayStore{claimed: make(map[string]time.Time), now: time.Now}
+}
+
+// FailWith makes method return err until called again with nil error
+func (s *SignatureReplayStore) FailWith(method string, err error) {
+	s.failWith(method, err)
+}
+
+func (s *SignatureReplayStore) Claim(ctx context.Context, signature string, ttl time.Duration) (bool, error) {
+	if err := s.check("Claim"); err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if expiresAt, ok := s.claimed[signature]; ok && s.now().Before(expiresAt) {
+		return false, nil
+	}
+	s.claimed[signature] = s.now().Add(ttl)
+	return true, nil
+}
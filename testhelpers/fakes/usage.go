@@ -0,0 +1,141 @@
+package fakes
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type usageKey struct {
+	client string
+	day    time.Time
+}
+
+type usageTable map[usageKey]domain.DailyUsage
+
+func (t usageTable) add(u domain.DailyUsage) {
+	key := usageKey{client: u.Client, day: u.Day.UTC().Truncate(24 * time.Hour)}
+	sum, ok := t[key]
+	if !ok {
+		sum = domain.DailyUsage{Day: key.day, Usage: domain.Usage{Client: u.Client}}
+	}
+	sum.Requests += u.Requests
+	sum.Errors += u.Errors
+	sum.BytesIn += u.BytesIn
+	sum.BytesOut += u.BytesOut
+	t[key] = sum
+}
+
+// UsageCounter is an in-memory ports.UsageCounter
+type UsageCounter struct {
+	hooks
+	mu    sync.Mutex
+	usage usageTable
+}
+
+func NewUsageCounter() *UsageCounter {
+	return &UsageCounter{usage: make(usageTable)}
+}
+
+// OnCall sets a hook consulted before every call
+func (c *UsageCounter) OnCall(hook ErrorHook) {
+	c.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (c *UsageCounter) FailWith(method string, err error) {
+	c.failWith(method, err)
+}
+
+func (c *UsageCounter) Add(ctx context.Context, usage domain.DailyUsage) error {
+	if err := c.check("Add"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.usage.add(usage)
+	return nil
+}
+
+func (c *UsageCounter) Drain(ctx context.Context) ([]domain.DailyUsage, error) {
+	if err := c.check("Drain"); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	drained := make([]domain.DailyUsage, 0, len(c.usage))
+	for _, u := range c.usage {
+		drained = append(drained, u)
+	}
+	clear(c.usage)
+	sort.Slice(drained, func(i, j int) bool { return drained[i].Client < drained[j].Client })
+	return drained, nil
+}
+
+// UsageRepository is an in-memory ports.UsageRepository
+type UsageRepository struct {
+	hooks
+	mu    sync.Mutex
+	usage usageTable
+}
+
+func NewUsageRepository() *UsageRepository {
+	return &UsageRepository{usage: make(usageTable)}
+}
+
+// OnCall sets a hook consulted before every call
+func (r *UsageRepository) OnCall(hook ErrorHook) {
+	r.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (r *UsageRepository) FailWith(method string, err error) {
+	r.failWith(method, err)
+}
+
+func (r *UsageRepository) AddUsage(ctx context.Context, usage []domain.DailyUsage) error {
+	if err := r.check("AddUsage"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range usage {
+		r.usage.add(u)
+	}
+	return nil
+}
+
+func (r *UsageRepository) GetUsage(ctx context.Context, from time.Time, to time.Time) ([]domain.Usage, error) {
+	if err := r.check("GetUsage"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	totals := make(map[string]domain.Usage)
+	for key, u := range r.usage {
+		if key.day.Before(from) || key.day.After(to) {
+			continue
+		}
+		sum := totals[key.client]
+		sum.Client = key.client
+		sum.Requests += u.Requests
+		sum.Errors += u.Errors
+		sum.BytesIn += u.BytesIn
+		sum.BytesOut += u.BytesOut
+		totals[key.client] = sum
+	}
+	result := make([]domain.Usage, 0, len(totals))
+	for _, u := range totals {
+		result = append(result, u)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Requests != result[j].Requests {
+			return result[i].Requests > result[j].Requests
+		}
+		return result[i].Client < result[j].Client
+	})
+	return result, nil
+}
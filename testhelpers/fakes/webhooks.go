@@ -0,0 +1,182 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+// WebhookDelivery is a queued delivery as WebhookRepository keeps it
+type WebhookDelivery struct {
+	domain.WebhookDelivery
+	NextAttemptAt time.Time
+	LastError     string
+	Failed        bool
+}
+
+// WebhookRepository is an in-memory ports.WebhookRepository. Completed
+// deliveries are removed, like Postgres does
+type WebhookRepository struct {
+	hooks
+	mu         sync.Mutex
+	webhooks   []domain.Webhook
+	deliveries []*WebhookDelivery
+	lastId     int64
+}
+
+func NewWebhookRepository() *WebhookRepository {
+	return &WebhookRepository{}
+}
+
+// OnCall sets a hook consulted before every call
+func (r *WebhookRepository) OnCall(hook ErrorHook) {
+	r.setHook(hook)
+}
+
+// FailWith makes method return err until called again with nil error
+func (r *WebhookRepository) FailWith(method string, err error) {
+	r.failWith(method, err)
+}
+
+// Deliveries returns copies of queued deliveries, failed ones included
+func (r *WebhookRepository) Deliveries() []WebhookDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deliveries := make([]WebhookDelivery, len(r.deliveries))
+	for i, d := range r.deliveries {
+		deliveries[i] = *d
+	}
+	return deliveries
+}
+
+// MakeDue makes pending deliveries due now, as if their delay has passed
+func (r *WebhookRepository) MakeDue() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.deliveries {
+		d.NextAttemptAt = time.Now()
+	}
+}
+
+func (r *WebhookRepository) CreateWebhook(ctx context.Context, webhook domain.Webhook) (domain.Webhook, error) {
+	if err := r.check("CreateWebhook"); err != nil {
+		return domain.Webhook{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastId++
+	webhook.Id, webhook.CreatedAt = r.lastId, time.Now().UTC()
+	r.webhooks = append(r.webhooks, webhook)
+	return webhook, nil
+}
+
+func (r *WebhookRepository) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	if err := r.check("ListWebhooks"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.webhooks), nil
+}
+
+func (r *WebhookRepository) DeleteWebhook(ctx context.Context, id int64) error {
+	if err := r.check("DeleteWebhook"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := slices.IndexFunc(r.webhooks, func(w domain.Webhook) bool { return w.Id == id })
+	if i < 0 {
+		return fmt.Errorf("%w: failed to find webhook %d", domain.ErrNotFound, id)
+	}
+	r.webhooks = slices.Delete(r.webhooks, i, i+1)
+	r.deliveries = slices.DeleteFunc(r.deliveries, func(d *WebhookDelivery) bool { return d.WebhookId == id })
+	return nil
+}
+
+func (r *WebhookRepository) EnqueueWebhookDeliveries(ctx context.Context, event domain.ProductEvent, payload []byte) (int, error) {
+	if err := r.check("EnqueueWebhookDeliveries"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	queued := 0
+	for _, w := range r.webhooks {
+		if !slices.Contains(w.EventTypes, event.Type) {
+			continue
+		}
+		r.lastId++
+		r.deliveries = append(r.deliveries, &WebhookDelivery{
+			WebhookDelivery: domain.WebhookDelivery{Id: r.lastId, WebhookId: w.Id, URL: w.URL, Secret: w.Secret, EventId: event.Id, EventType: event.Type, Payload: payload, Traceparent: event.Traceparent},
+			NextAttemptAt:   time.Now(),
+		})
+		queued++
+	}
+	return queued, nil
+}
+
+func (r *WebhookRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	if err := r.check("ClaimWebhookDeliveries"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	claimed := []domain.WebhookDelivery{}
+	for _, d := range r.deliveries {
+		if len(claimed) == limit {
+			break
+		}
+		if d.Failed || d.NextAttemptAt.After(now) {
+			continue
+		}
+		d.Attempts++
+		d.NextAttemptAt = now.Add(lease)
+		claimed = append(claimed, d.WebhookDelivery)
+	}
+	return claimed, nil
+}
+
+func (r *WebhookRepository) CompleteWebhookDelivery(ctx context.Context, id int64) error {
+	if err := r.check("CompleteWebhookDelivery"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries = slices.DeleteFunc(r.deliveries, func(d *WebhookDelivery) bool { return d.Id == id })
+	return nil
+}
+
+func (r *WebhookRepository) RetryWebhookDelivery(ctx context.Context, id int64, delay time.Duration, lastError string) error {
+	if err := r.check("RetryWebhookDelivery"); err != nil {
+		return err
+	}
+	r.update(id, func(d *WebhookDelivery) {
+		d.NextAttemptAt, d.LastError = time.Now().Add(delay), lastError
+	})
+	return nil
+}
+
+func (r *WebhookRepository) FailWebhookDelivery(ctx context.Context, id int64, lastError string) error {
+	if err := r.check("FailWebhookDelivery"); err != nil {
+		return err
+	}
+	r.update(id, func(d *WebhookDelivery) {
+		d.Failed, d.LastError = true, lastError
+	})
+	return nil
+}
+
+func (r *WebhookRepository) update(id int64, fn func(d *WebhookDelivery)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.deliveries {
+		if d.Id == id {
+			fn(d)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package testhelpers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pelyams/simpler_go_service/internal/domain"
+)
+
+type productFixture struct {
+	Id             int64  `yaml:"id"`
+	Name           string `yaml:"name"`
+	AdditionalInfo string `yaml:"additionalInfo"`
+}
+
+// LoadProducts inserts products from a YAML fixture file. Relative paths are
+// resolved against testhelpers directory, so fixtures are shared by all tests
+func LoadProducts(db *sql.DB, path string) ([]domain.Product, error) {
+	if !filepath.IsAbs(path) {
+		_, file, _, _ := runtime.Caller(0)
+		path = filepath.Join(filepath.Dir(file), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	var fixtures []productFixture
+	if err := yaml.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	products := make([]domain.Product, len(fixtures))
+	for i, f := range fixtures {
+		products[i] = domain.Product{Id: f.Id, Name: f.Name, AdditionalInfo: f.AdditionalInfo}
+	}
+	return InsertProducts(db, products...)
+}
+
+// InsertProducts stores products in a single transaction and returns them with
+// ids. Products with zero id get one from the sequence; explicit ids move the
+// sequence past them, so later inserts in the test do not collide
+func InsertProducts(db *sql.DB, products ...domain.Product) ([]domain.Product, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	inserted := make([]domain.Product, 0, len(products))
+	explicitIds := false
+	for _, p := range products {
+		if p.Id == 0 {
+			err = tx.QueryRow(
+				"INSERT INTO products (name, additional_info) VALUES ($1, $2) RETURNING id",
+				p.Name, p.AdditionalInfo).Scan(&p.Id)
+		} else {
+			explicitIds = true
+			_, err = tx.Exec(
+				"INSERT INTO products (id, name, additional_info) VALUES ($1, $2, $3)",
+				p.Id, p.Name, p.AdditionalInfo)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert product %q: %w", p.Name, err)
+		}
+		inserted = append(inserted, p)
+	}
+	if explicitIds {
+		_, err = tx.Exec("SELECT setval('products_id_seq', (SELECT MAX(id) FROM products))")
+		if err != nil {
+			return nil, fmt.Errorf("failed to move products sequence: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
+// GenerateProducts returns n products without ids named "Product #i" with
+// "Description for product #i" as additional info
+func GenerateProducts(n int) []domain.Product {
+	products := make([]domain.Product, n)
+	for i := range products {
+		products[i] = domain.Product{
+			Name:           fmt.Sprintf("Product #%d", i),
+			AdditionalInfo: fmt.Sprintf("Description for product #%d", i),
+		}
+	}
+	return products
+}
+
+// SeedCache writes products to redis the same way cache adapter does
+func SeedCache(ctx context.Context, client *redis.Client, products ...domain.Product) error {
+	for _, p := range products {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		if err := client.Set(ctx, fmt.Sprintf("product:%d", p.Id), data, 0).Err(); err != nil {
+			return fmt.Errorf("failed to seed product %d: %w", p.Id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package testhelpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// UpdateGoldenEnv makes AssertGolden rewrite golden files instead of comparing
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+type goldenConfig struct {
+	headers []string
+	ignored map[string]struct{}
+}
+
+type GoldenOption func(*goldenConfig)
+
+// WithGoldenHeaders records given headers in addition to Content-Type
+func WithGoldenHeaders(headers ...string) GoldenOption {
+	return func(c *goldenConfig) {
+		c.headers = append(c.headers, headers...)
+	}
+}
+
+// IgnoreGoldenFields replaces values of JSON fields with given names, at any
+// depth, with a placeholder, so generated ids or timestamps do not break tests
+func IgnoreGoldenFields(fields ...string) GoldenOption {
+	return func(c *goldenConfig) {
+		for _, f := range fields {
+			c.ignored[f] = struct{}{}
+		}
+	}
+}
+
+// AssertGolden compares recorded response against testdata/<name>.golden of
+// the calling package. Status, selected headers and JSON body with sorted
+// keys are recorded, so formatting changes do not show up as diffs.
+// Run tests with UPDATE_GOLDEN=1 to record new golden files
+func AssertGolden(t *testing.T, name string, rec *httptest.ResponseRecorder, opts ...GoldenOption) {
+	t.Helper()
+	cfg := &goldenConfig{headers: []string{"Content-Type"}, ignored: make(map[string]struct{})}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	actual, err := snapshot(rec.Result(), cfg)
+	require.NoError(t, err)
+
+	path := filepath.Join("testdata", name+".golden")
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, actual, 0o644))
+		return
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "golden file is missing, run tests with %s=1 to record it", UpdateGoldenEnv)
+	assert.Equal(t, string(expected), string(actual), "response differs from %s", path)
+}
+
+func snapshot(resp *http.Response, cfg *goldenConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d %s\n", resp.StatusCode, http.StatusText(resp.StatusCode))
+
+	headers := append([]string{}, cfg.headers...)
+	sort.Strings(headers)
+	for _, h := range headers {
+		if value := resp.Header.Get(h); value != "" {
+			fmt.Fprintf(&buf, "%s: %s\n", http.CanonicalHeaderKey(h), value)
+		}
+	}
+	buf.WriteString("\n")
+
+	defer resp.Body.Close()
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "json") || body.Len() == 0 {
+		buf.Write(body.Bytes())
+		return buf.Bytes(), nil
+	}
+
+	var document interface{}
+	decoder := json.NewDecoder(&body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to decode json body: %w", err)
+	}
+	// encoding/json writes map keys sorted
+	normalized, err := json.MarshalIndent(ignoreFields(document, cfg.ignored), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(normalized)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+func ignoreFields(value interface{}, ignored map[string]struct{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if _, ok := ignored[key]; ok {
+				v[key] = "<ignored>"
+			} else {
+				v[key] = ignoreFields(field, ignored)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = ignoreFields(item, ignored)
+		}
+	}
+	return value
+}
@@ -21,12 +21,17 @@ func CreatePostgresContainer(ctx context.Context) (*PostgresContainer, error) {
 	_, path, _, _ := runtime.Caller(0)
 	pwd := filepath.Dir(path)
 
+	fixedPort, err := withFixedHostPort("5432/tcp")
+	if err != nil {
+		return nil, err
+	}
 	pgContainer, err := postgres.Run(ctx, "postgres:17-alpine",
 		postgres.WithInitScripts(filepath.Join(pwd, "..", "sql", "init.sql")),
 		postgres.WithDatabase("test-db"),
 		postgres.WithUsername("postgres"),
 		postgres.WithPassword("postgres"),
 		testcontainers.WithHostPortAccess(5432),
+		fixedPort,
 		testcontainers.WithWaitStrategy(
 			wait.ForLog("database system is ready to accept connections").
 				WithOccurrence(2).WithStartupTimeout(5*time.Second)),
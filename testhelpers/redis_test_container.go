@@ -16,11 +16,16 @@ type RedisContainer struct {
 }
 
 func CreateRedisContainer(ctx context.Context) (*RedisContainer, error) {
+	fixedPort, err := withFixedHostPort("6379/tcp")
+	if err != nil {
+		return nil, err
+	}
 	redisContainer, err := redis.Run(ctx,
 		"redis:7.2",
 		redis.WithSnapshotting(10, 1),
 		redis.WithLogLevel(redis.LogLevelVerbose),
 		testcontainers.WithHostPortAccess(6379),
+		fixedPort,
 		testcontainers.WithWaitStrategy(
 			wait.ForLog("Ready to accept connections").WithStartupTimeout(3*time.Second),
 		),
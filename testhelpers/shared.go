@@ -0,0 +1,175 @@
+package testhelpers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// restartTimeout bounds waiting for a restarted container to accept connections
+const restartTimeout = 15 * time.Second
+
+// withFixedHostPort binds container port to a free host port chosen up front.
+// Docker picks a new random port on every start otherwise, so a container
+// restarted after a simulated outage would be unreachable for existing clients
+func withFixedHostPort(containerPort nat.Port) (testcontainers.CustomizeRequestOption, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find free port: %w", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	return testcontainers.WithHostConfigModifier(func(hostConfig *container.HostConfig) {
+		hostConfig.PortBindings = nat.PortMap{
+			containerPort: {{HostIP: "127.0.0.1", HostPort: strconv.Itoa(port)}},
+		}
+	}), nil
+}
+
+// ensureRunning starts container again if a test stopped it
+func ensureRunning(ctx context.Context, c testcontainers.Container) error {
+	state, err := c.State(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get container state: %w", err)
+	}
+	if state.Running {
+		return nil
+	}
+	if err := c.Start(ctx); err != nil {
+		return fmt.Errorf("failed to restart container: %w", err)
+	}
+	return nil
+}
+
+func waitFor(ctx context.Context, ping func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, restartTimeout)
+	defer cancel()
+	for {
+		err := ping(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("container is not ready: %w", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Reset brings database back to the state right after init.sql: restarts the
+// container if it was stopped and truncates every table in public schema.
+// Meant to be called between tests sharing one container
+func (c *PostgresContainer) Reset(ctx context.Context) error {
+	if err := ensureRunning(ctx, c); err != nil {
+		return err
+	}
+	db, err := sql.Open("postgres", c.ConnectionString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := waitFor(ctx, db.PingContext); err != nil {
+		return err
+	}
+
+	var tables string
+	err = db.QueryRowContext(ctx,
+		"SELECT COALESCE(string_agg(quote_ident(tablename), ', '), '') FROM pg_tables WHERE schemaname = 'public'").
+		Scan(&tables)
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+	if tables == "" {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, "TRUNCATE TABLE "+tables+" RESTART IDENTITY CASCADE"); err != nil {
+		return fmt.Errorf("failed to truncate tables: %w", err)
+	}
+	return nil
+}
+
+// Reset restarts the container if it was stopped and flushes all databases
+func (c *RedisContainer) Reset(ctx context.Context) error {
+	if err := ensureRunning(ctx, c); err != nil {
+		return err
+	}
+	client := redis.NewClient(&redis.Options{Addr: c.ConnectionString})
+	defer client.Close()
+	if err := waitFor(ctx, func(ctx context.Context) error { return client.Ping(ctx).Err() }); err != nil {
+		return err
+	}
+	if err := client.FlushAll(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to flush redis: %w", err)
+	}
+	return nil
+}
+
+var shared struct {
+	mu       sync.Mutex
+	postgres *PostgresContainer
+	redis    *RedisContainer
+}
+
+// SharedPostgresContainer returns a container shared by the whole test
+// binary, creating it on first use. Call TerminateSharedContainers from
+// TestMain once tests are done
+func SharedPostgresContainer(ctx context.Context) (*PostgresContainer, error) {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	if shared.postgres == nil {
+		pgContainer, err := CreatePostgresContainer(ctx)
+		if err != nil {
+			return nil, err
+		}
+		shared.postgres = pgContainer
+	}
+	return shared.postgres, nil
+}
+
+// SharedRedisContainer is SharedPostgresContainer counterpart for redis
+func SharedRedisContainer(ctx context.Context) (*RedisContainer, error) {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	if shared.redis == nil {
+		redisContainer, err := CreateRedisContainer(ctx)
+		if err != nil {
+			return nil, err
+		}
+		shared.redis = redisContainer
+	}
+	return shared.redis, nil
+}
+
+func TerminateSharedContainers(ctx context.Context) error {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	var errs []error
+	if shared.postgres != nil {
+		if err := shared.postgres.Terminate(ctx); err != nil {
+			errs = append(errs, err)
+		}
+		shared.postgres = nil
+	}
+	if shared.redis != nil {
+		if err := shared.redis.Terminate(ctx); err != nil {
+			errs = append(errs, err)
+		}
+		shared.redis = nil
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to terminate shared containers: %v", errs)
+	}
+	return nil
+}